@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// readAliases returns the aliases of the user config and publish.json without
+// reporting configuration problems, which the command the alias runs reports
+// itself. Unlike readConfig it never writes a default publish.json, since the
+// command may just be mistyped.
+func readAliases() map[string]string {
+	var layers []configLayer
+	if userConfig, _, err := loadUserConfig(); err == nil {
+		layers = append(layers, configLayer{Values: userConfig.Publish})
+	}
+	if path, err := repositoryConfigPath(); err == nil {
+		_, statErr := os.Stat(path)
+		_, tracked, _ := readIndexFile(path)
+		if statErr == nil || tracked {
+			if layer, err := readRepositoryLayer(); err == nil {
+				layers = append(layers, layer)
+			}
+		}
+	}
+	resolved, err := resolveConfig(layers)
+	if err != nil {
+		return nil
+	}
+	return resolved.Config.Aliases
+}
+
+// expandAlias replaces the alias args starts with by its definition, keeping
+// the arguments after it; nil when args[0] is no alias
+func expandAlias(args []string, aliases map[string]string) ([]string, error) {
+	definition, ok := aliases[args[0]]
+	if !ok {
+		return nil, nil
+	}
+	words, err := splitAliasArgs(definition)
+	if err != nil {
+		return nil, fmt.Errorf("alias %s: %v", args[0], err)
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("alias %s is empty", args[0])
+	}
+	if _, nested := aliases[words[0]]; nested {
+		return nil, fmt.Errorf("alias %s runs alias %s; aliases cannot run other aliases", args[0], words[0])
+	}
+	logFor(logConfig).Debug("expanding alias", "alias", args[0], "args", words)
+	return append(words, args[1:]...), nil
+}
+
+// splitAliasArgs splits an alias definition into arguments at whitespace;
+// single and double quotes keep whitespace in an argument
+func splitAliasArgs(definition string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range definition {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// validateAliases returns the problems of the aliases
+func validateAliases(aliases map[string]string) []string {
+	var problems []string
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t") {
+			problems = append(problems, fmt.Sprintf("alias name %q is not a single word", name))
+			continue
+		}
+		if _, err := expandAlias([]string{name}, aliases); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
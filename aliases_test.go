@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitAliasArgs(t *testing.T) {
+	tests := []struct {
+		definition string
+		want       []string
+		wantErr    bool
+	}{
+		{"--branch release/1.8 --remote origin", []string{"--branch", "release/1.8", "--remote", "origin"}, false},
+		{`tags  --match ">=1.4.0 <2.0.0"`, []string{"tags", "--match", ">=1.4.0 <2.0.0"}, false},
+		{`rollback --reason 'bad build' --tag ""`, []string{"rollback", "--reason", "bad build", "--tag", ""}, false},
+		{"", nil, false},
+		{`--ticket "CHG-1`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := splitAliasArgs(tt.definition)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitAliasArgs(%q) error = %v, want error %v", tt.definition, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitAliasArgs(%q) = %q, want %q", tt.definition, got, tt.want)
+		}
+	}
+}
+
+func TestExpandAlias(t *testing.T) {
+	aliases := map[string]string{
+		"hotfix": "--branch release/1.8 --remote origin",
+		"live":   "deployed --branch main",
+		"again":  "hotfix --no-push",
+		"empty":  " ",
+	}
+	tests := []struct {
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{[]string{"hotfix", "--tag", "v1.8.5"}, []string{"--branch", "release/1.8", "--remote", "origin", "--tag", "v1.8.5"}, false},
+		{[]string{"live"}, []string{"deployed", "--branch", "main"}, false},
+		{[]string{"unknown"}, nil, false},
+		{[]string{"again"}, nil, true},
+		{[]string{"empty"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := expandAlias(tt.args, aliases)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandAlias(%q) error = %v, want error %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandAlias(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAliases(t *testing.T) {
+	aliases := map[string]string{
+		"hotfix":    "--branch release/1.8",
+		"again":     "hotfix",
+		"-x":        "tags",
+		"two words": "tags",
+	}
+	want := []string{
+		`alias name "-x" is not a single word`,
+		"alias again runs alias hotfix; aliases cannot run other aliases",
+		`alias name "two words" is not a single word`,
+	}
+	if got := validateAliases(aliases); !reflect.DeepEqual(got, want) {
+		t.Errorf("validateAliases() = %q, want %q", got, want)
+	}
+}
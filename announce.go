@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// defaultAnnounceSubject is the subject template unless configured otherwise
+const defaultAnnounceSubject = "{{.Project}} {{.Tag}} released"
+
+// defaultAnnounceBody is the message template unless announce.template is set
+const defaultAnnounceBody = `{{.Project}} {{.Tag}} has been released.
+{{if .URL}}
+{{.URL}}
+{{end}}
+{{if .LastTag}}Changes since {{.LastTag}}:{{else}}Changes:{{end}}
+{{.Changelog}}
+`
+
+// AnnounceConfig sends a release announcement by email after a successful publish
+type AnnounceConfig struct {
+	// SMTP is the mail server as host:port; port 465 uses TLS, other ports STARTTLS when offered
+	SMTP string `json:"smtp,omitempty"`
+	// Username logs in with the password from GIT_PUBLISH_SMTP_PASSWORD
+	Username string `json:"username,omitempty"`
+	From     string `json:"from,omitempty"`
+	// To are the recipients, e.g. a mailing list; no announcement is sent without them
+	To []string `json:"to,omitempty"`
+	// Subject is a template of the subject line (default: {{.Project}} {{.Tag}} released)
+	Subject string `json:"subject,omitempty"`
+	// Template is a text/template file with the message body, relative to the repository root
+	Template string `json:"template,omitempty"`
+}
+
+// enabled reports whether releases are announced
+func (c AnnounceConfig) enabled() bool {
+	return len(c.To) > 0
+}
+
+// announcement holds the values available to the announcement templates
+type announcement struct {
+	Project string
+	Tag     string
+	LastTag string
+	Branch  string
+	Commit  string
+	Remote  string
+	URL     string
+	Ticket  string
+	Date    string
+	// Commits are the subjects of the commits since LastTag without those
+	// opting out of releases, Changelog lists them one per line
+	Commits   []string
+	Changelog string
+}
+
+// validateAnnounceConfig returns the problems of an announce configuration
+func validateAnnounceConfig(config AnnounceConfig) []string {
+	if !config.enabled() {
+		return nil
+	}
+	var problems []string
+	if _, _, err := net.SplitHostPort(config.SMTP); err != nil {
+		problems = append(problems, fmt.Sprintf("announce.smtp %q is not host:port", config.SMTP))
+	}
+	if _, err := mail.ParseAddress(config.From); err != nil {
+		problems = append(problems, fmt.Sprintf("announce.from %q is not an email address", config.From))
+	}
+	for _, to := range config.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			problems = append(problems, fmt.Sprintf("announce.to %q is not an email address", to))
+		}
+	}
+	if _, err := template.New("subject").Parse(config.Subject); err != nil {
+		problems = append(problems, fmt.Sprintf("announce.subject: %v", err))
+	}
+	return problems
+}
+
+// newAnnouncement collects the template values of a published plan
+func newAnnouncement(plan Plan, url string) announcement {
+	a := announcement{
+		Project: projectName(plan.RemoteURL),
+		Tag:     plan.Tag,
+		LastTag: plan.LastTag,
+		Branch:  plan.Branch,
+		Commit:  plan.TargetCommit,
+		Remote:  plan.Remote,
+		URL:     url,
+		Ticket:  plan.Ticket,
+		Date:    time.Now().In(teamLocation()).Format("2006-01-02"),
+	}
+	if a.URL == "" {
+		a.URL = plan.TagURL
+	}
+
+	commits, err := releasedCommits(plan.LastTag, plan.Tag)
+	if err != nil {
+		fmt.Printf("Warning: could not list the commits of %s: %v\n", plan.Tag, err)
+	}
+	lines := make([]string, len(commits))
+	for i, commit := range commits {
+		// Commits come as "<short hash> <subject>"
+		_, subject, _ := strings.Cut(commit, " ")
+		a.Commits = append(a.Commits, subject)
+		lines[i] = "- " + subject
+	}
+	a.Changelog = strings.Join(lines, "\n")
+	return a
+}
+
+// projectName names the project after its repository on the remote, or the
+// directory of the work tree
+func projectName(remoteURL string) string {
+	if info, ok := parseRemoteURL(remoteURL); ok {
+		return info.Repo
+	}
+	if root, err := gitClient.TopLevel(); err == nil {
+		return filepath.Base(root)
+	}
+	return "git-publish"
+}
+
+// renderAnnouncement returns the subject and body of the announcement
+func renderAnnouncement(config AnnounceConfig, a announcement) (string, string, error) {
+	subjectTemplate := config.Subject
+	if subjectTemplate == "" {
+		subjectTemplate = defaultAnnounceSubject
+	}
+	bodyTemplate := defaultAnnounceBody
+	if config.Template != "" {
+		path := config.Template
+		if root, err := gitClient.TopLevel(); err == nil && !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("reading announcement template: %v", err)
+		}
+		bodyTemplate = string(data)
+	}
+
+	subject, err := executeTemplate("subject", subjectTemplate, a)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := executeTemplate("template", bodyTemplate, a)
+	if err != nil {
+		return "", "", err
+	}
+	return strings.Join(strings.Fields(subject), " "), body, nil
+}
+
+// executeTemplate renders one of the announcement templates
+func executeTemplate(name, text string, data interface{}) (string, error) {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("announcement %s: %v", name, err)
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("announcement %s: %v", name, err)
+	}
+	return b.String(), nil
+}
+
+// formatMail returns an RFC 5322 plain text message
+func formatMail(from string, to []string, subject, body string, date time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+		b.WriteString(strings.TrimRight(line, "\r") + "\r\n")
+	}
+	return []byte(b.String())
+}
+
+// sendMail delivers a message through the SMTP server, replaced in tests
+var sendMail = func(config AnnounceConfig, message []byte) error {
+	host, port, err := net.SplitHostPort(config.SMTP)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, os.Getenv("GIT_PUBLISH_SMTP_PASSWORD"), host)
+	}
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return err
+	}
+	recipients := make([]string, len(config.To))
+	for i, to := range config.To {
+		address, err := mail.ParseAddress(to)
+		if err != nil {
+			return err
+		}
+		recipients[i] = address.Address
+	}
+
+	if port != "465" {
+		// SendMail upgrades to STARTTLS when the server offers it
+		return smtp.SendMail(config.SMTP, auth, from.Address, recipients, message)
+	}
+
+	conn, err := tls.Dial("tcp", config.SMTP, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range recipients {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// announceRelease renders the announcement of a published plan and sends it
+// to the plan's recipients, or only prints it with dryRun
+func announceRelease(config AnnounceConfig, plan Plan, url string, dryRun bool) error {
+	config.To = plan.Announce
+	subject, body, err := renderAnnouncement(config, newAnnouncement(plan, url))
+	if err != nil {
+		return err
+	}
+	message := formatMail(config.From, config.To, subject, body, time.Now())
+	if dryRun {
+		fmt.Printf("Announcement to %s:\n\n%s\n", strings.Join(config.To, ", "), strings.ReplaceAll(string(message), "\r\n", "\n"))
+		return nil
+	}
+	if err := sendMail(config, message); err != nil {
+		return fmt.Errorf("sending the announcement via %s: %v", config.SMTP, err)
+	}
+	fmt.Printf("Announced %s to %s\n", plan.Tag, strings.Join(config.To, ", "))
+	return nil
+}
+
+// runAnnounceCommand implements the announce command: it (re)sends or, with
+// --dry-run, previews the announcement of an existing tag
+func runAnnounceCommand(args []string) {
+	fs := flag.NewFlagSet("announce", flag.ExitOnError)
+	tag := fs.String("tag", "", "the released tag")
+	remote := fs.String("remote", "origin", "remote whose hosting service links to the tag")
+	dryRun := fs.Bool("dry-run", false, "print the announcement instead of sending it")
+	fs.Parse(args)
+
+	if *tag == "" {
+		fmt.Println("Usage: git-publish announce --tag <tag> [--remote <name>] [--dry-run]")
+		os.Exit(1)
+	}
+	config := readConfig()
+	if !config.Announce.enabled() {
+		fmt.Println("Error: no announcement recipients configured (announce.to)")
+		os.Exit(1)
+	}
+
+	plan, err := announcedPlan(config, *tag, *remote)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := announceRelease(config.Announce, plan, "", *dryRun); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// announcedPlan reconstructs the plan an existing tag was published with:
+// its branch and the previous tag of the same format
+func announcedPlan(config Config, tag, remote string) (Plan, error) {
+	commit, err := gitClient.RevParse("refs/tags/" + tag + "^{commit}")
+	if err != nil {
+		return Plan{}, fmt.Errorf("tag %s not found", tag)
+	}
+
+	plan := newPlan()
+	plan.Tag = tag
+	plan.TargetCommit = commit
+	for _, bt := range config.BranchTags {
+		prefix := extractPrefix(bt.Tag)
+		if !strings.HasPrefix(tag, prefix) || !validateTagFormat(tag, prefix) {
+			continue
+		}
+		plan.Branch, plan.TagFormat = bt.Branch, bt.Tag
+		tags, _ := gitClient.ListTags(tagGlob(bt.Tag))
+		for _, candidate := range tags {
+			if validateTagFormat(candidate, prefix) && isTagVersionGreater(tag, candidate) {
+				plan.LastTag = candidate
+				break
+			}
+		}
+		break
+	}
+
+	if url, ok := getAllRemoteURLs()[remote]; ok {
+		plan.Remote, plan.RemoteURL = remote, url
+		plan.TagURL = tagWebURL(url, config.Provider, tag)
+	}
+	plan.Announce = config.Announce.To
+	return plan, nil
+}
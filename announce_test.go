@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubSendMail records the messages sent for the rest of the test
+func stubSendMail(t *testing.T, err error) *[]string {
+	var sent []string
+	original := sendMail
+	sendMail = func(config AnnounceConfig, message []byte) error {
+		sent = append(sent, string(message))
+		return err
+	}
+	t.Cleanup(func() { sendMail = original })
+	return &sent
+}
+
+func TestValidateAnnounceConfig(t *testing.T) {
+	valid := AnnounceConfig{SMTP: "smtp.example.com:587", From: "Releases <releases@example.com>", To: []string{"users@lists.example.com"}}
+	if problems := validateAnnounceConfig(valid); len(problems) != 0 {
+		t.Errorf("validateAnnounceConfig(valid) = %v", problems)
+	}
+	if problems := validateAnnounceConfig(AnnounceConfig{}); len(problems) != 0 {
+		t.Errorf("validateAnnounceConfig(disabled) = %v", problems)
+	}
+
+	invalid := AnnounceConfig{SMTP: "smtp.example.com", From: "releases", To: []string{"users@lists.example.com", "not an address"}, Subject: "{{.Tag"}
+	if problems := validateAnnounceConfig(invalid); len(problems) != 4 {
+		t.Errorf("validateAnnounceConfig(invalid) = %v, want 4 problems", problems)
+	}
+}
+
+func TestRenderAnnouncement(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c")
+	g.subjects = map[string]string{"b": "Add export", "c": "Fix import"}
+	g.tags = map[string]string{"v1.0.0": "a", "v1.1.0": "c"}
+	useFakeGit(t, g)
+
+	plan := Plan{Tag: "v1.1.0", LastTag: "v1.0.0", Branch: "main", RemoteURL: "git@github.com:acme/tool.git", TagURL: "https://github.com/acme/tool/releases/tag/v1.1.0"}
+	subject, body, err := renderAnnouncement(AnnounceConfig{}, newAnnouncement(plan, ""))
+	if err != nil {
+		t.Fatalf("renderAnnouncement() error = %v", err)
+	}
+	if subject != "tool v1.1.0 released" {
+		t.Errorf("subject = %q", subject)
+	}
+	want := "tool v1.1.0 has been released.\n\nhttps://github.com/acme/tool/releases/tag/v1.1.0\n\nChanges since v1.0.0:\n- Fix import\n- Add export\n"
+	if body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+
+	// A template file from the repository, and the release URL over the tag URL
+	g.root = t.TempDir()
+	os.WriteFile(filepath.Join(g.root, "announce.tmpl"), []byte("{{.Tag}} on {{.Branch}}: {{.URL}} ({{len .Commits}} changes)"), 0644)
+	config := AnnounceConfig{Subject: "[release]\n{{.Tag}}", Template: "announce.tmpl"}
+	subject, body, err = renderAnnouncement(config, newAnnouncement(plan, "https://github.com/acme/tool/releases/1"))
+	if err != nil || subject != "[release] v1.1.0" || body != "v1.1.0 on main: https://github.com/acme/tool/releases/1 (2 changes)" {
+		t.Errorf("renderAnnouncement() = %q, %q, %v", subject, body, err)
+	}
+
+	config.Template = "missing.tmpl"
+	if _, _, err := renderAnnouncement(config, newAnnouncement(plan, "")); err == nil {
+		t.Errorf("renderAnnouncement() with a missing template succeeded")
+	}
+	if _, _, err := renderAnnouncement(AnnounceConfig{Subject: "{{.Version}}"}, newAnnouncement(plan, "")); err == nil {
+		t.Errorf("renderAnnouncement() with an unknown field succeeded")
+	}
+}
+
+func TestFormatMail(t *testing.T) {
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	message := string(formatMail("releases@example.com", []string{"a@example.com", "b@example.com"}, "tool v1.1.0 – released", "Hello\nWorld\n", date))
+
+	for _, want := range []string{
+		"From: releases@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: =?utf-8?q?tool_v1.1.0_=E2=80=93_released?=\r\n",
+		"Date: Fri, 01 Mar 2024 12:00:00 +0000\r\n",
+		"\r\n\r\nHello\r\nWorld\r\n",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("formatMail() = %q, missing %q", message, want)
+		}
+	}
+}
+
+func TestPublishPlanAnnounces(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.remotes["origin"] = "/srv/git/tool.git"
+	useFakeGit(t, g)
+	sent := stubSendMail(t, nil)
+
+	config := Config{Announce: AnnounceConfig{SMTP: "smtp.example.com:25", From: "releases@example.com", To: []string{"users@example.com"}}}
+	plan, err := makePlan(config, g.remotes, "main", "v0.0.0", "", "v1.0.0", "origin")
+	if err != nil || strings.Join(plan.Announce, ",") != "users@example.com" {
+		t.Fatalf("makePlan() = %v, %v, want an announcement to users@example.com", plan.Announce, err)
+	}
+	if err := publishPlan(plan, config, &eventBus{handlers: map[string][]eventHandler{}}); err != nil {
+		t.Fatalf("publishPlan() error = %v", err)
+	}
+	if len(*sent) != 1 || !strings.Contains((*sent)[0], "To: users@example.com") {
+		t.Errorf("sent %q, want one announcement", *sent)
+	}
+
+	// A failed announcement does not fail the release
+	stubSendMail(t, fmt.Errorf("connection refused"))
+	plan.Tag = "v1.0.1"
+	if err := publishPlan(plan, config, &eventBus{handlers: map[string][]eventHandler{}}); err != nil {
+		t.Errorf("publishPlan() with a failing mail server = %v, want success", err)
+	}
+
+	// Tags that are not pushed are not announced
+	if plan, _ := makePlan(config, g.remotes, "main", "v0.0.0", "", "v1.0.2", ""); len(plan.Announce) != 0 {
+		t.Errorf("makePlan() without push announces to %v", plan.Announce)
+	}
+}
+
+func TestAnnouncedPlan(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c")
+	g.tags = map[string]string{"v1.0.0": "a", "v1.1.0": "b", "v1.2.0": "c", "g1.5.0": "b"}
+	g.remotes["origin"] = "https://gitlab.com/acme/tool.git"
+	useFakeGit(t, g)
+
+	config := defaultConfig
+	config.Announce.To = []string{"users@example.com"}
+	plan, err := announcedPlan(config, "v1.1.0", "origin")
+	if err != nil {
+		t.Fatalf("announcedPlan() error = %v", err)
+	}
+	if plan.TargetCommit != "b" || plan.LastTag != "v1.0.0" || plan.Branch != "master" || plan.TagURL != "https://gitlab.com/acme/tool/-/tags/v1.1.0" {
+		t.Errorf("announcedPlan() = %+v", plan)
+	}
+
+	if _, err := announcedPlan(config, "v9.9.9", "origin"); err == nil {
+		t.Errorf("announcedPlan() of a missing tag succeeded")
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Variables to allow mocking in tests
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+var apiSleep = time.Sleep
+
+// apiMaxRetries is how many times a rate-limited or failed request is retried
+const apiMaxRetries = 3
+
+// apiMaxWait caps how long we wait for a rate limit to reset
+const apiMaxWait = 60 * time.Second
+
+// apiClient is the HTTP client shared by all hosting provider integrations.
+// It handles authentication headers, pagination and rate-limit backoff.
+type apiClient struct {
+	baseURL string
+	header  http.Header
+	// cli is the gh or glab api command requests are delegated to, see providerCLI
+	cli []string
+}
+
+// errNotSupported is returned by providers for operations their service does not offer
+var errNotSupported = errors.New("not supported by this hosting service")
+
+// apiError is returned for non-2xx responses
+type apiError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.URL, e.Status, e.Body)
+}
+
+// isNotFound reports whether err is an API 404 response
+func isNotFound(err error) bool {
+	apiErr, ok := err.(*apiError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// newAPIClient returns a client for the API at baseURL sending the given headers
+func newAPIClient(baseURL string, header http.Header) *apiClient {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &apiClient{baseURL: strings.TrimSuffix(baseURL, "/"), header: header}
+}
+
+// url resolves path against the base URL; absolute URLs are returned unchanged
+func (c *apiClient) url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return c.baseURL + path
+}
+
+// do sends a request with a JSON body (if not nil) and decodes the JSON response into out (if not nil)
+func (c *apiClient) do(method, path string, body interface{}, out interface{}) error {
+	resp, data, err := c.send(method, c.url(path), body)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, data, out)
+}
+
+// doHeader is do that also returns the response headers
+func (c *apiClient) doHeader(method, path string, body interface{}, out interface{}) (http.Header, error) {
+	resp, data, err := c.send(method, c.url(path), body)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Header, decodeResponse(resp, data, out)
+}
+
+// decodeResponse decodes a JSON response body into out when both are present
+func decodeResponse(resp *http.Response, data []byte, out interface{}) error {
+	if out == nil || len(data) == 0 || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// send performs the request, retrying on rate limits and server errors
+func (c *apiClient) send(method, endpoint string, body interface{}) (*http.Response, []byte, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if payload != nil {
+			reader = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequest(method, endpoint, reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		for key, values := range c.header {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		start := time.Now()
+		var resp *http.Response
+		if c.cli != nil {
+			resp, err = c.doCLI(req, payload)
+		} else {
+			resp, err = httpClient.Do(req)
+		}
+		if err != nil {
+			logFor(logAPI).Warn("request failed", "method", method, "url", endpoint, "attempt", attempt+1, "error", err)
+			if attempt < apiMaxRetries {
+				apiSleep(backoff(attempt))
+				continue
+			}
+			return nil, nil, err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		logFor(logAPI).Debug("request", "method", method, "url", endpoint, "status", resp.StatusCode, "duration", time.Since(start))
+
+		if wait, retry := retryAfter(resp, attempt); retry && attempt < apiMaxRetries {
+			logFor(logAPI).Warn("rate limited or server error, retrying", "url", endpoint, "status", resp.StatusCode, "wait", wait)
+			apiSleep(wait)
+			continue
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return resp, data, &apiError{
+				Method:     method,
+				URL:        endpoint,
+				StatusCode: resp.StatusCode,
+				Status:     resp.Status,
+				Body:       strings.TrimSpace(string(data)),
+			}
+		}
+		return resp, data, nil
+	}
+}
+
+// backoff returns the exponential backoff delay for the given attempt
+func backoff(attempt int) time.Duration {
+	return time.Duration(1<<attempt) * time.Second
+}
+
+// retryAfter decides whether a response should be retried and how long to wait first
+func retryAfter(resp *http.Response, attempt int) (time.Duration, bool) {
+	rateLimited := resp.StatusCode == http.StatusTooManyRequests ||
+		(resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0")
+
+	switch {
+	case rateLimited:
+		wait := backoff(attempt)
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		} else if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			wait = time.Until(time.Unix(reset, 0))
+		}
+		if wait < 0 {
+			wait = 0
+		}
+		if wait > apiMaxWait {
+			wait = apiMaxWait
+		}
+		return wait, true
+	case resp.StatusCode >= 500:
+		return backoff(attempt), true
+	}
+	return 0, false
+}
+
+// linkNextPattern extracts the rel="next" URL of a Link header
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextPageURL returns the URL of the next page from the Link header, if any
+func nextPageURL(resp *http.Response) string {
+	match := linkNextPattern.FindStringSubmatch(resp.Header.Get("Link"))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// getAllPages fetches every page of a JSON array endpoint following Link rel="next"
+func getAllPages[T any](c *apiClient, path string) ([]T, error) {
+	var all []T
+	endpoint := c.url(path)
+	for endpoint != "" {
+		resp, data, err := c.send("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page []T
+		if err := decodeResponse(resp, data, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		endpoint = nextPageURL(resp)
+	}
+	return all, nil
+}
+
+// Token sources reported by lookupToken
+const (
+	tokenFromConfig = "global config"
+	tokenFromLogin  = "auth login"
+	tokenFromHelper = "git credential helper"
+)
+
+// resolveToken finds an API token for the repository info from, in order: the
+// environment variables, the user config, a device flow login and the git
+// credential helper
+func resolveToken(info remoteInfo, envNames ...string) string {
+	token, _ := lookupToken(info, envNames...)
+	return token
+}
+
+// lookupToken is resolveToken that also names the source of the token, an
+// environment variable as $NAME
+func lookupToken(info remoteInfo, envNames ...string) (string, string) {
+	for _, name := range envNames {
+		if token := os.Getenv(name); token != "" {
+			return token, "$" + name
+		}
+	}
+	if userConfig, ok := readUserConfig(); ok {
+		if token := userConfig.Tokens[info.Host]; token != "" {
+			return token, tokenFromConfig
+		}
+	}
+	if token := storedOAuthToken(info.Host); token != "" {
+		return token, tokenFromLogin
+	}
+	if token := credentialHelperToken(info); token != "" {
+		return token, tokenFromHelper
+	}
+	return "", ""
+}
+
+// firstEnv returns the value of the first non-empty environment variable
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// credentialHelperToken asks the configured git credential helper (manager,
+// osxkeychain, store, ...) for the password of the repository's HTTPS URL.
+// Helpers only see the path with credential.useHttpPath, for per-repository
+// credentials.
+func credentialHelperToken(info remoteInfo) string {
+	password, _ := gitClient.CredentialPassword(info.Host, info.Owner+"/"+info.Repo+".git")
+	return password
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGetAllPages tests that pagination follows the Link header across pages
+func TestGetAllPages(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/items?page=2>; rel="next", <%s/items?page=2>; rel="last"`, server.URL, server.URL))
+			fmt.Fprint(w, `[{"name":"a"},{"name":"b"}]`)
+		case "2":
+			fmt.Fprint(w, `[{"name":"c"}]`)
+		}
+	}))
+	defer server.Close()
+
+	type item struct {
+		Name string `json:"name"`
+	}
+	items, err := getAllPages[item](newAPIClient(server.URL, nil), "/items")
+	if err != nil {
+		t.Fatalf("getAllPages() error: %v", err)
+	}
+	if len(items) != 3 || items[2].Name != "c" {
+		t.Errorf("getAllPages() = %v, expected items a, b and c", items)
+	}
+}
+
+// TestAPIClientRetries tests backoff on rate limits and giving up on client errors
+func TestAPIClientRetries(t *testing.T) {
+	originalSleep := apiSleep
+	defer func() { apiSleep = originalSleep }()
+
+	testCases := []struct {
+		name          string
+		responses     []int
+		header        http.Header
+		expectedCalls int
+		expectError   bool
+		expectedWait  time.Duration
+	}{
+		{"success", []int{200}, nil, 1, false, 0},
+		{"retry after 429", []int{429, 200}, http.Header{"Retry-After": {"7"}}, 2, false, 7 * time.Second},
+		{"exhausted rate limit", []int{403, 200}, http.Header{"X-Ratelimit-Remaining": {"0"}}, 2, false, time.Second},
+		{"server error", []int{502, 502, 200}, nil, 3, false, 3 * time.Second},
+		{"forbidden", []int{403}, nil, 1, true, 0},
+		{"gives up", []int{500, 500, 500, 500}, nil, 4, true, 7 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var waited time.Duration
+			apiSleep = func(d time.Duration) { waited += d }
+
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, values := range tc.header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(tc.responses[calls])
+				calls++
+				fmt.Fprint(w, `{}`)
+			}))
+			defer server.Close()
+
+			err := newAPIClient(server.URL, nil).do("GET", "/", nil, nil)
+			if (err != nil) != tc.expectError {
+				t.Errorf("do() error = %v, expected error: %v", err, tc.expectError)
+			}
+			if calls != tc.expectedCalls {
+				t.Errorf("do() made %d calls, expected %d", calls, tc.expectedCalls)
+			}
+			if waited != tc.expectedWait {
+				t.Errorf("do() waited %s, expected %s", waited, tc.expectedWait)
+			}
+		})
+	}
+}
+
+// TestResolveToken tests the token source order
+func TestResolveToken(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("TEST_TOKEN", "")
+
+	g := newFakeGitClient("c1")
+	g.credentials = map[string]string{"example.com/acme/tool.git": "from-helper"}
+	useFakeGit(t, g)
+
+	if token := resolveToken(remoteInfo{Host: "example.com", Owner: "acme", Repo: "tool"}, "TEST_TOKEN"); token != "from-helper" {
+		t.Errorf("resolveToken() = %q, expected the credential helper token", token)
+	}
+
+	if _, err := writeUserConfig(UserConfig{Tokens: map[string]string{"example.com": "from-config"}}); err != nil {
+		t.Fatalf("writeUserConfig() error: %v", err)
+	}
+	if token := resolveToken(remoteInfo{Host: "example.com", Owner: "acme", Repo: "tool"}, "TEST_TOKEN"); token != "from-config" {
+		t.Errorf("resolveToken() = %q, expected the user config token", token)
+	}
+
+	t.Setenv("TEST_TOKEN", "from-env")
+	if token := resolveToken(remoteInfo{Host: "example.com", Owner: "acme", Repo: "tool"}, "TEST_TOKEN"); token != "from-env" {
+		t.Errorf("resolveToken() = %q, expected the environment token", token)
+	}
+}
+
+// TestCredentialHelperToken tests the token lookup through a real credential helper
+func TestCredentialHelperToken(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(dir, "no-global-config"))
+	input := filepath.Join(dir, "helper-input")
+	// The helper records what git asks for and answers per repository
+	helper := fmt.Sprintf(`!f() { cat >> %q; echo username=me; echo password=repo-token; }; f`, input)
+	gitInTestRepo(t, dir, "config", "credential.helper", helper)
+	info := remoteInfo{Host: "git.example.com", Owner: "acme", Repo: "tool"}
+
+	if token := credentialHelperToken(info); token != "repo-token" {
+		t.Errorf("credentialHelperToken() = %q, want the helper's password", token)
+	}
+	asked, _ := os.ReadFile(input)
+	if !strings.Contains(string(asked), "host=git.example.com") || strings.Contains(string(asked), "path=") {
+		t.Errorf("helper asked for %q, want the host without the path", asked)
+	}
+
+	// Per-repository credentials need the path
+	os.Remove(input)
+	gitInTestRepo(t, dir, "config", "credential.useHttpPath", "true")
+	credentialHelperToken(info)
+	if asked, _ := os.ReadFile(input); !strings.Contains(string(asked), "path=acme/tool.git") {
+		t.Errorf("helper asked for %q with credential.useHttpPath, want the repository path", asked)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// runApplyCommand implements the apply command: it executes a plan written by
+// the plan command after checking that the repository still matches it
+func runApplyCommand(args []string) {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	sshKey := fs.String("ssh-key", "", "SSH private key used to push the tag (overrides push.sshKey)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: git-publish apply [--ssh-key <path>] <planfile>")
+		os.Exit(1)
+	}
+
+	plan, err := readPlanFile(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	config := readConfig()
+	if *sshKey != "" {
+		config.Push.SSHKey = *sshKey
+	}
+
+	// Make sure we compare against the current state of the remotes
+	if len(getAllRemoteURLs()) > 0 {
+		fetchRemote(config.Tags)
+	}
+
+	// A retried apply of a plan that was carried out already succeeds without
+	// changes; one that stopped after creating the tag resumes
+	state, err := publishedState(plan.Tag, plan.TargetCommit, plan.Remote)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	switch state {
+	case tagPublished:
+		fmt.Printf("Tag %s is already published at %s; nothing to do.\n", plan.Tag, shortHash(plan.TargetCommit))
+		return
+	case tagUnpushed:
+		fmt.Printf("Tag %s already exists at %s; resuming with the remaining steps.\n", plan.Tag, shortHash(plan.TargetCommit))
+		plan.TagExists = true
+	}
+
+	if problems := verifyPlan(plan); len(problems) > 0 {
+		red := color.New(color.FgRed).SprintFunc()
+		fmt.Printf("%s The repository no longer matches the plan, nothing was changed:\n", red("Error:"))
+		for _, problem := range problems {
+			fmt.Printf("  - %s\n", problem)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Print(formatPlan(plan))
+	executePlan(plan, config)
+}
+
+// readPlanFile reads and decodes a plan file
+func readPlanFile(path string) (Plan, error) {
+	var plan Plan
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return plan, fmt.Errorf("reading plan file: %v", err)
+	}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return plan, fmt.Errorf("parsing plan file %s: %v", path, err)
+	}
+	if plan.Version != planVersion {
+		return plan, fmt.Errorf("unsupported plan version %d (expected %d)", plan.Version, planVersion)
+	}
+	if plan.Branch == "" || plan.Tag == "" || plan.TargetCommit == "" {
+		return plan, fmt.Errorf("plan file %s is incomplete", path)
+	}
+	// The push arguments are run as they are, so they have to be a push to the remote
+	if plan.Remote != "" && !isPushTo(plan.PushArgs, plan.Remote) {
+		return plan, fmt.Errorf("plan file %s has no valid push to %s (pushArgs %q); create the plan again", path, plan.Remote, plan.PushArgs)
+	}
+	for _, mirror := range plan.Mirrors {
+		if !isPushTo(mirror.PushArgs, mirror.Remote) {
+			return plan, fmt.Errorf("plan file %s has no valid push to mirror %s (pushArgs %q); create the plan again", path, mirror.Remote, mirror.PushArgs)
+		}
+	}
+
+	return plan, nil
+}
+
+// isPushTo reports whether args are git push arguments pushing at least one
+// refspec to remote, as buildPushArgs builds them
+func isPushTo(args []string, remote string) bool {
+	if remote == "" || len(args) < 3 || args[0] != "push" {
+		return false
+	}
+	for _, arg := range args[1 : len(args)-1] {
+		if arg == remote {
+			return true
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return false
+		}
+	}
+	return false
+}
+
+// verifyPlan checks that the repository still matches the plan and returns every mismatch
+func verifyPlan(plan Plan) []string {
+	var problems []string
+
+	commit, err := lookupCommit(plan.Branch)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("branch %s cannot be resolved: %v", plan.Branch, err))
+	} else if commit != plan.TargetCommit {
+		problems = append(problems, fmt.Sprintf("branch %s moved from %s to %s", plan.Branch, plan.TargetCommit, commit))
+	}
+
+	except := ""
+	if plan.TagExists {
+		except = plan.Tag
+	}
+	// A deployed base is not read again: a deployment since planning changes nothing here
+	if lastTag := getLastTagExcept(plan.Branch, plan.TagFormat, plan.OnlyMarkedTags, except); lastTag != plan.LastTag && !plan.DeployedBase {
+		problems = append(problems, fmt.Sprintf("last tag on %s changed from %q to %q", plan.Branch, plan.LastTag, lastTag))
+	}
+
+	if !plan.TagExists && tagExists(plan.Tag) {
+		problems = append(problems, fmt.Sprintf("tag %s already exists", plan.Tag))
+	}
+
+	if plan.Remote != "" {
+		if url, ok := getAllRemoteURLs()[plan.Remote]; !ok {
+			problems = append(problems, fmt.Sprintf("remote %s no longer exists", plan.Remote))
+		} else if url != plan.RemoteURL {
+			problems = append(problems, fmt.Sprintf("remote %s now points to %s instead of %s", plan.Remote, url, plan.RemoteURL))
+		} else if !plan.TagExists {
+			if commit := remoteTagCommit(plan.Remote, plan.Tag); commit != "" {
+				problems = append(problems, fmt.Sprintf("tag %s already exists on remote %s at %s", plan.Tag, plan.Remote, shortHash(commit)))
+			}
+		}
+	}
+
+	return problems
+}
+
+// tagExists checks if the tag exists locally
+func tagExists(tag string) bool {
+	_, err := gitClient.RevParse("refs/tags/" + tag)
+	return err == nil
+}
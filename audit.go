@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// auditLogPath returns the audit log inside the git directory
+func auditLogPath() (string, error) {
+	return gitClient.GitPath("git-publish/audit.log")
+}
+
+// subscribeAuditLog records every lifecycle event except the start of a run as a JSON line
+func subscribeAuditLog(bus *eventBus) {
+	bus.subscribe(func(e event) error {
+		if err := appendAuditLog(e); err != nil {
+			fmt.Printf("Warning: could not write the audit log: %v\n", err)
+		}
+		return nil
+	}, eventTagCreated, eventTagPushed, eventReleaseCreated, eventPublished, eventPublishFailed, eventRolledBack)
+}
+
+// auditEntry is an event as recorded in the audit log: at its UTC time, with
+// the same time in the team's time zone for people reading the log
+type auditEntry struct {
+	event
+	TeamTime string `json:"teamTime,omitempty"`
+}
+
+// appendAuditLog appends an event to the audit log
+func appendAuditLog(e event) error {
+	path, err := auditLogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	e.Time = e.Time.UTC()
+	entry := auditEntry{event: e}
+	if teamTime := e.Time.In(teamLocation()); !isUTC(teamTime) {
+		entry.TeamTime = teamTime.Format(time.RFC3339)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
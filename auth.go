@@ -0,0 +1,316 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// OAuthToken is a token obtained through the OAuth device flow
+type OAuthToken struct {
+	ClientID              string    `json:"clientId"`
+	AccessToken           string    `json:"accessToken"`
+	RefreshToken          string    `json:"refreshToken,omitempty"`
+	ExpiresAt             time.Time `json:"expiresAt,omitempty"`
+	RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt,omitempty"`
+}
+
+// oauthTokenResponse is the token endpoint response of the device flow and of refreshes
+type oauthTokenResponse struct {
+	AccessToken           string `json:"access_token"`
+	RefreshToken          string `json:"refresh_token"`
+	ExpiresIn             int    `json:"expires_in"`
+	RefreshTokenExpiresIn int    `json:"refresh_token_expires_in"`
+	Error                 string `json:"error"`
+	ErrorDescription      string `json:"error_description"`
+	Interval              int    `json:"interval"`
+}
+
+// deviceCodeResponse is the first step of the OAuth device flow
+type deviceCodeResponse struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// Variable to allow mocking in tests
+var oauthBaseURL = func(host string) string { return "https://" + host }
+
+// tokenRefreshMargin refreshes tokens shortly before they expire
+const tokenRefreshMargin = time.Minute
+
+// runAuthCommand implements the auth command
+func runAuthCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: git-publish auth login github | logout github | status")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "login":
+		runAuthLogin(args[1:])
+	case "logout":
+		runAuthLogout(args[1:])
+	case "status":
+		runAuthStatus()
+	default:
+		fmt.Printf("Error: Unknown auth command '%s'\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// authProviderFlags parses the provider argument and the host flag shared by login and logout
+func authProviderFlags(name string, args []string) (*string, *string) {
+	fs := flag.NewFlagSet("auth "+name, flag.ExitOnError)
+	host := fs.String("host", "github.com", "GitHub host")
+	clientID := fs.String("client-id", "", "OAuth app client ID (default: githubClientId of the global config)")
+
+	if len(args) < 1 || args[0] != "github" {
+		fmt.Printf("Usage: git-publish auth %s github [--host github.com]\n", name)
+		os.Exit(1)
+	}
+	fs.Parse(args[1:])
+	return host, clientID
+}
+
+// runAuthLogin authorizes the tool through the GitHub device flow and stores the token
+func runAuthLogin(args []string) {
+	host, clientID := authProviderFlags("login", args)
+
+	userConfig, _ := readUserConfig()
+	if *clientID == "" {
+		*clientID = userConfig.GitHubClientID
+	}
+	if *clientID == "" {
+		fmt.Println("Error: No OAuth client ID configured. Register an OAuth app with device flow enabled and set githubClientId in the global config or pass --client-id.")
+		os.Exit(1)
+	}
+
+	token, err := githubDeviceLogin(*host, *clientID)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if userConfig.OAuth == nil {
+		userConfig.OAuth = make(map[string]OAuthToken)
+	}
+	userConfig.OAuth[*host] = token
+	path, err := writeUserConfig(userConfig)
+	if err != nil {
+		fmt.Printf("Error saving token: %v\n", err)
+		os.Exit(1)
+	}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	fmt.Printf("Logged in to %s. Token stored in %s\n", green(*host), path)
+}
+
+// runAuthLogout removes the stored device flow token
+func runAuthLogout(args []string) {
+	host, _ := authProviderFlags("logout", args)
+
+	userConfig, _ := readUserConfig()
+	if _, ok := userConfig.OAuth[*host]; !ok {
+		fmt.Printf("Not logged in to %s\n", *host)
+		return
+	}
+	delete(userConfig.OAuth, *host)
+	if _, err := writeUserConfig(userConfig); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Logged out of %s\n", *host)
+}
+
+// runAuthStatus lists the hosts with stored device flow tokens and, inside a
+// repository, where the API token of each remote comes from
+func runAuthStatus() {
+	userConfig, _ := readUserConfig()
+	if len(userConfig.OAuth) == 0 {
+		fmt.Println("Not logged in to any host.")
+	}
+	for host, token := range userConfig.OAuth {
+		switch {
+		case token.ExpiresAt.IsZero():
+			fmt.Printf("%s: logged in\n", host)
+		case token.RefreshToken != "":
+			fmt.Printf("%s: logged in (refreshed automatically, refresh token valid until %s)\n", host, token.RefreshTokenExpiresAt.Local().Format(time.RFC1123))
+		default:
+			fmt.Printf("%s: logged in until %s\n", host, token.ExpiresAt.Local().Format(time.RFC1123))
+		}
+	}
+
+	if !isGitRepository() {
+		return
+	}
+	if lines := remoteTokenSources(readConfig().Provider, getAllRemoteURLs()); len(lines) > 0 {
+		fmt.Println("API tokens of the remotes:")
+		for _, line := range lines {
+			fmt.Println("  " + line)
+		}
+	}
+}
+
+// remoteTokenSources describes where the API token of each remote with a
+// hosting service comes from, see resolveToken
+func remoteTokenSources(override ProviderConfig, remoteURLs map[string]string) []string {
+	var names []string
+	for name := range remoteURLs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		info, ok := parseRemoteURL(remoteURLs[name])
+		if !ok {
+			continue
+		}
+		providerType := strings.ToLower(override.Type)
+		if providerType == "" {
+			providerType = detectProviderType(info)
+		}
+		envNames, ok := providerTokenEnv[providerType]
+		if !ok {
+			continue
+		}
+		_, source := lookupToken(info, envNames...)
+		if source == "" {
+			source = "none (set " + strings.Join(envNames, " or ") + ", or store a password for https://" + info.Host + " in a git credential helper)"
+		}
+		lines = append(lines, fmt.Sprintf("%s (%s): %s", name, info.Host, source))
+	}
+	return lines
+}
+
+// oauthClient returns an API client for the OAuth endpoints of host
+func oauthClient(host string) *apiClient {
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	return newAPIClient(oauthBaseURL(host), header)
+}
+
+// githubDeviceLogin runs the OAuth device flow: the user enters a code in the browser while we poll for the token
+func githubDeviceLogin(host, clientID string) (OAuthToken, error) {
+	client := oauthClient(host)
+
+	var code deviceCodeResponse
+	if err := client.do("POST", "/login/device/code", map[string]string{"client_id": clientID, "scope": "repo"}, &code); err != nil {
+		return OAuthToken{}, fmt.Errorf("requesting device code: %v", err)
+	}
+
+	green := color.New(color.FgGreen, color.Bold).SprintFunc()
+	fmt.Printf("Open %s and enter the code %s\n", code.VerificationURI, green(code.UserCode))
+	openBrowser(code.VerificationURI)
+	fmt.Println("Waiting for authorization...")
+
+	interval := time.Duration(code.Interval) * time.Second
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		apiSleep(interval)
+
+		var resp oauthTokenResponse
+		body := map[string]string{
+			"client_id":   clientID,
+			"device_code": code.DeviceCode,
+			"grant_type":  "urn:ietf:params:oauth:grant-type:device_code",
+		}
+		if err := client.do("POST", "/login/oauth/access_token", body, &resp); err != nil {
+			return OAuthToken{}, err
+		}
+
+		switch resp.Error {
+		case "":
+			return newOAuthToken(clientID, resp), nil
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			interval = time.Duration(resp.Interval) * time.Second
+			continue
+		default:
+			return OAuthToken{}, fmt.Errorf("authorization failed: %s", resp.ErrorDescription)
+		}
+	}
+
+	return OAuthToken{}, fmt.Errorf("the device code expired, please try again")
+}
+
+// newOAuthToken converts a token endpoint response into a stored token
+func newOAuthToken(clientID string, resp oauthTokenResponse) OAuthToken {
+	token := OAuthToken{ClientID: clientID, AccessToken: resp.AccessToken, RefreshToken: resp.RefreshToken}
+	now := time.Now().UTC()
+	if resp.ExpiresIn > 0 {
+		token.ExpiresAt = now.Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	if resp.RefreshTokenExpiresIn > 0 {
+		token.RefreshTokenExpiresAt = now.Add(time.Duration(resp.RefreshTokenExpiresIn) * time.Second)
+	}
+	return token
+}
+
+// storedOAuthToken returns the device flow token of host, refreshing and
+// saving it when it is about to expire
+func storedOAuthToken(host string) string {
+	userConfig, ok := readUserConfig()
+	if !ok {
+		return ""
+	}
+	token, ok := userConfig.OAuth[host]
+	if !ok {
+		return ""
+	}
+	if token.ExpiresAt.IsZero() || time.Until(token.ExpiresAt) > tokenRefreshMargin {
+		return token.AccessToken
+	}
+
+	if token.RefreshToken == "" || (!token.RefreshTokenExpiresAt.IsZero() && time.Now().After(token.RefreshTokenExpiresAt)) {
+		fmt.Printf("Warning: The %s login expired, run git-publish auth login github --host %s\n", host, host)
+		return ""
+	}
+
+	var resp oauthTokenResponse
+	body := map[string]string{
+		"client_id":     token.ClientID,
+		"grant_type":    "refresh_token",
+		"refresh_token": token.RefreshToken,
+	}
+	if err := oauthClient(host).do("POST", "/login/oauth/access_token", body, &resp); err != nil {
+		fmt.Printf("Warning: Refreshing the %s token failed: %v\n", host, err)
+		return ""
+	}
+	if resp.Error != "" {
+		fmt.Printf("Warning: Refreshing the %s token failed: %s\n", host, resp.ErrorDescription)
+		return ""
+	}
+
+	userConfig.OAuth[host] = newOAuthToken(token.ClientID, resp)
+	if _, err := writeUserConfig(userConfig); err != nil {
+		fmt.Printf("Warning: Saving the refreshed %s token failed: %v\n", host, err)
+	}
+	return resp.AccessToken
+}
+
+// openBrowser tries to open url in the default browser; failures are ignored
+func openBrowser(url string) {
+	var cmd string
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = "open"
+	case "windows":
+		execCommand("rundll32", "url.dll,FileProtocolHandler", url).Start()
+		return
+	default:
+		cmd = "xdg-open"
+	}
+	execCommand(cmd, url).Start()
+}
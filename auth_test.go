@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newOAuthTestServer simulates the GitHub device flow endpoints; the first poll is still pending
+func newOAuthTestServer(t *testing.T) *httptest.Server {
+	polls := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+
+		switch {
+		case r.URL.Path == "/login/device/code":
+			json.NewEncoder(w).Encode(deviceCodeResponse{DeviceCode: "dev", UserCode: "ABCD-1234", VerificationURI: "https://example.com/device", ExpiresIn: 900, Interval: 5})
+		case body["grant_type"] == "refresh_token" && body["refresh_token"] == "refresh-1":
+			json.NewEncoder(w).Encode(oauthTokenResponse{AccessToken: "access-2", RefreshToken: "refresh-2", ExpiresIn: 28800})
+		case body["device_code"] == "dev":
+			polls++
+			if polls == 1 {
+				json.NewEncoder(w).Encode(oauthTokenResponse{Error: "authorization_pending"})
+				return
+			}
+			json.NewEncoder(w).Encode(oauthTokenResponse{AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresIn: 28800, RefreshTokenExpiresIn: 15897600})
+		default:
+			t.Errorf("unexpected request %s %v", r.URL.Path, body)
+			http.Error(w, "unexpected", http.StatusBadRequest)
+		}
+	}))
+}
+
+// TestGitHubDeviceLogin tests polling for the token until the user authorized the device
+func TestGitHubDeviceLogin(t *testing.T) {
+	server := newOAuthTestServer(t)
+	defer server.Close()
+
+	originalBaseURL, originalSleep, originalExecCommand := oauthBaseURL, apiSleep, execCommand
+	defer func() { oauthBaseURL, apiSleep, execCommand = originalBaseURL, originalSleep, originalExecCommand }()
+	oauthBaseURL = func(host string) string { return server.URL }
+	apiSleep = func(time.Duration) {}
+	execCommand = func(command string, args ...string) *exec.Cmd { return exec.Command("true") }
+
+	token, err := githubDeviceLogin("github.com", "client")
+	if err != nil {
+		t.Fatalf("githubDeviceLogin() error: %v", err)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-1" || token.ExpiresAt.IsZero() {
+		t.Errorf("githubDeviceLogin() = %+v, expected access-1 with refresh token and expiry", token)
+	}
+}
+
+// TestStoredOAuthTokenRefresh tests that expiring tokens are refreshed and saved
+func TestStoredOAuthTokenRefresh(t *testing.T) {
+	server := newOAuthTestServer(t)
+	defer server.Close()
+
+	originalBaseURL := oauthBaseURL
+	defer func() { oauthBaseURL = originalBaseURL }()
+	oauthBaseURL = func(host string) string { return server.URL }
+
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+
+	expiring := OAuthToken{ClientID: "client", AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresAt: time.Now().Add(30 * time.Second)}
+	if _, err := writeUserConfig(UserConfig{OAuth: map[string]OAuthToken{"github.com": expiring}}); err != nil {
+		t.Fatalf("writeUserConfig() error: %v", err)
+	}
+
+	if token := storedOAuthToken("github.com"); token != "access-2" {
+		t.Errorf("storedOAuthToken() = %q, expected the refreshed token", token)
+	}
+	userConfig, _ := readUserConfig()
+	if saved := userConfig.OAuth["github.com"]; saved.AccessToken != "access-2" || saved.RefreshToken != "refresh-2" {
+		t.Errorf("saved token = %+v, expected the refreshed token", saved)
+	}
+	if token := storedOAuthToken("github.com"); token != "access-2" {
+		t.Errorf("storedOAuthToken() = %q, expected the saved token without refreshing", token)
+	}
+}
+
+func TestRemoteTokenSources(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "from-env")
+	t.Setenv("GITLAB_TOKEN", "")
+
+	useFakeGit(t, newFakeGitClient("c1"))
+
+	remoteURLs := map[string]string{
+		"origin": "git@github.com:acme/tool.git",
+		"lab":    "https://gitlab.example.com/acme/tool.git",
+		"backup": "/srv/git/tool.git",
+	}
+	want := []string{
+		"lab (gitlab.example.com): none (set GITLAB_TOKEN, or store a password for https://gitlab.example.com in a git credential helper)",
+		"origin (github.com): $GH_TOKEN",
+	}
+	if got := remoteTokenSources(ProviderConfig{}, remoteURLs); !reflect.DeepEqual(got, want) {
+		t.Errorf("remoteTokenSources() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Version bump levels
+const (
+	bumpPatch = "patch"
+	bumpMinor = "minor"
+	bumpMajor = "major"
+)
+
+// BumpConfig represents the heuristics that suggest the next version from the changes since the last tag
+type BumpConfig struct {
+	// Suggest enables the heuristics; without it the patch version is always suggested
+	Suggest bool `json:"suggest,omitempty"`
+	// APIPaths are paths whose changes suggest a minor bump
+	APIPaths []string `json:"apiPaths,omitempty"`
+	// PatchPaths are paths that never need more than a patch bump, such as docs and tests
+	PatchPaths []string `json:"patchPaths,omitempty"`
+}
+
+// Default heuristics used when the bump config does not list paths
+var (
+	defaultAPIPaths   = []string{"api/", "proto/", "*.proto", "openapi.yaml", "openapi.json"}
+	defaultPatchPaths = []string{"docs/", "doc/", "test/", "tests/", "testdata/", "*.md", "*_test.go", "LICENSE"}
+)
+
+// bumpSuggestion is the suggested bump level and the reasons that led to it
+type bumpSuggestion struct {
+	Level   string
+	Reasons []string
+}
+
+// fileChange is a file changed between two revisions as reported by git diff --name-status
+type fileChange struct {
+	Status string
+	Path   string
+}
+
+// matchPath reports whether file matches pattern: a directory prefix when the
+// pattern ends with a slash, otherwise a glob matched against the path or its base name
+func matchPath(pattern, file string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(file, pattern) || strings.Contains(file, "/"+pattern)
+	}
+	if ok, _ := filepath.Match(pattern, file); ok {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, path.Base(file))
+	return ok
+}
+
+// matchAnyPath reports whether file matches any of the patterns
+func matchAnyPath(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if matchPath(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// changedFiles lists the files changed between two revisions, limited to paths when given
+func changedFiles(from, to string, paths []string) ([]fileChange, error) {
+	return gitClient.ChangedFiles(from, to, paths)
+}
+
+// goPackageDirs returns the directories of the changed non-test Go files
+func goPackageDirs(changes []fileChange) []string {
+	var dirs []string
+	for _, change := range changes {
+		if strings.HasSuffix(change.Path, ".go") && !strings.HasSuffix(change.Path, "_test.go") {
+			dirs = append(dirs, path.Dir(change.Path))
+		}
+	}
+	return uniqueStrings(dirs)
+}
+
+// suggestBump inspects the changes on branch since lastTag and suggests a bump level
+func suggestBump(lastTag, branch string, paths []string, config BumpConfig) (bumpSuggestion, error) {
+	commit, err := lookupCommit(branch)
+	if err != nil {
+		return bumpSuggestion{}, err
+	}
+	changes, err := changedFiles(lastTag, commit, paths)
+	if err != nil {
+		return bumpSuggestion{}, err
+	}
+	api, err := diffGoAPI(lastTag, commit, goPackageDirs(changes))
+	if err != nil {
+		return bumpSuggestion{}, err
+	}
+	return classifyBump(changes, api, config), nil
+}
+
+// classifyBump applies the bump heuristics to the changed files and Go API differences
+func classifyBump(changes []fileChange, api goAPIDiff, config BumpConfig) bumpSuggestion {
+	apiPaths := config.APIPaths
+	if len(apiPaths) == 0 {
+		apiPaths = defaultAPIPaths
+	}
+	patchPaths := config.PatchPaths
+	if len(patchPaths) == 0 {
+		patchPaths = defaultPatchPaths
+	}
+
+	if len(changes) == 0 {
+		return bumpSuggestion{Level: bumpPatch, Reasons: []string{"no files changed"}}
+	}
+	if len(api.Breaking) > 0 {
+		return bumpSuggestion{Level: bumpMajor, Reasons: []string{"the exported Go API changed incompatibly: " + summarizeList(api.Breaking)}}
+	}
+
+	var apiChanges []string
+	for _, change := range changes {
+		if matchAnyPath(apiPaths, change.Path) {
+			apiChanges = append(apiChanges, change.Path)
+		}
+	}
+	var reasons []string
+	if len(apiChanges) > 0 {
+		reasons = append(reasons, "API files changed: "+summarizeList(apiChanges))
+	}
+	if len(api.Added) > 0 {
+		reasons = append(reasons, "exported Go symbols were added: "+summarizeList(api.Added))
+	}
+	if len(reasons) > 0 {
+		return bumpSuggestion{Level: bumpMinor, Reasons: reasons}
+	}
+
+	for _, change := range changes {
+		if !matchAnyPath(patchPaths, change.Path) {
+			return bumpSuggestion{Level: bumpPatch, Reasons: []string{"no API changes detected"}}
+		}
+	}
+	return bumpSuggestion{Level: bumpPatch, Reasons: []string{"only documentation and tests changed"}}
+}
+
+// summarizeList joins the first few items and counts the rest
+func summarizeList(items []string) string {
+	const shown = 5
+	if len(items) <= shown {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:shown], ", "), len(items)-shown)
+}
+
+// bumpTag returns the tag following lastTag for the given bump level.
+// Before 1.0.0 a major bump only increments the minor version.
+func bumpTag(lastTag, tagFormat, level string) string {
+	next := calculateNextTag(lastTag, tagFormat)
+	if lastTag == "" || next == tagFormat || level == bumpPatch {
+		return next
+	}
+
+	prefix := extractPrefix(tagFormat)
+	parts := strings.Split(lastTag[len(prefix):], ".")
+	major, _ := strconv.Atoi(parts[0])
+	minor, _ := strconv.Atoi(parts[1])
+
+	if level == bumpMajor && major > 0 {
+		return fmt.Sprintf("%s%d.0.0", prefix, major+1)
+	}
+	return fmt.Sprintf("%s%d.%d.0", prefix, major, minor+1)
+}
+
+// tagCandidate is a version offered at the tag prompt by its number
+type tagCandidate struct {
+	Level string
+	Tag   string
+}
+
+// tagCandidates returns the patch, minor and major versions following
+// lastTag; unlike bumpTag the major candidate leaves 0.x for 1.0.0. There are
+// none for a first tag.
+func tagCandidates(lastTag, tagFormat string) []tagCandidate {
+	prefix := extractPrefix(tagFormat)
+	version, ok := parseSemver(strings.TrimPrefix(lastTag, prefix))
+	if lastTag == "" || !ok {
+		return nil
+	}
+	return []tagCandidate{
+		{bumpPatch, fmt.Sprintf("%s%d.%d.%d", prefix, version[0], version[1], version[2]+1)},
+		{bumpMinor, fmt.Sprintf("%s%d.%d.0", prefix, version[0], version[1]+1)},
+		{bumpMajor, fmt.Sprintf("%s%d.0.0", prefix, version[0]+1)},
+	}
+}
+
+// pickCandidate returns the tag of the candidate whose number (1 for the
+// first) was entered, or the input itself
+func pickCandidate(input string, candidates []tagCandidate) string {
+	if i, err := strconv.Atoi(input); err == nil && i > 0 && i <= len(candidates) {
+		return candidates[i-1].Tag
+	}
+	return input
+}
+
+// printBumpSuggestion explains the suggested bump level
+func printBumpSuggestion(suggestion bumpSuggestion) {
+	cyan := color.New(color.FgCyan).SprintFunc()
+	fmt.Printf("Suggested bump: %s\n", cyan(suggestion.Level))
+	for _, reason := range suggestion.Reasons {
+		fmt.Printf("  - %s\n", reason)
+	}
+}
+
+// suggestNextTag returns the tag suggested after lastTag on the branch of bt:
+// the next train release, the heuristic bump or else the next patch version
+func suggestNextTag(config Config, bt BranchTagConfig, lastTag string) (string, error) {
+	switch {
+	case bt.Train.enabled():
+		tag, train, err := trainTag(bt.Train, bt.Tag, lastTag, time.Now())
+		if err != nil {
+			return "", err
+		}
+		fmt.Println(describeTrain(train))
+		return tag, nil
+	case config.Bump.Suggest && lastTag != "":
+		if suggestion, err := suggestBump(lastTag, bt.Branch, bt.Paths, config.Bump); err != nil {
+			fmt.Printf("Warning: could not suggest a version bump: %v\n", err)
+		} else {
+			printBumpSuggestion(suggestion)
+			return bumpTag(lastTag, bt.Tag, suggestion.Level), nil
+		}
+	}
+	return calculateNextTag(lastTag, bt.Tag), nil
+}
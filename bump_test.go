@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestClassifyBump tests the bump heuristics
+func TestClassifyBump(t *testing.T) {
+	testCases := []struct {
+		name     string
+		changes  []fileChange
+		api      goAPIDiff
+		config   BumpConfig
+		expected string
+	}{
+		{"nothing changed", nil, goAPIDiff{}, BumpConfig{}, bumpPatch},
+		{"docs and tests", []fileChange{{"M", "README.md"}, {"M", "lib/lib_test.go"}, {"A", "docs/usage.txt"}}, goAPIDiff{}, BumpConfig{}, bumpPatch},
+		{"code change", []fileChange{{"M", "lib/lib.go"}}, goAPIDiff{}, BumpConfig{}, bumpPatch},
+		{"api directory", []fileChange{{"M", "api/v1/service.proto"}}, goAPIDiff{}, BumpConfig{}, bumpMinor},
+		{"configured api path", []fileChange{{"M", "schema/user.json"}}, goAPIDiff{}, BumpConfig{APIPaths: []string{"schema/"}}, bumpMinor},
+		{"added symbol", []fileChange{{"M", "lib/lib.go"}}, goAPIDiff{Added: []string{"lib/New"}}, BumpConfig{}, bumpMinor},
+		{"breaking change", []fileChange{{"M", "lib/lib.go"}}, goAPIDiff{Breaking: []string{"removed lib/Old"}, Added: []string{"lib/New"}}, BumpConfig{}, bumpMajor},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			suggestion := classifyBump(tc.changes, tc.api, tc.config)
+			if suggestion.Level != tc.expected {
+				t.Errorf("classifyBump() = %q (%v), expected %q", suggestion.Level, suggestion.Reasons, tc.expected)
+			}
+			if len(suggestion.Reasons) == 0 {
+				t.Errorf("classifyBump() gave no reasons")
+			}
+		})
+	}
+}
+
+// TestBumpTag tests computing the next tag for a bump level
+func TestBumpTag(t *testing.T) {
+	testCases := []struct {
+		lastTag  string
+		level    string
+		expected string
+	}{
+		{"v1.2.3", bumpPatch, "v1.2.4"},
+		{"v1.2.3", bumpMinor, "v1.3.0"},
+		{"v1.2.3", bumpMajor, "v2.0.0"},
+		{"v0.4.1", bumpMajor, "v0.5.0"},
+		{"", bumpMajor, "v0.0.0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.lastTag+" "+tc.level, func(t *testing.T) {
+			if result := bumpTag(tc.lastTag, "v0.0.0", tc.level); result != tc.expected {
+				t.Errorf("bumpTag(%q, %q) = %q, expected %q", tc.lastTag, tc.level, result, tc.expected)
+			}
+		})
+	}
+}
+
+// TestTagCandidates tests the versions offered by number at the tag prompt
+func TestTagCandidates(t *testing.T) {
+	testCases := []struct {
+		lastTag   string
+		tagFormat string
+		expected  []tagCandidate
+	}{
+		{"v1.4.2", "v0.0.0", []tagCandidate{{bumpPatch, "v1.4.3"}, {bumpMinor, "v1.5.0"}, {bumpMajor, "v2.0.0"}}},
+		{"v0.4.1", "v0.0.0", []tagCandidate{{bumpPatch, "v0.4.2"}, {bumpMinor, "v0.5.0"}, {bumpMajor, "v1.0.0"}}},
+		{"1.0.0", "0.0.0", []tagCandidate{{bumpPatch, "1.0.1"}, {bumpMinor, "1.1.0"}, {bumpMajor, "2.0.0"}}},
+		{"", "v0.0.0", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.lastTag, func(t *testing.T) {
+			if result := tagCandidates(tc.lastTag, tc.tagFormat); !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("tagCandidates(%q, %q) = %v, expected %v", tc.lastTag, tc.tagFormat, result, tc.expected)
+			}
+		})
+	}
+
+	candidates := tagCandidates("v1.4.2", "v0.0.0")
+	for input, expected := range map[string]string{"2": "v1.5.0", "3": "v2.0.0", "4": "4", "v1.4.5": "v1.4.5", "": ""} {
+		if result := pickCandidate(input, candidates); result != expected {
+			t.Errorf("pickCandidate(%q) = %q, expected %q", input, result, expected)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runBumpCommand implements the bump command: it commits the version files of
+// the next release on the checked-out branch without creating a tag, for
+// teams whose tags are created server-side once the commit is reviewed
+func runBumpCommand(args []string) {
+	fs := flag.NewFlagSet("bump", flag.ExitOnError)
+	branch := fs.String("branch", "", "configured branch to bump (default: the checked-out branch)")
+	tag := fs.String("tag", "", "tag whose version is written, skipping the prompt (default: the suggested next tag)")
+	format := fs.String("format", "", "tag format to bump when the branch has several with version files")
+	noFetch := fs.Bool("no-fetch", false, "use the local tags without fetching them from origin first")
+	dryRun := fs.Bool("dry-run", false, "only print the version that would be written")
+	fs.Parse(args)
+
+	config := readConfig()
+	if *branch == "" {
+		current, err := gitClient.CurrentBranch()
+		if err != nil {
+			fmt.Println("Error: HEAD is detached; check out the branch to bump or pass --branch")
+			os.Exit(1)
+		}
+		*branch = current
+	}
+	bt, err := bumpTarget(config, *branch, *format)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if _, ok := getAllRemoteURLs()["origin"]; ok && !*noFetch {
+		fetchRemote(config.Tags)
+	}
+	lastTag, err := releasedTag(config, bt, "")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	nextTag, err := suggestNextTag(config, bt, lastTag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if lastTag != "" {
+		fmt.Printf("Last tag: %s, suggested next tag: %s\n", lastTag, nextTag)
+	}
+
+	checkRules := func(tag string) error {
+		if err := checkTagRules(config.TagRules, bt.Branch, bt.Tag, lastTag, tag); err != nil {
+			return err
+		}
+		return checkPresetTag(config, bt.Branch, bt.Tag, tag)
+	}
+	version := *tag
+	if version != "" {
+		err := validateNewTag(version, bt.Tag, lastTag)
+		if err == nil {
+			err = checkRules(version)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		version, _ = promptForTag(bt.Tag, nextTag, lastTag, checkRules, false)
+	}
+
+	if *dryRun {
+		fmt.Printf("Would write version %s into %s on %s\n", strings.TrimPrefix(version, extractPrefix(bt.Tag)), versionFilePaths(bt.VersionFiles), bt.Branch)
+		return
+	}
+	committed, err := syncVersionFiles(bt.VersionFiles, bt.Branch, bt.Tag, version)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if committed {
+		fmt.Printf("Committed the version of %s on %s without tagging; push it for review, %s is to be tagged once it is merged\n", version, bt.Branch, version)
+	}
+}
+
+// bumpTarget returns the configuration of branch whose version files are
+// bumped; format picks one when several of its tag formats have version files
+func bumpTarget(config Config, branch, format string) (BranchTagConfig, error) {
+	var configured bool
+	var candidates []BranchTagConfig
+	for _, bt := range config.BranchTags {
+		if bt.Branch != branch {
+			continue
+		}
+		configured = true
+		if len(bt.VersionFiles) > 0 && (format == "" || bt.Tag == format) {
+			candidates = append(candidates, bt)
+		}
+	}
+	switch {
+	case !configured:
+		return BranchTagConfig{}, fmt.Errorf("branch '%s' is not configured", branch)
+	case len(candidates) == 0 && format != "":
+		return BranchTagConfig{}, fmt.Errorf("tag format %s of branch '%s' has no versionFiles", format, branch)
+	case len(candidates) == 0:
+		return BranchTagConfig{}, fmt.Errorf("branch '%s' has no versionFiles to bump", branch)
+	case len(candidates) > 1:
+		var formats []string
+		for _, bt := range candidates {
+			formats = append(formats, bt.Tag)
+		}
+		return BranchTagConfig{}, fmt.Errorf("branch '%s' has version files for the tag formats %s; pick one with --format", branch, strings.Join(formats, ", "))
+	}
+	return candidates[0], nil
+}
+
+// versionFilePaths lists the paths of files for messages
+func versionFilePaths(files []VersionFileConfig) string {
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	return strings.Join(uniqueStrings(paths), ", ")
+}
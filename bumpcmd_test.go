@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBumpTarget(t *testing.T) {
+	files := []VersionFileConfig{{Path: "pom.xml"}}
+	config := Config{BranchTags: []BranchTagConfig{
+		{Branch: "main", Tag: "v0.0.0", VersionFiles: files},
+		{Branch: "main", Tag: "api/v0.0.0"},
+		{Branch: "gray", Tag: "g0.0.0"},
+		{Branch: "charts", Tag: "a0.0.0", VersionFiles: files},
+		{Branch: "charts", Tag: "b0.0.0", VersionFiles: files},
+	}}
+
+	tests := []struct {
+		name    string
+		branch  string
+		format  string
+		want    string
+		wantErr string
+	}{
+		{"the format with version files", "main", "", "v0.0.0", ""},
+		{"format without version files", "main", "api/v0.0.0", "", "has no versionFiles"},
+		{"no version files", "gray", "", "", "has no versionFiles to bump"},
+		{"not configured", "dev", "", "", "is not configured"},
+		{"several formats", "charts", "", "", "pick one with --format"},
+		{"format picked", "charts", "b0.0.0", "b0.0.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bumpTarget(config, tt.branch, tt.format)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("bumpTarget() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got.Tag != tt.want {
+				t.Errorf("bumpTarget() = %s, %v, want %s", got.Tag, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// countChangesSince counts the commits on branch since lastTag that touch any of the given paths
+func countChangesSince(lastTag, branch string, paths []string) (int, error) {
+	return gitClient.CountCommits(lastTag, branchRef(branch), paths)
+}
+
+// confirmChangesSince warns when no files under paths changed since lastTag and
+// asks whether to tag anyway. It returns true when tagging should continue.
+func confirmChangesSince(lastTag, branch string, paths []string) bool {
+	count, err := countChangesSince(lastTag, branch, paths)
+	if err != nil {
+		fmt.Printf("Warning: could not detect changes since %s: %v\n", lastTag, err)
+		return true
+	}
+	if count > 0 {
+		fmt.Printf("%d commit(s) changed %s since %s\n", count, strings.Join(paths, ", "), lastTag)
+		return true
+	}
+
+	yellow := color.New(color.FgYellow).SprintFunc()
+	return prompter.Confirm(fmt.Sprintf("%s No changes detected under %s since %s - tag anyway?",
+		yellow("Warning:"), strings.Join(paths, ", "), lastTag), false)
+}
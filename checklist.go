@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// ChecklistItem is a step of the release checklist. Items with a command are
+// checked by running it; the others have to be confirmed by the operator.
+type ChecklistItem struct {
+	Text    string `json:"text,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// UnmarshalJSON also accepts a plain string, an item to confirm
+func (i *ChecklistItem) UnmarshalJSON(data []byte) error {
+	var text string
+	if json.Unmarshal(data, &text) == nil {
+		*i = ChecklistItem{Text: text}
+		return nil
+	}
+	type item ChecklistItem
+	return json.Unmarshal(data, (*item)(i))
+}
+
+// label is how the item is shown
+func (i ChecklistItem) label() string {
+	if i.Text != "" {
+		return i.Text
+	}
+	return i.Command
+}
+
+// validateChecklist returns the problems of the checklist items
+func validateChecklist(items []ChecklistItem) []string {
+	var problems []string
+	for n, item := range items {
+		if strings.TrimSpace(item.Text) == "" && strings.TrimSpace(item.Command) == "" {
+			problems = append(problems, fmt.Sprintf("checklist item %d has neither text nor command", n+1))
+		}
+	}
+	return problems
+}
+
+// runChecklist goes through the checklist before the planned tag is created.
+// Commands run with the environment of the hooks from the root of a temporary
+// detached work tree of the target commit, so they check what is tagged, and
+// check their item when they succeed; a failing command is an error. Every
+// other item is confirmed in turn, and the first one left unconfirmed returns
+// false.
+func runChecklist(items []ChecklistItem, plan Plan) (bool, error) {
+	if len(items) == 0 {
+		return true, nil
+	}
+	if !hasChecklistCommands(items) {
+		return goThroughChecklist(items, plan, "")
+	}
+	var done bool
+	err := inWorktree(plan.TargetCommit, func(dir string) error {
+		var err error
+		done, err = goThroughChecklist(items, plan, dir)
+		return err
+	})
+	return done, err
+}
+
+// hasChecklistCommands reports whether any item is checked by a command
+func hasChecklistCommands(items []ChecklistItem) bool {
+	for _, item := range items {
+		if item.Command != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// goThroughChecklist checks the items in turn, running the commands in dir
+func goThroughChecklist(items []ChecklistItem, plan Plan, dir string) (bool, error) {
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+
+	fmt.Printf("Release checklist for %s:\n", plan.Tag)
+	for _, item := range items {
+		if item.Command == "" {
+			if !prompter.Confirm(fmt.Sprintf("[ ] %s - done?", item.Text), false) {
+				fmt.Printf("Checklist item not done: %s\n", item.Text)
+				return false, nil
+			}
+			fmt.Printf("%s %s\n", green("[x]"), item.Text)
+			continue
+		}
+
+		cmd := execCommand("sh", "-c", item.Command)
+		cmd.Dir = dir
+		cmd.Env = hookEnv(plan)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			fmt.Printf("%s %s\n", red("[!]"), item.label())
+			if text := strings.TrimSpace(string(output)); text != "" {
+				for _, line := range strings.Split(text, "\n") {
+					fmt.Printf("    %s\n", line)
+				}
+			}
+			return false, fmt.Errorf("checklist item %q failed: %v", item.label(), err)
+		}
+		fmt.Printf("%s %s\n", green("[x]"), item.label())
+	}
+	return true, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChecklistItemUnmarshal(t *testing.T) {
+	var items []ChecklistItem
+	data := `["Announce the freeze in #releases", {"text": "Tests pass", "command": "make test"}, {"command": "make lint"}]`
+	if err := json.Unmarshal([]byte(data), &items); err != nil {
+		t.Fatal(err)
+	}
+	want := []ChecklistItem{
+		{Text: "Announce the freeze in #releases"},
+		{Text: "Tests pass", Command: "make test"},
+		{Command: "make lint"},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items = %+v, want %+v", items, want)
+	}
+	if problems := validateChecklist(append(items, ChecklistItem{Text: " "})); len(problems) != 1 {
+		t.Errorf("validateChecklist() = %q, want one problem", problems)
+	}
+}
+
+func TestRunChecklist(t *testing.T) {
+	originalExecCommand := execCommand
+	defer func() { execCommand = originalExecCommand }()
+	var ran []string
+	execCommand = func(command string, args ...string) *exec.Cmd {
+		ran = append(ran, args[len(args)-1])
+		if strings.Contains(args[len(args)-1], "fail") {
+			return exec.Command("sh", "-c", "echo broken; exit 1")
+		}
+		return exec.Command("true")
+	}
+
+	items := []ChecklistItem{
+		{Text: "Changelog reviewed"},
+		{Text: "Tests pass", Command: "make test"},
+		{Text: "Support informed"},
+	}
+	tests := []struct {
+		name     string
+		items    []ChecklistItem
+		answers  []string
+		wantDone bool
+		wantErr  bool
+		wantRan  []string
+	}{
+		{"all done", items, []string{"y", "y"}, true, false, []string{"make test"}},
+		{"manual item not done", items, []string{"n"}, false, false, nil},
+		{"no answer", items, nil, false, false, nil},
+		{"failing command", []ChecklistItem{{Command: "make fail"}, {Text: "Support informed"}}, nil, false, true, []string{"make fail"}},
+		{"empty", nil, nil, true, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran = nil
+			useScriptedPrompter(t, tt.answers...)
+			g := newFakeGitClient("c1")
+			useFakeGit(t, g)
+			var done bool
+			var err error
+			output := captureOutput(func() { done, err = runChecklist(tt.items, Plan{Tag: "v1.2.0", TargetCommit: "c1"}) })
+			if done != tt.wantDone || (err != nil) != tt.wantErr {
+				t.Errorf("runChecklist() = %v, %v, want %v, error %v", done, err, tt.wantDone, tt.wantErr)
+			}
+			if !reflect.DeepEqual(ran, tt.wantRan) {
+				t.Errorf("ran %q, want %q", ran, tt.wantRan)
+			}
+			if tt.wantErr && !strings.Contains(output, "broken") {
+				t.Errorf("output %q lacks the output of the failed command", output)
+			}
+			if len(g.worktrees) != 0 {
+				t.Errorf("work trees left behind: %v", g.worktrees)
+			}
+		})
+	}
+}
+
+// TestRunChecklistAtTargetCommit tests that commands check the commit being
+// tagged, from the repository root, when another branch is checked out
+func TestRunChecklistAtTargetCommit(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	gitInTestRepo(t, dir, "checkout", "-q", "-b", "release")
+	os.WriteFile(filepath.Join(dir, "CHANGELOG.md"), []byte("## 1.2.0\n"), 0644)
+	gitInTestRepo(t, dir, "add", "CHANGELOG.md")
+	gitInTestRepo(t, dir, "commit", "-q", "-m", "Changelog for 1.2.0")
+	gitInTestRepo(t, dir, "checkout", "-q", "main")
+	os.MkdirAll(filepath.Join(dir, "docs"), 0755)
+	os.Chdir(filepath.Join(dir, "docs"))
+	release, err := lookupCommit("release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	main, err := lookupCommit("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := []ChecklistItem{{Text: "Changelog written", Command: `grep -q "## 1.2.0" CHANGELOG.md && test "$(git rev-parse HEAD)" = "$GIT_PUBLISH_COMMIT"`}}
+	tests := []struct {
+		name     string
+		commit   string
+		wantDone bool
+	}{
+		{"release", release, true},
+		{"main", main, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var done bool
+			var err error
+			output := captureOutput(func() { done, err = runChecklist(items, Plan{Branch: tt.name, Tag: "v1.2.0", TargetCommit: tt.commit}) })
+			if done != tt.wantDone || (err != nil) == tt.wantDone {
+				t.Errorf("runChecklist() = %v, %v, want done %v\n%s", done, err, tt.wantDone, output)
+			}
+			cmd := exec.Command("git", "worktree", "list", "--porcelain")
+			cmd.Dir = dir
+			if list, _ := cmd.Output(); strings.Count(string(list), "worktree ") != 1 {
+				t.Errorf("work trees left behind:\n%s", list)
+			}
+		})
+	}
+}
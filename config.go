@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// configFileName is the repository configuration file, kept at the root of the work tree
+const configFileName = "publish.json"
+
+// configPollInterval is how often long-running modes check publish.json for changes
+const configPollInterval = 2 * time.Second
+
+// repositoryConfigPath returns the path of publish.json at the root of the work
+// tree, wherever in the work tree the tool runs. A publish.json in the current
+// subdirectory is reported as an error rather than silently ignored.
+func repositoryConfigPath() (string, error) {
+	root, err := gitClient.TopLevel()
+	if err != nil {
+		return configFileName, nil
+	}
+	path := filepath.Join(root, configFileName)
+
+	cwd, err := os.Getwd()
+	if err != nil || sameDir(cwd, root) {
+		return path, nil
+	}
+	local := filepath.Join(cwd, configFileName)
+	if _, err := os.Stat(local); err != nil {
+		return path, nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("found %s and %s; git-publish only reads the one at the repository root, remove the other", path, local)
+	}
+	return "", fmt.Errorf("found %s, but git-publish reads %s from the repository root; move it to %s", local, configFileName, path)
+}
+
+// sameDir reports whether a and b are the same directory, resolving symbolic links
+func sameDir(a, b string) bool {
+	if resolved, err := filepath.EvalSymlinks(a); err == nil {
+		a = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(b); err == nil {
+		b = resolved
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// loadConfigFile reads and validates a configuration file without falling back to defaults
+func loadConfigFile(path string) (Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return config, validateConfig(config)
+}
+
+// validateConfig checks a configuration for mistakes that would only surface while publishing
+func validateConfig(config Config) error {
+	var problems []string
+
+	if config.Version > currentConfigVersion() {
+		problems = append(problems, fmt.Sprintf("schema version %d is newer than this git-publish knows (%d); upgrade git-publish", config.Version, currentConfigVersion()))
+	}
+	if len(config.BranchTags) == 0 {
+		problems = append(problems, "branchTags is empty")
+	}
+	for _, bt := range config.BranchTags {
+		if bt.Branch == "" {
+			problems = append(problems, "a branchTags entry has no branch")
+		}
+		if !validateTagFormat(bt.Tag, extractPrefix(bt.Tag)) {
+			problems = append(problems, fmt.Sprintf("tag format %q of branch %s is not <prefix>X.Y.Z", bt.Tag, bt.Branch))
+		}
+		problems = append(problems, validateTrainConfig(bt.Branch, bt.Train)...)
+		problems = append(problems, validateVersionFiles(bt.Branch, bt.VersionFiles)...)
+		problems = append(problems, validateVersionChecks(bt.Branch, bt.VersionCheck)...)
+		problems = append(problems, validateDeployedConfig(bt)...)
+	}
+
+	switch config.GoAPICheck {
+	case "", goAPICheckBlock, goAPICheckWarn, goAPICheckOff:
+	default:
+		problems = append(problems, fmt.Sprintf("goApiCheck %q is not block, warn or off", config.GoAPICheck))
+	}
+	problems = append(problems, validateTicketConfig(config.Ticket)...)
+	problems = append(problems, validateTagRules(config.TagRules)...)
+	problems = append(problems, validateTagsConfig(config.Tags)...)
+	problems = append(problems, validateSignaturesConfig(config.Signatures)...)
+	problems = append(problems, validateAnnounceConfig(config.Announce)...)
+	problems = append(problems, validateReleaseConfig(config.Release)...)
+	problems = append(problems, validateGitOpsConfig(config.GitOps)...)
+	problems = append(problems, validatePruneConfig(config.Prune)...)
+	problems = append(problems, validatePreset(config)...)
+	problems = append(problems, validateProviderCLI(config.Provider.CLI)...)
+	problems = append(problems, validateAliases(config.Aliases)...)
+	problems = append(problems, validateChecklist(config.Checklist)...)
+	problems = append(problems, validateSigningConfig(config.Signing)...)
+	problems = append(problems, validateRemindConfig(config.Remind)...)
+	switch strings.ToLower(config.Provider.Type) {
+	case "", providerGitHub, providerGitLab, providerGitea, providerBitbucket, providerAzure:
+	default:
+		problems = append(problems, fmt.Sprintf("unknown provider type %q", config.Provider.Type))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// tagPrefixCollisions describes pairs of branchTags entries whose tag prefixes
+// overlap, such as "g" and "gray-": the pattern g* used to look up and protect
+// the tags of the first entry also matches every tag of the second. Each
+// description names up to three existing tags matching both, or an example.
+func tagPrefixCollisions(config Config) []string {
+	var collisions []string
+	for i, a := range config.BranchTags {
+		for _, b := range config.BranchTags[i+1:] {
+			broad, narrow := a, b
+			if len(extractPrefix(broad.Tag)) > len(extractPrefix(narrow.Tag)) {
+				broad, narrow = narrow, broad
+			}
+			broadPrefix, narrowPrefix := extractPrefix(broad.Tag), extractPrefix(narrow.Tag)
+			if broadPrefix == narrowPrefix || !strings.HasPrefix(narrowPrefix, broadPrefix) {
+				continue
+			}
+			// Tags without a prefix are looked up by [0-9]*, which only
+			// catches prefixes starting with a digit
+			if broadPrefix == "" && (narrowPrefix[0] < '0' || narrowPrefix[0] > '9') {
+				continue
+			}
+
+			examples, _ := gitClient.ListTags(narrowPrefix + "*")
+			if len(examples) > 3 {
+				examples = examples[:3]
+			}
+			if len(examples) == 0 {
+				examples = []string{narrowPrefix + "1.0.0"}
+			}
+			collisions = append(collisions, fmt.Sprintf("tag prefix %q of branch %s also matches the tags of branch %s (prefix %q), e.g. %s",
+				broadPrefix, broad.Branch, narrow.Branch, narrowPrefix, strings.Join(examples, ", ")))
+		}
+	}
+	return collisions
+}
+
+// configWatcher detects changes of the configuration files, publish.json and
+// the user config, by polling their modification times and sizes. Polling
+// needs no dependency beyond the standard library and also notices files
+// that editors replace by renaming a new one over them, which drops
+// file-change notifications watching the old file.
+type configWatcher struct {
+	// opts are the flags of the command, which keep precedence after a reload
+	opts  *publishOptions
+	files []watchedFile
+}
+
+// watchedFile is the last seen state of a watched file, zero while it does not exist
+type watchedFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// newConfigWatcher starts watching the configuration files from their current state
+func newConfigWatcher(opts *publishOptions) *configWatcher {
+	w := &configWatcher{opts: opts}
+	if path, err := repositoryConfigPath(); err == nil {
+		w.files = append(w.files, watchedFile{path: path})
+	}
+	if path, err := userConfigPath(); err == nil {
+		w.files = append(w.files, watchedFile{path: path})
+	}
+	w.changed()
+	return w
+}
+
+// changed reports whether a file changed since the last call
+func (w *configWatcher) changed() bool {
+	changed := false
+	for i := range w.files {
+		f := &w.files[i]
+		var modTime time.Time
+		var size int64
+		if info, err := os.Stat(f.path); err == nil {
+			modTime, size = info.ModTime(), info.Size()
+		}
+		if !modTime.Equal(f.modTime) || size != f.size {
+			f.modTime, f.size = modTime, size
+			changed = true
+		}
+	}
+	return changed
+}
+
+// reload returns the new configuration when a file changed and the result is
+// valid. It is resolved like at startup, over the defaults and under the
+// environment and flags. Invalid changes are reported and ignored so the
+// previous configuration stays in use.
+func (w *configWatcher) reload() (Config, bool) {
+	if !w.changed() {
+		return Config{}, false
+	}
+	config, err := reloadConfig(w.opts)
+	if err != nil {
+		fmt.Printf("Warning: ignoring the configuration change: %v\n", err)
+		return Config{}, false
+	}
+	fmt.Println("Reloaded the configuration")
+	logFor(logConfig).Info("config reloaded")
+	return config, true
+}
+
+// watchConfig calls apply with every valid change of the configuration files,
+// resolved with the flags in opts, until stop is closed
+func watchConfig(opts *publishOptions, apply func(Config), stop <-chan struct{}) {
+	w := newConfigWatcher(opts)
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if config, ok := w.reload(); ok {
+				apply(config)
+			}
+		}
+	}
+}
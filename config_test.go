@@ -0,0 +1,203 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestValidateConfig tests detection of invalid configurations
+func TestValidateConfig(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  Config
+		isValid bool
+	}{
+		{"default", defaultConfig, true},
+		{"empty", Config{}, false},
+		{"bad tag format", Config{BranchTags: []BranchTagConfig{{Branch: "main", Tag: "v1.0"}}}, false},
+		{"missing branch", Config{BranchTags: []BranchTagConfig{{Tag: "v0.0.0"}}}, false},
+		{"bad goApiCheck", Config{BranchTags: defaultConfig.BranchTags, GoAPICheck: "maybe"}, false},
+		{"bad provider", Config{BranchTags: defaultConfig.BranchTags, Provider: ProviderConfig{Type: "svn"}}, false},
+		{"bad provider cli", Config{BranchTags: defaultConfig.BranchTags, Provider: ProviderConfig{CLI: "sometimes"}}, false},
+		{"bad ticket mode", Config{BranchTags: defaultConfig.BranchTags, Ticket: TicketConfig{Mode: "always"}}, false},
+		{"bad tag rule", Config{BranchTags: defaultConfig.BranchTags, TagRules: []TagRule{{Rule: "patch <"}}}, false},
+		{"bad ticket pattern", Config{BranchTags: defaultConfig.BranchTags, Ticket: TicketConfig{Pattern: "CHG-("}}, false},
+		{"bad release preset", Config{BranchTags: defaultConfig.BranchTags, Release: ReleaseConfig{Preset: "eslint"}}, false},
+		{"github release notes", Config{BranchTags: defaultConfig.BranchTags, Release: ReleaseConfig{Notes: "github"}}, true},
+		{"github release notes with a preset", Config{BranchTags: defaultConfig.BranchTags, Release: ReleaseConfig{Notes: "github", Preset: "angular"}}, false},
+		{"bad release notes", Config{BranchTags: defaultConfig.BranchTags, Release: ReleaseConfig{Notes: "changelog"}}, false},
+		{"pull request release notes", Config{BranchTags: defaultConfig.BranchTags, Release: ReleaseConfig{Notes: "pullRequests", Sections: []NotesSection{{Title: "Docs", Labels: []string{"documentation"}}}}}, true},
+		{"sections without pull request notes", Config{BranchTags: defaultConfig.BranchTags, Release: ReleaseConfig{Sections: []NotesSection{{Title: "Docs", Labels: []string{"documentation"}}}}}, false},
+		{"section without labels", Config{BranchTags: defaultConfig.BranchTags, Release: ReleaseConfig{Notes: "pullRequests", Sections: []NotesSection{{Title: "Docs"}}}}, false},
+		{"bad version file", Config{BranchTags: []BranchTagConfig{{Branch: "main", Tag: "v0.0.0", VersionFiles: []VersionFileConfig{{Path: "build.gradle"}}}}}, false},
+		{"bad prune age", Config{BranchTags: defaultConfig.BranchTags, Prune: PruneConfig{Patterns: []string{"v*-rc*"}, MaxAge: "3 months"}}, false},
+		{"bad ref namespace", Config{BranchTags: defaultConfig.BranchTags, Tags: TagsConfig{Namespace: "refs/tags"}}, false},
+		{"unknown preset", Config{BranchTags: defaultConfig.BranchTags, Preset: "npm"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateConfig(tc.config); (err == nil) != tc.isValid {
+				t.Errorf("validateConfig() error = %v, expected valid: %v", err, tc.isValid)
+			}
+		})
+	}
+}
+
+// TestTagPrefixCollisions tests detection of overlapping tag prefixes
+func TestTagPrefixCollisions(t *testing.T) {
+	g := newFakeGitClient("a")
+	g.tags = map[string]string{"gray-1.0.0": "a", "gray-1.1.0": "a", "v1.0.0": "a"}
+	useFakeGit(t, g)
+
+	testCases := []struct {
+		name       string
+		branchTags []BranchTagConfig
+		want       []string
+	}{
+		{"default", defaultConfig.BranchTags, nil},
+		{"same prefix", []BranchTagConfig{{Branch: "main", Tag: "v0.0.0"}, {Branch: "release", Tag: "v0.0.0"}}, nil},
+		{"existing tags", []BranchTagConfig{{Branch: "main", Tag: "g0.0.0"}, {Branch: "gray", Tag: "gray-0.0.0"}},
+			[]string{`tag prefix "g" of branch main also matches the tags of branch gray (prefix "gray-"), e.g. gray-1.1.0, gray-1.0.0`}},
+		{"example tag", []BranchTagConfig{{Branch: "next", Tag: "v-0.0.0"}, {Branch: "main", Tag: "v0.0.0"}},
+			[]string{`tag prefix "v" of branch main also matches the tags of branch next (prefix "v-"), e.g. v-1.0.0`}},
+		{"no prefix", []BranchTagConfig{{Branch: "main", Tag: "0.0.0"}, {Branch: "gray", Tag: "gray-0.0.0"}}, nil},
+		{"no prefix and a digit", []BranchTagConfig{{Branch: "main", Tag: "0.0.0"}, {Branch: "lts", Tag: "1-0.0.0"}},
+			[]string{`tag prefix "" of branch main also matches the tags of branch lts (prefix "1-"), e.g. 1-1.0.0`}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tagPrefixCollisions(Config{BranchTags: tc.branchTags})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("tagPrefixCollisions() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestConfigWatcherReload tests that only valid changes are reloaded, resolved
+// over the user config and under the environment and flags like at startup
+func TestConfigWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	g := newFakeGitClient("a")
+	g.root = dir
+	useFakeGit(t, g)
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+	os.Chdir(dir)
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("GIT_PUBLISH_RELEASE", "true")
+	userConfig := filepath.Join(home, "git-publish", "config.json")
+	os.MkdirAll(filepath.Dir(userConfig), 0755)
+	// Distinct modification times even on coarse file systems
+	age := 10 * time.Hour
+	write := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		age -= time.Hour
+		mtime := time.Now().Add(-age)
+		os.Chtimes(path, mtime, mtime)
+	}
+	path := filepath.Join(dir, "publish.json")
+
+	write(path, `{"branchTags":[{"branch":"main","tag":"v0.0.0"}]}`)
+	write(userConfig, `{"publish":{"verify":"make check"}}`)
+	w := newConfigWatcher(&publishOptions{SSHKey: "~/.ssh/release"})
+	if _, ok := w.reload(); ok {
+		t.Errorf("reload() reported a change of untouched files")
+	}
+
+	output := captureOutput(func() {
+		write(path, `{"branchTags":[{"branch":"main","tag":"x"}]}`)
+		if _, ok := w.reload(); ok {
+			t.Errorf("reload() accepted an invalid configuration")
+		}
+		write(path, `{"branchTags":[{"branch":"main",`)
+		if _, ok := w.reload(); ok {
+			t.Errorf("reload() accepted a truncated configuration")
+		}
+	})
+	if strings.Count(output, "Warning: ignoring the configuration change") != 2 {
+		t.Errorf("reload() printed %q, want both changes ignored", output)
+	}
+
+	write(path, `{"branchTags":[{"branch":"release","tag":"r0.0.0"}]}`)
+	var config Config
+	var ok bool
+	captureOutput(func() { config, ok = w.reload() })
+	if !ok || config.BranchTags[0].Branch != "release" {
+		t.Fatalf("reload() = %+v, %v, expected the release branch", config, ok)
+	}
+	if !config.Release.Create || config.Push.SSHKey != "~/.ssh/release" || config.Verify != "make check" {
+		t.Errorf("reload() lost settings outside publish.json: release %v, ssh key %q, verify %q", config.Release.Create, config.Push.SSHKey, config.Verify)
+	}
+
+	// A change of the user config is picked up as well
+	write(userConfig, `{"publish":{"verify":"go test ./..."}}`)
+	captureOutput(func() { config, ok = w.reload() })
+	if !ok || config.Verify != "go test ./..." || config.BranchTags[0].Branch != "release" {
+		t.Errorf("reload() after a user config change = %+v, %v", config, ok)
+	}
+}
+
+func TestRepositoryConfigPath(t *testing.T) {
+	root := t.TempDir()
+	subdir := filepath.Join(root, "cmd")
+	if err := os.Mkdir(subdir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	g := newFakeGitClient("a")
+	g.root = root
+	useFakeGit(t, g)
+
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+
+	tests := []struct {
+		name       string
+		dir        string
+		rootConfig bool
+		subConfig  bool
+		want       string
+		wantErr    bool
+	}{
+		{"at the root", root, true, false, filepath.Join(root, "publish.json"), false},
+		{"subdirectory uses the root config", subdir, true, false, filepath.Join(root, "publish.json"), false},
+		{"subdirectory without any config", subdir, false, false, filepath.Join(root, "publish.json"), false},
+		{"configs in both places", subdir, true, true, "", true},
+		{"config only in the subdirectory", subdir, false, true, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for path, exists := range map[string]bool{filepath.Join(root, "publish.json"): tt.rootConfig, filepath.Join(subdir, "publish.json"): tt.subConfig} {
+				os.Remove(path)
+				if exists {
+					os.WriteFile(path, []byte(`{"branchTags": [{"branch": "main", "tag": "v0.0.0"}]}`), 0644)
+				}
+			}
+			os.Chdir(tt.dir)
+
+			got, err := repositoryConfigPath()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("repositoryConfigPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("repositoryConfigPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Outside a work tree the current directory is used
+	g.root = ""
+	if got, err := repositoryConfigPath(); err != nil || got != "publish.json" {
+		t.Errorf("repositoryConfigPath() without work tree = %q, %v", got, err)
+	}
+}
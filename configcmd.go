@@ -0,0 +1,445 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// configUsage lists the config subcommands
+const configUsage = `Usage: git-publish config <command>
+  show [--origin]                 print the effective configuration
+  get <key>                       print one effective value, e.g. push.sshKey
+  set [--global] <key> <value>    set a value, e.g. branchTags[0].tag v0.0.0
+  unset [--global] <key>          remove a value
+  edit [--tui]                    open publish.json in $VISUAL or $EDITOR, or edit it through menus
+  migrate [--check]               update publish.json to the current schema version`
+
+// runConfigCommand implements the config command
+func runConfigCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println(configUsage)
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "show":
+		runConfigShow(args[1:])
+	case "get":
+		runConfigGet(args[1:])
+	case "set":
+		runConfigSet(args[1:])
+	case "unset":
+		runConfigUnset(args[1:])
+	case "edit":
+		runConfigEdit(args[1:])
+	case "migrate":
+		runConfigMigrate(args[1:])
+	default:
+		fmt.Printf("Error: Unknown config command '%s'\n", args[0])
+		fmt.Println(configUsage)
+		os.Exit(1)
+	}
+}
+
+// runConfigShow prints the effective configuration, optionally with the origin of every value
+func runConfigShow(args []string) {
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	origin := fs.Bool("origin", false, "show where each value comes from")
+	opts := addPublishFlags(fs)
+	fs.Parse(args)
+
+	resolved, err := loadConfig(opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !*origin {
+		data, err := json.MarshalIndent(resolved.Values, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, key := range flattenValues(resolved.Values, "") {
+		value, _ := lookupValue(resolved.Values, key)
+		data, _ := json.Marshal(value)
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, data, resolved.Origins[key])
+	}
+	w.Flush()
+}
+
+// runConfigGet prints the effective value of a key; strings are printed as is, anything else as JSON
+func runConfigGet(args []string) {
+	fs := flag.NewFlagSet("config get", flag.ExitOnError)
+	opts := addPublishFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: git-publish config get <key>")
+		os.Exit(1)
+	}
+
+	resolved, err := loadConfig(opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	value, ok := lookupValue(resolved.Values, fs.Arg(0))
+	if !ok {
+		fmt.Printf("Error: %s is not set\n", fs.Arg(0))
+		os.Exit(1)
+	}
+
+	if s, isString := value.(string); isString {
+		fmt.Println(s)
+		return
+	}
+	data, _ := json.MarshalIndent(value, "", "  ")
+	fmt.Println(string(data))
+}
+
+// runConfigSet sets a value in publish.json or, with --global, in the user config
+func runConfigSet(args []string) {
+	fs := flag.NewFlagSet("config set", flag.ExitOnError)
+	global := fs.Bool("global", false, "change the publish settings of the user config")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fmt.Println("Usage: git-publish config set [--global] <key> <value>")
+		os.Exit(1)
+	}
+	key := fs.Arg(0)
+
+	target, err := readConfigTarget(*global)
+	if err == nil {
+		err = setValue(target.Values, key, parseConfigValue(fs.Arg(1)))
+	}
+	if err == nil {
+		err = target.save()
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Set %s in %s\n", key, target.Path)
+}
+
+// runConfigUnset removes a value from publish.json or, with --global, from the user config
+func runConfigUnset(args []string) {
+	fs := flag.NewFlagSet("config unset", flag.ExitOnError)
+	global := fs.Bool("global", false, "change the publish settings of the user config")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: git-publish config unset [--global] <key>")
+		os.Exit(1)
+	}
+	key := fs.Arg(0)
+
+	target, err := readConfigTarget(*global)
+	if err == nil {
+		err = unsetValue(target.Values, key)
+	}
+	if err == nil {
+		err = target.save()
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Removed %s from %s\n", key, target.Path)
+}
+
+// runConfigEdit opens publish.json in the user's editor and checks the result.
+// An invalid file is reopened on request; otherwise the previous content is restored.
+func runConfigEdit(args []string) {
+	fs := flag.NewFlagSet("config edit", flag.ExitOnError)
+	tui := fs.Bool("tui", false, "edit branches, hooks and integrations through menus instead of an editor")
+	fs.Parse(args)
+	if *tui {
+		runConfigForm()
+		return
+	}
+
+	path, err := repositoryConfigPath()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		writeDefaultConfig(path, repositoryDefaultConfig())
+	}
+	original, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for {
+		if err := runEditor(path); err != nil {
+			fmt.Printf("Error running the editor: %v\n", err)
+			os.Exit(1)
+		}
+		_, err := loadConfigFile(path)
+		if err == nil {
+			fmt.Printf("Saved %s\n", path)
+			return
+		}
+		fmt.Printf("Error: %v\n", err)
+		if !prompter.Confirm("Edit the file again?", true) {
+			break
+		}
+	}
+
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		fmt.Printf("Error restoring %s: %v\n", path, err)
+	} else {
+		fmt.Printf("Restored the previous %s\n", path)
+	}
+	os.Exit(1)
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, falling back to vi
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// The editor may carry arguments, e.g. "code --wait"
+	cmd := execCommand("sh", "-c", editor+` "$1"`, "sh", path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// configTarget is a configuration file changed by config set and unset
+type configTarget struct {
+	Path   string
+	Values map[string]interface{}
+	global bool
+}
+
+// readConfigTarget reads the values of publish.json or, if global, the publish
+// settings of the user config. A missing publish.json starts from the default
+// configuration; a file that cannot be parsed is an error, so it is never overwritten.
+func readConfigTarget(global bool) (*configTarget, error) {
+	if global {
+		path, err := userConfigPath()
+		if err != nil {
+			return nil, err
+		}
+		var userConfig UserConfig
+		if data, err := os.ReadFile(path); err == nil {
+			if err := json.Unmarshal(data, &userConfig); err != nil {
+				return nil, fmt.Errorf("parsing %s: %v", path, err)
+			}
+		}
+		if userConfig.Publish == nil {
+			userConfig.Publish = map[string]interface{}{}
+		}
+		return &configTarget{Path: path, Values: userConfig.Publish, global: true}, nil
+	}
+
+	path, err := repositoryConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &configTarget{Path: path, Values: configValues(Config{BranchTags: repositoryDefaultConfig().BranchTags})}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v; fix it with git-publish config edit", path, err)
+	}
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	return &configTarget{Path: path, Values: values}, nil
+}
+
+// save checks the changed values and writes them back. Values of the wrong
+// type, and for publish.json anything validateConfig rejects, are refused.
+func (t *configTarget) save() error {
+	data, err := json.MarshalIndent(t.Values, "", "  ")
+	if err != nil {
+		return err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("invalid value: %v", err)
+	}
+
+	if !t.global {
+		if err := validateConfig(config); err != nil {
+			return err
+		}
+		return os.WriteFile(t.Path, data, 0644)
+	}
+
+	userConfig, _ := readUserConfig()
+	userConfig.Publish = t.Values
+	_, err = writeUserConfig(userConfig)
+	return err
+}
+
+// parseConfigValue interprets a command line value as JSON (true, 3, ["a"],
+// "quoted"), anything else is taken as a plain string
+func parseConfigValue(s string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return s
+	}
+	return value
+}
+
+// parseKeyPath splits a key like branchTags[0].tag into object keys (strings)
+// and list indexes (ints)
+func parseKeyPath(key string) ([]interface{}, error) {
+	var path []interface{}
+	for _, segment := range strings.Split(key, ".") {
+		name := segment
+		if i := strings.Index(segment, "["); i >= 0 {
+			name = segment[:i]
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid key %q", key)
+		}
+		path = append(path, name)
+
+		for rest := segment[len(name):]; rest != ""; {
+			end := strings.Index(rest, "]")
+			if rest[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid key %q", key)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index in key %q", key)
+			}
+			path = append(path, index)
+			rest = rest[end+1:]
+		}
+	}
+	return path, nil
+}
+
+// setValue sets the value at the key path, creating objects on the way. A list
+// index may be one past the end to append an entry.
+func setValue(values map[string]interface{}, key string, value interface{}) error {
+	path, err := parseKeyPath(key)
+	if err != nil {
+		return err
+	}
+	if _, err := setIn(values, path, value); err != nil {
+		return fmt.Errorf("cannot set %s: %v", key, err)
+	}
+	return nil
+}
+
+func setIn(node interface{}, path []interface{}, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	switch part := path[0].(type) {
+	case string:
+		object, ok := node.(map[string]interface{})
+		if node == nil {
+			object, ok = map[string]interface{}{}, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s is inside a value that is not an object", part)
+		}
+		child, err := setIn(object[part], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		object[part] = child
+		return object, nil
+	default:
+		index := part.(int)
+		list, ok := node.([]interface{})
+		if node != nil && !ok {
+			return nil, fmt.Errorf("index %d used on a value that is not a list", index)
+		}
+		if index > len(list) {
+			return nil, fmt.Errorf("index %d is out of range, the list has %d entries", index, len(list))
+		}
+		if index == len(list) {
+			list = append(list, nil)
+		}
+		child, err := setIn(list[index], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		list[index] = child
+		return list, nil
+	}
+}
+
+// unsetValue removes the value at the key path. Objects left empty are removed
+// as well; removing a list entry shifts the following entries.
+func unsetValue(values map[string]interface{}, key string) error {
+	path, err := parseKeyPath(key)
+	if err != nil {
+		return err
+	}
+	if _, err := unsetIn(values, path); err != nil {
+		return fmt.Errorf("cannot unset %s: %v", key, err)
+	}
+	return nil
+}
+
+func unsetIn(node interface{}, path []interface{}) (interface{}, error) {
+	switch part := path[0].(type) {
+	case string:
+		object, ok := node.(map[string]interface{})
+		if _, exists := object[part]; !ok || !exists {
+			return nil, fmt.Errorf("%s is not set", part)
+		}
+		if len(path) == 1 {
+			delete(object, part)
+			return object, nil
+		}
+		child, err := unsetIn(object[part], path[1:])
+		if err != nil {
+			return nil, err
+		}
+		if emptied, isObject := child.(map[string]interface{}); isObject && len(emptied) == 0 {
+			delete(object, part)
+		} else {
+			object[part] = child
+		}
+		return object, nil
+	default:
+		index := part.(int)
+		list, ok := node.([]interface{})
+		if !ok || index >= len(list) {
+			return nil, fmt.Errorf("entry %d does not exist", index)
+		}
+		if len(path) == 1 {
+			return append(list[:index], list[index+1:]...), nil
+		}
+		child, err := unsetIn(list[index], path[1:])
+		if err != nil {
+			return nil, err
+		}
+		list[index] = child
+		return list, nil
+	}
+}
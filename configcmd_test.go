@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyPath(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    []interface{}
+		wantErr bool
+	}{
+		{"goApiCheck", []interface{}{"goApiCheck"}, false},
+		{"push.sshKey", []interface{}{"push", "sshKey"}, false},
+		{"branchTags[0].tag", []interface{}{"branchTags", 0, "tag"}, false},
+		{"matrix[1][2]", []interface{}{"matrix", 1, 2}, false},
+		{"branchTags[x]", nil, true},
+		{"branchTags[-1]", nil, true},
+		{"branchTags[0", nil, true},
+		{"push..sshKey", nil, true},
+		{"[0]", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseKeyPath(tt.key)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseKeyPath(%q) = %v, %v, want %v (error: %v)", tt.key, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// configJSON decodes a JSON document into the generic shape used by set and unset
+func configJSON(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &values); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func TestSetValue(t *testing.T) {
+	base := `{"branchTags":[{"branch":"main","tag":"v0.0.0"}]}`
+
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"replace list field", "branchTags[0].tag", "r0.0.0", `{"branchTags":[{"branch":"main","tag":"r0.0.0"}]}`, false},
+		{"append list entry", "branchTags[1]", `{"branch":"gray","tag":"g0.0.0"}`, `{"branchTags":[{"branch":"main","tag":"v0.0.0"},{"branch":"gray","tag":"g0.0.0"}]}`, false},
+		{"create objects", "push.followTags", "true", `{"branchTags":[{"branch":"main","tag":"v0.0.0"}],"push":{"followTags":true}}`, false},
+		{"index out of range", "branchTags[2].tag", "v0.0.0", "", true},
+		{"key inside a string", "branchTags[0].tag.x", "1", "", true},
+		{"index on an object", "branchTags[0][1]", "1", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := configJSON(t, base)
+			err := setValue(values, tt.key, parseConfigValue(tt.value))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setValue() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(values, configJSON(t, tt.want)) {
+				t.Errorf("setValue() = %v, want %s", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnsetValue(t *testing.T) {
+	base := `{"branchTags":[{"branch":"main","tag":"v0.0.0"},{"branch":"gray","tag":"g0.0.0"}],"provider":{"type":"gitea"}}`
+
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"list entry", "branchTags[0]", `{"branchTags":[{"branch":"gray","tag":"g0.0.0"}],"provider":{"type":"gitea"}}`, false},
+		{"emptied object", "provider.type", `{"branchTags":[{"branch":"main","tag":"v0.0.0"},{"branch":"gray","tag":"g0.0.0"}]}`, false},
+		{"missing key", "push.sshKey", "", true},
+		{"missing entry", "branchTags[5]", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := configJSON(t, base)
+			err := unsetValue(values, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unsetValue() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(values, configJSON(t, tt.want)) {
+				t.Errorf("unsetValue() = %v, want %s", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupValueIndex(t *testing.T) {
+	values := configJSON(t, `{"branchTags":[{"branch":"main","tag":"v0.0.0"}]}`)
+	if got, ok := lookupValue(values, "branchTags[0].tag"); !ok || got != "v0.0.0" {
+		t.Errorf("lookupValue(branchTags[0].tag) = %v, %v", got, ok)
+	}
+	if _, ok := lookupValue(values, "branchTags[1].tag"); ok {
+		t.Error("lookupValue(branchTags[1].tag) found a missing entry")
+	}
+}
+
+func TestConfigTargetSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "publish.json")
+	original := `{"branchTags":[{"branch":"main","tag":"v0.0.0"}]}`
+	os.WriteFile(path, []byte(original), 0644)
+
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{"valid tag format", "branchTags[0].tag", "r0.0.0", false},
+		{"invalid tag format", "branchTags[0].tag", "v1.0", true},
+		{"wrong type", "push.followTags", "yes", true},
+		{"unknown provider", "provider.type", "svn", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &configTarget{Path: path, Values: configJSON(t, original)}
+			if err := setValue(target.Values, tt.key, parseConfigValue(tt.value)); err != nil {
+				t.Fatal(err)
+			}
+			before, _ := os.ReadFile(path)
+			err := target.save()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("save() error = %v, want error: %v", err, tt.wantErr)
+			}
+			after, _ := os.ReadFile(path)
+			if tt.wantErr && string(after) != string(before) {
+				t.Errorf("save() wrote a rejected configuration: %s", after)
+			}
+			if _, err := loadConfigFile(path); err != nil {
+				t.Errorf("publish.json is no longer valid: %v", err)
+			}
+		})
+	}
+}
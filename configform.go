@@ -0,0 +1,326 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// formField is a value of publish.json edited by the config form
+type formField struct {
+	// Key is relative to the section, e.g. tag in branchTags[0].tag
+	Key   string
+	Label string
+	// List fields are edited entry by entry
+	List bool
+	// Validate checks a new value; nil accepts anything
+	Validate func(string) error
+}
+
+var branchFields = []formField{
+	{Key: "branch", Label: "Branch", Validate: requireValue},
+	{Key: "tag", Label: "Tag format", Validate: validateFormTagFormat},
+	{Key: "paths", Label: "Paths (only tag when these change)", List: true},
+}
+
+var hookFields = []formField{
+	{Key: "hooks.preTag", Label: "Before tagging", List: true},
+	{Key: "hooks.postTag", Label: "After tagging", List: true},
+	{Key: "hooks.postPush", Label: "After pushing", List: true},
+}
+
+var integrationFields = []formField{
+	{Key: "notifications.webhook", Label: "Notification webhook URL"},
+	{Key: "notifications.command", Label: "Notification command"},
+	{Key: "provider.type", Label: "Hosting service (github, gitlab, gitea, bitbucket, azure)", Validate: validateFormProvider},
+	{Key: "provider.apiUrl", Label: "Hosting service API URL"},
+	{Key: "ticket.mode", Label: "Change ticket (off, optional, required)", Validate: validateFormTicketMode},
+	{Key: "ticket.pattern", Label: "Change ticket pattern"},
+}
+
+// configForm edits publish.json through numbered menus instead of JSON.
+// Changes stay in memory until they are saved.
+type configForm struct {
+	target  *configTarget
+	changed bool
+}
+
+// runConfigForm implements config edit --tui
+func runConfigForm() {
+	target, err := readConfigTarget(false)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := (&configForm{target: target}).run(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fmt.Println("Nothing saved.")
+		os.Exit(1)
+	}
+}
+
+// run shows the main menu until the values are saved or discarded. Saving
+// validates the whole configuration; when that fails the menu is shown again.
+func (f *configForm) run() error {
+	for {
+		branches, _ := f.target.Values["branchTags"].([]interface{})
+		choice, err := formMenu("Edit "+f.target.Path, []string{
+			fmt.Sprintf("Branches (%d configured)", len(branches)),
+			"Hooks",
+			"Integrations",
+			"Save and quit",
+			"Quit without saving",
+		})
+		if err != nil {
+			return err
+		}
+		switch choice {
+		case 0:
+			err = f.editBranches()
+		case 1:
+			err = f.editFields("Hooks", "", hookFields, "")
+		case 2:
+			err = f.editFields("Integrations", "", integrationFields, "")
+		case 3:
+			if err := f.target.save(); err != nil {
+				fmt.Printf("Not saved: %v\n", err)
+				continue
+			}
+			fmt.Printf("Saved %s\n", f.target.Path)
+			return nil
+		case 4:
+			if !f.changed || prompter.Confirm("Discard the changes?", false) {
+				fmt.Println("Nothing saved.")
+				return nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// editBranches lists the branchTags entries to edit, add or remove
+func (f *configForm) editBranches() error {
+	for {
+		branches, _ := f.target.Values["branchTags"].([]interface{})
+		var options []string
+		for _, b := range branches {
+			entry, _ := b.(map[string]interface{})
+			options = append(options, fmt.Sprintf("%v (%v)", entry["branch"], entry["tag"]))
+		}
+		choice, err := formMenu("Branches", append(options, "Add a branch", "Back"))
+		if err != nil {
+			return err
+		}
+		switch {
+		case choice == len(branches)+1:
+			return nil
+		case choice == len(branches):
+			if err := f.addBranch(len(branches)); err != nil {
+				return err
+			}
+		default:
+			key := fmt.Sprintf("branchTags[%d]", choice)
+			if err := f.editFields(options[choice], key+".", branchFields, key); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// addBranch asks for the branch and tag format of a new branchTags entry
+func (f *configForm) addBranch(index int) error {
+	branch, err := prompter.Input("Branch:", "", requireValue)
+	if err != nil {
+		return err
+	}
+	tag, err := prompter.Input("Tag format, e.g. v0.0.0:", "v0.0.0", validateFormTagFormat)
+	if err != nil {
+		return err
+	}
+	entry := map[string]interface{}{"branch": branch, "tag": tag}
+	if err := setValue(f.target.Values, fmt.Sprintf("branchTags[%d]", index), entry); err != nil {
+		return err
+	}
+	f.changed = true
+	return nil
+}
+
+// editFields shows the fields of a section, keys prefixed with prefix, until
+// Back is chosen. With a removeKey the section can be removed as a whole.
+func (f *configForm) editFields(title, prefix string, fields []formField, removeKey string) error {
+	for {
+		var options []string
+		for _, field := range fields {
+			value, _ := lookupValue(f.target.Values, prefix+field.Key)
+			options = append(options, fmt.Sprintf("%s: %s", field.Label, describeFormValue(value)))
+		}
+		if removeKey != "" {
+			options = append(options, "Remove")
+		}
+		choice, err := formMenu(title, append(options, "Back"))
+		if err != nil {
+			return err
+		}
+		switch {
+		case choice < len(fields) && fields[choice].List:
+			err = f.editList(fields[choice], prefix+fields[choice].Key)
+		case choice < len(fields):
+			err = f.editValue(fields[choice], prefix+fields[choice].Key)
+		case removeKey != "" && choice == len(fields):
+			if !prompter.Confirm(fmt.Sprintf("Remove %s?", title), false) {
+				continue
+			}
+			if err := unsetValue(f.target.Values, removeKey); err != nil {
+				return err
+			}
+			f.changed = true
+			return nil
+		default:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// editValue asks for a new value of a string field; - removes it
+func (f *configForm) editValue(field formField, key string) error {
+	current, _ := lookupValue(f.target.Values, key)
+	def, _ := current.(string)
+	input, err := prompter.Input(field.Label+" (- to remove):", def, func(s string) error {
+		if s == "-" || field.Validate == nil {
+			return nil
+		}
+		return field.Validate(s)
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case input == "-" && current != nil:
+		err = unsetValue(f.target.Values, key)
+	case input != "-" && input != def:
+		err = setValue(f.target.Values, key, input)
+	default:
+		return nil
+	}
+	f.changed = true
+	return err
+}
+
+// editList lists the entries of a list field to change, remove or add to.
+// A list left empty is removed.
+func (f *configForm) editList(field formField, key string) error {
+	for {
+		value, _ := lookupValue(f.target.Values, key)
+		entries, _ := value.([]interface{})
+		var options []string
+		for _, entry := range entries {
+			options = append(options, fmt.Sprint(entry))
+		}
+		choice, err := formMenu(field.Label, append(options, "Add", "Back"))
+		if err != nil {
+			return err
+		}
+		if choice == len(entries)+1 {
+			return nil
+		}
+
+		entryKey := fmt.Sprintf("%s[%d]", key, choice)
+		question, def := "New entry:", ""
+		if choice < len(entries) {
+			question, def = "Entry (- to remove):", options[choice]
+		}
+		input, err := prompter.Input(question, def, requireValue)
+		if err != nil {
+			return err
+		}
+		switch {
+		case input == "-" && choice < len(entries):
+			err = unsetValue(f.target.Values, entryKey)
+			if err == nil && len(entries) == 1 {
+				err = unsetValue(f.target.Values, key)
+			}
+		case input != def:
+			err = setValue(f.target.Values, entryKey, input)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		f.changed = true
+	}
+}
+
+// formMenu prints numbered options and returns the index of the chosen one.
+// Unlike Select it has no default, and it fails once no input is left.
+func formMenu(title string, options []string) (int, error) {
+	fmt.Println(title)
+	for i, option := range options {
+		fmt.Printf("%d: %s\n", i+1, option)
+	}
+	input, err := prompter.Input("Enter number:", "", func(s string) error {
+		if _, ok := parseSelection(s, len(options)); !ok || s == "" {
+			return fmt.Errorf("enter a number from 1 to %d", len(options))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	n, _ := strconv.Atoi(input)
+	return n - 1, nil
+}
+
+// describeFormValue shows a value in a menu
+func describeFormValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "(not set)"
+	case []interface{}:
+		if len(v) == 0 {
+			return "(not set)"
+		}
+		var entries []string
+		for _, entry := range v {
+			entries = append(entries, fmt.Sprint(entry))
+		}
+		return strings.Join(entries, "; ")
+	}
+	return fmt.Sprint(value)
+}
+
+func requireValue(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return errors.New("a value is required")
+	}
+	return nil
+}
+
+func validateFormTagFormat(s string) error {
+	if !validateTagFormat(s, extractPrefix(s)) {
+		return errors.New("the tag format has to be <prefix>X.Y.Z, e.g. v0.0.0")
+	}
+	return nil
+}
+
+func validateFormProvider(s string) error {
+	switch strings.ToLower(s) {
+	case providerGitHub, providerGitLab, providerGitea, providerBitbucket, providerAzure:
+		return nil
+	}
+	return fmt.Errorf("unknown hosting service %q", s)
+}
+
+func validateFormTicketMode(s string) error {
+	if problems := validateTicketConfig(TicketConfig{Mode: s}); len(problems) > 0 {
+		return errors.New(problems[0])
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestConfigForm drives the config form through scripted menu answers
+func TestConfigForm(t *testing.T) {
+	original := `{"branchTags":[{"branch":"main","tag":"v0.0.0"}],"strict":true}`
+	tests := []struct {
+		name     string
+		answers  []string
+		wantErr  bool
+		expected string
+	}{
+		{
+			name: "add a branch",
+			// Branches, Add a branch, gray, g0.0.0, Back, Save and quit
+			answers:  []string{"1", "2", "gray", "g0.0.0", "4", "4"},
+			expected: `{"branchTags":[{"branch":"main","tag":"v0.0.0"},{"branch":"gray","tag":"g0.0.0"}],"strict":true}`,
+		},
+		{
+			name: "invalid tag format asked again",
+			// Branches, main, Tag format, 1.0, r0.0.0, Back, Back, Save and quit
+			answers:  []string{"1", "1", "2", "1.0", "r0.0.0", "5", "3", "4"},
+			expected: `{"branchTags":[{"branch":"main","tag":"r0.0.0"}],"strict":true}`,
+		},
+		{
+			name: "hooks and integrations",
+			// Hooks, Before tagging, Add, make test, Back, Back, Integrations, webhook, URL, Back, Save and quit
+			answers:  []string{"2", "1", "1", "make test", "3", "4", "3", "1", "https://hooks.example.com", "7", "4"},
+			expected: `{"branchTags":[{"branch":"main","tag":"v0.0.0"}],"hooks":{"preTag":["make test"]},"notifications":{"webhook":"https://hooks.example.com"},"strict":true}`,
+		},
+		{
+			name: "removing the only branch is not saved",
+			// Branches, main, Remove, yes, Back, Save and quit fails, Quit without saving, discard
+			answers:  []string{"1", "1", "4", "y", "2", "4", "5", "y"},
+			expected: original,
+		},
+		{
+			name:     "input ends",
+			answers:  []string{"1", "2", "gray"},
+			wantErr:  true,
+			expected: original,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "publish.json")
+			os.WriteFile(path, []byte(original), 0644)
+			useScriptedPrompter(t, tt.answers...)
+
+			form := &configForm{target: &configTarget{Path: path, Values: configJSON(t, original)}}
+			var err error
+			captureOutput(func() { err = form.run() })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("run() error = %v, want error: %v", err, tt.wantErr)
+			}
+			data, _ := os.ReadFile(path)
+			if got, want := configJSON(t, string(data)), configJSON(t, tt.expected); !reflect.DeepEqual(got, want) {
+				t.Errorf("publish.json = %s, expected %s", data, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDescribeFormValue(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, "(not set)"},
+		{"v0.0.0", "v0.0.0"},
+		{[]interface{}{}, "(not set)"},
+		{[]interface{}{"make test", "make lint"}, "make test; make lint"},
+	}
+	for _, tt := range tests {
+		if got := describeFormValue(tt.value); got != tt.expected {
+			t.Errorf("describeFormValue(%v) = %q, expected %q", tt.value, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// configMigration rewrites publish.json from one schema version to the next
+type configMigration struct {
+	// Description tells the user what changed, e.g. "hooks.pre is renamed to hooks.preTag"
+	Description string
+	// Apply changes values, in the JSON shape of publish.json, in place;
+	// renamed keys keep their position
+	Apply func(values *orderedObject)
+}
+
+// configMigrations are applied in order by config migrate to files of older
+// schema versions, each raising the version by one. A change to the shape of
+// publish.json adds one here; until the file is migrated, reading it fails
+// with a hint to run config migrate. There are none yet.
+var configMigrations []configMigration
+
+// currentConfigVersion is the schema version of publish.json this build
+// reads. Files without a version key are version 1, the first schema.
+func currentConfigVersion() int {
+	return 1 + len(configMigrations)
+}
+
+// configVersion returns the schema version of values; 1 when it has none
+func configVersion(values *orderedObject) (int, error) {
+	raw, ok := values.Get("version")
+	if !ok {
+		return 1, nil
+	}
+	number, ok := raw.(json.Number)
+	version, err := number.Int64()
+	if !ok || err != nil || version < 1 {
+		return 0, fmt.Errorf("version %v is not a schema version such as %d", raw, currentConfigVersion())
+	}
+	return int(version), nil
+}
+
+// migrateConfigValues returns a copy of values migrated to the current schema
+// version, the version it had and the descriptions of the applied migrations.
+// A version newer than this build knows is an error, as its keys may mean
+// something else.
+func migrateConfigValues(values *orderedObject) (*orderedObject, int, []string, error) {
+	from, err := configVersion(values)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if from > currentConfigVersion() {
+		return nil, from, nil, fmt.Errorf("the configuration has schema version %d, but this git-publish only knows up to %d; upgrade git-publish", from, currentConfigVersion())
+	}
+
+	data, err := values.MarshalJSON()
+	if err != nil {
+		return nil, from, nil, err
+	}
+	migrated, err := parseOrderedObject(data)
+	if err != nil {
+		return nil, from, nil, err
+	}
+	var notes []string
+	// configMigrations[0] migrates version 1 to 2
+	for i := from - 1; i < len(configMigrations); i++ {
+		configMigrations[i].Apply(migrated)
+		migrated.Set("version", json.Number(strconv.Itoa(i+2)))
+		notes = append(notes, configMigrations[i].Description)
+	}
+	return migrated, from, notes, nil
+}
+
+// pendingConfigMigration is a publish.json that needs migrating
+type pendingConfigMigration struct {
+	Path  string
+	From  int
+	Notes []string
+	// Old and New are the values before and after, indented as they are
+	// written, with the keys in the order of the file
+	Old, New []byte
+}
+
+// planConfigMigration parses the content of publish.json at path and returns
+// its migration, or nil when it is up to date
+func planConfigMigration(path string, data []byte) (*pendingConfigMigration, error) {
+	values, err := parseOrderedObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	migrated, from, notes, err := migrateConfigValues(values)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(notes) == 0 {
+		return nil, nil
+	}
+	// The old side is indented like the new one, so the diff only shows what the migration changed
+	old, err := formatOrderedObject(values)
+	if err != nil {
+		return nil, err
+	}
+	migratedData, err := formatOrderedObject(migrated)
+	if err != nil {
+		return nil, err
+	}
+	return &pendingConfigMigration{Path: path, From: from, Notes: notes, Old: old, New: migratedData}, nil
+}
+
+// backupPath is where the file is kept as it was before the migration
+func (m *pendingConfigMigration) backupPath() string {
+	return fmt.Sprintf("%s.v%d.bak", m.Path, m.From)
+}
+
+// describe prints what the migration changes, with a side-by-side diff
+func (m *pendingConfigMigration) describe() {
+	fmt.Printf("%s: schema version %d → %d\n", m.Path, m.From, currentConfigVersion())
+	for _, note := range m.Notes {
+		fmt.Printf("  - %s\n", note)
+	}
+	fmt.Println()
+	fmt.Print(sideBySideDiff(string(m.Old), string(m.New), 38))
+}
+
+// apply keeps a backup of the file and writes the migrated values
+func (m *pendingConfigMigration) apply(original []byte) error {
+	if err := os.WriteFile(m.backupPath(), original, 0644); err != nil {
+		return fmt.Errorf("backing up %s: %v", m.Path, err)
+	}
+	if err := os.WriteFile(m.Path, append(m.New, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing %s: %v", m.Path, err)
+	}
+	return nil
+}
+
+// checkConfigVersion refuses a publish.json of another schema version than
+// this build reads. Reading never migrates or rewrites the file: a newer
+// version may give keys another meaning, and an older one is brought up to
+// date by config migrate, which keeps a backup and shows the changes.
+func checkConfigVersion(path string, data []byte) error {
+	values, err := parseOrderedObject(data)
+	if err != nil {
+		// Reported by the parser of the caller
+		return nil
+	}
+	version, err := configVersion(values)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	switch {
+	case version > currentConfigVersion():
+		return fmt.Errorf("%s has schema version %d, but this git-publish only knows up to %d; upgrade git-publish", path, version, currentConfigVersion())
+	case version < currentConfigVersion():
+		return fmt.Errorf("%s has schema version %d; run git-publish config migrate to update it to %d", path, version, currentConfigVersion())
+	}
+	return nil
+}
+
+// runConfigMigrate implements config migrate. With --check it only reports
+// whether publish.json needs migrating and exits 1 if it does, for CI.
+func runConfigMigrate(args []string) {
+	fs := flag.NewFlagSet("config migrate", flag.ExitOnError)
+	check := fs.Bool("check", false, "only show the migration and exit 1 if publish.json needs one")
+	fs.Parse(args)
+
+	path, err := repositoryConfigPath()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		fmt.Printf("%s does not exist; nothing to migrate\n", path)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	m, err := planConfigMigration(path, data)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if m == nil {
+		fmt.Printf("%s is up to date (schema version %d)\n", path, currentConfigVersion())
+		return
+	}
+	m.describe()
+	if *check {
+		fmt.Printf("%s needs migrating; run git-publish config migrate\n", path)
+		os.Exit(1)
+	}
+	if err := m.apply(data); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Migrated %s; the previous file is kept as %s\n", path, m.backupPath())
+}
+
+// diffContextLines are the unchanged lines shown around a change
+const diffContextLines = 2
+
+// sideBySideDiff renders the lines of old and new next to each other, each
+// column width characters wide, like diff -y: | marks a changed line, < a
+// removed and > an added one. Unchanged stretches are cut down to the lines
+// around the changes.
+func sideBySideDiff(old, new string, width int) string {
+	a := strings.Split(strings.TrimRight(old, "\n"), "\n")
+	b := strings.Split(strings.TrimRight(new, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type row struct {
+		left, right string
+		mark        byte
+	}
+	var rows []row
+	var removed, added []string
+	flush := func() {
+		for k := 0; k < len(removed) || k < len(added); k++ {
+			switch {
+			case k < len(removed) && k < len(added):
+				rows = append(rows, row{removed[k], added[k], '|'})
+			case k < len(removed):
+				rows = append(rows, row{removed[k], "", '<'})
+			default:
+				rows = append(rows, row{"", added[k], '>'})
+			}
+		}
+		removed, added = nil, nil
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			rows = append(rows, row{a[i], b[j], ' '})
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, a[i])
+			i++
+		default:
+			added = append(added, b[j])
+			j++
+		}
+	}
+	flush()
+
+	show := make([]bool, len(rows))
+	changed := false
+	for n, r := range rows {
+		if r.mark == ' ' {
+			continue
+		}
+		changed = true
+		for k := max(0, n-diffContextLines); k <= min(len(rows)-1, n+diffContextLines); k++ {
+			show[k] = true
+		}
+	}
+	if !changed {
+		return ""
+	}
+	var out strings.Builder
+	skipped := false
+	for n, r := range rows {
+		if !show[n] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			fmt.Fprintf(&out, "%-*s   ...\n", width, "...")
+			skipped = false
+		}
+		line := fmt.Sprintf("%-*s %c %s", width, truncateColumn(r.left, width), r.mark, truncateColumn(r.right, width))
+		out.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	if skipped {
+		fmt.Fprintf(&out, "%-*s   ...\n", width, "...")
+	}
+	return out.String()
+}
+
+// truncateColumn cuts s to width characters, ending in … when it is longer
+func truncateColumn(s string, width int) string {
+	r := []rune(s)
+	if len(r) <= width {
+		return s
+	}
+	return string(r[:width-1]) + "…"
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useConfigMigrations replaces the migrations for the duration of a test
+func useConfigMigrations(t *testing.T, migrations ...configMigration) {
+	t.Helper()
+	original := configMigrations
+	configMigrations = migrations
+	t.Cleanup(func() { configMigrations = original })
+}
+
+// testConfigMigrations rename hooks.pre to hooks.preTag (version 2) and turn
+// a string verify into a list (version 3)
+var testConfigMigrations = []configMigration{
+	{Description: "hooks.pre is renamed to hooks.preTag", Apply: func(values *orderedObject) {
+		if hooks, ok := values.Get("hooks"); ok {
+			if hooks, ok := hooks.(*orderedObject); ok {
+				hooks.Rename("pre", "preTag")
+			}
+		}
+	}},
+	{Description: "verify is a list of commands", Apply: func(values *orderedObject) {
+		if verify, ok := values.Get("verify"); ok {
+			if verify, ok := verify.(string); ok {
+				values.Set("verify", []interface{}{verify})
+			}
+		}
+	}},
+}
+
+// orderedJSON parses a JSON object for a test
+func orderedJSON(t *testing.T, s string) *orderedObject {
+	t.Helper()
+	object, err := parseOrderedObject([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return object
+}
+
+func TestConfigVersion(t *testing.T) {
+	tests := []struct {
+		config  string
+		want    int
+		wantErr bool
+	}{
+		{`{}`, 1, false},
+		{`{"version": 2}`, 2, false},
+		{`{"version": 0}`, 0, true},
+		{`{"version": 1.5}`, 0, true},
+		{`{"version": "2"}`, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := configVersion(orderedJSON(t, tt.config))
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("configVersion(%s) = %d, %v, want %d (error: %v)", tt.config, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMigrateConfigValues(t *testing.T) {
+	useConfigMigrations(t, testConfigMigrations...)
+
+	tests := []struct {
+		name      string
+		config    string
+		want      string
+		wantFrom  int
+		wantNotes int
+		wantErr   bool
+	}{
+		{"unversioned", `{"hooks": {"pre": ["make"]}, "verify": "go test ./..."}`, `{"hooks": {"preTag": ["make"]}, "verify": ["go test ./..."], "version": 3}`, 1, 2, false},
+		{"one step behind", `{"version": 2, "verify": "make check"}`, `{"version": 3, "verify": ["make check"]}`, 2, 1, false},
+		{"current", `{"version": 3, "verify": ["make check"]}`, `{"version": 3, "verify": ["make check"]}`, 3, 0, false},
+		{"newer", `{"version": 4}`, ``, 4, 0, true},
+	}
+	for _, tt := range tests {
+		values := orderedJSON(t, tt.config)
+		got, from, notes, err := migrateConfigValues(values)
+		if (err != nil) != tt.wantErr || from != tt.wantFrom || len(notes) != tt.wantNotes {
+			t.Errorf("%s: migrateConfigValues() from %d with %q, %v, want from %d with %d notes (error: %v)", tt.name, from, notes, err, tt.wantFrom, tt.wantNotes, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		gotJSON, _ := json.Marshal(got)
+		if !reflect.DeepEqual(configJSON(t, string(gotJSON)), configJSON(t, tt.want)) {
+			t.Errorf("%s: migrateConfigValues() = %s, want %s", tt.name, gotJSON, tt.want)
+		}
+		if !reflect.DeepEqual(values, orderedJSON(t, tt.config)) {
+			t.Errorf("%s: migrateConfigValues() changed its input to %v", tt.name, values)
+		}
+	}
+}
+
+func TestSideBySideDiff(t *testing.T) {
+	old := "{\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 3,\n  \"d\": 4,\n  \"e\": 5,\n  \"f\": 6\n}"
+	new := "{\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 3,\n  \"d\": 4,\n  \"e\": 50,\n  \"f\": 6,\n  \"g\": 7\n}"
+	// The columns are 12 characters wide
+	want := "...            ...\n" +
+		"  \"c\": 3,        \"c\": 3,\n" +
+		"  \"d\": 4,        \"d\": 4,\n" +
+		"  \"e\": 5,    |   \"e\": 50,\n" +
+		"  \"f\": 6     |   \"f\": 6,\n" +
+		"             >   \"g\": 7\n" +
+		"}              }\n"
+	got := sideBySideDiff(old, new, 12)
+	if got != want {
+		t.Errorf("sideBySideDiff() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := sideBySideDiff("same\n", "same\n", 12); got != "" {
+		t.Errorf("sideBySideDiff() of equal text = %q, want nothing", got)
+	}
+	if got := sideBySideDiff("a very long line", "short", 8); got != "a very … | short\n" {
+		t.Errorf("sideBySideDiff() of a long line = %q", got)
+	}
+}
+
+func TestCheckConfigVersion(t *testing.T) {
+	useConfigMigrations(t, testConfigMigrations[0])
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"older", `{"branchTags": [{"branch": "main", "tag": "v0.0.0"}], "hooks": {"pre": ["make"]}}`, "run git-publish config migrate to update it to 2"},
+		{"current", `{"version": 2, "branchTags": [{"branch": "main", "tag": "v0.0.0"}]}`, ""},
+		{"newer", `{"version": 3, "branchTags": [{"branch": "main", "tag": "v0.0.0"}]}`, "upgrade git-publish"},
+		{"invalid version", `{"version": "2"}`, "not a schema version"},
+		{"unparsable", `{"version": `, ""},
+	}
+	for _, tt := range tests {
+		err := checkConfigVersion("publish.json", []byte(tt.data))
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: checkConfigVersion() = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// TestReadRepositoryLayerLeavesOlderSchema tests that reading publish.json of
+// an older schema neither migrates nor rewrites it
+func TestReadRepositoryLayerLeavesOlderSchema(t *testing.T) {
+	useConfigMigrations(t, testConfigMigrations[0])
+	g := newFakeGitClient("c1")
+	g.root = t.TempDir()
+	useFakeGit(t, g)
+	path := filepath.Join(g.root, "publish.json")
+	original := []byte(`{"branchTags": [{"branch": "main", "tag": "v0.0.0"}], "hooks": {"pre": ["make"]}}`)
+	os.WriteFile(path, original, 0644)
+
+	_, err := readRepositoryLayer()
+	if err == nil || !strings.Contains(err.Error(), "config migrate") {
+		t.Errorf("readRepositoryLayer() = %v, want the config migrate hint", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(original) {
+		t.Errorf("reading rewrote publish.json to %s", data)
+	}
+	if _, err := os.Stat(path + ".v1.bak"); err == nil {
+		t.Error("reading left a backup of publish.json")
+	}
+}
+
+func TestPlanConfigMigrationKeepsKeyOrder(t *testing.T) {
+	useConfigMigrations(t, testConfigMigrations...)
+	data := []byte(`{
+  "verify": "go test ./... && go vet ./...",
+  "hooks": {"post": ["notify"], "pre": ["make"], "failure": ["page"]},
+  "branchTags": [{"tag": "v0.0.0", "branch": "main"}]
+}`)
+	m, err := planConfigMigration("publish.json", data)
+	if err != nil || m == nil {
+		t.Fatalf("planConfigMigration() = %v, %v, want a migration", m, err)
+	}
+	want := `{
+  "verify": [
+    "go test ./... && go vet ./..."
+  ],
+  "hooks": {
+    "post": [
+      "notify"
+    ],
+    "preTag": [
+      "make"
+    ],
+    "failure": [
+      "page"
+    ]
+  },
+  "branchTags": [
+    {
+      "tag": "v0.0.0",
+      "branch": "main"
+    }
+  ],
+  "version": 3
+}`
+	if string(m.New) != want {
+		t.Errorf("planConfigMigration() wrote\n%s\nwant\n%s", m.New, want)
+	}
+	// The old side only differs in what the migration changed
+	if !strings.HasPrefix(string(m.Old), "{\n  \"verify\": \"go test ./... && go vet ./...\",\n  \"hooks\": {\n    \"post\"") {
+		t.Errorf("planConfigMigration() old side =\n%s", m.Old)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// DependenciesConfig is the dependency check run before tagging
+type DependenciesConfig struct {
+	// Check enables the check
+	Check bool `json:"check,omitempty"`
+	// Outdated lists outdated dependencies, by default with the tool of the ecosystem
+	Outdated string `json:"outdated,omitempty"`
+	// Audit fails when dependencies have known security advisories, by default
+	// govulncheck, npm audit or pip-audit
+	Audit string `json:"audit,omitempty"`
+}
+
+// dependencyTools are the default commands of an ecosystem, found by its manifest
+type dependencyTools struct {
+	Manifests []string
+	Outdated  string
+	Audit     string
+}
+
+var ecosystemTools = []dependencyTools{
+	{
+		Manifests: []string{"go.mod"},
+		Outdated:  `go list -m -u -f '{{if and .Update (not .Indirect) (not .Main)}}{{.Path}} {{.Version}} -> {{.Update.Version}}{{end}}' all`,
+		Audit:     "govulncheck ./...",
+	},
+	{
+		Manifests: []string{"package.json"},
+		Outdated:  "npm outdated",
+		Audit:     "npm audit --omit=dev",
+	},
+	{
+		Manifests: []string{"requirements.txt", "pyproject.toml"},
+		Outdated:  "pip list --outdated",
+		Audit:     "pip-audit",
+	},
+}
+
+// dependencyCommands returns the outdated and audit commands: the configured
+// ones, or those of the first ecosystem whose manifest is in the root directory
+// of the work tree at root
+func dependencyCommands(config DependenciesConfig, root string) (string, string) {
+	outdated, audit := config.Outdated, config.Audit
+	for _, tools := range ecosystemTools {
+		for _, manifest := range tools.Manifests {
+			if _, err := os.Stat(filepath.Join(root, manifest)); err != nil {
+				continue
+			}
+			if outdated == "" {
+				outdated = tools.Outdated
+			}
+			if audit == "" {
+				audit = tools.Audit
+			}
+			return outdated, audit
+		}
+	}
+	return outdated, audit
+}
+
+// checkDependencies lists outdated dependencies of the tip of branch and audits
+// them for known security advisories. The tools run in a temporary work tree
+// of that commit, so they check what is released rather than the working
+// directory. Outdated dependencies are only shown; advisories have to be
+// acknowledged, otherwise an error is returned. Missing tools are skipped with
+// a warning.
+func checkDependencies(config DependenciesConfig, branch string) error {
+	if !config.Check {
+		return nil
+	}
+	commit, err := lookupCommit(branch)
+	if err != nil {
+		return fmt.Errorf("branch %s not found: %v", branch, err)
+	}
+	return inWorktree(commit, func(dir string) error {
+		return checkDependenciesIn(config, dir)
+	})
+}
+
+// checkDependenciesIn runs the dependency check in the work tree at dir
+func checkDependenciesIn(config DependenciesConfig, dir string) error {
+	outdated, audit := dependencyCommands(config, dir)
+	if outdated == "" && audit == "" {
+		fmt.Println("Warning: dependencies.check is set, but no go.mod, package.json or Python requirements were found; set dependencies.outdated or dependencies.audit")
+		return nil
+	}
+
+	if outdated != "" {
+		// Most tools exit non-zero when something is outdated, which is no failure here
+		output, err := runDependencyCommand(outdated, dir)
+		switch {
+		case errors.Is(err, errToolMissing):
+			fmt.Printf("Warning: %v\n", err)
+		case output != "":
+			fmt.Println("Outdated dependencies:")
+			printIndented(output)
+		}
+	}
+	if audit == "" {
+		return nil
+	}
+
+	output, err := runDependencyCommand(audit, dir)
+	switch {
+	case err == nil:
+		fmt.Println("No known security advisories for the dependencies.")
+		return nil
+	case errors.Is(err, errToolMissing):
+		fmt.Printf("Warning: %v\n", err)
+		return nil
+	}
+	red := color.New(color.FgRed, color.Bold).SprintFunc()
+	fmt.Println(red("WARNING: the dependencies have known security advisories:"))
+	printIndented(output)
+	if !prompter.Confirm("Release with these advisories anyway?", false) {
+		return fmt.Errorf("release stopped: the dependencies have known security advisories (%s)", audit)
+	}
+	return nil
+}
+
+// errToolMissing is returned when the shell cannot find a dependency tool
+var errToolMissing = errors.New("dependency tool not found")
+
+// runDependencyCommand runs command through the shell in dir and returns its output
+func runDependencyCommand(command, dir string) (string, error) {
+	cmd := execCommand("sh", "-c", command)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 127 {
+		return "", fmt.Errorf("%w: %s; skipping it", errToolMissing, strings.Fields(command)[0])
+	}
+	return strings.TrimSpace(string(output)), err
+}
+
+// printIndented prints the non-empty lines of output indented
+func printIndented(output string) {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) != "" {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDependencyCommands(t *testing.T) {
+	dir := t.TempDir()
+
+	outdated, audit := dependencyCommands(DependenciesConfig{}, dir)
+	if outdated != "" || audit != "" {
+		t.Errorf("dependencyCommands() without a manifest = %q, %q", outdated, audit)
+	}
+	os.WriteFile(filepath.Join(dir, "package.json"), []byte("{}"), 0644)
+	if outdated, audit := dependencyCommands(DependenciesConfig{}, dir); outdated != "npm outdated" || audit != "npm audit --omit=dev" {
+		t.Errorf("dependencyCommands() = %q, %q, want the npm commands", outdated, audit)
+	}
+	if _, audit := dependencyCommands(DependenciesConfig{Audit: "snyk test"}, dir); audit != "snyk test" {
+		t.Errorf("configured audit = %q, want snyk test", audit)
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	originalExecCommand := execCommand
+	defer func() { execCommand = originalExecCommand }()
+
+	tests := []struct {
+		name    string
+		exits   map[string]string
+		answers []string
+		wantErr bool
+		want    string
+	}{
+		{"clean", map[string]string{"outdated": "exit 0", "audit": "exit 0"}, nil, false, "No known security advisories"},
+		{"outdated only", map[string]string{"outdated": "echo 'left-pad 1.0.0 -> 1.3.0'; exit 1", "audit": "exit 0"}, nil, false, "left-pad 1.0.0 -> 1.3.0"},
+		{"advisory acknowledged", map[string]string{"outdated": "exit 0", "audit": "echo GO-2024-0001; exit 3"}, []string{"y"}, false, "GO-2024-0001"},
+		{"advisory refused", map[string]string{"outdated": "exit 0", "audit": "echo GO-2024-0001; exit 3"}, []string{"n"}, true, "GO-2024-0001"},
+		{"tool missing", map[string]string{"outdated": "exit 0", "audit": "exit 127"}, nil, false, "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran []string
+			execCommand = func(command string, args ...string) *exec.Cmd {
+				script := args[len(args)-1]
+				ran = append(ran, script)
+				return exec.Command("sh", "-c", tt.exits[script])
+			}
+			useScriptedPrompter(t, tt.answers...)
+			g := newFakeGitClient("c1")
+			useFakeGit(t, g)
+
+			var err error
+			output := captureOutput(func() {
+				err = checkDependencies(DependenciesConfig{Check: true, Outdated: "outdated", Audit: "audit"}, "main")
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkDependencies() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !strings.Contains(output, tt.want) {
+				t.Errorf("output %q lacks %q", output, tt.want)
+			}
+			if !reflect.DeepEqual(ran, []string{"outdated", "audit"}) {
+				t.Errorf("ran %q, want the outdated and audit commands", ran)
+			}
+			if len(g.worktrees) != 0 {
+				t.Errorf("work trees left behind: %v", g.worktrees)
+			}
+		})
+	}
+
+	execCommand = func(command string, args ...string) *exec.Cmd {
+		t.Errorf("ran %s without dependencies.check", args)
+		return exec.Command("true")
+	}
+	if err := checkDependencies(DependenciesConfig{Outdated: "outdated"}, "main"); err != nil {
+		t.Errorf("checkDependencies() when off = %v", err)
+	}
+}
+
+// TestCheckDependenciesAtBranchTip tests that the tools run in the root of the
+// released commit, not in the working directory
+func TestCheckDependenciesAtBranchTip(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	gitInTestRepo(t, dir, "checkout", "-q", "-b", "release")
+	os.WriteFile(filepath.Join(dir, "package.json"), []byte("{}\n"), 0644)
+	gitInTestRepo(t, dir, "add", "package.json")
+	gitInTestRepo(t, dir, "commit", "-q", "-m", "Add package.json")
+	gitInTestRepo(t, dir, "checkout", "-q", "main")
+	os.WriteFile(filepath.Join(dir, "dirty.txt"), []byte("wip\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "cmd"), 0755)
+	os.Chdir(filepath.Join(dir, "cmd"))
+	release, err := lookupCommit("release")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DependenciesConfig{
+		Check:    true,
+		Outdated: "test -f package.json && echo package.json found",
+		Audit:    `test ! -f dirty.txt && test "$(git rev-parse HEAD)" = ` + release,
+	}
+	tests := []struct {
+		branch  string
+		wantErr bool
+		want    string
+	}{
+		{"release", false, "package.json found"},
+		{"main", true, "known security advisories"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			useScriptedPrompter(t, "n")
+			var err error
+			output := captureOutput(func() { err = checkDependencies(config, tt.branch) })
+			if (err != nil) != tt.wantErr || !strings.Contains(output, tt.want) {
+				t.Errorf("checkDependencies(%s) = %v, want error %v and %q in\n%s", tt.branch, err, tt.wantErr, tt.want, output)
+			}
+			cmd := exec.Command("git", "worktree", "list", "--porcelain")
+			cmd.Dir = dir
+			if list, _ := cmd.Output(); strings.Count(string(list), "worktree ") != 1 {
+				t.Errorf("work trees left behind:\n%s", list)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Bases of the next tag, see BranchTagConfig.Base
+const (
+	baseTags     = "tags"
+	baseDeployed = "deployed"
+)
+
+// DeployedConfig is where the version deployed from a branch is read
+type DeployedConfig struct {
+	// URL is fetched with GET; its body is the version, or holds it at Field
+	URL string `json:"url,omitempty"`
+	// File is read like the body of URL, e.g. a deployment manifest in the repository
+	File string `json:"file,omitempty"`
+	// Field is the dot-separated path of the version in a JSON response, e.g. build.version
+	Field string `json:"field,omitempty"`
+	// Kubernetes reads the version from an annotation of a cluster resource
+	Kubernetes KubernetesSource `json:"kubernetes,omitempty"`
+}
+
+// KubernetesSource is a resource annotation read with kubectl
+type KubernetesSource struct {
+	// Kubeconfig and Context select the cluster; kubectl's defaults apply when empty
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	Context    string `json:"context,omitempty"`
+	Namespace  string `json:"namespace,omitempty"`
+	// Resource is the kind and name, e.g. deployment/web
+	Resource   string `json:"resource,omitempty"`
+	Annotation string `json:"annotation,omitempty"`
+}
+
+func (c DeployedConfig) enabled() bool {
+	return c.URL != "" || c.File != "" || c.Kubernetes.Resource != ""
+}
+
+// validateDeployedConfig returns the problems of the deployment source of a
+// branch and of the base of its tags
+func validateDeployedConfig(bt BranchTagConfig) []string {
+	var problems []string
+	config, branch := bt.Deployed, bt.Branch
+	k := config.Kubernetes
+	sources := 0
+	for _, set := range []bool{config.URL != "", config.File != "", k != (KubernetesSource{})} {
+		if set {
+			sources++
+		}
+	}
+	switch {
+	case sources > 1:
+		problems = append(problems, fmt.Sprintf("deployed of branch %s has more than one of url, file and kubernetes", branch))
+	case k != (KubernetesSource{}) && (k.Resource == "" || k.Annotation == ""):
+		problems = append(problems, fmt.Sprintf("deployed.kubernetes of branch %s needs a resource and an annotation", branch))
+	case config.Field != "" && config.URL == "" && config.File == "":
+		problems = append(problems, fmt.Sprintf("deployed.field of branch %s needs a url or a file", branch))
+	}
+
+	switch bt.Base {
+	case "", baseTags:
+	case baseDeployed:
+		if !config.enabled() {
+			problems = append(problems, fmt.Sprintf("base deployed of branch %s needs a deployed source", branch))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("base %q of branch %s is not tags or deployed", bt.Base, branch))
+	}
+	return problems
+}
+
+// deploymentStatus compares the deployed version of a branch with its tags
+type deploymentStatus struct {
+	Branch string `json:"branch"`
+	Format string `json:"format"`
+	// Deployed is the tag of the deployed version
+	Deployed  string `json:"deployed,omitempty"`
+	LatestTag string `json:"latestTag,omitempty"`
+	// Undeployed are the tags on the branch above the deployed version, lowest first
+	Undeployed []string `json:"undeployed"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// runDeployedCommand implements the deployed command: it shows, for every
+// branch with a deployment source, the deployed version and the tags that are
+// not deployed yet
+func runDeployedCommand(args []string) {
+	fs := flag.NewFlagSet("deployed", flag.ExitOnError)
+	branch := fs.String("branch", "", "only show this branch")
+	output := fs.String("output", "text", "output format: text or json")
+	noFetch := fs.Bool("no-fetch", false, "use the local tags without fetching them from origin first (always with json)")
+	fs.Parse(args)
+
+	if *output != "text" && *output != "json" {
+		fmt.Printf("Error: Unknown output format '%s'\n", *output)
+		os.Exit(1)
+	}
+	config := readConfig()
+	var targets []BranchTagConfig
+	for _, bt := range config.BranchTags {
+		if bt.Deployed.enabled() && (*branch == "" || bt.Branch == *branch) {
+			targets = append(targets, bt)
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Println("No branch has a deployment source; set deployed on its branchTags entry (see the readme).")
+		os.Exit(1)
+	}
+	// Fetching prints progress, which would corrupt the JSON output
+	if _, ok := getAllRemoteURLs()["origin"]; ok && !*noFetch && *output == "text" {
+		fetchRemote(config.Tags)
+	}
+
+	statuses := []deploymentStatus{}
+	for _, bt := range targets {
+		statuses = append(statuses, checkDeployment(config, bt))
+	}
+	if *output == "json" {
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Print(formatDeployments(statuses))
+}
+
+// checkDeployment reads the deployed version of bt and lists its tags above it
+func checkDeployment(config Config, bt BranchTagConfig) deploymentStatus {
+	status := deploymentStatus{Branch: bt.Branch, Format: bt.Tag, Undeployed: []string{}}
+	tags, err := matchTags(config, versionRange{{}}, bt.Branch)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	var own []matchedTag
+	for _, t := range tags {
+		if t.Format == bt.Tag {
+			own = append(own, t)
+		}
+	}
+	if len(own) > 0 {
+		status.LatestTag = own[len(own)-1].Tag
+	}
+
+	deployed, err := deployedTag(bt)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Deployed = deployed
+	version, _ := parseSemver(strings.TrimPrefix(deployed, extractPrefix(bt.Tag)))
+	for _, t := range own {
+		if t.semver.compare(version) > 0 {
+			status.Undeployed = append(status.Undeployed, t.Tag)
+		}
+	}
+	return status
+}
+
+// deployedTag reads the deployed version of bt and returns it in its tag format
+func deployedTag(bt BranchTagConfig) (string, error) {
+	value, err := deployedVersion(bt.Deployed)
+	if err != nil {
+		return "", err
+	}
+	prefix := extractPrefix(bt.Tag)
+	version, ok := parseSemver(strings.TrimPrefix(strings.TrimPrefix(value, prefix), "v"))
+	if !ok {
+		return "", fmt.Errorf("deployed version %q is not X.Y.Z", value)
+	}
+	return prefix + version.String(), nil
+}
+
+// releasedTag returns the tag the next tag of bt follows: the last tag on its
+// branch other than except or, with base deployed, the tag of the deployed
+// version. That tag has to exist, as commits are listed and compared from it.
+func releasedTag(config Config, bt BranchTagConfig, except string) (string, error) {
+	if bt.Base != baseDeployed {
+		return getLastTagExcept(bt.Branch, bt.Tag, config.Tags.OnlyMarked, except), nil
+	}
+	tag, err := deployedTag(bt)
+	if err != nil {
+		return "", fmt.Errorf("reading the deployed version of %s: %v", bt.Branch, err)
+	}
+	if !tagExists(tag) {
+		return "", fmt.Errorf("the deployed version of %s has no tag %s; fetch the tags or fix the deployed source", bt.Branch, tag)
+	}
+	return tag, nil
+}
+
+// deployedVersion reads the version from the deployment source
+func deployedVersion(config DeployedConfig) (string, error) {
+	switch {
+	case config.URL != "":
+		resp, data, err := newAPIClient("", nil).send("GET", config.URL, nil)
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode >= 300 {
+			return "", fmt.Errorf("GET %s: %s", config.URL, resp.Status)
+		}
+		return versionFromDocument(data, config.Field)
+	case config.File != "":
+		data, err := os.ReadFile(config.File)
+		if err != nil {
+			return "", err
+		}
+		return versionFromDocument(data, config.Field)
+	}
+
+	k := config.Kubernetes
+	args := []string{"get", k.Resource, "--output", "json"}
+	if k.Kubeconfig != "" {
+		args = append(args, "--kubeconfig", k.Kubeconfig)
+	}
+	if k.Context != "" {
+		args = append(args, "--context", k.Context)
+	}
+	if k.Namespace != "" {
+		args = append(args, "--namespace", k.Namespace)
+	}
+	cmd := execCommand("kubectl", args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("kubectl get %s: %v %s", k.Resource, err, strings.TrimSpace(stderr.String()))
+	}
+	var resource struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(output, &resource); err != nil {
+		return "", fmt.Errorf("kubectl get %s: %v", k.Resource, err)
+	}
+	version, ok := resource.Metadata.Annotations[k.Annotation]
+	if !ok {
+		return "", fmt.Errorf("%s has no annotation %s", k.Resource, k.Annotation)
+	}
+	return version, nil
+}
+
+// versionFromDocument returns the version held by a response or file: the
+// value at field of a JSON document, or else a bare JSON string, unquoted, or
+// the plain text
+func versionFromDocument(data []byte, field string) (string, error) {
+	if field != "" {
+		return jsonField(data, field)
+	}
+	var version string
+	if json.Unmarshal(data, &version) == nil {
+		return version, nil
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// jsonField returns the string or number at the dot-separated path of a JSON document
+func jsonField(data []byte, path string) (string, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", fmt.Errorf("response is not JSON: %v", err)
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("response has no field %s", path)
+		}
+		if value, ok = object[key]; !ok {
+			return "", fmt.Errorf("response has no field %s", path)
+		}
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("field %s of the response is not a string", path)
+}
+
+// formatDeployments renders the deployment of every branch, highlighting undeployed tags
+func formatDeployments(statuses []deploymentStatus) string {
+	yellow := color.New(color.FgYellow).SprintFunc()
+	green := color.New(color.FgGreen).SprintFunc()
+	var b strings.Builder
+	for _, s := range statuses {
+		switch {
+		case s.Error != "":
+			fmt.Fprintf(&b, "%s (%s): %s\n", s.Branch, s.Format, yellow("unknown: "+s.Error))
+		case len(s.Undeployed) > 0:
+			fmt.Fprintf(&b, "%s (%s): deployed %s, latest tag %s\n", s.Branch, s.Format, s.Deployed, s.LatestTag)
+			fmt.Fprintf(&b, "  %s %s\n", yellow("Not deployed:"), yellow(strings.Join(s.Undeployed, ", ")))
+		default:
+			fmt.Fprintf(&b, "%s (%s): deployed %s, %s\n", s.Branch, s.Format, s.Deployed, green("up to date"))
+		}
+	}
+	return b.String()
+}
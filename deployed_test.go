@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDeployedVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/text":
+			fmt.Fprint(w, "1.8.3\n")
+		case "/string":
+			fmt.Fprint(w, `"v1.8.3"`)
+		case "/json":
+			fmt.Fprint(w, `{"build":{"version":"1.8.3","commit":"abc"}}`)
+		default:
+			http.Error(w, "no such page", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	manifest := filepath.Join(t.TempDir(), "prod.json")
+	os.WriteFile(manifest, []byte(`{"image":{"tag":"1.8.1"}}`), 0644)
+
+	tests := []struct {
+		name    string
+		config  DeployedConfig
+		want    string
+		wantErr bool
+	}{
+		{"plain text", DeployedConfig{URL: server.URL + "/text"}, "1.8.3", false},
+		{"JSON string", DeployedConfig{URL: server.URL + "/string"}, "v1.8.3", false},
+		{"JSON field", DeployedConfig{URL: server.URL + "/json", Field: "build.version"}, "1.8.3", false},
+		{"missing field", DeployedConfig{URL: server.URL + "/json", Field: "version"}, "", true},
+		{"error status", DeployedConfig{URL: server.URL + "/missing"}, "", true},
+		{"file", DeployedConfig{File: manifest, Field: "image.tag"}, "1.8.1", false},
+		{"missing file", DeployedConfig{File: manifest + ".missing"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deployedVersion(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deployedVersion() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("deployedVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeployedVersionKubernetes(t *testing.T) {
+	originalExecCommand := execCommand
+	defer func() { execCommand = originalExecCommand }()
+	var called []string
+	execCommand = func(command string, args ...string) *exec.Cmd {
+		called = append([]string{command}, args...)
+		return exec.Command("printf", "%s", `{"metadata":{"annotations":{"example.com/version":"1.8.2"}}}`)
+	}
+
+	k := KubernetesSource{Context: "prod", Namespace: "web", Resource: "deployment/web", Annotation: "example.com/version"}
+	got, err := deployedVersion(DeployedConfig{Kubernetes: k})
+	if err != nil || got != "1.8.2" {
+		t.Errorf("deployedVersion() = %q, %v, want 1.8.2", got, err)
+	}
+	want := []string{"kubectl", "get", "deployment/web", "--output", "json", "--context", "prod", "--namespace", "web"}
+	if !reflect.DeepEqual(called, want) {
+		t.Errorf("ran %q, want %q", called, want)
+	}
+
+	k.Annotation = "example.com/other"
+	if _, err := deployedVersion(DeployedConfig{Kubernetes: k}); err == nil {
+		t.Error("deployedVersion() with a missing annotation succeeded")
+	}
+}
+
+func TestCheckDeployment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Query().Get("version"))
+	}))
+	defer server.Close()
+
+	g := newFakeGitClient("a", "b", "c")
+	g.tags = map[string]string{"v1.8.2": "a", "v1.8.3": "b", "v1.8.4": "c"}
+	useFakeGit(t, g)
+
+	tests := []struct {
+		deployed string
+		want     deploymentStatus
+	}{
+		{"1.8.2", deploymentStatus{Branch: "main", Format: "v0.0.0", Deployed: "v1.8.2", LatestTag: "v1.8.4", Undeployed: []string{"v1.8.3", "v1.8.4"}}},
+		{"v1.8.4", deploymentStatus{Branch: "main", Format: "v0.0.0", Deployed: "v1.8.4", LatestTag: "v1.8.4", Undeployed: []string{}}},
+		{"latest", deploymentStatus{Branch: "main", Format: "v0.0.0", LatestTag: "v1.8.4", Undeployed: []string{}, Error: `deployed version "latest" is not X.Y.Z`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.deployed, func(t *testing.T) {
+			bt := BranchTagConfig{Branch: "main", Tag: "v0.0.0", Deployed: DeployedConfig{URL: server.URL + "/?version=" + tt.deployed}}
+			got := checkDeployment(Config{BranchTags: []BranchTagConfig{bt}}, bt)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkDeployment() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDeployedConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config DeployedConfig
+		base   string
+		want   int
+	}{
+		{"none", DeployedConfig{}, "", 0},
+		{"url", DeployedConfig{URL: "https://example.com/version", Field: "version"}, "", 0},
+		{"file", DeployedConfig{File: "deploy/prod.json", Field: "image.tag"}, "deployed", 0},
+		{"kubernetes", DeployedConfig{Kubernetes: KubernetesSource{Resource: "deployment/web", Annotation: "version"}}, "tags", 0},
+		{"both", DeployedConfig{URL: "https://example.com/version", Kubernetes: KubernetesSource{Resource: "deployment/web"}}, "", 1},
+		{"url and file", DeployedConfig{URL: "https://example.com/version", File: "VERSION"}, "", 1},
+		{"kubernetes without annotation", DeployedConfig{Kubernetes: KubernetesSource{Resource: "deployment/web"}}, "", 1},
+		{"field without url", DeployedConfig{Field: "version"}, "", 1},
+		{"deployed base without source", DeployedConfig{}, "deployed", 1},
+		{"unknown base", DeployedConfig{}, "manifest", 1},
+	}
+	for _, tt := range tests {
+		if got := validateDeployedConfig(BranchTagConfig{Branch: "main", Deployed: tt.config, Base: tt.base}); len(got) != tt.want {
+			t.Errorf("%s: validateDeployedConfig() = %q, want %d problems", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReleasedTag(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c")
+	g.tags = map[string]string{"v1.8.2": "a", "v1.8.3": "b", "v1.9.0": "c"}
+	useFakeGit(t, g)
+	manifest := filepath.Join(t.TempDir(), "VERSION")
+
+	tests := []struct {
+		name     string
+		base     string
+		deployed string
+		want     string
+		wantErr  bool
+	}{
+		{"last tag", "", "1.8.2", "v1.9.0", false},
+		{"deployed version", "deployed", "1.8.2\n", "v1.8.2", false},
+		{"deployed version with prefix", "deployed", "v1.8.3", "v1.8.3", false},
+		{"untagged deployed version", "deployed", "1.8.4", "", true},
+		{"invalid deployed version", "deployed", "latest", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.WriteFile(manifest, []byte(tt.deployed), 0644)
+			bt := BranchTagConfig{Branch: "main", Tag: "v0.0.0", Base: tt.base, Deployed: DeployedConfig{File: manifest}}
+			got, err := releasedTag(Config{BranchTags: []BranchTagConfig{bt}}, bt, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("releasedTag() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("releasedTag() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,378 @@
+//go:build integration
+// +build integration
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// e2eTimeout bounds how long an expect step waits for its text
+const e2eTimeout = 10 * time.Second
+
+// e2eStep is one expect or send line of a script
+type e2eStep struct {
+	Line      int
+	Directive string
+	Text      string
+}
+
+// e2eScript is a scripted end-to-end scenario read from testdata/e2e.
+//
+// Each line holds a directive followed by its argument; blank lines and lines
+// starting with # are ignored:
+//
+//	run <command>     shell command run in the fresh repository before the tool starts
+//	args <arguments>  command-line arguments of the tool, split on spaces
+//	expect <text>     wait until the output contains text (after the previous match)
+//	send <line>       type line and Enter (an empty line accepts the default)
+//	exit <code>       expected exit status (default 0)
+//	check <command>   shell command run in the repository afterwards, must succeed
+//
+// The tool reads the answers from a pseudo terminal, so it runs as
+// interactively as for a person. The private HOME of a scenario has an empty
+// global config, so the first-run walk-through only shows when a scenario
+// removes it (rm "$XDG_CONFIG_HOME/git-publish/config.json").
+type e2eScript struct {
+	Setup    []string
+	Args     []string
+	Steps    []e2eStep
+	ExitCode int
+	Checks   []string
+}
+
+// parseE2EScript parses the script format documented on e2eScript
+func parseE2EScript(r io.Reader) (e2eScript, error) {
+	var script e2eScript
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		directive, arg, _ := strings.Cut(text, " ")
+		arg = strings.TrimSpace(arg)
+
+		switch directive {
+		case "run":
+			script.Setup = append(script.Setup, arg)
+		case "args":
+			script.Args = append(script.Args, strings.Fields(arg)...)
+		case "expect", "send":
+			script.Steps = append(script.Steps, e2eStep{Line: line, Directive: directive, Text: arg})
+		case "exit":
+			code, err := strconv.Atoi(arg)
+			if err != nil {
+				return script, fmt.Errorf("line %d: invalid exit code %q", line, arg)
+			}
+			script.ExitCode = code
+		case "check":
+			script.Checks = append(script.Checks, arg)
+		default:
+			return script, fmt.Errorf("line %d: unknown directive %q", line, directive)
+		}
+	}
+	return script, scanner.Err()
+}
+
+// e2eOutput collects the combined output of the tool while it runs
+type e2eOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *e2eOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+func (o *e2eOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// e2eSession is a running tool process driven by a script
+type e2eSession struct {
+	t      *testing.T
+	name   string
+	stdin  io.WriteCloser
+	output *e2eOutput
+	cursor int
+	done   chan struct{}
+	err    error
+}
+
+// expect waits until text appears in the output after the previous match
+func (s *e2eSession) expect(step e2eStep) {
+	s.t.Helper()
+	deadline := time.Now().Add(e2eTimeout)
+	for {
+		output := s.output.String()
+		if i := strings.Index(output[s.cursor:], step.Text); i >= 0 {
+			s.cursor += i + len(step.Text)
+			return
+		}
+
+		select {
+		case <-s.done:
+			// Give the output one last look, the process may have written it just before exiting
+			if !strings.Contains(s.output.String()[s.cursor:], step.Text) {
+				s.t.Fatalf("%s:%d: process exited before printing %q\noutput:\n%s", s.name, step.Line, step.Text, output)
+			}
+			continue
+		default:
+		}
+		if time.Now().After(deadline) {
+			s.t.Fatalf("%s:%d: timed out waiting for %q\noutput:\n%s", s.name, step.Line, step.Text, output)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// send types a line of input
+func (s *e2eSession) send(step e2eStep) {
+	s.t.Helper()
+	if _, err := io.WriteString(s.stdin, step.Text+"\n"); err != nil {
+		s.t.Fatalf("%s:%d: sending %q: %v\noutput:\n%s", s.name, step.Line, step.Text, err, s.output.String())
+	}
+}
+
+// newE2ERepo creates an empty repository in a temporary directory, with a
+// private HOME so neither the user's git config nor their git-publish config
+// leak into the scenario; its empty global config marks the first run done.
+// It returns the repository path and the environment.
+func newE2ERepo(t *testing.T) (string, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	repo := filepath.Join(dir, "repo")
+
+	gitConfig := "[user]\n\tname = Test User\n\temail = test@example.com\n[init]\n\tdefaultBranch = main\n"
+	if err := os.MkdirAll(home, 0755); err != nil {
+		t.Fatalf("Failed to create home directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(gitConfig), 0644); err != nil {
+		t.Fatalf("Failed to write git config: %v", err)
+	}
+	userConfig := filepath.Join(home, ".config", "git-publish", "config.json")
+	if err := os.MkdirAll(filepath.Dir(userConfig), 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(userConfig, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write global config: %v", err)
+	}
+
+	env := append(os.Environ(),
+		"HOME="+home,
+		"XDG_CONFIG_HOME="+filepath.Join(home, ".config"),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"NO_COLOR=1",
+	)
+
+	cmd := exec.Command("git", "init", "-q", repo)
+	cmd.Env = env
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to initialize git repository: %v\n%s", err, output)
+	}
+	return repo, env
+}
+
+// runShell runs command through the shell in dir
+func runShell(dir string, env []string, command string) (string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// runE2EScript sets up a repository, runs the tool with the script's
+// arguments, drives its prompts and verifies the outcome
+func runE2EScript(t *testing.T, name string, script e2eScript) {
+	t.Helper()
+	repo, env := newE2ERepo(t)
+
+	for _, command := range script.Setup {
+		if output, err := runShell(repo, env, command); err != nil {
+			t.Fatalf("%s: setup %q failed: %v\n%s", name, command, err, output)
+		}
+	}
+
+	// The test binary itself runs the tool, see TestE2EMain
+	args := append([]string{"-test.run=^TestE2EMain$", "--"}, script.Args...)
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = repo
+	cmd.Env = append(env, "GIT_PUBLISH_E2E=1")
+
+	output := &e2eOutput{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	ptmx, tty, err := openPTY()
+	if err != nil {
+		t.Skipf("Cannot run %s: %v", name, err)
+	}
+	defer ptmx.Close()
+	cmd.Stdin = tty
+	if err := cmd.Start(); err != nil {
+		tty.Close()
+		t.Fatalf("Failed to start git-publish: %v", err)
+	}
+	tty.Close()
+	// The terminal echoes what is typed; the output is only read from stdout and stderr
+	go io.Copy(io.Discard, ptmx)
+
+	session := &e2eSession{t: t, name: name, stdin: ptmx, output: output, done: make(chan struct{})}
+	go func() {
+		session.err = cmd.Wait()
+		close(session.done)
+	}()
+	defer cmd.Process.Kill()
+
+	for _, step := range script.Steps {
+		if step.Directive == "expect" {
+			session.expect(step)
+		} else {
+			session.send(step)
+		}
+	}
+
+	// Ctrl-D at the start of a line ends the input, as a closed pipe would
+	io.WriteString(ptmx, "\x04")
+	select {
+	case <-session.done:
+	case <-time.After(e2eTimeout):
+		t.Fatalf("%s: git-publish did not exit\noutput:\n%s", name, output.String())
+	}
+
+	exitCode := 0
+	if exitErr, ok := session.err.(*exec.ExitError); ok {
+		exitCode = exitErr.ExitCode()
+	} else if session.err != nil {
+		t.Fatalf("%s: running git-publish: %v", name, session.err)
+	}
+	if exitCode != script.ExitCode {
+		t.Fatalf("%s: exit status %d, expected %d\noutput:\n%s", name, exitCode, script.ExitCode, output.String())
+	}
+
+	for _, command := range script.Checks {
+		if checkOutput, err := runShell(repo, env, command); err != nil {
+			t.Errorf("%s: check %q failed: %v\n%s\ngit-publish output:\n%s", name, command, err, checkOutput, output.String())
+		}
+	}
+}
+
+// TestE2EMain runs git-publish when the test binary is started by runE2EScript
+func TestE2EMain(t *testing.T) {
+	if os.Getenv("GIT_PUBLISH_E2E") != "1" {
+		t.Skip("Only runs as the git-publish process of an end-to-end scenario")
+	}
+
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	os.Args = append([]string{"git-publish"}, args...)
+	main()
+	os.Exit(0)
+}
+
+// TestE2EScenarios drives the interactive flow with every script in testdata/e2e
+func TestE2EScenarios(t *testing.T) {
+	// Skip in normal test runs
+	if os.Getenv("RUN_INTEGRATION_TESTS") != "true" {
+		t.Skip("Skipping integration test. Set RUN_INTEGRATION_TESTS=true to run")
+	}
+
+	paths, err := filepath.Glob(filepath.Join("testdata", "e2e", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("No scenarios found in testdata/e2e")
+	}
+
+	for _, path := range paths {
+		name := filepath.Base(path)
+		t.Run(strings.TrimSuffix(name, ".txt"), func(t *testing.T) {
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			script, err := parseE2EScript(file)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			runE2EScript(t, name, script)
+		})
+	}
+}
+
+func TestParseE2EScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    e2eScript
+		wantErr bool
+	}{
+		{
+			name: "all directives",
+			input: "# comment\n\nrun git commit --allow-empty -m init\nargs --no-push --branch main\n" +
+				"expect Enter tag\nsend\nsend v1.0.0\nexit 1\ncheck git tag -l v1.0.0\n",
+			want: e2eScript{
+				Setup: []string{"git commit --allow-empty -m init"},
+				Args:  []string{"--no-push", "--branch", "main"},
+				Steps: []e2eStep{
+					{Line: 5, Directive: "expect", Text: "Enter tag"},
+					{Line: 6, Directive: "send", Text: ""},
+					{Line: 7, Directive: "send", Text: "v1.0.0"},
+				},
+				ExitCode: 1,
+				Checks:   []string{"git tag -l v1.0.0"},
+			},
+		},
+		{
+			name:    "unknown directive",
+			input:   "wait 5\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid exit code",
+			input:   "exit one\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseE2EScript(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseE2EScript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseE2EScript() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
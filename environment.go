@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// isProductionEnvironment reports whether a branch environment is production,
+// named production or prod in any case
+func isProductionEnvironment(environment string) bool {
+	return strings.EqualFold(environment, "production") || strings.EqualFold(environment, "prod")
+}
+
+// environmentLabel shows an environment in prompts: in capitals, red for
+// production and yellow for any other environment
+func environmentLabel(environment string) string {
+	if isProductionEnvironment(environment) {
+		return color.New(color.FgRed, color.Bold).Sprint(strings.ToUpper(environment))
+	}
+	return color.New(color.FgYellow).Sprint(strings.ToUpper(environment))
+}
+
+// confirmEnvironment tells where the planned tag is released to and, for
+// production, asks once more. Naming both the branch and the tag with flags,
+// as CI does, counts as confirmation. A critical branch instead needs the tag
+// typed, see confirmCritical. It returns false when the release is cancelled.
+func confirmEnvironment(plan Plan, opts *publishOptions) bool {
+	if plan.Environment != "" {
+		fmt.Printf("You are about to publish %s to %s (branch %s)\n", plan.Tag, environmentLabel(plan.Environment), plan.Branch)
+	}
+	switch {
+	case plan.Critical:
+		return confirmCritical(plan, opts.Confirm)
+	case !isProductionEnvironment(plan.Environment) || (opts.Branch != "" && opts.Tag != ""):
+		return true
+	}
+	return prompter.Confirm(fmt.Sprintf("Publish %s to %s?", plan.Tag, plan.Environment), false)
+}
+
+// confirmCritical asks to type the tag before it is created on a critical
+// branch, unless confirmed, the value of --confirm, already names it
+func confirmCritical(plan Plan, confirmed string) bool {
+	if confirmed != "" {
+		if confirmed != plan.Tag {
+			fmt.Printf("--confirm %s does not match the tag %s; nothing was created.\n", confirmed, plan.Tag)
+			return false
+		}
+		return true
+	}
+	answer, err := prompter.Input(fmt.Sprintf("%s is a critical branch. Type %s to create the tag", plan.Branch, plan.Tag), "", func(string) error { return nil })
+	if err != nil || answer != plan.Tag {
+		fmt.Printf("The typed text does not match %s.\n", plan.Tag)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsProductionEnvironment(t *testing.T) {
+	tests := []struct {
+		environment string
+		want        bool
+	}{
+		{"production", true},
+		{"Production", true},
+		{"PROD", true},
+		{"staging", false},
+		{"preproduction", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isProductionEnvironment(tt.environment); got != tt.want {
+			t.Errorf("isProductionEnvironment(%q) = %v, want %v", tt.environment, got, tt.want)
+		}
+	}
+}
+
+func TestConfirmEnvironment(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		opts        publishOptions
+		answers     []string
+		want        bool
+		wantAsked   int
+	}{
+		{"no environment", "", publishOptions{}, nil, true, 0},
+		{"staging", "staging", publishOptions{}, nil, true, 0},
+		{"production confirmed", "production", publishOptions{}, []string{"y"}, true, 1},
+		{"production by default cancelled", "production", publishOptions{}, []string{""}, false, 1},
+		{"production from flags", "production", publishOptions{Branch: "main", Tag: "v1.2.0"}, nil, true, 0},
+		{"production with only the branch flag", "production", publishOptions{Branch: "main"}, []string{"n"}, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := useScriptedPrompter(t, tt.answers...)
+			plan := Plan{Branch: "main", Tag: "v1.2.0", Environment: tt.environment}
+			var got bool
+			output := captureOutput(func() { got = confirmEnvironment(plan, &tt.opts) })
+			if got != tt.want || len(p.asked) != tt.wantAsked {
+				t.Errorf("confirmEnvironment() = %v after %q, want %v after %d questions", got, p.asked, tt.want, tt.wantAsked)
+			}
+			if tt.environment != "" && !strings.Contains(output, "You are about to publish v1.2.0 to "+strings.ToUpper(tt.environment)) {
+				t.Errorf("confirmEnvironment() printed %q", output)
+			}
+		})
+	}
+}
+
+func TestConfirmCritical(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		opts        publishOptions
+		answers     []string
+		want        bool
+		wantAsked   int
+	}{
+		{"typed", "", publishOptions{}, []string{"v1.2.0"}, true, 1},
+		{"typed wrong", "", publishOptions{}, []string{"v1.2"}, false, 1},
+		{"enter only", "production", publishOptions{}, []string{""}, false, 1},
+		{"flags are not enough", "production", publishOptions{Branch: "main", Tag: "v1.2.0"}, []string{"y"}, false, 1},
+		{"confirmed in advance", "production", publishOptions{Branch: "main", Tag: "v1.2.0", Confirm: "v1.2.0"}, nil, true, 0},
+		{"confirmed another tag", "", publishOptions{Confirm: "v1.1.0"}, nil, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := useScriptedPrompter(t, tt.answers...)
+			plan := Plan{Branch: "main", Tag: "v1.2.0", Environment: tt.environment, Critical: true}
+			var got bool
+			captureOutput(func() { got = confirmEnvironment(plan, &tt.opts) })
+			if got != tt.want || len(p.asked) != tt.wantAsked {
+				t.Errorf("confirmEnvironment() = %v after %q, want %v after %d questions", got, p.asked, tt.want, tt.wantAsked)
+			}
+		})
+	}
+}
+
+func TestMakePlanEnvironment(t *testing.T) {
+	useFakeGit(t, newFakeGitClient("a"))
+	config := Config{BranchTags: []BranchTagConfig{{Branch: "main", Tag: "v0.0.0", Environment: "production", Critical: true}}}
+	plan, err := makePlan(config, nil, "main", "v0.0.0", "", "v1.0.0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Environment != "production" || !plan.Critical || !strings.Contains(formatPlan(plan), "Environment:   production\n  Critical:      yes") {
+		t.Errorf("plan environment %q, formatted:\n%s", plan.Environment, formatPlan(plan))
+	}
+}
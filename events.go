@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Lifecycle events published while a plan is executed
+const (
+	// eventTagComputed is published when execution of a plan starts, before the tag exists
+	eventTagComputed = "TagComputed"
+	eventTagCreated  = "TagCreated"
+	eventTagPushed   = "TagPushed"
+	// eventReleaseCreated carries the release URL
+	eventReleaseCreated = "ReleaseCreated"
+	// eventPublished is published once every step of the plan succeeded
+	eventPublished = "Published"
+	// eventPublishFailed carries the error that stopped the plan
+	eventPublishFailed = "PublishFailed"
+	// eventRolledBack is recorded by the rollback command, outside of any plan
+	eventRolledBack = "RolledBack"
+)
+
+// event is a lifecycle event of a publish run
+type event struct {
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	Plan  Plan      `json:"plan"`
+	URL   string    `json:"url,omitempty"`
+	Error string    `json:"error,omitempty"`
+	// Message says what a rollback undid and why
+	Message string `json:"message,omitempty"`
+}
+
+// eventHandler reacts to an event; an error stops the publish run
+type eventHandler func(e event) error
+
+// eventBus delivers lifecycle events to the integrations subscribed to them
+type eventBus struct {
+	handlers map[string][]eventHandler
+}
+
+// newEventBus returns a bus with the standard subscribers: hooks and the audit log
+func newEventBus() *eventBus {
+	bus := &eventBus{handlers: make(map[string][]eventHandler)}
+	subscribeHooks(bus)
+	subscribeAuditLog(bus)
+	return bus
+}
+
+// subscribe registers handler for the given event types
+func (b *eventBus) subscribe(handler eventHandler, types ...string) {
+	for _, t := range types {
+		b.handlers[t] = append(b.handlers[t], handler)
+	}
+}
+
+// publish delivers an event to its subscribers in order and stops at the first error
+func (b *eventBus) publish(e event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	for _, handler := range b.handlers[e.Type] {
+		if err := handler(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishPlan creates (and optionally pushes and releases) the planned tag,
+// publishing lifecycle events on bus along the way
+func publishPlan(plan Plan, config Config, bus *eventBus) error {
+	// Verified before the version files are committed, so a failure leaves the branch alone
+	if err := verifyRelease(&plan); err != nil {
+		bus.publish(event{Type: eventPublishFailed, Plan: plan, Error: err.Error()})
+		return err
+	}
+	if err := commitPlannedVersionFiles(&plan); err != nil {
+		bus.publish(event{Type: eventPublishFailed, Plan: plan, Error: err.Error()})
+		return err
+	}
+	// Only cleared when the steps return: after a panic, handlePanic finds the plan here
+	inFlight = &publishProgress{Plan: plan}
+	url, err := runPlanSteps(plan, config, bus, inFlight)
+	inFlight = nil
+	if err != nil {
+		bus.publish(event{Type: eventPublishFailed, Plan: plan, Error: err.Error()})
+		return err
+	}
+	if err := bus.publish(event{Type: eventPublished, Plan: plan, URL: url}); err != nil {
+		return err
+	}
+
+	// The release is out: a failed announcement or GitOps pull request is only reported
+	if len(plan.Announce) > 0 {
+		enterPhase("announce release")
+		if err := announceRelease(config.Announce, plan, url, false); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+	if plan.GitOps != "" {
+		enterPhase("open gitops pull request")
+		prURL, err := openGitOpsPullRequest(config.GitOps, plan, url)
+		switch {
+		case err != nil:
+			fmt.Printf("Warning: could not open the GitOps pull request: %v\n", err)
+		case prURL != "":
+			fmt.Printf("GitOps pull request: %s\n", prURL)
+		}
+	}
+	return nil
+}
+
+// runPlanSteps executes the steps of the plan, returns the release URL, if any,
+// and records the completed steps in progress
+func runPlanSteps(plan Plan, config Config, bus *eventBus, progress *publishProgress) (string, error) {
+	if err := bus.publish(event{Type: eventTagComputed, Plan: plan}); err != nil {
+		return "", err
+	}
+
+	// Create tag on branch
+	enterPhase("create tag")
+	create := func() error { return createTag(plan.TargetCommit, plan.Tag) }
+	switch {
+	case plan.TagDate != "":
+		create = func() error { return createBackdatedTag(plan) }
+	case plan.TagMessage != "":
+		create = func() error { return createMarkedTag(plan) }
+	}
+	if plan.ReleaseRef != "" {
+		createTag := create
+		create = func() error {
+			if err := createTag(); err != nil {
+				return err
+			}
+			return createReleaseRef(plan)
+		}
+	}
+	if plan.TagExists {
+		// Created by an earlier run, whose postTag hooks ran already
+		fmt.Printf("Tag %s already exists, skipping creation\n", plan.Tag)
+		if plan.ReleaseRef != "" {
+			if err := createReleaseRef(plan); err != nil {
+				return "", err
+			}
+		}
+	} else {
+		if err := create(); err != nil {
+			return "", err
+		}
+		progress.TagCreated = true
+		if err := bus.publish(event{Type: eventTagCreated, Plan: plan}); err != nil {
+			return "", err
+		}
+	}
+
+	// Push to remote if requested
+	if plan.Remote != "" {
+		enterPhase("push tag")
+		fmt.Printf("Pushing tag %s to remote %s...\n", plan.Tag, plan.Remote)
+		if err := pushTagToRemote(plan.Tag, plan.Remote, plan.PushArgs, config.Push.SSHKey); err != nil {
+			return "", err
+		}
+		progress.TagPushed = true
+		if err := bus.publish(event{Type: eventTagPushed, Plan: plan}); err != nil {
+			return "", err
+		}
+	}
+
+	// Mirrors are best effort: failures are reported but do not fail the run
+	if len(plan.Mirrors) > 0 {
+		enterPhase("replicate tag")
+		replicateToMirrors(plan.Mirrors, config.Push.SSHKey)
+	}
+
+	if !plan.Release {
+		return "", nil
+	}
+	enterPhase("create release")
+	url, err := createRelease(plan, config)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Release published: %s\n", url)
+	return url, bus.publish(event{Type: eventReleaseCreated, Plan: plan, URL: url})
+}
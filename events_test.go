@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestEventBus tests delivery order and that a failing handler stops delivery
+func TestEventBus(t *testing.T) {
+	bus := &eventBus{handlers: make(map[string][]eventHandler)}
+
+	var delivered []string
+	record := func(name string, err error) eventHandler {
+		return func(e event) error {
+			delivered = append(delivered, name+":"+e.Type)
+			return err
+		}
+	}
+	bus.subscribe(record("first", nil), eventTagCreated, eventTagPushed)
+	bus.subscribe(record("second", fmt.Errorf("hook failed")), eventTagPushed)
+	bus.subscribe(record("third", nil), eventTagPushed)
+
+	if err := bus.publish(event{Type: eventTagCreated}); err != nil {
+		t.Errorf("publish(TagCreated) error: %v", err)
+	}
+	if err := bus.publish(event{Type: eventTagPushed}); err == nil {
+		t.Errorf("publish(TagPushed) expected the handler error")
+	}
+
+	expected := "first:TagCreated first:TagPushed second:TagPushed"
+	if result := strings.Join(delivered, " "); result != expected {
+		t.Errorf("delivered %q, expected %q", result, expected)
+	}
+}
+
+// TestPublishPlanEvents tests the events published when a plan succeeds or fails
+func TestPublishPlanEvents(t *testing.T) {
+	testCases := []struct {
+		name     string
+		commit   string
+		expected string
+	}{
+		{"success", "abc", "TagComputed TagCreated Published"},
+		{"tag creation fails", "unknown", "TagComputed PublishFailed"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			useFakeGit(t, newFakeGitClient("abc"))
+
+			bus := &eventBus{handlers: make(map[string][]eventHandler)}
+			var types []string
+			bus.subscribe(func(e event) error {
+				types = append(types, e.Type)
+				return nil
+			}, eventTagComputed, eventTagCreated, eventTagPushed, eventReleaseCreated, eventPublished, eventPublishFailed)
+
+			captureOutput(func() { publishPlan(Plan{Branch: "main", TargetCommit: tc.commit, Tag: "v1.0.0"}, Config{}, bus) })
+			if result := strings.Join(types, " "); result != tc.expected {
+				t.Errorf("events = %q, expected %q", result, tc.expected)
+			}
+		})
+	}
+}
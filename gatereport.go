@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Gates checked before a tag is created, as named in the gate report
+const (
+	gateTagPolicy    = "tag policy"
+	gateGoAPI        = "Go API compatibility"
+	gateGoModule     = "Go module path"
+	gateSignatures   = "signed commits"
+	gateDependencies = "dependencies"
+	gatePermissions  = "token permissions"
+	gateVersions     = "version strings"
+	gateChecklist    = "release checklist"
+	gateVerify       = "verify command"
+)
+
+// Outcomes of a gate
+const (
+	gatePassed  = "passed"
+	gateFailed  = "failed"
+	gateSkipped = "skipped"
+)
+
+// gateResult is the outcome of one gate
+type gateResult struct {
+	Name   string
+	Status string
+	// Message is the error of a failed gate or why a gate was skipped
+	Message  string
+	Start    time.Time
+	Duration time.Duration
+}
+
+// gateRecorder collects the outcomes of the gates of a run and writes them
+// to the report file after every gate, so that the report is complete also
+// when a failing gate ends the run
+type gateRecorder struct {
+	// Path is the report file, JUnit XML or SARIF by extension; empty records nothing
+	Path    string
+	Branch  string
+	Tag     string
+	results []gateResult
+}
+
+// gates records the gates of the current run
+var gates = &gateRecorder{}
+
+// startGateReport starts recording the gates of a run into the report at path
+func startGateReport(path string) {
+	gates = &gateRecorder{Path: path}
+}
+
+// runGate runs check as the gate name, or records it as skipped when it is
+// not enabled, and returns the error of check
+func runGate(name string, enabled bool, check func() error) error {
+	if !enabled {
+		skipGate(name, "not configured")
+		return nil
+	}
+	start := time.Now()
+	err := check()
+	result := gateResult{Name: name, Status: gatePassed, Start: start, Duration: time.Since(start)}
+	if err != nil {
+		result.Status, result.Message = gateFailed, err.Error()
+	}
+	gates.record(result)
+	return err
+}
+
+// skipGate records that the gate name did not run, and why
+func skipGate(name, reason string) {
+	gates.record(gateResult{Name: name, Status: gateSkipped, Message: reason, Start: time.Now()})
+}
+
+// record keeps result, replacing an earlier outcome of the same gate (the
+// flow checks again after going back), and rewrites the report
+func (g *gateRecorder) record(result gateResult) {
+	if g.Path == "" {
+		return
+	}
+	replaced := false
+	for i, r := range g.results {
+		if r.Name == result.Name {
+			g.results[i], replaced = result, true
+		}
+	}
+	if !replaced {
+		g.results = append(g.results, result)
+	}
+	if err := g.write(); err != nil {
+		fmt.Printf("Warning: could not write the gate report: %v\n", err)
+	}
+}
+
+// write writes the report, as SARIF for .sarif and .json files and as JUnit XML otherwise
+func (g *gateRecorder) write() error {
+	var data []byte
+	var err error
+	switch strings.ToLower(filepath.Ext(g.Path)) {
+	case ".sarif", ".json":
+		data, err = json.MarshalIndent(g.sarif(), "", "  ")
+	default:
+		data, err = xml.MarshalIndent(g.junit(), "", "  ")
+		data = append([]byte(xml.Header), data...)
+	}
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(g.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(g.Path, append(data, '\n'), 0644)
+}
+
+// subject names the release the gates were checked for
+func (g *gateRecorder) subject() string {
+	switch {
+	case g.Tag != "":
+		return fmt.Sprintf("%s on %s", g.Tag, g.Branch)
+	case g.Branch != "":
+		return g.Branch
+	}
+	return "release"
+}
+
+// junitTestSuites is a JUnit XML report: one suite whose test cases are the gates
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+func (g *gateRecorder) junit() junitTestSuites {
+	suite := junitTestSuite{Name: "git-publish gates for " + g.subject()}
+	if g.Branch != "" {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "branch", Value: g.Branch})
+	}
+	if g.Tag != "" {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "tag", Value: g.Tag})
+	}
+	for _, r := range g.results {
+		if suite.Timestamp == "" {
+			suite.Timestamp = r.Start.UTC().Format(time.RFC3339)
+		}
+		c := junitTestCase{Name: r.Name, Classname: "git-publish.gates", Time: fmt.Sprintf("%.3f", r.Duration.Seconds())}
+		switch r.Status {
+		case gateFailed:
+			c.Failure = &junitMessage{Message: r.Message}
+			suite.Failures++
+		case gateSkipped:
+			c.Skipped = &junitMessage{Message: r.Message}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, c)
+		suite.Tests++
+	}
+	return junitTestSuites{Name: "git-publish", Tests: suite.Tests, Failures: suite.Failures, Skipped: suite.Skipped, Suites: []junitTestSuite{suite}}
+}
+
+// sarifLog is a SARIF 2.1.0 log with one result per gate: kind pass, fail
+// (level error) or notApplicable
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Results     []sarifResult     `json:"results"`
+	Properties  map[string]string `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name  string      `json:"name"`
+		Rules []sarifRule `json:"rules"`
+	} `json:"driver"`
+}
+
+type sarifRule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful bool   `json:"executionSuccessful"`
+	StartTimeUTC        string `json:"startTimeUtc,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID  string `json:"ruleId"`
+	Kind    string `json:"kind"`
+	Level   string `json:"level"`
+	Message struct {
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+func (g *gateRecorder) sarif() sarifLog {
+	run := sarifRun{Invocations: []sarifInvocation{{ExecutionSuccessful: true}}, Results: []sarifResult{}, Properties: map[string]string{}}
+	run.Tool.Driver.Name = "git-publish"
+	run.Tool.Driver.Rules = []sarifRule{}
+	if g.Branch != "" {
+		run.Properties["branch"] = g.Branch
+	}
+	if g.Tag != "" {
+		run.Properties["tag"] = g.Tag
+	}
+	for _, r := range g.results {
+		id := strings.ReplaceAll(strings.ToLower(r.Name), " ", "-")
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: id, Name: r.Name})
+		if run.Invocations[0].StartTimeUTC == "" {
+			run.Invocations[0].StartTimeUTC = r.Start.UTC().Format(time.RFC3339)
+		}
+		result := sarifResult{RuleID: id, Kind: "pass", Level: "none"}
+		result.Message.Text = fmt.Sprintf("%s %s for %s", r.Name, r.Status, g.subject())
+		switch r.Status {
+		case gateFailed:
+			result.Kind, result.Level = "fail", "error"
+			result.Message.Text += ": " + r.Message
+		case gateSkipped:
+			result.Kind = "notApplicable"
+			result.Message.Text += ": " + r.Message
+		}
+		run.Results = append(run.Results, result)
+	}
+	return sarifLog{Version: "2.1.0", Schema: "https://json.schemastore.org/sarif-2.1.0.json", Runs: []sarifRun{run}}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunGate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports", "gates.xml")
+	startGateReport(path)
+	t.Cleanup(func() { startGateReport("") })
+	gates.Branch, gates.Tag = "main", "v1.2.0"
+
+	ran := false
+	runGate(gateSignatures, false, func() error { ran = true; return nil })
+	if ran {
+		t.Error("runGate() ran a gate that is not enabled")
+	}
+	runGate(gateTagPolicy, true, func() error { return errors.New("rule failed") })
+	runGate(gateTagPolicy, true, func() error { return nil })
+	if err := runGate(gateVerify, true, func() error { return errors.New("exit status 1") }); err == nil {
+		t.Error("runGate() did not return the error of the gate")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	report := string(data)
+	for _, want := range []string{
+		`<testsuites name="git-publish" tests="3" failures="1" skipped="1">`,
+		`<property name="tag" value="v1.2.0"></property>`,
+		`<skipped message="not configured"></skipped>`,
+		`<failure message="exit status 1"></failure>`,
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %s:\n%s", want, report)
+		}
+	}
+	// The tag policy passed when checked again
+	if strings.Contains(report, "rule failed") {
+		t.Errorf("report keeps the replaced outcome:\n%s", report)
+	}
+}
+
+func TestGateReportSARIF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gates.sarif")
+	startGateReport(path)
+	t.Cleanup(func() { startGateReport("") })
+	gates.Branch, gates.Tag = "main", "v1.2.0"
+	runGate(gateGoModule, true, func() error { return nil })
+	skipGate(gateVerify, "--skip-verify")
+	runGate(gateDependencies, true, func() error { return errors.New("2 advisories") })
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("report is not JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("report = %s, want one SARIF 2.1.0 run", data)
+	}
+	var got []string
+	for _, r := range log.Runs[0].Results {
+		got = append(got, r.RuleID+" "+r.Kind+" "+r.Level)
+	}
+	want := "go-module-path pass none, verify-command notApplicable none, dependencies fail error"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("results = %q, want %q", strings.Join(got, ", "), want)
+	}
+	if text := log.Runs[0].Results[2].Message.Text; text != "dependencies failed for v1.2.0 on main: 2 advisories" {
+		t.Errorf("message = %q", text)
+	}
+}
+
+func TestGateReportDisabled(t *testing.T) {
+	startGateReport("")
+	runGate(gateVerify, true, func() error { return nil })
+	if len(gates.results) != 0 {
+		t.Errorf("gates recorded without a report: %v", gates.results)
+	}
+}
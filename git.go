@@ -0,0 +1,884 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitClient covers the git operations of the publish flow, so the flow can run
+// against the git binary or, in tests, against an in-memory repository
+type GitClient interface {
+	// IsRepository reports whether the working directory is inside a work tree
+	IsRepository() bool
+	// TopLevel returns the root directory of the work tree
+	TopLevel() (string, error)
+	// Refs returns the full names of the refs matching any of the for-each-ref
+	// patterns, e.g. refs/heads/main or refs/remotes/*/main
+	Refs(patterns ...string) ([]string, error)
+	// Remotes returns the URL of every remote by name
+	Remotes() (map[string]string, error)
+	// Fetch runs git fetch with the given arguments
+	Fetch(args ...string) error
+	// UpdateRemote updates the remote-tracking branches of remote, pruning deleted ones
+	UpdateRemote(remote string) error
+	// ListTags returns the tags matching pattern (all tags if empty), highest version first
+	ListTags(pattern string) ([]string, error)
+	// RevParse resolves rev to a full object name
+	RevParse(rev string) (string, error)
+	// IsAncestor reports whether ancestor is reachable from commit
+	IsAncestor(ancestor, commit string) (bool, error)
+	// CreateTag creates a lightweight tag on commit
+	CreateTag(tag, commit string) error
+	// CreateAnnotatedTag creates an annotated tag on commit, dated date instead of now
+	CreateAnnotatedTag(tag, commit, message string, date time.Time) error
+	// CreateSignedTag creates a signed annotated tag dated date, failing if the
+	// tag exists
+	CreateSignedTag(tag, commit, message string, date time.Time, signer tagSigner) error
+	// SignedTagObject writes a signed tag object like CreateSignedTag without
+	// creating the tag, returning the object name
+	SignedTagObject(tag, commit, message string, date time.Time, signer tagSigner) (string, error)
+	// VerifyTag checks the signature of a tag object with git verify-tag run
+	// with the -c options of config; errors carry git's message
+	VerifyTag(object string, config []string) error
+	// TagMessage returns the message of an annotated tag without its subject
+	// and signature, "" for a lightweight tag
+	TagMessage(tag string) (string, error)
+	// CreateTagObject writes an annotated tag object for tag on commit, dated
+	// date, without creating the tag ref, and returns its name
+	CreateTagObject(tag, commit, message string, date time.Time) (string, error)
+	// MoveTag points the tag ref at object in one update, failing unless it
+	// still holds oldObject
+	MoveTag(tag, object, oldObject string) error
+	// DeleteTag deletes a local tag
+	DeleteTag(tag string) error
+	// CreateRef creates ref at commit, failing if it exists
+	CreateRef(ref, commit string) error
+	// CreateBranch creates a local branch at commit, failing if it exists
+	CreateBranch(branch, commit string) error
+	// AddWorktree checks out commit in a new detached work tree at dir
+	AddWorktree(dir, commit string) error
+	// RemoveWorktree removes the work tree at dir, with any changes made in it
+	RemoveWorktree(dir string) error
+	// Commits lists the commits reachable from to but not from from (all of
+	// them when from is ""), newest first, as "<short hash> <subject>" without merges
+	Commits(from, to string) ([]string, error)
+	// CommitMessages returns the full message of each commit Commits lists, by short hash
+	CommitMessages(from, to string) (map[string]string, error)
+	// LogMessages returns the full messages of the commits reachable from to
+	// but not from from (all of them when from is ""), merges included, newest first
+	LogMessages(from, to string) ([]string, error)
+	// CommitStats counts the commits reachable from to but not from from, like
+	// Commits, with their authors and the date of the oldest one
+	CommitStats(from, to string) (commitStats, error)
+	// CommitSignatures returns the signature status of every commit Commits lists, newest first
+	CommitSignatures(from, to string) ([]commitSignature, error)
+	// CommitDate returns the committer date of commit
+	CommitDate(commit string) (time.Time, error)
+	// Push runs git push with args, authenticating with sshKey if it is not empty
+	Push(args []string, sshKey string) error
+	// ConfigValue returns the value of a git config key, "" if it is not set
+	ConfigValue(key string) (string, error)
+	// DefaultBranch returns the branch the HEAD of remote points to
+	DefaultBranch(remote string) (string, error)
+	// RemoteTag returns the commit tag points to on remote and the value of
+	// the tag ref there, the tag object of an annotated tag; "" if the remote
+	// has no such tag
+	RemoteTag(remote, tag string) (commit, object string, err error)
+	// RemoteTags returns the names of the tags on remote
+	RemoteTags(remote string) ([]string, error)
+	// PushDryRun checks whether remote accepts a push of refspec, without
+	// pushing anything or prompting for credentials; it gives up after timeout
+	PushDryRun(remote, refspec, sshKey string, timeout time.Duration) error
+	// CredentialPassword asks the configured credential helpers, without
+	// prompting, for the password of https://host/path; "" if they have none
+	CredentialPassword(host, path string) (string, error)
+	// GitPath returns the path of name inside the git directory, e.g. git-publish/audit.log
+	GitPath(name string) (string, error)
+	// CountCommits counts the commits reachable from to but not from from that
+	// touch any of paths, all of them when paths is empty
+	CountCommits(from, to string, paths []string) (int, error)
+	// ChangedFiles lists the files changed between two revisions, limited to paths when given
+	ChangedFiles(from, to string, paths []string) ([]fileChange, error)
+	// ListTree returns the names of the entries of a tree, e.g. HEAD:cmd
+	ListTree(treeish string) ([]string, error)
+	// ListFiles returns the paths of all files of rev
+	ListFiles(rev string) ([]string, error)
+	// CurrentBranch returns the checked-out branch, an error when HEAD is detached
+	CurrentBranch() (string, error)
+	// IsClean reports whether the tracked files and the index match HEAD
+	IsClean() (bool, error)
+	// HasStagedChanges reports whether the index differs from HEAD
+	HasStagedChanges() (bool, error)
+	// TrackedFiles returns the tracked files matching the pathspecs
+	TrackedFiles(pathspecs ...string) ([]string, error)
+	// StageGitlink records commit as the submodule at path in the index
+	StageGitlink(path, commit string) error
+	// Clone clones url into dir without checking out a branch
+	Clone(url, dir string) error
+	// SetRemoteHead sets refs/remotes/<remote>/HEAD to the default branch of remote
+	SetRemoteHead(remote string) error
+	// CheckoutDetached detaches HEAD at rev and checks it out
+	CheckoutDetached(rev string) error
+	// Checkout switches the working tree to branch; git's output is shown
+	Checkout(branch string) error
+	// Merge merges branch into the checked-out branch with a merge commit,
+	// leaving conflicts in the working tree; git's output is shown
+	Merge(branch, message string) error
+	// Commit commits paths as they are in the working tree, or the index when
+	// no path is given, on the checked-out branch; git's output is shown
+	Commit(message string, paths ...string) error
+	// SubmodulePaths returns the paths of the submodules declared in .gitmodules
+	SubmodulePaths() ([]string, error)
+	// IsPartialClone reports whether the repository has a promisor remote
+	IsPartialClone() bool
+	// ReadBlobs returns the contents of the given rev:path objects, leaving out
+	// those that do not exist; errors carry git's message
+	ReadBlobs(specs []string) (map[string][]byte, error)
+	// ReadIndexFile returns the contents of a file in the index, by its path
+	// from the repository root; errors carry git's message
+	ReadIndexFile(path string) ([]byte, error)
+	// SkipWorktreeFiles returns those of the files marked skip-worktree in the
+	// index, as paths from the repository root
+	SkipWorktreeFiles(files []string) ([]string, error)
+}
+
+// commitStats summarizes a range of commits
+type commitStats struct {
+	Count   int
+	Authors int
+	// Oldest is the committer date of the oldest commit, zero without commits
+	Oldest time.Time
+}
+
+// gitClient is the GitClient used by the publish flow
+var gitClient GitClient = execGitClient{}
+
+// execGitClient implements GitClient by running the git binary through execCommand
+type execGitClient struct{}
+
+func (execGitClient) IsRepository() bool {
+	return execCommand("git", "rev-parse", "--is-inside-work-tree").Run() == nil
+}
+
+func (execGitClient) TopLevel() (string, error) {
+	output, err := execCommand("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (execGitClient) Refs(patterns ...string) ([]string, error) {
+	args := append([]string{"for-each-ref", "--format=%(refname)"}, patterns...)
+	output, err := execCommand("git", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return outputLines(output), nil
+}
+
+// remoteURLCache holds the remote URLs by working directory. They are looked
+// up many times per run and the tool never changes remotes, so one git call
+// per repository is enough.
+var remoteURLCache = struct {
+	sync.Mutex
+	byDir map[string]map[string]string
+}{byDir: map[string]map[string]string{}}
+
+func (execGitClient) Remotes() (map[string]string, error) {
+	dir, _ := os.Getwd()
+	remoteURLCache.Lock()
+	defer remoteURLCache.Unlock()
+	remoteURLs, ok := remoteURLCache.byDir[dir]
+	if !ok {
+		// Exits with 1 when no remote has a URL
+		output, err := execCommand("git", "config", "--get-regexp", `^remote\..*\.url$`).Output()
+		var exitErr *exec.ExitError
+		if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+			return nil, err
+		}
+		remoteURLs = parseRemoteURLs(output)
+		remoteURLCache.byDir[dir] = remoteURLs
+	}
+
+	// Callers get their own copy
+	copied := make(map[string]string, len(remoteURLs))
+	for remote, url := range remoteURLs {
+		copied[remote] = url
+	}
+	return copied, nil
+}
+
+// parseRemoteURLs reads the remote.<name>.url lines of git config
+// --get-regexp. Remote names may contain dots; for a remote with several URLs
+// the last one wins, like git config --get.
+func parseRemoteURLs(output []byte) map[string]string {
+	remoteURLs := make(map[string]string)
+	for _, line := range outputLines(output) {
+		key, url, _ := strings.Cut(line, " ")
+		remote := strings.TrimSuffix(strings.TrimPrefix(key, "remote."), ".url")
+		remoteURLs[remote] = strings.TrimSpace(url)
+	}
+	return remoteURLs
+}
+
+func (execGitClient) Fetch(args ...string) error {
+	return runWithStderr(execCommand("git", append([]string{"fetch"}, args...)...))
+}
+
+func (execGitClient) UpdateRemote(remote string) error {
+	return execCommand("git", "remote", "update", remote, "--prune").Run()
+}
+
+func (execGitClient) ListTags(pattern string) ([]string, error) {
+	args := []string{"tag", "--list"}
+	if pattern != "" {
+		args = append(args, pattern)
+	}
+	output, err := execCommand("git", append(args, "--sort=-v:refname")...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return outputLines(output), nil
+}
+
+func (execGitClient) RevParse(rev string) (string, error) {
+	output, err := execCommand("git", "rev-parse", "--verify", "--quiet", rev).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (execGitClient) IsAncestor(ancestor, commit string) (bool, error) {
+	err := execCommand("git", "merge-base", "--is-ancestor", ancestor, commit).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (execGitClient) CreateTag(tag, commit string) error {
+	// The empty old value makes the update fail when a concurrent publisher
+	// created the tag first, instead of overwriting it
+	return runWithStderr(execCommand("git", "update-ref", "--create-reflog", "-m", "git-publish: create tag", "refs/tags/"+tag, commit, ""))
+}
+
+func (execGitClient) CreateAnnotatedTag(tag, commit, message string, date time.Time) error {
+	// Without -f git tag updates the ref in a transaction that expects it to
+	// be absent, just as update-ref with an empty old value does
+	cmd := execCommand("git", "tag", "--create-reflog", "-a", "-m", message, tag, commit)
+	// git's own date format: seconds since the epoch and the UTC offset to show
+	cmd.Env = append(os.Environ(), fmt.Sprintf("GIT_COMMITTER_DATE=@%d %s", date.Unix(), date.Format("-0700")))
+	return runWithStderr(cmd)
+}
+
+func (execGitClient) TagMessage(tag string) (string, error) {
+	output, err := execCommand("git", "for-each-ref", "--format=%(objecttype)%0a%(contents:body)", "refs/tags/"+tag).Output()
+	if err != nil {
+		return "", err
+	}
+	objectType, body, _ := strings.Cut(string(output), "\n")
+	if objectType == "" {
+		return "", fmt.Errorf("tag '%s' not found", tag)
+	}
+	// The contents of a lightweight tag are those of the commit
+	if objectType != "tag" {
+		return "", nil
+	}
+	return strings.TrimSpace(body), nil
+}
+
+func (execGitClient) CreateTagObject(tag, commit, message string, date time.Time) (string, error) {
+	// The tagger is the committer, as git tag makes it, at date
+	cmd := execCommand("git", "var", "GIT_COMMITTER_IDENT")
+	cmd.Env = append(os.Environ(), fmt.Sprintf("GIT_COMMITTER_DATE=@%d %s", date.Unix(), date.Format("-0700")))
+	ident, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("reading the committer identity: %v", err)
+	}
+	object := fmt.Sprintf("object %s\ntype commit\ntag %s\ntagger %s\n\n%s\n", commit, tag, strings.TrimSpace(string(ident)), strings.TrimSpace(message))
+	cmd = execCommand("git", "mktag")
+	cmd.Stdin = strings.NewReader(object)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil && strings.TrimSpace(stderr.String()) != "" {
+		return "", fmt.Errorf("%s", lastLine(stderr.String()))
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (execGitClient) MoveTag(tag, object, oldObject string) error {
+	return runWithStderr(execCommand("git", "update-ref", "--create-reflog", "-m", "git-publish: retag", "refs/tags/"+tag, object, oldObject))
+}
+
+func (execGitClient) DeleteTag(tag string) error {
+	return execCommand("git", "tag", "-d", tag).Run()
+}
+
+func (execGitClient) CreateRef(ref, commit string) error {
+	// An empty old value makes update-ref refuse to overwrite an existing ref
+	return runWithStderr(execCommand("git", "update-ref", ref, commit, ""))
+}
+
+func (execGitClient) CreateBranch(branch, commit string) error {
+	return runWithStderr(execCommand("git", "branch", "--no-track", branch, commit))
+}
+
+func (execGitClient) AddWorktree(dir, commit string) error {
+	return runWithStderr(execCommand("git", "worktree", "add", "--quiet", "--detach", dir, commit))
+}
+
+func (execGitClient) RemoveWorktree(dir string) error {
+	return runWithStderr(execCommand("git", "worktree", "remove", "--force", dir))
+}
+
+func (execGitClient) Commits(from, to string) ([]string, error) {
+	rangeSpec := to
+	if from != "" {
+		rangeSpec = from + ".." + to
+	}
+	output, err := execCommand("git", "log", "--no-merges", "--format=%h %s", rangeSpec, "--").Output()
+	if err != nil {
+		return nil, err
+	}
+	return outputLines(output), nil
+}
+
+func (execGitClient) CommitMessages(from, to string) (map[string]string, error) {
+	rangeSpec := to
+	if from != "" {
+		rangeSpec = from + ".." + to
+	}
+	// -z ends every commit with a NUL, messages may contain any other character
+	output, err := execCommand("git", "log", "-z", "--no-merges", "--format=%h%n%B", rangeSpec, "--").Output()
+	if err != nil {
+		return nil, err
+	}
+	messages := map[string]string{}
+	for _, record := range strings.Split(string(output), "\x00") {
+		hash, message, _ := strings.Cut(strings.TrimLeft(record, "\n"), "\n")
+		if hash != "" {
+			messages[hash] = strings.TrimSpace(message)
+		}
+	}
+	return messages, nil
+}
+
+func (execGitClient) LogMessages(from, to string) ([]string, error) {
+	rangeSpec := to
+	if from != "" {
+		rangeSpec = from + ".." + to
+	}
+	output, err := execCommand("git", "log", "-z", "--format=%B", rangeSpec, "--").Output()
+	if err != nil {
+		return nil, err
+	}
+	var messages []string
+	for _, message := range strings.Split(string(output), "\x00") {
+		if message = strings.TrimSpace(message); message != "" {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func (execGitClient) CommitStats(from, to string) (commitStats, error) {
+	rangeSpec := to
+	if from != "" {
+		rangeSpec = from + ".." + to
+	}
+	// One walk of the range: a "commit <hash>" line, then "<email> <timestamp>"
+	output, err := execCommand("git", "rev-list", "--no-merges", "--format=%aE %ct", rangeSpec, "--").Output()
+	if err != nil {
+		return commitStats{}, err
+	}
+	var stats commitStats
+	authors := map[string]bool{}
+	for _, line := range outputLines(output) {
+		if strings.HasPrefix(line, "commit ") {
+			continue
+		}
+		email, timestamp, _ := strings.Cut(line, " ")
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return commitStats{}, fmt.Errorf("unexpected rev-list output %q", line)
+		}
+		stats.Count++
+		authors[strings.ToLower(email)] = true
+		if date := time.Unix(seconds, 0); stats.Oldest.IsZero() || date.Before(stats.Oldest) {
+			stats.Oldest = date
+		}
+	}
+	stats.Authors = len(authors)
+	return stats, nil
+}
+
+func (execGitClient) CommitSignatures(from, to string) ([]commitSignature, error) {
+	rangeSpec := to
+	if from != "" {
+		rangeSpec = from + ".." + to
+	}
+	// Fields are separated by the unit separator, which does not appear in subjects
+	output, err := execCommand("git", "log", "--no-merges", "--format=%h%x1f%G?%x1f%cE%x1f%s", rangeSpec, "--").Output()
+	if err != nil {
+		return nil, err
+	}
+	var signatures []commitSignature
+	for _, line := range outputLines(output) {
+		fields := strings.SplitN(line, "\x1f", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected log output %q", line)
+		}
+		signatures = append(signatures, commitSignature{Hash: fields[0], Status: fields[1], Committer: fields[2], Subject: fields[3]})
+	}
+	return signatures, nil
+}
+
+func (execGitClient) CommitDate(commit string) (time.Time, error) {
+	output, err := execCommand("git", "show", "-s", "--format=%ct", commit).Output()
+	if err != nil {
+		return time.Time{}, err
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading the date of commit %s: %v", commit, err)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+func (execGitClient) Push(args []string, sshKey string) error {
+	cmd := execCommand("git", append([]string{"push"}, args...)...)
+	if err := applySSHKey(cmd, sshKey); err != nil {
+		return err
+	}
+	return runWithStderr(cmd)
+}
+
+func (execGitClient) DefaultBranch(remote string) (string, error) {
+	// The remote-tracking HEAD is set by clone (and git remote set-head)
+	output, err := execCommand("git", "symbolic-ref", "--quiet", "refs/remotes/"+remote+"/HEAD").Output()
+	if err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(string(output)), "refs/remotes/"+remote+"/"), nil
+	}
+
+	// Otherwise ask the remote: "ref: refs/heads/main<TAB>HEAD"
+	output, err = execCommand("git", "ls-remote", "--symref", remote, "HEAD").Output()
+	if err != nil {
+		return "", err
+	}
+	for _, line := range outputLines(output) {
+		if fields := strings.Fields(line); len(fields) == 3 && fields[0] == "ref:" && fields[2] == "HEAD" {
+			return strings.TrimPrefix(fields[1], "refs/heads/"), nil
+		}
+	}
+	return "", fmt.Errorf("remote %s has no HEAD branch", remote)
+}
+
+func (execGitClient) RemoteTag(remote, tag string) (string, string, error) {
+	ref := "refs/tags/" + tag
+	// With --exit-code a remote without the tag exits with 2, which is no error here
+	output, err := execCommand("git", "ls-remote", "--exit-code", "--tags", remote, ref, ref+"^{}").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	// Annotated tags are listed twice: the ref line holds the tag object, the
+	// peeled ref^{} line the commit
+	var commit, object string
+	for _, line := range outputLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[1] {
+		case ref:
+			object = fields[0]
+		case ref + "^{}":
+			commit = fields[0]
+		}
+	}
+	if commit == "" {
+		commit = object
+	}
+	return commit, object, nil
+}
+
+func (execGitClient) RemoteTags(remote string) ([]string, error) {
+	output, err := execCommand("git", "ls-remote", "--tags", "--refs", remote).Output()
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, line := range outputLines(output) {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			tags = append(tags, strings.TrimPrefix(fields[1], "refs/tags/"))
+		}
+	}
+	return tags, nil
+}
+
+func (execGitClient) PushDryRun(remote, refspec, sshKey string, timeout time.Duration) error {
+	cmd := execCommand("git", "push", "--dry-run", "--no-verify", remote, refspec)
+	if err := applySSHKey(cmd, sshKey); err != nil {
+		return err
+	}
+	// Never block on credential prompts while probing
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "GIT_TERMINAL_PROMPT=0")
+
+	var output strings.Builder
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			if msg := strings.TrimSpace(output.String()); msg != "" {
+				return fmt.Errorf("%s", lastLine(msg))
+			}
+			return err
+		}
+		return nil
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+}
+
+func (execGitClient) CredentialPassword(host, path string) (string, error) {
+	cmd := execCommand("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\npath=" + path + "\n\n")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=true", "SSH_ASKPASS=true", "GCM_INTERACTIVE=never")
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if password, ok := strings.CutPrefix(line, "password="); ok {
+			return password, nil
+		}
+	}
+	return "", nil
+}
+
+func (execGitClient) GitPath(name string) (string, error) {
+	output, err := execCommand("git", "rev-parse", "--git-path", name).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (execGitClient) CountCommits(from, to string, paths []string) (int, error) {
+	args := append([]string{"rev-list", "--count", from + ".." + to, "--"}, paths...)
+	output, err := execCommand("git", args...).Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
+
+func (execGitClient) ChangedFiles(from, to string, paths []string) ([]fileChange, error) {
+	args := append([]string{"diff", "--name-status", "--no-renames", from, to, "--"}, paths...)
+	output, err := execCommand("git", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	var changes []fileChange
+	for _, line := range outputLines(output) {
+		if fields := strings.Split(line, "\t"); len(fields) == 2 {
+			changes = append(changes, fileChange{Status: fields[0], Path: fields[1]})
+		}
+	}
+	return changes, nil
+}
+
+func (execGitClient) ListTree(treeish string) ([]string, error) {
+	output, err := execCommand("git", "ls-tree", "--name-only", treeish).Output()
+	if err != nil {
+		return nil, err
+	}
+	return outputLines(output), nil
+}
+
+func (execGitClient) ListFiles(rev string) ([]string, error) {
+	output, err := execCommand("git", "ls-tree", "-r", "--name-only", rev).Output()
+	if err != nil {
+		return nil, err
+	}
+	return outputLines(output), nil
+}
+
+func (execGitClient) CurrentBranch() (string, error) {
+	output, err := execCommand("git", "symbolic-ref", "--short", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("HEAD is detached")
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (execGitClient) IsClean() (bool, error) {
+	output, err := execCommand("git", "status", "--porcelain", "--untracked-files=no").Output()
+	if err != nil {
+		return false, err
+	}
+	return len(strings.TrimSpace(string(output))) == 0, nil
+}
+
+func (execGitClient) HasStagedChanges() (bool, error) {
+	err := execCommand("git", "diff", "--cached", "--quiet").Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, err
+}
+
+func (execGitClient) TrackedFiles(pathspecs ...string) ([]string, error) {
+	output, err := execCommand("git", append([]string{"ls-files", "--"}, pathspecs...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	return outputLines(output), nil
+}
+
+func (execGitClient) StageGitlink(path, commit string) error {
+	return runWithStderr(execCommand("git", "update-index", "--cacheinfo", "160000,"+commit+","+path))
+}
+
+func (execGitClient) Commit(message string, paths ...string) error {
+	args := []string{"commit", "-m", message}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+	cmd := execCommand("git", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (execGitClient) CreateSignedTag(tag, commit, message string, date time.Time, signer tagSigner) error {
+	args := append(append(append([]string{}, signer.Config...), "tag", "--create-reflog", "-m", message), signer.Key...)
+	cmd := execCommand("git", append(args, tag, commit)...)
+	cmd.Env = append(os.Environ(), committerDateEnv(date))
+	return runWithStderr(cmd)
+}
+
+// SignedTagObject signs the tag object with git tag in a scratch repository
+// that writes its objects to this one and includes its config, so the key and
+// format of signing apply while the tag itself is untouched
+func (execGitClient) SignedTagObject(tag, commit, message string, date time.Time, signer tagSigner) (string, error) {
+	output, err := execCommand("git", "rev-parse", "--git-common-dir").Output()
+	if err != nil {
+		return "", fmt.Errorf("finding the repository: %v", err)
+	}
+	common, err := filepath.Abs(strings.TrimSpace(string(output)))
+	if err != nil {
+		return "", err
+	}
+	scratch, err := os.MkdirTemp("", "git-publish-tag-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(scratch)
+	if err := runWithStderr(execCommand("git", "init", "-q", "--bare", scratch)); err != nil {
+		return "", err
+	}
+	if err := runWithStderr(execCommand("git", "config", "--file", filepath.Join(scratch, "config"), "include.path", filepath.Join(common, "config"))); err != nil {
+		return "", err
+	}
+
+	args := append(append(append([]string{}, signer.Config...), "tag", "-m", message), signer.Key...)
+	cmd := execCommand("git", append(args, tag, commit)...)
+	cmd.Env = append(os.Environ(), "GIT_DIR="+scratch, "GIT_OBJECT_DIRECTORY="+filepath.Join(common, "objects"), committerDateEnv(date))
+	if err := runWithStderr(cmd); err != nil {
+		return "", err
+	}
+	output, err = execCommand("git", "--git-dir", scratch, "rev-parse", "refs/tags/"+tag).Output()
+	if err != nil {
+		return "", fmt.Errorf("reading the signed tag: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (execGitClient) VerifyTag(object string, config []string) error {
+	output, err := execCommand("git", append(append([]string{}, config...), "verify-tag", object)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s", lastLine(string(output)))
+	}
+	return nil
+}
+
+func (execGitClient) Clone(url, dir string) error {
+	return runWithStderr(execCommand("git", "clone", "--quiet", "--no-checkout", url, dir))
+}
+
+func (execGitClient) SetRemoteHead(remote string) error {
+	return runWithStderr(execCommand("git", "remote", "set-head", remote, "--auto"))
+}
+
+func (execGitClient) CheckoutDetached(rev string) error {
+	return runWithStderr(execCommand("git", "checkout", "--quiet", "--detach", rev))
+}
+
+func (execGitClient) Checkout(branch string) error {
+	cmd := execCommand("git", "checkout", branch)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (execGitClient) Merge(branch, message string) error {
+	cmd := execCommand("git", "merge", "--no-ff", "-m", message, branch)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (execGitClient) SubmodulePaths() ([]string, error) {
+	output, err := execCommand("git", "config", "--null", "--file", ".gitmodules", "--get-regexp", `^submodule\..*\.path$`).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseSubmodulePaths(output), nil
+}
+
+func (execGitClient) IsPartialClone() bool {
+	output, err := execCommand("git", "config", "--get-regexp", `^remote\..*\.promisor$`).Output()
+	if err == nil {
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			if _, value, ok := strings.Cut(line, " "); ok && value == "true" {
+				return true
+			}
+		}
+	}
+	partial, err := execCommand("git", "config", "--get", "extensions.partialClone").Output()
+	return err == nil && strings.TrimSpace(string(partial)) != ""
+}
+
+// ReadBlobs reads all objects in a single git cat-file process
+func (execGitClient) ReadBlobs(specs []string) (map[string][]byte, error) {
+	contents := make(map[string][]byte)
+	if len(specs) == 0 {
+		return contents, nil
+	}
+
+	var stdout bytes.Buffer
+	cmd := execCommand("git", "cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(strings.Join(specs, "\n") + "\n")
+	cmd.Stdout = &stdout
+	if err := runWithStderr(cmd); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(&stdout)
+	for _, spec := range specs {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", spec, err)
+		}
+		fields := strings.Fields(header)
+		if len(fields) == 2 && fields[1] == "missing" {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("reading %s: unexpected cat-file output %q", spec, strings.TrimSpace(header))
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: unexpected cat-file output %q", spec, strings.TrimSpace(header))
+		}
+		data := make([]byte, size+1)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, fmt.Errorf("reading %s: %v", spec, err)
+		}
+		if fields[1] == "blob" {
+			contents[spec] = data[:size]
+		}
+	}
+	return contents, nil
+}
+
+func (execGitClient) ReadIndexFile(path string) ([]byte, error) {
+	var stdout bytes.Buffer
+	cmd := execCommand("git", "show", ":"+path)
+	cmd.Stdout = &stdout
+	if err := runWithStderr(cmd); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+func (execGitClient) SkipWorktreeFiles(files []string) ([]string, error) {
+	output, err := execCommand("git", append([]string{"ls-files", "-t", "--full-name", "--"}, files...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+	var skipped []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if status, file, ok := strings.Cut(line, " "); ok && status == "S" {
+			skipped = append(skipped, file)
+		}
+	}
+	return skipped, nil
+}
+
+// runWithStderr runs cmd and, if it fails, returns the last line git wrote to
+// stderr as the error, which says more than the exit status
+func runWithStderr(cmd *exec.Cmd) error {
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil && strings.TrimSpace(stderr.String()) != "" {
+		return fmt.Errorf("%s", lastLine(stderr.String()))
+	}
+	return err
+}
+
+func (execGitClient) ConfigValue(key string) (string, error) {
+	output, err := execCommand("git", "config", "--get", key).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil
+	}
+	return strings.TrimSpace(string(output)), err
+}
+
+// outputLines splits command output into its non-empty, trimmed lines
+func outputLines(output []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
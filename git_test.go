@@ -0,0 +1,1225 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGitClient is an in-memory GitClient. Commits are named by their hash and
+// form a history through parents; refs point at commits.
+type fakeGitClient struct {
+	root           string
+	parents        map[string]string
+	branches       map[string]string
+	remoteBranches map[string]string
+	tags           map[string]string
+	// refs are other refs by full name, e.g. refs/releases/v1.0.0
+	refs    map[string]string
+	remotes map[string]string
+	config  map[string]string
+	// defaultBranch is the HEAD branch of every remote
+	defaultBranch string
+	// commitDates are the committer dates of commits, the zero time if missing
+	commitDates map[string]time.Time
+	// tagDates are the dates of annotated tags
+	tagDates map[string]time.Time
+	// tagMessages are the messages of annotated tags
+	tagMessages map[string]string
+	// subjects are the commit subjects, "" if missing
+	subjects map[string]string
+	// bodies are the commit messages after the subject, "" if missing
+	bodies map[string]string
+	// authors are the author (and committer) emails of commits, "" if missing
+	authors map[string]string
+	// signatures are the %G? signature statuses of commits, N if missing
+	signatures map[string]string
+	// remoteTags are the commits of the tags on the remotes, keyed by "remote/tag"
+	remoteTags map[string]string
+	// remoteTagObjects are the ref values of annotated remote tags, keyed
+	// like remoteTags; other tags hold their commit
+	remoteTagObjects map[string]string
+	// tagObjects are the annotated tag objects written without a ref, by name
+	tagObjects map[string]fakeTagObject
+	// worktrees are the commits checked out in the added work trees, by directory
+	worktrees map[string]string
+	// files are the contents of the files of commits by path, none if missing
+	files map[string]map[string]string
+	// current is the checked-out branch, "" for a detached HEAD
+	current string
+	// dirty marks changes of tracked files in the working tree
+	dirty bool
+	// staged are the submodule commits recorded in the index, by path
+	staged map[string]string
+	// submodules are the paths declared in .gitmodules
+	submodules []string
+	// gitDir is the git directory, "" outside a repository
+	gitDir string
+	// credentials are the passwords of the credential helpers, keyed by "host/path"
+	credentials map[string]string
+	// pushDryRunErrs fails dry-run pushes to single remotes
+	pushDryRunErrs map[string]error
+	// commitMessages are the messages of the commits made, oldest first
+	commitMessages []string
+	// partialClone marks a repository with a promisor remote
+	partialClone bool
+	// skipWorktree are the files of the index left out of the work tree
+	skipWorktree []string
+	// head is the commit of a detached HEAD
+	head string
+	// clones are the URLs cloned, by directory
+	clones map[string]string
+	// tagSigners are the signers of the signed tags and tag objects, by name
+	tagSigners map[string]tagSigner
+	// verifyErr fails the verification of signatures
+	verifyErr error
+
+	fetches [][]string
+	pushes  [][]string
+	pushErr error
+	// pushErrs fails pushes to single remotes
+	pushErrs map[string]error
+}
+
+// fakeTagObject is an annotated tag object of the fake repository
+type fakeTagObject struct {
+	Commit  string
+	Message string
+	Date    time.Time
+}
+
+// newFakeGitClient returns a fake repository with a linear history of commits
+// (oldest first) and main pointing at the last one
+func newFakeGitClient(commits ...string) *fakeGitClient {
+	g := &fakeGitClient{
+		parents:        map[string]string{},
+		branches:       map[string]string{},
+		remoteBranches: map[string]string{},
+		tags:           map[string]string{},
+		refs:           map[string]string{},
+		remotes:        map[string]string{},
+		config:         map[string]string{},
+		commitDates:    map[string]time.Time{},
+		tagDates:       map[string]time.Time{},
+		tagMessages:    map[string]string{},
+		remoteTags:     map[string]string{},
+		authors:        map[string]string{},
+		signatures:     map[string]string{},
+		bodies:         map[string]string{},
+		subjects:       map[string]string{},
+		files:          map[string]map[string]string{},
+	}
+	parent := ""
+	for _, commit := range commits {
+		g.parents[commit] = parent
+		parent = commit
+	}
+	if parent != "" {
+		g.branches["main"] = parent
+		g.current = "main"
+	}
+	return g
+}
+
+// useFakeGit makes the publish flow use g for the rest of the test
+func useFakeGit(t *testing.T, g *fakeGitClient) {
+	original := gitClient
+	gitClient = g
+	t.Cleanup(func() { gitClient = original })
+}
+
+func (g *fakeGitClient) IsRepository() bool { return true }
+
+func (g *fakeGitClient) TopLevel() (string, error) {
+	if g.root == "" {
+		return "", fmt.Errorf("not in a work tree")
+	}
+	return g.root, nil
+}
+
+func (g *fakeGitClient) Refs(patterns ...string) ([]string, error) {
+	var all []string
+	for _, branch := range sortedKeys(g.branches) {
+		all = append(all, "refs/heads/"+branch)
+	}
+	for _, branch := range sortedKeys(g.remoteBranches) {
+		all = append(all, "refs/remotes/"+branch)
+	}
+
+	// Like for-each-ref: a glob without crossing slashes, or a prefix ending at a slash
+	var refs []string
+	for _, ref := range all {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, ref); matched || ref == pattern || strings.HasPrefix(ref, pattern+"/") {
+				refs = append(refs, ref)
+				break
+			}
+		}
+	}
+	return refs, nil
+}
+
+func (g *fakeGitClient) Remotes() (map[string]string, error) { return g.remotes, nil }
+
+func (g *fakeGitClient) Fetch(args ...string) error {
+	g.fetches = append(g.fetches, args)
+	return nil
+}
+
+func (g *fakeGitClient) UpdateRemote(remote string) error { return nil }
+
+func (g *fakeGitClient) ListTags(pattern string) ([]string, error) {
+	var tags []string
+	for tag := range g.tags {
+		if matched, _ := path.Match(pattern, tag); pattern == "" || matched {
+			tags = append(tags, tag)
+		}
+	}
+	// Like --sort=-v:refname; tags with different prefixes are ordered by prefix
+	sort.Slice(tags, func(i, j int) bool {
+		if pi, pj := extractPrefix(tags[i]), extractPrefix(tags[j]); pi != pj {
+			return pi > pj
+		}
+		return isTagVersionGreater(tags[i], tags[j])
+	})
+	return tags, nil
+}
+
+func (g *fakeGitClient) RevParse(rev string) (string, error) {
+	name := strings.TrimSuffix(rev, "^{commit}")
+	if name == "HEAD" && g.current != "" {
+		name = g.current
+	} else if name == "HEAD" && g.head != "" {
+		name = g.head
+	}
+	if treeish, file, ok := strings.Cut(name, ":"); ok {
+		if _, err := g.blob(treeish, file); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+	switch {
+	case strings.HasPrefix(name, "refs/tags/"):
+		if commit, ok := g.tags[strings.TrimPrefix(name, "refs/tags/")]; ok {
+			return commit, nil
+		}
+	case strings.HasPrefix(name, "refs/heads/") && g.branches[strings.TrimPrefix(name, "refs/heads/")] != "":
+		return g.branches[strings.TrimPrefix(name, "refs/heads/")], nil
+	case strings.HasPrefix(name, "refs/remotes/") && g.remoteBranches[strings.TrimPrefix(name, "refs/remotes/")] != "":
+		return g.remoteBranches[strings.TrimPrefix(name, "refs/remotes/")], nil
+	case g.branches[name] != "":
+		return g.branches[name], nil
+	case g.remoteBranches[name] != "":
+		return g.remoteBranches[name], nil
+	case g.tags[name] != "":
+		return g.tags[name], nil
+	case g.refs[name] != "":
+		return g.refs[name], nil
+	default:
+		if _, ok := g.parents[name]; ok {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unknown revision %s", rev)
+}
+
+func (g *fakeGitClient) IsAncestor(ancestor, commit string) (bool, error) {
+	for ; commit != ""; commit = g.parents[commit] {
+		if commit == ancestor {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (g *fakeGitClient) CreateTag(tag, commit string) error {
+	if _, exists := g.tags[tag]; exists {
+		return fmt.Errorf("tag '%s' already exists", tag)
+	}
+	if _, ok := g.parents[commit]; !ok {
+		return fmt.Errorf("unknown commit %s", commit)
+	}
+	g.tags[tag] = commit
+	return nil
+}
+
+func (g *fakeGitClient) CreateAnnotatedTag(tag, commit, message string, date time.Time) error {
+	if err := g.CreateTag(tag, commit); err != nil {
+		return err
+	}
+	g.tagDates[tag] = date
+	g.tagMessages[tag] = message
+	return nil
+}
+
+func (g *fakeGitClient) CreateSignedTag(tag, commit, message string, date time.Time, signer tagSigner) error {
+	if err := g.CreateAnnotatedTag(tag, commit, message, date); err != nil {
+		return err
+	}
+	g.recordSigner(tag, signer)
+	return nil
+}
+
+func (g *fakeGitClient) SignedTagObject(tag, commit, message string, date time.Time, signer tagSigner) (string, error) {
+	object, err := g.CreateTagObject(tag, commit, message, date)
+	if err != nil {
+		return "", err
+	}
+	g.recordSigner(object, signer)
+	return object, nil
+}
+
+// VerifyTag accepts the signed tags and tag objects unless verifyErr is set
+func (g *fakeGitClient) VerifyTag(object string, config []string) error {
+	if _, ok := g.tagSigners[strings.TrimPrefix(object, "refs/tags/")]; !ok {
+		return fmt.Errorf("error: no signature found")
+	}
+	return g.verifyErr
+}
+
+func (g *fakeGitClient) recordSigner(name string, signer tagSigner) {
+	if g.tagSigners == nil {
+		g.tagSigners = map[string]tagSigner{}
+	}
+	g.tagSigners[name] = signer
+}
+
+func (g *fakeGitClient) TagMessage(tag string) (string, error) {
+	if _, exists := g.tags[tag]; !exists {
+		return "", fmt.Errorf("tag '%s' not found", tag)
+	}
+	return g.tagMessages[tag], nil
+}
+
+func (g *fakeGitClient) Commits(from, to string) ([]string, error) {
+	tip, err := g.RevParse(to)
+	if err != nil {
+		return nil, err
+	}
+	base := ""
+	if from != "" {
+		if base, err = g.RevParse(from); err != nil {
+			return nil, err
+		}
+	}
+
+	var commits []string
+	for commit := tip; commit != ""; commit = g.parents[commit] {
+		if reachable, _ := g.IsAncestor(commit, base); reachable {
+			break
+		}
+		commits = append(commits, strings.TrimSpace(commit+" "+g.subjects[commit]))
+	}
+	return commits, nil
+}
+
+func (g *fakeGitClient) CommitMessages(from, to string) (map[string]string, error) {
+	commits, err := g.Commits(from, to)
+	if err != nil {
+		return nil, err
+	}
+	messages := map[string]string{}
+	for _, commit := range commits {
+		hash, _, _ := strings.Cut(commit, " ")
+		messages[hash] = strings.TrimSpace(g.subjects[hash] + "\n\n" + g.bodies[hash])
+	}
+	return messages, nil
+}
+
+// LogMessages lists the messages of the first-parent history, as the fake
+// history has no merges
+func (g *fakeGitClient) LogMessages(from, to string) ([]string, error) {
+	commits, err := g.Commits(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var messages []string
+	for _, commit := range commits {
+		hash, _, _ := strings.Cut(commit, " ")
+		messages = append(messages, strings.TrimSpace(g.subjects[hash]+"\n\n"+g.bodies[hash]))
+	}
+	return messages, nil
+}
+
+func (g *fakeGitClient) CommitStats(from, to string) (commitStats, error) {
+	commits, err := g.Commits(from, to)
+	if err != nil {
+		return commitStats{}, err
+	}
+	var stats commitStats
+	authors := map[string]bool{}
+	for _, commit := range commits {
+		hash, _, _ := strings.Cut(commit, " ")
+		stats.Count++
+		authors[g.authors[hash]] = true
+		if date := g.commitDates[hash]; stats.Oldest.IsZero() || date.Before(stats.Oldest) {
+			stats.Oldest = date
+		}
+	}
+	stats.Authors = len(authors)
+	return stats, nil
+}
+
+func (g *fakeGitClient) CommitSignatures(from, to string) ([]commitSignature, error) {
+	commits, err := g.Commits(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var signatures []commitSignature
+	for _, commit := range commits {
+		hash, subject, _ := strings.Cut(commit, " ")
+		status := g.signatures[hash]
+		if status == "" {
+			status = "N"
+		}
+		signatures = append(signatures, commitSignature{Hash: hash, Subject: subject, Committer: g.authors[hash], Status: status})
+	}
+	return signatures, nil
+}
+
+func (g *fakeGitClient) CommitDate(commit string) (time.Time, error) {
+	if _, ok := g.parents[commit]; !ok {
+		return time.Time{}, fmt.Errorf("unknown commit %s", commit)
+	}
+	return g.commitDates[commit], nil
+}
+
+func (g *fakeGitClient) CreateRef(ref, commit string) error {
+	if _, exists := g.refs[ref]; exists {
+		return fmt.Errorf("%s already exists", ref)
+	}
+	g.refs[ref] = commit
+	return nil
+}
+
+func (g *fakeGitClient) CreateBranch(branch, commit string) error {
+	if _, exists := g.branches[branch]; exists {
+		return fmt.Errorf("a branch named '%s' already exists", branch)
+	}
+	if _, ok := g.parents[commit]; !ok {
+		return fmt.Errorf("unknown commit %s", commit)
+	}
+	g.branches[branch] = commit
+	return nil
+}
+
+// AddWorktree creates dir, which stays empty: the fake repository has no files
+func (g *fakeGitClient) AddWorktree(dir, commit string) error {
+	if _, ok := g.parents[commit]; !ok {
+		return fmt.Errorf("unknown commit %s", commit)
+	}
+	if _, exists := g.worktrees[dir]; exists {
+		return fmt.Errorf("'%s' already exists", dir)
+	}
+	if g.worktrees == nil {
+		g.worktrees = map[string]string{}
+	}
+	g.worktrees[dir] = commit
+	return os.MkdirAll(dir, 0755)
+}
+
+func (g *fakeGitClient) RemoveWorktree(dir string) error {
+	if _, exists := g.worktrees[dir]; !exists {
+		return fmt.Errorf("'%s' is not a working tree", dir)
+	}
+	delete(g.worktrees, dir)
+	return os.RemoveAll(dir)
+}
+
+func (g *fakeGitClient) CreateTagObject(tag, commit, message string, date time.Time) (string, error) {
+	if _, ok := g.parents[commit]; !ok {
+		return "", fmt.Errorf("unknown commit %s", commit)
+	}
+	if g.tagObjects == nil {
+		g.tagObjects = map[string]fakeTagObject{}
+	}
+	object := fmt.Sprintf("tag-object-%d", len(g.tagObjects)+1)
+	g.tagObjects[object] = fakeTagObject{Commit: commit, Message: message, Date: date}
+	return object, nil
+}
+
+// MoveTag compares oldObject with the commit of the tag, as the fake
+// repository resolves tags to commits
+func (g *fakeGitClient) MoveTag(tag, object, oldObject string) error {
+	if current, exists := g.tags[tag]; !exists || current != oldObject {
+		return fmt.Errorf("cannot lock ref 'refs/tags/%s': is at %s but expected %s", tag, current, oldObject)
+	}
+	if annotated, ok := g.tagObjects[object]; ok {
+		g.tags[tag], g.tagMessages[tag], g.tagDates[tag] = annotated.Commit, annotated.Message, annotated.Date
+		return nil
+	}
+	if _, ok := g.parents[object]; !ok {
+		return fmt.Errorf("unknown commit %s", object)
+	}
+	g.tags[tag] = object
+	delete(g.tagMessages, tag)
+	return nil
+}
+
+func (g *fakeGitClient) DeleteTag(tag string) error {
+	if _, exists := g.tags[tag]; !exists {
+		return fmt.Errorf("tag '%s' not found", tag)
+	}
+	delete(g.tags, tag)
+	return nil
+}
+
+func (g *fakeGitClient) Push(args []string, sshKey string) error {
+	g.pushes = append(g.pushes, args)
+	for _, arg := range args {
+		if err, ok := g.pushErrs[arg]; ok {
+			return err
+		}
+	}
+	return g.pushErr
+}
+
+func (g *fakeGitClient) ConfigValue(key string) (string, error) { return g.config[key], nil }
+
+func (g *fakeGitClient) DefaultBranch(remote string) (string, error) {
+	if g.defaultBranch == "" {
+		return "", fmt.Errorf("remote %s has no HEAD branch", remote)
+	}
+	return g.defaultBranch, nil
+}
+
+func (g *fakeGitClient) RemoteTag(remote, tag string) (string, string, error) {
+	if _, ok := g.remotes[remote]; !ok {
+		return "", "", fmt.Errorf("remote %s not found", remote)
+	}
+	commit := g.remoteTags[remote+"/"+tag]
+	if object := g.remoteTagObjects[remote+"/"+tag]; object != "" {
+		return commit, object, nil
+	}
+	return commit, commit, nil
+}
+
+func (g *fakeGitClient) RemoteTags(remote string) ([]string, error) {
+	if _, ok := g.remotes[remote]; !ok {
+		return nil, fmt.Errorf("remote %s not found", remote)
+	}
+	var tags []string
+	for _, key := range sortedKeys(g.remoteTags) {
+		if tag, ok := strings.CutPrefix(key, remote+"/"); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (g *fakeGitClient) PushDryRun(remote, refspec, sshKey string, timeout time.Duration) error {
+	if _, ok := g.remotes[remote]; !ok {
+		return fmt.Errorf("'%s' does not appear to be a git repository", remote)
+	}
+	return g.pushDryRunErrs[remote]
+}
+
+func (g *fakeGitClient) CredentialPassword(host, path string) (string, error) {
+	return g.credentials[host+"/"+path], nil
+}
+
+func (g *fakeGitClient) GitPath(name string) (string, error) {
+	if g.gitDir == "" {
+		return "", fmt.Errorf("not a git repository")
+	}
+	return filepath.Join(g.gitDir, name), nil
+}
+
+// CountCommits counts the commits whose files under paths differ from those of their parent
+func (g *fakeGitClient) CountCommits(from, to string, paths []string) (int, error) {
+	commits, err := g.Commits(from, to)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, commit := range commits {
+		hash, _, _ := strings.Cut(commit, " ")
+		if len(paths) == 0 || len(g.diff(g.parents[hash], hash, paths)) > 0 {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (g *fakeGitClient) ChangedFiles(from, to string, paths []string) ([]fileChange, error) {
+	a, err := g.RevParse(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := g.RevParse(to)
+	if err != nil {
+		return nil, err
+	}
+	return g.diff(a, b, paths), nil
+}
+
+// diff compares the files of two commits under paths, like git diff --name-status
+func (g *fakeGitClient) diff(a, b string, paths []string) []fileChange {
+	var changes []fileChange
+	for _, file := range sortedKeys(mergedFiles(g.files[a], g.files[b])) {
+		if len(paths) > 0 && !underAnyPath(file, paths) {
+			continue
+		}
+		before, inA := g.files[a][file]
+		after, inB := g.files[b][file]
+		switch {
+		case !inA:
+			changes = append(changes, fileChange{Status: "A", Path: file})
+		case !inB:
+			changes = append(changes, fileChange{Status: "D", Path: file})
+		case before != after:
+			changes = append(changes, fileChange{Status: "M", Path: file})
+		}
+	}
+	return changes
+}
+
+// ListTree lists the files and directories directly in the tree of rev:dir
+func (g *fakeGitClient) ListTree(treeish string) ([]string, error) {
+	rev, dir, _ := strings.Cut(treeish, ":")
+	commit, err := g.RevParse(rev)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range sortedKeys(g.files[commit]) {
+		if dir != "" {
+			var ok bool
+			if file, ok = strings.CutPrefix(file, strings.TrimSuffix(dir, "/")+"/"); !ok {
+				continue
+			}
+		}
+		name, _, _ := strings.Cut(file, "/")
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && dir != "" {
+		return nil, fmt.Errorf("not a tree object: %s", treeish)
+	}
+	return names, nil
+}
+
+func (g *fakeGitClient) ListFiles(rev string) ([]string, error) {
+	commit, err := g.RevParse(rev)
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(g.files[commit]), nil
+}
+
+func (g *fakeGitClient) CurrentBranch() (string, error) {
+	if g.current == "" {
+		return "", fmt.Errorf("HEAD is detached")
+	}
+	return g.current, nil
+}
+
+func (g *fakeGitClient) IsClean() (bool, error) { return !g.dirty && len(g.staged) == 0, nil }
+
+func (g *fakeGitClient) HasStagedChanges() (bool, error) { return len(g.staged) > 0, nil }
+
+func (g *fakeGitClient) TrackedFiles(pathspecs ...string) ([]string, error) {
+	var files []string
+	for _, file := range sortedKeys(g.files[g.branches[g.current]]) {
+		for _, pathspec := range pathspecs {
+			if matched, _ := path.Match(pathspec, file); matched || file == pathspec {
+				files = append(files, file)
+				break
+			}
+		}
+	}
+	return files, nil
+}
+
+func (g *fakeGitClient) StageGitlink(path, commit string) error {
+	if g.staged == nil {
+		g.staged = map[string]string{}
+	}
+	g.staged[path] = commit
+	return nil
+}
+
+// Commit adds a commit on the checked-out branch with the same files, as the
+// fake repository has no working tree
+func (g *fakeGitClient) Commit(message string, paths ...string) error {
+	if g.current == "" {
+		return fmt.Errorf("HEAD is detached")
+	}
+	if len(paths) == 0 && len(g.staged) == 0 {
+		return fmt.Errorf("nothing to commit")
+	}
+	g.staged = nil
+	g.addCommit(message)
+	return nil
+}
+
+// Clone records the clone and creates its git directory
+func (g *fakeGitClient) Clone(url, dir string) error {
+	if g.clones == nil {
+		g.clones = map[string]string{}
+	}
+	g.clones[dir] = url
+	return os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+}
+
+func (g *fakeGitClient) SetRemoteHead(remote string) error {
+	branch, err := g.DefaultBranch(remote)
+	if err != nil {
+		return err
+	}
+	g.remoteBranches[remote+"/HEAD"] = g.remoteBranches[remote+"/"+branch]
+	return nil
+}
+
+func (g *fakeGitClient) CheckoutDetached(rev string) error {
+	commit, err := g.RevParse(rev)
+	if err != nil {
+		return err
+	}
+	g.current, g.head = "", commit
+	return nil
+}
+
+func (g *fakeGitClient) Checkout(branch string) error {
+	if _, ok := g.branches[branch]; !ok {
+		return fmt.Errorf("pathspec '%s' did not match any file(s) known to git", branch)
+	}
+	g.current, g.head = branch, ""
+	return nil
+}
+
+// Merge adds a commit with the files of branch on the checked-out branch; the
+// fake history keeps only its first parent
+func (g *fakeGitClient) Merge(branch, message string) error {
+	tip, ok := g.branches[branch]
+	if !ok || g.current == "" {
+		return fmt.Errorf("merge: %s - not something we can merge", branch)
+	}
+	g.addCommit(message)
+	if files, ok := g.files[tip]; ok {
+		g.files[g.branches[g.current]] = files
+	}
+	return nil
+}
+
+// addCommit adds a commit with the files of its parent on the checked-out branch
+func (g *fakeGitClient) addCommit(message string) {
+	parent := g.branches[g.current]
+	commit := fmt.Sprintf("commit-%d", len(g.commitMessages)+1)
+	g.parents[commit] = parent
+	if files, ok := g.files[parent]; ok {
+		g.files[commit] = files
+	}
+	g.subjects[commit], _, _ = strings.Cut(message, "\n")
+	g.branches[g.current] = commit
+	g.commitMessages = append(g.commitMessages, message)
+}
+
+func (g *fakeGitClient) SubmodulePaths() ([]string, error) {
+	if len(g.submodules) == 0 {
+		return nil, fmt.Errorf("no .gitmodules")
+	}
+	return g.submodules, nil
+}
+
+func (g *fakeGitClient) IsPartialClone() bool { return g.partialClone }
+
+func (g *fakeGitClient) ReadBlobs(specs []string) (map[string][]byte, error) {
+	contents := make(map[string][]byte)
+	for _, spec := range specs {
+		rev, file, _ := strings.Cut(spec, ":")
+		if content, err := g.blob(rev, file); err == nil {
+			contents[spec] = []byte(content)
+		}
+	}
+	return contents, nil
+}
+
+// ReadIndexFile reads the file from the checked-out commit, as the fake
+// repository has no index of its own
+func (g *fakeGitClient) ReadIndexFile(path string) ([]byte, error) {
+	content, err := g.blob(g.branches[g.current], path)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+func (g *fakeGitClient) SkipWorktreeFiles(files []string) ([]string, error) {
+	var skipped []string
+	for _, file := range files {
+		if contains(g.skipWorktree, file) {
+			skipped = append(skipped, file)
+		}
+	}
+	return skipped, nil
+}
+
+// blob returns the contents of a file of a commit
+func (g *fakeGitClient) blob(rev, file string) (string, error) {
+	commit, err := g.RevParse(rev)
+	if err != nil {
+		return "", err
+	}
+	content, ok := g.files[commit][file]
+	if !ok {
+		return "", fmt.Errorf("path '%s' does not exist in '%s'", file, rev)
+	}
+	return content, nil
+}
+
+// mergedFiles returns the union of the files of two commits
+func mergedFiles(a, b map[string]string) map[string]string {
+	merged := map[string]string{}
+	for file, content := range a {
+		merged[file] = content
+	}
+	for file, content := range b {
+		merged[file] = content
+	}
+	return merged
+}
+
+// underAnyPath reports whether file is one of paths or in one of their directories
+func underAnyPath(file string, paths []string) bool {
+	for _, p := range paths {
+		if p = strings.TrimSuffix(p, "/"); file == p || strings.HasPrefix(file, p+"/") || p == "." {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestOutputLines(t *testing.T) {
+	got := outputLines([]byte("* main\n  develop\n\n"))
+	want := []string{"* main", "develop"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("outputLines() = %q, want %q", got, want)
+	}
+}
+
+func TestGetLastTagWithFakeGit(t *testing.T) {
+	// a - b - c (main)
+	//      \
+	//       d (gray)
+	g := newFakeGitClient("a", "b", "c")
+	g.parents["d"] = "b"
+	g.branches["gray"] = "d"
+	g.tags = map[string]string{"v1.0.0": "a", "v1.0.1": "c", "g1.9.9": "b", "g1.9.10": "d", "v2.0.0": "d",
+		"1.4.0": "b", "2024.06.01": "c", "2024-backup": "c"}
+	g.tagMessages = map[string]string{"v1.0.0": toolTagTrailer, "g1.9.9": "Hotfix"}
+	useFakeGit(t, g)
+
+	tests := []struct {
+		branch     string
+		tagFormat  string
+		onlyMarked bool
+		want       string
+	}{
+		{"main", "v0.0.0", false, "v1.0.1"},
+		{"gray", "v0.0.0", false, "v2.0.0"},
+		{"gray", "g0.0.0", false, "g1.9.10"},
+		{"main", "g0.0.0", false, "g1.9.9"},
+		{"missing", "v0.0.0", false, ""},
+		{"main", "v0.0.0", true, "v1.0.0"},
+		{"main", "g0.0.0", true, ""},
+		{"main", "0.0.0", false, "1.4.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%s/%v", tt.branch, tt.tagFormat, tt.onlyMarked), func(t *testing.T) {
+			if got := getLastTag(tt.branch, tt.tagFormat, tt.onlyMarked); got != tt.want {
+				t.Errorf("getLastTag(%q, %q, %v) = %q, want %q", tt.branch, tt.tagFormat, tt.onlyMarked, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetConfiguredBranchesWithFakeGit(t *testing.T) {
+	g := newFakeGitClient("a")
+	g.branches["feature"] = "a"
+	g.branches["main/old"] = "a"
+	g.remoteBranches = map[string]string{"origin/main": "a", "origin/gray": "a", "origin/other": "a", "upstream/release/1.x": "a"}
+	useFakeGit(t, g)
+
+	got := getConfiguredBranches([]string{"main", "gray", "develop", "release/1.x"})
+	sort.Strings(got)
+	want := []string{"gray", "main", "release/1.x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getConfiguredBranches() = %v, want %v", got, want)
+	}
+}
+
+func TestLookupCommitWithFakeGit(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.remoteBranches["origin/gray"] = "a"
+	useFakeGit(t, g)
+
+	if commit, err := lookupCommit("main"); err != nil || commit != "b" {
+		t.Errorf("lookupCommit(main) = %q, %v, want b", commit, err)
+	}
+	// Branches that only exist on the remote resolve to the remote-tracking branch
+	if commit, err := lookupCommit("gray"); err != nil || commit != "a" {
+		t.Errorf("lookupCommit(gray) = %q, %v, want a", commit, err)
+	}
+	if _, err := lookupCommit("missing"); err == nil {
+		t.Error("lookupCommit(missing) succeeded, want an error")
+	}
+}
+
+func TestBranchRef(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.branches["release/1.2"] = "a"
+	g.remoteBranches = map[string]string{
+		"origin/release/1.3":      "a",
+		"upstream/release/1.3":    "b",
+		"upstream/release/1.4/rc": "a",
+		"upstream/hotfix/2.0":     "b",
+		"origin/main":             "a",
+	}
+	useFakeGit(t, g)
+
+	tests := []struct {
+		branch string
+		want   string
+	}{
+		{"main", "refs/heads/main"},
+		{"release/1.2", "refs/heads/release/1.2"},
+		{"release/1.3", "refs/remotes/origin/release/1.3"},
+		{"hotfix/2.0", "refs/remotes/upstream/hotfix/2.0"},
+		{"release/1.4", "release/1.4"},
+		{"missing", "missing"},
+	}
+	for _, tt := range tests {
+		if got := branchRef(tt.branch); got != tt.want {
+			t.Errorf("branchRef(%s) = %s, want %s", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestCreateAndPushTagWithFakeGit(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.remotes["origin"] = "git@github.com:owner/repo.git"
+	useFakeGit(t, g)
+
+	if err := createTag("b", "v1.0.0"); err != nil {
+		t.Fatalf("createTag() error = %v", err)
+	}
+	if !tagExists("v1.0.0") || !isTagOnBranch("v1.0.0", "main") {
+		t.Errorf("tag v1.0.0 was not created on main: %v", g.tags)
+	}
+	if err := createTag("b", "v1.0.0"); err == nil {
+		t.Error("createTag() of an existing tag succeeded, want an error")
+	}
+
+	pushArgs := buildPushArgs(PushConfig{FollowTags: true}, TagsConfig{}, "v1.0.0", "main", "origin")
+	if err := pushTagToRemote("v1.0.0", "origin", pushArgs, ""); err != nil {
+		t.Fatalf("pushTagToRemote() error = %v", err)
+	}
+	want := [][]string{{"--follow-tags", "origin", "v1.0.0"}}
+	if !reflect.DeepEqual(g.pushes, want) {
+		t.Errorf("pushes = %q, want %q", g.pushes, want)
+	}
+
+	g.pushErr = fmt.Errorf("rejected")
+	if err := pushTagToRemote("v1.0.0", "origin", pushArgs, ""); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("pushTagToRemote() error = %v, want the push failure", err)
+	}
+}
+
+func TestGetAllRemoteURLsWithFakeGit(t *testing.T) {
+	g := newFakeGitClient("a")
+	g.remotes = map[string]string{"origin": "https://example.com/repo.git", "backup": "/srv/repo.git"}
+	useFakeGit(t, g)
+
+	if got := getAllRemoteURLs(); !reflect.DeepEqual(got, g.remotes) {
+		t.Errorf("getAllRemoteURLs() = %v, want %v", got, g.remotes)
+	}
+}
+
+func TestExecRemotes(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	client := execGitClient{}
+	if remotes, err := client.Remotes(); err != nil || len(remotes) != 0 {
+		t.Fatalf("Remotes() without remotes = %v, %v, want none", remotes, err)
+	}
+
+	// Lookups are cached per working directory, so a repository with remotes is a new one
+	dir = newBlobTestRepo(t)
+	gitInTestRepo(t, dir, "remote", "add", "origin", "git@github.com:owner/repo.git")
+	gitInTestRepo(t, dir, "remote", "add", "mirror.eu", "https://git.example.eu/repo.git")
+	want := map[string]string{"origin": "git@github.com:owner/repo.git", "mirror.eu": "https://git.example.eu/repo.git"}
+	remotes, err := client.Remotes()
+	if err != nil || !reflect.DeepEqual(remotes, want) {
+		t.Errorf("Remotes() = %v, %v, want %v", remotes, err, want)
+	}
+
+	// The cached result cannot be changed through the returned map
+	delete(remotes, "origin")
+	if again, _ := client.Remotes(); !reflect.DeepEqual(again, want) {
+		t.Errorf("second Remotes() = %v, want %v", again, want)
+	}
+}
+
+func TestParseRemoteURLs(t *testing.T) {
+	output := []byte("remote.origin.url git@github.com:owner/repo.git\nremote.Mirror.EU.url https://a.example/repo.git\nremote.Mirror.EU.url https://b.example/repo.git\n")
+	want := map[string]string{"origin": "git@github.com:owner/repo.git", "Mirror.EU": "https://b.example/repo.git"}
+	if got := parseRemoteURLs(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRemoteURLs() = %v, want %v", got, want)
+	}
+}
+
+func TestExecCommitStats(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	gitInTestRepo(t, dir, "tag", "v1.0.0")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "second")
+	gitInTestRepo(t, dir, "-c", "user.email=Other@example.com", "commit", "-q", "--allow-empty", "-m", "third")
+
+	stats, err := (execGitClient{}).CommitStats("v1.0.0", "HEAD")
+	if err != nil || stats.Count != 2 || stats.Authors != 2 || stats.Oldest.IsZero() {
+		t.Errorf("CommitStats(v1.0.0, HEAD) = %+v, %v, want 2 commits by 2 authors", stats, err)
+	}
+	if stats, err := (execGitClient{}).CommitStats("HEAD", "HEAD"); err != nil || stats.Count != 0 || !stats.Oldest.IsZero() {
+		t.Errorf("CommitStats(HEAD, HEAD) = %+v, %v, want no commits", stats, err)
+	}
+}
+
+func TestExecCommitMessages(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	gitInTestRepo(t, dir, "tag", "v1.0.0")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "Update CI", "-m", "Release-Note: none")
+
+	messages, err := (execGitClient{}).CommitMessages("v1.0.0", "HEAD")
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("CommitMessages(v1.0.0, HEAD) = %q, %v, want one message", messages, err)
+	}
+	for _, message := range messages {
+		if message != "Update CI\n\nRelease-Note: none" {
+			t.Errorf("message = %q", message)
+		}
+	}
+}
+
+func TestExecCreateRef(t *testing.T) {
+	newBlobTestRepo(t)
+	client := execGitClient{}
+	head, err := client.RevParse("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.CreateRef("refs/releases/v1.0.0", head); err != nil {
+		t.Fatalf("CreateRef() error = %v", err)
+	}
+	if commit, err := client.RevParse("refs/releases/v1.0.0"); err != nil || commit != head {
+		t.Errorf("RevParse(refs/releases/v1.0.0) = %q, %v, want %s", commit, err, head)
+	}
+	if err := client.CreateRef("refs/releases/v1.0.0", head); err == nil {
+		t.Error("CreateRef() of an existing ref succeeded, want an error")
+	}
+}
+
+func TestExecCreateTag(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	client := execGitClient{}
+	head, err := client.RevParse("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.CreateTag("v1.0.0", head); err != nil {
+		t.Fatalf("CreateTag() error = %v", err)
+	}
+	if commit, err := client.RevParse("refs/tags/v1.0.0"); err != nil || commit != head {
+		t.Errorf("RevParse(refs/tags/v1.0.0) = %q, %v, want %s", commit, err, head)
+	}
+	gitInTestRepo(t, dir, "reflog", "exists", "refs/tags/v1.0.0")
+
+	// A tag created meanwhile is never overwritten
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "second")
+	second, _ := client.RevParse("HEAD")
+	if err := client.CreateTag("v1.0.0", second); err == nil {
+		t.Error("CreateTag() of an existing tag succeeded, want an error")
+	}
+	if err := client.CreateAnnotatedTag("v1.0.0", second, "Release v1.0.0", time.Now()); err == nil {
+		t.Error("CreateAnnotatedTag() of an existing tag succeeded, want an error")
+	}
+	if commit, _ := client.RevParse("refs/tags/v1.0.0"); commit != head {
+		t.Errorf("tag v1.0.0 moved to %s, want it kept at %s", commit, head)
+	}
+}
+
+func TestExecMoveTag(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	client := execGitClient{}
+	gitInTestRepo(t, dir, "tag", "-a", "-m", "Release v1.0.0", "v1.0.0")
+	oldObject, _ := client.RevParse("refs/tags/v1.0.0")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "second")
+	second, _ := client.RevParse("HEAD")
+
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	object, err := client.CreateTagObject("v1.0.0", second, "Release v1.0.0\n\nTicket: OPS-1", date)
+	if err != nil {
+		t.Fatalf("CreateTagObject() error = %v", err)
+	}
+	// Only the object is written, the tag is not moved yet
+	if current, _ := client.RevParse("refs/tags/v1.0.0"); current != oldObject {
+		t.Errorf("CreateTagObject() moved the tag to %s", current)
+	}
+
+	if err := client.MoveTag("v1.0.0", object, second); err == nil {
+		t.Error("MoveTag() from a value the tag does not have succeeded, want an error")
+	}
+	if err := client.MoveTag("v1.0.0", object, oldObject); err != nil {
+		t.Fatalf("MoveTag() error = %v", err)
+	}
+	commit, _ := client.RevParse("refs/tags/v1.0.0^{commit}")
+	message, _ := client.TagMessage("v1.0.0")
+	tagger, _ := execCommand("git", "for-each-ref", "--format=%(taggerdate:unix)", "refs/tags/v1.0.0").Output()
+	if commit != second || message != "Ticket: OPS-1" || strings.TrimSpace(string(tagger)) != fmt.Sprint(date.Unix()) {
+		t.Errorf("tag v1.0.0 = %s with %q, tagged at %s, want %s with the ticket at %d", commit, message, tagger, second, date.Unix())
+	}
+	gitInTestRepo(t, dir, "reflog", "exists", "refs/tags/v1.0.0")
+}
+
+func TestExecCreateBranch(t *testing.T) {
+	newBlobTestRepo(t)
+	client := execGitClient{}
+	head, err := client.RevParse("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.CreateBranch("gray", head); err != nil {
+		t.Fatalf("CreateBranch() error = %v", err)
+	}
+	if commit, err := client.RevParse("gray"); err != nil || commit != head {
+		t.Errorf("RevParse(gray) = %q, %v, want %s", commit, err, head)
+	}
+	if err := client.CreateBranch("gray", head); err == nil {
+		t.Error("CreateBranch() of an existing branch succeeded, want an error")
+	}
+}
+
+func TestExecCommitSignatures(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	gitInTestRepo(t, dir, "tag", "v1.0.0")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "Unsigned change")
+
+	signatures, err := (execGitClient{}).CommitSignatures("v1.0.0", "HEAD")
+	if err != nil || len(signatures) != 1 {
+		t.Fatalf("CommitSignatures(v1.0.0, HEAD) = %+v, %v, want one commit", signatures, err)
+	}
+	if s := signatures[0]; s.Status != "N" || s.Subject != "Unsigned change" || s.Committer == "" {
+		t.Errorf("signature = %+v, want an unsigned commit", s)
+	}
+}
+
+func TestExecChangedFilesAndCountCommits(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	gitInTestRepo(t, dir, "tag", "v1.0.0")
+	os.MkdirAll(filepath.Join(dir, "docs"), 0755)
+	os.WriteFile(filepath.Join(dir, "docs", "guide.md"), []byte("# Guide\n"), 0644)
+	gitInTestRepo(t, dir, "add", "docs")
+	gitInTestRepo(t, dir, "commit", "-q", "-m", "Add the guide")
+	gitInTestRepo(t, dir, "rm", "-q", "publish.json")
+	gitInTestRepo(t, dir, "commit", "-q", "-m", "Drop the config")
+	client := execGitClient{}
+
+	changes, err := client.ChangedFiles("v1.0.0", "HEAD", nil)
+	want := []fileChange{{Status: "A", Path: "docs/guide.md"}, {Status: "D", Path: "publish.json"}}
+	if err != nil || !reflect.DeepEqual(changes, want) {
+		t.Errorf("ChangedFiles(v1.0.0, HEAD) = %+v, %v, want %+v", changes, err, want)
+	}
+	if count, err := client.CountCommits("v1.0.0", "HEAD", nil); err != nil || count != 2 {
+		t.Errorf("CountCommits(v1.0.0, HEAD) = %d, %v, want 2", count, err)
+	}
+	if count, err := client.CountCommits("v1.0.0", "HEAD", []string{"docs"}); err != nil || count != 1 {
+		t.Errorf("CountCommits(v1.0.0, HEAD, docs) = %d, %v, want 1", count, err)
+	}
+	if names, err := client.ListTree("HEAD:docs"); err != nil || !reflect.DeepEqual(names, []string{"guide.md"}) {
+		t.Errorf("ListTree(HEAD:docs) = %q, %v, want [guide.md]", names, err)
+	}
+}
+
+func TestExecCommit(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	client := execGitClient{}
+
+	if branch, err := client.CurrentBranch(); err != nil || branch != "main" {
+		t.Errorf("CurrentBranch() = %q, %v, want main", branch, err)
+	}
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("package b\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "publish.json"), []byte("{\"tagPrefix\": \"v\"}\n"), 0644)
+	if clean, err := client.IsClean(); err != nil || clean {
+		t.Errorf("IsClean() with a changed file = %v, %v, want false", clean, err)
+	}
+	if files, err := client.TrackedFiles("*.go"); err != nil || !reflect.DeepEqual(files, []string{"a.go"}) {
+		t.Errorf("TrackedFiles(*.go) = %q, %v, want [a.go]", files, err)
+	}
+
+	var err error
+	captureOutput(func() { err = client.Commit("Rename the package", "a.go") })
+	if err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if messages, err := client.CommitMessages("HEAD~1", "HEAD"); err != nil || len(messages) != 1 {
+		t.Errorf("CommitMessages(HEAD~1, HEAD) = %q, %v, want the new commit", messages, err)
+	}
+	// Only the given paths are committed
+	changes, err := client.ChangedFiles("HEAD~1", "HEAD", nil)
+	if want := []fileChange{{Status: "M", Path: "a.go"}}; err != nil || !reflect.DeepEqual(changes, want) {
+		t.Errorf("committed changes = %+v, %v, want %+v", changes, err, want)
+	}
+	if clean, err := client.IsClean(); err != nil || clean {
+		t.Errorf("IsClean() = %v, %v, want publish.json left changed", clean, err)
+	}
+}
+
+func TestExecStageGitlink(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	client := execGitClient{}
+	first, err := client.RevParse("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitInTestRepo(t, dir, "update-index", "--add", "--cacheinfo", "160000,"+first+",libs/core")
+	gitInTestRepo(t, dir, "commit", "-q", "-m", "Add the submodule")
+	second, _ := client.RevParse("HEAD")
+
+	if staged, err := client.HasStagedChanges(); err != nil || staged {
+		t.Errorf("HasStagedChanges() = %v, %v, want false", staged, err)
+	}
+	if err := client.StageGitlink("libs/core", second); err != nil {
+		t.Fatalf("StageGitlink() error = %v", err)
+	}
+	if staged, err := client.HasStagedChanges(); err != nil || !staged {
+		t.Errorf("HasStagedChanges() after StageGitlink = %v, %v, want true", staged, err)
+	}
+	if path, err := client.GitPath("git-publish/audit.log"); err != nil || path != ".git/git-publish/audit.log" {
+		t.Errorf("GitPath() = %q, %v, want .git/git-publish/audit.log", path, err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// GitOpsConfig opens a pull request against a deployment (GitOps) repository
+// that updates the image tag to the new release after a push
+type GitOpsConfig struct {
+	// Repo is the URL of the GitOps repository on GitHub, GitLab or Gitea
+	Repo string `json:"repo,omitempty"`
+	// Base is the branch the pull request targets (default: main)
+	Base string `json:"base,omitempty"`
+	// Files are the Kubernetes manifests or kustomization.yaml files setting the image
+	Files []string `json:"files,omitempty"`
+	// Image is the image name without a tag, e.g. ghcr.io/acme/api
+	Image string `json:"image,omitempty"`
+	// ImageTag is the new image tag; {tag} and {version} (the tag without its prefix) are substituted (default: {tag})
+	ImageTag string `json:"imageTag,omitempty"`
+	// Provider overrides the hosting service detected from Repo
+	Provider ProviderConfig `json:"provider,omitempty"`
+}
+
+// enabled reports whether releases are handed over to a GitOps repository
+func (c GitOpsConfig) enabled() bool {
+	return c.Repo != ""
+}
+
+// validateGitOpsConfig returns the problems of a GitOps configuration
+func validateGitOpsConfig(config GitOpsConfig) []string {
+	if !config.enabled() {
+		return nil
+	}
+	var problems []string
+	if _, ok := parseRemoteURL(config.Repo); !ok {
+		problems = append(problems, fmt.Sprintf("gitops.repo %q is not a hosted repository URL", config.Repo))
+	}
+	if config.Image == "" || strings.ContainsAny(config.Image, " @") {
+		problems = append(problems, fmt.Sprintf("gitops.image %q is not an image name without tag or digest", config.Image))
+	}
+	if len(config.Files) == 0 {
+		problems = append(problems, "gitops.files is empty")
+	}
+	return problems
+}
+
+// gitOpsImageTag returns the image tag a release of tag deploys
+func gitOpsImageTag(config GitOpsConfig, plan Plan) string {
+	imageTag := config.ImageTag
+	if imageTag == "" {
+		imageTag = "{tag}"
+	}
+	version := strings.TrimPrefix(plan.Tag, extractPrefix(plan.TagFormat))
+	return strings.NewReplacer("{tag}", plan.Tag, "{version}", version).Replace(imageTag)
+}
+
+// openGitOpsPullRequest proposes the image tag of a published plan to the
+// GitOps repository, linking the release (or tag) page, and returns the URL of
+// the pull request, "" when the files already deploy the tag
+func openGitOpsPullRequest(config GitOpsConfig, plan Plan, releaseURL string) (string, error) {
+	p, err := newProvider(config.Repo, config.Provider)
+	if err != nil {
+		return "", err
+	}
+	if !p.HasToken() {
+		return "", fmt.Errorf("no %s API token found for %s", p.Name(), config.Repo)
+	}
+	base := config.Base
+	if base == "" {
+		base = "main"
+	}
+	imageTag := gitOpsImageTag(config, plan)
+
+	files := map[string]string{}
+	for _, path := range config.Files {
+		content, err := p.FileContent(path, base)
+		if err != nil {
+			return "", fmt.Errorf("reading %s: %v", path, err)
+		}
+		updated, found := setImageTag(content, config.Image, imageTag)
+		if !found {
+			return "", fmt.Errorf("%s does not set image %s", path, config.Image)
+		}
+		if updated != content {
+			files[path] = updated
+		}
+	}
+	if len(files) == 0 {
+		fmt.Printf("%s already deploys %s:%s\n", config.Repo, config.Image, imageTag)
+		return "", nil
+	}
+
+	project := projectName(plan.RemoteURL)
+	link := releaseURL
+	if link == "" {
+		link = plan.TagURL
+	}
+	body := fmt.Sprintf("Updates %s to %s, released as %s of %s.", config.Image, imageTag, plan.Tag, project)
+	if link != "" {
+		body += "\n\nRelease: " + link
+	}
+	return p.OpenPullRequest(pullRequest{
+		Base:   base,
+		Branch: "git-publish/" + project + "-" + plan.Tag,
+		Title:  fmt.Sprintf("Deploy %s %s", project, plan.Tag),
+		Body:   body,
+		Files:  files,
+	})
+}
+
+// kustomizeImageName matches the name of an images entry of a kustomization
+var kustomizeImageName = regexp.MustCompile(`^(\s*)(- )?(\s*)name:\s*["']?([^"'\s#]+)["']?\s*(#.*)?$`)
+
+// setImageTag sets the tag of image in a manifest, both in image: fields
+// ("image: ghcr.io/acme/api:v1.0.0") and in the images of a kustomization
+// ("- name: ghcr.io/acme/api" with "newTag: v1.0.0"). It reports whether the
+// manifest refers to the image at all.
+func setImageTag(manifest, image, tag string) (string, bool) {
+	imageField := regexp.MustCompile(`^(\s*(?:- )?\s*image:\s*["']?)` + regexp.QuoteMeta(image) + `(:[^"'\s@#]+)?(["']?\s*(?:#.*)?)$`)
+	lines := strings.Split(manifest, "\n")
+	found := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		cr := lines[i][len(line):]
+		if m := imageField.FindStringSubmatch(line); m != nil {
+			lines[i] = m[1] + image + ":" + tag + m[3] + cr
+			found = true
+			continue
+		}
+
+		m := kustomizeImageName.FindStringSubmatch(line)
+		if m == nil || m[4] != image {
+			continue
+		}
+		found = true
+		// The other keys of the entry are indented like name
+		indent := len(m[1]) + len(m[2]) + len(m[3])
+		hasTag := false
+		for j := i + 1; j < len(lines); j++ {
+			sibling := strings.TrimSuffix(lines[j], "\r")
+			keyIndent := len(sibling) - len(strings.TrimLeft(sibling, " "))
+			if strings.TrimSpace(sibling) == "" || keyIndent != indent || strings.HasPrefix(strings.TrimSpace(sibling), "-") {
+				break
+			}
+			if strings.HasPrefix(strings.TrimSpace(sibling), "newTag:") {
+				value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(sibling), "newTag:"))
+				quote := ""
+				if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+					quote = value[:1]
+				}
+				lines[j] = sibling[:keyIndent] + "newTag: " + quote + tag + quote + lines[j][len(sibling):]
+				hasTag = true
+			}
+		}
+		if !hasTag {
+			newTag := strings.Repeat(" ", indent) + "newTag: " + tag + cr
+			lines = append(lines[:i+1], append([]string{newTag}, lines[i+1:]...)...)
+		}
+	}
+	return strings.Join(lines, "\n"), found
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSetImageTag(t *testing.T) {
+	tests := []struct {
+		name, manifest, want string
+		found                bool
+	}{
+		{"deployment",
+			"containers:\n  - name: api\n    image: ghcr.io/acme/api:v1.0.0\n  - name: worker\n    image: ghcr.io/acme/api-worker:v1.0.0\n",
+			"containers:\n  - name: api\n    image: ghcr.io/acme/api:v1.1.0\n  - name: worker\n    image: ghcr.io/acme/api-worker:v1.0.0\n", true},
+		{"quoted without tag", "  - image: \"ghcr.io/acme/api\" # pinned\r\n", "  - image: \"ghcr.io/acme/api:v1.1.0\" # pinned\r\n", true},
+		{"kustomization",
+			"images:\n  - name: ghcr.io/acme/api\n    newTag: \"v1.0.0\"\n  - name: redis\n    newTag: 7.0.0\n",
+			"images:\n  - name: ghcr.io/acme/api\n    newTag: \"v1.1.0\"\n  - name: redis\n    newTag: 7.0.0\n", true},
+		{"kustomization without tag",
+			"images:\n- name: ghcr.io/acme/api\n  newName: registry.example.com/api\n",
+			"images:\n- name: ghcr.io/acme/api\n  newTag: v1.1.0\n  newName: registry.example.com/api\n", true},
+		{"other image", "image: ghcr.io/acme/web:v1.0.0\n", "image: ghcr.io/acme/web:v1.0.0\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := setImageTag(tt.manifest, "ghcr.io/acme/api", "v1.1.0")
+			if got != tt.want || found != tt.found {
+				t.Errorf("setImageTag() = %q, %v, want %q, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestValidateGitOpsConfig(t *testing.T) {
+	valid := GitOpsConfig{Repo: "https://github.com/acme/deploy.git", Files: []string{"apps/api/kustomization.yaml"}, Image: "ghcr.io/acme/api"}
+	if problems := validateGitOpsConfig(valid); len(problems) != 0 {
+		t.Errorf("validateGitOpsConfig(valid) = %v", problems)
+	}
+	if problems := validateGitOpsConfig(GitOpsConfig{Repo: "/srv/deploy"}); len(problems) != 3 {
+		t.Errorf("validateGitOpsConfig(invalid) = %v, want 3 problems", problems)
+	}
+}
+
+func TestOpenGitOpsPullRequest(t *testing.T) {
+	manifest := "images:\n  - name: ghcr.io/acme/api\n    newTag: v1.0.0\n"
+	var updated string
+	var pull map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/acme/deploy/contents/apps/api/kustomization.yaml":
+			fmt.Fprintf(w, `{"content": %q, "encoding": "base64", "sha": "f1"}`, base64.StdEncoding.EncodeToString([]byte(manifest)))
+		case "GET /repos/acme/deploy/git/ref/heads/main":
+			fmt.Fprint(w, `{"object": {"sha": "c1"}}`)
+		case "POST /repos/acme/deploy/git/refs":
+			w.WriteHeader(http.StatusCreated)
+		case "PUT /repos/acme/deploy/contents/apps/api/kustomization.yaml":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			content, _ := base64.StdEncoding.DecodeString(body["content"])
+			updated = string(content)
+		case "POST /repos/acme/deploy/pulls":
+			json.NewDecoder(r.Body).Decode(&pull)
+			fmt.Fprint(w, `{"html_url": "https://github.com/acme/deploy/pull/7"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GITHUB_TOKEN", "secret")
+
+	config := GitOpsConfig{
+		Repo:     "https://github.com/acme/deploy.git",
+		Files:    []string{"apps/api/kustomization.yaml"},
+		Image:    "ghcr.io/acme/api",
+		Provider: ProviderConfig{Type: providerGitHub, APIURL: server.URL},
+	}
+	plan := Plan{Tag: "v1.1.0", TagFormat: "v0.0.0", RemoteURL: "git@github.com:acme/api.git"}
+	url, err := openGitOpsPullRequest(config, plan, "https://github.com/acme/api/releases/tag/v1.1.0")
+	if err != nil || url != "https://github.com/acme/deploy/pull/7" {
+		t.Fatalf("openGitOpsPullRequest() = %q, %v", url, err)
+	}
+	if !strings.Contains(updated, "newTag: v1.1.0") {
+		t.Errorf("committed %q, want the new tag", updated)
+	}
+	if pull["head"] != "git-publish/api-v1.1.0" || pull["base"] != "main" || !strings.Contains(pull["body"], "releases/tag/v1.1.0") {
+		t.Errorf("pull request = %v", pull)
+	}
+
+	// Files already deploying the tag need no pull request
+	manifest = updated
+	if url, err := openGitOpsPullRequest(config, plan, ""); err != nil || url != "" {
+		t.Errorf("openGitOpsPullRequest() again = %q, %v, want nothing to do", url, err)
+	}
+	config.ImageTag = "{version}"
+	if got := gitOpsImageTag(config, plan); got != "1.1.0" {
+		t.Errorf("gitOpsImageTag({version}) = %q", got)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// goFileAPI parses a Go source file and returns its package name and exported
+// API keyed by symbol: top-level declarations, methods as "Type.Method", and
+// exported struct fields and interface methods as "Type.Name". Each symbol maps
+// to a normalized declaration in which parameter names are omitted, so two
+// declarations are compatible only if their texts are equal.
+func goFileAPI(src []byte) (string, map[string]string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return "", nil, err
+	}
+
+	render := func(node interface{}) string {
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, node)
+		return buf.String()
+	}
+	signature := func(ft *ast.FuncType) string {
+		return strings.TrimPrefix(render(&ast.FuncType{
+			TypeParams: ft.TypeParams,
+			Params:     withoutNames(ft.Params),
+			Results:    withoutNames(ft.Results),
+		}), "func")
+	}
+
+	symbols := make(map[string]string)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				symbols[d.Name.Name] = "func " + d.Name.Name + signature(d.Type)
+				continue
+			}
+			recv := receiverTypeName(d.Recv.List[0].Type)
+			if ast.IsExported(recv) {
+				symbols[recv+"."+d.Name.Name] = "func (" + render(d.Recv.List[0].Type) + ") " + d.Name.Name + signature(d.Type)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if !s.Name.IsExported() {
+						continue
+					}
+					name := s.Name.Name
+					header := "type " + name
+					if s.TypeParams != nil {
+						header += render(s.TypeParams)
+					}
+					if s.Assign.IsValid() {
+						header += " ="
+					}
+
+					switch t := s.Type.(type) {
+					case *ast.StructType:
+						symbols[name] = header + " struct"
+						for _, field := range t.Fields.List {
+							for _, fieldName := range fieldNames(field) {
+								if ast.IsExported(fieldName) {
+									symbols[name+"."+fieldName] = "field " + fieldName + " " + render(field.Type)
+								}
+							}
+						}
+					case *ast.InterfaceType:
+						symbols[name] = header + " interface"
+						for _, method := range t.Methods.List {
+							if ft, ok := method.Type.(*ast.FuncType); ok {
+								for _, methodName := range method.Names {
+									symbols[name+"."+methodName.Name] = "interface method " + methodName.Name + signature(ft)
+								}
+							} else {
+								// Embedded interface or type constraint
+								symbols[name+"."+render(method.Type)] = "interface embeds " + render(method.Type)
+							}
+						}
+					default:
+						symbols[name] = header + " " + render(s.Type)
+					}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						if !name.IsExported() {
+							continue
+						}
+						symbols[name.Name] = d.Tok.String() + " " + name.Name
+						if s.Type != nil {
+							symbols[name.Name] += " " + render(s.Type)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return file.Name.Name, symbols, nil
+}
+
+// withoutNames returns a copy of a parameter list with one unnamed field per parameter
+func withoutNames(list *ast.FieldList) *ast.FieldList {
+	if list == nil {
+		return nil
+	}
+	stripped := &ast.FieldList{}
+	for _, field := range list.List {
+		for range fieldNames(field) {
+			stripped.List = append(stripped.List, &ast.Field{Type: field.Type})
+		}
+	}
+	return stripped
+}
+
+// fieldNames returns the names declared by a field; an embedded field is named after its type
+func fieldNames(field *ast.Field) []string {
+	if len(field.Names) == 0 {
+		return []string{receiverTypeName(field.Type)}
+	}
+	names := make([]string, len(field.Names))
+	for i, name := range field.Names {
+		names[i] = name.Name
+	}
+	return names
+}
+
+// receiverTypeName returns the type name of a method receiver such as *T or T[K]
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// isPublicGoPackageDir reports whether the exported API of a package directory
+// is visible to other modules
+func isPublicGoPackageDir(dir string) bool {
+	for _, segment := range strings.Split(dir, "/") {
+		if segment == "internal" || segment == "testdata" || segment == "vendor" {
+			return false
+		}
+	}
+	return true
+}
+
+// goPackageAPI returns the exported API of the package in dir at the given
+// revision, with symbols qualified by the directory. Commands and test files
+// are ignored.
+func goPackageAPI(rev, dir string) (map[string]string, error) {
+	api := make(map[string]string)
+
+	spec := rev + ":" + dir
+	if dir == "." {
+		spec = rev + ":"
+	}
+	names, err := gitClient.ListTree(spec)
+	if err != nil {
+		// The directory does not exist at this revision
+		return api, nil
+	}
+
+	var files []string
+	for _, name := range names {
+		if strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			files = append(files, rev+":"+path.Join(dir, name))
+		}
+	}
+	// In a partial clone this fetches the files of the package in one go
+	sources, err := readBlobs(files)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		src, ok := sources[file]
+		if !ok {
+			continue
+		}
+		pkg, symbols, err := goFileAPI(src)
+		if err != nil || pkg == "main" {
+			continue
+		}
+		for symbol, decl := range symbols {
+			api[path.Join(dir, symbol)] = decl
+		}
+	}
+
+	return api, nil
+}
+
+// goAPIDiff lists the differences between two versions of an exported Go API
+type goAPIDiff struct {
+	// Breaking lists removed or changed symbols and methods added to interfaces
+	Breaking []string
+	// Added lists new symbols that do not break existing users
+	Added []string
+}
+
+// diffGoAPI compares the exported API of the Go packages in dirs between two
+// revisions. It fails if the sources of a package cannot be read, rather than
+// reporting the package as removed.
+func diffGoAPI(oldRev, newRev string, dirs []string) (goAPIDiff, error) {
+	var diff goAPIDiff
+	for _, dir := range dirs {
+		if !isPublicGoPackageDir(dir) {
+			continue
+		}
+		oldAPI, err := goPackageAPI(oldRev, dir)
+		if err != nil {
+			return diff, err
+		}
+		newAPI, err := goPackageAPI(newRev, dir)
+		if err != nil {
+			return diff, err
+		}
+		packageDiff := compareGoAPI(oldAPI, newAPI)
+		diff.Breaking = append(diff.Breaking, packageDiff.Breaking...)
+		diff.Added = append(diff.Added, packageDiff.Added...)
+	}
+	sort.Strings(diff.Breaking)
+	sort.Strings(diff.Added)
+	return diff, nil
+}
+
+// compareGoAPI classifies the differences between two versions of an API, in the spirit of apidiff
+func compareGoAPI(oldAPI, newAPI map[string]string) goAPIDiff {
+	var diff goAPIDiff
+	for symbol, oldDecl := range oldAPI {
+		newDecl, ok := newAPI[symbol]
+		switch {
+		case !ok:
+			diff.Breaking = append(diff.Breaking, "removed "+symbol)
+		case newDecl != oldDecl:
+			diff.Breaking = append(diff.Breaking, fmt.Sprintf("changed %s: %s -> %s", symbol, oldDecl, newDecl))
+		}
+	}
+	for symbol, newDecl := range newAPI {
+		if _, ok := oldAPI[symbol]; ok {
+			continue
+		}
+		// Implementations of an existing interface do not have the new method
+		parent := symbol[:strings.LastIndex(symbol, ".")+1]
+		if strings.HasPrefix(newDecl, "interface ") && parent != "" && oldAPI[strings.TrimSuffix(parent, ".")] != "" {
+			diff.Breaking = append(diff.Breaking, "added "+symbol+" to an existing interface")
+			continue
+		}
+		diff.Added = append(diff.Added, symbol)
+	}
+	sort.Strings(diff.Breaking)
+	sort.Strings(diff.Added)
+	return diff
+}
+
+// Go API check modes
+const (
+	goAPICheckBlock = "block"
+	goAPICheckWarn  = "warn"
+	goAPICheckOff   = "off"
+)
+
+// isGoModule reports whether the commit has a go.mod at the root or in one of
+// the paths. Only trees are looked up, so partial clones fetch no file contents.
+func isGoModule(commit string, paths []string) bool {
+	for _, dir := range append([]string{""}, paths...) {
+		if _, err := gitClient.RevParse(commit + ":" + path.Join(dir, "go.mod")); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isMajorRelease reports whether tag is allowed to break the API released as lastTag:
+// a new major version, or a new minor version before 1.0.0
+func isMajorRelease(lastTag, tag, tagFormat string) bool {
+	prefix := extractPrefix(tagFormat)
+	oldParts := strings.Split(strings.TrimPrefix(lastTag, prefix), ".")
+	newParts := strings.Split(strings.TrimPrefix(tag, prefix), ".")
+	if len(oldParts) != 3 || len(newParts) != 3 {
+		return true
+	}
+
+	oldMajor, _ := strconv.Atoi(oldParts[0])
+	newMajor, _ := strconv.Atoi(newParts[0])
+	if newMajor != oldMajor || oldMajor > 0 {
+		return newMajor > oldMajor
+	}
+	oldMinor, _ := strconv.Atoi(oldParts[1])
+	newMinor, _ := strconv.Atoi(newParts[1])
+	return newMinor > oldMinor
+}
+
+// checkGoAPICompat compares the exported Go API on branch with lastTag and
+// reports breaking changes when tag is not a major release. Depending on the
+// configured mode it only warns or returns an error.
+func checkGoAPICompat(config Config, branch, tagFormat, lastTag, tag string, allowBreaking bool) error {
+	if config.GoAPICheck == goAPICheckOff || lastTag == "" || isMajorRelease(lastTag, tag, tagFormat) {
+		return nil
+	}
+
+	paths := findBranchTagConfig(config, branch, tagFormat).Paths
+	commit, err := lookupCommit(branch)
+	if err != nil || !isGoModule(commit, paths) {
+		return nil
+	}
+	changes, err := changedFiles(lastTag, commit, paths)
+	if err != nil {
+		fmt.Printf("Warning: could not check the Go API for breaking changes: %v\n", err)
+		return nil
+	}
+	diff, err := diffGoAPI(lastTag, commit, goPackageDirs(changes))
+	if err != nil {
+		fmt.Printf("Warning: could not check the Go API for breaking changes: %v\n", err)
+		return nil
+	}
+	if len(diff.Breaking) == 0 {
+		return nil
+	}
+
+	red := color.New(color.FgRed, color.Bold).SprintFunc()
+	fmt.Println(red(fmt.Sprintf("WARNING: %s is not a major release, but the Go API has breaking changes since %s:", tag, lastTag)))
+	for _, change := range diff.Breaking {
+		fmt.Printf("  - %s\n", change)
+	}
+
+	if config.GoAPICheck == goAPICheckWarn || allowBreaking {
+		return nil
+	}
+	return fmt.Errorf("refusing to release breaking API changes as %s; tag a new major version or pass --allow-breaking", tag)
+}
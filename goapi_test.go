@@ -0,0 +1,130 @@
+package main
+
+import "testing"
+
+// TestGoFileAPI tests extraction of exported declarations from Go source
+func TestGoFileAPI(t *testing.T) {
+	src := []byte(`package lib
+
+type Client struct{ addr string }
+type options struct{}
+
+const Version = "1"
+var debug bool
+
+func New(addr string) *Client { return &Client{addr} }
+func (c *Client) Do() error { return nil }
+func (o options) Apply() {}
+func helper() {}
+`)
+
+	pkg, symbols, err := goFileAPI(src)
+	if err != nil {
+		t.Fatalf("goFileAPI() error: %v", err)
+	}
+	if pkg != "lib" {
+		t.Errorf("goFileAPI() package = %q, expected %q", pkg, "lib")
+	}
+
+	expected := map[string]string{
+		"Client":    "type Client struct",
+		"Version":   "const Version",
+		"New":       "func New(string) *Client",
+		"Client.Do": "func (*Client) Do() error",
+	}
+	if len(symbols) != len(expected) {
+		t.Errorf("goFileAPI() = %v, expected %v", symbols, expected)
+	}
+	for name, decl := range expected {
+		if symbols[name] != decl {
+			t.Errorf("goFileAPI()[%q] = %q, expected %q", name, symbols[name], decl)
+		}
+	}
+}
+
+// TestCompareGoAPI tests the classification of API differences between two versions of a package
+func TestCompareGoAPI(t *testing.T) {
+	testCases := []struct {
+		name     string
+		old      string
+		new      string
+		breaking int
+		added    int
+	}{
+		{"identical", "func F(a int) {}", "func F(a int) {}", 0, 0},
+		{"renamed parameter", "func F(a, b int) {}", "func F(x int, y int) {}", 0, 0},
+		{"changed parameter", "func F(a int) {}", "func F(a int64) {}", 1, 0},
+		{"removed function", "func F() {}\nfunc G() {}", "func G() {}", 1, 0},
+		{"added function", "func F() {}", "func F() {}\nfunc G() {}", 0, 1},
+		{"added struct field", "type T struct{ A int }", "type T struct{ A int; B string }", 0, 1},
+		{"changed struct field", "type T struct{ A int }", "type T struct{ A string }", 1, 0},
+		{"unexported field", "type T struct{ A int }", "type T struct{ A int; b int }", 0, 0},
+		{"added interface method", "type I interface{ M() }", "type I interface{ M(); N() }", 1, 0},
+		{"new interface", "", "type I interface{ M() }", 0, 2},
+		{"struct became interface", "type T struct{}", "type T interface{}", 1, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, oldAPI, err := goFileAPI([]byte("package p\n" + tc.old))
+			if err != nil {
+				t.Fatalf("goFileAPI(old) error: %v", err)
+			}
+			_, newAPI, err := goFileAPI([]byte("package p\n" + tc.new))
+			if err != nil {
+				t.Fatalf("goFileAPI(new) error: %v", err)
+			}
+
+			diff := compareGoAPI(oldAPI, newAPI)
+			if len(diff.Breaking) != tc.breaking || len(diff.Added) != tc.added {
+				t.Errorf("compareGoAPI() = %+v, expected %d breaking and %d added", diff, tc.breaking, tc.added)
+			}
+		})
+	}
+}
+
+// TestIsMajorRelease tests which version bumps may break the API
+func TestIsMajorRelease(t *testing.T) {
+	testCases := []struct {
+		lastTag  string
+		tag      string
+		expected bool
+	}{
+		{"v1.2.3", "v1.2.4", false},
+		{"v1.2.3", "v1.3.0", false},
+		{"v1.2.3", "v2.0.0", true},
+		{"v0.2.3", "v0.2.4", false},
+		{"v0.2.3", "v0.3.0", true},
+		{"v0.2.3", "v1.0.0", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.lastTag+"->"+tc.tag, func(t *testing.T) {
+			if result := isMajorRelease(tc.lastTag, tc.tag, "v0.0.0"); result != tc.expected {
+				t.Errorf("isMajorRelease(%q, %q) = %v, expected %v", tc.lastTag, tc.tag, result, tc.expected)
+			}
+		})
+	}
+}
+
+// TestIsGoModule tests finding go.mod at the root or in one of the paths
+func TestIsGoModule(t *testing.T) {
+	g := newFakeGitClient("c1")
+	g.files["c1"] = map[string]string{"tools/go.mod": "module example.com/tools\n", "main.go": "package main\n"}
+	useFakeGit(t, g)
+
+	testCases := []struct {
+		paths    []string
+		expected bool
+	}{
+		{nil, false},
+		{[]string{"docs"}, false},
+		{[]string{"docs", "tools"}, true},
+	}
+
+	for _, tc := range testCases {
+		if result := isGoModule("c1", tc.paths); result != tc.expected {
+			t.Errorf("isGoModule(c1, %q) = %v, expected %v", tc.paths, result, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// majorSuffixPattern matches the /vN suffix of a module path
+var majorSuffixPattern = regexp.MustCompile(`/v([0-9]+)$`)
+
+// parseModulePath returns the module path declared in a go.mod file
+func parseModulePath(gomod string) string {
+	for _, line := range strings.Split(gomod, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], "\"`")
+		}
+	}
+	return ""
+}
+
+// expectedModulePath returns modulePath with the /vN suffix required for the
+// given major version: none for v0 and v1, /vN from v2 on
+func expectedModulePath(modulePath string, major int) string {
+	base := majorSuffixPattern.ReplaceAllString(modulePath, "")
+	if major < 2 {
+		return base
+	}
+	return fmt.Sprintf("%s/v%d", base, major)
+}
+
+// tagMajorVersion returns the major version of a tag in the given format
+func tagMajorVersion(tag, tagFormat string) (int, bool) {
+	parts := strings.Split(strings.TrimPrefix(tag, extractPrefix(tagFormat)), ".")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	return major, err == nil
+}
+
+// checkGoModulePath verifies that the go.mod files of the tagged module carry
+// the /vN suffix required by the major version of tag. When offerFix is set and
+// the branch is checked out, it offers to commit the corrected module path.
+func checkGoModulePath(config Config, branch, tagFormat, tag string, offerFix bool) error {
+	major, ok := tagMajorVersion(tag, tagFormat)
+	if !ok {
+		return nil
+	}
+	commit, err := lookupCommit(branch)
+	if err != nil {
+		return nil
+	}
+
+	dirs := findBranchTagConfig(config, branch, tagFormat).Paths
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	for _, dir := range dirs {
+		spec := commit + ":" + path.Join(dir, "go.mod")
+		blobs, err := readBlobs([]string{spec})
+		if err != nil {
+			return err
+		}
+		gomod, ok := blobs[spec]
+		if !ok {
+			continue
+		}
+		modulePath := parseModulePath(string(gomod))
+		expected := expectedModulePath(modulePath, major)
+		if modulePath == "" || modulePath == expected {
+			continue
+		}
+
+		// The major subdirectory layout keeps vN in its own directory
+		if major >= 2 {
+			subdir := commit + ":" + path.Join(dir, fmt.Sprintf("v%d", major), "go.mod")
+			sub, err := readBlobs([]string{subdir})
+			if err != nil {
+				return err
+			}
+			if parseModulePath(string(sub[subdir])) == expected {
+				continue
+			}
+		}
+
+		red := color.New(color.FgRed).SprintFunc()
+		fmt.Printf("%s %s needs module path %s in %s, but it declares %s\n",
+			red("Error:"), tag, expected, path.Join(dir, "go.mod"), modulePath)
+
+		if !offerFix || !canCommitOnBranch(branch) {
+			return fmt.Errorf("module path of %s does not match %s", path.Join(dir, "go.mod"), tag)
+		}
+		if !prompter.Confirm(fmt.Sprintf("Commit the module path change (go.mod and imports) on %s?", branch), false) {
+			return fmt.Errorf("module path of %s does not match %s", path.Join(dir, "go.mod"), tag)
+		}
+		if err := commitModulePath(dir, modulePath, expected); err != nil {
+			return fmt.Errorf("updating module path: %v", err)
+		}
+		fmt.Printf("Committed the module path change to %s\n", expected)
+	}
+
+	return nil
+}
+
+// canCommitOnBranch reports whether branch is checked out with a clean working tree
+func canCommitOnBranch(branch string) bool {
+	current, err := gitClient.CurrentBranch()
+	if err != nil || current != branch {
+		return false
+	}
+	clean, err := gitClient.IsClean()
+	return err == nil && clean
+}
+
+// commitModulePath rewrites the module path in dir/go.mod and the module's own
+// imports, then commits the result
+func commitModulePath(dir, oldPath, newPath string) error {
+	files, err := gitClient.TrackedFiles(path.Join(dir, "go.mod"), path.Join(dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	if skipped := outsideSparseCheckout(files); len(skipped) > 0 {
+		return fmt.Errorf("files outside the sparse checkout: %s; run git sparse-checkout add %s first", summarizeList(skipped), dir)
+	}
+
+	replacer := strings.NewReplacer(
+		"module "+oldPath+"\n", "module "+newPath+"\n",
+		`"`+oldPath+`"`, `"`+newPath+`"`,
+		`"`+oldPath+`/`, `"`+newPath+`/`,
+	)
+	var changed []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		updated := replacer.Replace(string(data))
+		if updated == string(data) {
+			continue
+		}
+		if err := os.WriteFile(file, []byte(updated), 0644); err != nil {
+			return err
+		}
+		changed = append(changed, file)
+	}
+	return gitClient.Commit("Update module path to "+newPath, changed...)
+}
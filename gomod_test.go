@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+// TestParseModulePath tests reading the module path from go.mod
+func TestParseModulePath(t *testing.T) {
+	testCases := []struct {
+		gomod    string
+		expected string
+	}{
+		{"module example.com/lib\n\ngo 1.21\n", "example.com/lib"},
+		{"// comment\nmodule \"example.com/lib/v2\" // quoted\n", "example.com/lib/v2"},
+		{"go 1.21\n", ""},
+	}
+
+	for _, tc := range testCases {
+		if result := parseModulePath(tc.gomod); result != tc.expected {
+			t.Errorf("parseModulePath(%q) = %q, expected %q", tc.gomod, result, tc.expected)
+		}
+	}
+}
+
+// TestExpectedModulePath tests the /vN suffix required for each major version
+func TestExpectedModulePath(t *testing.T) {
+	testCases := []struct {
+		modulePath string
+		major      int
+		expected   string
+	}{
+		{"example.com/lib", 0, "example.com/lib"},
+		{"example.com/lib", 1, "example.com/lib"},
+		{"example.com/lib", 2, "example.com/lib/v2"},
+		{"example.com/lib/v2", 3, "example.com/lib/v3"},
+		{"example.com/lib/v2", 1, "example.com/lib"},
+		{"example.com/v2", 2, "example.com/v2"},
+	}
+
+	for _, tc := range testCases {
+		if result := expectedModulePath(tc.modulePath, tc.major); result != tc.expected {
+			t.Errorf("expectedModulePath(%q, %d) = %q, expected %q", tc.modulePath, tc.major, result, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Hook stages
+const (
+	hookPreTag   = "preTag"
+	hookPostTag  = "postTag"
+	hookPostPush = "postPush"
+)
+
+// HooksConfig represents shell commands run around tag creation
+type HooksConfig struct {
+	PreTag   []string `json:"preTag,omitempty"`
+	PostTag  []string `json:"postTag,omitempty"`
+	PostPush []string `json:"postPush,omitempty"`
+}
+
+// PlannedHook is a hook command that will run at the given stage
+type PlannedHook struct {
+	Stage   string `json:"stage"`
+	Command string `json:"command"`
+}
+
+// plannedHooks lists the hooks that will run, skipping push hooks when nothing is pushed
+func plannedHooks(hooks HooksConfig, push bool) []PlannedHook {
+	var planned []PlannedHook
+	for _, command := range hooks.PreTag {
+		planned = append(planned, PlannedHook{Stage: hookPreTag, Command: command})
+	}
+	for _, command := range hooks.PostTag {
+		planned = append(planned, PlannedHook{Stage: hookPostTag, Command: command})
+	}
+	if push {
+		for _, command := range hooks.PostPush {
+			planned = append(planned, PlannedHook{Stage: hookPostPush, Command: command})
+		}
+	}
+	return planned
+}
+
+// hookEnv returns the environment passed to hook commands
+func hookEnv(plan Plan) []string {
+	return append(os.Environ(),
+		"GIT_PUBLISH_BRANCH="+plan.Branch,
+		"GIT_PUBLISH_COMMIT="+plan.TargetCommit,
+		"GIT_PUBLISH_LAST_TAG="+plan.LastTag,
+		"GIT_PUBLISH_TAG="+plan.Tag,
+		"GIT_PUBLISH_REMOTE="+plan.Remote,
+		"GIT_PUBLISH_TAG_URL="+plan.TagURL,
+		"GIT_PUBLISH_ENVIRONMENT="+plan.Environment,
+	)
+}
+
+// runHooks runs the planned hooks of the given stage and stops at the first failure
+func runHooks(plan Plan, stage string) error {
+	for _, hook := range plan.Hooks {
+		if hook.Stage != stage {
+			continue
+		}
+
+		fmt.Printf("Running %s hook: %s\n", stage, hook.Command)
+		cmd := execCommand("sh", "-c", hook.Command)
+		cmd.Env = hookEnv(plan)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook failed: %v", stage, err)
+		}
+	}
+	return nil
+}
+
+// subscribeHooks runs the configured hooks on the lifecycle events of their stage
+func subscribeHooks(bus *eventBus) {
+	stages := map[string]string{
+		eventTagComputed: hookPreTag,
+		eventTagCreated:  hookPostTag,
+		eventTagPushed:   hookPostPush,
+	}
+	for eventType, stage := range stages {
+		stage := stage
+		bus.subscribe(func(e event) error { return runHooks(e.Plan, stage) }, eventType)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// hotfixOptions are the flags of the hotfix command
+type hotfixOptions struct {
+	// BranchName is the hotfix branch; {tag} is replaced by the tag being fixed
+	BranchName string
+	Remote     string
+	NoPush     bool
+	// MergeInto is the branch the hotfix is merged back into, asked when empty
+	MergeInto string
+}
+
+// runHotfixCommand implements the hotfix command: it branches off a released
+// tag, waits for the fix to be committed there, tags the next patch version
+// on the hotfix branch and offers to merge the fix back. Running it again
+// with the same tag resumes where it stopped.
+func runHotfixCommand(args []string) {
+	fs := flag.NewFlagSet("hotfix", flag.ExitOnError)
+	branchName := fs.String("branch-name", "hotfix/{tag}", "name of the hotfix branch; {tag} is the tag being fixed")
+	remote := fs.String("remote", "", "remote to push the hotfix tag and branch to (skips the push prompt)")
+	noPush := fs.Bool("no-push", false, "create the hotfix tag without pushing it")
+	mergeInto := fs.String("merge-into", "", "branch to merge the hotfix back into (skips the merge prompt)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: git-publish hotfix [--branch-name hotfix/{tag}] [--remote <name> | --no-push] [--merge-into <branch>] <tag>")
+		os.Exit(1)
+	}
+	opts := hotfixOptions{BranchName: *branchName, Remote: *remote, NoPush: *noPush, MergeInto: *mergeInto}
+	if err := runHotfix(readConfig(), getAllRemoteURLs(), fs.Arg(0), opts); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runHotfix carries out the hotfix of tag step by step
+func runHotfix(config Config, remoteURLs map[string]string, tag string, opts hotfixOptions) error {
+	green := color.New(color.FgGreen).SprintFunc()
+
+	// 1. The tag being fixed decides the tag format and the branch merged back into
+	tagCommit, err := gitClient.RevParse("refs/tags/" + tag + "^{commit}")
+	if err != nil {
+		return fmt.Errorf("tag %s does not exist", tag)
+	}
+	var released BranchTagConfig
+	for _, bt := range config.BranchTags {
+		if validateTagFormat(tag, extractPrefix(bt.Tag)) {
+			released = bt
+			break
+		}
+	}
+	if released.Tag == "" {
+		return fmt.Errorf("tag %s matches no configured tag format", tag)
+	}
+	tagFormat := released.Tag
+
+	// 2. The hotfix branch starts at the tag, unless an earlier run created it
+	branch := strings.ReplaceAll(opts.BranchName, "{tag}", tag)
+	if _, err := gitClient.RevParse("refs/heads/" + branch); err != nil {
+		if err := gitClient.CreateBranch(branch, tagCommit); err != nil {
+			return fmt.Errorf("creating branch %s: %v", branch, err)
+		}
+		fmt.Printf("Created branch %s at %s\n", green(branch), tag)
+	} else {
+		fmt.Printf("Continuing with the existing branch %s\n", green(branch))
+	}
+
+	// 3. The fix is committed by hand
+	fixed, err := waitForHotfixCommits(tag, branch)
+	if err != nil || !fixed {
+		return err
+	}
+
+	// 4. The next free patch version is tagged on the hotfix branch
+	hotfixTag, err := promptForHotfixTag(tag, tagFormat)
+	if err != nil {
+		return err
+	}
+	remote := opts.Remote
+	switch {
+	case opts.NoPush || len(remoteURLs) == 0:
+		remote = ""
+	case remote == "":
+		if push, selected, _ := promptForPushToRemote(remoteURLs, config.Remotes, false); push {
+			remote = selected
+		}
+	}
+	plan, err := makePlan(config, remoteURLs, branch, tagFormat, tag, hotfixTag, remote)
+	if err != nil {
+		return err
+	}
+	// The hotfix branch goes along, so the fix is not only reachable from the tag
+	if plan.Remote != "" && len(config.Push.Refspecs) == 0 {
+		plan.PushArgs = append(plan.PushArgs, branch)
+	}
+	fmt.Print(formatPlan(plan))
+	if !prompter.Confirm(fmt.Sprintf("Tag %s on %s?", hotfixTag, branch), true) {
+		fmt.Printf("Hotfix not tagged; run git-publish hotfix %s again to continue.\n", tag)
+		return nil
+	}
+	executePlan(plan, config)
+
+	// 5. The fix goes back into the branch the tag was released from
+	into := opts.MergeInto
+	if into == "" {
+		into = released.Branch
+		if !prompter.Confirm(fmt.Sprintf("Merge %s back into %s?", branch, into), true) {
+			fmt.Printf("Not merged; merge %s back by hand when ready.\n", branch)
+			return nil
+		}
+	}
+	return mergeHotfix(branch, into, hotfixTag)
+}
+
+// waitForHotfixCommits asks to commit the fix on branch until it has commits
+// after tag. It returns false when the operator stops, to resume later.
+func waitForHotfixCommits(tag, branch string) (bool, error) {
+	for {
+		commits, err := gitClient.Commits("refs/tags/"+tag, "refs/heads/"+branch)
+		if err != nil {
+			return false, fmt.Errorf("listing the commits of %s: %v", branch, err)
+		}
+		if len(commits) > 0 {
+			fmt.Printf("%s on %s since %s:\n", plural(len(commits), "commit"), branch, tag)
+			for _, commit := range commits {
+				fmt.Printf("  %s\n", commit)
+			}
+			return true, nil
+		}
+
+		fmt.Printf("Commit the fix on %s, e.g.:\n", branch)
+		fmt.Printf("  git switch %s\n", branch)
+		fmt.Println("  git cherry-pick <commit>   (or fix and git commit)")
+		if !prompter.Confirm("Is the fix committed?", false) {
+			fmt.Printf("Run git-publish hotfix %s again once the fix is committed.\n", tag)
+			return false, nil
+		}
+	}
+}
+
+// promptForHotfixTag asks for the hotfix tag, suggesting the first patch
+// version after tag that is not taken yet
+func promptForHotfixTag(tag, tagFormat string) (string, error) {
+	suggested := nextFreePatch(tag, tagFormat)
+	pattern := tagPattern(tagFormat)
+	return prompter.Input(fmt.Sprintf("Hotfix tag (default: %s):", suggested), suggested, func(input string) error {
+		switch {
+		case !pattern.MatchString(input):
+			return fmt.Errorf("Invalid format! Tag should match %s", tagFormat)
+		case !isTagVersionGreater(input, tag):
+			return fmt.Errorf("the hotfix tag has to be greater than %s", tag)
+		case tagExists(input):
+			return fmt.Errorf("tag %s already exists", input)
+		}
+		return nil
+	})
+}
+
+// nextFreePatch returns the first patch version after tag without a tag,
+// skipping patches released meanwhile from another branch
+func nextFreePatch(tag, tagFormat string) string {
+	next := bumpTag(tag, tagFormat, bumpPatch)
+	for tagExists(next) {
+		next = bumpTag(next, tagFormat, bumpPatch)
+	}
+	return next
+}
+
+// mergeHotfix merges the hotfix branch into into, which is checked out for
+// that. A conflict is left for the operator to resolve.
+func mergeHotfix(branch, into, hotfixTag string) error {
+	if err := gitClient.Checkout(into); err != nil {
+		return fmt.Errorf("git checkout failed: %v; finish the merge of %s into %s by hand", err, branch, into)
+	}
+	if err := gitClient.Merge(branch, fmt.Sprintf("Merge hotfix %s", hotfixTag)); err != nil {
+		return fmt.Errorf("git merge failed: %v; finish the merge of %s into %s by hand", err, branch, into)
+	}
+	fmt.Printf("Merged %s into %s; push %s when ready.\n", branch, into, into)
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNextFreePatch(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c")
+	g.tags = map[string]string{"v1.4.2": "a", "v1.4.3": "b", "v1.5.0": "c"}
+	useFakeGit(t, g)
+
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"v1.4.2", "v1.4.4"},
+		{"v1.4.3", "v1.4.4"},
+		{"v1.5.0", "v1.5.1"},
+	}
+	for _, tt := range tests {
+		if got := nextFreePatch(tt.tag, "v0.0.0"); got != tt.want {
+			t.Errorf("nextFreePatch(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestWaitForHotfixCommits(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.tags = map[string]string{"v1.4.2": "a"}
+	g.branches["hotfix/v1.4.2"] = "a"
+	useFakeGit(t, g)
+
+	useScriptedPrompter(t, "n")
+	var fixed bool
+	output := captureOutput(func() { fixed, _ = waitForHotfixCommits("v1.4.2", "hotfix/v1.4.2") })
+	if fixed {
+		t.Error("waitForHotfixCommits() without commits = true")
+	}
+	if !strings.Contains(output, "git switch hotfix/v1.4.2") {
+		t.Errorf("output %q does not explain how to commit the fix", output)
+	}
+
+	// The fix is committed while the question is open
+	g.parents["fix"] = "a"
+	g.branches["hotfix/v1.4.2"] = "fix"
+	useScriptedPrompter(t)
+	output = captureOutput(func() { fixed, _ = waitForHotfixCommits("v1.4.2", "hotfix/v1.4.2") })
+	if !fixed || !strings.Contains(output, "1 commit on hotfix/v1.4.2 since v1.4.2") {
+		t.Errorf("waitForHotfixCommits() = %v with output %q, want the fix listed", fixed, output)
+	}
+}
+
+func TestRunHotfix(t *testing.T) {
+	config := Config{BranchTags: []BranchTagConfig{{Branch: "main", Tag: "v0.0.0"}}}
+	opts := hotfixOptions{BranchName: "hotfix/{tag}", NoPush: true}
+
+	tests := []struct {
+		name       string
+		tag        string
+		wantErr    string
+		wantBranch bool
+	}{
+		{"unknown tag", "v9.9.9", "does not exist", false},
+		{"other tag format", "app-1.0.0", "matches no configured tag format", false},
+		{"stops without a fix", "v1.4.2", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGitClient("a", "b")
+			g.tags = map[string]string{"v1.4.2": "a", "app-1.0.0": "a"}
+			useFakeGit(t, g)
+			useScriptedPrompter(t, "n")
+
+			var err error
+			captureOutput(func() { err = runHotfix(config, nil, tt.tag, opts) })
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("runHotfix() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := g.branches["hotfix/v1.4.2"]; (got == "a") != tt.wantBranch {
+				t.Errorf("hotfix branch at %q, want it created: %v", got, tt.wantBranch)
+			}
+		})
+	}
+}
+
+func TestMergeHotfix(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.branches["hotfix/v1.4.3"] = "a"
+	g.current = "hotfix/v1.4.3"
+	useFakeGit(t, g)
+
+	var err error
+	captureOutput(func() { err = mergeHotfix("hotfix/v1.4.3", "main", "v1.4.3") })
+	if err != nil {
+		t.Fatalf("mergeHotfix() error = %v", err)
+	}
+	if g.current != "main" || g.parents[g.branches["main"]] != "b" || g.subjects[g.branches["main"]] != "Merge hotfix v1.4.3" {
+		t.Errorf("main at %s (parent %s, %q) with %s checked out, want the merge commit on main", g.branches["main"], g.parents[g.branches["main"]], g.subjects[g.branches["main"]], g.current)
+	}
+
+	captureOutput(func() { err = mergeHotfix("hotfix/v1.4.3", "release", "v1.4.3") })
+	if err == nil || !strings.Contains(err.Error(), "finish the merge of hotfix/v1.4.3 into release by hand") {
+		t.Errorf("mergeHotfix() into a missing branch = %v, want the manual merge hint", err)
+	}
+}
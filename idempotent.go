@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// How far a requested tag has been published, see publishedState
+const (
+	tagNew       = "new"
+	tagUnpushed  = "unpushed"
+	tagPublished = "published"
+)
+
+// publishedState reports how far tag has been published at commit: not yet,
+// created locally but not pushed to remote, or created and pushed (with
+// remote "", creating it is all there is to do). A tag pointing at another
+// commit, locally or on the remote, is an error. When the remote cannot be
+// asked, the tag counts as unpushed: pushing an identical tag again is harmless.
+func publishedState(tag, commit, remote string) (string, error) {
+	local, err := gitClient.RevParse("refs/tags/" + tag + "^{commit}")
+	if err != nil {
+		return tagNew, nil
+	}
+	if local != commit {
+		return "", fmt.Errorf("tag %s already exists at %s, not at %s", tag, shortHash(local), shortHash(commit))
+	}
+	if remote == "" {
+		return tagPublished, nil
+	}
+
+	pushed, _, err := gitClient.RemoteTag(remote, tag)
+	switch {
+	case err != nil:
+		fmt.Printf("Warning: could not check tag %s on remote %s: %v\n", tag, remote, err)
+		return tagUnpushed, nil
+	case pushed == "":
+		return tagUnpushed, nil
+	case pushed != commit:
+		return "", fmt.Errorf("tag %s already exists on remote %s at %s, not at %s", tag, remote, shortHash(pushed), shortHash(commit))
+	}
+	return tagPublished, nil
+}
+
+// shortHash abbreviates a commit hash for messages
+func shortHash(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+// rerunRemote returns the remote a re-run with the given options pushes to,
+// as far as it is known before the remote is selected: the --remote flag, none
+// with --no-push or without remotes, or the only remote
+func rerunRemote(opts *publishOptions, remoteURLs map[string]string) (string, bool) {
+	switch {
+	case opts.Remote != "":
+		return opts.Remote, true
+	case opts.NoPush || len(remoteURLs) == 0:
+		return "", true
+	case len(remoteURLs) == 1:
+		for remote := range remoteURLs {
+			return remote, true
+		}
+	}
+	return "", false
+}
+
+// checkRerun makes publishing an existing tag idempotent for retried jobs: it
+// exits successfully when tag is already published at the commit of branch and
+// reports whether the tag exists but still has to be pushed
+func checkRerun(tag, branch string, opts *publishOptions, remoteURLs map[string]string) bool {
+	commit, err := lookupCommit(branch)
+	if err != nil {
+		return false
+	}
+	remote, known := rerunRemote(opts, remoteURLs)
+	state, err := publishedState(tag, commit, remote)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case state == tagPublished && known && remote != "":
+		fmt.Printf("Tag %s is already published at %s on %s; nothing to do.\n", tag, shortHash(commit), remote)
+		os.Exit(0)
+	case state == tagPublished && known:
+		fmt.Printf("Tag %s already exists at %s; nothing to do.\n", tag, shortHash(commit))
+		os.Exit(0)
+	case state != tagNew:
+		fmt.Printf("Tag %s already exists at %s; resuming with the remaining steps.\n", tag, shortHash(commit))
+		return true
+	}
+	return false
+}
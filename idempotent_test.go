@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPublishedState(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.tags = map[string]string{"v1.0.0": "a", "v1.1.0": "b"}
+	g.remotes = map[string]string{"origin": "git@example.com:acme/tool.git", "mirror": "/srv/git/tool.git"}
+	g.remoteTags = map[string]string{"origin/v1.0.0": "a", "origin/v1.1.0": "a"}
+	useFakeGit(t, g)
+
+	tests := []struct {
+		name    string
+		tag     string
+		commit  string
+		remote  string
+		want    string
+		wantErr bool
+	}{
+		{"new tag", "v1.2.0", "b", "origin", tagNew, false},
+		{"pushed", "v1.0.0", "a", "origin", tagPublished, false},
+		{"local only run", "v1.1.0", "b", "", tagPublished, false},
+		{"created, not pushed", "v1.0.0", "a", "mirror", tagUnpushed, false},
+		{"at another commit", "v1.0.0", "b", "origin", "", true},
+		{"at another commit on the remote", "v1.1.0", "b", "origin", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := publishedState(tt.tag, tt.commit, tt.remote)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("publishedState(%s, %s, %q) = %q, %v, want %q (error %v)", tt.tag, tt.commit, tt.remote, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	// A remote that cannot be asked gets the tag pushed again
+	if got, err := publishedState("v1.0.0", "a", "unknown"); got != tagUnpushed || err != nil {
+		t.Errorf("publishedState() with an unreachable remote = %q, %v, want unpushed", got, err)
+	}
+}
+
+func TestRerunRemote(t *testing.T) {
+	one := map[string]string{"origin": "git@example.com:acme/tool.git"}
+	two := map[string]string{"origin": "git@example.com:acme/tool.git", "fork": "git@example.com:me/tool.git"}
+
+	tests := []struct {
+		name       string
+		opts       publishOptions
+		remoteURLs map[string]string
+		want       string
+		wantKnown  bool
+	}{
+		{"flag", publishOptions{Remote: "fork"}, two, "fork", true},
+		{"no push", publishOptions{NoPush: true}, two, "", true},
+		{"no remotes", publishOptions{}, nil, "", true},
+		{"only remote", publishOptions{}, one, "origin", true},
+		{"chosen later", publishOptions{}, two, "", false},
+	}
+	for _, tt := range tests {
+		if got, known := rerunRemote(&tt.opts, tt.remoteURLs); got != tt.want || known != tt.wantKnown {
+			t.Errorf("%s: rerunRemote() = %q, %v, want %q, %v", tt.name, got, known, tt.want, tt.wantKnown)
+		}
+	}
+}
+
+func TestRunPlanStepsExistingTag(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.tags["v1.0.0"] = "b"
+	useFakeGit(t, g)
+
+	created := 0
+	bus := &eventBus{handlers: map[string][]eventHandler{}}
+	bus.subscribe(func(e event) error { created++; return nil }, eventTagCreated)
+
+	plan := Plan{Branch: "main", TargetCommit: "b", Tag: "v1.0.0", TagExists: true, Remote: "origin", PushArgs: []string{"push", "origin", "v1.0.0"}}
+	if err := publishPlan(plan, Config{}, bus); err != nil {
+		t.Fatalf("publishPlan() error = %v", err)
+	}
+	if created != 0 || len(g.pushes) != 1 {
+		t.Errorf("publishPlan() of an existing tag created it %d times and pushed %d times, want only a push", created, len(g.pushes))
+	}
+	if fmt.Sprint(g.pushes[0]) != "[origin v1.0.0]" {
+		t.Errorf("pushed %v", g.pushes[0])
+	}
+}
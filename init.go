@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// runInit implements the init command: it writes the configuration file and
+// bootstraps tag protection rules on the hosting service
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	remoteName := fs.String("remote", "origin", "remote whose hosting service should protect tags")
+	skipProtection := fs.Bool("skip-protection", false, "do not create tag protection rules")
+	preset := fs.String("preset", "", "create publish.json with the tag conventions of terraform modules")
+	fs.Parse(args)
+
+	green := color.New(color.FgGreen).SprintFunc()
+
+	// readConfig writes the default configuration when none exists
+	configPath, err := repositoryConfigPath()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	_, statErr := os.Stat(configPath)
+	switch {
+	case *preset != "" && *preset != presetTerraform:
+		fmt.Printf("Error: preset %q is not %s\n", *preset, presetTerraform)
+		os.Exit(1)
+	case *preset != "" && !os.IsNotExist(statErr):
+		fmt.Printf("Error: %s already exists; set \"preset\": %q in it instead\n", configPath, *preset)
+		os.Exit(1)
+	case *preset != "":
+		fmt.Printf("Creating %s with the %s preset...\n", configPath, *preset)
+		if err := writeTerraformConfig(configPath); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case os.IsNotExist(statErr):
+		fmt.Printf("Creating %s with the default configuration...\n", configPath)
+	default:
+		fmt.Printf("Using existing %s\n", configPath)
+	}
+	config := readConfig()
+
+	if *skipProtection {
+		fmt.Println(green("Initialization complete!"))
+		return
+	}
+
+	remoteURL, ok := getAllRemoteURLs()[*remoteName]
+	if !ok {
+		fmt.Printf("Remote '%s' not found. Skipping tag protection.\n", *remoteName)
+		return
+	}
+
+	p, err := newProvider(remoteURL, config.Provider)
+	if err != nil {
+		fmt.Printf("Warning: %v. Skipping tag protection.\n", err)
+		return
+	}
+	if !p.HasToken() {
+		fmt.Printf("Warning: No %s API token found (see API tokens in the readme). Skipping tag protection.\n", p.Name())
+		return
+	}
+	if err := checkTokenPermissions(p, actionProtectTags); err != nil {
+		fmt.Printf("Error configuring tag protection: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := syncTagProtection(p, tagPatterns(config)); err == errNotSupported {
+		fmt.Printf("Warning: Tag protection is not supported on %s; configure tag permissions in its repository settings.\n", p.Name())
+	} else if err != nil {
+		fmt.Printf("Error configuring tag protection: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(green("Initialization complete!"))
+}
+
+// syncTagProtection creates protection rules for every pattern that is not protected yet
+func syncTagProtection(p provider, patterns []string) error {
+	existing, err := p.ProtectedTagPatterns()
+	if err != nil {
+		return err
+	}
+
+	var supported []string
+	for _, pattern := range patterns {
+		protected := protectionPattern(p, pattern)
+		if protected != pattern {
+			fmt.Printf("Warning: %s only matches * in tag patterns; protecting %s for tags matching %s\n", p.Name(), protected, pattern)
+		}
+		supported = append(supported, protected)
+	}
+	for _, pattern := range uniqueStrings(supported) {
+		if contains(existing, pattern) {
+			fmt.Printf("Tags matching %s are already protected on %s\n", pattern, p.Name())
+			continue
+		}
+		if err := p.ProtectTagPattern(pattern); err != nil {
+			return err
+		}
+		fmt.Printf("Protected tags matching %s on %s\n", pattern, p.Name())
+	}
+
+	return nil
+}
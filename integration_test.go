@@ -149,36 +149,5 @@ func TestIntegrationGitTag(t *testing.T) {
 		t.Errorf("Tag v0.0.1 was not created")
 	}
 
-	// The full integration test would now run the main function with mocked stdin/stdout
-	// and verify the behavior of the tool
-	// This requires more complex mocking of user input and is beyond the scope of this example
+	// The interactive flow itself is covered by the scripted scenarios in e2e_test.go
 }
-
-// Example of a mocked user input scenario for manual testing
-/*
-func TestScenarioCreateTagOnMaster(t *testing.T) {
-	// For this to work, we would need to mock os.Stdin and os.Stdout
-	// and implement a way to programmatically provide user input
-
-	dir, cleanup := setupGitRepo(t)
-	defer cleanup()
-
-	// Mock stdin with predefined answers:
-	// 1 (select master branch)
-	// v1.0.0 (enter tag)
-	// n (don't push to remote)
-
-	// Call main() with mocked I/O
-
-	// Verify the tag was created
-	cmd := exec.Command("git", "tag", "-l", "v1.0.0")
-	output, err := cmd.Output()
-	if err != nil {
-		t.Fatalf("Failed to list tags: %v", err)
-	}
-
-	if len(output) == 0 {
-		t.Errorf("Tag v1.0.0 was not created")
-	}
-}
-*/
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configLayer is one source of configuration values. Values uses the JSON
+// shape of publish.json; layers later in a stack take precedence.
+type configLayer struct {
+	Origin string
+	Values map[string]interface{}
+}
+
+// resolvedConfig is the effective configuration and where each value came from
+type resolvedConfig struct {
+	Config Config
+	// Origins maps the key path of every value (e.g. push.sshKey) to its layer
+	Origins map[string]string
+	// Values holds the merged values in the JSON shape of publish.json
+	Values map[string]interface{}
+}
+
+// configEnvVars maps environment variables to the configuration value they set
+var configEnvVars = []struct {
+	Name string
+	Key  string
+	Bool bool
+}{
+	{"GIT_PUBLISH_SSH_KEY", "push.sshKey", false},
+	{"GIT_PUBLISH_RELEASE", "release.create", true},
+	{"GIT_PUBLISH_BUMP_SUGGEST", "bump.suggest", true},
+	{"GIT_PUBLISH_GO_API_CHECK", "goApiCheck", false},
+	{"GIT_PUBLISH_PROVIDER", "provider.type", false},
+	{"GIT_PUBLISH_PROVIDER_API_URL", "provider.apiUrl", false},
+	{"GIT_PUBLISH_PROVIDER_CLI", "provider.cli", false},
+	{"GIT_PUBLISH_STRICT", "strict", true},
+}
+
+// readConfigLayers returns the configuration layers from lowest to highest
+// precedence: built-in defaults, user config, publish.json, environment and
+// flags. Configuration files that cannot be used are left out and returned as problems.
+func readConfigLayers(opts *publishOptions) ([]configLayer, []error) {
+	var problems []error
+	layers := []configLayer{{Origin: "default", Values: configValues(defaultConfig)}}
+
+	userConfig, _, err := loadUserConfig()
+	if err != nil {
+		problems = append(problems, err)
+	} else if len(userConfig.Publish) > 0 {
+		path, _ := userConfigPath()
+		layers = append(layers, configLayer{Origin: "user config (" + path + ")", Values: userConfig.Publish})
+	}
+
+	layer, err := readRepositoryLayer()
+	if err != nil {
+		problems = append(problems, err)
+	}
+	if layer.Values != nil {
+		layers = append(layers, layer)
+	}
+
+	for _, v := range configEnvVars {
+		value := os.Getenv(v.Name)
+		if value == "" {
+			continue
+		}
+		var parsed interface{} = value
+		if v.Bool {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				fmt.Printf("Warning: ignoring %s=%s, expected true or false\n", v.Name, value)
+				continue
+			}
+			parsed = b
+		}
+		layers = append(layers, configLayer{Origin: "environment (" + v.Name + ")", Values: keyValue(v.Key, parsed)})
+	}
+
+	if opts != nil && opts.SSHKey != "" {
+		layers = append(layers, configLayer{Origin: "flag (--ssh-key)", Values: keyValue("push.sshKey", opts.SSHKey)})
+	}
+	if opts != nil && opts.GateReport != "" {
+		layers = append(layers, configLayer{Origin: "flag (--gate-report)", Values: keyValue("gateReport", opts.GateReport)})
+	}
+	if opts != nil && opts.Strict {
+		layers = append(layers, configLayer{Origin: "flag (--strict)", Values: keyValue("strict", true)})
+	}
+	return layers, problems
+}
+
+// readRepositoryLayer reads publish.json, writing the default configuration
+// first if it does not exist yet. When the file cannot be used the error says
+// why; the layer then only holds what could still be read, if anything.
+func readRepositoryLayer() (configLayer, error) {
+	configPath, err := repositoryConfigPath()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	layer := configLayer{Origin: "publish.json (" + configPath + ")"}
+
+	var fileContent []byte
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		// A sparse checkout may leave the committed file out of the work tree
+		data, tracked, err := readIndexFile(configPath)
+		if err != nil {
+			return layer, fmt.Errorf("reading %s: %v", configPath, err)
+		}
+		if !tracked {
+			// Write default config if file doesn't exist
+			config := repositoryDefaultConfig()
+			writeDefaultConfig(configPath, config)
+			layer.Values = configValues(Config{BranchTags: config.BranchTags})
+			return layer, nil
+		}
+		logFor(logConfig).Debug("reading config from the index", "path", configPath)
+		layer.Origin = "publish.json (index, outside the sparse checkout)"
+		fileContent = data
+	} else {
+		// Read config file
+		logFor(logConfig).Debug("reading config", "path", configPath)
+		fileContent, err = os.ReadFile(configPath)
+		if err != nil {
+			return layer, fmt.Errorf("reading %s: %v", configPath, err)
+		}
+	}
+
+	if err := checkConfigVersion(configPath, fileContent); err != nil {
+		return layer, err
+	}
+
+	// Parse config file, checking the types of known values as well
+	var values map[string]interface{}
+	if err := json.Unmarshal(fileContent, &values); err != nil {
+		return layer, fmt.Errorf("parsing %s: %v", configPath, err)
+	}
+	var config Config
+	if err := json.Unmarshal(fileContent, &config); err != nil {
+		// Keep a strict setting, so the file can still ask not to fall back
+		if strict, _ := values["strict"].(bool); strict {
+			layer.Values = keyValue("strict", true)
+		}
+		return layer, fmt.Errorf("parsing %s: %v", configPath, err)
+	}
+	layer.Values = values
+
+	// Validate config
+	if len(config.BranchTags) == 0 {
+		delete(layer.Values, "branchTags")
+		return layer, fmt.Errorf("%s is valid but has no branchTags", configPath)
+	}
+	return layer, nil
+}
+
+// loadConfig resolves the configuration layers, with opts as the flag layer.
+// Problems with the configuration files are reported as warnings and the
+// defaults are used instead, unless strict mode is on: then they are errors,
+// as is a resolved configuration that fails validation.
+func loadConfig(opts *publishOptions) (resolvedConfig, error) {
+	layers, problems := readConfigLayers(opts)
+	resolved, err := resolveConfig(layers)
+	if err != nil {
+		return resolved, err
+	}
+	for _, collision := range tagPrefixCollisions(resolved.Config) {
+		fmt.Printf("Warning: %s\n", collision)
+	}
+
+	if !resolved.Config.Strict {
+		for _, problem := range problems {
+			fmt.Printf("Warning: %v\n", problem)
+			fmt.Println("Using default configuration")
+		}
+		return resolved, nil
+	}
+
+	if len(problems) > 0 {
+		return resolved, fmt.Errorf("%v (strict mode does not fall back to the default configuration)", problems[0])
+	}
+	if err := validateConfig(resolved.Config); err != nil {
+		return resolved, fmt.Errorf("%v (strict mode)", err)
+	}
+	return resolved, nil
+}
+
+// reloadConfig resolves the configuration layers again for a long-running
+// mode. Unlike loadConfig it never falls back to the defaults: a configuration
+// file that cannot be used, or a result that fails validation, is an error, so
+// the caller keeps the configuration it has.
+func reloadConfig(opts *publishOptions) (Config, error) {
+	layers, problems := readConfigLayers(opts)
+	if len(problems) > 0 {
+		return Config{}, problems[0]
+	}
+	resolved, err := resolveConfig(layers)
+	if err != nil {
+		return Config{}, err
+	}
+	return resolved.Config, validateConfig(resolved.Config)
+}
+
+// resolveConfig merges the layers and decodes the result
+func resolveConfig(layers []configLayer) (resolvedConfig, error) {
+	resolved := resolvedConfig{Origins: map[string]string{}, Values: map[string]interface{}{}}
+	for _, layer := range layers {
+		mergeValues(resolved.Values, layer.Values, "", layer.Origin, resolved.Origins)
+	}
+
+	data, err := json.Marshal(resolved.Values)
+	if err != nil {
+		return resolved, err
+	}
+	if err := json.Unmarshal(data, &resolved.Config); err != nil {
+		return resolved, fmt.Errorf("combining configuration layers: %v", err)
+	}
+	return resolved, nil
+}
+
+// mergeValues copies src into dst. Objects are merged key by key, anything
+// else (including lists) replaces the previous value; origins records the
+// origin of every replaced key path.
+func mergeValues(dst, src map[string]interface{}, prefix, origin string, origins map[string]string) {
+	for key, value := range src {
+		path := prefix + key
+		if object, ok := value.(map[string]interface{}); ok {
+			existing, ok := dst[key].(map[string]interface{})
+			if !ok {
+				existing = map[string]interface{}{}
+				dst[key] = existing
+			}
+			mergeValues(existing, object, path+".", origin, origins)
+			continue
+		}
+		dst[key] = value
+		origins[path] = origin
+	}
+}
+
+// configValues converts a configuration to its JSON shape, leaving out empty sections
+func configValues(config Config) map[string]interface{} {
+	var values map[string]interface{}
+	data, _ := json.Marshal(config)
+	json.Unmarshal(data, &values)
+	for key, value := range values {
+		if object, ok := value.(map[string]interface{}); ok && len(object) == 0 {
+			delete(values, key)
+		}
+	}
+	return values
+}
+
+// keyValue returns the JSON shape setting the dotted key path to value
+func keyValue(key string, value interface{}) map[string]interface{} {
+	parts := strings.Split(key, ".")
+	values := map[string]interface{}{parts[len(parts)-1]: value}
+	for i := len(parts) - 2; i >= 0; i-- {
+		values = map[string]interface{}{parts[i]: values}
+	}
+	return values
+}
+
+// flattenValues lists the leaf values of the JSON shape by key path, sorted
+func flattenValues(values map[string]interface{}, prefix string) []string {
+	var keys []string
+	for key, value := range values {
+		if object, ok := value.(map[string]interface{}); ok {
+			keys = append(keys, flattenValues(object, prefix+key+".")...)
+			continue
+		}
+		keys = append(keys, prefix+key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lookupValue returns the value at the key path, e.g. push.sshKey or branchTags[0].tag
+func lookupValue(values map[string]interface{}, key string) (interface{}, bool) {
+	path, err := parseKeyPath(key)
+	if err != nil {
+		return nil, false
+	}
+
+	var current interface{} = values
+	for _, part := range path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			name, ok := part.(string)
+			if !ok {
+				return nil, false
+			}
+			if current, ok = node[name]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			index, ok := part.(int)
+			if !ok || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
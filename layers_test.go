@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveConfig(t *testing.T) {
+	layers := []configLayer{
+		{Origin: "default", Values: configValues(defaultConfig)},
+		{Origin: "user", Values: map[string]interface{}{
+			"goApiCheck": "warn",
+			"push":       map[string]interface{}{"followTags": true, "options": []interface{}{"ci.skip"}},
+		}},
+		{Origin: "repo", Values: map[string]interface{}{
+			"branchTags": []interface{}{map[string]interface{}{"branch": "main", "tag": "v0.0.0"}},
+			"push":       map[string]interface{}{"options": []interface{}{"merge_request.create"}},
+			"release":    map[string]interface{}{"create": true},
+		}},
+		{Origin: "env", Values: keyValue("release.create", false)},
+		{Origin: "flag", Values: keyValue("push.sshKey", "~/.ssh/release")},
+	}
+
+	resolved, err := resolveConfig(layers)
+	if err != nil {
+		t.Fatalf("resolveConfig() error = %v", err)
+	}
+
+	want := Config{
+		BranchTags: []BranchTagConfig{{Branch: "main", Tag: "v0.0.0"}},
+		Push:       PushConfig{Options: []string{"merge_request.create"}, FollowTags: true, SSHKey: "~/.ssh/release"},
+		GoAPICheck: "warn",
+	}
+	if !reflect.DeepEqual(resolved.Config, want) {
+		t.Errorf("resolveConfig().Config = %+v, want %+v", resolved.Config, want)
+	}
+
+	wantOrigins := map[string]string{
+		"branchTags":      "repo",
+		"goApiCheck":      "user",
+		"push.followTags": "user",
+		"push.options":    "repo",
+		"push.sshKey":     "flag",
+		"release.create":  "env",
+	}
+	if !reflect.DeepEqual(resolved.Origins, wantOrigins) {
+		t.Errorf("resolveConfig().Origins = %v, want %v", resolved.Origins, wantOrigins)
+	}
+
+	keys := flattenValues(resolved.Values, "")
+	if !reflect.DeepEqual(keys, []string{"branchTags", "goApiCheck", "push.followTags", "push.options", "push.sshKey", "release.create"}) {
+		t.Errorf("flattenValues() = %v", keys)
+	}
+	if value, ok := lookupValue(resolved.Values, "push.sshKey"); !ok || value != "~/.ssh/release" {
+		t.Errorf("lookupValue(push.sshKey) = %v, %v", value, ok)
+	}
+}
+
+func TestResolveConfigTypeError(t *testing.T) {
+	layers := []configLayer{{Origin: "user", Values: map[string]interface{}{"push": "yes"}}}
+	if _, err := resolveConfig(layers); err == nil {
+		t.Error("resolveConfig() succeeded with a mistyped value, want an error")
+	}
+}
+
+func TestReadConfigLayers(t *testing.T) {
+	root := t.TempDir()
+	g := newFakeGitClient("a")
+	g.root = root
+	useFakeGit(t, g)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "home"))
+	t.Setenv("HOME", filepath.Join(root, "home"))
+	t.Setenv("GIT_PUBLISH_GO_API_CHECK", "off")
+	t.Setenv("GIT_PUBLISH_RELEASE", "not-a-bool")
+
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+	os.Chdir(root)
+
+	tests := []struct {
+		name       string
+		content    string
+		wantBranch string
+		wantOrigin string
+		wantIssue  bool
+	}{
+		{"valid file", `{"branchTags": [{"branch": "trunk", "tag": "r0.0.0"}]}`, "trunk", "publish.json", false},
+		{"syntax error falls back", `{"branchTags": [`, "master", "default", true},
+		{"type error falls back", `{"branchTags": "trunk"}`, "master", "default", true},
+		{"empty branch tags fall back", `{"branchTags": [], "goApiCheck": "warn"}`, "master", "default", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.WriteFile(filepath.Join(root, "publish.json"), []byte(tt.content), 0644)
+
+			layers, problems := readConfigLayers(&publishOptions{SSHKey: "id_release"})
+			if (len(problems) > 0) != tt.wantIssue {
+				t.Errorf("readConfigLayers() problems = %v, want problems: %v", problems, tt.wantIssue)
+			}
+			resolved, err := resolveConfig(layers)
+			if err != nil {
+				t.Fatalf("resolveConfig() error = %v", err)
+			}
+			if resolved.Config.BranchTags[0].Branch != tt.wantBranch {
+				t.Errorf("first branch = %q, want %q", resolved.Config.BranchTags[0].Branch, tt.wantBranch)
+			}
+			if origin := resolved.Origins["branchTags"]; origin[:len(tt.wantOrigin)] != tt.wantOrigin {
+				t.Errorf("branchTags origin = %q, want %s", origin, tt.wantOrigin)
+			}
+			// The environment beats the file, invalid values are ignored
+			if resolved.Config.GoAPICheck != "off" || resolved.Config.Release.Create {
+				t.Errorf("environment layer not applied: %+v", resolved.Config)
+			}
+			if resolved.Config.Push.SSHKey != "id_release" || resolved.Origins["push.sshKey"] != "flag (--ssh-key)" {
+				t.Errorf("flag layer not applied: %+v", resolved.Config.Push)
+			}
+		})
+	}
+}
+
+func TestLoadConfigStrict(t *testing.T) {
+	root := t.TempDir()
+	g := newFakeGitClient("a")
+	g.root = root
+	useFakeGit(t, g)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "home"))
+	t.Setenv("HOME", filepath.Join(root, "home"))
+
+	cwd, _ := os.Getwd()
+	defer os.Chdir(cwd)
+	os.Chdir(root)
+
+	tests := []struct {
+		name    string
+		content string
+		strict  bool
+		env     string
+		wantErr bool
+	}{
+		{"valid file", `{"branchTags": [{"branch": "main", "tag": "v0.0.0"}]}`, true, "", false},
+		{"syntax error without strict", `{"branchTags": [`, false, "", false},
+		{"syntax error with --strict", `{"branchTags": [`, true, "", true},
+		{"syntax error with GIT_PUBLISH_STRICT", `{"branchTags": [`, false, "true", true},
+		{"type error with strict in the file", `{"strict": true, "branchTags": "main"}`, false, "", true},
+		{"empty branch tags with strict", `{"strict": true, "branchTags": []}`, false, "", true},
+		{"invalid tag format with strict", `{"strict": true, "branchTags": [{"branch": "main", "tag": "v1"}]}`, false, "", true},
+		{"invalid tag format without strict", `{"branchTags": [{"branch": "main", "tag": "v1"}]}`, false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.WriteFile(filepath.Join(root, "publish.json"), []byte(tt.content), 0644)
+			t.Setenv("GIT_PUBLISH_STRICT", tt.env)
+
+			_, err := loadConfig(&publishOptions{Strict: tt.strict})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadConfig() error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// lintFormat is the result of linting the tags of one tag format
+type lintFormat struct {
+	Format string
+	// Branches are the configured branches of the format that exist
+	Branches []string
+	// Missing are the configured branches of the format that do not exist
+	Missing []string
+	Tags    int
+	// Violations are the tags not reachable from any of Branches
+	Violations []lintViolation
+}
+
+// lintViolation is a tag that is not on the branches of its format
+type lintViolation struct {
+	Tag string
+	// OnBranches are the other configured branches the tag is reachable from
+	OnBranches []string
+}
+
+// runLintCommand implements the lint command: it checks that the existing tags
+// of every configured format are reachable from the branches of that format
+func runLintCommand(args []string) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	fs.Parse(args)
+
+	results := lintTags(readConfig())
+	violations := 0
+	for _, result := range results {
+		fmt.Print(formatLintResult(result))
+		violations += len(result.Violations)
+	}
+	if violations > 0 {
+		fmt.Printf("Error: %d tag(s) are not on the branches of their format\n", violations)
+		os.Exit(1)
+	}
+	fmt.Println("All tags are on the branches of their format")
+}
+
+// lintTags checks the tags of every configured format against its branches.
+// Branches sharing a format (main and master) are checked together; a tag has
+// to be reachable from one of them.
+func lintTags(config Config) []lintFormat {
+	var formats []string
+	branches := map[string][]string{}
+	for _, bt := range config.BranchTags {
+		if _, ok := branches[bt.Tag]; !ok {
+			formats = append(formats, bt.Tag)
+		}
+		branches[bt.Tag] = append(branches[bt.Tag], bt.Branch)
+	}
+
+	var results []lintFormat
+	for _, format := range formats {
+		result := lintFormat{Format: format}
+		for _, branch := range branches[format] {
+			if _, err := lookupCommit(branch); err != nil {
+				result.Missing = append(result.Missing, branch)
+			} else {
+				result.Branches = append(result.Branches, branch)
+			}
+		}
+		if len(result.Branches) == 0 {
+			results = append(results, result)
+			continue
+		}
+
+		prefix := extractPrefix(format)
+		tags, err := gitClient.ListTags(tagGlob(format))
+		if err != nil {
+			fmt.Printf("Error getting tags: %v\n", err)
+			os.Exit(1)
+		}
+		for _, tag := range tags {
+			if !validateTagFormat(tag, prefix) {
+				continue
+			}
+			result.Tags++
+			if onAnyBranch(tag, result.Branches) {
+				continue
+			}
+			var on []string
+			for _, bt := range config.BranchTags {
+				if !contains(on, bt.Branch) && isTagOnBranchFunc(tag, bt.Branch) {
+					on = append(on, bt.Branch)
+				}
+			}
+			result.Violations = append(result.Violations, lintViolation{Tag: tag, OnBranches: on})
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// onAnyBranch reports whether tag is reachable from one of branches
+func onAnyBranch(tag string, branches []string) bool {
+	for _, branch := range branches {
+		if isTagOnBranchFunc(tag, branch) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatLintResult renders the result of one tag format
+func formatLintResult(result lintFormat) string {
+	var b strings.Builder
+	configured := append(append([]string{}, result.Branches...), result.Missing...)
+	fmt.Fprintf(&b, "%s (%s): ", result.Format, strings.Join(configured, ", "))
+	if len(result.Branches) == 0 {
+		b.WriteString("skipped, no configured branch exists\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%s, %d not on %s\n", plural(result.Tags, "tag"), len(result.Violations), strings.Join(result.Branches, " or "))
+	for _, branch := range result.Missing {
+		fmt.Fprintf(&b, "  Warning: branch %s does not exist\n", branch)
+	}
+	for _, v := range result.Violations {
+		where := "on no configured branch"
+		if len(v.OnBranches) > 0 {
+			where = "only on " + strings.Join(v.OnBranches, ", ")
+		}
+		fmt.Fprintf(&b, "  %s is %s\n", v.Tag, where)
+	}
+	return b.String()
+}
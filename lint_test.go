@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintTags(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c")
+	g.parents["g1"] = "a"
+	g.parents["orphan"] = ""
+	g.branches["gray"] = "g1"
+	g.tags = map[string]string{
+		"v1.0.0": "a",
+		"v1.1.0": "c",
+		"v1.2.0": "g1",
+		"v0.9.0": "orphan",
+		"g1.0.0": "g1",
+		"g1.1.0": "b",
+		"v-next": "c",
+	}
+	useFakeGit(t, g)
+
+	got := lintTags(Config{BranchTags: defaultConfig.BranchTags})
+	want := []lintFormat{
+		{
+			Format:   "v0.0.0",
+			Branches: []string{"main"},
+			Missing:  []string{"master"},
+			Tags:     4,
+			Violations: []lintViolation{
+				{Tag: "v1.2.0", OnBranches: []string{"gray"}},
+				{Tag: "v0.9.0"},
+			},
+		},
+		{
+			Format:     "g0.0.0",
+			Branches:   []string{"gray"},
+			Tags:       2,
+			Violations: []lintViolation{{Tag: "g1.1.0", OnBranches: []string{"main"}}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lintTags() = %+v, want %+v", got, want)
+	}
+
+	output := formatLintResult(got[0])
+	for _, line := range []string{
+		"v0.0.0 (main, master): 4 tags, 2 not on main",
+		"Warning: branch master does not exist",
+		"v1.2.0 is only on gray",
+		"v0.9.0 is on no configured branch",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("formatLintResult() = %q, want it to contain %q", output, line)
+		}
+	}
+}
+
+func TestLintTagsWithoutBranches(t *testing.T) {
+	g := newFakeGitClient("a")
+	g.tags = map[string]string{"g1.0.0": "a"}
+	useFakeGit(t, g)
+
+	got := lintTags(Config{BranchTags: []BranchTagConfig{{Branch: "gray", Tag: "g0.0.0"}}})
+	if len(got) != 1 || got[0].Tags != 0 || len(got[0].Violations) != 0 {
+		t.Errorf("lintTags() = %+v, want the format skipped", got)
+	}
+	if output := formatLintResult(got[0]); !strings.Contains(output, "skipped") {
+		t.Errorf("formatLintResult() = %q, want it skipped", output)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Log modules scope log records by the part of the tool that emitted them
+const (
+	logGit      = "git"
+	logConfig   = "config"
+	logAPI      = "api"
+	logUI       = "ui"
+	logSchedule = "schedule"
+	logServe    = "serve"
+)
+
+// logSettings holds the logging configuration selected on the command line
+var logSettings = struct {
+	mu      sync.Mutex
+	format  string
+	level   slog.Level
+	modules map[string]slog.Level
+	out     io.Writer
+	loggers map[string]*slog.Logger
+}{
+	format: "text",
+	level:  slog.LevelWarn,
+	out:    os.Stderr,
+}
+
+// configureLogging sets the log format (text or json) and levels. The level
+// spec is a default level optionally followed by per-module levels, e.g.
+// "info,api=debug".
+func configureLogging(format, levelSpec string, out io.Writer) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown log format %q (use text or json)", format)
+	}
+
+	level := slog.LevelWarn
+	modules := make(map[string]slog.Level)
+	for _, part := range strings.Split(levelSpec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		module, name, scoped := strings.Cut(part, "=")
+		if !scoped {
+			name = module
+		}
+		var parsed slog.Level
+		if err := parsed.UnmarshalText([]byte(name)); err != nil {
+			return fmt.Errorf("unknown log level %q", name)
+		}
+		if scoped {
+			modules[module] = parsed
+		} else {
+			level = parsed
+		}
+	}
+
+	logSettings.mu.Lock()
+	defer logSettings.mu.Unlock()
+	logSettings.format = format
+	logSettings.level = level
+	logSettings.modules = modules
+	logSettings.out = out
+	logSettings.loggers = nil
+	return nil
+}
+
+// logFor returns the logger of a module
+func logFor(module string) *slog.Logger {
+	logSettings.mu.Lock()
+	defer logSettings.mu.Unlock()
+
+	if logger, ok := logSettings.loggers[module]; ok {
+		return logger
+	}
+
+	level, ok := logSettings.modules[module]
+	if !ok {
+		level = logSettings.level
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if logSettings.format == "json" {
+		handler = slog.NewJSONHandler(logSettings.out, options)
+	} else {
+		handler = slog.NewTextHandler(logSettings.out, options)
+	}
+
+	logger := slog.New(handler).With("module", module)
+	if logSettings.loggers == nil {
+		logSettings.loggers = make(map[string]*slog.Logger)
+	}
+	logSettings.loggers[module] = logger
+	return logger
+}
+
+// extractLogFlags removes --log-level and --log-format from args, wherever
+// they appear, and returns their values (defaulting to the environment)
+func extractLogFlags(args []string) (rest []string, format, level string) {
+	format = os.Getenv("GIT_PUBLISH_LOG_FORMAT")
+	if format == "" {
+		format = "text"
+	}
+	level = os.Getenv("GIT_PUBLISH_LOG_LEVEL")
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var target *string
+		name := strings.TrimLeft(arg, "-")
+		name, value, hasValue := strings.Cut(name, "=")
+		switch {
+		case !strings.HasPrefix(arg, "-"):
+		case name == "log-format":
+			target = &format
+		case name == "log-level":
+			target = &level
+		}
+		if target == nil {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		*target = value
+	}
+	return rest, format, level
+}
+
+// loggedCommand is exec.Command logging every git invocation at debug level
+func loggedCommand(name string, args ...string) *exec.Cmd {
+	logFor(logGit).Debug("exec", "command", name, "args", args)
+	return exec.Command(name, args...)
+}
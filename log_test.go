@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestExtractLogFlags tests removing the logging flags from anywhere on the command line
+func TestExtractLogFlags(t *testing.T) {
+	testCases := []struct {
+		args   []string
+		rest   []string
+		format string
+		level  string
+	}{
+		{[]string{"plan", "--branch", "main"}, []string{"plan", "--branch", "main"}, "text", ""},
+		{[]string{"--log-format", "json", "plan"}, []string{"plan"}, "json", ""},
+		{[]string{"plan", "--log-level=info,api=debug", "--tag", "v1.0.0"}, []string{"plan", "--tag", "v1.0.0"}, "text", "info,api=debug"},
+		{[]string{"-log-format=json", "-log-level", "debug"}, nil, "json", "debug"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			t.Setenv("GIT_PUBLISH_LOG_FORMAT", "")
+			t.Setenv("GIT_PUBLISH_LOG_LEVEL", "")
+
+			rest, format, level := extractLogFlags(tc.args)
+			if strings.Join(rest, " ") != strings.Join(tc.rest, " ") || format != tc.format || level != tc.level {
+				t.Errorf("extractLogFlags(%v) = %v, %q, %q, expected %v, %q, %q", tc.args, rest, format, level, tc.rest, tc.format, tc.level)
+			}
+		})
+	}
+}
+
+// TestConfigureLogging tests default and per-module log levels
+func TestConfigureLogging(t *testing.T) {
+	defer configureLogging("text", "", os.Stderr)
+
+	var out bytes.Buffer
+	if err := configureLogging("json", "warn,api=debug", &out); err != nil {
+		t.Fatalf("configureLogging() error: %v", err)
+	}
+
+	logFor(logAPI).Debug("api debug")
+	logFor(logGit).Debug("git debug")
+	logFor(logGit).Warn("git warning")
+
+	logged := out.String()
+	if !strings.Contains(logged, `"msg":"api debug","module":"api"`) {
+		t.Errorf("expected the api debug record, got %s", logged)
+	}
+	if strings.Contains(logged, "git debug") {
+		t.Errorf("expected git debug records to be filtered, got %s", logged)
+	}
+	if !strings.Contains(logged, "git warning") {
+		t.Errorf("expected the git warning record, got %s", logged)
+	}
+
+	if err := configureLogging("xml", "", &out); err == nil {
+		t.Errorf("configureLogging() accepted an unknown format")
+	}
+	if err := configureLogging("text", "api=loud", &out); err == nil {
+		t.Errorf("configureLogging() accepted an unknown level")
+	}
+}
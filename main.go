@@ -1,13 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
-	"os/exec"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -17,13 +15,87 @@ import (
 
 // BranchTagConfig represents the configuration for branch and tag format
 type BranchTagConfig struct {
-	Branch string `json:"branch"`
-	Tag    string `json:"tag"`
+	Branch string   `json:"branch"`
+	Tag    string   `json:"tag"`
+	Paths  []string `json:"paths,omitempty"`
+	// Train derives the suggested tag from a release train schedule
+	Train TrainConfig `json:"train,omitempty"`
+	// VersionFiles are set to the new version and committed before tagging
+	VersionFiles []VersionFileConfig `json:"versionFiles,omitempty"`
+	// VersionCheck are files that have to contain the new version before tagging
+	VersionCheck []VersionCheckConfig `json:"versionCheck,omitempty"`
+	// Deployed is where the version deployed from the branch is read
+	Deployed DeployedConfig `json:"deployed,omitempty"`
+	// Base is what the next tag follows: tags (default), the last tag on the
+	// branch, or deployed, the version read from Deployed
+	Base string `json:"base,omitempty"`
+	// Environment is where releases of the branch go, e.g. staging or production
+	Environment string `json:"environment,omitempty"`
+	// Critical requires the tag to be typed to confirm its release
+	Critical bool `json:"critical,omitempty"`
+}
+
+// PushConfig represents how tags are pushed to the remote
+type PushConfig struct {
+	// Options are passed to the server as --push-option (e.g. GitLab's "ci.skip")
+	Options    []string `json:"options,omitempty"`
+	FollowTags bool     `json:"followTags,omitempty"`
+	// Refspecs replace the default tag refspec; {tag} and {branch} are substituted
+	Refspecs []string `json:"refspecs,omitempty"`
+	// SSHKey selects the identity used for the push without touching global git config
+	SSHKey string `json:"sshKey,omitempty"`
 }
 
 // Config represents the application configuration
 type Config struct {
+	// Version is the schema version of the file, see currentConfigVersion
+	Version    int               `json:"version,omitempty"`
 	BranchTags []BranchTagConfig `json:"branchTags"`
+	Push       PushConfig        `json:"push,omitempty"`
+	Hooks      HooksConfig       `json:"hooks,omitempty"`
+	// Notifications receive the outcome of unattended (scheduled or served) releases
+	Notifications NotificationsConfig `json:"notifications,omitempty"`
+	// Announce emails a release announcement after a successful publish
+	Announce AnnounceConfig `json:"announce,omitempty"`
+	// GitOps opens a pull request deploying a pushed tag
+	GitOps GitOpsConfig `json:"gitops,omitempty"`
+	// Provider overrides the hosting service detected from the remote URL
+	Provider ProviderConfig `json:"provider,omitempty"`
+	Release  ReleaseConfig  `json:"release,omitempty"`
+	Bump     BumpConfig     `json:"bump,omitempty"`
+	Remotes  RemotesConfig  `json:"remotes,omitempty"`
+	Tags     TagsConfig     `json:"tags,omitempty"`
+	Ticket   TicketConfig   `json:"ticket,omitempty"`
+	// TagRules are extra conditions on new tags, see TagRule
+	TagRules []TagRule `json:"tagRules,omitempty"`
+	// GoAPICheck is block (default), warn or off: breaking Go API changes in non-major releases
+	GoAPICheck string `json:"goApiCheck,omitempty"`
+	// Prompts tunes the questions of the interactive flow
+	Prompts PromptsConfig `json:"prompts,omitempty"`
+	// Strict turns configuration errors into failures instead of falling back to defaults
+	Strict bool `json:"strict,omitempty"`
+	// Signatures requires the commits of a release to be signed
+	Signatures SignaturesConfig `json:"signatures,omitempty"`
+	// Prune selects the pre-release tags the prune command deletes
+	Prune PruneConfig `json:"prune,omitempty"`
+	// Serve configures the release bot of the serve command
+	Serve ServeConfig `json:"serve,omitempty"`
+	// Aliases are extra commands expanding to the command line they map to
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// Preset applies the tag conventions of an ecosystem; terraform is the only one
+	Preset string `json:"preset,omitempty"`
+	// Dependencies lists outdated dependencies and audits them before tagging
+	Dependencies DependenciesConfig `json:"dependencies,omitempty"`
+	// Verify is a command, e.g. go test ./..., that has to pass before the tag is created
+	Verify string `json:"verify,omitempty"`
+	// Checklist is gone through before tagging, see ChecklistItem
+	Checklist []ChecklistItem `json:"checklist,omitempty"`
+	// Signing signs the tags created by the tool, see SigningConfig
+	Signing SigningConfig `json:"signing,omitempty"`
+	// Remind lists the branches that have not been released for a while
+	Remind RemindConfig `json:"remind,omitempty"`
+	// GateReport is a file receiving the outcome of every gate, JUnit XML or SARIF by extension
+	GateReport string `json:"gateReport,omitempty"`
 }
 
 // Default configuration
@@ -36,32 +108,193 @@ var defaultConfig = Config{
 }
 
 // Variables to allow mocking in tests
-var execCommand = exec.Command
+var execCommand = loggedCommand
 var isTagOnBranchFunc = isTagOnBranch
 
 func main() {
+	// Report panics instead of dumping a bare stack trace
+	defer handlePanic()
+
 	// Check if we're in a git repository
 	if !isGitRepository() {
 		fmt.Println("Error: Not in a git repository")
 		os.Exit(1)
 	}
 
+	// Logging flags are accepted anywhere on the command line
+	args, logFormat, logLevel := extractLogFlags(os.Args[1:])
+	if err := configureLogging(logFormat, logLevel, os.Stderr); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// So is --profile-perf, which prints the time of every phase and git call to stderr
+	args, profile := extractPerfFlag(args)
+	if profile {
+		startProfiling(os.Stderr)
+		defer perf.report()
+	}
+
+	runCommand(args)
+}
+
+// runCommand dispatches subcommands and aliases, and starts the interactive
+// flow for anything else
+func runCommand(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		runPublish(args)
+		return
+	}
+	currentCommand = args[0]
+	switch args[0] {
+	case "init":
+		runInit(args[1:])
+	case "plan":
+		runPlanCommand(args[1:])
+	case "apply":
+		runApplyCommand(args[1:])
+	case "ui":
+		runUICommand(args[1:])
+	case "schedule":
+		runScheduleCommand(args[1:])
+	case "submodule":
+		runSubmoduleCommand(args[1:])
+	case "auth":
+		runAuthCommand(args[1:])
+	case "telemetry":
+		runTelemetryCommand(args[1:])
+	case "config":
+		runConfigCommand(args[1:])
+	case "migrate":
+		runMigrateCommand(args[1:])
+	case "import":
+		runImportCommand(args[1:])
+	case "announce":
+		runAnnounceCommand(args[1:])
+	case "lint":
+		runLintCommand(args[1:])
+	case "prune":
+		runPruneCommand(args[1:])
+	case "tags":
+		runTagsCommand(args[1:])
+	case "bump":
+		runBumpCommand(args[1:])
+	case "serve":
+		runServeCommand(args[1:])
+	case "rollback":
+		runRollbackCommand(args[1:])
+	case "deployed":
+		runDeployedCommand(args[1:])
+	case "hotfix":
+		runHotfixCommand(args[1:])
+	case "signers":
+		runSignersCommand(args[1:])
+	case "remind":
+		runRemindCommand(args[1:])
+	default:
+		expanded, err := expandAlias(args, readAliases())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if expanded == nil {
+			fmt.Printf("Error: Unknown command '%s'\n", args[0])
+			os.Exit(1)
+		}
+		// Aliases do not nest, so the expansion is dispatched once more at most
+		runCommand(expanded)
+	}
+}
+
+// publishOptions holds the command-line flags shared by the publish flow and the plan command
+type publishOptions struct {
+	Branch              string
+	Tag                 string
+	Remote              string
+	NoPush              bool
+	SSHKey              string
+	SkipPermissionCheck bool
+	AllowBreaking       bool
+	Strict              bool
+	TagDate             string
+	Ticket              string
+	Trailers            stringsFlag
+	SkipVerify          bool
+	GateReport          string
+	// Confirm is the tag a critical branch asks to type, given in advance
+	Confirm string
+}
+
+// addPublishFlags registers the publish flow flags on fs
+func addPublishFlags(fs *flag.FlagSet) *publishOptions {
+	opts := &publishOptions{}
+	fs.StringVar(&opts.Branch, "branch", "", "branch to tag (skips the branch prompt)")
+	fs.StringVar(&opts.Tag, "tag", "", "tag to create (skips the tag prompt)")
+	fs.StringVar(&opts.Remote, "remote", "", "remote to push the tag to (skips the push prompt)")
+	fs.BoolVar(&opts.NoPush, "no-push", false, "create the tag without pushing it")
+	fs.StringVar(&opts.SSHKey, "ssh-key", "", "SSH private key used to push the tag (overrides push.sshKey)")
+	fs.BoolVar(&opts.SkipPermissionCheck, "skip-permission-check", false, "do not probe push permissions before prompting")
+	fs.BoolVar(&opts.AllowBreaking, "allow-breaking", false, "release breaking Go API changes without a major version bump")
+	fs.BoolVar(&opts.Strict, "strict", false, "fail on configuration errors instead of falling back to defaults")
+	fs.StringVar(&opts.Ticket, "ticket", "", "change ticket recorded in the tag message (skips the ticket prompt)")
+	fs.Var(&opts.Trailers, "trailer", "Key=Value trailer of the tag message, repeatable (overrides tags.trailers)")
+	fs.BoolVar(&opts.SkipVerify, "skip-verify", false, "create the tag without running the verify command (recorded in the audit log)")
+	fs.StringVar(&opts.GateReport, "gate-report", "", "write the outcome of every gate to this file, SARIF for .sarif or .json, else JUnit XML (overrides gateReport)")
+	fs.StringVar(&opts.Confirm, "confirm", "", "the tag, typed in advance to confirm a release from a critical branch")
+	fs.StringVar(&opts.TagDate, "tag-date", "", "create an annotated tag dated YYYY-MM-DD[ HH:MM:SS] or RFC 3339, for importing historical releases")
+	return opts
+}
+
+// runPublish runs the interactive publish flow
+func runPublish(args []string) {
+	fs := flag.NewFlagSet("git-publish", flag.ExitOnError)
+	opts := addPublishFlags(fs)
+	forceRetag := fs.String("force-retag", "", "move an existing tag to the head of its branch, asking to type the tag name")
+	fs.Parse(args)
+
+	// Explain what the tool does on the very first run
+	runOnboarding()
+
+	config, remoteURLs := preparePublish(opts)
+	if *forceRetag != "" {
+		runRetag(config, remoteURLs, *forceRetag, opts)
+		return
+	}
+
+	plan, ok := buildPlan(config, remoteURLs, opts)
+	if !ok {
+		fmt.Println("Tagging cancelled.")
+		return
+	}
+	rememberChoices(plan)
+
+	executePlan(plan, config)
+}
+
+// preparePublish loads the configuration, keeps only existing branches and
+// returns the remotes the tag may be pushed to
+func preparePublish(opts *publishOptions) (Config, map[string]string) {
 	// Set up colors for better user experience
 	green := color.New(color.FgGreen).SprintFunc()
 	cyan := color.New(color.FgCyan).SprintFunc()
 
 	// Show initial message
+	enterPhase("initialize")
 	fmt.Println(cyan("Initializing git-publish..."))
 
 	// Check if remote repository exists early
 	remoteURLs := getAllRemoteURLs()
 	hasRemote := len(remoteURLs) > 0
 
-	config := readConfig()
+	config := readConfigWith(opts)
 
 	// Filter branches that don't exist in the repository
 	fmt.Println("Finding available branches...")
+	configured := config.BranchTags
 	config = filterExistingBranches(config, hasRemote)
+	// Interactive runs may create missing branches, e.g. gray from main
+	if isInteractive() {
+		config.BranchTags = offerMissingBranches(configured, config.BranchTags, opts.Branch)
+	}
 
 	// Check if any branches remain
 	if len(config.BranchTags) == 0 {
@@ -69,96 +302,423 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Find out early which remotes accept our pushes
+	if hasRemote && !opts.NoPush && !opts.SkipPermissionCheck {
+		remoteURLs = checkPushPermissions(remoteURLs, config.Push)
+		if len(remoteURLs) == 0 {
+			fmt.Println("Continuing in create-local-only mode.")
+		}
+	}
+
 	fmt.Println(green("Initialization complete!"))
 
-	// Interactive CLI - now includes tag checking within the selection process
-	selectedBranch, tagFormat := selectBranchAndTag(config)
+	return config, remoteURLs
+}
 
-	// Get last tag from the selected branch
-	lastTag := getLastTag(selectedBranch, tagFormat)
+// buildPlan asks for (or takes from the flags) the branch, tag and remote and
+// returns the resulting plan. It returns false when the user cancelled.
+func buildPlan(config Config, remoteURLs map[string]string, opts *publishOptions) (Plan, bool) {
+	// Set up colors for better user experience
+	green := color.New(color.FgGreen).SprintFunc()
+	cyan := color.New(color.FgCyan).SprintFunc()
 
-	// Calculate next tag
-	nextTag := calculateNextTag(lastTag, tagFormat)
+	startGateReport(config.GateReport)
 
-	if lastTag == "" {
-		fmt.Println(cyan("Creating first tag for this branch..."))
-	} else {
-		fmt.Printf("Last tag: %s, suggested next tag: %s\n", lastTag, green(nextTag))
+	// Check a backdating request before asking anything
+	var tagDate time.Time
+	if opts.TagDate != "" {
+		var err error
+		if tagDate, err = parseTagDate(opts.TagDate, time.Now()); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	// Ask for tag
-	tagToCreate := promptForTag(tagFormat, nextTag, lastTag)
-
-	// Ask to push to remote if remotes exist
-	if !hasRemote {
-		fmt.Println("No remote repositories found. Skipping push step.")
+	// The questions are steps, so that b at any of them returns to the previous one
+	var selectedBranch, tagFormat, lastTag, tagToCreate, ticket, remote string
+	var tagExists bool
+	var bt BranchTagConfig
+	var trailers []string
+	// chooseTag asks for the tag and checks it; a tag taken on the remote asks again
+	chooseTag := func() navigation {
+		// Calculate next tag
+		enterPhase("suggest tag")
+		bt = findBranchTagConfig(config, selectedBranch, tagFormat)
+		nextTag, err := suggestNextTag(config, bt, lastTag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		// The bump level of the last release is suggested again
+		nextTag = preferredTag(nextTag, lastTag, tagFormat, readPrefs().Bump)
+
+		switch {
+		case lastTag == "":
+			fmt.Println(cyan("Creating first tag for this branch..."))
+		case bt.Base == baseDeployed:
+			fmt.Printf("Deployed version: %s, suggested next tag: %s\n", lastTag, green(nextTag))
+		default:
+			fmt.Printf("Last tag: %s, suggested next tag: %s\n", lastTag, green(nextTag))
+		}
 
-		// Create tag on branch
-		createTag(selectedBranch, tagToCreate)
+		// Show what the tag would ship before it is entered
+		previewCommits(lastTag, selectedBranch, opts.Tag == "")
+		if lastTag != "" {
+			warnSkippedRelease(lastTag, selectedBranch)
+		}
 
-		fmt.Printf("Successfully created tag %s on branch %s\n", green(tagToCreate), green(selectedBranch))
-	} else {
-		// Ask to push to remote
-		pushToRemote, selectedRemote := promptForPushToRemote(remoteURLs)
-
-		// Create tag on branch
-		createTag(selectedBranch, tagToCreate)
-
-		// Push to remote if requested
-		if pushToRemote {
-			fmt.Printf("Pushing tag %s to remote %s...\n", tagToCreate, selectedRemote)
-			pushTagToRemote(tagToCreate, selectedRemote)
-			fmt.Printf("Successfully created tag %s on branch %s\n", green(tagToCreate), green(selectedBranch))
-			fmt.Printf("Tag was pushed to remote: %s\n", green(selectedRemote))
+		// Ask for tag
+		enterPhase("enter tag")
+		checkRules := func(tag string) error {
+			if err := checkTagRules(config.TagRules, selectedBranch, tagFormat, lastTag, tag); err != nil {
+				return err
+			}
+			return checkPresetTag(config, selectedBranch, tagFormat, tag)
+		}
+		if opts.Tag != "" {
+			if err := validateNewTag(opts.Tag, tagFormat, lastTag); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			tagToCreate = opts.Tag
 		} else {
-			fmt.Printf("Successfully created tag %s on branch %s\n", green(tagToCreate), green(selectedBranch))
+			var nav navigation
+			if tagToCreate, nav = promptForTag(tagFormat, nextTag, lastTag, checkRules, true); nav != navigateNone {
+				return nav
+			}
+		}
+		gates.Branch, gates.Tag = selectedBranch, tagToCreate
+		// The prompt only accepts tags passing the rules; checking again records the gate
+		if err := runGate(gateTagPolicy, len(config.TagRules) > 0 || config.Preset == presetTerraform, func() error {
+			return checkRules(tagToCreate)
+		}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Keep breaking Go API changes out of patch and minor releases
+		enterPhase("check release")
+		if err := runGate(gateGoAPI, config.GoAPICheck != goAPICheckOff && lastTag != "", func() error {
+			return checkGoAPICompat(config, selectedBranch, tagFormat, lastTag, tagToCreate, opts.AllowBreaking)
+		}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		// Go modules need a /vN module path from v2 on
+		if err := runGate(gateGoModule, true, func() error {
+			return checkGoModulePath(config, selectedBranch, tagFormat, tagToCreate, isInteractive())
+		}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
+		if err := runGate(gateSignatures, config.Signatures.Require, func() error {
+			return checkSignedCommits(config.Signatures, selectedBranch, lastTag)
+		}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := runGate(gateDependencies, config.Dependencies.Check, func() error {
+			return checkDependencies(config.Dependencies, selectedBranch)
+		}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return navigateNone
 	}
-}
+	steps := []flowStep{
+		{"branch", func() navigation {
+			enterPhase("select branch")
+			if opts.Branch != "" {
+				flagged, ok := findBranchTagForFlags(config, opts.Branch, opts.Tag)
+				if !ok {
+					fmt.Printf("Error: Branch '%s' is not configured or does not exist\n", opts.Branch)
+					os.Exit(1)
+				}
+				selectedBranch, tagFormat = flagged.Branch, flagged.Tag
+			} else {
+				var nav navigation
+				if selectedBranch, tagFormat, nav = selectBranchAndTag(config); nav != navigateNone {
+					return nav
+				}
+			}
 
-// isGitRepository checks if the current directory is a git repository
-func isGitRepository() bool {
-	cmd := execCommand("git", "rev-parse", "--is-inside-work-tree")
-	if err := cmd.Run(); err != nil {
-		return false
+			// A retried run stops here when the tag is already published, or resumes
+			// when it was created but not pushed
+			tagExists = opts.Tag != "" && checkRerun(opts.Tag, selectedBranch, opts, remoteURLs)
+
+			// Get last tag from the selected branch, or the deployed version
+			except := ""
+			if tagExists {
+				except = opts.Tag
+			}
+			var err error
+			lastTag, err = releasedTag(config, findBranchTagConfig(config, selectedBranch, tagFormat), except)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+
+			// In monorepos, warn when nothing changed under the configured paths
+			if paths := findBranchTagConfig(config, selectedBranch, tagFormat).Paths; lastTag != "" && len(paths) > 0 {
+				if !confirmChangesSince(lastTag, selectedBranch, paths) {
+					return navigateCancel
+				}
+			}
+			return navigateNone
+		}},
+		{"tag", chooseTag},
+		{"ticket", func() navigation {
+			// Change management may require a ticket for the release
+			ticket = ""
+			switch {
+			case opts.Ticket != "":
+				title, err := checkTicket(config.Ticket, opts.Ticket)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(1)
+				}
+				if title != "" {
+					fmt.Printf("Ticket %s: %s\n", opts.Ticket, title)
+				}
+				ticket = opts.Ticket
+			case config.Ticket.enabled():
+				ticket = promptForTicket(config.Ticket)
+			}
+			var err error
+			trailers, err = resolveTrailers(config.Tags.Trailers, opts.Trailers, true)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return navigateNone
+		}},
+		{"remote", func() navigation {
+			// Ask to push to remote if remotes exist
+			enterPhase("select remote")
+			remote = ""
+			switch {
+			case opts.NoPush:
+				fmt.Println("Push disabled. Skipping push step.")
+			case len(remoteURLs) == 0:
+				fmt.Println("No remote repositories found. Skipping push step.")
+			case opts.Remote != "":
+				remote = opts.Remote
+			default:
+				pushToRemote, selectedRemote, nav := promptForPushToRemote(remoteURLs, config.Remotes, true)
+				if nav != navigateNone {
+					return nav
+				}
+				if pushToRemote {
+					remote = selectedRemote
+				}
+			}
+			return navigateNone
+		}},
+		{"remote tag", func() navigation {
+			// A tag someone else pushed meanwhile would only fail the push at the end
+			for remote != "" && !tagExists {
+				commit := remoteTagCommit(remote, tagToCreate)
+				if commit == "" {
+					return navigateNone
+				}
+				if opts.Tag != "" {
+					fmt.Printf("Error: %v\n", remoteTagExistsError(remote, tagToCreate, commit))
+					os.Exit(1)
+				}
+				switch resolveRemoteTag(remote, tagToCreate, commit) {
+				case remoteTagFetch:
+					fetchRemoteTags(remote)
+					var err error
+					if lastTag, err = releasedTag(config, bt, ""); err != nil {
+						fmt.Printf("Error: %v\n", err)
+						os.Exit(1)
+					}
+				case remoteTagQuit:
+					return navigateCancel
+				}
+				if nav := chooseTag(); nav != navigateNone {
+					return nav
+				}
+			}
+			return navigateNone
+		}},
+	}
+	if !runSteps(steps) {
+		return Plan{}, false
 	}
-	return true
-}
 
-// readConfig reads the configuration file
-func readConfig() Config {
-	configPath := "publish.json"
+	plan, err := makePlan(config, remoteURLs, selectedBranch, tagFormat, lastTag, tagToCreate, remote)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	// Version files are only recorded here: publishPlan commits them on the
+	// branch once the release is confirmed, so the tag includes them
+	if !tagExists {
+		if plan.VersionFiles, err = pendingVersionFiles(bt.VersionFiles, selectedBranch, tagFormat, tagToCreate); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	// The remote branch has to move to the version commit along with the tag
+	if len(plan.VersionFiles) > 0 && plan.Remote != "" && len(config.Push.Refspecs) == 0 {
+		plan.PushArgs = append(plan.PushArgs, selectedBranch)
+	}
+	plan.Trailers = trailers
+	plan.SkipVerify = opts.SkipVerify && plan.Verify != ""
+	setTicket(&plan, config, ticket)
+	plan.TagExists = tagExists
+	// Files bumped by hand have to hold the new version in the tagged commit
+	checks, err := versionChecks(bt, plan.TargetCommit)
+	checks = withoutVersionFiles(checks, plan.VersionFiles)
+	if err == nil {
+		err = runGate(gateVersions, !tagExists && len(checks) > 0, func() error {
+			return checkVersionStrings(checks, plan, tagFormat)
+		})
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := runGate(gatePermissions, plan.Release || plan.GitOps != "", func() error {
+		return preflightPlan(plan, config)
+	}); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	done, checklistErr := true, error(nil)
+	runGate(gateChecklist, len(config.Checklist) > 0, func() error {
+		done, checklistErr = runChecklist(config.Checklist, plan)
+		if checklistErr == nil && !done {
+			return fmt.Errorf("an item is not done")
+		}
+		return checklistErr
+	})
+	if checklistErr != nil {
+		fmt.Printf("Error: %v\n", checklistErr)
+		os.Exit(1)
+	}
+	if !done {
+		return Plan{}, false
+	}
 
-	// Check if config file exists
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		// Write default config if file doesn't exist
-		writeDefaultConfig(configPath)
-		return defaultConfig
+	if !tagDate.IsZero() {
+		if err := checkTagDate(tagDate, plan.TargetCommit); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		plan.TagDate = tagDate.Format(time.RFC3339)
+		if !confirmTagDate(plan) {
+			return Plan{}, false
+		}
 	}
+	if !confirmEnvironment(plan, opts) {
+		return Plan{}, false
+	}
+
+	return plan, true
+}
+
+// makePlan assembles the plan for tagging branch with tag and pushing it to remote
+// (no push when remote is empty)
+func makePlan(config Config, remoteURLs map[string]string, branch, tagFormat, lastTag, tag, remote string) (Plan, error) {
+	plan := newPlan()
+	plan.Branch = branch
+	plan.TagFormat = tagFormat
+	plan.LastTag = lastTag
+	plan.Tag = tag
 
-	// Read config file
-	fileContent, err := os.ReadFile(configPath)
+	commit, err := lookupCommit(branch)
 	if err != nil {
-		fmt.Printf("Error reading config file: %v\n", err)
-		fmt.Println("Using default configuration")
-		return defaultConfig
+		return plan, fmt.Errorf("getting commit hash for branch %s: %v", branch, err)
 	}
+	plan.TargetCommit = commit
 
-	// Parse config file
-	var config Config
-	if err := json.Unmarshal(fileContent, &config); err != nil {
-		fmt.Printf("Error parsing config file: %v\n", err)
-		fmt.Println("Using default configuration")
-		return defaultConfig
+	if remote != "" {
+		url, ok := remoteURLs[remote]
+		if !ok {
+			return plan, fmt.Errorf("remote '%s' not found or not pushable", remote)
+		}
+		if remoteKind(url) == remoteKindBundle {
+			return plan, fmt.Errorf("remote '%s' is a bundle file, which cannot receive pushes", remote)
+		}
+		plan.Remote = remote
+		plan.RemoteURL = url
+		plan.PushArgs = buildPushArgs(config.Push, config.Tags, tag, branch, remote)
+		plan.RefOnly = config.Tags.NamespaceOnly
+		plan.Mirrors = plannedMirrors(config.Remotes, remoteURLs, plan)
+		// Local repositories have no hosting service to publish a release on,
+		// and hosting services only publish tags
+		plan.Release = config.Release.Create && remoteKind(url) == "" && !plan.RefOnly
+		if !plan.RefOnly {
+			plan.TagURL = tagWebURL(url, config.Provider, tag)
+		}
+		// Only pushed tags are announced
+		if config.Announce.enabled() {
+			plan.Announce = config.Announce.To
+		}
+		if config.GitOps.enabled() {
+			plan.GitOps = config.GitOps.Repo
+		}
 	}
+	plan.ReleaseRef = releaseRef(config.Tags, tag)
+	plan.Hooks = plannedHooks(config.Hooks, plan.Remote != "")
+	plan.TagMessage = tagMessage(plan, config.Tags)
+	if config.Signing.Tags {
+		// Only annotated tags carry a signature
+		signing := config.Signing
+		plan.Signing = &signing
+		if plan.TagMessage == "" {
+			plan.TagMessage = "Release " + tag
+		}
+	}
+	plan.OnlyMarkedTags = config.Tags.OnlyMarked
+	bt := findBranchTagConfig(config, branch, tagFormat)
+	plan.DeployedBase = bt.Base == baseDeployed
+	plan.Environment = bt.Environment
+	plan.Critical = bt.Critical
+	plan.Verify = config.Verify
+
+	return plan, nil
+}
 
-	// Validate config
-	if len(config.BranchTags) == 0 {
-		fmt.Println("Config file is valid but empty. Using default configuration")
-		return defaultConfig
+// executePlan creates (and optionally pushes) the planned tag and exits on failure
+func executePlan(plan Plan, config Config) {
+	green := color.New(color.FgGreen).SprintFunc()
+
+	if err := publishPlan(plan, config, newEventBus()); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
 
-	return config
+	fmt.Printf("Successfully created tag %s on branch %s at %s\n", green(plan.Tag), green(plan.Branch), formatReleaseTime(time.Now()))
+	if plan.Remote != "" {
+		fmt.Printf("Tag was pushed to remote: %s\n", green(plan.Remote))
+	}
+	if plan.TagURL != "" {
+		fmt.Printf("Tag URL: %s\n", plan.TagURL)
+	}
+}
+
+// isGitRepository checks if the current directory is a git repository
+func isGitRepository() bool {
+	return gitClient.IsRepository()
+}
+
+// readConfig reads the configuration file, layered over the user config and
+// built-in defaults and overridden by the environment
+func readConfig() Config {
+	return readConfigWith(nil)
+}
+
+// readConfigWith resolves the configuration layers, with opts as the flag layer
+func readConfigWith(opts *publishOptions) Config {
+	resolved, err := loadConfig(opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	return resolved.Config
 }
 
 // filterExistingBranches filters out branches that don't exist in the repository
@@ -171,7 +731,7 @@ func filterExistingBranches(config Config, hasRemote bool) Config {
 
 	// Only fetch if remote exists
 	if hasRemote {
-		fetchRemote()
+		fetchRemote(config.Tags)
 	}
 
 	// Get all available branches (local and now fetched remote)
@@ -186,51 +746,40 @@ func filterExistingBranches(config Config, hasRemote bool) Config {
 		}
 	}
 
-	return Config{BranchTags: filteredBranchTags}
+	config.BranchTags = filteredBranchTags
+	return config
 }
 
-// getConfiguredBranches gets local and remote branches that match the configured branches
+// getConfiguredBranches returns the configured branches that exist locally or
+// on a remote. Only the configured names are queried, so repositories with
+// thousands of branches are not listed in full.
 func getConfiguredBranches(configuredBranches []string) []string {
-	// Get all local branches
-	cmdLocal := execCommand("git", "branch", "--list")
-	outputLocal, err := cmdLocal.Output()
-	localBranches := []string{}
-	if err == nil {
-		for _, line := range strings.Split(strings.TrimSpace(string(outputLocal)), "\n") {
-			if line != "" {
-				// Remove the asterisk and spaces
-				branch := strings.TrimSpace(strings.TrimPrefix(line, "*"))
-				// Only include branch if it's in the configured branches
-				if contains(configuredBranches, branch) {
-					localBranches = append(localBranches, branch)
-				}
-			}
-		}
+	if len(configuredBranches) == 0 {
+		return nil
 	}
 
-	// Get all remote branches
-	cmdRemote := execCommand("git", "branch", "-r")
-	outputRemote, err := cmdRemote.Output()
-	remoteBranches := []string{}
-	if err == nil {
-		for _, line := range strings.Split(strings.TrimSpace(string(outputRemote)), "\n") {
-			if line != "" {
-				// Remove 'origin/' prefix and spaces
-				parts := strings.Split(strings.TrimSpace(line), "/")
-				if len(parts) >= 2 {
-					branch := parts[len(parts)-1]
-					// Only include branch if it's in the configured branches
-					if contains(configuredBranches, branch) {
-						remoteBranches = append(remoteBranches, branch)
-					}
-				}
-			}
-		}
+	patterns := make([]string, 0, 2*len(configuredBranches))
+	for _, branch := range configuredBranches {
+		patterns = append(patterns, "refs/heads/"+branch, "refs/remotes/*/"+branch)
+	}
+	refs, err := gitClient.Refs(patterns...)
+	if err != nil {
+		return nil
 	}
 
-	// Combine local and remote branches and remove duplicates
-	allBranches := append(localBranches, remoteBranches...)
-	return uniqueStrings(allBranches)
+	// Patterns also match refs below the name (main/old), so compare exactly
+	var branches []string
+	for _, ref := range refs {
+		branch := strings.TrimPrefix(ref, "refs/heads/")
+		if branch == ref {
+			// Remove the remote name of refs/remotes/<remote>/<branch>
+			_, branch, _ = strings.Cut(strings.TrimPrefix(ref, "refs/remotes/"), "/")
+		}
+		if contains(configuredBranches, branch) {
+			branches = append(branches, branch)
+		}
+	}
+	return uniqueStrings(branches)
 }
 
 // contains checks if a string exists in a slice
@@ -267,8 +816,11 @@ func branchExists(branch string, availableBranches []string) bool {
 }
 
 // writeDefaultConfig writes the default configuration to the given path
-func writeDefaultConfig(path string) {
-	data, err := json.MarshalIndent(defaultConfig, "", "  ")
+func writeDefaultConfig(path string, config Config) {
+	// Only the branch mapping is written; optional sections stay out of new files
+	data, err := json.MarshalIndent(struct {
+		BranchTags []BranchTagConfig `json:"branchTags"`
+	}{config.BranchTags}, "", "  ")
 	if err != nil {
 		fmt.Printf("Error creating default config: %v\n", err)
 		return
@@ -279,88 +831,169 @@ func writeDefaultConfig(path string) {
 	}
 }
 
-// selectBranchAndTag presents a selection of branches from the config
-func selectBranchAndTag(config Config) (string, string) {
+// selectBranchAndTag presents a selection of branches from the config, the
+// first question of the flow
+func selectBranchAndTag(config Config) (string, string, navigation) {
 	// Set up colors for better user experience
 	green := color.New(color.FgGreen).SprintFunc()
 
-	// Prepare options for selection
-	branchOptions := make([]string, len(config.BranchTags))
-	tagFormats := make([]string, len(config.BranchTags))
-	for i, bt := range config.BranchTags {
-		branchOptions[i] = bt.Branch
-		tagFormats[i] = bt.Tag
+	// The branch of the last release comes first, so it is the default
+	// selection, or else the remote's default branch
+	defaultBranch, _ := gitClient.DefaultBranch("origin")
+	lastBranch := readPrefs().Branch
+	branchTags := preferBranch(preferBranch(config.BranchTags, defaultBranch), lastBranch)
+
+	// Show the last tag of every branch, the first one is the default
+	choices := make([]choice, len(branchTags))
+	for i, bt := range branchTags {
+		choices[i] = choice{Name: bt.Branch}
+		if lastTag := getLastTag(bt.Branch, bt.Tag, config.Tags.OnlyMarked); lastTag == "" {
+			choices[i].Detail = fmt.Sprintf("(No existing tags, format: %s)", bt.Tag)
+		} else {
+			choices[i].Detail = fmt.Sprintf("(Last tag: %s)", green(lastTag))
+			if stats, err := gitClient.CommitStats(lastTag, branchRef(bt.Branch)); err == nil {
+				choices[i].Detail += " " + describeCommitStats(stats, time.Now())
+			}
+		}
+		if bt.Environment != "" {
+			choices[i].Detail += " → " + environmentLabel(bt.Environment)
+		}
+		if bt.Branch == defaultBranch {
+			choices[i].Detail += " [default branch]"
+		}
+		if bt.Branch == lastBranch {
+			choices[i].Detail += " [last used]"
+		}
 	}
 
-	// Default to first branch
-	defaultBranch := config.BranchTags[0].Branch
-	defaultTagFormat := config.BranchTags[0].Tag
+	// Asking to pick the only branch there is would be a pointless question
+	if len(branchTags) == 1 && !config.Prompts.AlwaysSelectBranch {
+		fmt.Printf("Using branch %s %s, the only configured branch\n", green(branchTags[0].Branch), choices[0].Detail)
+		return branchTags[0].Branch, branchTags[0].Tag, navigateNone
+	}
 
-	// Display options
-	fmt.Println("Select branch for tagging:")
-	for i, branch := range branchOptions {
-		lastTag := getLastTag(branch, tagFormats[i])
-		if lastTag == "" {
-			fmt.Printf("%d: %s (No existing tags, format: %s)\n", i+1, branch, tagFormats[i])
-		} else {
-			fmt.Printf("%d: %s (Last tag: %s)\n", i+1, branch, green(lastTag))
-		}
+	index, nav := prompter.SelectStep("Select branch for tagging:", "branch", choices)
+	if nav != navigateNone {
+		return "", "", nav
 	}
+	selected := branchTags[index]
+	return selected.Branch, selected.Tag, navigateNone
+}
 
-	// Default option as the first one
-	fmt.Printf("Enter number (default: 1 for %s): ", defaultBranch)
+// describeCommitStats summarizes the commits since the last tag of a branch,
+// e.g. "5 commits since (by 2 authors, oldest 12 days ago)"
+func describeCommitStats(stats commitStats, now time.Time) string {
+	if stats.Count == 0 {
+		return "no commits since"
+	}
+	return fmt.Sprintf("%s since (by %s, oldest %s)",
+		plural(stats.Count, "commit"), plural(stats.Authors, "author"), relativeAge(now.Sub(stats.Oldest)))
+}
 
-	// Read user input
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
+// plural returns "1 <noun>" or "<n> <noun>s"
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
 
-	// Handle default or parse selection
-	selectedBranch := defaultBranch
-	selectedTagFormat := defaultTagFormat
-	if input != "" {
-		if idx, err := strconv.Atoi(input); err == nil && idx > 0 && idx <= len(branchOptions) {
-			selectedBranch = branchOptions[idx-1]
-			selectedTagFormat = tagFormats[idx-1]
-		} else {
-			fmt.Printf("Invalid selection, using default branch: %s\n", defaultBranch)
+// relativeAge describes how long ago something happened in hours or days
+func relativeAge(age time.Duration) string {
+	switch {
+	case age < time.Hour:
+		return "less than an hour ago"
+	case age < 48*time.Hour:
+		return plural(int(age.Hours()), "hour") + " ago"
+	}
+	return plural(int(age.Hours()/24), "day") + " ago"
+}
+
+// preferBranch returns the branch mappings with those of branch moved to the
+// front, keeping the configured order otherwise
+func preferBranch(branchTags []BranchTagConfig, branch string) []BranchTagConfig {
+	ordered := make([]BranchTagConfig, 0, len(branchTags))
+	for _, bt := range branchTags {
+		if bt.Branch == branch {
+			ordered = append(ordered, bt)
+		}
+	}
+	for _, bt := range branchTags {
+		if bt.Branch != branch {
+			ordered = append(ordered, bt)
+		}
+	}
+	return ordered
+}
+
+// findBranchTagForFlags finds the configuration entry for a branch given on the command line.
+// When the branch has several tag formats, the tag (if given) selects the matching one.
+func findBranchTagForFlags(config Config, branch, tag string) (BranchTagConfig, bool) {
+	var found []BranchTagConfig
+	for _, bt := range config.BranchTags {
+		if bt.Branch == branch {
+			found = append(found, bt)
 		}
 	}
+	if len(found) == 0 {
+		return BranchTagConfig{}, false
+	}
 
-	return selectedBranch, selectedTagFormat
+	if tag != "" {
+		for _, bt := range found {
+			if tagPattern(bt.Tag).MatchString(tag) {
+				return bt, true
+			}
+		}
+	}
+	return found[0], true
+}
+
+// findBranchTagConfig returns the configuration entry for the given branch and tag format
+func findBranchTagConfig(config Config, branch, tagFormat string) BranchTagConfig {
+	for _, bt := range config.BranchTags {
+		if bt.Branch == branch && bt.Tag == tagFormat {
+			return bt
+		}
+	}
+	return BranchTagConfig{Branch: branch, Tag: tagFormat}
 }
 
-// fetchRemote fetches latest information from remote
-func fetchRemote() {
+// fetchRemote fetches latest information from remote; releases published to
+// a namespace only are fetched as local tags, so the last tag is found as usual
+func fetchRemote(tags TagsConfig) {
 	// Show progress message
 	fmt.Println("Fetching branch information from remote, please wait...")
 
 	// Use a channel to track progress with timeout
 	done := make(chan bool)
-	errCh := make(chan error)
+	// Buffered for the four fetch steps, errors are only read once all are done
+	errCh := make(chan error, 4)
 
 	go func() {
 		// First try a simple fetch to update remote refs
 		// This avoids issues with specific branches
-		cmd := execCommand("git", "fetch", "--no-tags", "origin")
-		err := cmd.Run()
-		if err != nil {
+		if err := gitClient.Fetch("--no-tags", "origin"); err != nil {
 			// Non-critical error, just log it
 			errCh <- fmt.Errorf("warning: initial fetch failed: %v", err)
 		}
 
 		// Now try to fetch tags if there are any
 		if hasAnyTags() {
-			cmd = execCommand("git", "fetch", "--depth=5", "origin", "refs/tags/*:refs/tags/*")
-			if err := cmd.Run(); err != nil {
+			if err := gitClient.Fetch("--depth=5", "origin", "refs/tags/*:refs/tags/*"); err != nil {
 				// Non-critical error, just log it
 				errCh <- fmt.Errorf("warning: failed to fetch tags: %v", err)
 			}
 		}
 
+		if tags.NamespaceOnly {
+			if err := gitClient.Fetch("origin", tags.Namespace+"/*:refs/tags/*"); err != nil {
+				errCh <- fmt.Errorf("warning: failed to fetch %s: %v", tags.Namespace, err)
+			}
+		}
+
 		// Additional step to ensure branch synchronization
-		cmd = execCommand("git", "remote", "update", "origin", "--prune")
-		if err := cmd.Run(); err != nil {
+		if err := gitClient.UpdateRemote("origin"); err != nil {
 			errCh <- fmt.Errorf("warning: failed to update remote: %v", err)
 		}
 
@@ -391,25 +1024,34 @@ func fetchRemote() {
 	}
 
 	// Print any errors we collected
+	diagnose := timeoutReached
 	for _, err := range fetchErrors {
 		fmt.Println(err)
+		diagnose = diagnose || isNetworkError(err)
+	}
+
+	// Explain hangs and connection failures
+	if diagnose {
+		if diagnostics := connectionDiagnostics("origin", getAllRemoteURLs()["origin"]); diagnostics != "" {
+			fmt.Println(diagnostics)
+		}
 	}
 }
 
 // hasAnyTags checks if the repository has any tags at all
 func hasAnyTags() bool {
-	cmd := execCommand("git", "tag", "-l")
-	output, err := cmd.Output()
-	if err != nil {
-		return false
-	}
-
-	tags := strings.TrimSpace(string(output))
-	return tags != ""
+	tags, err := gitClient.ListTags("")
+	return err == nil && len(tags) > 0
 }
 
 // getLastTag returns the last tag matching the format on the given branch
-func getLastTag(branch string, tagFormat string) string {
+func getLastTag(branch string, tagFormat string, onlyMarked bool) string {
+	return getLastTagExcept(branch, tagFormat, onlyMarked, "")
+}
+
+// getLastTagExcept returns the last tag on branch other than except, the tag
+// an earlier run already created
+func getLastTagExcept(branch string, tagFormat string, onlyMarked bool, except string) string {
 	// Check if there are any tags first
 	if !hasAnyTags() {
 		return ""
@@ -418,30 +1060,18 @@ func getLastTag(branch string, tagFormat string) string {
 	// Extract prefix from tag format (like "v" from "v0.0.0")
 	prefix := extractPrefix(tagFormat)
 
-	// Use rev-list to get tags on this branch efficiently
-	// This is much faster than listing all tags and checking each one
-	cmd := execCommand("git", "tag", "--list", prefix+"*", "--sort=-v:refname")
-	output, err := cmd.Output()
+	// Tags come sorted by version, highest first
+	tags, err := gitClient.ListTags(tagGlob(tagFormat))
 	if err != nil {
 		fmt.Printf("Error getting tags: %v\n", err)
 		return ""
 	}
 
-	tags := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(tags) == 0 || (len(tags) == 1 && tags[0] == "") {
-		return ""
-	}
-
 	// Find the first tag that is on the branch
 	for _, tag := range tags {
-		// Skip empty tags
-		if tag == "" {
-			continue
-		}
-
-		if isTagOnBranchFunc(tag, branch) {
+		if tag != except && isTagOnBranchFunc(tag, branch) {
 			// Validate the tag format matches our expected format
-			if validateTagFormat(tag, prefix) {
+			if validateTagFormat(tag, prefix) && (!onlyMarked || isToolTag(tag)) {
 				return tag
 			}
 		}
@@ -467,7 +1097,7 @@ func validateTagFormat(tag, prefix string) bool {
 
 	// Validate each part is a number
 	for _, part := range parts {
-		if _, err := strconv.Atoi(part); err != nil {
+		if !isVersionNumber(part) {
 			return false
 		}
 	}
@@ -475,8 +1105,33 @@ func validateTagFormat(tag, prefix string) bool {
 	return true
 }
 
-// extractPrefix extracts the prefix from a tag format
+// isVersionNumber reports whether s is a semver version number: digits only,
+// without a sign or leading zeros, so dates such as 2024.06.01 are not versions
+func isVersionNumber(s string) bool {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// versionNumberPattern matches a version number as isVersionNumber accepts it
+const versionNumberPattern = `(0|[1-9]\d*)`
+
+// trailingVersion matches the X.Y.Z at the end of a tag or tag format
+var trailingVersion = regexp.MustCompile(`\d+\.\d+\.\d+$`)
+
+// extractPrefix extracts the prefix from a tag format: everything before the
+// trailing X.Y.Z, so prefixes may contain digits (modules/s3/v), or before
+// the first digit when there is no version
 func extractPrefix(tagFormat string) string {
+	if loc := trailingVersion.FindStringIndex(tagFormat); loc != nil {
+		return tagFormat[:loc[0]]
+	}
 	for i, c := range tagFormat {
 		if c >= '0' && c <= '9' {
 			return tagFormat[:i]
@@ -485,46 +1140,37 @@ func extractPrefix(tagFormat string) string {
 	return tagFormat
 }
 
-// isTagOnBranch checks if the given tag is on the specified branch
-func isTagOnBranch(tag, branch string) bool {
-	// First check if the tag exists
-	cmdTagExists := execCommand("git", "show-ref", "--tags", tag)
-	if err := cmdTagExists.Run(); err != nil {
-		return false
+// tagGlob returns the glob pattern listing the tags of a tag format, such as
+// v* for v0.0.0. Without a prefix only tags starting with a digit are listed,
+// not every tag of the repository.
+func tagGlob(tagFormat string) string {
+	if prefix := extractPrefix(tagFormat); prefix != "" {
+		return prefix + "*"
 	}
+	return "[0-9]*"
+}
 
-	// Get the commit hash for the tag
-	cmdTagCommit := execCommand("git", "rev-list", "-n", "1", tag)
-	tagCommit, err := cmdTagCommit.Output()
+// isTagOnBranch checks if the given tag is on the specified branch
+func isTagOnBranch(tag, branch string) bool {
+	tagCommit, err := gitClient.RevParse("refs/tags/" + tag + "^{commit}")
 	if err != nil {
 		return false
 	}
-	tagCommitStr := strings.TrimSpace(string(tagCommit))
-
-	// Try local branch first
-	cmdBranchCommit := execCommand("git", "rev-parse", "--verify", branch)
-	branchCommit, err := cmdBranchCommit.Output()
 
-	// If local branch doesn't exist, try remote branch
+	// Neither local nor remote branch exists
+	branchCommit, err := lookupCommit(branch)
 	if err != nil {
-		cmdBranchCommit = execCommand("git", "rev-parse", "--verify", "origin/"+branch)
-		branchCommit, err = cmdBranchCommit.Output()
-		if err != nil {
-			// Neither local nor remote branch exists
-			return false
-		}
+		return false
 	}
-	branchCommitStr := strings.TrimSpace(string(branchCommit))
 
 	// Fast check: if tag is the branch tip, return true
-	if tagCommitStr == branchCommitStr {
+	if tagCommit == branchCommit {
 		return true
 	}
 
 	// Check if the tag commit is an ancestor of branch commit
-	// This is much faster than the previous checks
-	cmdMergeBase := execCommand("git", "merge-base", "--is-ancestor", tagCommitStr, branchCommitStr)
-	return cmdMergeBase.Run() == nil
+	onBranch, err := gitClient.IsAncestor(tagCommit, branchCommit)
+	return err == nil && onBranch
 }
 
 // calculateNextTag calculates the next tag based on the last tag
@@ -605,166 +1251,270 @@ func isTagVersionGreater(newTag, oldTag string) bool {
 	return newPatch > oldPatch
 }
 
-// promptForTag asks the user for the tag to create
-func promptForTag(tagFormat, defaultTag, lastTag string) string {
-	// Compile regex for tag validation
+// tagPattern returns the regular expression a tag of the given format must match
+func tagPattern(tagFormat string) *regexp.Regexp {
 	prefix := extractPrefix(tagFormat)
-	patternStr := "^" + regexp.QuoteMeta(prefix) + "\\d+\\.\\d+\\.\\d+$"
-	pattern := regexp.MustCompile(patternStr)
+	return regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + versionNumberPattern + `\.` + versionNumberPattern + `\.` + versionNumberPattern + "$")
+}
+
+// validateNewTag checks a tag given without prompting against the format and the last tag
+func validateNewTag(tag, tagFormat, lastTag string) error {
+	if !tagPattern(tagFormat).MatchString(tag) {
+		return fmt.Errorf("invalid format, tag %s should match %s", tag, tagFormat)
+	}
+	if lastTag != "" && !isTagVersionGreater(tag, lastTag) {
+		return fmt.Errorf("new tag %s must be greater than the last tag: %s", tag, lastTag)
+	}
+	return nil
+}
+
+// promptForTag asks the user for the tag to create; check, if not nil, adds
+// conditions to the built-in format and ordering checks. As a step of the
+// flow, b and q are returned as navigation.
+func promptForTag(tagFormat, defaultTag, lastTag string, check func(tag string) error, step bool) (string, navigation) {
+	// Compile regex for tag validation
+	pattern := tagPattern(tagFormat)
 
 	// Set up colors
 	green := color.New(color.FgGreen).SprintFunc()
 	red := color.New(color.FgRed).SprintFunc()
 
-	fmt.Printf("Enter tag (format: %s, default: %s):\n", tagFormat, green(defaultTag))
-	fmt.Print("> ")
-
-	// Read user input
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
+	// The next patch, minor and major versions are one keystroke away
+	candidates := tagCandidates(lastTag, tagFormat)
+	if len(candidates) > 0 {
+		offers := make([]string, len(candidates))
+		for i, c := range candidates {
+			offers[i] = fmt.Sprintf("%d for %s %s", i+1, c.Level, green(c.Tag))
+		}
+		fmt.Printf("Enter %s\n", strings.Join(offers, ", "))
+	}
 
-	// If empty, use default
-	if input == "" {
-		input = defaultTag
+	// Hand-typed versions are checked against similar existing tags
+	expected := []string{defaultTag}
+	for _, c := range candidates {
+		expected = append(expected, c.Tag)
 	}
 
-	// Validate input format and version
-	for {
+	question := fmt.Sprintf("Enter tag (format: %s, default: %s):", tagFormat, green(defaultTag))
+	validate := func(input string) error {
+		input = pickCandidate(input, candidates)
 		// First check format
 		if !pattern.MatchString(input) {
-			fmt.Printf("Invalid format! Tag should match %s\n", tagFormat)
-			fmt.Print("> ")
-			input, _ = reader.ReadString('\n')
-			input = strings.TrimSpace(input)
-			continue
+			return fmt.Errorf("Invalid format! Tag should match %s", tagFormat)
 		}
-
 		// Then check if version is greater than the last tag
 		// Skip this check if there's no last tag
 		if lastTag != "" && !isTagVersionGreater(input, lastTag) {
-			fmt.Printf("%s New tag must be greater than the last tag: %s\n", red("Error:"), lastTag)
-			fmt.Print("> ")
-			input, _ = reader.ReadString('\n')
-			input = strings.TrimSpace(input)
-			continue
+			return fmt.Errorf("%s New tag must be greater than the last tag: %s", red("Error:"), lastTag)
+		}
+		if check != nil {
+			if err := check(input); err != nil {
+				return fmt.Errorf("%s %v", red("Error:"), err)
+			}
+		}
+		return nil
+	}
+	var tag string
+	for {
+		var answer string
+		var nav navigation
+		var err error
+		if step {
+			answer, nav, err = prompter.InputStep(question, defaultTag, validate)
+		} else {
+			answer, err = prompter.Input(question, defaultTag, validate)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if nav != navigateNone {
+			return "", nav
+		}
+		tag = pickCandidate(answer, candidates)
+		if contains(expected, tag) || confirmTypedTag(tag, tagFormat) {
+			break
 		}
-
-		// If we get here, the tag is valid
-		fmt.Printf("Valid tag: %s\n", green(input))
-		break
 	}
 
-	return input
+	fmt.Printf("Valid tag: %s\n", green(tag))
+	return tag, navigateNone
 }
 
 // getAllRemoteURLs gets all remote repository URLs
 func getAllRemoteURLs() map[string]string {
-	// Get all remotes
-	cmd := execCommand("git", "remote")
-	output, err := cmd.Output()
+	remoteURLs, err := gitClient.Remotes()
 	if err != nil {
 		return map[string]string{}
 	}
-
-	remotes := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(remotes) == 0 || (len(remotes) == 1 && remotes[0] == "") {
-		return map[string]string{}
-	}
-
-	// Get URL for each remote
-	remoteURLs := make(map[string]string)
-	for _, remote := range remotes {
-		cmd = execCommand("git", "config", "--get", fmt.Sprintf("remote.%s.url", remote))
-		url, err := cmd.Output()
-		if err == nil {
-			remoteURLs[remote] = strings.TrimSpace(string(url))
-		}
-	}
-
 	return remoteURLs
 }
 
-// promptForPushToRemote asks if the tag should be pushed to remote and which remote to use
-func promptForPushToRemote(remoteURLs map[string]string) (bool, string) {
+// promptForPushToRemote asks if the tag should be pushed to remote and which
+// remote to use. As a step of the flow, b and q are returned as navigation.
+func promptForPushToRemote(remoteURLs map[string]string, config RemotesConfig, step bool) (bool, string, navigation) {
 	// Ask if user wants to push
-	fmt.Print("Do you want to push tag to remote? (Y/n): ")
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
-	input = strings.ToLower(strings.TrimSpace(input))
+	question := "Do you want to push tag to remote?"
+	var push bool
+	nav := navigateNone
+	if step {
+		push, nav = prompter.ConfirmStep(question, true)
+	} else {
+		push = prompter.Confirm(question, true)
+	}
+	if !push {
+		return false, "", nav
+	}
 
-	// If user doesn't want to push, return false
-	if input != "" && input != "y" && input != "yes" {
-		return false, ""
+	classified := classifyRemotes(remoteURLs, config)
+	remotes, hidden := pushableRemotes(classified, config)
+	if len(hidden) > 0 {
+		names := make([]string, len(hidden))
+		for i, remote := range hidden {
+			names[i] = remote.Name
+		}
+		fmt.Printf("Hiding mirror remotes: %s (use --remote or set remotes.showMirrors to push to them)\n", strings.Join(names, ", "))
+	}
+	for _, remote := range classified {
+		if remote.Kind == remoteKindBundle {
+			fmt.Printf("Skipping bundle remote %s: bundles cannot receive pushes (carry the tag over with git bundle create)\n", remote.Name)
+		}
+	}
+	if len(remotes) == 0 {
+		fmt.Println("No remote can receive the tag. Skipping push step.")
+		return false, "", navigateNone
 	}
 
 	// If there's only one remote, use it without asking
-	if len(remoteURLs) == 1 {
-		for name, url := range remoteURLs {
-			fmt.Printf("Using remote: %s (%s)\n", name, url)
-			return true, name
-		}
+	if len(remotes) == 1 {
+		fmt.Printf("Using remote: %s (%s)\n", remotes[0].Name, remotes[0].URL)
+		return true, remotes[0].Name, navigateNone
 	}
 
-	// If there are multiple remotes, let the user choose
-	fmt.Println("Select remote to push to:")
-	remoteNames := make([]string, 0, len(remoteURLs))
-	for name := range remoteURLs {
-		remoteNames = append(remoteNames, name)
+	// If there are multiple remotes, let the user choose; the one pushed to
+	// last time is the default, or else the primary one
+	lastRemote := readPrefs().Remote
+	remotes = preferRemote(remotes, lastRemote)
+	choices := make([]choice, len(remotes))
+	for i, remote := range remotes {
+		choices[i] = choice{Name: remote.Name, Detail: remoteDetail(remote)}
+		if remote.Name == lastRemote {
+			choices[i].Detail += " [last used]"
+		}
 	}
+	title := "Select remote to push to:"
+	if !step {
+		return true, remotes[prompter.Select(title, "remote", choices)].Name, navigateNone
+	}
+	index, nav := prompter.SelectStep(title, "remote", choices)
+	if nav != navigateNone {
+		return false, "", nav
+	}
+	return true, remotes[index].Name, navigateNone
+}
 
-	// Sort remote names for consistent display
-	sort.Strings(remoteNames)
+// buildPushArgs builds the git push arguments for the tag according to the push configuration
+// and the refs releases are published as
+func buildPushArgs(push PushConfig, tags TagsConfig, tag, branch, remote string) []string {
+	args := []string{"push"}
+	for _, option := range push.Options {
+		args = append(args, "--push-option="+option)
+	}
+	if push.FollowTags {
+		args = append(args, "--follow-tags")
+	}
+	args = append(args, remote)
 
-	// Display options
-	for i, name := range remoteNames {
-		fmt.Printf("%d: %s (%s)\n", i+1, name, remoteURLs[name])
+	if len(push.Refspecs) == 0 {
+		if tags.Namespace == "" {
+			return append(args, tag)
+		}
+		return append(args, releaseRefspecs(tags, tag)...)
+	}
+	replacer := strings.NewReplacer("{tag}", tag, "{branch}", branch, "{ref}", releaseRef(tags, tag))
+	for _, refspec := range push.Refspecs {
+		args = append(args, replacer.Replace(refspec))
 	}
+	return args
+}
 
-	// Default to first remote
-	defaultRemote := remoteNames[0]
-	fmt.Printf("Enter number (default: 1 for %s): ", defaultRemote)
+// pushTagToRemote pushes the tag to the specified remote using the planned push arguments
+func pushTagToRemote(tag, remote string, pushArgs []string, sshKey string) error {
+	// The planned arguments start with the push subcommand itself
+	done := make(chan error, 1)
+	go func() { done <- gitClient.Push(pushArgs[1:], sshKey) }()
 
-	// Read user selection
-	input, _ = reader.ReadString('\n')
-	input = strings.TrimSpace(input)
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(pushSlowAfter):
+		// Keep waiting, but tell the user what may be wrong with the connection
+		fmt.Println("Push taking longer than expected...")
+		if diagnostics := connectionDiagnostics(remote, getAllRemoteURLs()[remote]); diagnostics != "" {
+			fmt.Println(diagnostics)
+		}
+		err = <-done
+	}
 
-	// Handle default or parse selection
-	selectedRemote := defaultRemote
-	if input != "" {
-		if idx, err := strconv.Atoi(input); err == nil && idx > 0 && idx <= len(remoteNames) {
-			selectedRemote = remoteNames[idx-1]
-		} else {
-			fmt.Printf("Invalid selection, using default remote: %s\n", defaultRemote)
+	if err == nil {
+		return nil
+	}
+	// A tag pushed meanwhile by another publisher is never overwritten, since
+	// the push does not force; say so instead of only relaying git's rejection
+	if remoteCommit, _, lookupErr := gitClient.RemoteTag(remote, tag); lookupErr == nil && remoteCommit != "" {
+		if local, _ := gitClient.RevParse("refs/tags/" + tag + "^{commit}"); local != remoteCommit {
+			return fmt.Errorf("pushing tag %s to remote %s: another publisher pushed it first at %s; nothing was overwritten. Remove the local tag with git tag -d %s and fetch the tags", tag, remote, shortHash(remoteCommit), tag)
 		}
 	}
+	if isNetworkError(err) {
+		if diagnostics := connectionDiagnostics(remote, getAllRemoteURLs()[remote]); diagnostics != "" {
+			return fmt.Errorf("pushing tag %s to remote %s: %v\n%s", tag, remote, err, diagnostics)
+		}
+	}
+	return fmt.Errorf("pushing tag %s to remote %s: %v", tag, remote, err)
+}
 
-	return true, selectedRemote
+// lookupCommit returns the commit hash the branch points to, falling back to the remote-tracking branch
+func lookupCommit(branch string) (string, error) {
+	return gitClient.RevParse(branchRef(branch) + "^{commit}")
 }
 
-// pushTagToRemote pushes the tag to the specified remote
-func pushTagToRemote(tag, remote string) {
-	cmd := execCommand("git", "push", remote, tag)
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("Error pushing tag %s to remote %s: %v\n", tag, remote, err)
-		os.Exit(1)
+// branchRef returns the full ref of branch: the local branch or, for a branch
+// that only exists on a remote, its remote-tracking branch, preferring origin.
+// Full refs keep names with slashes such as release/1.2 apart from remote names
+// and from tags of the same name. Unknown branches are returned unchanged.
+func branchRef(branch string) string {
+	for _, ref := range []string{"refs/heads/" + branch, "refs/remotes/origin/" + branch} {
+		if _, err := gitClient.RevParse(ref); err == nil {
+			return ref
+		}
+	}
+	refs, err := gitClient.Refs("refs/remotes/*/" + branch)
+	if err != nil {
+		return branch
+	}
+	for _, ref := range refs {
+		// The pattern also matches refs below the name (release/1.2/rc)
+		if _, name, _ := strings.Cut(strings.TrimPrefix(ref, "refs/remotes/"), "/"); name == branch {
+			return ref
+		}
 	}
+	return branch
 }
 
-// createTag creates a tag on the specified branch
-func createTag(branch, tag string) {
-	// Get commit hash from branch
-	cmd := execCommand("git", "rev-parse", branch)
-	commitHash, err := cmd.Output()
-	if err != nil {
-		fmt.Printf("Error getting commit hash for branch %s: %v\n", branch, err)
-		os.Exit(1)
+// createTag creates a tag on the specified commit
+func createTag(commit, tag string) error {
+	if err := gitClient.CreateTag(tag, commit); err != nil {
+		return tagCreationError(tag, err)
 	}
+	return nil
+}
 
-	// Create tag
-	cmd = execCommand("git", "tag", tag, strings.TrimSpace(string(commitHash)))
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("Error creating tag %s: %v\n", tag, err)
-		os.Exit(1)
+// tagCreationError explains a failed tag creation, telling a tag that a
+// concurrent publisher created first apart from other failures
+func tagCreationError(tag string, err error) error {
+	if existing, lookupErr := gitClient.RevParse("refs/tags/" + tag + "^{commit}"); lookupErr == nil {
+		return fmt.Errorf("creating tag %s: it was created meanwhile at %s, probably by another publisher; it was left untouched", tag, shortHash(existing))
 	}
+	return fmt.Errorf("creating tag %s: %v", tag, err)
 }
@@ -57,7 +57,9 @@ func TestExtractPrefix(t *testing.T) {
 		{"release1.2.3", "release"},
 		{"dev0.1.0", "dev"},
 		{"1.0.0", ""}, // No prefix
-		{"", ""},      // Empty string
+		{"modules/s3-bucket/v0.0.0", "modules/s3-bucket/v"},
+		{"v2beta", "v"},
+		{"", ""}, // Empty string
 	}
 
 	for _, tc := range testCases {
@@ -70,6 +72,55 @@ func TestExtractPrefix(t *testing.T) {
 	}
 }
 
+// TestValidateTagFormat tests which tags belong to a tag prefix
+func TestValidateTagFormat(t *testing.T) {
+	testCases := []struct {
+		tag      string
+		prefix   string
+		expected bool
+	}{
+		{"v1.2.3", "v", true},
+		{"v1.2", "v", false},
+		{"g1.2.3", "v", false},
+		{"1.2.3", "", true},
+		{"0.10.0", "", true},
+		{"v1.2.3", "", false},
+		{"2024-backup", "", false},
+		{"2024.06.01", "", false}, // a date, not a version
+		{"1.2.3-rc.1", "", false},
+		{"+1.2.3", "", false},
+		{"1.-2.3", "", false},
+		{"v01.2.3", "v", false},
+	}
+
+	for _, tc := range testCases {
+		if result := validateTagFormat(tc.tag, tc.prefix); result != tc.expected {
+			t.Errorf("validateTagFormat(%q, %q) = %v, expected %v", tc.tag, tc.prefix, result, tc.expected)
+		}
+		if result := tagPattern(tc.prefix + "0.0.0").MatchString(tc.tag); result != tc.expected {
+			t.Errorf("tagPattern(%q) matches %q = %v, expected %v", tc.prefix+"0.0.0", tc.tag, result, tc.expected)
+		}
+	}
+}
+
+// TestTagGlob tests the patterns tags of a format are listed with
+func TestTagGlob(t *testing.T) {
+	testCases := []struct {
+		tagFormat string
+		expected  string
+	}{
+		{"v0.0.0", "v*"},
+		{"modules/s3/v0.0.0", "modules/s3/v*"},
+		{"0.0.0", "[0-9]*"},
+	}
+
+	for _, tc := range testCases {
+		if result := tagGlob(tc.tagFormat); result != tc.expected {
+			t.Errorf("tagGlob(%q) = %q, expected %q", tc.tagFormat, result, tc.expected)
+		}
+	}
+}
+
 // TestIsTagVersionGreater tests the tag version comparison
 func TestIsTagVersionGreater(t *testing.T) {
 	testCases := []struct {
@@ -129,15 +180,6 @@ func TestCalculateNextTag(t *testing.T) {
 func TestGrayScaleTagging(t *testing.T) {
 	// Test the specific issue with g1.9.9 -> g1.9.10 instead of g1.10.0
 
-	// Save original functions to restore them after the test
-	originalExec := execCommand
-	originalTagOnBranch := isTagOnBranchFunc
-
-	defer func() {
-		execCommand = originalExec
-		isTagOnBranchFunc = originalTagOnBranch
-	}()
-
 	// Test case 1: With current implementation, g1.9.9 would increment to g1.9.10
 	lastTag := "g1.9.9"
 	tagFormat := "g0.0.0"
@@ -163,38 +205,13 @@ func TestGrayScaleTagging(t *testing.T) {
 		t.Errorf("isTagVersionGreater(g1.9.10, g1.9.9) returned false, expected true")
 	}
 
-	// Test case 4: Mock getLastTag to see that g1.9.10 is correctly determined to be newer than g1.9.9
-	// First ensure hasAnyTags returns true
-	execCommand = func(cmd string, args ...string) *exec.Cmd {
-		// Mock hasAnyTags check
-		if cmd == "git" && len(args) == 2 && args[0] == "tag" && args[1] == "-l" {
-			cs := []string{"-test.run=TestHelperProcess", "--", cmd}
-			cs = append(cs, args...)
-			mockCmd := exec.Command(os.Args[0], cs...)
-			mockCmd.Env = []string{"GO_WANT_HELPER_PROCESS=1", "TEST_TAGS=g1.9.9,g1.9.10"}
-			return mockCmd
-		}
+	// Test case 4: getLastTag finds g1.9.10 newer than g1.9.9 on the branch
+	g := newFakeGitClient("a", "b")
+	g.branches["gray"] = "b"
+	g.tags = map[string]string{"g1.9.9": "a", "g1.9.10": "b"}
+	useFakeGit(t, g)
 
-		// Mock the sorted tag list (--sort=-v:refname returns newest tags first)
-		if cmd == "git" && len(args) >= 3 && args[0] == "tag" && args[1] == "--list" {
-			cs := []string{"-test.run=TestHelperProcess", "--", cmd}
-			cs = append(cs, args...)
-			mockCmd := exec.Command(os.Args[0], cs...)
-			// Return them in reverse order since Git sorts them with newest first
-			mockCmd.Env = []string{"GO_WANT_HELPER_PROCESS=1", "TEST_TAGS=g1.9.10,g1.9.9"}
-			return mockCmd
-		}
-
-		// Mock other git commands
-		return exec.Command("echo", "Testing")
-	}
-
-	// Mock isTagOnBranch to return true only for g1.9.10
-	isTagOnBranchFunc = func(tag, branch string) bool {
-		return tag == "g1.9.10"
-	}
-
-	latestTag := getLastTag("gray", "g0.0.0")
+	latestTag := getLastTag("gray", "g0.0.0", false)
 	expectedLatest := "g1.9.10"
 
 	if latestTag != expectedLatest {
@@ -403,48 +420,16 @@ func TestGetLastTagSorting(t *testing.T) {
 	// This is a more integration-oriented test, but we can still test the logic
 	// by setting up a fake list of tags that would be incorrectly sorted by string comparison
 
-	// Save original functions to restore them after the test
-	originalExec := execCommand
-	originalTagOnBranch := isTagOnBranchFunc
-
-	defer func() {
-		execCommand = originalExec
-		isTagOnBranchFunc = originalTagOnBranch
-	}()
-
-	// Mock the git command execution
-	execCommand = func(cmd string, args ...string) *exec.Cmd {
-		// Mock the hasAnyTags check
-		if cmd == "git" && len(args) == 2 && args[0] == "tag" && args[1] == "-l" {
-			cs := []string{"-test.run=TestHelperProcess", "--", cmd}
-			cs = append(cs, args...)
-			mockCmd := exec.Command(os.Args[0], cs...)
-			mockCmd.Env = []string{"GO_WANT_HELPER_PROCESS=1", "TEST_HAS_TAGS=true"}
-			return mockCmd
-		}
-
-		// For git tag --list, return our test tags sorted by the git command
-		if cmd == "git" && len(args) >= 3 && args[0] == "tag" && args[1] == "--list" {
-			// Create a fake command that will output our test data
-			cs := []string{"-test.run=TestHelperProcess", "--", cmd}
-			cs = append(cs, args...)
-			mockCmd := exec.Command(os.Args[0], cs...)
-			// Return sorted tags (g2.0.0 first since --sort=-v:refname sorts descending)
-			mockCmd.Env = []string{"GO_WANT_HELPER_PROCESS=1", "TEST_TAGS=g2.0.0,g1.10.0,g1.9.10,g1.9.9,g1.9.1"}
-			return mockCmd
-		}
-
-		// For other commands, use a mock implementation
-		return exec.Command("echo", "Testing")
-	}
-
-	// Mock isTagOnBranch to return true only for the first tag (g2.0.0)
-	isTagOnBranchFunc = func(tag, branch string) bool {
-		return tag == "g2.0.0"
-	}
+	// g3.0.0 is on another branch; of the tags on gray, g2.0.0 is the highest
+	// version, above g1.10.0, which is below g1.9.9 as a string
+	g := newFakeGitClient("a", "b", "c")
+	g.parents["d"] = "b"
+	g.branches["gray"] = "c"
+	g.tags = map[string]string{"g1.9.1": "a", "g1.9.9": "a", "g1.9.10": "b", "g1.10.0": "b", "g2.0.0": "c", "g3.0.0": "d"}
+	useFakeGit(t, g)
 
 	// Run the test
-	result := getLastTag("gray", "g0.0.0")
+	result := getLastTag("gray", "g0.0.0", false)
 	expected := "g2.0.0"
 
 	if result != expected {
@@ -452,27 +437,59 @@ func TestGetLastTagSorting(t *testing.T) {
 	}
 }
 
-// TestHelperProcess is not a real test, it's used to mock command execution
-func TestHelperProcess(t *testing.T) {
-	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
-		return
+// TestBuildPushArgs tests the git push arguments built from the push configuration
+func TestBuildPushArgs(t *testing.T) {
+	releases := TagsConfig{Namespace: "refs/releases"}
+	testCases := []struct {
+		name     string
+		push     PushConfig
+		tags     TagsConfig
+		expected []string
+	}{
+		{"default", PushConfig{}, TagsConfig{}, []string{"push", "origin", "v1.0.0"}},
+		{"options", PushConfig{Options: []string{"ci.skip"}, FollowTags: true}, TagsConfig{},
+			[]string{"push", "--push-option=ci.skip", "--follow-tags", "origin", "v1.0.0"}},
+		{"refspecs", PushConfig{Refspecs: []string{"refs/tags/{tag}:refs/tags/{tag}", "{branch}"}}, TagsConfig{},
+			[]string{"push", "origin", "refs/tags/v1.0.0:refs/tags/v1.0.0", "main"}},
+		{"namespace", PushConfig{}, releases, []string{"push", "origin", "refs/tags/v1.0.0", "refs/releases/v1.0.0"}},
+		{"namespace only", PushConfig{}, TagsConfig{Namespace: "refs/releases", NamespaceOnly: true},
+			[]string{"push", "origin", "refs/releases/v1.0.0"}},
+		{"namespace refspecs", PushConfig{Refspecs: []string{"{ref}:refs/deploy/{tag}"}}, releases,
+			[]string{"push", "origin", "refs/releases/v1.0.0:refs/deploy/v1.0.0"}},
 	}
 
-	// Mock hasAnyTags check
-	if os.Getenv("TEST_HAS_TAGS") == "true" {
-		fmt.Println("v1.0.0")
-		os.Exit(0)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := buildPushArgs(tc.push, tc.tags, "v1.0.0", "main", "origin")
+			if strings.Join(result, " ") != strings.Join(tc.expected, " ") {
+				t.Errorf("buildPushArgs() = %v, expected %v", result, tc.expected)
+			}
+		})
 	}
+}
 
-	// Get the tags we want to return
-	tags := os.Getenv("TEST_TAGS")
-	if tags != "" {
-		// Convert comma-separated list to newline-separated list
-		tagList := strings.Split(tags, ",")
-		for _, tag := range tagList {
-			fmt.Println(tag)
-		}
+func TestConcurrentPublisherErrors(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.remotes["origin"] = "git@example.com:acme/tool.git"
+	g.tags = map[string]string{"v1.0.0": "a"}
+	useFakeGit(t, g)
+
+	// Another publisher created the tag between the checks and the creation
+	if err := createTag("b", "v1.0.0"); err == nil || !strings.Contains(err.Error(), "created meanwhile at a") {
+		t.Errorf("createTag() of a tag created meanwhile = %v, want it explained", err)
+	}
+	if g.tags["v1.0.0"] != "a" {
+		t.Errorf("tag v1.0.0 = %s, want it left at a", g.tags["v1.0.0"])
 	}
 
-	os.Exit(0)
+	// ... or pushed it first
+	g.pushErr = fmt.Errorf("! [rejected] v1.0.0 -> v1.0.0 (already exists)")
+	g.remoteTags = map[string]string{"origin/v1.0.0": "b"}
+	if err := pushTagToRemote("v1.0.0", "origin", []string{"push", "origin", "v1.0.0"}, ""); err == nil || !strings.Contains(err.Error(), "another publisher pushed it first at b") {
+		t.Errorf("pushTagToRemote() of a tag pushed meanwhile = %v, want it explained", err)
+	}
+	g.remoteTags = nil
+	if err := pushTagToRemote("v1.0.0", "origin", []string{"push", "origin", "v1.0.0"}, ""); err == nil || strings.Contains(err.Error(), "another publisher") {
+		t.Errorf("pushTagToRemote() with another rejection = %v, want git's error only", err)
+	}
 }
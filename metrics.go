@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// publishDurationBuckets are the upper bounds, in seconds, of the publish
+// duration histogram
+var publishDurationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600}
+
+// releaseMetrics counts the releases of serve per repository and renders them
+// in the Prometheus text format
+type releaseMetrics struct {
+	mu      sync.Mutex
+	repos   map[string]*repositoryMetrics
+	started map[string]time.Time
+}
+
+// repositoryMetrics are the metrics of one repository
+type repositoryMetrics struct {
+	published   int
+	failed      int
+	lastRelease time.Time
+	// buckets count the durations up to the bound of the same index
+	buckets       []int
+	durationSum   float64
+	durationCount int
+}
+
+func newReleaseMetrics() *releaseMetrics {
+	return &releaseMetrics{repos: map[string]*repositoryMetrics{}, started: map[string]time.Time{}}
+}
+
+// repository returns the metrics of repo, created on first use. m.mu has to be held.
+func (m *releaseMetrics) repository(repo string) *repositoryMetrics {
+	r, ok := m.repos[repo]
+	if !ok {
+		r = &repositoryMetrics{buckets: make([]int, len(publishDurationBuckets))}
+		m.repos[repo] = r
+	}
+	return r
+}
+
+// subscribe counts the publish runs on bus for repo; a run lasts from its
+// TagComputed event to its Published or PublishFailed event
+func (m *releaseMetrics) subscribe(bus *eventBus, repo string) {
+	bus.subscribe(func(e event) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.started[repo+" "+e.Plan.Tag] = e.Time
+		return nil
+	}, eventTagComputed)
+	bus.subscribe(func(e event) error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		key := repo + " " + e.Plan.Tag
+		r := m.repository(repo)
+		if start, ok := m.started[key]; ok {
+			delete(m.started, key)
+			r.observe(e.Time.Sub(start).Seconds())
+		}
+		if e.Type == eventPublished {
+			r.published++
+			r.lastRelease = e.Time
+		} else {
+			r.failed++
+		}
+		return nil
+	}, eventPublished, eventPublishFailed)
+}
+
+// failure counts a release of repo that failed before its plan was executed
+func (m *releaseMetrics) failure(repo string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.repository(repo).failed++
+}
+
+func (r *repositoryMetrics) observe(seconds float64) {
+	for i, bound := range publishDurationBuckets {
+		if seconds <= bound {
+			r.buckets[i]++
+		}
+	}
+	r.durationSum += seconds
+	r.durationCount++
+}
+
+// handler serves the metrics for Prometheus to scrape
+func (m *releaseMetrics) handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, m.format())
+}
+
+// format renders the metrics in the Prometheus text exposition format
+func (m *releaseMetrics) format() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	repos := make([]string, 0, len(m.repos))
+	for repo := range m.repos {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "# HELP git_publish_publishes_total Tags published.")
+	fmt.Fprintln(&b, "# TYPE git_publish_publishes_total counter")
+	for _, repo := range repos {
+		fmt.Fprintf(&b, "git_publish_publishes_total{repository=%q} %d\n", repo, m.repos[repo].published)
+	}
+	fmt.Fprintln(&b, "# HELP git_publish_failures_total Releases that failed.")
+	fmt.Fprintln(&b, "# TYPE git_publish_failures_total counter")
+	for _, repo := range repos {
+		fmt.Fprintf(&b, "git_publish_failures_total{repository=%q} %d\n", repo, m.repos[repo].failed)
+	}
+	fmt.Fprintln(&b, "# HELP git_publish_publish_duration_seconds Time from computing a tag to publishing it or failing.")
+	fmt.Fprintln(&b, "# TYPE git_publish_publish_duration_seconds histogram")
+	for _, repo := range repos {
+		r := m.repos[repo]
+		for i, bound := range publishDurationBuckets {
+			fmt.Fprintf(&b, "git_publish_publish_duration_seconds_bucket{repository=%q,le=\"%g\"} %d\n", repo, bound, r.buckets[i])
+		}
+		fmt.Fprintf(&b, "git_publish_publish_duration_seconds_bucket{repository=%q,le=\"+Inf\"} %d\n", repo, r.durationCount)
+		fmt.Fprintf(&b, "git_publish_publish_duration_seconds_sum{repository=%q} %g\n", repo, r.durationSum)
+		fmt.Fprintf(&b, "git_publish_publish_duration_seconds_count{repository=%q} %d\n", repo, r.durationCount)
+	}
+	fmt.Fprintln(&b, "# HELP git_publish_last_release_timestamp_seconds When the last tag was published, as a Unix time.")
+	fmt.Fprintln(&b, "# TYPE git_publish_last_release_timestamp_seconds gauge")
+	for _, repo := range repos {
+		if last := m.repos[repo].lastRelease; !last.IsZero() {
+			fmt.Fprintf(&b, "git_publish_last_release_timestamp_seconds{repository=%q} %d\n", repo, last.Unix())
+		}
+	}
+	return b.String()
+}
+
+// metricsRepositoryName labels the metrics of the repository served from the
+// current directory: owner/repo of the origin URL
+func metricsRepositoryName(remoteURLs map[string]string) string {
+	if info, ok := parseRemoteURL(remoteURLs["origin"]); ok {
+		return info.Owner + "/" + info.Repo
+	}
+	return "origin"
+}
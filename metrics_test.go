@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReleaseMetrics(t *testing.T) {
+	m := newReleaseMetrics()
+	bus := &eventBus{handlers: map[string][]eventHandler{}}
+	m.subscribe(bus, "acme/web")
+	start := time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+
+	bus.publish(event{Type: eventTagComputed, Time: start, Plan: Plan{Tag: "v1.0.0"}})
+	bus.publish(event{Type: eventPublished, Time: start.Add(3 * time.Second), Plan: Plan{Tag: "v1.0.0"}})
+	bus.publish(event{Type: eventTagComputed, Time: start, Plan: Plan{Tag: "v1.0.1"}})
+	bus.publish(event{Type: eventPublishFailed, Time: start.Add(20 * time.Second), Plan: Plan{Tag: "v1.0.1"}})
+	m.failure("acme/api")
+
+	rec := httptest.NewRecorder()
+	m.handler(rec, httptest.NewRequest("GET", "/metrics", nil))
+	got := rec.Body.String()
+	for _, want := range []string{
+		`git_publish_publishes_total{repository="acme/web"} 1`,
+		`git_publish_failures_total{repository="acme/web"} 1`,
+		`git_publish_failures_total{repository="acme/api"} 1`,
+		`git_publish_publish_duration_seconds_bucket{repository="acme/web",le="5"} 1`,
+		`git_publish_publish_duration_seconds_bucket{repository="acme/web",le="30"} 2`,
+		`git_publish_publish_duration_seconds_bucket{repository="acme/web",le="+Inf"} 2`,
+		`git_publish_publish_duration_seconds_sum{repository="acme/web"} 23`,
+		`git_publish_last_release_timestamp_seconds{repository="acme/web"} 1777896003`,
+		"# TYPE git_publish_publish_duration_seconds histogram",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics lack %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `git_publish_last_release_timestamp_seconds{repository="acme/api"}`) {
+		t.Errorf("metrics have a last release of a repository without releases:\n%s", got)
+	}
+}
+
+func TestMetricsRepositoryName(t *testing.T) {
+	tests := []struct {
+		remoteURLs map[string]string
+		want       string
+	}{
+		{map[string]string{"origin": "git@github.com:acme/web.git"}, "acme/web"},
+		{map[string]string{"origin": "https://gitlab.example.com/acme/api"}, "acme/api"},
+		{map[string]string{}, "origin"},
+	}
+	for _, tt := range tests {
+		if got := metricsRepositoryName(tt.remoteURLs); got != tt.want {
+			t.Errorf("metricsRepositoryName(%v) = %q, want %q", tt.remoteURLs, got, tt.want)
+		}
+	}
+}
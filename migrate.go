@@ -0,0 +1,249 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Statuses of a migration other than the reason it is skipped
+const (
+	migrationCreate = "create"
+	migrationDone   = "already migrated"
+)
+
+// migration renames one legacy tag
+type migration struct {
+	OldTag string
+	NewTag string
+	Commit string
+	// Status is migrationCreate, migrationDone or why the tag is skipped
+	Status string
+}
+
+// legacyPlaceholder matches the placeholders of a --from template
+var legacyPlaceholder = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// runMigrateCommand implements the migrate command: it renames legacy tags
+// into a tag format, creating the new tags at the same commits
+func runMigrateCommand(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", "", "legacy tag template with {major}, {minor} and {patch}, e.g. release_{major}_{minor}_{patch}")
+	to := fs.String("to", "", "tag format of the new tags, e.g. v0.0.0")
+	deleteOld := fs.Bool("delete-old", false, "delete the legacy tags once their new tags exist")
+	dryRun := fs.Bool("dry-run", false, "only show what would be done")
+	fs.Parse(args)
+
+	if *from == "" || *to == "" {
+		fmt.Println("Usage: git-publish migrate --from <template> --to <format> [--delete-old] [--dry-run]")
+		os.Exit(1)
+	}
+	pattern, err := legacyTagPattern(*from)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !validateTagFormat(*to, extractPrefix(*to)) {
+		fmt.Printf("Error: tag format %q is not <prefix>X.Y.Z\n", *to)
+		os.Exit(1)
+	}
+
+	migrations, err := planMigration(pattern, *to)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(migrations) == 0 {
+		fmt.Printf("No tags match %s\n", *from)
+		return
+	}
+	printMigrations(migrations, *deleteOld)
+
+	creating, deleting := countMigrations(migrations, *deleteOld)
+	if *dryRun {
+		fmt.Printf("Dry run: %d tags would be created and %d deleted.\n", creating, deleting)
+		return
+	}
+	if creating == 0 && deleting == 0 {
+		fmt.Println("Nothing to migrate.")
+		return
+	}
+
+	question := fmt.Sprintf("Create %d tags?", creating)
+	if deleting > 0 {
+		question = fmt.Sprintf("Create %d tags and delete %d legacy tags?", creating, deleting)
+	}
+	// Deleting tags is harder to undo, so it needs an explicit yes
+	if !prompter.Confirm(question, deleting == 0) {
+		fmt.Println("Migration cancelled.")
+		return
+	}
+
+	created, deleted, failed := applyMigration(migrations, *deleteOld)
+	fmt.Printf("Created %d tags, deleted %d legacy tags.\n", len(created), len(deleted))
+	if len(created) > 0 {
+		fmt.Printf("Push the new tags with: git push <remote> %s\n", strings.Join(created, " "))
+	}
+	if len(deleted) > 0 {
+		fmt.Printf("Delete the legacy tags on the remote with: git push <remote> --delete %s\n", strings.Join(deleted, " "))
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// legacyTagPattern compiles a --from template into a regular expression.
+// {major} is required; {minor} and {patch} are optional and default to 0.
+func legacyTagPattern(template string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	seen := map[string]bool{}
+	last := 0
+	for _, loc := range legacyPlaceholder.FindAllStringSubmatchIndex(template, -1) {
+		name := template[loc[2]:loc[3]]
+		if name != "major" && name != "minor" && name != "patch" {
+			return nil, fmt.Errorf("unknown placeholder {%s} in %q, use {major}, {minor} and {patch}", name, template)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("placeholder {%s} appears twice in %q", name, template)
+		}
+		seen[name] = true
+		b.WriteString(regexp.QuoteMeta(template[last:loc[0]]))
+		b.WriteString(`(?P<` + name + `>\d+)`)
+		last = loc[1]
+	}
+	if !seen["major"] {
+		return nil, fmt.Errorf("template %q has no {major} placeholder", template)
+	}
+	b.WriteString(regexp.QuoteMeta(template[last:]))
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// planMigration maps every tag matching pattern to the tag format, ordered by
+// version. New tags that already exist at the same commit count as migrated;
+// anything else that would be ambiguous is skipped with the reason as status.
+func planMigration(pattern *regexp.Regexp, toFormat string) ([]migration, error) {
+	tags, err := gitClient.ListTags("")
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %v", err)
+	}
+	prefix := extractPrefix(toFormat)
+
+	var migrations []migration
+	for _, tag := range tags {
+		match := pattern.FindStringSubmatch(tag)
+		if match == nil {
+			continue
+		}
+		version := [3]int{}
+		for i, name := range []string{"major", "minor", "patch"} {
+			if index := pattern.SubexpIndex(name); index > 0 {
+				version[i], _ = strconv.Atoi(match[index])
+			}
+		}
+		newTag := fmt.Sprintf("%s%d.%d.%d", prefix, version[0], version[1], version[2])
+		if newTag == tag {
+			continue
+		}
+		commit, err := gitClient.RevParse("refs/tags/" + tag + "^{commit}")
+		if err != nil {
+			return nil, fmt.Errorf("resolving tag %s: %v", tag, err)
+		}
+		migrations = append(migrations, migration{OldTag: tag, NewTag: newTag, Commit: commit})
+	}
+
+	sort.SliceStable(migrations, func(i, j int) bool {
+		if migrations[i].NewTag == migrations[j].NewTag {
+			return migrations[i].OldTag < migrations[j].OldTag
+		}
+		return isTagVersionGreater(migrations[j].NewTag, migrations[i].NewTag)
+	})
+
+	// Legacy tags mapping to the same new tag, e.g. release_1_02 and release_1_2
+	sources := map[string][]string{}
+	for _, m := range migrations {
+		sources[m.NewTag] = append(sources[m.NewTag], m.OldTag)
+	}
+
+	for i := range migrations {
+		m := &migrations[i]
+		if others := sources[m.NewTag]; len(others) > 1 {
+			m.Status = "skipped: " + strings.Join(others, " and ") + " map to the same tag"
+			continue
+		}
+		existing, err := gitClient.RevParse("refs/tags/" + m.NewTag + "^{commit}")
+		switch {
+		case err != nil:
+			m.Status = migrationCreate
+		case existing == m.Commit:
+			m.Status = migrationDone
+		default:
+			m.Status = "skipped: " + m.NewTag + " exists at another commit"
+		}
+	}
+	return migrations, nil
+}
+
+// countMigrations returns how many tags would be created and deleted
+func countMigrations(migrations []migration, deleteOld bool) (creating, deleting int) {
+	for _, m := range migrations {
+		if m.Status == migrationCreate {
+			creating++
+		}
+		if deleteOld && (m.Status == migrationCreate || m.Status == migrationDone) {
+			deleting++
+		}
+	}
+	return creating, deleting
+}
+
+// printMigrations shows the preview of a migration
+func printMigrations(migrations []migration, deleteOld bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LEGACY TAG\tNEW TAG\tCOMMIT\tACTION")
+	for _, m := range migrations {
+		action := m.Status
+		if deleteOld && (m.Status == migrationCreate || m.Status == migrationDone) {
+			action += ", delete legacy tag"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.OldTag, m.NewTag, shortCommit(m.Commit), action)
+	}
+	w.Flush()
+}
+
+// applyMigration creates the new tags and, with deleteOld, deletes the legacy
+// tags whose new tag exists. It returns the created and deleted tags and the
+// number of failures, which are reported and do not stop the other tags.
+func applyMigration(migrations []migration, deleteOld bool) (created, deleted []string, failed int) {
+	for _, m := range migrations {
+		switch m.Status {
+		case migrationCreate:
+			if err := createTag(m.Commit, m.NewTag); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				failed++
+				continue
+			}
+			created = append(created, m.NewTag)
+		case migrationDone:
+		default:
+			continue
+		}
+
+		if !deleteOld {
+			continue
+		}
+		if err := gitClient.DeleteTag(m.OldTag); err != nil {
+			fmt.Printf("Error deleting tag %s: %v\n", m.OldTag, err)
+			failed++
+			continue
+		}
+		deleted = append(deleted, m.OldTag)
+	}
+	return created, deleted, failed
+}
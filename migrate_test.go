@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLegacyTagPattern(t *testing.T) {
+	tests := []struct {
+		template string
+		tag      string
+		match    bool
+		wantErr  bool
+	}{
+		{"release_{major}_{minor}_{patch}", "release_1_2_3", true, false},
+		{"release_{major}_{minor}_{patch}", "release_1_2", false, false},
+		{"release_{major}_{minor}_{patch}", "xrelease_1_2_3", false, false},
+		{"v{major}.{minor}", "v1.2", true, false},
+		{"v{major}.{minor}", "v1x2", false, false},
+		{"build-{patch}", "", false, true},
+		{"r{major}-{build}", "", false, true},
+		{"r{major}.{major}", "", false, true},
+	}
+
+	for _, tt := range tests {
+		pattern, err := legacyTagPattern(tt.template)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("legacyTagPattern(%q) error = %v, want error: %v", tt.template, err, tt.wantErr)
+			continue
+		}
+		if err == nil && pattern.MatchString(tt.tag) != tt.match {
+			t.Errorf("legacyTagPattern(%q) matches %q = %v, want %v", tt.template, tt.tag, !tt.match, tt.match)
+		}
+	}
+}
+
+func TestPlanMigration(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c", "d")
+	g.tags = map[string]string{
+		"release_1_0_0":  "a",
+		"release_1_2_0":  "b",
+		"release_1_02_0": "c",
+		"release_2_0_0":  "c",
+		"release_3_0_0":  "d",
+		"v2.0.0":         "c",
+		"v3.0.0":         "b",
+		"other":          "d",
+	}
+	useFakeGit(t, g)
+
+	pattern, err := legacyTagPattern("release_{major}_{minor}_{patch}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := planMigration(pattern, "v0.0.0")
+	if err != nil {
+		t.Fatalf("planMigration() error = %v", err)
+	}
+
+	want := []migration{
+		{"release_1_0_0", "v1.0.0", "a", migrationCreate},
+		{"release_1_02_0", "v1.2.0", "c", "skipped: release_1_02_0 and release_1_2_0 map to the same tag"},
+		{"release_1_2_0", "v1.2.0", "b", "skipped: release_1_02_0 and release_1_2_0 map to the same tag"},
+		{"release_2_0_0", "v2.0.0", "c", migrationDone},
+		{"release_3_0_0", "v3.0.0", "d", "skipped: v3.0.0 exists at another commit"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Errorf("planMigration() =\n%v\nwant\n%v", migrations, want)
+	}
+
+	creating, deleting := countMigrations(migrations, true)
+	if creating != 1 || deleting != 2 {
+		t.Errorf("countMigrations() = %d, %d, want 1, 2", creating, deleting)
+	}
+}
+
+func TestApplyMigration(t *testing.T) {
+	tests := []struct {
+		name        string
+		deleteOld   bool
+		wantCreated []string
+		wantDeleted []string
+		wantTags    []string
+	}{
+		{"keep legacy tags", false, []string{"v1.0.0"}, nil, []string{"release_1_0_0", "release_2_0_0", "release_3_0_0", "v1.0.0", "v2.0.0", "v3.0.0"}},
+		{"delete legacy tags", true, []string{"v1.0.0"}, []string{"release_1_0_0", "release_2_0_0"}, []string{"release_3_0_0", "v1.0.0", "v2.0.0", "v3.0.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGitClient("a", "b", "c")
+			g.tags = map[string]string{"release_1_0_0": "a", "release_2_0_0": "b", "release_3_0_0": "c", "v2.0.0": "b", "v3.0.0": "a"}
+			useFakeGit(t, g)
+
+			migrations := []migration{
+				{"release_1_0_0", "v1.0.0", "a", migrationCreate},
+				{"release_2_0_0", "v2.0.0", "b", migrationDone},
+				{"release_3_0_0", "v3.0.0", "c", "skipped: v3.0.0 exists at another commit"},
+			}
+			created, deleted, failed := applyMigration(migrations, tt.deleteOld)
+			if failed != 0 || !reflect.DeepEqual(created, tt.wantCreated) || !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("applyMigration() = %v, %v, %d, want %v, %v, 0", created, deleted, failed, tt.wantCreated, tt.wantDeleted)
+			}
+			if got := sortedKeys(g.tags); !reflect.DeepEqual(got, tt.wantTags) {
+				t.Errorf("tags = %v, want %v", got, tt.wantTags)
+			}
+			if g.tags["v1.0.0"] != "a" {
+				t.Errorf("v1.0.0 points at %q, want the commit of release_1_0_0", g.tags["v1.0.0"])
+			}
+		})
+	}
+}
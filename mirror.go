@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// PlannedMirror is a mirror remote the tag is replicated to after the push
+type PlannedMirror struct {
+	Remote   string   `json:"remote"`
+	PushArgs []string `json:"pushArgs"`
+}
+
+// mirrorResult is the outcome of replicating the tag to one mirror
+type mirrorResult struct {
+	Remote string
+	Err    error
+}
+
+// plannedMirrors lists the configured mirrors the tag of a pushed plan is
+// replicated to. The remote the tag is pushed to is skipped, as are mirrors
+// that are not configured in git.
+func plannedMirrors(config RemotesConfig, remoteURLs map[string]string, plan Plan) []PlannedMirror {
+	if plan.Remote == "" {
+		return nil
+	}
+
+	var mirrors []PlannedMirror
+	for _, remote := range config.Mirrors {
+		if remote == plan.Remote {
+			continue
+		}
+		if _, ok := remoteURLs[remote]; !ok {
+			fmt.Printf("Warning: mirror remote '%s' not found, skipping it\n", remote)
+			continue
+		}
+		if remoteKind(remoteURLs[remote]) == remoteKindBundle {
+			fmt.Printf("Warning: mirror remote '%s' is a bundle file, which cannot receive pushes; skipping it\n", remote)
+			continue
+		}
+		args := []string{"push", remote}
+		if !plan.RefOnly {
+			args = append(args, "refs/tags/"+plan.Tag)
+		}
+		if plan.ReleaseRef != "" {
+			args = append(args, plan.ReleaseRef)
+		}
+		if config.MirrorBranch {
+			args = append(args, plan.TargetCommit+":refs/heads/"+plan.Branch)
+		}
+		mirrors = append(mirrors, PlannedMirror{Remote: remote, PushArgs: args})
+	}
+	return mirrors
+}
+
+// replicateToMirrors pushes the tag to every planned mirror. A failing mirror
+// does not stop the others or the publish run; the status of every mirror is printed.
+func replicateToMirrors(mirrors []PlannedMirror, sshKey string) []mirrorResult {
+	green := color.New(color.FgGreen).SprintFunc()
+	yellow := color.New(color.FgYellow).SprintFunc()
+
+	results := make([]mirrorResult, len(mirrors))
+	for i, mirror := range mirrors {
+		results[i] = mirrorResult{Remote: mirror.Remote, Err: gitClient.Push(mirror.PushArgs[1:], sshKey)}
+	}
+
+	for i, result := range results {
+		if result.Err == nil {
+			fmt.Printf("Replicated tag to mirror %s\n", green(result.Remote))
+			continue
+		}
+		fmt.Printf("%s Could not replicate tag to mirror %s: %v\n", yellow("Warning:"), result.Remote, result.Err)
+		fmt.Printf("Retry with: git %s\n", strings.Join(mirrors[i].PushArgs, " "))
+	}
+	return results
+}
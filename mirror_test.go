@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPlannedMirrors(t *testing.T) {
+	remoteURLs := map[string]string{"origin": "git@example.com:acme/tool.git", "backup": "/srv/tool.git", "github": "https://github.com/acme/tool.git"}
+	plan := Plan{Branch: "main", TargetCommit: "abc", Tag: "v1.0.0", Remote: "origin"}
+
+	tests := []struct {
+		name   string
+		config RemotesConfig
+		plan   Plan
+		want   []PlannedMirror
+	}{
+		{"no mirrors", RemotesConfig{}, plan, nil},
+		{
+			"tag only",
+			RemotesConfig{Mirrors: []string{"backup", "origin", "missing"}},
+			plan,
+			[]PlannedMirror{{Remote: "backup", PushArgs: []string{"push", "backup", "refs/tags/v1.0.0"}}},
+		},
+		{
+			"with branch",
+			RemotesConfig{Mirrors: []string{"github"}, MirrorBranch: true},
+			plan,
+			[]PlannedMirror{{Remote: "github", PushArgs: []string{"push", "github", "refs/tags/v1.0.0", "abc:refs/heads/main"}}},
+		},
+		{
+			"namespace only",
+			RemotesConfig{Mirrors: []string{"backup"}},
+			Plan{TargetCommit: "abc", Tag: "v1.0.0", Remote: "origin", ReleaseRef: "refs/releases/v1.0.0", RefOnly: true},
+			[]PlannedMirror{{Remote: "backup", PushArgs: []string{"push", "backup", "refs/releases/v1.0.0"}}},
+		},
+		{"not pushed", RemotesConfig{Mirrors: []string{"backup"}}, Plan{Tag: "v1.0.0"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := plannedMirrors(tt.config, remoteURLs, tt.plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("plannedMirrors() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunPlanStepsReplicatesToMirrors(t *testing.T) {
+	g := newFakeGitClient("a")
+	g.pushErrs = map[string]error{"github": errors.New("connection refused")}
+	useFakeGit(t, g)
+	bus := &eventBus{handlers: make(map[string][]eventHandler)}
+	plan := Plan{
+		Branch: "main", TargetCommit: "a", Tag: "v1.0.0", Remote: "origin",
+		PushArgs: []string{"push", "origin", "v1.0.0"},
+		Mirrors: []PlannedMirror{
+			{Remote: "github", PushArgs: []string{"push", "github", "refs/tags/v1.0.0"}},
+			{Remote: "backup", PushArgs: []string{"push", "backup", "refs/tags/v1.0.0"}},
+		},
+	}
+
+	// A failing mirror neither stops the other mirrors nor fails the run
+	if _, err := runPlanSteps(plan, Config{}, bus, &publishProgress{Plan: plan}); err != nil {
+		t.Fatalf("runPlanSteps() error = %v", err)
+	}
+	want := [][]string{{"origin", "v1.0.0"}, {"github", "refs/tags/v1.0.0"}, {"backup", "refs/tags/v1.0.0"}}
+	if !reflect.DeepEqual(g.pushes, want) {
+		t.Errorf("pushes = %q, want %q", g.pushes, want)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// diagnosticTimeout limits each DNS lookup and connection attempt of the diagnostics
+var diagnosticTimeout = 3 * time.Second
+
+// lookupHost resolves a host name, replaceable in tests
+var lookupHost = func(host string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticTimeout)
+	defer cancel()
+	return net.DefaultResolver.LookupHost(ctx, host)
+}
+
+// dialTCP checks that a TCP connection to address can be opened, replaceable in tests
+var dialTCP = func(address string) error {
+	conn, err := net.DialTimeout("tcp", address, diagnosticTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// networkErrorHints are fragments of git and ssh error messages caused by the network
+var networkErrorHints = []string{
+	"could not resolve",
+	"connection timed out",
+	"connection refused",
+	"connection reset",
+	"failed to connect",
+	"operation timed out",
+	"timed out after",
+	"network is unreachable",
+	"no route to host",
+	"proxy",
+	"unable to access",
+	"ssh: connect to host",
+	"could not read from remote repository",
+}
+
+// isNetworkError reports whether err looks like a connection problem rather than a rejected push
+func isNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, hint := range networkErrorHints {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteEndpoint returns the scheme, host and port git connects to for a remote URL.
+// Local paths and file:// URLs have no endpoint.
+func remoteEndpoint(remoteURL string) (scheme, host, port string, ok bool) {
+	if remoteKind(remoteURL) != "" {
+		return "", "", "", false
+	}
+	if strings.Contains(remoteURL, "://") {
+		u, err := url.Parse(remoteURL)
+		if err != nil || u.Hostname() == "" {
+			return "", "", "", false
+		}
+		scheme, host, port = u.Scheme, u.Hostname(), u.Port()
+		if port == "" {
+			switch scheme {
+			case "https":
+				port = "443"
+			case "http":
+				port = "80"
+			case "git":
+				port = "9418"
+			default:
+				port = "22"
+			}
+		}
+		return scheme, host, port, true
+	}
+
+	// scp-like syntax: [user@]host:path; a colon after a slash is part of a local path
+	colon := strings.Index(remoteURL, ":")
+	if colon <= 0 || strings.Contains(remoteURL[:colon], "/") {
+		return "", "", "", false
+	}
+	host = remoteURL[:colon]
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	return "ssh", host, "22", true
+}
+
+// proxySettings lists the proxy configuration git may use for the remote:
+// git's remote.<name>.proxy and http.proxy, then the proxy environment variables
+func proxySettings(remote string) []string {
+	var settings []string
+	for _, key := range []string{"remote." + remote + ".proxy", "http.proxy"} {
+		if value, _ := gitClient.ConfigValue(key); value != "" {
+			settings = append(settings, fmt.Sprintf("git config %s=%s", key, redactURL(value)))
+		}
+	}
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy", "NO_PROXY", "no_proxy"} {
+		if value := os.Getenv(name); value != "" {
+			settings = append(settings, fmt.Sprintf("%s=%s", name, redactURL(value)))
+		}
+	}
+	return settings
+}
+
+// effectiveProxy returns the proxy URL git uses for an HTTP(S) remote, "" for a direct connection
+func effectiveProxy(remote, scheme, host string) string {
+	for _, key := range []string{"remote." + remote + ".proxy", "http.proxy"} {
+		if value, _ := gitClient.ConfigValue(key); value != "" {
+			return value
+		}
+	}
+	if excludedByNoProxy(host) {
+		return ""
+	}
+	names := []string{"https_proxy", "HTTPS_PROXY", "all_proxy", "ALL_PROXY"}
+	if scheme == "http" {
+		names = []string{"http_proxy", "all_proxy", "ALL_PROXY"}
+	}
+	return firstEnv(names...)
+}
+
+// excludedByNoProxy reports whether NO_PROXY exempts host from the proxy
+func excludedByNoProxy(host string) bool {
+	for _, entry := range strings.Split(firstEnv("no_proxy", "NO_PROXY"), ",") {
+		entry = strings.TrimPrefix(strings.TrimSpace(entry), ".")
+		if entry == "*" || (entry != "" && (host == entry || strings.HasSuffix(host, "."+entry))) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL hides the password of a URL with credentials
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// connectionDiagnostics checks the proxy configuration, name resolution and
+// reachability of a remote and describes the results, one finding per line.
+// It returns "" for remotes that are local paths.
+func connectionDiagnostics(remote, remoteURL string) string {
+	scheme, host, port, ok := remoteEndpoint(remoteURL)
+	if !ok {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Connection diagnostics for %s (%s):\n", remote, redactURL(remoteURL))
+
+	settings := proxySettings(remote)
+	if len(settings) == 0 {
+		fmt.Fprintln(&b, "  Proxy: none configured")
+	}
+	for _, setting := range settings {
+		fmt.Fprintf(&b, "  Proxy: %s\n", setting)
+	}
+
+	address := net.JoinHostPort(host, port)
+	proxy := ""
+	if scheme == "http" || scheme == "https" {
+		proxy = effectiveProxy(remote, scheme, host)
+	} else if len(settings) > 0 {
+		fmt.Fprintln(&b, "  Note: HTTP proxy settings do not apply to SSH remotes; set a ProxyCommand in ~/.ssh/config or use the HTTPS URL")
+	}
+
+	if addrs, err := lookupHost(host); err != nil {
+		fmt.Fprintf(&b, "  DNS: %s does not resolve: %v\n", host, err)
+	} else {
+		fmt.Fprintf(&b, "  DNS: %s resolves to %s\n", host, strings.Join(addrs, ", "))
+	}
+
+	if proxy != "" {
+		fmt.Fprintf(&b, "  Git connects through the proxy %s\n", redactURL(proxy))
+		if u, err := url.Parse(proxy); err == nil && u.Hostname() != "" {
+			proxyPort := u.Port()
+			if proxyPort == "" {
+				// The default proxy port of curl, which git uses for HTTP(S)
+				proxyPort = "1080"
+			}
+			address = net.JoinHostPort(u.Hostname(), proxyPort)
+		}
+	}
+	if err := dialTCP(address); err != nil {
+		fmt.Fprintf(&b, "  TCP: %s is not reachable: %v\n", address, err)
+	} else {
+		fmt.Fprintf(&b, "  TCP: %s is reachable\n", address)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
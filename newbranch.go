@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+)
+
+// offerMissingBranches offers to create the configured branches that do not
+// exist from an existing one, e.g. gray from main, and adds the created ones
+// to the available branches. Only branches whose tag format no existing
+// branch serves are offered (master is left alone next to main), unless
+// wanted names the branch explicitly.
+func offerMissingBranches(configured, available []BranchTagConfig, wanted string) []BranchTagConfig {
+	if len(available) == 0 {
+		return available
+	}
+	served := map[string]bool{}
+	var existing []string
+	for _, bt := range available {
+		served[bt.Tag] = true
+		existing = append(existing, bt.Branch)
+	}
+
+	var offered []string
+	for _, bt := range configured {
+		if contains(existing, bt.Branch) || contains(offered, bt.Branch) {
+			continue
+		}
+		if wanted != bt.Branch && (wanted != "" || served[bt.Tag]) {
+			continue
+		}
+		offered = append(offered, bt.Branch)
+		if created := offerBranch(bt, uniqueStrings(existing)); created {
+			existing = append(existing, bt.Branch)
+			for _, same := range configured {
+				if same.Branch == bt.Branch {
+					available = append(available, same)
+					served[same.Tag] = true
+				}
+			}
+		}
+	}
+	return available
+}
+
+// offerBranch asks whether to create the missing branch of bt and from which
+// of existing; it reports whether the branch was created
+func offerBranch(bt BranchTagConfig, existing []string) bool {
+	green := color.New(color.FgGreen).SprintFunc()
+
+	if !prompter.Confirm(fmt.Sprintf("Branch %s (tags %s) does not exist. Create it now?", bt.Branch, bt.Tag), false) {
+		return false
+	}
+	choices := make([]choice, len(existing))
+	for i, branch := range existing {
+		choices[i] = choice{Name: branch}
+		if commit, err := lookupCommit(branch); err == nil {
+			choices[i].Detail = "(at " + shortHash(commit) + ")"
+		}
+	}
+	from := existing[prompter.Select(fmt.Sprintf("Create %s from:", bt.Branch), "branch", choices)]
+
+	commit, err := lookupCommit(from)
+	if err == nil {
+		err = gitClient.CreateBranch(bt.Branch, commit)
+	}
+	if err != nil {
+		fmt.Printf("Error creating branch %s from %s: %v\n", bt.Branch, from, err)
+		return false
+	}
+	fmt.Printf("Created branch %s from %s at %s; publish it with git push -u <remote> %s\n", green(bt.Branch), from, shortHash(commit), bt.Branch)
+	return true
+}
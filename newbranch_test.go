@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOfferMissingBranches(t *testing.T) {
+	configured := []BranchTagConfig{
+		{Branch: "master", Tag: "v0.0.0"},
+		{Branch: "main", Tag: "v0.0.0"},
+		{Branch: "gray", Tag: "g0.0.0"},
+	}
+	available := []BranchTagConfig{{Branch: "main", Tag: "v0.0.0"}}
+
+	tests := []struct {
+		name         string
+		wanted       string
+		answers      []string
+		wantBranches []string
+		wantAsked    int
+	}{
+		{"create gray from main", "", []string{"y", "1"}, []string{"main", "gray"}, 2},
+		{"declined", "", []string{"n"}, []string{"main"}, 1},
+		{"wanted alias of an existing format", "master", []string{"y", ""}, []string{"main", "master"}, 2},
+		{"another branch wanted", "main", nil, []string{"main"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGitClient("a", "b")
+			useFakeGit(t, g)
+			p := useScriptedPrompter(t, tt.answers...)
+
+			var got []BranchTagConfig
+			captureOutput(func() { got = offerMissingBranches(configured, available, tt.wanted) })
+			var branches []string
+			for _, bt := range got {
+				branches = append(branches, bt.Branch)
+			}
+			if !reflect.DeepEqual(branches, tt.wantBranches) || len(p.asked) != tt.wantAsked {
+				t.Errorf("offerMissingBranches() = %v after %d questions, want %v after %d", branches, len(p.asked), tt.wantBranches, tt.wantAsked)
+			}
+			for _, branch := range tt.wantBranches {
+				if g.branches[branch] != "b" {
+					t.Errorf("branch %s at %q, want it at b", branch, g.branches[branch])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// NotificationsConfig represents where release notifications are sent
+type NotificationsConfig struct {
+	// Webhook receives a JSON POST for every notification
+	Webhook string `json:"webhook,omitempty"`
+	// Command is run through the shell with the notification in its environment
+	Command string `json:"command,omitempty"`
+}
+
+// notification describes the outcome of an unattended release
+type notification struct {
+	Event   string `json:"event"`
+	Tag     string `json:"tag"`
+	Branch  string `json:"branch"`
+	Remote  string `json:"remote,omitempty"`
+	Message string `json:"message"`
+}
+
+// sendNotification delivers n to every configured channel; failures are only reported
+func sendNotification(config NotificationsConfig, n notification) {
+	fmt.Printf("[%s] %s\n", n.Event, n.Message)
+
+	if config.Webhook != "" {
+		if err := newAPIClient("", nil).do("POST", config.Webhook, n, nil); err != nil {
+			fmt.Printf("Warning: notification webhook failed: %v\n", err)
+		}
+	}
+
+	if config.Command != "" {
+		cmd := execCommand("sh", "-c", config.Command)
+		cmd.Env = append(os.Environ(),
+			"GIT_PUBLISH_EVENT="+n.Event,
+			"GIT_PUBLISH_TAG="+n.Tag,
+			"GIT_PUBLISH_BRANCH="+n.Branch,
+			"GIT_PUBLISH_REMOTE="+n.Remote,
+			"GIT_PUBLISH_MESSAGE="+n.Message,
+		)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Printf("Warning: notification command failed: %v\n", err)
+		}
+	}
+}
+
+// subscribeNotifications notifies about the outcome of publish runs
+func subscribeNotifications(bus *eventBus, config NotificationsConfig) {
+	bus.subscribe(func(e event) error {
+		n := notification{Tag: e.Plan.Tag, Branch: e.Plan.Branch, Remote: e.Plan.Remote}
+		if e.Type == eventPublished {
+			n.Event = "release.published"
+			n.Message = fmt.Sprintf("Published %s on %s", e.Plan.Tag, e.Plan.Branch)
+		} else {
+			n.Event = "release.failed"
+			n.Message = fmt.Sprintf("Publishing %s on %s failed: %s", e.Plan.Tag, e.Plan.Branch, e.Error)
+		}
+		sendNotification(config, n)
+		return nil
+	}, eventPublished, eventPublishFailed)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// orderedObject is a JSON object that keeps the order of its keys, so a file
+// the tool rewrites keeps the layout its authors chose. Values are decoded
+// like encoding/json does into interface{}, except that objects are
+// *orderedObject and numbers json.Number, which keeps them as written.
+type orderedObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// newOrderedObject returns an empty object
+func newOrderedObject() *orderedObject {
+	return &orderedObject{values: map[string]interface{}{}}
+}
+
+// parseOrderedObject parses data, which must hold a JSON object
+func parseOrderedObject(data []byte) (*orderedObject, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the JSON object")
+	}
+	object, ok := value.(*orderedObject)
+	if !ok {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+	return object, nil
+}
+
+// decodeOrdered decodes the next value of dec
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := newOrderedObject()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			object.Set(key.(string), value)
+		}
+		_, err := dec.Token()
+		return object, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+	return token, nil
+}
+
+// Keys returns the keys in order
+func (o *orderedObject) Keys() []string {
+	return append([]string(nil), o.keys...)
+}
+
+// Get returns the value of key
+func (o *orderedObject) Get(key string) (interface{}, bool) {
+	value, ok := o.values[key]
+	return value, ok
+}
+
+// Set replaces the value of key in place, or adds key at the end
+func (o *orderedObject) Set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// Delete removes key
+func (o *orderedObject) Delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Rename moves the value of from to the key to, at the position of from. A
+// value to already had is replaced.
+func (o *orderedObject) Rename(from, to string) {
+	value, ok := o.values[from]
+	if !ok || from == to {
+		return
+	}
+	o.Delete(to)
+	delete(o.values, from)
+	for i, k := range o.keys {
+		if k == from {
+			o.keys[i] = to
+			break
+		}
+	}
+	o.values[to] = value
+}
+
+// MarshalJSON writes the keys in order. HTML characters are not escaped,
+// as in the commands of hooks.
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := encodeUnescaped(&b, key); err != nil {
+			return nil, err
+		}
+		b.WriteByte(':')
+		if err := encodeUnescaped(&b, o.values[key]); err != nil {
+			return nil, err
+		}
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// formatOrderedObject indents o as publish.json is written, without a final newline
+func formatOrderedObject(o *orderedObject) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(o); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// encodeUnescaped appends the JSON of v to b without escaping HTML characters
+func encodeUnescaped(b *bytes.Buffer, v interface{}) error {
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	// Encode ends the value with a newline
+	b.Truncate(b.Len() - 1)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOrderedObject(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    string
+		wantErr bool
+	}{
+		// Key order, number literals and HTML characters are kept
+		{`{"z": 1.0, "a": [{"y": true, "b": null}], "m": "a && b <c>"}`, `{"z":1.0,"a":[{"y":true,"b":null}],"m":"a && b <c>"}`, false},
+		{`{}`, `{}`, false},
+		{`[1, 2]`, ``, true},
+		{`{"a": 1} {"b": 2}`, ``, true},
+		{`{"a": }`, ``, true},
+	}
+	for _, tt := range tests {
+		object, err := parseOrderedObject([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseOrderedObject(%s) error = %v, want error %v", tt.data, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		// Formatting and parsing again changes nothing but the indentation
+		data, err := formatOrderedObject(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := orderedJSON(t, string(data)).MarshalJSON()
+		if string(got) != tt.want {
+			t.Errorf("parseOrderedObject(%s) = %s, want %s", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestOrderedObjectEdits(t *testing.T) {
+	object := orderedJSON(t, `{"c": 1, "a": 2, "b": 3}`)
+	object.Set("a", "two")
+	object.Set("d", 4)
+	object.Rename("c", "e")
+	object.Delete("b")
+	object.Delete("missing")
+	object.Rename("missing", "f")
+	if want := []string{"e", "a", "d"}; !reflect.DeepEqual(object.Keys(), want) {
+		t.Errorf("Keys() = %q, want %q", object.Keys(), want)
+	}
+	if value, ok := object.Get("a"); !ok || value != "two" {
+		t.Errorf(`Get("a") = %v, %v, want two`, value, ok)
+	}
+
+	// Renaming onto an existing key replaces it at the position of the old key
+	object.Rename("d", "e")
+	if want := []string{"a", "e"}; !reflect.DeepEqual(object.Keys(), want) {
+		t.Errorf("Keys() after renaming onto e = %q, want %q", object.Keys(), want)
+	}
+	if value, _ := object.Get("e"); value != 4 {
+		t.Errorf(`Get("e") = %v, want 4`, value)
+	}
+}
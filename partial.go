@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Partial clones (git clone --filter=blob:none) fetch file contents on demand
+// from a promisor remote, and sparse checkouts leave files of the index out of
+// the work tree. git-publish only needs commits and trees for most of its
+// work; the few file contents it reads are fetched in one cat-file process,
+// and objects that cannot be fetched are reported instead of being mistaken
+// for missing files.
+
+// missingObjectHints are the messages of git commands that failed because an
+// object is neither in the repository nor fetchable from a promisor remote
+var missingObjectHints = []string{
+	"from promisor remote",
+	"missing blob",
+	"unable to read",
+	"bad object",
+}
+
+// isPartialClone reports whether the repository has a promisor remote
+func isPartialClone() bool {
+	return gitClient.IsPartialClone()
+}
+
+// isMissingObjectError reports whether a git command failed on an object that
+// is not available locally
+func isMissingObjectError(message string) bool {
+	for _, hint := range missingObjectHints {
+		if strings.Contains(message, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// missingObjectError explains an object that could not be read
+func missingObjectError(what, stderr string) error {
+	stderr = strings.TrimSpace(stderr)
+	if lines := strings.Split(stderr, "\n"); len(lines) > 0 {
+		stderr = lines[len(lines)-1]
+	}
+	if isPartialClone() {
+		return fmt.Errorf("%s is not available in this partial clone and could not be fetched from the promisor remote (%s); check the connection to the remote", what, stderr)
+	}
+	return fmt.Errorf("%s could not be read (%s)", what, stderr)
+}
+
+// readBlobs returns the contents of the given rev:path objects, read by a single
+// git cat-file process. Objects that do not exist are left out; objects that
+// exist but cannot be fetched are an error.
+func readBlobs(specs []string) (map[string][]byte, error) {
+	contents, err := gitClient.ReadBlobs(specs)
+	if err != nil {
+		if isMissingObjectError(err.Error()) {
+			return nil, missingObjectError(fmt.Sprintf("the contents of %d file(s)", len(specs)), err.Error())
+		}
+		return nil, fmt.Errorf("git cat-file: %v", err)
+	}
+	return contents, nil
+}
+
+// readIndexFile returns a file of the index that the sparse checkout left out of
+// the work tree; false if the file is not in the index either
+func readIndexFile(file string) ([]byte, bool, error) {
+	skipped := outsideSparseCheckout([]string{file})
+	if len(skipped) == 0 {
+		return nil, false, nil
+	}
+	data, err := gitClient.ReadIndexFile(skipped[0])
+	if err != nil {
+		if isMissingObjectError(err.Error()) {
+			return nil, true, missingObjectError(file, err.Error())
+		}
+		return nil, false, nil
+	}
+	return data, true, nil
+}
+
+// outsideSparseCheckout returns those of the files that the sparse checkout
+// keeps out of the work tree (marked skip-worktree in the index), as paths from
+// the repository root
+func outsideSparseCheckout(files []string) []string {
+	if len(files) == 0 {
+		return nil
+	}
+	skipped, _ := gitClient.SkipWorktreeFiles(files)
+	return skipped
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitInTestRepo runs git in dir, skipping the test when git fails
+func gitInTestRepo(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("git %s failed: %v: %s", strings.Join(args, " "), err, output)
+	}
+}
+
+// newBlobTestRepo creates a repository with one commit holding a.go and
+// publish.json and changes into it
+func newBlobTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	gitInTestRepo(t, dir, "init", "-q", "-b", "main")
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "publish.json"), []byte("{}\n"), 0644)
+	gitInTestRepo(t, dir, "add", ".")
+	gitInTestRepo(t, dir, "commit", "-q", "-m", "initial")
+
+	cwd, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(cwd) })
+	os.Chdir(dir)
+	return dir
+}
+
+func TestIsMissingObjectError(t *testing.T) {
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{"fatal: could not fetch 7898192 from promisor remote", true},
+		{"error: unable to read 7898192", true},
+		{"fatal: bad object HEAD~1:a.go", true},
+		{"fatal: path 'a.go' does not exist in 'HEAD'", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isMissingObjectError(tt.message); got != tt.want {
+			t.Errorf("isMissingObjectError(%q) = %v, want %v", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestReadBlobs(t *testing.T) {
+	newBlobTestRepo(t)
+
+	blobs, err := readBlobs([]string{"HEAD:a.go", "HEAD:missing.go", "HEAD:publish.json"})
+	if err != nil {
+		t.Fatalf("readBlobs: %v", err)
+	}
+	if string(blobs["HEAD:a.go"]) != "package a\n" || string(blobs["HEAD:publish.json"]) != "{}\n" {
+		t.Errorf("readBlobs = %q", blobs)
+	}
+	if _, ok := blobs["HEAD:missing.go"]; ok {
+		t.Errorf("readBlobs returned a file that does not exist")
+	}
+
+	if blobs, err := readBlobs(nil); err != nil || len(blobs) != 0 {
+		t.Errorf("readBlobs(nil) = %v, %v", blobs, err)
+	}
+}
+
+func TestReadBlobsPartialClone(t *testing.T) {
+	src := t.TempDir()
+	gitInTestRepo(t, src, "init", "-q", "-b", "main")
+	os.WriteFile(filepath.Join(src, "a.go"), []byte("package a\n"), 0644)
+	os.WriteFile(filepath.Join(src, "go.mod"), []byte("module example.com/a\n"), 0644)
+	gitInTestRepo(t, src, "add", ".")
+	gitInTestRepo(t, src, "commit", "-q", "-m", "initial")
+	gitInTestRepo(t, src, "config", "uploadpack.allowFilter", "true")
+
+	clone := filepath.Join(t.TempDir(), "clone")
+	gitInTestRepo(t, src, "clone", "-q", "--filter=blob:none", "--no-checkout", "file://"+src, clone)
+	cwd, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(cwd) })
+	os.Chdir(clone)
+
+	if !isPartialClone() {
+		t.Fatalf("isPartialClone() = false in a blob:none clone")
+	}
+
+	// Blobs cannot be fetched once the promisor remote is gone, trees are local
+	os.RemoveAll(src)
+	if !isGoModule("HEAD", nil) {
+		t.Errorf("isGoModule needs the contents of go.mod")
+	}
+	_, err := readBlobs([]string{"HEAD:a.go"})
+	if err == nil || !strings.Contains(err.Error(), "partial clone") {
+		t.Errorf("readBlobs without the promisor remote = %v, want a partial clone error", err)
+	}
+}
+
+func TestReadIndexFile(t *testing.T) {
+	dir := newBlobTestRepo(t)
+
+	if _, tracked, err := readIndexFile(filepath.Join(dir, "publish.json")); tracked || err != nil {
+		t.Errorf("readIndexFile of a checked out file = %v, %v, want not from the index", tracked, err)
+	}
+
+	// Leave publish.json out of the work tree the way a sparse checkout does
+	gitInTestRepo(t, dir, "update-index", "--skip-worktree", "publish.json")
+	os.Remove(filepath.Join(dir, "publish.json"))
+
+	if skipped := outsideSparseCheckout([]string{"a.go", "publish.json"}); len(skipped) != 1 || skipped[0] != "publish.json" {
+		t.Errorf("outsideSparseCheckout = %v, want [publish.json]", skipped)
+	}
+	data, tracked, err := readIndexFile(filepath.Join(dir, "publish.json"))
+	if err != nil || !tracked || string(data) != "{}\n" {
+		t.Errorf("readIndexFile = %q, %v, %v", data, tracked, err)
+	}
+	if _, tracked, _ := readIndexFile(filepath.Join(dir, "other.json")); tracked {
+		t.Errorf("readIndexFile of an untracked file reported it as tracked")
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// perf records the timings shown by --profile-perf; it is nil, and its
+// methods do nothing, when profiling is off
+var perf *perfRecorder
+
+// perfRecorder collects the wall time of every phase and GitClient call and
+// the commands run, and prints them to out
+type perfRecorder struct {
+	mu    sync.Mutex
+	out   io.Writer
+	start time.Time
+	// phase is the running phase, entered at phaseStart
+	phase      string
+	phaseStart time.Time
+	phaseCalls int
+	phaseTime  time.Duration
+	calls      map[string]*perfStat
+	commands   []*exec.Cmd
+}
+
+// perfStat sums up the calls of one GitClient method
+type perfStat struct {
+	Count int
+	Total time.Duration
+	Max   time.Duration
+}
+
+// extractPerfFlag removes --profile-perf from args, wherever it appears, and
+// reports whether profiling is on (also with GIT_PUBLISH_PROFILE_PERF=true)
+func extractPerfFlag(args []string) ([]string, bool) {
+	enabled := os.Getenv("GIT_PUBLISH_PROFILE_PERF") == "true"
+	var rest []string
+	for _, arg := range args {
+		switch strings.TrimLeft(arg, "-") {
+		case "profile-perf", "profile-perf=true":
+			if strings.HasPrefix(arg, "-") {
+				enabled = true
+				continue
+			}
+		case "profile-perf=false":
+			if strings.HasPrefix(arg, "-") {
+				enabled = false
+				continue
+			}
+		}
+		rest = append(rest, arg)
+	}
+	return rest, enabled
+}
+
+// startProfiling times git calls and phases from now on, reporting to out
+func startProfiling(out io.Writer) {
+	now := time.Now()
+	perf = &perfRecorder{out: out, start: now, phase: currentPhase, phaseStart: now, calls: map[string]*perfStat{}}
+	gitClient = timedGitClient{gitClient}
+	execCommand = profiledCommand
+}
+
+// time starts timing a call of a GitClient method; call the result when it returns
+func (p *perfRecorder) time(method string) func() {
+	if p == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		stat := p.calls[method]
+		if stat == nil {
+			stat = &perfStat{}
+			p.calls[method] = stat
+		}
+		stat.Count++
+		stat.Total += elapsed
+		if elapsed > stat.Max {
+			stat.Max = elapsed
+		}
+		p.phaseCalls++
+		p.phaseTime += elapsed
+	}
+}
+
+// enterPhase prints the time spent in the previous phase as soon as the next
+// one starts, so the timings are there even when the run exits with an error
+func (p *perfRecorder) enterPhase(phase string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.endPhase(time.Now())
+	p.phase = phase
+}
+
+// endPhase prints the running phase; p.mu is held
+func (p *perfRecorder) endPhase(now time.Time) {
+	fmt.Fprintf(p.out, "[perf] %-22s %9s  %d git calls (%s)\n", p.phase, formatPerfDuration(now.Sub(p.phaseStart)), p.phaseCalls, formatPerfDuration(p.phaseTime))
+	p.phaseStart, p.phaseCalls, p.phaseTime = now, 0, 0
+}
+
+// command records a command whose CPU time is reported once it has run
+func (p *perfRecorder) command(cmd *exec.Cmd) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.commands = append(p.commands, cmd)
+}
+
+// report prints the last phase, the total and the GitClient calls and
+// commands that took the most time
+func (p *perfRecorder) report() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.endPhase(now)
+	fmt.Fprintf(p.out, "[perf] total %s\n", formatPerfDuration(now.Sub(p.start)))
+
+	methods := make([]string, 0, len(p.calls))
+	for method := range p.calls {
+		methods = append(methods, method)
+	}
+	sort.Slice(methods, func(i, j int) bool { return p.calls[methods[i]].Total > p.calls[methods[j]].Total })
+	for _, method := range methods {
+		stat := p.calls[method]
+		fmt.Fprintf(p.out, "[perf] git %-20s %5d calls %9s total %9s max\n", method, stat.Count, formatPerfDuration(stat.Total), formatPerfDuration(stat.Max))
+	}
+
+	// Commands only report CPU time: exec.Cmd does not say when they finished
+	type commandStat struct {
+		Count int
+		CPU   time.Duration
+	}
+	commands := map[string]*commandStat{}
+	var labels []string
+	for _, cmd := range p.commands {
+		if cmd.ProcessState == nil {
+			continue
+		}
+		label := commandLabel(cmd.Args)
+		if commands[label] == nil {
+			commands[label] = &commandStat{}
+			labels = append(labels, label)
+		}
+		commands[label].Count++
+		commands[label].CPU += cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+	}
+	sort.Slice(labels, func(i, j int) bool { return commands[labels[i]].CPU > commands[labels[j]].CPU })
+	for _, label := range labels {
+		fmt.Fprintf(p.out, "[perf] run %-20s %5d times %9s CPU\n", label, commands[label].Count, formatPerfDuration(commands[label].CPU))
+	}
+}
+
+// commandLabel names a command by its program and subcommand, e.g. "git log"
+func commandLabel(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	label := args[0]
+	for i := 1; i < len(args); i++ {
+		switch {
+		case args[i] == "-C" || args[i] == "-c":
+			i++
+		case !strings.HasPrefix(args[i], "-"):
+			return label + " " + args[i]
+		}
+	}
+	return label
+}
+
+// formatPerfDuration rounds a duration for the report
+func formatPerfDuration(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// profiledCommand is loggedCommand recording the command for the report
+func profiledCommand(name string, args ...string) *exec.Cmd {
+	cmd := loggedCommand(name, args...)
+	perf.command(cmd)
+	return cmd
+}
+
+// registerProfiler serves the pprof endpoints under /debug/pprof/ when
+// profiling is on and a token is set. They need the token, as a header or as
+// the token query parameter for go tool pprof.
+func registerProfiler(mux *http.ServeMux, token string) {
+	if perf == nil || token == "" {
+		return
+	}
+	guard := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			given := r.Header.Get("X-Git-Publish-Token")
+			if given == "" {
+				given = r.URL.Query().Get("token")
+			}
+			if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				http.Error(w, "invalid or missing token", http.StatusForbidden)
+				return
+			}
+			next(w, r)
+		}
+	}
+	mux.HandleFunc("/debug/pprof/", guard(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", guard(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", guard(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", guard(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", guard(pprof.Trace))
+}
+
+// timedGitClient times every call of the GitClient it wraps
+type timedGitClient struct {
+	GitClient
+}
+
+func (c timedGitClient) IsRepository() bool {
+	defer perf.time("IsRepository")()
+	return c.GitClient.IsRepository()
+}
+
+func (c timedGitClient) TopLevel() (string, error) {
+	defer perf.time("TopLevel")()
+	return c.GitClient.TopLevel()
+}
+
+func (c timedGitClient) Refs(patterns ...string) ([]string, error) {
+	defer perf.time("Refs")()
+	return c.GitClient.Refs(patterns...)
+}
+
+func (c timedGitClient) Remotes() (map[string]string, error) {
+	defer perf.time("Remotes")()
+	return c.GitClient.Remotes()
+}
+
+func (c timedGitClient) Fetch(args ...string) error {
+	defer perf.time("Fetch")()
+	return c.GitClient.Fetch(args...)
+}
+
+func (c timedGitClient) UpdateRemote(remote string) error {
+	defer perf.time("UpdateRemote")()
+	return c.GitClient.UpdateRemote(remote)
+}
+
+func (c timedGitClient) ListTags(pattern string) ([]string, error) {
+	defer perf.time("ListTags")()
+	return c.GitClient.ListTags(pattern)
+}
+
+func (c timedGitClient) RevParse(rev string) (string, error) {
+	defer perf.time("RevParse")()
+	return c.GitClient.RevParse(rev)
+}
+
+func (c timedGitClient) IsAncestor(ancestor, commit string) (bool, error) {
+	defer perf.time("IsAncestor")()
+	return c.GitClient.IsAncestor(ancestor, commit)
+}
+
+func (c timedGitClient) CreateTag(tag, commit string) error {
+	defer perf.time("CreateTag")()
+	return c.GitClient.CreateTag(tag, commit)
+}
+
+func (c timedGitClient) CreateAnnotatedTag(tag, commit, message string, date time.Time) error {
+	defer perf.time("CreateAnnotatedTag")()
+	return c.GitClient.CreateAnnotatedTag(tag, commit, message, date)
+}
+
+func (c timedGitClient) TagMessage(tag string) (string, error) {
+	defer perf.time("TagMessage")()
+	return c.GitClient.TagMessage(tag)
+}
+
+func (c timedGitClient) CreateTagObject(tag, commit, message string, date time.Time) (string, error) {
+	defer perf.time("CreateTagObject")()
+	return c.GitClient.CreateTagObject(tag, commit, message, date)
+}
+
+func (c timedGitClient) MoveTag(tag, object, oldObject string) error {
+	defer perf.time("MoveTag")()
+	return c.GitClient.MoveTag(tag, object, oldObject)
+}
+
+func (c timedGitClient) DeleteTag(tag string) error {
+	defer perf.time("DeleteTag")()
+	return c.GitClient.DeleteTag(tag)
+}
+
+func (c timedGitClient) CreateRef(ref, commit string) error {
+	defer perf.time("CreateRef")()
+	return c.GitClient.CreateRef(ref, commit)
+}
+
+func (c timedGitClient) CreateBranch(branch, commit string) error {
+	defer perf.time("CreateBranch")()
+	return c.GitClient.CreateBranch(branch, commit)
+}
+
+func (c timedGitClient) Commits(from, to string) ([]string, error) {
+	defer perf.time("Commits")()
+	return c.GitClient.Commits(from, to)
+}
+
+func (c timedGitClient) CommitMessages(from, to string) (map[string]string, error) {
+	defer perf.time("CommitMessages")()
+	return c.GitClient.CommitMessages(from, to)
+}
+
+func (c timedGitClient) CommitStats(from, to string) (commitStats, error) {
+	defer perf.time("CommitStats")()
+	return c.GitClient.CommitStats(from, to)
+}
+
+func (c timedGitClient) CommitSignatures(from, to string) ([]commitSignature, error) {
+	defer perf.time("CommitSignatures")()
+	return c.GitClient.CommitSignatures(from, to)
+}
+
+func (c timedGitClient) CommitDate(commit string) (time.Time, error) {
+	defer perf.time("CommitDate")()
+	return c.GitClient.CommitDate(commit)
+}
+
+func (c timedGitClient) Push(args []string, sshKey string) error {
+	defer perf.time("Push")()
+	return c.GitClient.Push(args, sshKey)
+}
+
+func (c timedGitClient) ConfigValue(key string) (string, error) {
+	defer perf.time("ConfigValue")()
+	return c.GitClient.ConfigValue(key)
+}
+
+func (c timedGitClient) DefaultBranch(remote string) (string, error) {
+	defer perf.time("DefaultBranch")()
+	return c.GitClient.DefaultBranch(remote)
+}
+
+func (c timedGitClient) RemoteTag(remote, tag string) (string, string, error) {
+	defer perf.time("RemoteTag")()
+	return c.GitClient.RemoteTag(remote, tag)
+}
+
+func (c timedGitClient) RemoteTags(remote string) ([]string, error) {
+	defer perf.time("RemoteTags")()
+	return c.GitClient.RemoteTags(remote)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractPerfFlag(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    []string
+		enabled bool
+	}{
+		{[]string{"plan", "--profile-perf", "--branch", "main"}, []string{"plan", "--branch", "main"}, true},
+		{[]string{"-profile-perf=true"}, nil, true},
+		{[]string{"--profile-perf=false", "ui"}, []string{"ui"}, false},
+		{[]string{"--tag", "profile-perf"}, []string{"--tag", "profile-perf"}, false},
+	}
+	for _, tt := range tests {
+		got, enabled := extractPerfFlag(tt.args)
+		if !reflect.DeepEqual(got, tt.want) || enabled != tt.enabled {
+			t.Errorf("extractPerfFlag(%q) = %q, %v, want %q, %v", tt.args, got, enabled, tt.want, tt.enabled)
+		}
+	}
+}
+
+// useProfiling turns profiling on for the rest of the test and returns its output
+func useProfiling(t *testing.T) *bytes.Buffer {
+	originalGit, originalExec, originalPhase := gitClient, execCommand, currentPhase
+	t.Cleanup(func() {
+		perf, gitClient, execCommand, currentPhase = nil, originalGit, originalExec, originalPhase
+	})
+	var out bytes.Buffer
+	startProfiling(&out)
+	return &out
+}
+
+func TestProfiling(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.tags["v1.0.0"] = "a"
+	useFakeGit(t, g)
+	currentPhase = "startup"
+	out := useProfiling(t)
+
+	enterPhase("select branch")
+	getLastTag("main", "v0.0.0", false)
+	enterPhase("enter tag")
+	perf.report()
+
+	output := out.String()
+	for _, want := range []string{"[perf] startup", "[perf] select branch", "[perf] enter tag", "[perf] total", "[perf] git ListTags", "[perf] git IsAncestor"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("report = %q, want it to contain %q", output, want)
+		}
+	}
+}
+
+func TestPerfRecorderOff(t *testing.T) {
+	var p *perfRecorder
+	p.time("ListTags")()
+	p.enterPhase("select branch")
+	p.report()
+}
+
+func TestCommandLabel(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"git", "log", "--format=%h"}, "git log"},
+		{[]string{"git", "-C", "/repo", "commit", "-m", "x"}, "git commit"},
+		{[]string{"git", "--version"}, "git"},
+	}
+	for _, tt := range tests {
+		if got := commandLabel(tt.args); got != tt.want {
+			t.Errorf("commandLabel(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestFormatPerfDuration(t *testing.T) {
+	if got := formatPerfDuration(1234567 * time.Nanosecond); got != "1ms" {
+		t.Errorf("formatPerfDuration(1.234567ms) = %q", got)
+	}
+	if got := formatPerfDuration(1500 * time.Nanosecond); got != "2µs" {
+		t.Errorf("formatPerfDuration(1.5µs) = %q", got)
+	}
+}
+
+func TestRegisterProfiler(t *testing.T) {
+	useProfiling(t)
+	mux := http.NewServeMux()
+	registerProfiler(mux, "secret")
+
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{"/debug/pprof/", http.StatusForbidden},
+		{"/debug/pprof/?token=wrong", http.StatusForbidden},
+		{"/debug/pprof/?token=secret", http.StatusOK},
+		{"/debug/pprof/cmdline?token=secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if rec.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.url, rec.Code, tt.want)
+		}
+	}
+
+	// An empty token would let anyone in, so nothing is served
+	mux = http.NewServeMux()
+	registerProfiler(mux, "")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /debug/pprof/ without a token = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
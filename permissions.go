@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provider API actions whose permissions are checked before tagging
+const (
+	actionRelease     = "release"
+	actionProtectTags = "protect-tags"
+	actionPullRequest = "pull-request"
+)
+
+// checkTokenPermissions reports what the token of p lacks for action; a
+// permission check the service rejects is only logged, since the action
+// itself will tell
+func checkTokenPermissions(p provider, action string) error {
+	if !p.HasToken() {
+		return fmt.Errorf("no %s API token found (see API tokens in the readme)", p.Name())
+	}
+	missing, err := p.MissingPermissions(action)
+	if err != nil {
+		logFor(logAPI).Debug("checking token permissions failed", "provider", p.Name(), "error", err)
+		return nil
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the %s API token lacks %s, which the %s needs", p.Name(), strings.Join(missing, " and "), actionNoun(action))
+	}
+	return nil
+}
+
+// actionNoun names action in messages
+func actionNoun(action string) string {
+	switch action {
+	case actionProtectTags:
+		return "tag protection"
+	case actionPullRequest:
+		return "pull request"
+	}
+	return "release"
+}
+
+// preflightPlan checks the API tokens before anything is tagged, so a
+// release or GitOps pull request cannot fail after the tag was pushed
+func preflightPlan(plan Plan, config Config) error {
+	if plan.Release {
+		p, err := newProvider(plan.RemoteURL, config.Provider)
+		if err != nil {
+			return err
+		}
+		if err := checkTokenPermissions(p, actionRelease); err != nil {
+			return err
+		}
+	}
+	if plan.GitOps != "" {
+		p, err := newProvider(config.GitOps.Repo, config.GitOps.Provider)
+		if err != nil {
+			return err
+		}
+		if err := checkTokenPermissions(p, actionPullRequest); err != nil {
+			return fmt.Errorf("GitOps repository %s: %v", config.GitOps.Repo, err)
+		}
+	}
+	return nil
+}
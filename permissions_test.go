@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// TestMissingPermissions tests the token permission checks of the providers
+func TestMissingPermissions(t *testing.T) {
+	info := remoteInfo{"example.com", "owner", "repo"}
+	testCases := []struct {
+		name     string
+		provider string
+		scopes   string // X-OAuth-Scopes on GitHub, scopes JSON on GitLab
+		repo     string
+		action   string
+		expected []string
+	}{
+		{"github fine-grained with push", "github", "", `{"permissions":{"push":true}}`, actionRelease, nil},
+		{"github fine-grained read only", "github", "", `{"permissions":{"pull":true}}`, actionRelease, []string{"contents:write"}},
+		{"github classic repo scope", "github", "repo, workflow", `{"private":true,"permissions":{"push":true}}`, actionRelease, nil},
+		{"github classic public_repo on private", "github", "public_repo", `{"private":true,"permissions":{"push":true}}`, actionRelease, []string{"the repo scope"}},
+		{"github classic public_repo on public", "github", "public_repo", `{"permissions":{"push":true}}`, actionRelease, nil},
+		{"github classic without scopes", "github", "-", `{"permissions":{"push":false}}`, actionRelease, []string{"the repo scope", "contents:write"}},
+		{"github protect without admin", "github", "", `{"permissions":{"push":true}}`, actionProtectTags, []string{"administration:write (the admin role)"}},
+		{"github pull request", "github", "", `{"permissions":{}}`, actionPullRequest, []string{"contents:write", "pull_requests:write"}},
+		{"gitlab developer", "gitlab", `["api"]`, `{"permissions":{"project_access":{"access_level":30}}}`, actionRelease, nil},
+		{"gitlab reporter", "gitlab", `["api"]`, `{"permissions":{"project_access":{"access_level":20}}}`, actionRelease, []string{"the Developer role"}},
+		{"gitlab group maintainer", "gitlab", "", `{"permissions":{"project_access":{"access_level":30},"group_access":{"access_level":40}}}`, actionProtectTags, nil},
+		{"gitlab read_api scope", "gitlab", `["read_api"]`, `{"permissions":{"project_access":{"access_level":40}}}`, actionRelease, []string{"the api scope"}},
+		{"gitea push", "gitea", "", `{"permissions":{"push":true}}`, actionRelease, nil},
+		{"gitea protect without admin", "gitea", "", `{"permissions":{"push":true}}`, actionProtectTags, []string{"repository admin access"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/repos/owner/repo", "/projects/owner/repo":
+					// "-" is a classic token without scopes
+					if tc.provider == "github" && tc.scopes == "-" {
+						w.Header()["X-Oauth-Scopes"] = []string{""}
+					} else if tc.provider == "github" && tc.scopes != "" {
+						w.Header().Set("X-OAuth-Scopes", tc.scopes)
+					}
+					fmt.Fprint(w, tc.repo)
+				case "/personal_access_tokens/self":
+					if tc.scopes == "" {
+						http.Error(w, `{"message":"404 Not Found"}`, http.StatusNotFound)
+						return
+					}
+					fmt.Fprintf(w, `{"scopes":%s}`, tc.scopes)
+				default:
+					http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			var p provider
+			switch tc.provider {
+			case "github":
+				p = newGitHubProvider(server.URL, info, "token")
+			case "gitlab":
+				p = newGitLabProvider(server.URL, info, "token")
+			default:
+				p = newGiteaProvider(server.URL, info, "token")
+			}
+			missing, err := p.MissingPermissions(tc.action)
+			if err != nil {
+				t.Fatalf("MissingPermissions(%q) failed: %v", tc.action, err)
+			}
+			if !reflect.DeepEqual(missing, tc.expected) {
+				t.Errorf("MissingPermissions(%q) = %q, expected %q", tc.action, missing, tc.expected)
+			}
+		})
+	}
+}
+
+// TestCheckTokenPermissions tests the preflight error messages
+func TestCheckTokenPermissions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo" {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"permissions":{"pull":true}}`)
+	}))
+	defer server.Close()
+	info := remoteInfo{"example.com", "owner", "repo"}
+
+	err := checkTokenPermissions(newGitHubProvider(server.URL, info, "token"), actionRelease)
+	expected := "the GitHub API token lacks contents:write, which the release needs"
+	if err == nil || err.Error() != expected {
+		t.Errorf("checkTokenPermissions() = %v, expected %q", err, expected)
+	}
+
+	if err := checkTokenPermissions(newGitHubProvider(server.URL, info, ""), actionRelease); err == nil {
+		t.Error("checkTokenPermissions() without a token succeeded")
+	}
+
+	// A rejected check leaves the verdict to the action itself
+	other := remoteInfo{"example.com", "owner", "other"}
+	if err := checkTokenPermissions(newGitHubProvider(server.URL, other, "token"), actionRelease); err != nil {
+		t.Errorf("checkTokenPermissions() with a failing check = %v, expected nil", err)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// planVersion is the format version of plan files
+const planVersion = 1
+
+// Plan describes every action of a publish run so it can be reviewed before it is executed
+type Plan struct {
+	Version      int             `json:"version"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	Branch       string          `json:"branch"`
+	TargetCommit string          `json:"targetCommit"`
+	TagFormat    string          `json:"tagFormat"`
+	LastTag      string          `json:"lastTag"`
+	Tag          string          `json:"tag"`
+	Remote       string          `json:"remote,omitempty"`
+	RemoteURL    string          `json:"remoteUrl,omitempty"`
+	PushArgs     []string        `json:"pushArgs,omitempty"`
+	Mirrors      []PlannedMirror `json:"mirrors,omitempty"`
+	Hooks        []PlannedHook   `json:"hooks,omitempty"`
+	Release      bool            `json:"release,omitempty"`
+	// TagDate backdates an annotated tag (RFC 3339)
+	TagDate string `json:"tagDate,omitempty"`
+	// TagMessage makes the tag an annotated tag; without it and TagDate the tag is lightweight
+	TagMessage string `json:"tagMessage,omitempty"`
+	// OnlyMarkedTags means LastTag was looked up among tags created by the tool only
+	OnlyMarkedTags bool `json:"onlyMarkedTags,omitempty"`
+	// DeployedBase means LastTag is the deployed version rather than the last tag
+	DeployedBase bool `json:"deployedBase,omitempty"`
+	// Verify is the command that has to pass before the tag is created, unless
+	// SkipVerify; Verified records that it ran and passed
+	Verify     string `json:"verify,omitempty"`
+	SkipVerify bool   `json:"skipVerify,omitempty"`
+	Verified   bool   `json:"verified,omitempty"`
+	// Ticket is the change ticket of the release, recorded in the tag message
+	Ticket string `json:"ticket,omitempty"`
+	// Trailers are the "Key: value" trailers of the tag message besides the ticket
+	Trailers []string `json:"trailers,omitempty"`
+	// TagURL is the web page of the pushed tag on the remote's hosting service
+	TagURL string `json:"tagUrl,omitempty"`
+	// TagExists means an earlier run created the tag at TargetCommit; it is not created again
+	TagExists bool `json:"tagExists,omitempty"`
+	// Announce are the recipients of the release announcement sent after publishing
+	Announce []string `json:"announce,omitempty"`
+	// GitOps is the repository a pull request deploying the tag is opened against
+	GitOps string `json:"gitops,omitempty"`
+	// ReleaseRef is the namespace ref created and pushed along with the tag
+	ReleaseRef string `json:"releaseRef,omitempty"`
+	// RefOnly keeps the tag local: only ReleaseRef is pushed
+	RefOnly bool `json:"refOnly,omitempty"`
+	// Signing signs the tag, which is annotated then
+	Signing *SigningConfig `json:"signing,omitempty"`
+	// Environment is where the branch releases to, e.g. production
+	Environment string `json:"environment,omitempty"`
+	// Critical means the tag had to be typed to confirm the release
+	Critical bool `json:"critical,omitempty"`
+	// VersionFiles do not hold the version of Tag yet; publishing writes them
+	// and commits them on Branch, and tags that commit instead of TargetCommit
+	VersionFiles []VersionFileConfig `json:"versionFiles,omitempty"`
+}
+
+// runPlanCommand implements the plan command: it computes the publish plan
+// without executing it and prints it as text or JSON
+func runPlanCommand(args []string) {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	opts := addPublishFlags(fs)
+	output := fs.String("output", "text", "output format: text or json")
+	outFile := fs.String("out", "", "write the plan to this file instead of stdout")
+	fs.Parse(args)
+
+	if *output != "text" && *output != "json" {
+		fmt.Printf("Error: Unknown output format '%s'\n", *output)
+		os.Exit(1)
+	}
+
+	// Keep stdout clean for the JSON document: progress and prompts go to stderr
+	stdout := os.Stdout
+	if *output == "json" && *outFile == "" {
+		os.Stdout = os.Stderr
+	}
+
+	config, remoteURLs := preparePublish(opts)
+	plan, ok := buildPlan(config, remoteURLs, opts)
+	os.Stdout = stdout
+	if !ok {
+		fmt.Println("Planning cancelled.")
+		return
+	}
+
+	var data []byte
+	if *output == "json" {
+		var err error
+		data, err = json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			fmt.Printf("Error encoding plan: %v\n", err)
+			os.Exit(1)
+		}
+		data = append(data, '\n')
+	} else {
+		data = []byte(formatPlan(plan))
+	}
+
+	if *outFile == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*outFile, data, 0644); err != nil {
+		fmt.Printf("Error writing plan to %s: %v\n", *outFile, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Plan written to %s\n", *outFile)
+}
+
+// newPlan returns an empty plan stamped with the current format version and time
+func newPlan() Plan {
+	return Plan{Version: planVersion, CreatedAt: time.Now().UTC()}
+}
+
+// formatPlan renders the plan as human readable text
+func formatPlan(plan Plan) string {
+	var b strings.Builder
+
+	fmt.Fprintln(&b, "Plan:")
+	fmt.Fprintf(&b, "  Branch:        %s\n", plan.Branch)
+	if plan.Environment != "" {
+		fmt.Fprintf(&b, "  Environment:   %s\n", plan.Environment)
+	}
+	if plan.Critical {
+		fmt.Fprintf(&b, "  Critical:      yes, the tag has to be typed to confirm\n")
+	}
+	fmt.Fprintf(&b, "  Target commit: %s\n", plan.TargetCommit)
+	if plan.LastTag == "" {
+		fmt.Fprintf(&b, "  Last tag:      (none)\n")
+	} else {
+		fmt.Fprintf(&b, "  Last tag:      %s\n", plan.LastTag)
+	}
+	if plan.TagExists {
+		fmt.Fprintf(&b, "  Create tag:    %s (already exists)\n", plan.Tag)
+	} else {
+		fmt.Fprintf(&b, "  Create tag:    %s\n", plan.Tag)
+	}
+	if plan.ReleaseRef != "" {
+		fmt.Fprintf(&b, "  Create ref:    %s\n", plan.ReleaseRef)
+	}
+	if len(plan.VersionFiles) > 0 {
+		fmt.Fprintf(&b, "  Bump version:  %s, committed on %s before tagging\n", versionFilePaths(plan.VersionFiles), plan.Branch)
+	}
+	if plan.TagDate != "" {
+		fmt.Fprintf(&b, "  Tag date:      %s (annotated, backdated)\n", plan.TagDate)
+	}
+	if plan.Ticket != "" {
+		fmt.Fprintf(&b, "  Ticket:        %s\n", plan.Ticket)
+	}
+	if plan.TagMessage != "" {
+		fmt.Fprintf(&b, "  Tag message:   %s\n", strings.ReplaceAll(plan.TagMessage, "\n\n", " / "))
+	}
+	if plan.Signing != nil {
+		fmt.Fprintf(&b, "  Signed:        %s\n", describeSigning(*plan.Signing))
+	}
+	if plan.Remote == "" {
+		fmt.Fprintf(&b, "  Push:          no\n")
+	} else {
+		fmt.Fprintf(&b, "  Push:          %s (%s)\n", plan.Remote, plan.RemoteURL)
+		if plan.RefOnly {
+			fmt.Fprintf(&b, "  Tag pushed:    no, only %s\n", plan.ReleaseRef)
+		}
+		fmt.Fprintf(&b, "  Push command:  git %s\n", strings.Join(plan.PushArgs, " "))
+		if plan.TagURL != "" {
+			fmt.Fprintf(&b, "  Tag URL:       %s\n", plan.TagURL)
+		}
+	}
+	for _, mirror := range plan.Mirrors {
+		fmt.Fprintf(&b, "  Mirror:        git %s\n", strings.Join(mirror.PushArgs, " "))
+	}
+	if plan.Release {
+		fmt.Fprintf(&b, "  Release:       yes\n")
+	}
+	if len(plan.Announce) > 0 {
+		fmt.Fprintf(&b, "  Announce to:   %s\n", strings.Join(plan.Announce, ", "))
+	}
+	if plan.GitOps != "" {
+		fmt.Fprintf(&b, "  GitOps PR:     %s\n", plan.GitOps)
+	}
+	switch {
+	case plan.SkipVerify:
+		fmt.Fprintf(&b, "  Verify:        %s (skipped)\n", plan.Verify)
+	case plan.Verify != "":
+		fmt.Fprintf(&b, "  Verify:        %s\n", plan.Verify)
+	}
+	for _, hook := range plan.Hooks {
+		fmt.Fprintf(&b, "  Hook (%s): %s\n", hook.Stage, hook.Command)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPlannedHooks tests that push hooks are only planned when the tag is pushed
+func TestPlannedHooks(t *testing.T) {
+	hooks := HooksConfig{
+		PreTag:   []string{"make check"},
+		PostTag:  []string{"echo tagged"},
+		PostPush: []string{"./notify.sh"},
+	}
+
+	if planned := plannedHooks(hooks, true); len(planned) != 3 || planned[2].Stage != hookPostPush {
+		t.Errorf("plannedHooks(push) = %+v, expected 3 hooks ending with postPush", planned)
+	}
+	if planned := plannedHooks(hooks, false); len(planned) != 2 {
+		t.Errorf("plannedHooks(no push) = %+v, expected 2 hooks", planned)
+	}
+}
+
+// TestFormatPlan tests the text rendering of a plan
+func TestFormatPlan(t *testing.T) {
+	plan := Plan{
+		Branch:       "main",
+		TargetCommit: "abc123",
+		Tag:          "v1.0.1",
+		LastTag:      "v1.0.0",
+		Remote:       "origin",
+		RemoteURL:    "git@github.com:owner/repo.git",
+		PushArgs:     []string{"push", "origin", "v1.0.1"},
+	}
+
+	text := formatPlan(plan)
+	for _, expected := range []string{"Create tag:    v1.0.1", "Last tag:      v1.0.0", "git push origin v1.0.1"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("formatPlan() is missing %q:\n%s", expected, text)
+		}
+	}
+}
+
+// TestReadPlanFile tests decoding and validation of plan files
+func TestReadPlanFile(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", `{"version":1,"branch":"main","targetCommit":"abc","tag":"v1.0.0"}`, false},
+		{"future version", `{"version":2,"branch":"main","targetCommit":"abc","tag":"v1.0.0"}`, true},
+		{"incomplete", `{"version":1,"branch":"main"}`, true},
+		{"malformed", `{"version":`, true},
+		{"push", `{"version":1,"branch":"main","targetCommit":"abc","tag":"v1.0.0","remote":"origin","pushArgs":["push","--push-option=ci.skip","origin","v1.0.0"]}`, false},
+		{"remote without push", `{"version":1,"branch":"main","targetCommit":"abc","tag":"v1.0.0","remote":"origin"}`, true},
+		{"empty push", `{"version":1,"branch":"main","targetCommit":"abc","tag":"v1.0.0","remote":"origin","pushArgs":[]}`, true},
+		{"not a push", `{"version":1,"branch":"main","targetCommit":"abc","tag":"v1.0.0","remote":"origin","pushArgs":["origin","v1.0.0"]}`, true},
+		{"push elsewhere", `{"version":1,"branch":"main","targetCommit":"abc","tag":"v1.0.0","remote":"origin","pushArgs":["push","evil","v1.0.0"]}`, true},
+		{"push without refspec", `{"version":1,"branch":"main","targetCommit":"abc","tag":"v1.0.0","remote":"origin","pushArgs":["push","origin"]}`, true},
+		{"bad mirror push", `{"version":1,"branch":"main","targetCommit":"abc","tag":"v1.0.0","mirrors":[{"remote":"backup","pushArgs":["push"]}]}`, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name+".json")
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("Failed to write plan file: %v", err)
+			}
+			_, err := readPlanFile(path)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("readPlanFile() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// repoPrefs are the choices of the last release in a repository, offered as
+// the defaults of the next one
+type repoPrefs struct {
+	Branch string `json:"branch,omitempty"`
+	// Bump is the level of the last tag picked among the candidates: patch, minor or major
+	Bump   string `json:"bump,omitempty"`
+	Remote string `json:"remote,omitempty"`
+}
+
+// prefsPath returns the preferences file inside the git directory
+func prefsPath() (string, error) {
+	return gitClient.GitPath("git-publish/prefs.json")
+}
+
+// readPrefs reads the preferences of the repository; missing or unreadable
+// preferences are empty
+func readPrefs() repoPrefs {
+	var prefs repoPrefs
+	path, err := prefsPath()
+	if err != nil {
+		return prefs
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &prefs); err != nil {
+			logFor(logConfig).Debug("ignoring unreadable preferences", "path", path, "error", err)
+		}
+	}
+	return prefs
+}
+
+// rememberChoices stores the branch, bump level and remote of plan as the
+// defaults of the next run. A tag typed by hand keeps the previous bump level.
+func rememberChoices(plan Plan) {
+	prefs := readPrefs()
+	prefs.Branch = plan.Branch
+	if plan.Remote != "" {
+		prefs.Remote = plan.Remote
+	}
+	for _, c := range tagCandidates(plan.LastTag, plan.TagFormat) {
+		if c.Tag == plan.Tag {
+			prefs.Bump = c.Level
+		}
+	}
+
+	path, err := prefsPath()
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		data, _ := json.MarshalIndent(prefs, "", "  ")
+		err = os.WriteFile(path, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		logFor(logConfig).Debug("could not remember the choices", "error", err)
+	}
+}
+
+// preferredTag turns the plain patch suggestion into the candidate of the
+// bump level used last time; other suggestions, e.g. of a release train or of
+// the commit messages, are kept
+func preferredTag(nextTag, lastTag, tagFormat, bump string) string {
+	candidates := tagCandidates(lastTag, tagFormat)
+	if bump == "" || len(candidates) == 0 || nextTag != candidates[0].Tag {
+		return nextTag
+	}
+	for _, c := range candidates {
+		if c.Level == bump {
+			return c.Tag
+		}
+	}
+	return nextTag
+}
+
+// preferRemote moves the remote named name to the front, keeping the order of the others
+func preferRemote(remotes []classifiedRemote, name string) []classifiedRemote {
+	ordered := make([]classifiedRemote, 0, len(remotes))
+	for _, remote := range remotes {
+		if remote.Name == name {
+			ordered = append(ordered, remote)
+		}
+	}
+	for _, remote := range remotes {
+		if remote.Name != name {
+			ordered = append(ordered, remote)
+		}
+	}
+	return ordered
+}
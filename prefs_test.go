@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRememberChoices(t *testing.T) {
+	g := newFakeGitClient("c1")
+	g.gitDir = t.TempDir()
+	useFakeGit(t, g)
+	if prefs := readPrefs(); prefs != (repoPrefs{}) {
+		t.Fatalf("readPrefs() in a new repository = %+v, want none", prefs)
+	}
+
+	rememberChoices(Plan{Branch: "main", TagFormat: "v0.0.0", LastTag: "v1.4.2", Tag: "v1.5.0", Remote: "origin"})
+	want := repoPrefs{Branch: "main", Bump: bumpMinor, Remote: "origin"}
+	if prefs := readPrefs(); prefs != want {
+		t.Errorf("readPrefs() = %+v, want %+v", prefs, want)
+	}
+
+	// A hand-typed tag and an unpushed tag keep the earlier bump and remote
+	rememberChoices(Plan{Branch: "gray", TagFormat: "g0.0.0", LastTag: "g1.0.0", Tag: "g1.0.7"})
+	want = repoPrefs{Branch: "gray", Bump: bumpMinor, Remote: "origin"}
+	if prefs := readPrefs(); prefs != want {
+		t.Errorf("readPrefs() = %+v, want %+v", prefs, want)
+	}
+}
+
+func TestPreferredTag(t *testing.T) {
+	tests := []struct {
+		nextTag string
+		bump    string
+		want    string
+	}{
+		{"v1.4.3", "", "v1.4.3"},
+		{"v1.4.3", bumpPatch, "v1.4.3"},
+		{"v1.4.3", bumpMinor, "v1.5.0"},
+		{"v1.4.3", bumpMajor, "v2.0.0"},
+		// A suggestion other than the next patch, e.g. from the commits, wins
+		{"v1.5.0", bumpMajor, "v1.5.0"},
+	}
+	for _, tt := range tests {
+		if got := preferredTag(tt.nextTag, "v1.4.2", "v0.0.0", tt.bump); got != tt.want {
+			t.Errorf("preferredTag(%s, %q) = %s, want %s", tt.nextTag, tt.bump, got, tt.want)
+		}
+	}
+	if got := preferredTag("v0.0.0", "", "v0.0.0", bumpMinor); got != "v0.0.0" {
+		t.Errorf("preferredTag() of a first tag = %s, want v0.0.0", got)
+	}
+}
+
+func TestPreferRemote(t *testing.T) {
+	remotes := []classifiedRemote{{Name: "origin", Primary: true}, {Name: "backup"}, {Name: "upstream"}}
+	var names []string
+	for _, remote := range preferRemote(remotes, "upstream") {
+		names = append(names, remote.Name)
+	}
+	if want := []string{"upstream", "origin", "backup"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("preferRemote() = %q, want %q", names, want)
+	}
+	if got := preferRemote(remotes, "gone"); !reflect.DeepEqual(got, remotes) {
+		t.Errorf("preferRemote() with an unknown remote = %+v, want the original order", got)
+	}
+}
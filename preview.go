@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+)
+
+// commitPreviewPageSize is how many commits the preview shows at a time
+const commitPreviewPageSize = 20
+
+// previewCommits lists the commits a tag on branch would ship since lastTag.
+// With paged it offers the commits a page at a time, otherwise it shows the
+// first page only.
+func previewCommits(lastTag, branch string, paged bool) {
+	commits, err := gitClient.Commits(lastTag, branchRef(branch))
+	if err != nil {
+		fmt.Printf("Warning: could not list the commits to be released: %v\n", err)
+		return
+	}
+
+	switch {
+	case len(commits) == 0 && lastTag != "":
+		fmt.Printf("No new commits on %s since %s\n", branch, lastTag)
+		return
+	case len(commits) == 0:
+		return
+	case lastTag == "":
+		fmt.Printf("%d commit(s) on %s, all included in the first tag:\n", len(commits), branch)
+	default:
+		fmt.Printf("%d commit(s) on %s since %s:\n", len(commits), branch, lastTag)
+	}
+
+	for start := 0; start < len(commits); start += commitPreviewPageSize {
+		end := min(start+commitPreviewPageSize, len(commits))
+		for _, commit := range commits[start:end] {
+			fmt.Printf("  %s\n", commit)
+		}
+
+		rest := len(commits) - end
+		if rest == 0 {
+			return
+		}
+		if !paged || !prompter.Confirm(fmt.Sprintf("Show %d more of %d remaining commits?", min(rest, commitPreviewPageSize), rest), false) {
+			fmt.Printf("  ... and %d more\n", rest)
+			return
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPreviewCommits(t *testing.T) {
+	commits := make([]string, 45)
+	for i := range commits {
+		commits[i] = fmt.Sprintf("c%02d", i)
+	}
+	g := newFakeGitClient(commits...)
+	g.subjects = map[string]string{"c44": "Fix login", "c05": "Add login"}
+	g.tags = map[string]string{"v1.0.0": "c04", "v1.1.0": "c44"}
+	useFakeGit(t, g)
+
+	tests := []struct {
+		name    string
+		lastTag string
+		paged   bool
+		answers []string
+		want    []string
+		notWant []string
+	}{
+		{"first page only", "v1.0.0", false, nil,
+			[]string{"40 commit(s) on main since v1.0.0:", "  c44 Fix login", "  c25\n", "  ... and 20 more"}, []string{"c24", "Add login"}},
+		{"all pages", "v1.0.0", true, []string{"y"},
+			[]string{"  c25\n", "  c24\n", "  c05 Add login\n"}, []string{"... and"}},
+		{"stop paging", "v1.0.0", true, []string{"n"},
+			[]string{"  c25\n", "  ... and 20 more"}, []string{"c24"}},
+		{"nothing new", "v1.1.0", true, nil,
+			[]string{"No new commits on main since v1.1.0"}, nil},
+		{"first tag", "", false, nil,
+			[]string{"45 commit(s) on main, all included in the first tag:", "  ... and 25 more"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := useScriptedPrompter(t, tt.answers...)
+			output := captureOutput(func() { previewCommits(tt.lastTag, "main", tt.paged) })
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("output does not contain %q:\n%s", want, output)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(output, notWant) {
+					t.Errorf("output contains %q:\n%s", notWant, output)
+				}
+			}
+			if len(p.asked) != len(tt.answers) {
+				t.Errorf("asked %d questions, want %d: %q", len(p.asked), len(tt.answers), p.asked)
+			}
+		})
+	}
+}
+
+// captureOutput returns what f prints to stdout
+func captureOutput(f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		panic(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	return <-done
+}
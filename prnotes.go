@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NotesSection is a heading of pullRequests release notes and the pull
+// request labels listed under it
+type NotesSection struct {
+	Title  string   `json:"title"`
+	Labels []string `json:"labels"`
+}
+
+// defaultNotesSections group pull requests by the labels GitHub, GitLab and
+// Gitea suggest for new repositories
+var defaultNotesSections = []NotesSection{
+	{Title: "Breaking Changes", Labels: []string{"breaking"}},
+	{Title: "Features", Labels: []string{"enhancement"}},
+	{Title: "Bug Fixes", Labels: []string{"bug"}},
+}
+
+// otherChangesTitle heads the pull requests that no section has a label of
+const otherChangesTitle = "Other Changes"
+
+// pullRequestReferences find the pull request a commit message comes from:
+// GitHub and Gitea merge and squash subjects ("Merge pull request #12 from",
+// "Add export (#12)") and the trailer of GitLab merge commits ("See merge
+// request group/project!12")
+var pullRequestReferences = []*regexp.Regexp{
+	regexp.MustCompile(`\AMerge pull request #(\d+) `),
+	regexp.MustCompile(`\A[^\n]*\(#(\d+)\)`),
+	regexp.MustCompile(`(?m)^See merge request \S*!(\d+)$`),
+}
+
+// pullRequestNumbers returns the pull requests merged between from and to,
+// newest first, found in the messages of the commits (merges included) that
+// do not opt out of releases
+func pullRequestNumbers(from, to string) ([]int, error) {
+	messages, err := gitClient.LogMessages(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var numbers []int
+	seen := map[int]bool{}
+	for _, message := range messages {
+		if skipsRelease(message) {
+			continue
+		}
+		for _, reference := range pullRequestReferences {
+			m := reference.FindStringSubmatch(message)
+			if m == nil {
+				continue
+			}
+			number, _ := strconv.Atoi(m[1])
+			if !seen[number] {
+				seen[number] = true
+				numbers = append(numbers, number)
+			}
+			break
+		}
+	}
+	return numbers, nil
+}
+
+// pullRequestNotes lists the pull requests merged between lastTag and tag,
+// fetched from p, under the first of sections that has one of their labels.
+// Commits that were not merged through a pull request are left out, as in the
+// notes GitHub generates.
+func pullRequestNotes(p provider, sections []NotesSection, lastTag, tag string) (string, error) {
+	numbers, err := pullRequestNumbers(lastTag, tag)
+	if err != nil {
+		return "", err
+	}
+	var prs []mergedPullRequest
+	for _, number := range numbers {
+		pr, err := p.MergedPullRequest(number)
+		if isNotFound(err) {
+			// "(#12)" referred to an issue
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("pull request #%d: %v", number, err)
+		}
+		prs = append(prs, pr)
+	}
+	if len(prs) == 0 {
+		return "", errors.New("no commit refers to a pull request")
+	}
+	if len(sections) == 0 {
+		sections = defaultNotesSections
+	}
+	return groupPullRequests(prs, sections), nil
+}
+
+// groupPullRequests renders prs under a heading per section, in the order of
+// sections, and the rest under Other Changes. Labels match case-insensitively.
+func groupPullRequests(prs []mergedPullRequest, sections []NotesSection) string {
+	lines := make([][]string, len(sections)+1)
+	for _, pr := range prs {
+		i := sectionOf(pr, sections)
+		lines[i] = append(lines[i], pullRequestLine(pr))
+	}
+	var notes []string
+	for i := range lines {
+		title := otherChangesTitle
+		if i < len(sections) {
+			title = sections[i].Title
+		}
+		if len(lines[i]) > 0 {
+			notes = append(notes, "### "+title+"\n\n"+strings.Join(lines[i], "\n"))
+		}
+	}
+	return strings.Join(notes, "\n\n")
+}
+
+// sectionOf returns the index of the first section with a label of pr,
+// len(sections) when there is none
+func sectionOf(pr mergedPullRequest, sections []NotesSection) int {
+	for i, section := range sections {
+		for _, want := range section.Labels {
+			for _, label := range pr.Labels {
+				if strings.EqualFold(label, want) {
+					return i
+				}
+			}
+		}
+	}
+	return len(sections)
+}
+
+// pullRequestLine renders pr as GitHub lists it in generated notes
+func pullRequestLine(pr mergedPullRequest) string {
+	line := "- " + pr.Title
+	if pr.Author != "" {
+		line += " by @" + pr.Author
+	}
+	if pr.URL != "" {
+		return line + " in " + pr.URL
+	}
+	return fmt.Sprintf("%s in #%d", line, pr.Number)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPullRequestNumbers(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	gitInTestRepo(t, dir, "tag", "v1.0.0")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "Add export (#12)")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "chore: bump dependencies")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "Update CI (#30)", "-m", "[skip release]")
+	gitInTestRepo(t, dir, "checkout", "-q", "-b", "crash")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "Handle empty input")
+	gitInTestRepo(t, dir, "checkout", "-q", "main")
+	gitInTestRepo(t, dir, "merge", "-q", "--no-ff", "-m", "Merge pull request #15 from octocat/crash", "crash")
+	gitInTestRepo(t, dir, "checkout", "-q", "-b", "docs")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "Document export")
+	gitInTestRepo(t, dir, "checkout", "-q", "main")
+	gitInTestRepo(t, dir, "merge", "-q", "--no-ff", "-m", "Merge branch 'docs' into 'main'", "-m", "See merge request acme/tool!21", "docs")
+	// A squash that says its pull request again
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "Fix export of empty files (#12)")
+
+	numbers, err := pullRequestNumbers("v1.0.0", "HEAD")
+	if err != nil {
+		t.Fatalf("pullRequestNumbers() error = %v", err)
+	}
+	sort.Ints(numbers)
+	if want := []int{12, 15, 21}; !reflect.DeepEqual(numbers, want) {
+		t.Errorf("pullRequestNumbers() = %v, want %v", numbers, want)
+	}
+}
+
+func TestPullRequestNumbersWithFakeGit(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c", "d")
+	g.tags["v1.0.0"] = "a"
+	g.subjects = map[string]string{"a": "Add import (#9)", "b": "Add export (#12)", "c": "Merge pull request #15 from octocat/crash", "d": "Update CI (#30)"}
+	g.bodies["d"] = "[skip release]"
+	useFakeGit(t, g)
+
+	numbers, err := pullRequestNumbers("v1.0.0", "main")
+	if want := []int{15, 12}; err != nil || !reflect.DeepEqual(numbers, want) {
+		t.Errorf("pullRequestNumbers() = %v, %v, want %v newest first", numbers, err, want)
+	}
+}
+
+func TestGroupPullRequests(t *testing.T) {
+	prs := []mergedPullRequest{
+		{Number: 15, Title: "Handle empty input", Author: "octocat", URL: "https://github.com/acme/tool/pull/15", Labels: []string{"Bug"}},
+		{Number: 14, Title: "Drop v1 API", Author: "hubot", URL: "https://github.com/acme/tool/pull/14", Labels: []string{"enhancement", "breaking"}},
+		{Number: 13, Title: "Update README", Labels: []string{"documentation"}},
+		{Number: 12, Title: "Add export", Author: "octocat", URL: "https://github.com/acme/tool/pull/12", Labels: []string{"enhancement"}},
+	}
+
+	tests := []struct {
+		name     string
+		sections []NotesSection
+		want     string
+	}{
+		{"default sections", defaultNotesSections, "### Breaking Changes\n\n- Drop v1 API by @hubot in https://github.com/acme/tool/pull/14\n\n" +
+			"### Features\n\n- Add export by @octocat in https://github.com/acme/tool/pull/12\n\n" +
+			"### Bug Fixes\n\n- Handle empty input by @octocat in https://github.com/acme/tool/pull/15\n\n" +
+			"### Other Changes\n\n- Update README in #13"},
+		{"configured sections", []NotesSection{
+			{Title: "New", Labels: []string{"enhancement", "feature"}},
+			{Title: "Documentation", Labels: []string{"documentation"}},
+		}, "### New\n\n- Drop v1 API by @hubot in https://github.com/acme/tool/pull/14\n- Add export by @octocat in https://github.com/acme/tool/pull/12\n\n" +
+			"### Documentation\n\n- Update README in #13\n\n" +
+			"### Other Changes\n\n- Handle empty input by @octocat in https://github.com/acme/tool/pull/15"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := groupPullRequests(prs, tt.sections); got != tt.want {
+				t.Errorf("groupPullRequests() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPullRequestNotes tests the notes of pull requests fetched from GitHub and GitLab
+func TestPullRequestNotes(t *testing.T) {
+	responses := map[string]string{
+		"/repos/owner/repo/pulls/12":               `{"title":"Add export","html_url":"https://github.com/owner/repo/pull/12","user":{"login":"octocat"},"labels":[{"name":"enhancement"}]}`,
+		"/repos/owner/repo/pulls/15":               `{"title":"Handle empty input","html_url":"https://github.com/owner/repo/pull/15","user":{"login":"hubot"},"labels":[{"name":"bug"}]}`,
+		"/projects/owner%2Frepo/merge_requests/12": `{"title":"Add export","web_url":"https://gitlab.com/owner/repo/-/merge_requests/12","author":{"username":"octocat"},"labels":["enhancement"]}`,
+		"/projects/owner%2Frepo/merge_requests/15": "",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.EscapedPath()]
+		switch {
+		case !ok:
+			http.NotFound(w, r)
+			return
+		case response == "":
+			http.Error(w, `{"message":"500 Internal Server Error"}`, http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, response)
+	}))
+	defer server.Close()
+	github := newGitHubProvider(server.URL, remoteInfo{"github.com", "owner", "repo"}, "token")
+	gitlab := newGitLabProvider(server.URL, remoteInfo{"gitlab.com", "owner", "repo"}, "token")
+
+	dir := newBlobTestRepo(t)
+	gitInTestRepo(t, dir, "tag", "v1.0.0")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "Add export (#12)")
+	// #7 is an issue, not a pull request
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "Fix typo (#7)")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "Handle empty input (#15)")
+	gitInTestRepo(t, dir, "tag", "v1.1.0")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "chore: bump dependencies")
+	gitInTestRepo(t, dir, "tag", "v1.2.0")
+
+	tests := []struct {
+		name    string
+		p       provider
+		lastTag string
+		tag     string
+		want    string
+		warning string
+	}{
+		{"github", github, "v1.0.0", "v1.1.0", "### Features\n\n- Add export by @octocat in https://github.com/owner/repo/pull/12\n\n" +
+			"### Bug Fixes\n\n- Handle empty input by @hubot in https://github.com/owner/repo/pull/15", ""},
+		{"no pull requests", github, "v1.1.0", "v1.2.0", "- chore: bump dependencies", "no commit refers to a pull request"},
+		{"failed request", gitlab, "v1.0.0", "v1.1.0", "- Handle empty input (#15)\n- Fix typo (#7)\n- Add export (#12)", "pull request #15: "},
+		{"not supported", &bitbucketProvider{}, "v1.0.0", "v1.1.0", "- Handle empty input (#15)\n- Fix typo (#7)\n- Add export (#12)", errNotSupported.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			output := captureOutput(func() {
+				got = providerReleaseNotes(tt.p, ReleaseConfig{Notes: notesPullRequests}, tt.lastTag, tt.tag)
+			})
+			if got != tt.want {
+				t.Errorf("providerReleaseNotes() = %q, want %q", got, tt.want)
+			}
+			if tt.warning == "" && output != "" || !strings.Contains(output, tt.warning) {
+				t.Errorf("providerReleaseNotes() printed %q, want %q", output, tt.warning)
+			}
+		})
+	}
+
+	// GitLab returns the labels as names
+	pr, err := gitlab.MergedPullRequest(12)
+	want := mergedPullRequest{Number: 12, Title: "Add export", Author: "octocat", URL: "https://gitlab.com/owner/repo/-/merge_requests/12", Labels: []string{"enhancement"}}
+	if err != nil || !reflect.DeepEqual(pr, want) {
+		t.Errorf("MergedPullRequest(12) = %+v, %v, want %+v", pr, err, want)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// probeRef is a throwaway ref used to check push permission without creating anything
+const probeRef = "refs/git-publish/permission-probe"
+
+// probeTimeout limits how long a single permission probe may take
+var probeTimeout = 20 * time.Second
+
+// pushSlowAfter is how long a push may take before connection diagnostics are shown
+var pushSlowAfter = 30 * time.Second
+
+// probePushPermission checks with a dry-run push whether the remote accepts pushes from us
+func probePushPermission(remote string, push PushConfig) error {
+	return gitClient.PushDryRun(remote, "HEAD:"+probeRef, push.SSHKey, probeTimeout)
+}
+
+// lastLine returns the last non-empty line of s
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// checkPushPermissions probes every remote and returns only those that accept pushes.
+// When no remote is pushable the user may continue in create-local-only mode,
+// which is signalled by an empty result; declining exits the program.
+func checkPushPermissions(remoteURLs map[string]string, push PushConfig) map[string]string {
+	yellow := color.New(color.FgYellow).SprintFunc()
+
+	fmt.Println("Checking push permissions...")
+
+	names := make([]string, 0, len(remoteURLs))
+	for name := range remoteURLs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pushable := make(map[string]string)
+	for _, name := range names {
+		if remoteKind(remoteURLs[name]) == remoteKindBundle {
+			fmt.Printf("Skipping remote %s: it is a bundle file, which cannot receive pushes\n", name)
+			continue
+		}
+		if err := probePushPermission(name, push); err != nil {
+			fmt.Printf("%s Cannot push to remote %s: %v\n", yellow("Warning:"), name, err)
+			if isNetworkError(err) {
+				if diagnostics := connectionDiagnostics(name, remoteURLs[name]); diagnostics != "" {
+					fmt.Println(diagnostics)
+				}
+			}
+			continue
+		}
+		pushable[name] = remoteURLs[name]
+	}
+
+	if len(pushable) > 0 {
+		return pushable
+	}
+
+	if !prompter.Confirm("You cannot push to any remote. Continue in create-local-only mode?", true) {
+		fmt.Println("Aborted.")
+		os.Exit(1)
+	}
+
+	return pushable
+}
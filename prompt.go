@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PromptsConfig tunes the questions of the interactive flow
+type PromptsConfig struct {
+	// AlwaysSelectBranch asks for the branch even when only one configured branch exists
+	AlwaysSelectBranch bool `json:"alwaysSelectBranch,omitempty"`
+}
+
+// choice is an option of a Select prompt
+type choice struct {
+	// Name identifies the option, e.g. a branch name; it is repeated in the default hint
+	Name string
+	// Detail is shown after the name, e.g. "(Last tag: v1.0.0)"
+	Detail string
+}
+
+// Prompter asks the user the questions of the interactive flow. Keeping all
+// input behind it lets tests and other frontends drive the same flow.
+type Prompter interface {
+	// Select asks to pick one of choices and returns its index. The first
+	// choice is the default; noun names the kind of choice in messages.
+	Select(title, noun string, choices []choice) int
+	// Confirm asks a yes/no question; an empty answer returns def
+	Confirm(question string, def bool) bool
+	// Input asks for a value until validate accepts it; an empty answer means
+	// def. The error is returned when no more input is available.
+	Input(question, def string, validate func(string) error) (string, error)
+
+	// SelectStep, ConfirmStep and InputStep ask the questions of the steps of
+	// the flow like Select, Confirm and Input. An answer of b (or Esc) or q is
+	// returned as the navigation it asks for instead of being taken as the
+	// answer; otherwise the navigation is navigateNone.
+	SelectStep(title, noun string, choices []choice) (int, navigation)
+	ConfirmStep(question string, def bool) (bool, navigation)
+	InputStep(question, def string, validate func(string) error) (string, navigation, error)
+}
+
+// prompter is the Prompter used by the interactive flow
+var prompter Prompter = newTerminalPrompter(os.Stdin)
+
+// terminalPrompter prompts on stdout and reads the answers line by line from a reader
+type terminalPrompter struct {
+	// reader is shared by all prompts so that input buffered while answering
+	// one prompt (e.g. answers piped in by a script) is not lost to the next one
+	reader *bufio.Reader
+}
+
+// newTerminalPrompter returns a Prompter reading answers from r
+func newTerminalPrompter(r io.Reader) *terminalPrompter {
+	return &terminalPrompter{reader: bufio.NewReader(r)}
+}
+
+// readLine reads the next answer; io.EOF is only returned when nothing was typed
+func (p *terminalPrompter) readLine() (string, error) {
+	line, err := p.reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimSpace(line), err
+}
+
+func (p *terminalPrompter) Select(title, noun string, choices []choice) int {
+	index, _ := p.selectChoice(title, noun, choices, false)
+	return index
+}
+
+func (p *terminalPrompter) SelectStep(title, noun string, choices []choice) (int, navigation) {
+	return p.selectChoice(title, noun, choices, true)
+}
+
+// selectChoice asks a Select question; step reads navigation in the answer
+func (p *terminalPrompter) selectChoice(title, noun string, choices []choice, step bool) (int, navigation) {
+	fmt.Println(title)
+	for i, c := range choices {
+		if c.Detail == "" {
+			fmt.Printf("%d: %s\n", i+1, c.Name)
+		} else {
+			fmt.Printf("%d: %s %s\n", i+1, c.Name, c.Detail)
+		}
+	}
+	fmt.Printf("Enter number (default: 1 for %s): ", choices[0].Name)
+
+	input, _ := p.readLine()
+	if nav := parseNavigation(input); step && nav != navigateNone {
+		return 0, nav
+	}
+	index, ok := parseSelection(input, len(choices))
+	if !ok {
+		fmt.Printf("Invalid selection, using default %s: %s\n", noun, choices[0].Name)
+	}
+	return index, navigateNone
+}
+
+func (p *terminalPrompter) Confirm(question string, def bool) bool {
+	yes, _ := p.confirm(question, def, false)
+	return yes
+}
+
+func (p *terminalPrompter) ConfirmStep(question string, def bool) (bool, navigation) {
+	return p.confirm(question, def, true)
+}
+
+// confirm asks a Confirm question; step reads navigation in the answer
+func (p *terminalPrompter) confirm(question string, def bool, step bool) (bool, navigation) {
+	if def {
+		fmt.Printf("%s (Y/n): ", question)
+	} else {
+		fmt.Printf("%s (y/N): ", question)
+	}
+	input, _ := p.readLine()
+	if nav := parseNavigation(input); step && nav != navigateNone {
+		return false, nav
+	}
+	return parseConfirmation(input, def), navigateNone
+}
+
+func (p *terminalPrompter) Input(question, def string, validate func(string) error) (string, error) {
+	answer, _, err := p.input(question, def, validate, false)
+	return answer, err
+}
+
+func (p *terminalPrompter) InputStep(question, def string, validate func(string) error) (string, navigation, error) {
+	return p.input(question, def, validate, true)
+}
+
+// input asks an Input question; step reads navigation in the answers
+func (p *terminalPrompter) input(question, def string, validate func(string) error, step bool) (string, navigation, error) {
+	fmt.Println(question)
+	for {
+		fmt.Print("> ")
+		input, err := p.readLine()
+		if err != nil {
+			fmt.Println()
+			return "", navigateNone, fmt.Errorf("no answer to %q: %v", question, err)
+		}
+		if nav := parseNavigation(input); step && nav != navigateNone {
+			return "", nav, nil
+		}
+		if input == "" {
+			input = def
+		}
+		if err := validate(input); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		return input, navigateNone, nil
+	}
+}
+
+// scriptedPrompter answers prompts from a prepared list of answers, as if
+// they had been typed. It records the questions it was asked.
+type scriptedPrompter struct {
+	answers []string
+	asked   []string
+	// offered are the choices of the last Select
+	offered []choice
+}
+
+// newScriptedPrompter returns a Prompter replaying answers in order
+func newScriptedPrompter(answers ...string) *scriptedPrompter {
+	return &scriptedPrompter{answers: answers}
+}
+
+// next returns the next answer, or false when the script is exhausted
+func (p *scriptedPrompter) next(question string) (string, bool) {
+	p.asked = append(p.asked, question)
+	if len(p.answers) == 0 {
+		return "", false
+	}
+	answer := p.answers[0]
+	p.answers = p.answers[1:]
+	return strings.TrimSpace(answer), true
+}
+
+func (p *scriptedPrompter) Select(title, noun string, choices []choice) int {
+	index, _ := p.selectChoice(title, choices, false)
+	return index
+}
+
+func (p *scriptedPrompter) SelectStep(title, noun string, choices []choice) (int, navigation) {
+	return p.selectChoice(title, choices, true)
+}
+
+func (p *scriptedPrompter) selectChoice(title string, choices []choice, step bool) (int, navigation) {
+	p.offered = choices
+	input, _ := p.next(title)
+	if nav := parseNavigation(input); step && nav != navigateNone {
+		return 0, nav
+	}
+	index, _ := parseSelection(input, len(choices))
+	return index, navigateNone
+}
+
+func (p *scriptedPrompter) Confirm(question string, def bool) bool {
+	yes, _ := p.confirm(question, def, false)
+	return yes
+}
+
+func (p *scriptedPrompter) ConfirmStep(question string, def bool) (bool, navigation) {
+	return p.confirm(question, def, true)
+}
+
+func (p *scriptedPrompter) confirm(question string, def bool, step bool) (bool, navigation) {
+	input, _ := p.next(question)
+	if nav := parseNavigation(input); step && nav != navigateNone {
+		return false, nav
+	}
+	return parseConfirmation(input, def), navigateNone
+}
+
+func (p *scriptedPrompter) Input(question, def string, validate func(string) error) (string, error) {
+	answer, _, err := p.input(question, def, validate, false)
+	return answer, err
+}
+
+func (p *scriptedPrompter) InputStep(question, def string, validate func(string) error) (string, navigation, error) {
+	return p.input(question, def, validate, true)
+}
+
+func (p *scriptedPrompter) input(question, def string, validate func(string) error, step bool) (string, navigation, error) {
+	for {
+		input, ok := p.next(question)
+		if !ok {
+			return "", navigateNone, fmt.Errorf("no answer to %q", question)
+		}
+		if nav := parseNavigation(input); step && nav != navigateNone {
+			return "", nav, nil
+		}
+		if input == "" {
+			input = def
+		}
+		if validate(input) == nil {
+			return input, navigateNone, nil
+		}
+	}
+}
+
+// parseSelection turns a 1-based answer into an index of n choices. Empty and
+// invalid answers select the first choice; ok is false for invalid ones.
+func parseSelection(input string, n int) (index int, ok bool) {
+	if input == "" {
+		return 0, true
+	}
+	if i, err := strconv.Atoi(input); err == nil && i > 0 && i <= n {
+		return i - 1, true
+	}
+	return 0, false
+}
+
+// parseConfirmation interprets a yes/no answer; anything but yes is no, unless empty
+func parseConfirmation(input string, def bool) bool {
+	switch strings.ToLower(input) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useScriptedPrompter makes the interactive flow answer its prompts from answers
+func useScriptedPrompter(t *testing.T, answers ...string) *scriptedPrompter {
+	original := prompter
+	p := newScriptedPrompter(answers...)
+	prompter = p
+	t.Cleanup(func() { prompter = original })
+	return p
+}
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantIndex int
+		wantOK    bool
+	}{
+		{"", 0, true},
+		{"1", 0, true},
+		{"3", 2, true},
+		{"4", 0, false},
+		{"0", 0, false},
+		{"main", 0, false},
+	}
+
+	for _, tt := range tests {
+		index, ok := parseSelection(tt.input, 3)
+		if index != tt.wantIndex || ok != tt.wantOK {
+			t.Errorf("parseSelection(%q, 3) = %d, %v, want %d, %v", tt.input, index, ok, tt.wantIndex, tt.wantOK)
+		}
+	}
+}
+
+func TestParseConfirmation(t *testing.T) {
+	tests := []struct {
+		input string
+		def   bool
+		want  bool
+	}{
+		{"", true, true},
+		{"", false, false},
+		{"y", false, true},
+		{"YES", false, true},
+		{"n", true, false},
+		{"maybe", true, false},
+	}
+
+	for _, tt := range tests {
+		if got := parseConfirmation(tt.input, tt.def); got != tt.want {
+			t.Errorf("parseConfirmation(%q, %v) = %v, want %v", tt.input, tt.def, got, tt.want)
+		}
+	}
+}
+
+func TestTerminalPrompter(t *testing.T) {
+	// Answers for several prompts arrive at once, as when piped in
+	p := newTerminalPrompter(strings.NewReader("2\nn\nbad\nv1.0.0\n"))
+	choices := []choice{{Name: "main"}, {Name: "develop"}}
+
+	if got := p.Select("Select branch:", "branch", choices); got != 1 {
+		t.Errorf("Select() = %d, want 1", got)
+	}
+	if p.Confirm("Push?", true) {
+		t.Error("Confirm() = true, want false")
+	}
+
+	validate := func(input string) error {
+		if !strings.HasPrefix(input, "v") {
+			return errors.New("invalid")
+		}
+		return nil
+	}
+	if got, err := p.Input("Tag:", "v0.0.1", validate); err != nil || got != "v1.0.0" {
+		t.Errorf("Input() = %q, %v, want v1.0.0", got, err)
+	}
+
+	// Without more input the defaults apply and Input fails instead of looping
+	if got := p.Select("Select branch:", "branch", choices); got != 0 {
+		t.Errorf("Select() at EOF = %d, want 0", got)
+	}
+	if !p.Confirm("Push?", true) {
+		t.Error("Confirm() at EOF = false, want true")
+	}
+	if _, err := p.Input("Tag:", "v0.0.1", validate); err == nil {
+		t.Error("Input() at EOF succeeded, want an error")
+	}
+}
+
+func TestTerminalPrompterSteps(t *testing.T) {
+	// Step questions return b and q as navigation, ordinary ones take them as answers
+	p := newTerminalPrompter(strings.NewReader("b\nq\nQuit\nq\n"))
+	choices := []choice{{Name: "main"}, {Name: "develop"}}
+	accept := func(string) error { return nil }
+
+	var got, gotStep string
+	var nav, inputNav navigation
+	captureOutput(func() {
+		_, nav = p.SelectStep("Select branch:", "branch", choices)
+		got, _ = p.Input("Ticket:", "", accept)
+		gotStep, inputNav, _ = p.InputStep("Tag:", "", accept)
+	})
+	if nav != navigateBack {
+		t.Errorf("SelectStep() navigation = %v, want back", nav)
+	}
+	if got != "q" {
+		t.Errorf("Input() = %q, want q", got)
+	}
+	if gotStep != "" || inputNav != navigateCancel {
+		t.Errorf("InputStep() = %q, %v, want cancel", gotStep, inputNav)
+	}
+	if yes, nav := p.ConfirmStep("Push?", true); yes || nav != navigateCancel {
+		t.Errorf("ConfirmStep() = %v, %v, want cancel", yes, nav)
+	}
+}
+
+func TestTerminalPrompterLastLineWithoutNewline(t *testing.T) {
+	p := newTerminalPrompter(strings.NewReader("v2.0.0"))
+	got, err := p.Input("Tag:", "v0.0.1", func(string) error { return nil })
+	if err != nil || got != "v2.0.0" {
+		t.Errorf("Input() = %q, %v, want v2.0.0", got, err)
+	}
+}
+
+func TestScriptedPrompter(t *testing.T) {
+	p := newScriptedPrompter("", "y", "x", "")
+	choices := []choice{{Name: "origin"}, {Name: "upstream"}}
+
+	if got := p.Select("Select remote:", "remote", choices); got != 0 {
+		t.Errorf("Select() = %d, want 0", got)
+	}
+	if !p.Confirm("Push?", false) {
+		t.Error("Confirm() = false, want true")
+	}
+	validate := func(input string) error {
+		if input == "x" {
+			return errors.New("invalid")
+		}
+		return nil
+	}
+	if got, err := p.Input("Tag:", "v1.0.1", validate); err != nil || got != "v1.0.1" {
+		t.Errorf("Input() = %q, %v, want the default v1.0.1", got, err)
+	}
+	if _, err := p.Input("Tag:", "v1.0.1", validate); err == nil {
+		t.Error("Input() without answers succeeded, want an error")
+	}
+
+	want := []string{"Select remote:", "Push?", "Tag:", "Tag:", "Tag:"}
+	if !reflect.DeepEqual(p.asked, want) {
+		t.Errorf("asked = %q, want %q", p.asked, want)
+	}
+}
+
+func TestSelectBranchAndTagPrompt(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c")
+	g.branches["gray"] = "a"
+	g.tags["g1.0.0"] = "a"
+	g.tags["v1.0.0"] = "a"
+	g.authors = map[string]string{"b": "ann@example.com", "c": "bob@example.com"}
+	g.commitDates["b"] = time.Now().AddDate(0, 0, -3)
+	g.commitDates["c"] = time.Now().AddDate(0, 0, -1)
+	useFakeGit(t, g)
+	p := useScriptedPrompter(t, "2")
+
+	config := Config{BranchTags: []BranchTagConfig{{Branch: "main", Tag: "v0.0.0"}, {Branch: "gray", Tag: "g0.0.0"}}}
+	branch, format, _ := selectBranchAndTag(config)
+	if branch != "gray" || format != "g0.0.0" {
+		t.Errorf("selectBranchAndTag() = %q, %q, want gray, g0.0.0", branch, format)
+	}
+	// Each branch shows the commits since its last tag
+	for i, want := range []string{"2 commits since (by 2 authors, oldest 3 days ago)", "no commits since"} {
+		if !strings.HasSuffix(p.offered[i].Detail, want) {
+			t.Errorf("detail of %s = %q, want it to end in %q", p.offered[i].Name, p.offered[i].Detail, want)
+		}
+	}
+
+	// The remote's default branch is offered first and is the default selection
+	g.defaultBranch = "gray"
+	useScriptedPrompter(t, "")
+	if branch, _, _ := selectBranchAndTag(config); branch != "gray" {
+		t.Errorf("selectBranchAndTag() = %q, want the default branch gray", branch)
+	}
+}
+
+func TestDescribeCommitStats(t *testing.T) {
+	now := time.Date(2024, 3, 13, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		stats commitStats
+		want  string
+	}{
+		{commitStats{}, "no commits since"},
+		{commitStats{Count: 1, Authors: 1, Oldest: now.Add(-10 * time.Minute)}, "1 commit since (by 1 author, oldest less than an hour ago)"},
+		{commitStats{Count: 3, Authors: 1, Oldest: now.Add(-5 * time.Hour)}, "3 commits since (by 1 author, oldest 5 hours ago)"},
+		{commitStats{Count: 5, Authors: 2, Oldest: now.AddDate(0, 0, -12)}, "5 commits since (by 2 authors, oldest 12 days ago)"},
+	}
+	for _, tt := range tests {
+		if got := describeCommitStats(tt.stats, now); got != tt.want {
+			t.Errorf("describeCommitStats(%+v) = %q, want %q", tt.stats, got, tt.want)
+		}
+	}
+}
+
+func TestPreferBranch(t *testing.T) {
+	branchTags := []BranchTagConfig{{Branch: "master", Tag: "v0.0.0"}, {Branch: "main", Tag: "v0.0.0"}, {Branch: "gray", Tag: "g0.0.0"}, {Branch: "main", Tag: "api-v0.0.0"}}
+
+	tests := []struct {
+		branch string
+		want   []string
+	}{
+		{"main", []string{"main/v0.0.0", "main/api-v0.0.0", "master/v0.0.0", "gray/g0.0.0"}},
+		{"gray", []string{"gray/g0.0.0", "master/v0.0.0", "main/v0.0.0", "main/api-v0.0.0"}},
+		{"", []string{"master/v0.0.0", "main/v0.0.0", "gray/g0.0.0", "main/api-v0.0.0"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, bt := range preferBranch(branchTags, tt.branch) {
+			got = append(got, bt.Branch+"/"+bt.Tag)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("preferBranch(%q) = %v, want %v", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestPromptForTag(t *testing.T) {
+	p := useScriptedPrompter(t, "1.2.0", "v1.0.0", "v1.2.0")
+
+	if got, _ := promptForTag("v0.0.0", "v1.1.1", "v1.1.0", nil, false); got != "v1.2.0" {
+		t.Errorf("promptForTag() = %q, want v1.2.0", got)
+	}
+	// The invalid format and the version that is not greater were both asked again
+	if len(p.asked) != 3 {
+		t.Errorf("asked %d times, want 3", len(p.asked))
+	}
+}
+
+func TestPromptForPushToRemote(t *testing.T) {
+	remotes := map[string]string{"origin": "git@example.com:a.git", "upstream": "git@example.com:b.git"}
+
+	tests := []struct {
+		name       string
+		answers    []string
+		wantPush   bool
+		wantRemote string
+	}{
+		{"default remote", []string{"", ""}, true, "origin"},
+		{"second remote", []string{"y", "2"}, true, "upstream"},
+		{"no push", []string{"n"}, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeGit(t, newFakeGitClient("a"))
+			useScriptedPrompter(t, tt.answers...)
+			push, remote, _ := promptForPushToRemote(remotes, RemotesConfig{}, false)
+			if push != tt.wantPush || remote != tt.wantRemote {
+				t.Errorf("promptForPushToRemote() = %v, %q, want %v, %q", push, remote, tt.wantPush, tt.wantRemote)
+			}
+		})
+	}
+}
+
+func TestSelectBranchAndTagSingleBranch(t *testing.T) {
+	g := newFakeGitClient("a")
+	useFakeGit(t, g)
+	config := Config{BranchTags: []BranchTagConfig{{Branch: "main", Tag: "v0.0.0"}}}
+
+	p := useScriptedPrompter(t)
+	var branch string
+	output := captureOutput(func() { branch, _, _ = selectBranchAndTag(config) })
+	if branch != "main" || len(p.asked) != 0 {
+		t.Errorf("selectBranchAndTag() = %q after %d questions, want main without asking", branch, len(p.asked))
+	}
+	if !strings.Contains(output, "the only configured branch") {
+		t.Errorf("output = %q, want the branch announced", output)
+	}
+
+	// The question can be kept
+	config.Prompts.AlwaysSelectBranch = true
+	p = useScriptedPrompter(t, "")
+	if branch, _, _ := selectBranchAndTag(config); branch != "main" || len(p.asked) != 1 {
+		t.Errorf("selectBranchAndTag() = %q after %d questions, want main after asking", branch, len(p.asked))
+	}
+}
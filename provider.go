@@ -0,0 +1,724 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// remoteInfo describes a hosted repository parsed from a remote URL
+type remoteInfo struct {
+	Host  string
+	Owner string
+	Repo  string
+}
+
+// provider is a git hosting service reachable through its REST API
+type provider interface {
+	// Name returns a human readable name of the hosting service
+	Name() string
+	// HasToken reports whether an API token is available for authenticated calls
+	HasToken() bool
+	// ProtectedTagPatterns lists the tag patterns that are already protected
+	ProtectedTagPatterns() ([]string, error)
+	// ProtectTagPattern protects tags matching pattern so only maintainers can push them
+	ProtectTagPattern(pattern string) error
+	// CreateRelease publishes a release for an already pushed tag and returns its web URL
+	CreateRelease(tag, name, notes string) (string, error)
+	// UpdateRelease replaces the notes of the release of a re-pointed tag and
+	// returns its web URL, "" when the tag has no release
+	UpdateRelease(tag, notes string) (string, error)
+	// FileContent returns the contents of a file of the repository at ref
+	FileContent(path, ref string) (string, error)
+	// OpenPullRequest commits the files of pr to a new branch off its base
+	// and opens a pull request, returning its web URL
+	OpenPullRequest(pr pullRequest) (string, error)
+	// DeleteRelease deletes the release of tag, or turns it back into a draft,
+	// and reports whether there was one
+	DeleteRelease(tag string, draft bool) (bool, error)
+	// GenerateReleaseNotes returns the notes the service writes for tag since
+	// previousTag ("" for its own choice), as its web UI does, following the
+	// repository's configuration of them
+	GenerateReleaseNotes(tag, previousTag string) (string, error)
+	// MergedPullRequest returns the title, author and labels of pull (or
+	// merge) request number
+	MergedPullRequest(number int) (mergedPullRequest, error)
+	// MissingPermissions returns what the token lacks for action, e.g.
+	// contents:write; nil when it suffices or the service cannot tell
+	MissingPermissions(action string) ([]string, error)
+}
+
+// pullRequest is a change proposed through a new branch
+type pullRequest struct {
+	Base   string
+	Branch string
+	Title  string
+	Body   string
+	// Files are the new contents by path
+	Files map[string]string
+}
+
+// mergedPullRequest is a pull request as the release notes list it
+type mergedPullRequest struct {
+	Number int
+	Title  string
+	Author string
+	URL    string
+	Labels []string
+}
+
+// sortedFiles returns the paths of the changed files in a stable order
+func (pr pullRequest) sortedFiles() []string {
+	paths := make([]string, 0, len(pr.Files))
+	for path := range pr.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// escapeFilePath escapes the segments of a repository file path for a URL
+func escapeFilePath(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
+
+// repoFile is a file as the contents APIs of GitHub, GitLab and Gitea return it
+type repoFile struct {
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+	SHA      string `json:"sha"`
+}
+
+// decode returns the contents of the file
+func (f repoFile) decode() (string, error) {
+	if f.Encoding != "base64" {
+		return f.Content, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(f.Content, "\n", ""))
+	return string(data), err
+}
+
+// parseRemoteURL extracts host, owner and repository name from a git remote URL.
+// It understands https://host/owner/repo.git, ssh://git@host:port/owner/repo.git
+// and the scp-like git@host:owner/repo.git forms. For Azure DevOps the owner is
+// "organization/project". Local paths, file:// URLs and bundles are not hosted.
+func parseRemoteURL(remoteURL string) (remoteInfo, bool) {
+	remoteURL = strings.TrimSpace(remoteURL)
+	if remoteURL == "" || remoteKind(remoteURL) != "" {
+		return remoteInfo{}, false
+	}
+
+	var host, path string
+	if strings.Contains(remoteURL, "://") {
+		u, err := url.Parse(remoteURL)
+		if err != nil || u.Host == "" {
+			return remoteInfo{}, false
+		}
+		host = u.Hostname()
+		path = u.Path
+	} else {
+		// scp-like syntax: [user@]host:owner/repo.git
+		colon := strings.Index(remoteURL, ":")
+		if colon <= 0 {
+			return remoteInfo{}, false
+		}
+		host = remoteURL[:colon]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		path = remoteURL[colon+1:]
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if isAzureHost(host) {
+		path = azureRepoPath(host, path)
+	}
+	slash := strings.LastIndex(path, "/")
+	if host == "" || slash <= 0 || slash == len(path)-1 {
+		return remoteInfo{}, false
+	}
+
+	return remoteInfo{Host: host, Owner: path[:slash], Repo: path[slash+1:]}, true
+}
+
+// Hosting provider types accepted in the provider config
+const (
+	providerGitHub    = "github"
+	providerGitLab    = "gitlab"
+	providerGitea     = "gitea"
+	providerBitbucket = "bitbucket"
+	providerAzure     = "azure"
+)
+
+// ProviderConfig overrides the hosting provider detected from the remote URL
+type ProviderConfig struct {
+	// Type is one of github, gitlab, gitea, bitbucket (Data Center) or azure (DevOps)
+	Type string `json:"type,omitempty"`
+	// APIURL is the base URL of the REST API, e.g. https://git.example.com/api/v1
+	APIURL string `json:"apiUrl,omitempty"`
+	// CLI delegates API calls to gh or glab: auto when no token is found, or always
+	CLI string `json:"cli,omitempty"`
+}
+
+// providerTokenEnv lists the environment variables holding the API token of each hosting service
+var providerTokenEnv = map[string][]string{
+	providerGitHub:    {"GITHUB_TOKEN", "GH_TOKEN"},
+	providerGitLab:    {"GITLAB_TOKEN"},
+	providerGitea:     {"GITEA_TOKEN"},
+	providerBitbucket: {"BITBUCKET_TOKEN"},
+	providerAzure:     {"AZURE_DEVOPS_TOKEN", "AZURE_DEVOPS_EXT_PAT"},
+}
+
+// detectProviderType guesses the hosting service from the parsed remote URL
+func detectProviderType(info remoteInfo) string {
+	host := strings.ToLower(info.Host)
+	switch {
+	case isAzureHost(host):
+		return providerAzure
+	case strings.Contains(host, "github"):
+		return providerGitHub
+	case strings.Contains(host, "gitlab"):
+		return providerGitLab
+	case strings.Contains(host, "gitea") || host == "codeberg.org":
+		return providerGitea
+	case host != "bitbucket.org" && (strings.Contains(host, "bitbucket") || strings.HasPrefix(info.Owner, "scm/")):
+		return providerBitbucket
+	}
+	return ""
+}
+
+// newProvider returns the hosting provider for the given remote URL, honoring
+// the type and API URL of the provider config when set
+func newProvider(remoteURL string, override ProviderConfig) (provider, error) {
+	if kind := remoteKind(remoteURL); kind != "" {
+		return nil, fmt.Errorf("%s is a %s repository without a hosting service", remoteURL, kind)
+	}
+	info, ok := parseRemoteURL(remoteURL)
+	if !ok {
+		return nil, fmt.Errorf("cannot parse remote URL %q", remoteURL)
+	}
+
+	providerType := strings.ToLower(override.Type)
+	if providerType == "" {
+		providerType = detectProviderType(info)
+	}
+	apiBase := override.APIURL
+
+	switch providerType {
+	case providerGitHub:
+		if apiBase == "" {
+			apiBase = "https://api.github.com"
+			if strings.ToLower(info.Host) != "github.com" {
+				apiBase = "https://" + info.Host + "/api/v3"
+			}
+		}
+		p := newGitHubProvider(apiBase, info, resolveToken(info, providerTokenEnv[providerGitHub]...))
+		cli, err := providerCLI(override.CLI, providerType, info, p.token)
+		if err != nil {
+			return nil, err
+		}
+		p.api.cli = cli
+		return p, nil
+	case providerGitLab:
+		if apiBase == "" {
+			apiBase = "https://" + info.Host + "/api/v4"
+		}
+		p := newGitLabProvider(apiBase, info, resolveToken(info, providerTokenEnv[providerGitLab]...))
+		cli, err := providerCLI(override.CLI, providerType, info, p.token)
+		if err != nil {
+			return nil, err
+		}
+		p.api.cli = cli
+		return p, nil
+	case providerGitea:
+		if apiBase == "" {
+			apiBase = "https://" + info.Host + "/api/v1"
+		}
+		return newGiteaProvider(apiBase, info, resolveToken(info, providerTokenEnv[providerGitea]...)), nil
+	case providerBitbucket:
+		if apiBase == "" {
+			apiBase = "https://" + info.Host + "/rest"
+		}
+		return newBitbucketProvider(apiBase, info, resolveToken(info, providerTokenEnv[providerBitbucket]...)), nil
+	case providerAzure:
+		p, err := newAzureProvider(apiBase, info, resolveToken(info, providerTokenEnv[providerAzure]...))
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	case "":
+		return nil, fmt.Errorf("unsupported hosting provider for %s (set provider.type in publish.json)", info.Host)
+	}
+
+	return nil, fmt.Errorf("unknown provider type %q", override.Type)
+}
+
+// tagPatterns converts the configured tag formats into unique glob patterns such as "v*"
+func tagPatterns(config Config) []string {
+	var patterns []string
+	for _, bt := range config.BranchTags {
+		patterns = append(patterns, tagGlob(bt.Tag))
+	}
+	return uniqueStrings(patterns)
+}
+
+// protectionPattern returns the pattern protecting the tags matching glob on
+// p. GitLab's protected tags know no other wildcard than *, so character
+// classes such as the [0-9] of prefix-less formats widen to *.
+func protectionPattern(p provider, glob string) string {
+	if _, ok := p.(*gitlabProvider); !ok {
+		return glob
+	}
+	pattern := characterClass.ReplaceAllString(glob, "*")
+	for strings.Contains(pattern, "**") {
+		pattern = strings.ReplaceAll(pattern, "**", "*")
+	}
+	return pattern
+}
+
+// characterClass matches the bracket expressions of a glob, such as [0-9]
+var characterClass = regexp.MustCompile(`\[[^]]*\]`)
+
+// githubProvider talks to the GitHub (or GitHub Enterprise) REST API
+type githubProvider struct {
+	api   *apiClient
+	info  remoteInfo
+	token string
+}
+
+// newGitHubProvider returns a GitHub provider for the API at apiBase
+func newGitHubProvider(apiBase string, info remoteInfo, token string) *githubProvider {
+	header := http.Header{}
+	header.Set("Accept", "application/vnd.github+json")
+	header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return &githubProvider{api: newAPIClient(apiBase, header), info: info, token: token}
+}
+
+func (p *githubProvider) Name() string { return "GitHub" }
+
+func (p *githubProvider) HasToken() bool { return p.token != "" || p.api.cli != nil }
+
+func (p *githubProvider) repoPath() string {
+	return fmt.Sprintf("/repos/%s/%s", p.info.Owner, p.info.Repo)
+}
+
+// githubRulesetName is the name of the tag ruleset managed for a pattern
+func githubRulesetName(pattern string) string {
+	return "git-publish tags " + pattern
+}
+
+func (p *githubProvider) ProtectedTagPatterns() ([]string, error) {
+	type ruleset struct {
+		Name   string `json:"name"`
+		Target string `json:"target"`
+	}
+	rulesets, err := getAllPages[ruleset](p.api, p.repoPath()+"/rulesets?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, rs := range rulesets {
+		if rs.Target == "tag" && strings.HasPrefix(rs.Name, githubRulesetName("")) {
+			patterns = append(patterns, strings.TrimPrefix(rs.Name, githubRulesetName("")))
+		}
+	}
+	return patterns, nil
+}
+
+func (p *githubProvider) ProtectTagPattern(pattern string) error {
+	// Repository roles 5 (admin) and 2 (maintain) may bypass the ruleset
+	ruleset := map[string]interface{}{
+		"name":        githubRulesetName(pattern),
+		"target":      "tag",
+		"enforcement": "active",
+		"conditions": map[string]interface{}{
+			"ref_name": map[string]interface{}{
+				"include": []string{"refs/tags/" + pattern},
+				"exclude": []string{},
+			},
+		},
+		"rules": []map[string]string{
+			{"type": "creation"},
+			{"type": "update"},
+			{"type": "deletion"},
+		},
+		"bypass_actors": []map[string]interface{}{
+			{"actor_id": 5, "actor_type": "RepositoryRole", "bypass_mode": "always"},
+			{"actor_id": 2, "actor_type": "RepositoryRole", "bypass_mode": "always"},
+		},
+	}
+	return p.api.do("POST", p.repoPath()+"/rulesets", ruleset, nil)
+}
+
+func (p *githubProvider) CreateRelease(tag, name, notes string) (string, error) {
+	body := map[string]interface{}{
+		"tag_name": tag,
+		"name":     name,
+		"body":     notes,
+	}
+	var release struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := p.api.do("POST", p.repoPath()+"/releases", body, &release); err != nil {
+		return "", err
+	}
+	return release.HTMLURL, nil
+}
+
+func (p *githubProvider) UpdateRelease(tag, notes string) (string, error) {
+	var release struct {
+		ID      int64  `json:"id"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := p.api.do("GET", p.repoPath()+"/releases/tags/"+url.PathEscape(tag), nil, &release); err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	body := map[string]interface{}{"body": notes}
+	if err := p.api.do("PATCH", fmt.Sprintf("%s/releases/%d", p.repoPath(), release.ID), body, nil); err != nil {
+		return "", err
+	}
+	return release.HTMLURL, nil
+}
+
+// GenerateReleaseNotes has GitHub write the notes like "Generate release
+// notes" does, grouped by the categories of .github/release.yml
+func (p *githubProvider) GenerateReleaseNotes(tag, previousTag string) (string, error) {
+	body := map[string]interface{}{"tag_name": tag}
+	if previousTag != "" {
+		body["previous_tag_name"] = previousTag
+	}
+	var notes struct {
+		Body string `json:"body"`
+	}
+	if err := p.api.do("POST", p.repoPath()+"/releases/generate-notes", body, &notes); err != nil {
+		return "", err
+	}
+	return notes.Body, nil
+}
+
+func (p *githubProvider) MergedPullRequest(number int) (mergedPullRequest, error) {
+	var pr struct {
+		Title   string `json:"title"`
+		HTMLURL string `json:"html_url"`
+		User    struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}
+	if err := p.api.do("GET", fmt.Sprintf("%s/pulls/%d", p.repoPath(), number), nil, &pr); err != nil {
+		return mergedPullRequest{}, err
+	}
+	merged := mergedPullRequest{Number: number, Title: pr.Title, Author: pr.User.Login, URL: pr.HTMLURL}
+	for _, label := range pr.Labels {
+		merged.Labels = append(merged.Labels, label.Name)
+	}
+	return merged, nil
+}
+
+func (p *githubProvider) DeleteRelease(tag string, draft bool) (bool, error) {
+	var release struct {
+		ID int64 `json:"id"`
+	}
+	if err := p.api.do("GET", p.repoPath()+"/releases/tags/"+url.PathEscape(tag), nil, &release); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	path := fmt.Sprintf("%s/releases/%d", p.repoPath(), release.ID)
+	if draft {
+		return true, p.api.do("PATCH", path, map[string]interface{}{"draft": true}, nil)
+	}
+	return true, p.api.do("DELETE", path, nil, nil)
+}
+
+func (p *githubProvider) FileContent(path, ref string) (string, error) {
+	var file repoFile
+	if err := p.api.do("GET", p.repoPath()+"/contents/"+escapeFilePath(path)+"?ref="+url.QueryEscape(ref), nil, &file); err != nil {
+		return "", err
+	}
+	return file.decode()
+}
+
+func (p *githubProvider) OpenPullRequest(pr pullRequest) (string, error) {
+	var base struct {
+		Object struct {
+			SHA string `json:"sha"`
+		} `json:"object"`
+	}
+	if err := p.api.do("GET", p.repoPath()+"/git/ref/heads/"+escapeFilePath(pr.Base), nil, &base); err != nil {
+		return "", err
+	}
+	ref := map[string]string{"ref": "refs/heads/" + pr.Branch, "sha": base.Object.SHA}
+	if err := p.api.do("POST", p.repoPath()+"/git/refs", ref, nil); err != nil {
+		return "", err
+	}
+
+	// The contents API commits one file at a time
+	for _, path := range pr.sortedFiles() {
+		var file repoFile
+		if err := p.api.do("GET", p.repoPath()+"/contents/"+escapeFilePath(path)+"?ref="+url.QueryEscape(pr.Branch), nil, &file); err != nil {
+			return "", err
+		}
+		update := map[string]string{
+			"message": pr.Title,
+			"content": base64.StdEncoding.EncodeToString([]byte(pr.Files[path])),
+			"sha":     file.SHA,
+			"branch":  pr.Branch,
+		}
+		if err := p.api.do("PUT", p.repoPath()+"/contents/"+escapeFilePath(path), update, nil); err != nil {
+			return "", err
+		}
+	}
+
+	body := map[string]string{"title": pr.Title, "head": pr.Branch, "base": pr.Base, "body": pr.Body}
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := p.api.do("POST", p.repoPath()+"/pulls", body, &created); err != nil {
+		return "", err
+	}
+	return created.HTMLURL, nil
+}
+
+func (p *githubProvider) MissingPermissions(action string) ([]string, error) {
+	var repo struct {
+		Private     bool `json:"private"`
+		Permissions struct {
+			Admin bool `json:"admin"`
+			Push  bool `json:"push"`
+		} `json:"permissions"`
+	}
+	header, err := p.api.doHeader("GET", p.repoPath(), nil, &repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	// Only classic tokens list their scopes; fine-grained tokens and apps
+	// are limited through the permissions of the repository
+	if _, classic := header["X-Oauth-Scopes"]; classic {
+		scopes := strings.Split(header.Get("X-OAuth-Scopes"), ",")
+		for i := range scopes {
+			scopes[i] = strings.TrimSpace(scopes[i])
+		}
+		if !contains(scopes, "repo") && (repo.Private || !contains(scopes, "public_repo")) {
+			missing = append(missing, "the repo scope")
+		}
+	}
+	switch {
+	case action == actionProtectTags && !repo.Permissions.Admin:
+		missing = append(missing, "administration:write (the admin role)")
+	case action == actionPullRequest && !repo.Permissions.Push:
+		missing = append(missing, "contents:write", "pull_requests:write")
+	case action == actionRelease && !repo.Permissions.Push:
+		missing = append(missing, "contents:write")
+	}
+	return missing, nil
+}
+
+// gitlabProvider talks to the GitLab REST API
+type gitlabProvider struct {
+	api   *apiClient
+	info  remoteInfo
+	token string
+}
+
+// newGitLabProvider returns a GitLab provider for the API at apiBase
+func newGitLabProvider(apiBase string, info remoteInfo, token string) *gitlabProvider {
+	header := http.Header{}
+	if token != "" {
+		// Bearer works for personal access tokens as well as OAuth tokens from credential helpers
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return &gitlabProvider{api: newAPIClient(apiBase, header), info: info, token: token}
+}
+
+func (p *gitlabProvider) Name() string { return "GitLab" }
+
+func (p *gitlabProvider) HasToken() bool { return p.token != "" || p.api.cli != nil }
+
+func (p *gitlabProvider) projectPath() string {
+	return "/projects/" + url.PathEscape(p.info.Owner+"/"+p.info.Repo)
+}
+
+func (p *gitlabProvider) ProtectedTagPatterns() ([]string, error) {
+	type protectedTag struct {
+		Name string `json:"name"`
+	}
+	protected, err := getAllPages[protectedTag](p.api, p.projectPath()+"/protected_tags?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, pt := range protected {
+		patterns = append(patterns, pt.Name)
+	}
+	return patterns, nil
+}
+
+func (p *gitlabProvider) ProtectTagPattern(pattern string) error {
+	// Access level 40 is Maintainer
+	body := map[string]interface{}{
+		"name":                pattern,
+		"create_access_level": 40,
+	}
+	return p.api.do("POST", p.projectPath()+"/protected_tags", body, nil)
+}
+
+func (p *gitlabProvider) CreateRelease(tag, name, notes string) (string, error) {
+	body := map[string]interface{}{
+		"tag_name":    tag,
+		"name":        name,
+		"description": notes,
+	}
+	var release struct {
+		Links struct {
+			Self string `json:"self"`
+		} `json:"_links"`
+	}
+	if err := p.api.do("POST", p.projectPath()+"/releases", body, &release); err != nil {
+		return "", err
+	}
+	return release.Links.Self, nil
+}
+
+func (p *gitlabProvider) UpdateRelease(tag, notes string) (string, error) {
+	body := map[string]interface{}{"description": notes}
+	var release struct {
+		Links struct {
+			Self string `json:"self"`
+		} `json:"_links"`
+	}
+	if err := p.api.do("PUT", p.projectPath()+"/releases/"+url.PathEscape(tag), body, &release); err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return release.Links.Self, nil
+}
+
+// GenerateReleaseNotes is not supported: the notes are made from the commits
+func (p *gitlabProvider) GenerateReleaseNotes(tag, previousTag string) (string, error) {
+	return "", errNotSupported
+}
+
+// MergedPullRequest returns merge request number; GitLab returns its labels as names
+func (p *gitlabProvider) MergedPullRequest(number int) (mergedPullRequest, error) {
+	var mr struct {
+		Title  string `json:"title"`
+		WebURL string `json:"web_url"`
+		Author struct {
+			Username string `json:"username"`
+		} `json:"author"`
+		Labels []string `json:"labels"`
+	}
+	if err := p.api.do("GET", fmt.Sprintf("%s/merge_requests/%d", p.projectPath(), number), nil, &mr); err != nil {
+		return mergedPullRequest{}, err
+	}
+	return mergedPullRequest{Number: number, Title: mr.Title, Author: mr.Author.Username, URL: mr.WebURL, Labels: mr.Labels}, nil
+}
+
+// DeleteRelease deletes the release of tag; GitLab has no draft releases
+func (p *gitlabProvider) DeleteRelease(tag string, draft bool) (bool, error) {
+	if draft {
+		return false, errors.New("GitLab has no draft releases; roll back without --draft")
+	}
+	if err := p.api.do("DELETE", p.projectPath()+"/releases/"+url.PathEscape(tag), nil, nil); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *gitlabProvider) FileContent(path, ref string) (string, error) {
+	var file repoFile
+	if err := p.api.do("GET", p.projectPath()+"/repository/files/"+url.PathEscape(path)+"?ref="+url.QueryEscape(ref), nil, &file); err != nil {
+		return "", err
+	}
+	return file.decode()
+}
+
+func (p *gitlabProvider) OpenPullRequest(pr pullRequest) (string, error) {
+	// One commit creates the branch with every file
+	var actions []map[string]string
+	for _, path := range pr.sortedFiles() {
+		actions = append(actions, map[string]string{"action": "update", "file_path": path, "content": pr.Files[path]})
+	}
+	commit := map[string]interface{}{
+		"branch":         pr.Branch,
+		"start_branch":   pr.Base,
+		"commit_message": pr.Title,
+		"actions":        actions,
+	}
+	if err := p.api.do("POST", p.projectPath()+"/repository/commits", commit, nil); err != nil {
+		return "", err
+	}
+
+	body := map[string]string{"source_branch": pr.Branch, "target_branch": pr.Base, "title": pr.Title, "description": pr.Body}
+	var created struct {
+		WebURL string `json:"web_url"`
+	}
+	if err := p.api.do("POST", p.projectPath()+"/merge_requests", body, &created); err != nil {
+		return "", err
+	}
+	return created.WebURL, nil
+}
+
+func (p *gitlabProvider) MissingPermissions(action string) ([]string, error) {
+	var missing []string
+	// Only personal, group and project access tokens can look themselves up
+	var self struct {
+		Scopes []string `json:"scopes"`
+	}
+	if err := p.api.do("GET", "/personal_access_tokens/self", nil, &self); err == nil && !contains(self.Scopes, "api") {
+		missing = append(missing, "the api scope")
+	}
+
+	var project struct {
+		Permissions struct {
+			ProjectAccess *struct {
+				AccessLevel int `json:"access_level"`
+			} `json:"project_access"`
+			GroupAccess *struct {
+				AccessLevel int `json:"access_level"`
+			} `json:"group_access"`
+		} `json:"permissions"`
+	}
+	if err := p.api.do("GET", p.projectPath(), nil, &project); err != nil {
+		return nil, err
+	}
+	level := 0
+	if access := project.Permissions.ProjectAccess; access != nil {
+		level = access.AccessLevel
+	}
+	if access := project.Permissions.GroupAccess; access != nil && access.AccessLevel > level {
+		level = access.AccessLevel
+	}
+	// Access level 30 is Developer, 40 Maintainer
+	switch {
+	case action == actionProtectTags && level < 40:
+		missing = append(missing, "the Maintainer role")
+	case action != actionProtectTags && level < 30:
+		missing = append(missing, "the Developer role")
+	}
+	return missing, nil
+}
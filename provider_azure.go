@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// isAzureHost reports whether host belongs to Azure DevOps (including the legacy visualstudio.com domains)
+func isAzureHost(host string) bool {
+	host = strings.ToLower(host)
+	return host == "dev.azure.com" || host == "ssh.dev.azure.com" || strings.HasSuffix(host, ".visualstudio.com")
+}
+
+// azureRepoPath normalizes the path of an Azure DevOps remote to "organization/project/repo".
+// Remotes come in several shapes:
+//
+//	https://dev.azure.com/org/project/_git/repo
+//	git@ssh.dev.azure.com:v3/org/project/repo
+//	https://org.visualstudio.com/DefaultCollection/project/_git/repo
+//	org@vs-ssh.visualstudio.com:v3/org/project/repo
+//
+// and the project is omitted when it has the same name as the repository.
+func azureRepoPath(host, path string) string {
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || segment == "_git" || strings.EqualFold(segment, "DefaultCollection") {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) > 0 && segments[0] == "v3" {
+		segments = segments[1:]
+	}
+
+	host = strings.ToLower(host)
+	if strings.HasSuffix(host, ".visualstudio.com") && !strings.HasPrefix(host, "vs-ssh.") {
+		segments = append([]string{strings.TrimSuffix(host, ".visualstudio.com")}, segments...)
+	}
+	if len(segments) == 2 {
+		segments = []string{segments[0], segments[1], segments[1]}
+	}
+	return strings.Join(segments, "/")
+}
+
+// azureProvider talks to the Azure DevOps Services REST API
+type azureProvider struct {
+	api          *apiClient
+	organization string
+	project      string
+	repo         string
+	token        string
+}
+
+// newAzureProvider returns an Azure DevOps provider; apiBase defaults to the organization URL
+func newAzureProvider(apiBase string, info remoteInfo, token string) (*azureProvider, error) {
+	slash := strings.Index(info.Owner, "/")
+	if slash <= 0 {
+		return nil, fmt.Errorf("cannot determine the Azure DevOps organization and project of %s/%s", info.Owner, info.Repo)
+	}
+	organization, project := info.Owner[:slash], info.Owner[slash+1:]
+
+	if apiBase == "" {
+		apiBase = "https://dev.azure.com/" + url.PathEscape(organization)
+	}
+	header := http.Header{}
+	if token != "" {
+		// Personal access tokens are sent as the password of basic authentication
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(":"+token)))
+	}
+
+	return &azureProvider{
+		api:          newAPIClient(apiBase, header),
+		organization: organization,
+		project:      project,
+		repo:         info.Repo,
+		token:        token,
+	}, nil
+}
+
+func (p *azureProvider) Name() string { return "Azure DevOps" }
+
+func (p *azureProvider) HasToken() bool { return p.token != "" }
+
+func (p *azureProvider) repoPath() string {
+	return fmt.Sprintf("/%s/_apis/git/repositories/%s", url.PathEscape(p.project), url.PathEscape(p.repo))
+}
+
+func (p *azureProvider) ProtectedTagPatterns() ([]string, error) {
+	return nil, errNotSupported
+}
+
+// ProtectTagPattern is not supported: Azure DevOps manages tag permissions
+// through security namespaces in the repository settings
+func (p *azureProvider) ProtectTagPattern(pattern string) error {
+	return errNotSupported
+}
+
+// CreateRelease annotates the pushed tag with the release notes. Azure Repos has
+// no release objects, so an annotated tag is the closest equivalent: a
+// lightweight tag pushed by git is replaced by an annotated one on the same commit.
+func (p *azureProvider) CreateRelease(tag, name, notes string) (string, error) {
+	var refs struct {
+		Value []struct {
+			Name           string `json:"name"`
+			ObjectID       string `json:"objectId"`
+			PeeledObjectID string `json:"peeledObjectId"`
+		} `json:"value"`
+	}
+	query := "/refs?filter=" + url.QueryEscape("tags/"+tag) + "&peelTags=true&api-version=7.1"
+	if err := p.api.do("GET", p.repoPath()+query, nil, &refs); err != nil {
+		return "", err
+	}
+
+	var objectID, peeled string
+	for _, ref := range refs.Value {
+		if ref.Name == "refs/tags/"+tag {
+			objectID, peeled = ref.ObjectID, ref.PeeledObjectID
+		}
+	}
+	if objectID == "" {
+		return "", fmt.Errorf("tag %s not found on the server", tag)
+	}
+
+	// A peeled object means the tag is already annotated
+	if peeled == "" && notes != "" {
+		if err := p.updateTagRef(tag, objectID, strings.Repeat("0", 40)); err != nil {
+			return "", err
+		}
+		annotated := map[string]interface{}{
+			"name":         tag,
+			"taggedObject": map[string]string{"objectId": objectID},
+			"message":      name + "\n\n" + notes,
+		}
+		if err := p.api.do("POST", p.repoPath()+"/annotatedtags?api-version=7.1-preview.1", annotated, nil); err != nil {
+			// Restore the lightweight tag so the pushed release is not lost
+			if restoreErr := p.updateTagRef(tag, strings.Repeat("0", 40), objectID); restoreErr != nil {
+				return "", fmt.Errorf("%v (restoring tag %s also failed: %v)", err, tag, restoreErr)
+			}
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("https://dev.azure.com/%s/%s/_git/%s?version=GT%s",
+		url.PathEscape(p.organization), url.PathEscape(p.project), url.PathEscape(p.repo), url.QueryEscape(tag)), nil
+}
+
+// updateTagRef moves a tag ref on the server; an all-zero object ID creates or deletes it
+func (p *azureProvider) updateTagRef(tag, oldObjectID, newObjectID string) error {
+	update := []map[string]string{{
+		"name":        "refs/tags/" + tag,
+		"oldObjectId": oldObjectID,
+		"newObjectId": newObjectID,
+	}}
+	return p.api.do("POST", p.repoPath()+"/refs?api-version=7.1", update, nil)
+}
+
+// UpdateRelease annotates the re-pointed tag again: the force push replaced the
+// annotated tag CreateRelease made with the pushed one
+func (p *azureProvider) UpdateRelease(tag, notes string) (string, error) {
+	return p.CreateRelease(tag, tag, notes)
+}
+
+// GenerateReleaseNotes is not supported: the notes are made from the commits
+func (p *azureProvider) GenerateReleaseNotes(tag, previousTag string) (string, error) {
+	return "", errNotSupported
+}
+
+// MergedPullRequest is not supported: the notes are made from the commits
+func (p *azureProvider) MergedPullRequest(number int) (mergedPullRequest, error) {
+	return mergedPullRequest{}, errNotSupported
+}
+
+// DeleteRelease is not supported: the annotated tag itself is the release
+func (p *azureProvider) DeleteRelease(tag string, draft bool) (bool, error) {
+	return false, errNotSupported
+}
+
+// FileContent is not supported: pull requests are only opened on GitHub, GitLab and Gitea
+func (p *azureProvider) FileContent(path, ref string) (string, error) {
+	return "", errNotSupported
+}
+
+func (p *azureProvider) OpenPullRequest(pr pullRequest) (string, error) {
+	return "", errNotSupported
+}
+
+// MissingPermissions cannot tell: Azure DevOps does not expose the scopes of
+// a personal access token
+func (p *azureProvider) MissingPermissions(action string) ([]string, error) {
+	return nil, nil
+}
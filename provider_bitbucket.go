@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// bitbucketProvider talks to the Bitbucket Data Center (Server) REST API
+type bitbucketProvider struct {
+	api     *apiClient
+	webBase string
+	project string
+	repo    string
+	token   string
+}
+
+// newBitbucketProvider returns a Bitbucket Data Center provider for the REST API at apiBase
+// (the /rest root, which serves both the core and the branch permissions API)
+func newBitbucketProvider(apiBase string, info remoteInfo, token string) *bitbucketProvider {
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	// Clone URLs look like https://host/scm/KEY/repo.git or ssh://git@host:7999/KEY/repo.git
+	project := info.Owner
+	if slash := strings.LastIndex(project, "/"); slash >= 0 {
+		project = project[slash+1:]
+	}
+
+	return &bitbucketProvider{
+		api:     newAPIClient(apiBase, header),
+		webBase: strings.TrimSuffix(strings.TrimSuffix(apiBase, "/"), "/rest"),
+		project: project,
+		repo:    info.Repo,
+		token:   token,
+	}
+}
+
+func (p *bitbucketProvider) Name() string { return "Bitbucket" }
+
+func (p *bitbucketProvider) HasToken() bool { return p.token != "" }
+
+func (p *bitbucketProvider) repoPath() string {
+	return fmt.Sprintf("/projects/%s/repos/%s", p.project, p.repo)
+}
+
+// bitbucketPage is the envelope of paged Bitbucket Data Center responses
+type bitbucketPage[T any] struct {
+	Values        []T  `json:"values"`
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+// getAllBitbucketPages fetches every page of a paged Bitbucket endpoint
+func getAllBitbucketPages[T any](c *apiClient, path string) ([]T, error) {
+	var all []T
+	start := 0
+	for {
+		var page bitbucketPage[T]
+		if err := c.do("GET", fmt.Sprintf("%s?limit=100&start=%d", path, start), nil, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Values...)
+		if page.IsLastPage || len(page.Values) == 0 {
+			return all, nil
+		}
+		start = page.NextPageStart
+	}
+}
+
+func (p *bitbucketProvider) ProtectedTagPatterns() ([]string, error) {
+	type restriction struct {
+		Matcher struct {
+			ID string `json:"id"`
+		} `json:"matcher"`
+	}
+	restrictions, err := getAllBitbucketPages[restriction](p.api, "/branch-permissions/2.0"+p.repoPath()+"/restrictions")
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, r := range restrictions {
+		if strings.HasPrefix(r.Matcher.ID, "refs/tags/") {
+			patterns = append(patterns, strings.TrimPrefix(r.Matcher.ID, "refs/tags/"))
+		}
+	}
+	return uniqueStrings(patterns), nil
+}
+
+// ProtectTagPattern prevents deleting and rewriting matching tags. Restricting who
+// may create them needs per-user exemptions, which are left to the administrators.
+func (p *bitbucketProvider) ProtectTagPattern(pattern string) error {
+	for _, restrictionType := range []string{"no-deletes", "fast-forward-only"} {
+		body := map[string]interface{}{
+			"type": restrictionType,
+			"matcher": map[string]interface{}{
+				"id":        "refs/tags/" + pattern,
+				"displayId": pattern,
+				"type":      map[string]string{"id": "PATTERN"},
+				"active":    true,
+			},
+		}
+		if err := p.api.do("POST", "/branch-permissions/2.0"+p.repoPath()+"/restrictions", body, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateRelease checks that the server knows the pushed tag and returns its page.
+// Bitbucket Data Center has no release objects: the tag itself is the release.
+func (p *bitbucketProvider) CreateRelease(tag, name, notes string) (string, error) {
+	if err := p.api.do("GET", "/api/1.0"+p.repoPath()+"/tags/"+url.PathEscape(tag), nil, nil); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s/browse?at=%s", p.webBase, p.repoPath(), url.QueryEscape("refs/tags/"+tag)), nil
+}
+
+// UpdateRelease returns the page of the re-pointed tag, which is the release
+func (p *bitbucketProvider) UpdateRelease(tag, notes string) (string, error) {
+	return p.CreateRelease(tag, tag, notes)
+}
+
+// GenerateReleaseNotes is not supported: the notes are made from the commits
+func (p *bitbucketProvider) GenerateReleaseNotes(tag, previousTag string) (string, error) {
+	return "", errNotSupported
+}
+
+// MergedPullRequest is not supported: pull requests have no labels to group by
+func (p *bitbucketProvider) MergedPullRequest(number int) (mergedPullRequest, error) {
+	return mergedPullRequest{}, errNotSupported
+}
+
+// DeleteRelease is not supported: the tag itself is the release
+func (p *bitbucketProvider) DeleteRelease(tag string, draft bool) (bool, error) {
+	return false, errNotSupported
+}
+
+// FileContent is not supported: pull requests are only opened on GitHub, GitLab and Gitea
+func (p *bitbucketProvider) FileContent(path, ref string) (string, error) {
+	return "", errNotSupported
+}
+
+func (p *bitbucketProvider) OpenPullRequest(pr pullRequest) (string, error) {
+	return "", errNotSupported
+}
+
+// MissingPermissions cannot tell: Bitbucket Data Center does not expose the
+// permissions of a token on a repository
+func (p *bitbucketProvider) MissingPermissions(action string) ([]string, error) {
+	return nil, nil
+}
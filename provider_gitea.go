@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// giteaProvider talks to the Gitea (or Forgejo) REST API
+type giteaProvider struct {
+	api   *apiClient
+	info  remoteInfo
+	token string
+}
+
+// newGiteaProvider returns a Gitea provider for the API at apiBase
+func newGiteaProvider(apiBase string, info remoteInfo, token string) *giteaProvider {
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "token "+token)
+	}
+	return &giteaProvider{api: newAPIClient(apiBase, header), info: info, token: token}
+}
+
+func (p *giteaProvider) Name() string { return "Gitea" }
+
+func (p *giteaProvider) HasToken() bool { return p.token != "" }
+
+func (p *giteaProvider) repoPath() string {
+	return fmt.Sprintf("/repos/%s/%s", p.info.Owner, p.info.Repo)
+}
+
+func (p *giteaProvider) ProtectedTagPatterns() ([]string, error) {
+	type tagProtection struct {
+		NamePattern string `json:"name_pattern"`
+	}
+	protections, err := getAllPages[tagProtection](p.api, p.repoPath()+"/tag_protections")
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, tp := range protections {
+		patterns = append(patterns, tp.NamePattern)
+	}
+	return patterns, nil
+}
+
+func (p *giteaProvider) ProtectTagPattern(pattern string) error {
+	// Without allow-listed users or teams only repository administrators may push matching tags
+	body := map[string]interface{}{
+		"name_pattern": pattern,
+	}
+	return p.api.do("POST", p.repoPath()+"/tag_protections", body, nil)
+}
+
+func (p *giteaProvider) CreateRelease(tag, name, notes string) (string, error) {
+	body := map[string]interface{}{
+		"tag_name": tag,
+		"name":     name,
+		"body":     notes,
+	}
+	var release struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := p.api.do("POST", p.repoPath()+"/releases", body, &release); err != nil {
+		return "", err
+	}
+	return release.HTMLURL, nil
+}
+
+func (p *giteaProvider) UpdateRelease(tag, notes string) (string, error) {
+	var release struct {
+		ID      int64  `json:"id"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := p.api.do("GET", p.repoPath()+"/releases/tags/"+url.PathEscape(tag), nil, &release); err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	body := map[string]interface{}{"body": notes}
+	if err := p.api.do("PATCH", fmt.Sprintf("%s/releases/%d", p.repoPath(), release.ID), body, nil); err != nil {
+		return "", err
+	}
+	return release.HTMLURL, nil
+}
+
+// GenerateReleaseNotes is not supported: the notes are made from the commits
+func (p *giteaProvider) GenerateReleaseNotes(tag, previousTag string) (string, error) {
+	return "", errNotSupported
+}
+
+func (p *giteaProvider) MergedPullRequest(number int) (mergedPullRequest, error) {
+	var pr struct {
+		Title   string `json:"title"`
+		HTMLURL string `json:"html_url"`
+		User    struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		Labels []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}
+	if err := p.api.do("GET", fmt.Sprintf("%s/pulls/%d", p.repoPath(), number), nil, &pr); err != nil {
+		return mergedPullRequest{}, err
+	}
+	merged := mergedPullRequest{Number: number, Title: pr.Title, Author: pr.User.Login, URL: pr.HTMLURL}
+	for _, label := range pr.Labels {
+		merged.Labels = append(merged.Labels, label.Name)
+	}
+	return merged, nil
+}
+
+func (p *giteaProvider) DeleteRelease(tag string, draft bool) (bool, error) {
+	var release struct {
+		ID int64 `json:"id"`
+	}
+	if err := p.api.do("GET", p.repoPath()+"/releases/tags/"+url.PathEscape(tag), nil, &release); err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	path := fmt.Sprintf("%s/releases/%d", p.repoPath(), release.ID)
+	if draft {
+		return true, p.api.do("PATCH", path, map[string]interface{}{"draft": true}, nil)
+	}
+	return true, p.api.do("DELETE", path, nil, nil)
+}
+
+func (p *giteaProvider) FileContent(path, ref string) (string, error) {
+	var file repoFile
+	if err := p.api.do("GET", p.repoPath()+"/contents/"+escapeFilePath(path)+"?ref="+url.QueryEscape(ref), nil, &file); err != nil {
+		return "", err
+	}
+	return file.decode()
+}
+
+func (p *giteaProvider) OpenPullRequest(pr pullRequest) (string, error) {
+	// The first file creates the branch off the base, the others go onto it
+	from := map[string]string{"branch": pr.Base, "new_branch": pr.Branch}
+	for _, path := range pr.sortedFiles() {
+		var file repoFile
+		if err := p.api.do("GET", p.repoPath()+"/contents/"+escapeFilePath(path)+"?ref="+url.QueryEscape(from["branch"]), nil, &file); err != nil {
+			return "", err
+		}
+		update := map[string]string{
+			"message": pr.Title,
+			"content": base64.StdEncoding.EncodeToString([]byte(pr.Files[path])),
+			"sha":     file.SHA,
+		}
+		for key, value := range from {
+			update[key] = value
+		}
+		if err := p.api.do("PUT", p.repoPath()+"/contents/"+escapeFilePath(path), update, nil); err != nil {
+			return "", err
+		}
+		from = map[string]string{"branch": pr.Branch}
+	}
+
+	body := map[string]string{"title": pr.Title, "head": pr.Branch, "base": pr.Base, "body": pr.Body}
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := p.api.do("POST", p.repoPath()+"/pulls", body, &created); err != nil {
+		return "", err
+	}
+	return created.HTMLURL, nil
+}
+
+func (p *giteaProvider) MissingPermissions(action string) ([]string, error) {
+	var repo struct {
+		Permissions struct {
+			Admin bool `json:"admin"`
+			Push  bool `json:"push"`
+		} `json:"permissions"`
+	}
+	if err := p.api.do("GET", p.repoPath(), nil, &repo); err != nil {
+		return nil, err
+	}
+	switch {
+	case action == actionProtectTags && !repo.Permissions.Admin:
+		return []string{"repository admin access"}, nil
+	case action != actionProtectTags && !repo.Permissions.Push:
+		return []string{"repository write access"}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseRemoteURL tests parsing of the supported remote URL forms
+func TestParseRemoteURL(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected remoteInfo
+		ok       bool
+	}{
+		{"https://github.com/chenmijiang/go-git-publish.git", remoteInfo{"github.com", "chenmijiang", "go-git-publish"}, true},
+		{"https://github.com/chenmijiang/go-git-publish", remoteInfo{"github.com", "chenmijiang", "go-git-publish"}, true},
+		{"git@github.com:chenmijiang/go-git-publish.git", remoteInfo{"github.com", "chenmijiang", "go-git-publish"}, true},
+		{"ssh://git@gitlab.example.com:2222/group/sub/project.git", remoteInfo{"gitlab.example.com", "group/sub", "project"}, true},
+		{"https://user@gitlab.com/group/project/", remoteInfo{"gitlab.com", "group", "project"}, true},
+		{"https://me@dev.azure.com/org/My%20Project/_git/repo", remoteInfo{"dev.azure.com", "org/My Project", "repo"}, true},
+		{"git@ssh.dev.azure.com:v3/org/project/repo", remoteInfo{"ssh.dev.azure.com", "org/project", "repo"}, true},
+		{"https://org.visualstudio.com/DefaultCollection/project/_git/repo", remoteInfo{"org.visualstudio.com", "org/project", "repo"}, true},
+		{"org@vs-ssh.visualstudio.com:v3/org/project/repo", remoteInfo{"vs-ssh.visualstudio.com", "org/project", "repo"}, true},
+		{"https://dev.azure.com/org/_git/repo", remoteInfo{"dev.azure.com", "org/repo", "repo"}, true},
+		{"", remoteInfo{}, false},
+		{"https://github.com/onlyowner", remoteInfo{}, false},
+		{"not a url", remoteInfo{}, false},
+		{"/srv/git/team/tool.git", remoteInfo{}, false},
+		{"file://fileserver/share/team/tool.git", remoteInfo{}, false},
+		{"/media/usb/tool.bundle", remoteInfo{}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			result, ok := parseRemoteURL(tc.input)
+			if ok != tc.ok || result != tc.expected {
+				t.Errorf("parseRemoteURL(%q) = %+v, %v, expected %+v, %v", tc.input, result, ok, tc.expected, tc.ok)
+			}
+		})
+	}
+}
+
+// TestNewProvider tests hosting provider detection from the remote URL and the config override
+func TestNewProvider(t *testing.T) {
+	testCases := []struct {
+		input    string
+		override ProviderConfig
+		expected string
+	}{
+		{"https://github.com/owner/repo.git", ProviderConfig{}, "GitHub"},
+		{"git@github.example.com:owner/repo.git", ProviderConfig{}, "GitHub"},
+		{"https://gitlab.com/group/project.git", ProviderConfig{}, "GitLab"},
+		{"https://gitea.example.com/owner/repo.git", ProviderConfig{}, "Gitea"},
+		{"git@codeberg.org:owner/repo.git", ProviderConfig{}, "Gitea"},
+		{"https://git.example.com/scm/PROJ/repo.git", ProviderConfig{}, "Bitbucket"},
+		{"ssh://git@bitbucket.example.com:7999/PROJ/repo.git", ProviderConfig{}, "Bitbucket"},
+		{"https://bitbucket.org/owner/repo.git", ProviderConfig{}, ""},
+		{"https://dev.azure.com/org/project/_git/repo", ProviderConfig{}, "Azure DevOps"},
+		{"git@ssh.dev.azure.com:v3/org/project/repo", ProviderConfig{}, "Azure DevOps"},
+		{"https://example.com/owner/repo.git", ProviderConfig{}, ""},
+		{"https://example.com/owner/repo.git", ProviderConfig{Type: "gitea"}, "Gitea"},
+		{"https://example.com/owner/repo.git", ProviderConfig{Type: "unknown"}, ""},
+		{"file://fileserver/share/owner/repo.git", ProviderConfig{Type: "github"}, ""},
+		{"/media/usb/repo.bundle", ProviderConfig{Type: "gitlab"}, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input+" "+tc.override.Type, func(t *testing.T) {
+			p, err := newProvider(tc.input, tc.override)
+			name := ""
+			if err == nil {
+				name = p.Name()
+			}
+			if name != tc.expected {
+				t.Errorf("newProvider(%q, %v) = %q, expected %q", tc.input, tc.override, name, tc.expected)
+			}
+		})
+	}
+}
+
+// TestBitbucketProvider tests project key extraction and paged protection listing
+func TestBitbucketProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/branch-permissions/2.0/projects/PROJ/repos/repo/restrictions" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("start") == "0" {
+			fmt.Fprint(w, `{"values":[{"matcher":{"id":"refs/tags/v*"}},{"matcher":{"id":"refs/heads/main"}}],"isLastPage":false,"nextPageStart":2}`)
+			return
+		}
+		fmt.Fprint(w, `{"values":[{"matcher":{"id":"refs/tags/v*"}},{"matcher":{"id":"refs/tags/g*"}}],"isLastPage":true}`)
+	}))
+	defer server.Close()
+
+	info, _ := parseRemoteURL("https://git.example.com/scm/PROJ/repo.git")
+	p := newBitbucketProvider(server.URL+"/rest", info, "")
+
+	patterns, err := p.ProtectedTagPatterns()
+	if err != nil {
+		t.Fatalf("ProtectedTagPatterns() error: %v", err)
+	}
+	if len(patterns) != 2 || patterns[0] != "v*" || patterns[1] != "g*" {
+		t.Errorf("ProtectedTagPatterns() = %v, expected [v* g*]", patterns)
+	}
+}
+
+// TestTagPatterns tests conversion of tag formats into protection patterns
+func TestTagPatterns(t *testing.T) {
+	patterns := tagPatterns(defaultConfig)
+	expected := []string{"v*", "g*"}
+
+	if len(patterns) != len(expected) {
+		t.Fatalf("tagPatterns() = %v, expected %v", patterns, expected)
+	}
+	for i, p := range patterns {
+		if p != expected[i] {
+			t.Errorf("tagPatterns()[%d] = %q, expected %q", i, p, expected[i])
+		}
+	}
+}
+
+// TestSyncTagProtectionGitLab tests that GitLab is only sent * wildcards
+func TestSyncTagProtectionGitLab(t *testing.T) {
+	var created []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/projects/owner%2Frepo/protected_tags" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == "GET" {
+			fmt.Fprint(w, `[{"name":"v*"}]`)
+			return
+		}
+		var body struct {
+			Name        string `json:"name"`
+			AccessLevel int    `json:"create_access_level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.AccessLevel != 40 {
+			t.Errorf("POST protected_tags body = %+v, %v, want a name and access level 40", body, err)
+		}
+		created = append(created, body.Name)
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+	p := newGitLabProvider(server.URL, remoteInfo{"gitlab.com", "owner", "repo"}, "token")
+
+	// Two prefix-less formats share the one pattern GitLab understands
+	config := Config{BranchTags: []BranchTagConfig{
+		{Branch: "main", Tag: "v0.0.0"},
+		{Branch: "release", Tag: "0.0.0"},
+		{Branch: "lts", Tag: "0.0.0-lts"},
+	}}
+	var err error
+	output := captureOutput(func() { err = syncTagProtection(p, tagPatterns(config)) })
+	if err != nil {
+		t.Fatalf("syncTagProtection() error = %v", err)
+	}
+	if want := []string{"*"}; !reflect.DeepEqual(created, want) {
+		t.Errorf("syncTagProtection() created %q, want %q", created, want)
+	}
+	if !strings.Contains(output, "Warning: GitLab only matches * in tag patterns; protecting * for tags matching [0-9]*") {
+		t.Errorf("syncTagProtection() printed %q, want a warning about the widened pattern", output)
+	}
+}
+
+// TestProtectionPattern tests that only GitLab patterns lose their character classes
+func TestProtectionPattern(t *testing.T) {
+	tests := []struct {
+		p    provider
+		glob string
+		want string
+	}{
+		{&gitlabProvider{}, "v*", "v*"},
+		{&gitlabProvider{}, "[0-9]*", "*"},
+		{&gitlabProvider{}, "release-[0-9]*", "release-*"},
+		{&githubProvider{}, "[0-9]*", "[0-9]*"},
+		{&giteaProvider{}, "[0-9]*", "[0-9]*"},
+	}
+	for _, tt := range tests {
+		if got := protectionPattern(tt.p, tt.glob); got != tt.want {
+			t.Errorf("protectionPattern(%s, %q) = %q, want %q", tt.p.Name(), tt.glob, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Values of provider.cli
+const (
+	// providerCLIAuto delegates to the CLI when no API token was found
+	providerCLIAuto = "auto"
+	// providerCLIAlways delegates every API call to the CLI
+	providerCLIAlways = "always"
+)
+
+// providerCLIs are the command-line clients API calls can be delegated to
+var providerCLIs = map[string]string{
+	providerGitHub: "gh",
+	providerGitLab: "glab",
+}
+
+// validateProviderCLI returns the problems of the provider.cli setting
+func validateProviderCLI(mode string) []string {
+	switch strings.ToLower(mode) {
+	case "", providerCLIAuto, providerCLIAlways:
+		return nil
+	}
+	return []string{fmt.Sprintf("provider.cli %q is not auto or always", mode)}
+}
+
+// providerCLI returns the api subcommand of gh or glab that API calls of the
+// repository go through, or nil when they use the built-in client. With
+// always an installed and logged in CLI is required; with auto it is only
+// used when no token was found.
+func providerCLI(mode, providerType string, info remoteInfo, token string) ([]string, error) {
+	mode = strings.ToLower(mode)
+	cli, supported := providerCLIs[providerType]
+	switch {
+	case mode == "" || (mode == providerCLIAuto && token != ""):
+		return nil, nil
+	case !supported:
+		if mode == providerCLIAlways {
+			return nil, fmt.Errorf("provider.cli is always, but only GitHub (gh) and GitLab (glab) have a supported CLI")
+		}
+		return nil, nil
+	}
+
+	// auth status fails both when the CLI is missing and when it is logged out
+	if err := runWithStderr(execCommand(cli, "auth", "status", "--hostname", info.Host)); err != nil {
+		if mode == providerCLIAlways {
+			return nil, fmt.Errorf("provider.cli is always, but %s is not installed or not logged in to %s (run %s auth login --hostname %s): %v", cli, info.Host, cli, info.Host, err)
+		}
+		logFor(logAPI).Debug("not delegating to the CLI", "cli", cli, "host", info.Host, "error", err)
+		return nil, nil
+	}
+	return []string{cli, "api", "--hostname", info.Host}, nil
+}
+
+// doCLI performs req through the CLI of the client: the CLI adds its own
+// credentials and resolves its enterprise endpoint from the host
+func (c *apiClient) doCLI(req *http.Request, payload []byte) (*http.Response, error) {
+	endpoint := req.URL.String()
+	// The CLI prefixes the API root of the host itself
+	if strings.HasPrefix(endpoint, c.baseURL+"/") {
+		endpoint = strings.TrimPrefix(endpoint, c.baseURL+"/")
+	}
+
+	args := append([]string{}, c.cli[1:]...)
+	args = append(args, "--method", req.Method, "--include")
+	keys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		if !strings.EqualFold(key, "Authorization") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range req.Header[key] {
+			args = append(args, "--header", key+": "+value)
+		}
+	}
+	if payload != nil {
+		args = append(args, "--input", "-")
+	}
+	args = append(args, endpoint)
+
+	cmd := execCommand(c.cli[0], args...)
+	if payload != nil {
+		cmd.Stdin = bytes.NewReader(payload)
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	// The CLIs exit with an error for error statuses, but still print the response
+	output, err := cmd.Output()
+	resp, parseErr := parseCLIResponse(output)
+	if parseErr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("%s api: %v %s", c.cli[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("%s api: %v", c.cli[0], parseErr)
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+// parseCLIResponse parses the output of gh api --include or glab api
+// --include: the status line, the headers, an empty line and the body
+func parseCLIResponse(output []byte) (*http.Response, error) {
+	text := strings.ReplaceAll(string(output), "\r\n", "\n")
+	head, body, _ := strings.Cut(text, "\n\n")
+	lines := strings.Split(head, "\n")
+
+	// e.g. HTTP/2.0 404 Not Found
+	fields := strings.SplitN(lines[0], " ", 3)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "HTTP/") {
+		return nil, fmt.Errorf("unexpected output %q", lines[0])
+	}
+	code, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected status line %q", lines[0])
+	}
+	resp := &http.Response{StatusCode: code, Status: strings.Join(fields[1:], " "), Header: http.Header{}}
+	for _, line := range lines[1:] {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			resp.Header.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+	resp.Body = io.NopCloser(strings.NewReader(body))
+	return resp, nil
+}
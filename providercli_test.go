@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCLIResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		wantStatus int
+		wantLink   string
+		wantBody   string
+		wantErr    bool
+	}{
+		{"gh", "HTTP/2.0 200 OK\r\nContent-Type: application/json\r\nLink: <https://api.github.com/x?page=2>; rel=\"next\"\r\n\r\n[1]", 200, `<https://api.github.com/x?page=2>; rel="next"`, "[1]", false},
+		{"error status", "HTTP/1.1 404 Not Found\nContent-Type: application/json\n\n{\"message\":\"Not Found\"}", 404, "", `{"message":"Not Found"}`, false},
+		{"no content", "HTTP/2.0 204 No Content\r\n\r\n", 204, "", "", false},
+		{"not a response", "gh: not logged in", 0, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := parseCLIResponse([]byte(tt.output))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCLIResponse() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus || resp.Header.Get("Link") != tt.wantLink || string(body) != tt.wantBody {
+				t.Errorf("parseCLIResponse() = %d, Link %q, body %q, want %d, %q, %q", resp.StatusCode, resp.Header.Get("Link"), body, tt.wantStatus, tt.wantLink, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestProviderCLI(t *testing.T) {
+	info := remoteInfo{Host: "github.example.com", Owner: "acme", Repo: "tool"}
+	tests := []struct {
+		name         string
+		mode         string
+		providerType string
+		token        string
+		loggedIn     bool
+		want         []string
+		wantErr      bool
+	}{
+		{"off", "", providerGitHub, "", true, nil, false},
+		{"auto without a token", "auto", providerGitHub, "", true, []string{"gh", "api", "--hostname", "github.example.com"}, false},
+		{"auto with a token", "auto", providerGitHub, "secret", true, nil, false},
+		{"auto logged out", "auto", providerGitLab, "", false, nil, false},
+		{"always with a token", "always", providerGitLab, "secret", true, []string{"glab", "api", "--hostname", "github.example.com"}, false},
+		{"always logged out", "always", providerGitHub, "", false, nil, true},
+		{"always without a CLI", "always", providerGitea, "", true, nil, true},
+		{"auto without a CLI", "auto", providerGitea, "", true, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalExecCommand := execCommand
+			defer func() { execCommand = originalExecCommand }()
+			execCommand = func(command string, args ...string) *exec.Cmd {
+				if tt.loggedIn {
+					return exec.Command("true")
+				}
+				return exec.Command("sh", "-c", "echo 'You are not logged into any GitHub hosts' >&2; exit 1")
+			}
+
+			got, err := providerCLI(tt.mode, tt.providerType, info, tt.token)
+			if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("providerCLI() = %v, %v, want %v (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIClientThroughCLI(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	var calls [][]string
+	originalExecCommand := execCommand
+	defer func() { execCommand = originalExecCommand }()
+	execCommand = func(command string, args ...string) *exec.Cmd {
+		calls = append(calls, append([]string{command}, args...))
+		if strings.HasSuffix(args[len(args)-1], "/releases/tags/v9.9.9") {
+			return exec.Command("sh", "-c", `printf 'HTTP/2.0 404 Not Found\r\n\r\n{"message":"Not Found"}'; exit 1`)
+		}
+		return exec.Command("sh", "-c", `cat > "$0"; printf 'HTTP/2.0 201 Created\r\nContent-Type: application/json\r\n\r\n{"html_url":"https://github.com/acme/tool/releases/tag/v1.0.0"}'`, input)
+	}
+
+	c := newAPIClient("https://api.github.com", nil)
+	c.header.Set("Authorization", "Bearer secret")
+	c.header.Set("Accept", "application/vnd.github+json")
+	c.cli = []string{"gh", "api", "--hostname", "github.com"}
+
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := c.do("POST", "/repos/acme/tool/releases", map[string]string{"tag_name": "v1.0.0"}, &created); err != nil {
+		t.Fatalf("do() error = %v", err)
+	}
+	want := []string{"gh", "api", "--hostname", "github.com", "--method", "POST", "--include", "--header", "Accept: application/vnd.github+json", "--header", "Content-Type: application/json", "--input", "-", "repos/acme/tool/releases"}
+	if !reflect.DeepEqual(calls[0], want) {
+		t.Errorf("command = %q, want %q", calls[0], want)
+	}
+	if body, _ := os.ReadFile(input); string(body) != `{"tag_name":"v1.0.0"}` || created.HTMLURL != "https://github.com/acme/tool/releases/tag/v1.0.0" {
+		t.Errorf("sent %s and decoded %q, want the JSON body and the release URL", body, created.HTMLURL)
+	}
+
+	// Error statuses are API errors as with the built-in client
+	if err := c.do("GET", "/repos/acme/tool/releases/tags/v9.9.9", nil, nil); !isNotFound(err) {
+		t.Errorf("do() of a missing release = %v, want a 404 error", err)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PruneConfig selects the pre-release and nightly tags the prune command
+// deletes once they are past their retention
+type PruneConfig struct {
+	// Patterns are globs of the prunable tags, e.g. "v*-rc*" or "nightly-*"
+	Patterns []string `json:"patterns,omitempty"`
+	// Keep is the number of newest tags of every pattern that are kept
+	Keep int `json:"keep,omitempty"`
+	// MaxAge keeps tags younger than it, e.g. "90d", "8w" or "720h"
+	MaxAge string `json:"maxAge,omitempty"`
+	// Protected are globs of tags that are never pruned
+	Protected []string `json:"protected,omitempty"`
+	// Remotes also delete the pruned tags on these remotes
+	Remotes []string `json:"remotes,omitempty"`
+}
+
+// prunedTag is a tag selected for deletion
+type prunedTag struct {
+	Tag  string
+	Date time.Time
+}
+
+// validatePruneConfig returns the problems of a prune configuration
+func validatePruneConfig(config PruneConfig) []string {
+	var problems []string
+	for _, pattern := range append(append([]string{}, config.Patterns...), config.Protected...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("prune pattern %q is not a valid glob", pattern))
+		}
+	}
+	if config.Keep < 0 {
+		problems = append(problems, fmt.Sprintf("prune.keep %d is negative", config.Keep))
+	}
+	if config.MaxAge != "" {
+		if _, err := parseRetention(config.MaxAge); err != nil {
+			problems = append(problems, fmt.Sprintf("prune.maxAge: %v", err))
+		}
+	}
+	return problems
+}
+
+// parseRetention parses an age of days ("90d"), weeks ("8w") or a Go duration
+func parseRetention(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(value, suffix)); err == nil && strings.HasSuffix(value, suffix) && n >= 0 {
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q, use e.g. 90d, 8w or 720h", value)
+	}
+	return d, nil
+}
+
+// stringsFlag collects the values of a repeatable flag
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// runPruneCommand implements the prune command: it deletes old pre-release
+// tags locally and on the selected remotes
+func runPruneCommand(args []string) {
+	publishConfig := readConfig()
+	config := publishConfig.Prune
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	var patterns, remotes stringsFlag
+	fs.Var(&patterns, "pattern", "glob of the prunable tags, repeatable (default: prune.patterns)")
+	fs.Var(&remotes, "remote", "also delete the tags on this remote, repeatable (default: prune.remotes)")
+	keep := fs.Int("keep", config.Keep, "number of newest tags of every pattern to keep")
+	maxAge := fs.String("older-than", config.MaxAge, "only prune tags older than this, e.g. 90d")
+	dryRun := fs.Bool("dry-run", false, "only list the tags that would be deleted")
+	yes := fs.Bool("yes", false, "delete without asking")
+	fs.Parse(args)
+
+	if len(patterns) > 0 {
+		config.Patterns = patterns
+	}
+	if len(remotes) > 0 {
+		config.Remotes = remotes
+	}
+	config.Keep, config.MaxAge = *keep, *maxAge
+	if problems := validatePruneConfig(config); len(problems) > 0 {
+		fmt.Printf("Error: %s\n", strings.Join(problems, "; "))
+		os.Exit(1)
+	}
+	if len(config.Patterns) == 0 || (config.Keep == 0 && config.MaxAge == "") {
+		fmt.Println("Usage: git-publish prune --pattern <glob> [--keep <n>] [--older-than <age>] [--remote <name>] [--dry-run] [--yes]")
+		fmt.Println("The patterns and a retention (--keep or --older-than) can also be set under prune in publish.json")
+		os.Exit(1)
+	}
+	remoteURLs := getAllRemoteURLs()
+	for _, remote := range config.Remotes {
+		if _, ok := remoteURLs[remote]; !ok {
+			fmt.Printf("Error: remote '%s' not found\n", remote)
+			os.Exit(1)
+		}
+	}
+
+	pruned, err := selectPrunedTags(config, publishConfig.BranchTags, time.Now())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(pruned) == 0 {
+		fmt.Println("No tags to prune.")
+		return
+	}
+	for _, p := range pruned {
+		fmt.Printf("  %s (%s)\n", p.Tag, p.Date.Format("2006-01-02"))
+	}
+	where := "locally"
+	if len(config.Remotes) > 0 {
+		where += " and on " + strings.Join(config.Remotes, ", ")
+	}
+	if *dryRun {
+		fmt.Printf("Dry run: %d tags would be deleted %s.\n", len(pruned), where)
+		return
+	}
+	if !*yes && !prompter.Confirm(fmt.Sprintf("Delete %d tags %s?", len(pruned), where), false) {
+		fmt.Println("Prune cancelled.")
+		return
+	}
+
+	failed := pruneTags(pruned, config.Remotes, publishConfig.Push.SSHKey)
+	fmt.Printf("Deleted %d tags %s.\n", len(pruned)-failed, where)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// selectPrunedTags returns the tags matching the patterns that are past the
+// retention, oldest first. The newest keep tags of every pattern and tags
+// younger than maxAge stay; so do protected tags and release tags of the
+// configured formats, whatever the patterns say.
+func selectPrunedTags(config PruneConfig, branchTags []BranchTagConfig, now time.Time) ([]prunedTag, error) {
+	var maxAge time.Duration
+	if config.MaxAge != "" {
+		var err error
+		if maxAge, err = parseRetention(config.MaxAge); err != nil {
+			return nil, err
+		}
+	}
+
+	selected := map[string]prunedTag{}
+	kept := map[string]bool{}
+	for _, pattern := range config.Patterns {
+		tags, err := gitClient.ListTags(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("listing tags: %v", err)
+		}
+		var candidates []prunedTag
+		for _, tag := range tags {
+			if isProtectedTag(tag, config.Protected, branchTags) {
+				continue
+			}
+			commit, err := gitClient.RevParse("refs/tags/" + tag + "^{commit}")
+			if err != nil {
+				return nil, fmt.Errorf("resolving tag %s: %v", tag, err)
+			}
+			date, err := gitClient.CommitDate(commit)
+			if err != nil {
+				return nil, fmt.Errorf("reading the date of tag %s: %v", tag, err)
+			}
+			candidates = append(candidates, prunedTag{Tag: tag, Date: date})
+		}
+
+		// Newest first, so the first keep candidates are kept
+		sort.Slice(candidates, func(i, j int) bool {
+			if !candidates[i].Date.Equal(candidates[j].Date) {
+				return candidates[i].Date.After(candidates[j].Date)
+			}
+			return candidates[i].Tag > candidates[j].Tag
+		})
+		for i, candidate := range candidates {
+			if i < config.Keep || (config.MaxAge != "" && now.Sub(candidate.Date) < maxAge) {
+				// A tag kept under one pattern is kept under all of them
+				kept[candidate.Tag] = true
+				continue
+			}
+			selected[candidate.Tag] = candidate
+		}
+	}
+
+	pruned := make([]prunedTag, 0, len(selected))
+	for _, p := range selected {
+		if !kept[p.Tag] {
+			pruned = append(pruned, p)
+		}
+	}
+	sort.Slice(pruned, func(i, j int) bool {
+		if !pruned[i].Date.Equal(pruned[j].Date) {
+			return pruned[i].Date.Before(pruned[j].Date)
+		}
+		return pruned[i].Tag < pruned[j].Tag
+	})
+	return pruned, nil
+}
+
+// isProtectedTag reports whether tag matches a protected glob or is a
+// release tag of one of the configured formats
+func isProtectedTag(tag string, protected []string, branchTags []BranchTagConfig) bool {
+	for _, pattern := range protected {
+		if matched, _ := path.Match(pattern, tag); matched {
+			return true
+		}
+	}
+	for _, bt := range branchTags {
+		if validateTagFormat(tag, extractPrefix(bt.Tag)) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneTags deletes the tags on every remote that has them, then locally, and
+// returns the number of tags that could not be deleted everywhere. A tag whose
+// remote deletion failed is kept locally so a re-run finds it again.
+func pruneTags(pruned []prunedTag, remotes []string, sshKey string) int {
+	failed := map[string]bool{}
+	for _, remote := range remotes {
+		existing, err := gitClient.RemoteTags(remote)
+		if err != nil {
+			fmt.Printf("Error listing the tags on %s: %v\n", remote, err)
+			for _, p := range pruned {
+				failed[p.Tag] = true
+			}
+			continue
+		}
+		args := []string{remote, "--delete"}
+		var deleting []string
+		for _, p := range pruned {
+			if contains(existing, p.Tag) {
+				args = append(args, "refs/tags/"+p.Tag)
+				deleting = append(deleting, p.Tag)
+			}
+		}
+		if len(deleting) == 0 {
+			continue
+		}
+		if err := gitClient.Push(args, sshKey); err != nil {
+			fmt.Printf("Error deleting the tags on %s: %v\n", remote, err)
+			for _, tag := range deleting {
+				failed[tag] = true
+			}
+		}
+	}
+	for _, p := range pruned {
+		if failed[p.Tag] {
+			continue
+		}
+		if err := gitClient.DeleteTag(p.Tag); err != nil {
+			fmt.Printf("Error deleting tag %s: %v\n", p.Tag, err)
+			failed[p.Tag] = true
+		}
+	}
+	return len(failed)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90d", 90 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"-3d", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRetention(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseRetention(%q) = %v, %v, want %v (error: %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSelectPrunedTags(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	g := newFakeGitClient("a", "b", "c", "d")
+	for i, commit := range []string{"a", "b", "c", "d"} {
+		// a is 120 days old, d 30 days
+		g.commitDates[commit] = now.AddDate(0, 0, -120+30*i)
+	}
+	g.tags = map[string]string{
+		"v1.0.0-rc.1":      "a",
+		"v1.0.0-rc.2":      "b",
+		"v1.0.0":           "b",
+		"v1.1.0-rc.1":      "c",
+		"v1.1.0-rc.keep":   "a",
+		"nightly-20240201": "a",
+		"nightly-20240501": "d",
+	}
+	useFakeGit(t, g)
+
+	tests := []struct {
+		name   string
+		config PruneConfig
+		want   []string
+	}{
+		{"keep newest", PruneConfig{Patterns: []string{"v*-rc*"}, Keep: 1, Protected: []string{"*.keep"}}, []string{"v1.0.0-rc.1", "v1.0.0-rc.2"}},
+		{"older than", PruneConfig{Patterns: []string{"v*-rc*", "nightly-*"}, MaxAge: "75d"}, []string{"nightly-20240201", "v1.0.0-rc.1", "v1.1.0-rc.keep", "v1.0.0-rc.2"}},
+		{"keep and age", PruneConfig{Patterns: []string{"nightly-*"}, Keep: 1, MaxAge: "200d"}, nil},
+		{"release tags", PruneConfig{Patterns: []string{"v*"}, Keep: 3}, []string{"v1.0.0-rc.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pruned, err := selectPrunedTags(tt.config, defaultConfig.BranchTags, now)
+			if err != nil {
+				t.Fatalf("selectPrunedTags() error = %v", err)
+			}
+			var got []string
+			for _, p := range pruned {
+				got = append(got, p.Tag)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectPrunedTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneTags(t *testing.T) {
+	g := newFakeGitClient("a")
+	g.tags = map[string]string{"rc-1": "a", "rc-2": "a", "rc-3": "a"}
+	g.remotes = map[string]string{"origin": "https://example.com/repo.git", "backup": "https://backup.example.com/repo.git"}
+	g.remoteTags = map[string]string{"origin/rc-1": "a", "origin/rc-2": "a", "backup/rc-2": "a"}
+	g.pushErrs = map[string]error{"backup": errors.New("permission denied")}
+	useFakeGit(t, g)
+
+	pruned := []prunedTag{{Tag: "rc-1"}, {Tag: "rc-2"}, {Tag: "rc-3"}}
+	if failed := pruneTags(pruned, []string{"origin", "backup"}, ""); failed != 1 {
+		t.Errorf("pruneTags() failed = %d, want 1", failed)
+	}
+	wantPushes := [][]string{
+		{"origin", "--delete", "refs/tags/rc-1", "refs/tags/rc-2"},
+		{"backup", "--delete", "refs/tags/rc-2"},
+	}
+	if !reflect.DeepEqual(g.pushes, wantPushes) {
+		t.Errorf("pushes = %v, want %v", g.pushes, wantPushes)
+	}
+	// rc-2 could not be deleted on backup, so it stays for a re-run
+	if !reflect.DeepEqual(sortedKeys(g.tags), []string{"rc-2"}) {
+		t.Errorf("local tags = %v, want [rc-2]", sortedKeys(g.tags))
+	}
+}
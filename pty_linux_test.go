@@ -0,0 +1,46 @@
+//go:build integration && linux
+// +build integration,linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY opens a pseudo terminal. The tool reads its answers from tty, so
+// it runs as interactively as for a person; what is written to ptmx is typed
+// on tty.
+func openPTY() (ptmx, tty *os.File, err error) {
+	ptmx, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The terminal is locked until unlocked, then its number names it under /dev/pts
+	var unlock int32
+	if err := ptyIoctl(ptmx, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		ptmx.Close()
+		return nil, nil, fmt.Errorf("unlocking the pty: %v", err)
+	}
+	var number uint32
+	if err := ptyIoctl(ptmx, syscall.TIOCGPTN, unsafe.Pointer(&number)); err != nil {
+		ptmx.Close()
+		return nil, nil, fmt.Errorf("getting the pty number: %v", err)
+	}
+	tty, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	return ptmx, tty, nil
+}
+
+// ptyIoctl runs the ioctl request on f with a pointer argument
+func ptyIoctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
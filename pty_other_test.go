@@ -0,0 +1,14 @@
+//go:build integration && !linux
+// +build integration,!linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// openPTY is only implemented for Linux; the scenarios are skipped elsewhere
+func openPTY() (ptmx, tty *os.File, err error) {
+	return nil, nil, errors.New("the end-to-end scenarios need a Linux pty")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// publishProgress records which steps of a plan have been carried out
+type publishProgress struct {
+	Plan       Plan
+	TagCreated bool
+	TagPushed  bool
+}
+
+// inFlight is the plan being executed by publishPlan, nil otherwise
+var inFlight *publishProgress
+
+// restoreAfterPanic tells the user what a crashed publish run left behind.
+// A tag that was created but not pushed yet can be deleted, so the run can be
+// repeated from a clean state.
+func restoreAfterPanic() {
+	progress := inFlight
+	if progress == nil || !progress.TagCreated {
+		return
+	}
+	plan := progress.Plan
+	yellow := color.New(color.FgYellow).SprintFunc()
+
+	if progress.TagPushed {
+		fmt.Printf("%s Tag %s was created and pushed to %s; the remaining steps did not run.\n", yellow("Warning:"), plan.Tag, plan.Remote)
+		if plan.Release {
+			fmt.Printf("Create the release for %s on the hosting service to finish.\n", plan.Tag)
+		}
+		return
+	}
+
+	fmt.Printf("%s Tag %s was created on %s, but publishing did not finish.\n", yellow("Warning:"), plan.Tag, plan.Branch)
+	if prompter.Confirm(fmt.Sprintf("Delete tag %s to restore the previous state?", plan.Tag), true) {
+		if err := gitClient.DeleteTag(plan.Tag); err != nil {
+			fmt.Printf("Error deleting tag %s: %v\n", plan.Tag, err)
+		} else {
+			fmt.Printf("Deleted tag %s\n", plan.Tag)
+			fmt.Printf("Resume with: %s\n", resumeCommand(plan))
+			return
+		}
+	}
+	if plan.Remote != "" {
+		fmt.Printf("Resume with: git %s\n", strings.Join(plan.PushArgs, " "))
+	}
+}
+
+// resumeCommand returns the git-publish command that runs the plan again
+func resumeCommand(plan Plan) string {
+	command := fmt.Sprintf("git-publish --branch %s --tag %s", shellQuote(plan.Branch), shellQuote(plan.Tag))
+	if plan.TagDate != "" {
+		command += " --tag-date " + shellQuote(plan.TagDate)
+	}
+	if plan.Ticket != "" {
+		command += " --ticket " + shellQuote(plan.Ticket)
+	}
+	if plan.Remote == "" {
+		return command + " --no-push"
+	}
+	return command + " --remote " + shellQuote(plan.Remote)
+}
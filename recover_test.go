@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResumeCommand(t *testing.T) {
+	tests := []struct {
+		plan Plan
+		want string
+	}{
+		{Plan{Branch: "main", Tag: "v1.2.0", Remote: "origin"}, "git-publish --branch 'main' --tag 'v1.2.0' --remote 'origin'"},
+		{Plan{Branch: "gray", Tag: "g1.0.0"}, "git-publish --branch 'gray' --tag 'g1.0.0' --no-push"},
+		{Plan{Branch: "main", Tag: "v0.9.0", TagDate: "2020-01-02T10:00:00Z"}, "git-publish --branch 'main' --tag 'v0.9.0' --tag-date '2020-01-02T10:00:00Z' --no-push"},
+	}
+
+	for _, tt := range tests {
+		if got := resumeCommand(tt.plan); got != tt.want {
+			t.Errorf("resumeCommand(%+v) = %q, want %q", tt.plan, got, tt.want)
+		}
+	}
+}
+
+func TestRunPlanStepsRecordsProgress(t *testing.T) {
+	g := newFakeGitClient("a")
+	useFakeGit(t, g)
+	bus := &eventBus{handlers: make(map[string][]eventHandler)}
+	plan := Plan{Branch: "main", TargetCommit: "a", Tag: "v1.0.0", Remote: "origin", PushArgs: []string{"push", "origin", "v1.0.0"}}
+
+	progress := &publishProgress{Plan: plan}
+	if _, err := runPlanSteps(plan, Config{}, bus, progress); err != nil {
+		t.Fatalf("runPlanSteps() error = %v", err)
+	}
+	if !progress.TagCreated || !progress.TagPushed {
+		t.Errorf("progress = %+v, want tag created and pushed", progress)
+	}
+
+	// A failed push leaves the tag created but not pushed
+	g.tags = map[string]string{}
+	g.pushErr = errors.New("rejected")
+	progress = &publishProgress{Plan: plan}
+	if _, err := runPlanSteps(plan, Config{}, bus, progress); err == nil {
+		t.Fatal("runPlanSteps() succeeded, want the push error")
+	}
+	if !progress.TagCreated || progress.TagPushed {
+		t.Errorf("progress = %+v, want tag created but not pushed", progress)
+	}
+
+	// publishPlan only leaves the plan in flight when its steps never return
+	publishPlan(plan, Config{}, bus)
+	if inFlight != nil {
+		t.Errorf("inFlight = %+v after publishPlan returned, want nil", inFlight)
+	}
+}
+
+func TestRestoreAfterPanic(t *testing.T) {
+	plan := Plan{Branch: "main", TargetCommit: "a", Tag: "v1.0.0", Remote: "origin", PushArgs: []string{"push", "origin", "v1.0.0"}}
+
+	tests := []struct {
+		name        string
+		progress    *publishProgress
+		answers     []string
+		wantTag     bool
+		wantPrompts int
+	}{
+		{"nothing in flight", nil, nil, true, 0},
+		{"tag not created yet", &publishProgress{Plan: plan}, nil, true, 0},
+		{"delete created tag", &publishProgress{Plan: plan, TagCreated: true}, []string{""}, false, 1},
+		{"keep created tag", &publishProgress{Plan: plan, TagCreated: true}, []string{"n"}, true, 1},
+		{"pushed tag is kept", &publishProgress{Plan: plan, TagCreated: true, TagPushed: true}, nil, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGitClient("a")
+			g.tags["v1.0.0"] = "a"
+			useFakeGit(t, g)
+			p := useScriptedPrompter(t, tt.answers...)
+			defer func() { inFlight = nil }()
+			inFlight = tt.progress
+
+			restoreAfterPanic()
+
+			if _, exists := g.tags["v1.0.0"]; exists != tt.wantTag {
+				t.Errorf("tag exists = %v, want %v", exists, tt.wantTag)
+			}
+			if len(p.asked) != tt.wantPrompts {
+				t.Errorf("asked %q, want %d prompts", p.asked, tt.wantPrompts)
+			}
+		})
+	}
+}
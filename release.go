@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Release note presets, named after the conventional-changelog presets they follow
+const (
+	presetAngular             = "angular"
+	presetConventionalCommits = "conventionalcommits"
+)
+
+// ReleaseConfig represents the release published on the hosting service after a push
+type ReleaseConfig struct {
+	// Create publishes a release for the pushed tag
+	Create bool `json:"create,omitempty"`
+	// Preset groups the release notes by conventional commit type (angular or
+	// conventionalcommits); without it the notes list every commit subject
+	Preset string `json:"preset,omitempty"`
+	// Notes is commits to make the notes from the commit subjects, github
+	// to have GitHub generate them following .github/release.yml, or
+	// pullRequests to list the merged pull requests grouped by their labels.
+	// By default GitHub generates them when the repository has that file and
+	// no preset is set.
+	Notes string `json:"notes,omitempty"`
+	// Sections group pullRequests notes by label, in order (default:
+	// breaking, enhancement and bug as Breaking Changes, Features and Bug Fixes)
+	Sections []NotesSection `json:"sections,omitempty"`
+}
+
+// Sources of the release notes
+const (
+	notesCommits      = "commits"
+	notesGitHub       = "github"
+	notesPullRequests = "pullRequests"
+)
+
+// githubReleaseConfigs are the files GitHub reads the release notes categories from
+var githubReleaseConfigs = []string{".github/release.yml", ".github/release.yaml"}
+
+// conventionalSubject matches "type(scope)!: description" commit subjects
+var conventionalSubject = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?!?: (.+)$`)
+
+// conventionalSections are the note sections of the presets by commit type, in
+// order; commits of other types are left out of the notes as the presets do
+var conventionalSections = []struct{ Type, Title string }{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance Improvements"},
+	{"revert", "Reverts"},
+}
+
+// validateReleaseConfig returns the problems of a release configuration
+func validateReleaseConfig(config ReleaseConfig) []string {
+	var problems []string
+	switch config.Preset {
+	case "", presetAngular, presetConventionalCommits:
+	default:
+		problems = append(problems, fmt.Sprintf("release.preset %q is not %s or %s", config.Preset, presetAngular, presetConventionalCommits))
+	}
+	switch config.Notes {
+	case "", notesCommits:
+	case notesGitHub, notesPullRequests:
+		if config.Preset != "" {
+			problems = append(problems, fmt.Sprintf("release.preset only applies to notes made from the commits, not to release.notes %s", config.Notes))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("release.notes %q is not %s, %s or %s", config.Notes, notesCommits, notesGitHub, notesPullRequests))
+	}
+	if len(config.Sections) > 0 && config.Notes != notesPullRequests {
+		problems = append(problems, fmt.Sprintf("release.sections only apply to release.notes %s", notesPullRequests))
+	}
+	for i, section := range config.Sections {
+		if section.Title == "" {
+			problems = append(problems, fmt.Sprintf("release.sections[%d] has no title", i))
+		}
+		if len(section.Labels) == 0 {
+			problems = append(problems, fmt.Sprintf("release.sections[%d] has no labels", i))
+		}
+	}
+	return problems
+}
+
+// githubReleaseConfig returns the release notes configuration of GitHub in
+// the tree of tag, "" when it has none
+func githubReleaseConfig(tag string) string {
+	specs := make([]string, len(githubReleaseConfigs))
+	for i, file := range githubReleaseConfigs {
+		specs[i] = tag + ":" + file
+	}
+	blobs, err := readBlobs(specs)
+	if err != nil {
+		return ""
+	}
+	for i, spec := range specs {
+		if _, ok := blobs[spec]; ok {
+			return githubReleaseConfigs[i]
+		}
+	}
+	return ""
+}
+
+// generatesNotes reports whether p writes the notes of the release of tag
+func generatesNotes(p provider, config ReleaseConfig, tag string) bool {
+	switch config.Notes {
+	case notesGitHub:
+		return true
+	case notesCommits, notesPullRequests:
+		return false
+	}
+	_, isGitHub := p.(*githubProvider)
+	return isGitHub && config.Preset == "" && githubReleaseConfig(tag) != ""
+}
+
+// providerReleaseNotes returns the notes of the release of tag on p: generated
+// by the hosting service or made from its pull requests when configured, else
+// made from the commits since lastTag
+func providerReleaseNotes(p provider, config ReleaseConfig, lastTag, tag string) string {
+	if config.Notes == notesPullRequests {
+		notes, err := pullRequestNotes(p, config.Sections, lastTag, tag)
+		if err == nil {
+			return notes
+		}
+		fmt.Printf("Warning: could not list the pull requests of %s on %s (%v); listing the commits instead\n", tag, p.Name(), err)
+	}
+	if generatesNotes(p, config, tag) {
+		notes, err := p.GenerateReleaseNotes(tag, lastTag)
+		if err == nil {
+			return notes
+		}
+		fmt.Printf("Warning: %s did not generate the release notes (%v); listing the commits instead\n", p.Name(), err)
+	}
+	return releaseNotes(lastTag, tag, config.Preset)
+}
+
+// releaseNotes lists the subjects of the commits between lastTag and tag,
+// leaving out commits that opt out of releases, grouped as preset does
+func releaseNotes(lastTag, tag, preset string) string {
+	commits, err := releasedCommits(lastTag, tag)
+	if err != nil {
+		return ""
+	}
+	subjects := make([]string, len(commits))
+	for i, commit := range commits {
+		_, subjects[i], _ = strings.Cut(commit, " ")
+	}
+	if preset != "" {
+		return conventionalNotes(subjects)
+	}
+	for i, subject := range subjects {
+		subjects[i] = "- " + subject
+	}
+	return strings.Join(subjects, "\n")
+}
+
+// conventionalNotes groups commit subjects into a section per conventional
+// commit type, with the scope in bold as the presets render it
+func conventionalNotes(subjects []string) string {
+	var sections []string
+	for _, section := range conventionalSections {
+		var lines []string
+		for _, subject := range subjects {
+			m := conventionalSubject.FindStringSubmatch(subject)
+			if m == nil || !strings.EqualFold(m[1], section.Type) {
+				continue
+			}
+			if m[2] != "" {
+				lines = append(lines, fmt.Sprintf("- **%s:** %s", m[2], m[3]))
+			} else {
+				lines = append(lines, "- "+m[3])
+			}
+		}
+		if len(lines) > 0 {
+			sections = append(sections, "### "+section.Title+"\n\n"+strings.Join(lines, "\n"))
+		}
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// createRelease publishes the release of a pushed tag on the remote's hosting service
+func createRelease(plan Plan, config Config) (string, error) {
+	p, err := newProvider(plan.RemoteURL, config.Provider)
+	if err != nil {
+		return "", err
+	}
+	if !p.HasToken() {
+		return "", fmt.Errorf("no %s API token found", p.Name())
+	}
+
+	fmt.Printf("Creating %s release %s...\n", p.Name(), plan.Tag)
+	url, err := p.CreateRelease(plan.Tag, plan.Tag, providerReleaseNotes(p, config.Release, plan.LastTag, plan.Tag))
+	if err != nil {
+		return "", fmt.Errorf("creating %s release: %v", p.Name(), err)
+	}
+	return url, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReleaseNotes(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c", "d", "e")
+	g.tags = map[string]string{"v1.0.0": "a", "v1.1.0": "e"}
+	g.subjects = map[string]string{"b": "feat(cli): add export", "c": "fix: handle empty input", "d": "chore: update CI", "e": "feat!: drop v1 API"}
+	useFakeGit(t, g)
+
+	want := "- feat!: drop v1 API\n- chore: update CI\n- fix: handle empty input\n- feat(cli): add export"
+	if got := releaseNotes("v1.0.0", "v1.1.0", ""); got != want {
+		t.Errorf("releaseNotes() = %q, want %q", got, want)
+	}
+
+	want = "### Features\n\n- drop v1 API\n- **cli:** add export\n\n### Bug Fixes\n\n- handle empty input"
+	if got := releaseNotes("v1.0.0", "v1.1.0", presetConventionalCommits); got != want {
+		t.Errorf("releaseNotes(conventionalcommits) = %q, want %q", got, want)
+	}
+}
+
+// TestProviderReleaseNotes tests that GitHub writes the notes of a repository with .github/release.yml
+func TestProviderReleaseNotes(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+strings.TrimSpace(string(body)))
+		fmt.Fprint(w, `{"name":"v1.1.0","body":"## What's Changed\n### Exciting New Features\n* Add export by @octocat in #12"}`)
+	}))
+	defer server.Close()
+	github := newGitHubProvider(server.URL, remoteInfo{"github.com", "owner", "repo"}, "token")
+	gitlab := newGitLabProvider(server.URL, remoteInfo{"gitlab.com", "owner", "repo"}, "token")
+
+	dir := newBlobTestRepo(t)
+	gitInTestRepo(t, dir, "tag", "v1.0.0")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "feat: add export")
+	gitInTestRepo(t, dir, "tag", "v1.1.0")
+	os.MkdirAll(filepath.Join(dir, ".github"), 0755)
+	os.WriteFile(filepath.Join(dir, ".github", "release.yml"), []byte("changelog:\n  categories:\n    - title: Exciting New Features\n      labels: [enhancement]\n"), 0644)
+	gitInTestRepo(t, dir, "add", ".")
+	gitInTestRepo(t, dir, "commit", "-q", "-m", "chore: categorize release notes")
+	gitInTestRepo(t, dir, "tag", "v1.2.0")
+
+	generated := "## What's Changed\n### Exciting New Features\n* Add export by @octocat in #12"
+	tests := []struct {
+		name     string
+		p        provider
+		config   ReleaseConfig
+		tag      string
+		want     string
+		wantCall bool
+	}{
+		{"release.yml", github, ReleaseConfig{}, "v1.2.0", generated, true},
+		{"no release.yml", github, ReleaseConfig{}, "v1.1.0", "- feat: add export", false},
+		{"preset", github, ReleaseConfig{Preset: presetAngular}, "v1.2.0", "", false},
+		{"commits", github, ReleaseConfig{Notes: notesCommits}, "v1.2.0", "- chore: categorize release notes", false},
+		{"github", github, ReleaseConfig{Notes: notesGitHub}, "v1.1.0", generated, true},
+		{"other service", gitlab, ReleaseConfig{}, "v1.2.0", "- chore: categorize release notes", false},
+		{"not supported", gitlab, ReleaseConfig{Notes: notesGitHub}, "v1.2.0", "- chore: categorize release notes", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			lastTag := "v1.0.0"
+			if tt.tag == "v1.2.0" {
+				lastTag = "v1.1.0"
+			}
+			var got string
+			captureOutput(func() { got = providerReleaseNotes(tt.p, tt.config, lastTag, tt.tag) })
+			if got != tt.want {
+				t.Errorf("providerReleaseNotes() = %q, want %q", got, tt.want)
+			}
+			want := []string(nil)
+			if tt.wantCall {
+				want = []string{fmt.Sprintf(`POST /repos/owner/repo/releases/generate-notes {"previous_tag_name":%q,"tag_name":%q}`, lastTag, tt.tag)}
+			}
+			if !reflect.DeepEqual(requests, want) {
+				t.Errorf("requests = %q, want %q", requests, want)
+			}
+		})
+	}
+}
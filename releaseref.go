@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// namespacePattern matches a ref namespace such as refs/releases
+var namespacePattern = regexp.MustCompile(`^refs(/[A-Za-z0-9_][A-Za-z0-9_.-]*)+$`)
+
+// validateTagsConfig returns the problems of the tags section
+func validateTagsConfig(config TagsConfig) []string {
+	var problems []string
+	switch ns := config.Namespace; {
+	case ns == "":
+		if config.NamespaceOnly {
+			problems = append(problems, "tags.namespaceOnly needs tags.namespace")
+		}
+	case !namespacePattern.MatchString(ns) || strings.Contains(ns, "..") || strings.HasSuffix(ns, ".lock"):
+		problems = append(problems, fmt.Sprintf("tags.namespace %q is not a ref namespace like refs/releases", ns))
+	case ns == "refs/tags" || ns == "refs/heads" || ns == "refs/remotes" || strings.HasPrefix(ns, "refs/tags/") || strings.HasPrefix(ns, "refs/heads/") || strings.HasPrefix(ns, "refs/remotes/"):
+		problems = append(problems, fmt.Sprintf("tags.namespace %q is reserved for git's own refs", ns))
+	}
+	return append(problems, validateTagTrailers(config.Trailers)...)
+}
+
+// releaseRef returns the namespace ref a release of tag is published as, ""
+// without a namespace
+func releaseRef(config TagsConfig, tag string) string {
+	if config.Namespace == "" {
+		return ""
+	}
+	return config.Namespace + "/" + tag
+}
+
+// releaseRefspecs returns what a push of tag publishes: the tag unless only
+// the namespace ref is pushed, and the namespace ref
+func releaseRefspecs(config TagsConfig, tag string) []string {
+	var refspecs []string
+	if !config.NamespaceOnly {
+		refspecs = append(refspecs, "refs/tags/"+tag)
+	}
+	if ref := releaseRef(config, tag); ref != "" {
+		refspecs = append(refspecs, ref)
+	}
+	return refspecs
+}
+
+// createReleaseRef points the namespace ref of a plan at its commit; a ref an
+// earlier run created there is left as it is
+func createReleaseRef(plan Plan) error {
+	if existing, err := gitClient.RevParse(plan.ReleaseRef); err == nil {
+		if existing == plan.TargetCommit {
+			return nil
+		}
+		return fmt.Errorf("%s already exists at %s, not at %s", plan.ReleaseRef, shortHash(existing), shortHash(plan.TargetCommit))
+	}
+	if err := gitClient.CreateRef(plan.ReleaseRef, plan.TargetCommit); err != nil {
+		return fmt.Errorf("creating %s: %v", plan.ReleaseRef, err)
+	}
+	return nil
+}
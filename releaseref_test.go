@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateTagsConfig(t *testing.T) {
+	tests := []struct {
+		config   TagsConfig
+		problems int
+	}{
+		{TagsConfig{}, 0},
+		{TagsConfig{Namespace: "refs/releases"}, 0},
+		{TagsConfig{Namespace: "refs/deploy/prod", NamespaceOnly: true}, 0},
+		{TagsConfig{NamespaceOnly: true}, 1},
+		{TagsConfig{Namespace: "releases"}, 1},
+		{TagsConfig{Namespace: "refs/releases/"}, 1},
+		{TagsConfig{Namespace: "refs/rel eases"}, 1},
+		{TagsConfig{Namespace: "refs/tags/releases"}, 1},
+		{TagsConfig{Namespace: "refs/heads"}, 1},
+	}
+	for _, tt := range tests {
+		if problems := validateTagsConfig(tt.config); len(problems) != tt.problems {
+			t.Errorf("validateTagsConfig(%+v) = %q, want %d problem(s)", tt.config, problems, tt.problems)
+		}
+	}
+}
+
+func TestMakePlanReleaseRef(t *testing.T) {
+	g := newFakeGitClient("a")
+	useFakeGit(t, g)
+	remoteURLs := map[string]string{"origin": "https://github.com/acme/tool.git"}
+	config := Config{
+		Tags:    TagsConfig{Namespace: "refs/releases", NamespaceOnly: true},
+		Release: ReleaseConfig{Create: true},
+	}
+
+	plan, err := makePlan(config, remoteURLs, "main", "v0.0.0", "", "v1.0.0", "origin")
+	if err != nil {
+		t.Fatalf("makePlan() error = %v", err)
+	}
+	if plan.ReleaseRef != "refs/releases/v1.0.0" || !plan.RefOnly {
+		t.Errorf("makePlan() ref = %q (only: %v), want refs/releases/v1.0.0 only", plan.ReleaseRef, plan.RefOnly)
+	}
+	if !reflect.DeepEqual(plan.PushArgs, []string{"push", "origin", "refs/releases/v1.0.0"}) {
+		t.Errorf("makePlan() push args = %v", plan.PushArgs)
+	}
+	// Hosting services only know tags
+	if plan.Release || plan.TagURL != "" {
+		t.Errorf("makePlan() release = %v, tag URL = %q, want neither", plan.Release, plan.TagURL)
+	}
+}
+
+func TestRunPlanStepsCreatesReleaseRef(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	useFakeGit(t, g)
+
+	plan := Plan{Branch: "main", TargetCommit: "b", Tag: "v1.0.0", ReleaseRef: "refs/releases/v1.0.0", RefOnly: true,
+		Remote: "origin", PushArgs: []string{"push", "origin", "refs/releases/v1.0.0"}}
+	if err := publishPlan(plan, Config{}, newEventBus()); err != nil {
+		t.Fatalf("publishPlan() error = %v", err)
+	}
+	// The tag stays local, as the anchor of the next version
+	if g.tags["v1.0.0"] != "b" || g.refs["refs/releases/v1.0.0"] != "b" {
+		t.Errorf("publishPlan() created tag %q and ref %q, want both at b", g.tags["v1.0.0"], g.refs["refs/releases/v1.0.0"])
+	}
+
+	// A re-run keeps the ref; one at another commit is an error
+	plan.TagExists = true
+	if err := publishPlan(plan, Config{}, newEventBus()); err != nil {
+		t.Errorf("publishPlan() of an existing release error = %v", err)
+	}
+	g.refs["refs/releases/v1.0.0"] = "a"
+	if err := publishPlan(plan, Config{}, newEventBus()); err == nil {
+		t.Error("publishPlan() with the ref at another commit succeeded")
+	}
+}
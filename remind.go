@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RemindConfig flags branches that have not been released for a while, so
+// that slow-moving channels are not forgotten
+type RemindConfig struct {
+	// After is the age of the last tag of a branch that is overdue, e.g. "21d"
+	After string `json:"after,omitempty"`
+	// Branches overrides After per branch; "off" never reminds about the branch
+	Branches map[string]string `json:"branches,omitempty"`
+}
+
+// remindOff exempts a branch from reminders
+const remindOff = "off"
+
+// remindInterval is how often serve checks for overdue branches
+const remindInterval = 24 * time.Hour
+
+// overdueBranch is a branch whose last tag is older than its threshold
+type overdueBranch struct {
+	Branch  string
+	LastTag string
+	Date    time.Time
+	Age     time.Duration
+}
+
+// message is the reminder about b
+func (b overdueBranch) message() string {
+	return fmt.Sprintf("%s hasn't been released in %s (last tag %s on %s)", b.Branch, plural(int(b.Age/(24*time.Hour)), "day"), b.LastTag, formatReleaseTime(b.Date))
+}
+
+// enabled reports whether any branch is checked
+func (c RemindConfig) enabled() bool {
+	if c.After != "" && c.After != remindOff {
+		return true
+	}
+	for _, after := range c.Branches {
+		if after != remindOff {
+			return true
+		}
+	}
+	return false
+}
+
+// threshold returns the age after which branch is overdue, false when it is
+// not checked
+func (c RemindConfig) threshold(branch string) (time.Duration, bool) {
+	after, ok := c.Branches[branch]
+	if !ok {
+		after = c.After
+	}
+	if after == "" || after == remindOff {
+		return 0, false
+	}
+	d, err := parseRetention(after)
+	return d, err == nil
+}
+
+// validateRemindConfig returns the problems of a remind configuration
+func validateRemindConfig(config RemindConfig) []string {
+	var problems []string
+	if config.After != "" && config.After != remindOff {
+		if _, err := parseRetention(config.After); err != nil {
+			problems = append(problems, fmt.Sprintf("remind.after: %v", err))
+		}
+	}
+	branches := make([]string, 0, len(config.Branches))
+	for branch := range config.Branches {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+	for _, branch := range branches {
+		if after := config.Branches[branch]; after != remindOff {
+			if _, err := parseRetention(after); err != nil {
+				problems = append(problems, fmt.Sprintf("remind.branches.%s: %v", branch, err))
+			}
+		}
+	}
+	return problems
+}
+
+// overdueBranches returns the configured branches whose last tag is older
+// than their threshold at now, oldest release first. The release date is the
+// date of the tagged commit; a branch with several tag formats counts its
+// newest release, and a branch never released is left out.
+func overdueBranches(branchTags []BranchTagConfig, config RemindConfig, now time.Time) ([]overdueBranch, error) {
+	latest := map[string]overdueBranch{}
+	for _, bt := range branchTags {
+		if _, ok := config.threshold(bt.Branch); !ok {
+			continue
+		}
+		tag := getLastTag(bt.Branch, bt.Tag, false)
+		if tag == "" {
+			continue
+		}
+		commit, err := gitClient.RevParse("refs/tags/" + tag + "^{commit}")
+		if err != nil {
+			return nil, fmt.Errorf("resolving tag %s: %v", tag, err)
+		}
+		date, err := gitClient.CommitDate(commit)
+		if err != nil {
+			return nil, fmt.Errorf("reading the date of tag %s: %v", tag, err)
+		}
+		if previous, ok := latest[bt.Branch]; !ok || date.After(previous.Date) {
+			latest[bt.Branch] = overdueBranch{Branch: bt.Branch, LastTag: tag, Date: date, Age: now.Sub(date)}
+		}
+	}
+
+	var overdue []overdueBranch
+	for branch, b := range latest {
+		if threshold, _ := config.threshold(branch); b.Age >= threshold {
+			overdue = append(overdue, b)
+		}
+	}
+	sort.Slice(overdue, func(i, j int) bool {
+		if !overdue[i].Date.Equal(overdue[j].Date) {
+			return overdue[i].Date.Before(overdue[j].Date)
+		}
+		return overdue[i].Branch < overdue[j].Branch
+	})
+	return overdue, nil
+}
+
+// notifyOverdue sends a release.overdue notification for every overdue branch
+func notifyOverdue(config Config, overdue []overdueBranch) {
+	for _, b := range overdue {
+		sendNotification(config.Notifications, notification{
+			Event:   "release.overdue",
+			Tag:     b.LastTag,
+			Branch:  b.Branch,
+			Message: b.message(),
+		})
+	}
+}
+
+// remindOverdue checks the branches of config at now and notifies about the
+// overdue ones, for serve; nothing is checked when reminders are off
+func remindOverdue(config Config, now time.Time) {
+	if !config.Remind.enabled() {
+		return
+	}
+	overdue, err := overdueBranches(config.BranchTags, config.Remind, now)
+	if err != nil {
+		fmt.Printf("Warning: checking for overdue releases failed: %v\n", err)
+		return
+	}
+	notifyOverdue(config, overdue)
+}
+
+// scheduleReminders calls check now and then every remindInterval, for serve
+func scheduleReminders(check func(now time.Time)) {
+	for {
+		check(time.Now())
+		time.Sleep(remindInterval)
+	}
+}
+
+// runRemindCommand implements the remind command: it lists the branches that
+// have not been released within their threshold
+func runRemindCommand(args []string) {
+	config := readConfig()
+	fs := flag.NewFlagSet("remind", flag.ExitOnError)
+	after := fs.String("after", config.Remind.After, "age of the last tag after which a branch is overdue, e.g. 21d (default: remind.after)")
+	notify := fs.Bool("notify", false, "send a release.overdue notification to the notifications channels for every overdue branch")
+	fs.Parse(args)
+
+	config.Remind.After = *after
+	if problems := validateRemindConfig(config.Remind); len(problems) > 0 {
+		fmt.Printf("Error: %s\n", strings.Join(problems, "; "))
+		os.Exit(1)
+	}
+	if !config.Remind.enabled() {
+		fmt.Println("Usage: git-publish remind [--after <age>] [--notify]")
+		fmt.Println("The age, and ages per branch, can also be set under remind in publish.json")
+		os.Exit(1)
+	}
+
+	overdue, err := overdueBranches(config.BranchTags, config.Remind, time.Now())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(overdue) == 0 {
+		fmt.Println("Every branch has been released recently.")
+		return
+	}
+	if *notify {
+		notifyOverdue(config, overdue)
+		return
+	}
+	for _, b := range overdue {
+		fmt.Println(b.message())
+	}
+}
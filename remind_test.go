@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRemindConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config RemindConfig
+		want   []string
+	}{
+		{"empty", RemindConfig{}, nil},
+		{"valid", RemindConfig{After: "21d", Branches: map[string]string{"gray": "8w", "main": "off"}}, nil},
+		{"off", RemindConfig{After: "off"}, nil},
+		{"invalid", RemindConfig{After: "soon", Branches: map[string]string{"gray": "-1d"}}, []string{
+			`remind.after: invalid age "soon", use e.g. 90d, 8w or 720h`,
+			`remind.branches.gray: invalid age "-1d", use e.g. 90d, 8w or 720h`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateRemindConfig(tt.config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateRemindConfig() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemindConfigEnabled(t *testing.T) {
+	tests := []struct {
+		config RemindConfig
+		want   bool
+	}{
+		{RemindConfig{}, false},
+		{RemindConfig{After: "off"}, false},
+		{RemindConfig{Branches: map[string]string{"main": "off"}}, false},
+		{RemindConfig{After: "21d"}, true},
+		{RemindConfig{After: "off", Branches: map[string]string{"gray": "8w"}}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.config.enabled(); got != tt.want {
+			t.Errorf("%+v.enabled() = %v, want %v", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestOverdueBranches(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	g := newFakeGitClient("a", "b", "c")
+	g.commitDates["a"] = now.AddDate(0, 0, -60)
+	g.commitDates["b"] = now.AddDate(0, 0, -21)
+	g.commitDates["c"] = now.AddDate(0, 0, -3)
+	g.branches["gray"] = "b"
+	g.branches["legacy"] = "a"
+	g.branches["fresh"] = "c"
+	g.tags = map[string]string{
+		"v1.0.0":  "a",
+		"v1.1.0":  "c",
+		"g1.0.0":  "a",
+		"g1.1.0":  "b",
+		"l1.0.0":  "a",
+		"rc1.0.0": "a",
+	}
+	useFakeGit(t, g)
+	branchTags := []BranchTagConfig{
+		{Branch: "main", Tag: "v0.0.0"},
+		{Branch: "gray", Tag: "g0.0.0"},
+		{Branch: "legacy", Tag: "l0.0.0"},
+		// A second format of legacy, released as long ago
+		{Branch: "legacy", Tag: "rc0.0.0"},
+		// Never released
+		{Branch: "fresh", Tag: "f0.0.0"},
+	}
+
+	tests := []struct {
+		name   string
+		config RemindConfig
+		want   []string
+	}{
+		{"default threshold", RemindConfig{After: "21d"}, []string{"legacy l1.0.0", "gray g1.1.0"}},
+		{"longer threshold", RemindConfig{After: "30d"}, []string{"legacy l1.0.0"}},
+		{"per branch", RemindConfig{After: "90d", Branches: map[string]string{"gray": "2w", "main": "1d"}}, []string{"gray g1.1.0", "main v1.1.0"}},
+		{"off", RemindConfig{After: "21d", Branches: map[string]string{"legacy": "off"}}, []string{"gray g1.1.0"}},
+		{"nothing overdue", RemindConfig{After: "90d"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			overdue, err := overdueBranches(branchTags, tt.config, now)
+			if err != nil {
+				t.Fatalf("overdueBranches() error = %v", err)
+			}
+			var got []string
+			for _, b := range overdue {
+				got = append(got, b.Branch+" "+b.LastTag)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("overdueBranches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverdueBranchMessage(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	date := time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC)
+	b := overdueBranch{Branch: "gray", LastTag: "g1.4.0", Date: date, Age: 21*24*time.Hour + 3*time.Hour}
+	got := b.message()
+	if want := "gray hasn't been released in 21 days (last tag g1.4.0 on 2024-06-10 09:00 UTC"; !strings.HasPrefix(got, want) {
+		t.Errorf("message() = %q, want prefix %q", got, want)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Remote roles assigned by classifyRemotes
+const (
+	remoteRoleUpstream = "upstream"
+	remoteRoleFork     = "fork"
+	remoteRoleMirror   = "mirror"
+)
+
+// Remote kinds assigned by remoteKind; remotes on a hosting service have none
+const (
+	remoteKindLocal  = "local"
+	remoteKindBundle = "bundle"
+)
+
+// RemotesConfig classifies the remotes offered in the push picker
+type RemotesConfig struct {
+	// Primary is the remote offered first (default: git's remote.pushDefault)
+	Primary string `json:"primary,omitempty"`
+	// Roles maps remote names to upstream, fork or mirror, overriding the guess from names and URLs
+	Roles map[string]string `json:"roles,omitempty"`
+	// ShowMirrors lists mirror remotes in the push picker as well
+	ShowMirrors bool `json:"showMirrors,omitempty"`
+	// Mirrors receive the tag after it was pushed; they are classified as mirrors, too
+	Mirrors []string `json:"mirrors,omitempty"`
+	// MirrorBranch replicates the tagged branch to the mirrors along with the tag
+	MirrorBranch bool `json:"mirrorBranch,omitempty"`
+}
+
+// classifiedRemote is a remote with its role, "" when nothing hints at one,
+// and its kind, "" for remotes on a hosting service
+type classifiedRemote struct {
+	Name    string
+	URL     string
+	Role    string
+	Kind    string
+	Primary bool
+}
+
+// remoteKind tells repositories on the local file system (paths and file://
+// URLs, as used in air-gapped setups) and bundle files apart from remotes
+// reached over the network. Like git, it takes a colon after a slash, or after
+// a single drive letter, as part of a local path rather than scp-like syntax.
+func remoteKind(remoteURL string) string {
+	path := strings.TrimSpace(remoteURL)
+	if strings.HasPrefix(path, "file://") {
+		path = strings.TrimPrefix(path, "file://")
+	} else if strings.Contains(path, "://") {
+		return ""
+	} else if colon := strings.Index(path, ":"); colon > 1 && !strings.Contains(path[:colon], "/") {
+		return ""
+	}
+
+	if strings.HasSuffix(path, ".bundle") {
+		return remoteKindBundle
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		return remoteKindBundle
+	}
+	return remoteKindLocal
+}
+
+// classifyRemotes assigns a role to every remote and orders them with the
+// primary remote first, then by name
+func classifyRemotes(remoteURLs map[string]string, config RemotesConfig) []classifiedRemote {
+	primary := config.Primary
+	if primary == "" {
+		primary, _ = gitClient.ConfigValue("remote.pushDefault")
+	}
+
+	remotes := make([]classifiedRemote, 0, len(remoteURLs))
+	for name, url := range remoteURLs {
+		remotes = append(remotes, classifiedRemote{
+			Name:    name,
+			URL:     url,
+			Role:    remoteRole(name, url, remoteURLs, config),
+			Kind:    remoteKind(url),
+			Primary: name == primary,
+		})
+	}
+	sort.Slice(remotes, func(i, j int) bool {
+		if remotes[i].Primary != remotes[j].Primary {
+			return remotes[i].Primary
+		}
+		return remotes[i].Name < remotes[j].Name
+	})
+	return remotes
+}
+
+// remoteRole guesses the role of a remote. The config wins (roles, then
+// mirrors), then git's remote.<name>.mirror setting, then the names: "mirror"
+// in the name or URL marks a mirror, a remote named upstream or fork is one. A
+// remote pointing at the same repository as the upstream remote under another
+// owner is a fork.
+func remoteRole(name, url string, remoteURLs map[string]string, config RemotesConfig) string {
+	if role, ok := config.Roles[name]; ok {
+		return role
+	}
+	for _, mirror := range config.Mirrors {
+		if mirror == name {
+			return remoteRoleMirror
+		}
+	}
+	if mirror, _ := gitClient.ConfigValue("remote." + name + ".mirror"); mirror == "true" {
+		return remoteRoleMirror
+	}
+
+	switch {
+	case strings.Contains(strings.ToLower(name), "mirror"), strings.Contains(strings.ToLower(url), "mirror"):
+		return remoteRoleMirror
+	case name == remoteRoleUpstream:
+		return remoteRoleUpstream
+	case name == remoteRoleFork:
+		return remoteRoleFork
+	}
+
+	info, ok := parseRemoteURL(url)
+	if !ok {
+		return ""
+	}
+	for other, otherURL := range remoteURLs {
+		if other == name || (other != remoteRoleUpstream && config.Roles[other] != remoteRoleUpstream) {
+			continue
+		}
+		upstream, ok := parseRemoteURL(otherURL)
+		if ok && upstream.Host == info.Host && upstream.Repo == info.Repo && upstream.Owner != info.Owner {
+			return remoteRoleFork
+		}
+	}
+	return ""
+}
+
+// pushableRemotes returns the remotes offered for pushing: bundles cannot
+// receive pushes and are never offered, mirrors are left out unless
+// config.ShowMirrors is set or there is nothing else to push to
+func pushableRemotes(remotes []classifiedRemote, config RemotesConfig) (shown, hidden []classifiedRemote) {
+	var writable []classifiedRemote
+	for _, remote := range remotes {
+		if remote.Kind != remoteKindBundle {
+			writable = append(writable, remote)
+		}
+	}
+	if config.ShowMirrors {
+		return writable, nil
+	}
+	for _, remote := range writable {
+		if remote.Role == remoteRoleMirror {
+			hidden = append(hidden, remote)
+		} else {
+			shown = append(shown, remote)
+		}
+	}
+	if len(shown) == 0 {
+		return hidden, nil
+	}
+	return shown, hidden
+}
+
+// remoteDetail describes a remote in the push picker
+func remoteDetail(remote classifiedRemote) string {
+	var labels []string
+	if remote.Role != "" {
+		labels = append(labels, remote.Role)
+	}
+	if remote.Kind != "" {
+		labels = append(labels, remote.Kind)
+	}
+	if remote.Primary {
+		labels = append(labels, "primary")
+	}
+	if len(labels) == 0 {
+		return fmt.Sprintf("(%s)", remote.URL)
+	}
+	return fmt.Sprintf("(%s) [%s]", remote.URL, strings.Join(labels, ", "))
+}
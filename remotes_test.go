@@ -0,0 +1,198 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClassifyRemotes(t *testing.T) {
+	remoteURLs := map[string]string{
+		"origin":   "git@github.com:alice/tool.git",
+		"upstream": "https://github.com/acme/tool.git",
+		"backup":   "git@git.example.com:acme/tool.git",
+		"github":   "https://mirror.example.com/acme/tool.git",
+		"ci":       "git@gitlab.example.com:acme/tool.git",
+	}
+
+	tests := []struct {
+		name      string
+		config    RemotesConfig
+		gitConfig map[string]string
+		want      []classifiedRemote
+	}{
+		{
+			name:      "heuristics",
+			gitConfig: map[string]string{"remote.backup.mirror": "true"},
+			want: []classifiedRemote{
+				{Name: "backup", Role: remoteRoleMirror},
+				{Name: "ci"},
+				{Name: "github", Role: remoteRoleMirror},
+				{Name: "origin", Role: remoteRoleFork},
+				{Name: "upstream", Role: remoteRoleUpstream},
+			},
+		},
+		{
+			name:   "config roles and primary",
+			config: RemotesConfig{Primary: "upstream", Roles: map[string]string{"ci": remoteRoleMirror, "github": remoteRoleUpstream}},
+			want: []classifiedRemote{
+				{Name: "upstream", Role: remoteRoleUpstream, Primary: true},
+				{Name: "backup"},
+				{Name: "ci", Role: remoteRoleMirror},
+				{Name: "github", Role: remoteRoleUpstream},
+				{Name: "origin", Role: remoteRoleFork},
+			},
+		},
+		{
+			name:      "git push default is the primary remote",
+			gitConfig: map[string]string{"remote.pushDefault": "origin"},
+			want: []classifiedRemote{
+				{Name: "origin", Role: remoteRoleFork, Primary: true},
+				{Name: "backup"},
+				{Name: "ci"},
+				{Name: "github", Role: remoteRoleMirror},
+				{Name: "upstream", Role: remoteRoleUpstream},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGitClient("a")
+			for key, value := range tt.gitConfig {
+				g.config[key] = value
+			}
+			useFakeGit(t, g)
+
+			got := classifyRemotes(remoteURLs, tt.config)
+			for i := range got {
+				got[i].URL = ""
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("classifyRemotes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushableRemotes(t *testing.T) {
+	origin := classifiedRemote{Name: "origin"}
+	mirror := classifiedRemote{Name: "mirror", Role: remoteRoleMirror}
+	usb := classifiedRemote{Name: "usb", Kind: remoteKindBundle}
+
+	tests := []struct {
+		name       string
+		remotes    []classifiedRemote
+		config     RemotesConfig
+		wantShown  []classifiedRemote
+		wantHidden []classifiedRemote
+	}{
+		{"mirrors hidden", []classifiedRemote{mirror, origin}, RemotesConfig{}, []classifiedRemote{origin}, []classifiedRemote{mirror}},
+		{"mirrors shown", []classifiedRemote{mirror, origin}, RemotesConfig{ShowMirrors: true}, []classifiedRemote{mirror, origin}, nil},
+		{"only mirrors", []classifiedRemote{mirror}, RemotesConfig{}, []classifiedRemote{mirror}, nil},
+		{"bundles never", []classifiedRemote{mirror, origin, usb}, RemotesConfig{ShowMirrors: true}, []classifiedRemote{mirror, origin}, nil},
+		{"only bundles", []classifiedRemote{usb}, RemotesConfig{}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shown, hidden := pushableRemotes(tt.remotes, tt.config)
+			if !reflect.DeepEqual(shown, tt.wantShown) || !reflect.DeepEqual(hidden, tt.wantHidden) {
+				t.Errorf("pushableRemotes() = %v, %v, want %v, %v", shown, hidden, tt.wantShown, tt.wantHidden)
+			}
+		})
+	}
+}
+
+func TestPromptForPushToRemoteSkipsMirrors(t *testing.T) {
+	g := newFakeGitClient("a")
+	g.config["remote.pushDefault"] = "upstream"
+	useFakeGit(t, g)
+	remotes := map[string]string{
+		"origin":   "git@example.com:alice/tool.git",
+		"upstream": "git@example.com:acme/tool.git",
+		"mirror":   "git@backup.example.com:acme/tool.git",
+	}
+
+	// The primary remote is the default
+	useScriptedPrompter(t, "", "")
+	if _, remote, _ := promptForPushToRemote(remotes, RemotesConfig{}, false); remote != "upstream" {
+		t.Errorf("promptForPushToRemote() = %q, want the primary remote upstream", remote)
+	}
+
+	// With the mirror hidden, a single remote is used without asking
+	delete(remotes, "origin")
+	p := useScriptedPrompter(t, "")
+	if _, remote, _ := promptForPushToRemote(remotes, RemotesConfig{}, false); remote != "upstream" || len(p.asked) != 1 {
+		t.Errorf("promptForPushToRemote() = %q after %d prompts, want upstream after 1", remote, len(p.asked))
+	}
+}
+
+func TestRemoteKind(t *testing.T) {
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "transfer")
+	os.WriteFile(bundle, []byte("# v2 git bundle\n"), 0644)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"git@github.com:acme/tool.git", ""},
+		{"https://git.example.com/acme/tool.git", ""},
+		{"ssh://git@git.example.com:2222/acme/tool.git", ""},
+		{"/srv/git/tool.git", remoteKindLocal},
+		{"../tool.git", remoteKindLocal},
+		{"./dir:with/colon", remoteKindLocal},
+		{"C:/repos/tool.git", remoteKindLocal},
+		{"file:///srv/git/tool.git", remoteKindLocal},
+		{"/media/usb/tool.bundle", remoteKindBundle},
+		{"file:///media/usb/tool.bundle", remoteKindBundle},
+		{dir, remoteKindLocal},
+		{bundle, remoteKindBundle},
+	}
+	for _, tt := range tests {
+		if got := remoteKind(tt.url); got != tt.want {
+			t.Errorf("remoteKind(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestPromptForPushToRemoteSkipsBundles(t *testing.T) {
+	useFakeGit(t, newFakeGitClient("a"))
+
+	p := useScriptedPrompter(t, "")
+	push, remote, _ := promptForPushToRemote(map[string]string{"usb": "/media/usb/tool.bundle"}, RemotesConfig{}, false)
+	if push || remote != "" || len(p.asked) != 1 {
+		t.Errorf("promptForPushToRemote() = %v, %q after %d prompts, want no push after 1", push, remote, len(p.asked))
+	}
+
+	p = useScriptedPrompter(t, "")
+	remotes := map[string]string{"usb": "/media/usb/tool.bundle", "offline": "/srv/git/tool.git"}
+	if _, remote, _ := promptForPushToRemote(remotes, RemotesConfig{}, false); remote != "offline" || len(p.asked) != 1 {
+		t.Errorf("promptForPushToRemote() = %q after %d prompts, want the local remote after 1", remote, len(p.asked))
+	}
+}
+
+func TestMakePlanLocalRemotes(t *testing.T) {
+	useFakeGit(t, newFakeGitClient("a"))
+	config := defaultConfig
+	config.Release.Create = true
+	remotes := map[string]string{
+		"origin":  "git@github.com:acme/tool.git",
+		"offline": "/srv/git/tool.git",
+		"usb":     "/media/usb/tool.bundle",
+	}
+
+	plan, err := makePlan(config, remotes, "main", "v0.0.0", "", "v1.0.0", "origin")
+	if err != nil || !plan.Release || plan.TagURL != "https://github.com/acme/tool/releases/tag/v1.0.0" {
+		t.Errorf("makePlan() to a hosted remote = %v, release %v at %q, want a release on GitHub", err, plan.Release, plan.TagURL)
+	}
+	plan, err = makePlan(config, remotes, "main", "v0.0.0", "", "v1.0.0", "offline")
+	if err != nil || plan.Release || plan.Remote != "offline" || plan.TagURL != "" {
+		t.Errorf("makePlan() to a local remote = %v, release %v, want a push without release", err, plan.Release)
+	}
+	if _, err := makePlan(config, remotes, "main", "v0.0.0", "", "v1.0.0", "usb"); err == nil {
+		t.Errorf("makePlan() to a bundle succeeded, want an error")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "fmt"
+
+// Ways out of a tag that already exists on the remote, see resolveRemoteTag
+const (
+	remoteTagOtherVersion = iota
+	remoteTagFetch
+	remoteTagQuit
+)
+
+// remoteTagCommit asks remote, with a single ls-remote and whatever hosting
+// service it is, which commit tag points to there; "" when the remote does not
+// have the tag. A remote that cannot be asked is only reported: the push
+// finds out the rest.
+func remoteTagCommit(remote, tag string) string {
+	commit, _, err := gitClient.RemoteTag(remote, tag)
+	if err != nil {
+		fmt.Printf("Warning: could not check whether tag %s exists on remote %s: %v\n", tag, remote, err)
+		return ""
+	}
+	return commit
+}
+
+// remoteTagExistsError explains a tag taken on the remote to runs that cannot ask
+func remoteTagExistsError(remote, tag, commit string) error {
+	return fmt.Errorf("tag %s already exists on remote %s at %s; choose another version with --tag, or fetch the tags (git fetch %s --tags) and run again for a new suggestion", tag, remote, shortHash(commit), remote)
+}
+
+// checkRemoteTag fails when tag already exists on remote, before anything is
+// created, instead of when the push is rejected at the end
+func checkRemoteTag(remote, tag string) error {
+	if remote == "" {
+		return nil
+	}
+	if commit := remoteTagCommit(remote, tag); commit != "" {
+		return remoteTagExistsError(remote, tag, commit)
+	}
+	return nil
+}
+
+// resolveRemoteTag asks what to do about tag existing on remote at commit:
+// choose a different version, fetch the tags and have the next version
+// suggested again, or quit
+func resolveRemoteTag(remote, tag, commit string) int {
+	fmt.Printf("Tag %s already exists on remote %s at %s, probably published by someone else meanwhile.\n", tag, remote, shortHash(commit))
+	choices := []choice{
+		{Name: "Choose a different version"},
+		{Name: "Fetch the tags", Detail: fmt.Sprintf("(from %s, then suggest the next version again)", remote)},
+		{Name: "Quit"},
+	}
+	return prompter.Select("How do you want to continue?", "option", choices)
+}
+
+// fetchRemoteTags fetches the tags of remote, so the last tag is found again
+func fetchRemoteTags(remote string) {
+	fmt.Printf("Fetching the tags of %s...\n", remote)
+	if err := gitClient.Fetch("--tags", remote); err != nil {
+		fmt.Printf("Warning: fetching the tags of %s failed: %v\n", remote, err)
+	}
+}
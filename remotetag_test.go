@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckRemoteTag(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.remotes = map[string]string{"origin": "git@example.com:acme/tool.git"}
+	g.remoteTags = map[string]string{"origin/v1.1.0": "b"}
+	useFakeGit(t, g)
+
+	tests := []struct {
+		name    string
+		remote  string
+		tag     string
+		wantErr string
+	}{
+		{"free", "origin", "v1.2.0", ""},
+		{"taken", "origin", "v1.1.0", "tag v1.1.0 already exists on remote origin at b; choose another version with --tag"},
+		{"no push", "", "v1.1.0", ""},
+		// The push reports a remote that cannot be asked
+		{"unknown remote", "backup", "v1.1.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			captureOutput(func() { err = checkRemoteTag(tt.remote, tt.tag) })
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkRemoteTag(%q, %s) = %v, want %q", tt.remote, tt.tag, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveRemoteTag(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   int
+	}{
+		{"", remoteTagOtherVersion},
+		{"2", remoteTagFetch},
+		{"3", remoteTagQuit},
+	}
+	for _, tt := range tests {
+		useScriptedPrompter(t, tt.answer)
+		var got int
+		output := captureOutput(func() { got = resolveRemoteTag("origin", "v1.1.0", "0123456789") })
+		if got != tt.want {
+			t.Errorf("resolveRemoteTag() with answer %q = %d, want %d", tt.answer, got, tt.want)
+		}
+		if want := "Tag v1.1.0 already exists on remote origin at 0123456"; !strings.Contains(output, want) {
+			t.Errorf("resolveRemoteTag() printed %q, want %q", output, want)
+		}
+	}
+}
+
+func TestFetchRemoteTags(t *testing.T) {
+	g := newFakeGitClient("a")
+	useFakeGit(t, g)
+	captureOutput(func() { fetchRemoteTags("mirror") })
+	if want := [][]string{{"--tags", "mirror"}}; !reflect.DeepEqual(g.fetches, want) {
+		t.Errorf("fetches = %v, want %v", g.fetches, want)
+	}
+}
+
+func TestExecRemoteTag(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if err := exec.Command("git", "init", "-q", "--bare", remote).Run(); err != nil {
+		t.Skipf("git init failed: %v", err)
+	}
+	gitInTestRepo(t, dir, "tag", "-a", "-m", "Release", "v1.0.0")
+	gitInTestRepo(t, dir, "tag", "v1.0.1")
+	gitInTestRepo(t, dir, "push", "-q", remote, "v1.0.0", "v1.0.1")
+	head, _ := exec.Command("git", "rev-parse", "HEAD").Output()
+	tagObject, _ := exec.Command("git", "rev-parse", "refs/tags/v1.0.0").Output()
+
+	// A lease has to name the tag object of an annotated tag
+	commit, object, err := execGitClient{}.RemoteTag(remote, "v1.0.0")
+	if err != nil || commit != strings.TrimSpace(string(head)) || object != strings.TrimSpace(string(tagObject)) {
+		t.Errorf("RemoteTag(v1.0.0) = %q, %q, %v, want the tagged commit %s and the tag object %s", commit, object, err, head, tagObject)
+	}
+	if commit, object, err := (execGitClient{}).RemoteTag(remote, "v1.0.1"); commit != strings.TrimSpace(string(head)) || object != commit || err != nil {
+		t.Errorf("RemoteTag(v1.0.1) = %q, %q, %v, want the commit %s twice", commit, object, err, head)
+	}
+	if commit, object, err := (execGitClient{}).RemoteTag(remote, "v2.0.0"); commit != "" || object != "" || err != nil {
+		t.Errorf("RemoteTag(v2.0.0) = %q, %q, %v, want no tag and no error", commit, object, err)
+	}
+	if _, _, err := (execGitClient{}).RemoteTag(filepath.Join(os.TempDir(), "no-such-remote"), "v1.0.0"); err == nil {
+		t.Error("RemoteTag() of a missing remote = nil error, want one")
+	}
+}
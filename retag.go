@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// eventTagRetagged is recorded in the audit log when a tag was moved
+const eventTagRetagged = "TagRetagged"
+
+// retagPlan moves an existing tag to the head of its branch
+type retagPlan struct {
+	Tag       string
+	Branch    string
+	TagFormat string
+	OldCommit string
+	NewCommit string
+	// OldObject is the tag ref's value, the tag object of an annotated tag
+	OldObject string
+	// Annotated recreates the tag as an annotated tag with Message
+	Annotated bool
+	Message   string
+	// Signing signs the recreated tag, which is annotated then
+	Signing *SigningConfig
+	// Remotes get the tag force pushed
+	Remotes []string
+	// Leases are the values the remotes' tag refs must still have for the
+	// force push, the tag object of an annotated tag, empty when a remote
+	// does not have the tag
+	Leases map[string]string
+}
+
+// runRetag implements --force-retag: it re-points tag to the head of its
+// branch, locally and on the remotes that have it, after the tag name was
+// typed to confirm
+func runRetag(config Config, remoteURLs map[string]string, tag string, opts *publishOptions) {
+	red := color.New(color.FgRed).SprintFunc()
+
+	enterPhase("plan retag")
+	plan, err := makeRetagPlan(config, remoteURLs, tag, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if plan.OldCommit == plan.NewCommit {
+		fmt.Printf("Tag %s already points at %s, the head of %s; nothing to do.\n", tag, shortHash(plan.NewCommit), plan.Branch)
+		return
+	}
+
+	fmt.Print(formatRetagPlan(plan))
+	fmt.Println(red("Anyone who fetched the tag keeps the old commit until they run git fetch --tags --force."))
+	answer, err := prompter.Input(fmt.Sprintf("Type %s to move the tag", tag), "", func(string) error { return nil })
+	if err != nil || answer != tag {
+		fmt.Println("Retag cancelled.")
+		return
+	}
+
+	enterPhase("retag")
+	if err := executeRetag(plan, config, remoteURLs); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// makeRetagPlan resolves the tag, its branch and the remotes to force push to.
+// Without --branch the tag moves along the configured branch of its format
+// that contains its current commit.
+func makeRetagPlan(config Config, remoteURLs map[string]string, tag string, opts *publishOptions) (retagPlan, error) {
+	plan := retagPlan{Tag: tag, Leases: map[string]string{}}
+	object, err := gitClient.RevParse("refs/tags/" + tag)
+	if err != nil {
+		return plan, fmt.Errorf("tag %s does not exist; create it with --tag", tag)
+	}
+	plan.OldObject = object
+	if plan.OldCommit, err = gitClient.RevParse("refs/tags/" + tag + "^{commit}"); err != nil {
+		return plan, fmt.Errorf("resolving tag %s: %v", tag, err)
+	}
+	if plan.Message, err = gitClient.TagMessage(tag); err != nil {
+		return plan, fmt.Errorf("reading tag %s: %v", tag, err)
+	}
+	plan.Annotated = object != plan.OldCommit || plan.Message != ""
+	if config.Signing.Tags {
+		signing := config.Signing
+		plan.Signing, plan.Annotated = &signing, true
+	}
+
+	for _, bt := range config.BranchTags {
+		if (opts.Branch != "" && bt.Branch != opts.Branch) || !validateTagFormat(tag, extractPrefix(bt.Tag)) {
+			continue
+		}
+		commit, err := lookupCommit(bt.Branch)
+		if err != nil {
+			continue
+		}
+		contains, _ := gitClient.IsAncestor(plan.OldCommit, commit)
+		if plan.Branch == "" || contains {
+			plan.Branch, plan.TagFormat, plan.NewCommit = bt.Branch, bt.Tag, commit
+		}
+		if contains {
+			break
+		}
+	}
+	if plan.Branch == "" {
+		if opts.Branch != "" {
+			return plan, fmt.Errorf("tag %s does not match the tag format of branch %s", tag, opts.Branch)
+		}
+		return plan, fmt.Errorf("tag %s matches the tag format of no configured branch", tag)
+	}
+
+	var candidates []string
+	switch {
+	case opts.NoPush:
+	case opts.Remote != "":
+		if _, ok := remoteURLs[opts.Remote]; !ok {
+			return plan, fmt.Errorf("remote '%s' not found or not pushable", opts.Remote)
+		}
+		candidates = []string{opts.Remote}
+	default:
+		for _, remote := range classifyRemotes(remoteURLs, config.Remotes) {
+			if remote.Kind != remoteKindBundle {
+				candidates = append(candidates, remote.Name)
+			}
+		}
+	}
+	for _, remote := range candidates {
+		_, object, err := gitClient.RemoteTag(remote, tag)
+		switch {
+		case err != nil && opts.Remote == "":
+			fmt.Printf("Warning: could not check tag %s on %s: %v\n", tag, remote, err)
+			continue
+		case object == "" && opts.Remote == "":
+			continue
+		}
+		// The push only replaces the tag ref value the remote had when it was
+		// checked; a lease compares the unpeeled value, so it is the tag object
+		// of an annotated tag, whichever the local one is
+		plan.Remotes = append(plan.Remotes, remote)
+		plan.Leases[remote] = object
+	}
+	return plan, nil
+}
+
+// formatRetagPlan describes a retag before it is confirmed
+func formatRetagPlan(plan retagPlan) string {
+	s := fmt.Sprintf("Move tag %s from %s to %s, the head of %s\n", plan.Tag, shortHash(plan.OldCommit), shortHash(plan.NewCommit), plan.Branch)
+	if plan.Signing != nil {
+		s += fmt.Sprintf("Signed: %s\n", describeSigning(*plan.Signing))
+	}
+	if len(plan.Remotes) == 0 {
+		return s + "Force push: none, the tag is only moved locally\n"
+	}
+	return s + fmt.Sprintf("Force push: %s\n", strings.Join(plan.Remotes, ", "))
+}
+
+// executeRetag moves the tag, force pushes it and updates the releases of the
+// tag on the hosting services of the remotes. The new tag object is written,
+// signed and verified like a new tag, first and the ref moved in one update
+// from the old tag object, so the tag is never missing and stays as it was
+// when signing or the move fails.
+func executeRetag(plan retagPlan, config Config, remoteURLs map[string]string) error {
+	green := color.New(color.FgGreen).SprintFunc()
+
+	object := plan.NewCommit
+	if plan.Annotated {
+		message := "Release " + plan.Tag
+		if plan.Message != "" {
+			message += "\n\n" + plan.Message
+		}
+		tag := Plan{Tag: plan.Tag, TargetCommit: plan.NewCommit, Signing: plan.Signing}
+		var err error
+		if object, err = tagObject(tag, message, time.Now()); err != nil {
+			return fmt.Errorf("creating tag %s: %v; the tag still points at %s", plan.Tag, err, shortHash(plan.OldCommit))
+		}
+	}
+	if err := gitClient.MoveTag(plan.Tag, object, plan.OldObject); err != nil {
+		return fmt.Errorf("moving tag %s: %v; the tag still points at %s", plan.Tag, err, shortHash(plan.OldCommit))
+	}
+	fmt.Printf("Moved tag %s to %s\n", green(plan.Tag), shortHash(plan.NewCommit))
+	if releaseRef(config.Tags, plan.Tag) != "" {
+		fmt.Printf("Warning: %s still points at %s; move it with git update-ref\n", releaseRef(config.Tags, plan.Tag), shortHash(plan.OldCommit))
+	}
+
+	record := newPlan()
+	record.Tag, record.Branch, record.TagFormat, record.TargetCommit = plan.Tag, plan.Branch, plan.TagFormat, plan.NewCommit
+	if err := appendAuditLog(event{Type: eventTagRetagged, Time: time.Now().UTC(), Plan: record}); err != nil {
+		fmt.Printf("Warning: could not write the audit log: %v\n", err)
+	}
+
+	var failed []string
+	for _, remote := range plan.Remotes {
+		var args []string
+		for _, option := range config.Push.Options {
+			args = append(args, "--push-option="+option)
+		}
+		// A lease instead of --force: a tag moved meanwhile by someone else
+		// makes the push fail rather than be clobbered
+		lease := "--force-with-lease=refs/tags/" + plan.Tag + ":" + plan.Leases[remote]
+		args = append(args, lease, remote, "refs/tags/"+plan.Tag)
+		if err := gitClient.Push(args, config.Push.SSHKey); err != nil {
+			fmt.Printf("Error force pushing tag %s to %s: %v\n", plan.Tag, remote, err)
+			fmt.Printf("Retry with: git push %s %s refs/tags/%s\n", lease, remote, plan.Tag)
+			fmt.Printf("A stale lease means the tag changed on %s since it was checked; see git ls-remote %s refs/tags/%s\n", remote, remote, plan.Tag)
+			failed = append(failed, remote)
+			continue
+		}
+		fmt.Printf("Force pushed tag %s to %s\n", green(plan.Tag), remote)
+		updateRetaggedRelease(plan, config, remoteURLs[remote])
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("tag %s was moved locally but not on %s", plan.Tag, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// updateRetaggedRelease regenerates the notes of the release of a moved tag.
+// Remotes without a hosting service or API token are skipped, with a warning
+// when releases are configured.
+func updateRetaggedRelease(plan retagPlan, config Config, remoteURL string) {
+	if remoteKind(remoteURL) != "" {
+		return
+	}
+	p, err := newProvider(remoteURL, config.Provider)
+	if err != nil || !p.HasToken() {
+		if config.Release.Create {
+			fmt.Printf("Warning: the release of %s was not updated: no API token for %s\n", plan.Tag, remoteURL)
+		}
+		return
+	}
+	lastTag := getLastTagExcept(plan.Branch, plan.TagFormat, config.Tags.OnlyMarked, plan.Tag)
+	url, err := p.UpdateRelease(plan.Tag, providerReleaseNotes(p, config.Release, lastTag, plan.Tag))
+	switch {
+	case err != nil:
+		fmt.Printf("Warning: updating the %s release of %s failed: %v\n", p.Name(), plan.Tag, err)
+	case url != "":
+		fmt.Printf("Updated %s release: %s\n", p.Name(), url)
+	}
+}
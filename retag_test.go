@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMakeRetagPlan(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c")
+	g.branches["gray"] = "b"
+	g.parents["x"] = "a"
+	g.branches["release"] = "x"
+	g.tags = map[string]string{"v1.0.0": "a", "g1.0.0": "a", "v2.0.0": "x"}
+	g.tagMessages = map[string]string{"g1.0.0": "Created-By: git-publish"}
+	g.remotes = map[string]string{"origin": "git@example.com:acme/tool.git", "backup": "/srv/git/tool.git", "fork": "/srv/git/fork.git", "usb": "/media/tool.bundle"}
+	g.remoteTags = map[string]string{"origin/v1.0.0": "a", "backup/v1.0.0": "a", "origin/g1.0.0": "a"}
+	// origin has g1.0.0 as an annotated tag, backup has v1.0.0 as one
+	g.remoteTagObjects = map[string]string{"origin/g1.0.0": "tag-g", "backup/v1.0.0": "tag-v"}
+	useFakeGit(t, g)
+
+	config := Config{BranchTags: []BranchTagConfig{
+		{Branch: "main", Tag: "v0.0.0"},
+		{Branch: "release", Tag: "v0.0.0"},
+		{Branch: "gray", Tag: "g0.0.0"},
+	}}
+	tests := []struct {
+		name        string
+		tag         string
+		opts        publishOptions
+		wantBranch  string
+		wantCommit  string
+		wantRemotes []string
+		annotated   bool
+		wantErr     bool
+	}{
+		{"every remote with the tag", "v1.0.0", publishOptions{}, "main", "c", []string{"backup", "origin"}, false, false},
+		{"branch containing the tag", "v2.0.0", publishOptions{}, "release", "x", nil, false, false},
+		{"annotated", "g1.0.0", publishOptions{}, "gray", "b", []string{"origin"}, true, false},
+		{"branch flag", "v1.0.0", publishOptions{Branch: "release"}, "release", "x", []string{"backup", "origin"}, false, false},
+		{"remote flag", "v1.0.0", publishOptions{Remote: "origin"}, "main", "c", []string{"origin"}, false, false},
+		{"remote flag without the tag", "v1.0.0", publishOptions{Remote: "fork"}, "main", "c", []string{"fork"}, false, false},
+		{"no push", "v1.0.0", publishOptions{NoPush: true}, "main", "c", nil, false, false},
+		{"missing tag", "v9.0.0", publishOptions{}, "", "", nil, false, true},
+		{"branch of another format", "v1.0.0", publishOptions{Branch: "gray"}, "", "", nil, false, true},
+		{"unknown remote", "v1.0.0", publishOptions{Remote: "upstream"}, "", "", nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := makeRetagPlan(config, g.remotes, tt.tag, &tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("makeRetagPlan(%s) error = %v, want error %v", tt.tag, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if plan.Branch != tt.wantBranch || plan.NewCommit != tt.wantCommit || plan.OldCommit != g.tags[tt.tag] || !reflect.DeepEqual(plan.Remotes, tt.wantRemotes) || plan.Annotated != tt.annotated {
+				t.Errorf("makeRetagPlan(%s) = %+v, want branch %s at %s, remotes %v, annotated %v", tt.tag, plan, tt.wantBranch, tt.wantCommit, tt.wantRemotes, tt.annotated)
+			}
+			// The force push only replaces the tag ref value the remote has now,
+			// whatever the local tag is
+			for _, remote := range plan.Remotes {
+				want := g.remoteTags[remote+"/"+tt.tag]
+				if object := g.remoteTagObjects[remote+"/"+tt.tag]; object != "" {
+					want = object
+				}
+				if plan.Leases[remote] != want {
+					t.Errorf("lease of %s = %q, want %q", remote, plan.Leases[remote], want)
+				}
+			}
+		})
+	}
+
+	// Signing recreates even a lightweight tag as a signed annotated one
+	config.Signing = SigningConfig{Tags: true, Format: "ssh"}
+	plan, err := makeRetagPlan(config, g.remotes, "v1.0.0", &publishOptions{NoPush: true})
+	if err != nil || plan.Signing == nil || !plan.Annotated {
+		t.Errorf("makeRetagPlan() with signing = %+v, %v, want a signed annotated tag", plan, err)
+	}
+	if !strings.Contains(formatRetagPlan(plan), "Signed: yes (ssh, key user.signingKey)") {
+		t.Errorf("formatRetagPlan() does not show the signature:\n%s", formatRetagPlan(plan))
+	}
+}
+
+func TestExecuteRetag(t *testing.T) {
+	newBlobTestRepo(t)
+	g := newFakeGitClient("a", "b")
+	g.tags = map[string]string{"v1.0.0": "a"}
+	g.tagMessages = map[string]string{"v1.0.0": "Ticket: OPS-1"}
+	g.remotes = map[string]string{"origin": "git@example.com:acme/tool.git", "backup": "/srv/git/tool.git"}
+	useFakeGit(t, g)
+
+	plan := retagPlan{Tag: "v1.0.0", Branch: "main", TagFormat: "v0.0.0", OldCommit: "a", NewCommit: "b", OldObject: "a", Annotated: true, Message: "Ticket: OPS-1", Remotes: []string{"origin", "backup"}, Leases: map[string]string{"origin": "a", "backup": ""}}
+	config := Config{Push: PushConfig{Options: []string{"ci.skip"}}}
+	var err error
+	captureOutput(func() { err = executeRetag(plan, config, g.remotes) })
+	if err != nil {
+		t.Fatalf("executeRetag() error = %v", err)
+	}
+	if g.tags["v1.0.0"] != "b" || g.tagMessages["v1.0.0"] != "Release v1.0.0\n\nTicket: OPS-1" {
+		t.Errorf("tag v1.0.0 = %s with message %q, want it annotated at b with the old message", g.tags["v1.0.0"], g.tagMessages["v1.0.0"])
+	}
+	want := [][]string{
+		{"--push-option=ci.skip", "--force-with-lease=refs/tags/v1.0.0:a", "origin", "refs/tags/v1.0.0"},
+		{"--push-option=ci.skip", "--force-with-lease=refs/tags/v1.0.0:", "backup", "refs/tags/v1.0.0"},
+	}
+	if !reflect.DeepEqual(g.pushes, want) {
+		t.Errorf("pushes = %v, want %v", g.pushes, want)
+	}
+
+	// A failed push leaves the tag moved locally and names the remote
+	g.pushErrs = map[string]error{"backup": fmt.Errorf("rejected")}
+	g.pushes = nil
+	plan.OldCommit, plan.NewCommit, plan.OldObject, plan.Annotated = "b", "a", "b", false
+	output := captureOutput(func() { err = executeRetag(plan, config, g.remotes) })
+	if err == nil || !strings.Contains(err.Error(), "not on backup") {
+		t.Errorf("executeRetag() with a failing remote = %v, want an error naming backup", err)
+	}
+	if want := "Retry with: git push --force-with-lease=refs/tags/v1.0.0: backup refs/tags/v1.0.0"; !strings.Contains(output, want) {
+		t.Errorf("executeRetag() printed %q, want the retry keeping the lease %q", output, want)
+	}
+	if g.tags["v1.0.0"] != "a" || len(g.pushes) != 2 {
+		t.Errorf("tag v1.0.0 = %s after %d pushes, want it at a after pushing to both remotes", g.tags["v1.0.0"], len(g.pushes))
+	}
+
+	// A tag that cannot be moved stays as it was, message included
+	g.tagMessages["v1.0.0"] = "Release v1.0.0"
+	for _, moved := range []retagPlan{
+		{Tag: "v1.0.0", OldCommit: "a", NewCommit: "unknown", OldObject: "a"},
+		{Tag: "v1.0.0", OldCommit: "a", NewCommit: "unknown", OldObject: "a", Annotated: true},
+		// Moved by someone else since the plan
+		{Tag: "v1.0.0", OldCommit: "b", NewCommit: "a", OldObject: "b"},
+	} {
+		g.pushes = nil
+		captureOutput(func() { err = executeRetag(moved, config, g.remotes) })
+		if err == nil || g.tags["v1.0.0"] != "a" || g.tagMessages["v1.0.0"] != "Release v1.0.0" || len(g.pushes) > 0 {
+			t.Errorf("executeRetag(%+v) = %v with the tag at %q, want an error and the tag left alone", moved, err, g.tags["v1.0.0"])
+		}
+	}
+}
+
+func TestUpdateRetaggedRelease(t *testing.T) {
+	var notes string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /repos/acme/tool/releases/tags/v1.1.0":
+			fmt.Fprint(w, `{"id": 42, "html_url": "https://github.com/acme/tool/releases/tag/v1.1.0"}`)
+		case "PATCH /repos/acme/tool/releases/42":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			notes = body["body"]
+			fmt.Fprint(w, `{}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("GITHUB_TOKEN", "secret")
+
+	g := newFakeGitClient("a", "b", "c")
+	g.subjects = map[string]string{"b": "Fix the parser", "c": "Fix the fix"}
+	g.tags = map[string]string{"v1.0.0": "a", "v1.1.0": "c"}
+	useFakeGit(t, g)
+
+	config := Config{Provider: ProviderConfig{Type: providerGitHub, APIURL: server.URL}}
+	plan := retagPlan{Tag: "v1.1.0", Branch: "main", TagFormat: "v0.0.0", NewCommit: "c"}
+	output := captureOutput(func() { updateRetaggedRelease(plan, config, "git@github.com:acme/tool.git") })
+	if notes != "- Fix the fix\n- Fix the parser" {
+		t.Errorf("release notes = %q, want the commits up to the new commit", notes)
+	}
+	if !strings.Contains(output, "Updated GitHub release: https://github.com/acme/tool/releases/tag/v1.1.0") {
+		t.Errorf("output %q does not link the updated release", output)
+	}
+
+	// A tag without a release is left alone
+	plan.Tag = "v1.0.0"
+	if output := captureOutput(func() { updateRetaggedRelease(plan, config, "git@github.com:acme/tool.git") }); strings.Contains(output, "release") {
+		t.Errorf("output %q mentions a release of a tag without one", output)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// rollbackOptions are the steps of a rollback
+type rollbackOptions struct {
+	Remote string
+	// Draft keeps the release as a draft instead of deleting it
+	Draft bool
+	// DeleteTag also deletes the tag on the remote and locally
+	DeleteTag bool
+	Reason    string
+}
+
+// runRollbackCommand implements the rollback command: it takes back the
+// release of a published tag and records that in the audit log
+func runRollbackCommand(args []string) {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	remote := fs.String("remote", "origin", "remote whose hosting service has the release")
+	draft := fs.Bool("draft", false, "turn the release back into a draft instead of deleting it")
+	deleteTag := fs.Bool("delete-tag", false, "also delete the tag on the remote and locally")
+	reason := fs.String("reason", "", "why the release is rolled back, for the notification and the audit log")
+	dryRun := fs.Bool("dry-run", false, "only print what would be rolled back")
+	yes := fs.Bool("yes", false, "roll back without asking")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: git-publish rollback <tag> [--draft] [--delete-tag] [--reason <text>] [--remote <name>] [--dry-run] [--yes]")
+		os.Exit(1)
+	}
+	opts := rollbackOptions{Remote: *remote, Draft: *draft, DeleteTag: *deleteTag, Reason: *reason}
+	tag := fs.Arg(0)
+
+	config := readConfig()
+	remoteURL, ok := getAllRemoteURLs()[opts.Remote]
+	if !ok {
+		fmt.Printf("Error: remote '%s' not found\n", opts.Remote)
+		os.Exit(1)
+	}
+	p, err := newProvider(remoteURL, config.Provider)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkTokenPermissions(p, actionRelease); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	steps := rollbackSteps(p, tag, opts)
+	for _, step := range steps {
+		fmt.Printf("  %s\n", step)
+	}
+	if *dryRun {
+		return
+	}
+	if !*yes && !prompter.Confirm(fmt.Sprintf("Roll back %s?", tag), false) {
+		fmt.Println("Rollback cancelled.")
+		return
+	}
+
+	plan := newPlan()
+	plan.Tag = tag
+	plan.Branch = tagBranch(config, tag)
+	plan.Remote = opts.Remote
+	plan.RemoteURL = remoteURL
+	done, err := rollbackRelease(p, plan, config, opts)
+	summary := rollbackSummary(tag, done, opts.Reason)
+
+	e := event{Type: eventRolledBack, Plan: plan, Message: summary}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	newEventBus().publish(e)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		if len(done) > 0 {
+			fmt.Printf("Already done: %s\n", strings.Join(done, ", "))
+		}
+		os.Exit(1)
+	}
+	sendNotification(config.Notifications, notification{
+		Event:   "release.rolledback",
+		Tag:     tag,
+		Branch:  plan.Branch,
+		Remote:  opts.Remote,
+		Message: summary,
+	})
+}
+
+// rollbackSteps describes what rolling back tag does
+func rollbackSteps(p provider, tag string, opts rollbackOptions) []string {
+	release := fmt.Sprintf("Delete the %s release %s", p.Name(), tag)
+	if opts.Draft {
+		release = fmt.Sprintf("Turn the %s release %s back into a draft", p.Name(), tag)
+	}
+	steps := []string{release}
+	if opts.DeleteTag {
+		steps = append(steps, fmt.Sprintf("Delete the tag %s on %s and locally", tag, opts.Remote))
+	}
+	return steps
+}
+
+// rollbackRelease deletes (or drafts) the release of the planned tag and, if
+// asked to, the tag itself, returning what was done before any failure. The
+// release goes first: some services keep a release whose tag is gone.
+func rollbackRelease(p provider, plan Plan, config Config, opts rollbackOptions) ([]string, error) {
+	var done []string
+	found, err := p.DeleteRelease(plan.Tag, opts.Draft)
+	switch {
+	case err == errNotSupported && opts.DeleteTag:
+		fmt.Printf("%s has no release objects besides the tag; deleting the tag rolls it back.\n", p.Name())
+	case err == errNotSupported:
+		return done, fmt.Errorf("%s has no release objects besides the tag; roll back with --delete-tag", p.Name())
+	case err != nil:
+		return done, fmt.Errorf("rolling back the %s release: %v", p.Name(), err)
+	case !found:
+		fmt.Printf("%s has no release for %s.\n", p.Name(), plan.Tag)
+	case opts.Draft:
+		done = append(done, "release turned into a draft")
+	default:
+		done = append(done, "release deleted")
+	}
+
+	if opts.DeleteTag {
+		commit, _, err := gitClient.RemoteTag(plan.Remote, plan.Tag)
+		if err != nil {
+			return done, fmt.Errorf("looking up tag %s on %s: %v", plan.Tag, plan.Remote, err)
+		}
+		if commit != "" {
+			if err := gitClient.Push([]string{plan.Remote, "--delete", "refs/tags/" + plan.Tag}, config.Push.SSHKey); err != nil {
+				return done, fmt.Errorf("deleting tag %s on %s: %v", plan.Tag, plan.Remote, err)
+			}
+			done = append(done, "tag deleted on "+plan.Remote)
+		}
+		// The tag may only exist on the remote, e.g. when it was created there
+		if _, err := gitClient.RevParse("refs/tags/" + plan.Tag); err == nil {
+			if err := gitClient.DeleteTag(plan.Tag); err != nil {
+				return done, fmt.Errorf("deleting tag %s: %v", plan.Tag, err)
+			}
+			done = append(done, "local tag deleted")
+		}
+	}
+	return done, nil
+}
+
+// rollbackSummary is the message of the notification and audit log entry
+func rollbackSummary(tag string, done []string, reason string) string {
+	summary := fmt.Sprintf("Rolled back %s", tag)
+	if len(done) > 0 {
+		summary += ": " + strings.Join(done, ", ")
+	} else {
+		summary += ": nothing to undo"
+	}
+	if reason != "" {
+		summary += " (" + reason + ")"
+	}
+	return summary
+}
+
+// tagBranch returns the first configured branch whose tag format tag has
+func tagBranch(config Config, tag string) string {
+	for _, bt := range config.BranchTags {
+		if validateTagFormat(tag, extractPrefix(bt.Tag)) {
+			return bt.Branch
+		}
+	}
+	return ""
+}
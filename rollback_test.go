@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestRollbackRelease tests the steps of a rollback against a GitHub API
+func TestRollbackRelease(t *testing.T) {
+	testCases := []struct {
+		name         string
+		opts         rollbackOptions
+		release      bool
+		remoteTag    bool
+		expectedDone []string
+		expectedReq  []string
+		expectedPush [][]string
+	}{
+		{
+			name:         "delete release",
+			release:      true,
+			remoteTag:    true,
+			expectedDone: []string{"release deleted"},
+			expectedReq:  []string{"GET /repos/owner/repo/releases/tags/v1.2.0", "DELETE /repos/owner/repo/releases/7"},
+		},
+		{
+			name:         "draft release",
+			opts:         rollbackOptions{Draft: true},
+			release:      true,
+			expectedDone: []string{"release turned into a draft"},
+			expectedReq:  []string{"GET /repos/owner/repo/releases/tags/v1.2.0", `PATCH /repos/owner/repo/releases/7 {"draft":true}`},
+		},
+		{
+			name:         "delete release and tag",
+			opts:         rollbackOptions{DeleteTag: true},
+			release:      true,
+			remoteTag:    true,
+			expectedDone: []string{"release deleted", "tag deleted on origin", "local tag deleted"},
+			expectedReq:  []string{"GET /repos/owner/repo/releases/tags/v1.2.0", "DELETE /repos/owner/repo/releases/7"},
+			expectedPush: [][]string{{"origin", "--delete", "refs/tags/v1.2.0"}},
+		},
+		{
+			name:         "tag without release or remote tag",
+			opts:         rollbackOptions{DeleteTag: true},
+			expectedDone: []string{"local tag deleted"},
+			expectedReq:  []string{"GET /repos/owner/repo/releases/tags/v1.2.0"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				requests = append(requests, strings.TrimSpace(r.Method+" "+r.URL.Path+" "+strings.TrimSpace(string(body))))
+				if r.Method == "GET" && !tc.release {
+					http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+					return
+				}
+				fmt.Fprint(w, `{"id":7}`)
+			}))
+			defer server.Close()
+
+			g := newFakeGitClient("c1")
+			g.remotes["origin"] = "https://github.com/owner/repo.git"
+			g.tags["v1.2.0"] = "c1"
+			if tc.remoteTag {
+				g.remoteTags["origin/v1.2.0"] = "c1"
+			}
+			useFakeGit(t, g)
+
+			tc.opts.Remote = "origin"
+			plan := newPlan()
+			plan.Tag = "v1.2.0"
+			plan.Remote = "origin"
+			p := newGitHubProvider(server.URL, remoteInfo{"github.com", "owner", "repo"}, "token")
+			var done []string
+			var err error
+			captureOutput(func() { done, err = rollbackRelease(p, plan, Config{}, tc.opts) })
+			if err != nil {
+				t.Fatalf("rollbackRelease() failed: %v", err)
+			}
+			if !reflect.DeepEqual(done, tc.expectedDone) {
+				t.Errorf("done = %q, expected %q", done, tc.expectedDone)
+			}
+			if !reflect.DeepEqual(requests, tc.expectedReq) {
+				t.Errorf("requests = %q, expected %q", requests, tc.expectedReq)
+			}
+			if !reflect.DeepEqual(g.pushes, tc.expectedPush) {
+				t.Errorf("pushes = %q, expected %q", g.pushes, tc.expectedPush)
+			}
+			if _, exists := g.tags["v1.2.0"]; exists == tc.opts.DeleteTag {
+				t.Errorf("local tag exists = %v with DeleteTag %v", exists, tc.opts.DeleteTag)
+			}
+		})
+	}
+}
+
+// TestRollbackWithoutReleaseObjects tests services whose tag is the release
+func TestRollbackWithoutReleaseObjects(t *testing.T) {
+	p, err := newProvider("https://git.example.com/scm/PROJ/repo.git", ProviderConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := newPlan()
+	plan.Tag = "v1.2.0"
+	plan.Remote = "origin"
+
+	_, err = rollbackRelease(p, plan, Config{}, rollbackOptions{Remote: "origin"})
+	if err == nil || !strings.Contains(err.Error(), "--delete-tag") {
+		t.Errorf("rollbackRelease() = %v, expected a hint at --delete-tag", err)
+	}
+}
+
+// TestRollbackSummary tests the notification message of a rollback
+func TestRollbackSummary(t *testing.T) {
+	testCases := []struct {
+		done     []string
+		reason   string
+		expected string
+	}{
+		{[]string{"release deleted", "tag deleted on origin"}, "broken migration", "Rolled back v1.2.0: release deleted, tag deleted on origin (broken migration)"},
+		{nil, "", "Rolled back v1.2.0: nothing to undo"},
+	}
+	for _, tc := range testCases {
+		if got := rollbackSummary("v1.2.0", tc.done, tc.reason); got != tc.expected {
+			t.Errorf("rollbackSummary(%q, %q) = %q, expected %q", tc.done, tc.reason, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,580 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TagRule is a condition every new tag has to satisfy, written in a small
+// expression language, e.g. `patch < 100` or `major > lastMajor implies branch == "main"`
+type TagRule struct {
+	Rule string `json:"rule"`
+	// Message is shown when the rule is broken instead of the rule itself
+	Message string `json:"message,omitempty"`
+}
+
+// tagRuleVariables are the names a rule may use, with their meaning
+var tagRuleVariables = map[string]string{
+	"tag":       "the new tag",
+	"prefix":    "the tag prefix, e.g. v",
+	"major":     "major version of the new tag",
+	"minor":     "minor version of the new tag",
+	"patch":     "patch version of the new tag",
+	"branch":    "the tagged branch",
+	"lastTag":   `the last tag on the branch, "" for the first tag`,
+	"lastMajor": "major version of the last tag, 0 for the first tag",
+	"lastMinor": "minor version of the last tag, 0 for the first tag",
+	"lastPatch": "patch version of the last tag, 0 for the first tag",
+	"bump":      `major, minor or patch: the part incremented since the last tag, "" for the first tag`,
+	"first":     "true for the first tag on the branch",
+}
+
+// checkTagRules returns an error for the first rule tag breaks
+func checkTagRules(rules []TagRule, branch, tagFormat, lastTag, tag string) error {
+	env := tagRuleEnv(branch, tagFormat, lastTag, tag)
+	for _, rule := range rules {
+		expr, err := parseTagRule(rule.Rule)
+		if err != nil {
+			return err
+		}
+		value, err := expr.eval(env)
+		if err != nil {
+			return fmt.Errorf("evaluating rule %q: %v", rule.Rule, err)
+		}
+		ok, isBool := value.(bool)
+		if !isBool {
+			return fmt.Errorf("rule %q does not evaluate to true or false", rule.Rule)
+		}
+		if !ok {
+			if rule.Message != "" {
+				return fmt.Errorf("%s", rule.Message)
+			}
+			return fmt.Errorf("tag %s breaks the rule %s", tag, rule.Rule)
+		}
+	}
+	return nil
+}
+
+// validateTagRules returns the problems of the configured rules
+func validateTagRules(rules []TagRule) []string {
+	var problems []string
+	for _, rule := range rules {
+		if _, err := parseTagRule(rule.Rule); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+// tagRuleEnv returns the variables rules are evaluated with
+func tagRuleEnv(branch, tagFormat, lastTag, tag string) map[string]interface{} {
+	prefix := extractPrefix(tagFormat)
+	version, _ := tagVersion(tag, prefix)
+	last, hasLast := tagVersion(lastTag, prefix)
+
+	bump := ""
+	switch {
+	case !hasLast:
+	case version[0] != last[0]:
+		bump = bumpMajor
+	case version[1] != last[1]:
+		bump = bumpMinor
+	default:
+		bump = bumpPatch
+	}
+
+	return map[string]interface{}{
+		"tag":       tag,
+		"prefix":    prefix,
+		"major":     int64(version[0]),
+		"minor":     int64(version[1]),
+		"patch":     int64(version[2]),
+		"branch":    branch,
+		"lastTag":   lastTag,
+		"lastMajor": int64(last[0]),
+		"lastMinor": int64(last[1]),
+		"lastPatch": int64(last[2]),
+		"bump":      bump,
+		"first":     !hasLast,
+	}
+}
+
+// tagVersion returns the major, minor and patch version of a tag with prefix
+func tagVersion(tag, prefix string) ([3]int, bool) {
+	var version [3]int
+	if tag == "" || !validateTagFormat(tag, prefix) {
+		return version, false
+	}
+	for i, part := range strings.Split(tag[len(prefix):], ".") {
+		version[i], _ = strconv.Atoi(part)
+	}
+	return version, true
+}
+
+// ruleExpr is a parsed rule expression
+type ruleExpr interface {
+	eval(env map[string]interface{}) (interface{}, error)
+}
+
+type literalExpr struct{ value interface{} }
+
+type variableExpr struct{ name string }
+
+type listExpr struct{ items []ruleExpr }
+
+type unaryExpr struct {
+	op string
+	x  ruleExpr
+}
+
+type binaryExpr struct {
+	op   string
+	x, y ruleExpr
+}
+
+func (e literalExpr) eval(env map[string]interface{}) (interface{}, error) {
+	return e.value, nil
+}
+
+func (e variableExpr) eval(env map[string]interface{}) (interface{}, error) {
+	value, ok := env[e.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %s", e.name)
+	}
+	return value, nil
+}
+
+func (e listExpr) eval(env map[string]interface{}) (interface{}, error) {
+	values := make([]interface{}, len(e.items))
+	for i, item := range e.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (e unaryExpr) eval(env map[string]interface{}) (interface{}, error) {
+	x, err := e.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch v := x.(type) {
+	case bool:
+		if e.op == "!" {
+			return !v, nil
+		}
+	case int64:
+		if e.op == "-" {
+			return -v, nil
+		}
+	}
+	return nil, fmt.Errorf("%s cannot be applied to %s", e.op, describeValue(x))
+}
+
+func (e binaryExpr) eval(env map[string]interface{}) (interface{}, error) {
+	x, err := e.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// The logical operators only evaluate their right side when needed
+	switch e.op {
+	case "&&", "||", "implies":
+		left, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s needs true or false, got %s", e.op, describeValue(x))
+		}
+		if (e.op == "&&" && !left) || (e.op == "||" && left) {
+			return left, nil
+		}
+		if e.op == "implies" && !left {
+			return true, nil
+		}
+		y, err := e.y.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		right, ok := y.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s needs true or false, got %s", e.op, describeValue(y))
+		}
+		return right, nil
+	}
+
+	y, err := e.y.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	_, xList := x.([]interface{})
+	_, yList := y.([]interface{})
+	switch {
+	case (e.op == "==" || e.op == "!=") && (xList || yList), e.op == "in" && xList:
+		return nil, fmt.Errorf("lists can only be used on the right of in")
+	case e.op == "==":
+		return x == y, nil
+	case e.op == "!=":
+		return x != y, nil
+	}
+
+	switch e.op {
+	case "in":
+		switch container := y.(type) {
+		case []interface{}:
+			for _, item := range container {
+				if item == x {
+					return true, nil
+				}
+			}
+			return false, nil
+		case string:
+			if s, ok := x.(string); ok {
+				return strings.Contains(container, s), nil
+			}
+		}
+	case "matches":
+		s, ok1 := x.(string)
+		pattern, ok2 := y.(string)
+		if ok1 && ok2 {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+			return re.MatchString(s), nil
+		}
+	}
+
+	if a, ok := x.(int64); ok {
+		if b, ok := y.(int64); ok {
+			return evalInts(e.op, a, b)
+		}
+	}
+	if a, ok := x.(string); ok {
+		if b, ok := y.(string); ok {
+			switch e.op {
+			case "<":
+				return a < b, nil
+			case "<=":
+				return a <= b, nil
+			case ">":
+				return a > b, nil
+			case ">=":
+				return a >= b, nil
+			case "+":
+				return a + b, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s cannot be applied to %s and %s", e.op, describeValue(x), describeValue(y))
+}
+
+// evalInts applies an arithmetic or comparison operator to two numbers
+func evalInts(op string, a, b int64) (interface{}, error) {
+	switch op {
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	case ">":
+		return a > b, nil
+	case ">=":
+		return a >= b, nil
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/", "%":
+		if b == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		if op == "/" {
+			return a / b, nil
+		}
+		return a % b, nil
+	}
+	return nil, fmt.Errorf("%s cannot be applied to numbers", op)
+}
+
+// describeValue names the type of a value for error messages
+func describeValue(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		return fmt.Sprintf("true/false (%v)", v)
+	case int64:
+		return fmt.Sprintf("a number (%d)", v)
+	case string:
+		return fmt.Sprintf("a string (%q)", v)
+	case []interface{}:
+		return "a list"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// ruleToken is a token of a rule expression; kind is number, string, name, op or end
+type ruleToken struct {
+	kind string
+	text string
+}
+
+// ruleOperators are the operators of the rule language, longest first
+var ruleOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ","}
+
+// tokenizeRule splits a rule expression into tokens
+func tokenizeRule(src string) ([]ruleToken, error) {
+	var tokens []ruleToken
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && src[i] >= '0' && src[i] <= '9' {
+				i++
+			}
+			tokens = append(tokens, ruleToken{"number", src[start:i]})
+		case c == '"':
+			end := i + 1
+			for end < len(src) && src[end] != '"' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("unterminated string in %q", src)
+			}
+			value, err := strconv.Unquote(src[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s in %q", src[i:end+1], src)
+			}
+			tokens = append(tokens, ruleToken{"string", value})
+			i = end + 1
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			tokens = append(tokens, ruleToken{"name", src[start:i]})
+		default:
+			op := ""
+			for _, candidate := range ruleOperators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q in %q", src[i], src)
+			}
+			tokens = append(tokens, ruleToken{"op", op})
+			i += len(op)
+		}
+	}
+	return append(tokens, ruleToken{kind: "end"}), nil
+}
+
+// ruleParser parses rule expressions by recursive descent. From the lowest
+// precedence: implies, ||, &&, !, comparisons (== != < <= > >= in matches),
+// + -, * / %, unary minus.
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+}
+
+// parseTagRule parses a rule and checks that it only uses known variables
+func parseTagRule(src string) (ruleExpr, error) {
+	tokens, err := tokenizeRule(src)
+	if err != nil {
+		return nil, fmt.Errorf("rule %q: %v", src, err)
+	}
+	p := &ruleParser{tokens: tokens}
+	expr, err := p.implies()
+	if err == nil && p.peek().kind != "end" {
+		err = fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rule %q: %v", src, err)
+	}
+	return expr, nil
+}
+
+func (p *ruleParser) peek() ruleToken {
+	return p.tokens[p.pos]
+}
+
+// accept consumes the next token if it is one of the given operators or keywords
+func (p *ruleParser) accept(ops ...string) (string, bool) {
+	token := p.peek()
+	if token.kind != "op" && token.kind != "name" {
+		return "", false
+	}
+	for _, op := range ops {
+		if token.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *ruleParser) implies() (ruleExpr, error) {
+	x, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("implies"); !ok {
+		return x, nil
+	}
+	y, err := p.implies()
+	if err != nil {
+		return nil, err
+	}
+	return binaryExpr{"implies", x, y}, nil
+}
+
+func (p *ruleParser) or() (ruleExpr, error) {
+	return p.binary([]string{"||"}, p.and)
+}
+
+func (p *ruleParser) and() (ruleExpr, error) {
+	return p.binary([]string{"&&"}, p.not)
+}
+
+func (p *ruleParser) not() (ruleExpr, error) {
+	if _, ok := p.accept("!"); ok {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return unaryExpr{"!", x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *ruleParser) comparison() (ruleExpr, error) {
+	x, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=", "in", "matches")
+	if !ok {
+		return x, nil
+	}
+	y, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if literal, ok := y.(literalExpr); ok && op == "matches" {
+		if pattern, ok := literal.value.(string); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return binaryExpr{op, x, y}, nil
+}
+
+func (p *ruleParser) sum() (ruleExpr, error) {
+	return p.binary([]string{"+", "-"}, p.product)
+}
+
+func (p *ruleParser) product() (ruleExpr, error) {
+	return p.binary([]string{"*", "/", "%"}, p.negation)
+}
+
+// binary parses a left-associative chain of ops between operands
+func (p *ruleParser) binary(ops []string, operand func() (ruleExpr, error)) (ruleExpr, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return x, nil
+		}
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		x = binaryExpr{op, x, y}
+	}
+}
+
+func (p *ruleParser) negation() (ruleExpr, error) {
+	if _, ok := p.accept("-"); ok {
+		x, err := p.negation()
+		if err != nil {
+			return nil, err
+		}
+		return unaryExpr{"-", x}, nil
+	}
+	return p.primary()
+}
+
+func (p *ruleParser) primary() (ruleExpr, error) {
+	token := p.peek()
+	p.pos++
+	switch token.kind {
+	case "number":
+		n, err := strconv.ParseInt(token.text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", token.text)
+		}
+		return literalExpr{n}, nil
+	case "string":
+		return literalExpr{token.text}, nil
+	case "name":
+		switch token.text {
+		case "true", "false":
+			return literalExpr{token.text == "true"}, nil
+		}
+		if _, ok := tagRuleVariables[token.text]; !ok {
+			return nil, fmt.Errorf("unknown variable %s", token.text)
+		}
+		return variableExpr{token.text}, nil
+	case "op":
+		switch token.text {
+		case "(":
+			x, err := p.implies()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, fmt.Errorf("missing )")
+			}
+			return x, nil
+		case "[":
+			var list listExpr
+			if _, ok := p.accept("]"); ok {
+				return list, nil
+			}
+			for {
+				item, err := p.implies()
+				if err != nil {
+					return nil, err
+				}
+				list.items = append(list.items, item)
+				if _, ok := p.accept("]"); ok {
+					return list, nil
+				}
+				if _, ok := p.accept(","); !ok {
+					return nil, fmt.Errorf("missing ] or ,")
+				}
+			}
+		}
+	case "end":
+		p.pos--
+		return nil, fmt.Errorf("unexpected end of rule")
+	}
+	return nil, fmt.Errorf("unexpected %q", token.text)
+}
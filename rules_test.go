@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckTagRules(t *testing.T) {
+	tests := []struct {
+		rule    string
+		branch  string
+		lastTag string
+		tag     string
+		wantErr string
+	}{
+		{"patch < 100", "main", "v1.0.98", "v1.0.99", ""},
+		{"patch < 100", "main", "v1.0.99", "v1.0.100", "tag v1.0.100 breaks the rule patch < 100"},
+		{`bump == "major" implies branch == "main"`, "main", "v1.2.3", "v2.0.0", ""},
+		{`bump == "major" implies branch == "main"`, "gray", "v1.2.3", "v2.0.0", "breaks the rule"},
+		{`bump == "major" implies branch == "main"`, "gray", "v1.2.3", "v1.3.0", ""},
+		{`first || major - lastMajor <= 1`, "main", "", "v5.0.0", ""},
+		{`first || major - lastMajor <= 1`, "main", "v1.2.3", "v3.0.0", "breaks the rule"},
+		{`branch in ["main", "master"] && !(minor % 2 == 1)`, "master", "v1.2.3", "v1.4.0", ""},
+		{`branch in ["main", "master"] && !(minor % 2 == 1)`, "main", "v1.2.3", "v1.3.0", "breaks the rule"},
+		{`branch matches "^release/" || prefix + "1" == "v1"`, "main", "", "v0.0.1", ""},
+		{`(-patch < -1) == false`, "main", "", "v0.0.1", ""},
+		{`tag == lastTag`, "main", "v1.0.0", "v1.0.1", "breaks the rule"},
+		{"patch", "main", "", "v0.0.1", "does not evaluate to true or false"},
+		{`major && minor`, "main", "", "v0.0.1", "needs true or false"},
+		{`major / (minor - minor) == 0`, "main", "", "v1.0.1", "division by zero"},
+		{`patch == "1"`, "main", "", "v0.0.1", "breaks the rule"},
+		{`patch < "1"`, "main", "", "v0.0.1", "cannot be applied to a number (1) and a string"},
+	}
+
+	for _, tt := range tests {
+		err := checkTagRules([]TagRule{{Rule: tt.rule}}, tt.branch, "v0.0.0", tt.lastTag, tt.tag)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("rule %s for %s: unexpected error %v", tt.rule, tt.tag, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("rule %s for %s: error = %v, want %q", tt.rule, tt.tag, err, tt.wantErr)
+		}
+	}
+
+	rules := []TagRule{{Rule: "patch < 10", Message: "Start a new minor version after 9 patches"}}
+	if err := checkTagRules(rules, "main", "v0.0.0", "", "v1.0.10"); err == nil || err.Error() != rules[0].Message {
+		t.Errorf("checkTagRules() error = %v, want the rule's message", err)
+	}
+}
+
+func TestParseTagRuleErrors(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr string
+	}{
+		{"", "unexpected end of rule"},
+		{"patch <", "unexpected end of rule"},
+		{"patch < 100)", `unexpected ")"`},
+		{"major < minor < patch", `unexpected "<"`},
+		{"(patch < 100", "missing )"},
+		{"prerelease", "unknown variable prerelease"},
+		{`branch == "main`, "unterminated string"},
+		{"patch ~ 1", "unexpected '~'"},
+		{`branch matches "("`, "invalid pattern"},
+		{`branch in ["main" "gray"]`, "missing ] or ,"},
+	}
+
+	for _, tt := range tests {
+		_, err := parseTagRule(tt.rule)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("parseTagRule(%q) error = %v, want %q", tt.rule, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// scheduledRelease is a plan waiting to be executed at a given time
+type scheduledRelease struct {
+	At   time.Time `json:"at"`
+	Plan Plan      `json:"plan"`
+}
+
+// scheduleTimeLayouts are the accepted --at formats, interpreted in local time
+var scheduleTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// schedulePollInterval is how often the daemon checks for due releases
+const schedulePollInterval = 30 * time.Second
+
+// runScheduleCommand implements the schedule command
+func runScheduleCommand(args []string) {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	opts := addPublishFlags(fs)
+	at := fs.String("at", "", "time to publish, e.g. 2024-07-01T09:00 (local time)")
+	list := fs.Bool("list", false, "list pending releases")
+	cancel := fs.String("cancel", "", "cancel the pending release of this tag")
+	runDue := fs.Bool("run-due", false, "execute releases that are due and exit (for cron/systemd/launchd)")
+	daemon := fs.Bool("daemon", false, "wait and execute pending releases when they are due")
+	fs.Parse(args)
+
+	switch {
+	case *list:
+		listScheduledReleases()
+	case *cancel != "":
+		cancelScheduledRelease(*cancel)
+	case *runDue:
+		runDueReleases(readConfig)
+	case *daemon:
+		runScheduleDaemon()
+	case *at != "":
+		scheduleRelease(*at, opts)
+	default:
+		fmt.Println("Usage: git-publish schedule --at <time> [flags] | --list | --cancel <tag> | --run-due | --daemon")
+		os.Exit(1)
+	}
+}
+
+// parseScheduleTime parses the --at value in local time
+func parseScheduleTime(value string) (time.Time, error) {
+	for _, layout := range scheduleTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected e.g. 2024-07-01T09:00", value)
+}
+
+// scheduleDir returns the directory holding pending releases inside the git directory
+func scheduleDir() (string, error) {
+	return gitClient.GitPath("git-publish/scheduled")
+}
+
+// scheduleRelease builds a plan now and records it for execution at the given time
+func scheduleRelease(at string, opts *publishOptions) {
+	when, err := parseScheduleTime(at)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if when.Before(time.Now()) {
+		fmt.Printf("Error: %s is in the past\n", when.Format(time.RFC1123))
+		os.Exit(1)
+	}
+
+	config, remoteURLs := preparePublish(opts)
+	plan, ok := buildPlan(config, remoteURLs, opts)
+	if !ok {
+		fmt.Println("Scheduling cancelled.")
+		return
+	}
+
+	dir, err := scheduleDir()
+	if err != nil {
+		fmt.Printf("Error locating git directory: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("Error creating %s: %v\n", dir, err)
+		os.Exit(1)
+	}
+
+	data, err := json.MarshalIndent(scheduledRelease{At: when, Plan: plan}, "", "  ")
+	if err != nil {
+		fmt.Printf("Error encoding scheduled release: %v\n", err)
+		os.Exit(1)
+	}
+	path := filepath.Join(dir, plan.Tag+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		fmt.Printf("Error writing %s: %v\n", path, err)
+		os.Exit(1)
+	}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	fmt.Print(formatPlan(plan))
+	fmt.Printf("Scheduled %s for %s\n", green(plan.Tag), green(formatReleaseTime(when)))
+	// git-publish is usually a shell alias, so timers need the binary path
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "git-publish"
+	}
+	fmt.Println("Execute it with one of:")
+	fmt.Println("  git-publish schedule --daemon    (keep this running until then)")
+	fmt.Printf("  systemd-run --user --on-calendar='%s' --working-directory=%s %s schedule --run-due\n",
+		when.Format("2006-01-02 15:04:05"), currentDir(), executable)
+	fmt.Printf("  a launchd/cron job running '%s schedule --run-due' in %s\n", executable, currentDir())
+}
+
+// currentDir returns the working directory, or "." if it cannot be determined
+func currentDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	return dir
+}
+
+// loadScheduledReleases reads all pending releases ordered by time, keyed by file path
+func loadScheduledReleases() ([]string, map[string]scheduledRelease) {
+	releases := make(map[string]scheduledRelease)
+
+	dir, err := scheduleDir()
+	if err != nil {
+		return nil, releases
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+
+	var valid []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var release scheduledRelease
+		if err := json.Unmarshal(data, &release); err != nil {
+			fmt.Printf("Warning: ignoring unreadable scheduled release %s: %v\n", path, err)
+			continue
+		}
+		releases[path] = release
+		valid = append(valid, path)
+	}
+
+	sort.Slice(valid, func(i, j int) bool { return releases[valid[i]].At.Before(releases[valid[j]].At) })
+	return valid, releases
+}
+
+// listScheduledReleases prints the pending releases
+func listScheduledReleases() {
+	paths, releases := loadScheduledReleases()
+	if len(paths) == 0 {
+		fmt.Println("No pending releases.")
+		return
+	}
+	for _, path := range paths {
+		release := releases[path]
+		remote := "no push"
+		if release.Plan.Remote != "" {
+			remote = "push to " + release.Plan.Remote
+		}
+		fmt.Printf("%s  %s on %s (%s)\n", formatReleaseTime(release.At), release.Plan.Tag, release.Plan.Branch, remote)
+	}
+}
+
+// cancelScheduledRelease removes the pending release of the tag
+func cancelScheduledRelease(tag string) {
+	paths, releases := loadScheduledReleases()
+	for _, path := range paths {
+		if releases[path].Plan.Tag == tag {
+			if err := os.Remove(path); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Cancelled the scheduled release of %s\n", tag)
+			return
+		}
+	}
+	fmt.Printf("Error: No scheduled release for %s\n", tag)
+	os.Exit(1)
+}
+
+// runDueReleases executes every pending release whose time has come, using the
+// configuration returned by loadConfig, and returns the number of releases still pending
+func runDueReleases(loadConfig func() Config) int {
+	paths, releases := loadScheduledReleases()
+	pending := 0
+
+	var config Config
+	loaded := false
+	for _, path := range paths {
+		release := releases[path]
+		if release.At.After(time.Now()) {
+			pending++
+			continue
+		}
+		if !loaded {
+			config = loadConfig()
+			if len(getAllRemoteURLs()) > 0 {
+				fetchRemote(config.Tags)
+			}
+			loaded = true
+		}
+		executeScheduledRelease(path, release, config)
+	}
+
+	return pending
+}
+
+// executeScheduledRelease verifies and publishes a due release and notifies about the outcome.
+// Failed releases are kept with a .failed suffix so they are not retried.
+func executeScheduledRelease(path string, release scheduledRelease, config Config) {
+	plan := release.Plan
+	bus := newEventBus()
+	subscribeNotifications(bus, config.Notifications)
+
+	if problems := verifyPlan(plan); len(problems) > 0 {
+		err := fmt.Errorf("the repository no longer matches the plan: %s", strings.Join(problems, "; "))
+		bus.publish(event{Type: eventPublishFailed, Plan: plan, Error: err.Error()})
+		os.Rename(path, path+".failed")
+		return
+	}
+
+	if err := publishPlan(plan, config, bus); err != nil {
+		os.Rename(path, path+".failed")
+		return
+	}
+	os.Remove(path)
+}
+
+// runScheduleDaemon waits for pending releases and executes them when due,
+// exiting once nothing is left to do. Valid changes of the configuration are
+// picked up without a restart.
+func runScheduleDaemon() {
+	fmt.Println("Waiting for scheduled releases. Press Ctrl+C to stop.")
+	config := readConfig()
+	watcher := newConfigWatcher(nil)
+	for {
+		if reloaded, ok := watcher.reload(); ok {
+			config = reloaded
+		}
+		logFor(logSchedule).Debug("checking for due releases")
+		if runDueReleases(func() Config { return config }) == 0 {
+			fmt.Println("No pending releases left.")
+			return
+		}
+		time.Sleep(schedulePollInterval)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseScheduleTime tests the accepted --at formats
+func TestParseScheduleTime(t *testing.T) {
+	expected := time.Date(2024, 7, 1, 9, 0, 0, 0, time.Local)
+
+	for _, input := range []string{"2024-07-01T09:00", "2024-07-01 09:00", "2024-07-01T09:00:00"} {
+		result, err := parseScheduleTime(input)
+		if err != nil || !result.Equal(expected) {
+			t.Errorf("parseScheduleTime(%q) = %v, %v, expected %v", input, result, err, expected)
+		}
+	}
+
+	if _, err := parseScheduleTime("tomorrow morning"); err == nil {
+		t.Errorf("Expected an error for an unparseable time")
+	}
+}
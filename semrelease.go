@@ -0,0 +1,442 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// semanticReleaseFiles are the configuration files of semantic-release, in
+// the order it looks them up
+var semanticReleaseFiles = []string{
+	"package.json", ".releaserc", ".releaserc.json", ".releaserc.yaml", ".releaserc.yml",
+	".releaserc.js", ".releaserc.cjs", "release.config.js", "release.config.cjs",
+}
+
+// semanticReleaseDefaults are the parts of the semantic-release defaults that
+// apply when a configuration leaves them out
+var semanticReleaseDefaults = semanticReleaseConfig{
+	Branches:  json.RawMessage(`["+([0-9])?(.{+([0-9]),x}).x", "master", "main", "next", "next-major", {"name": "beta", "prerelease": true}, {"name": "alpha", "prerelease": true}]`),
+	TagFormat: "v${version}",
+	Plugins:   json.RawMessage(`["@semantic-release/commit-analyzer", "@semantic-release/release-notes-generator", "@semantic-release/npm", "@semantic-release/github"]`),
+}
+
+// semanticReleaseConfig is the part of a semantic-release configuration that
+// has an equivalent in publish.json
+type semanticReleaseConfig struct {
+	// Branches is a branch or a list of branch names and branch objects
+	Branches  json.RawMessage `json:"branches"`
+	TagFormat string          `json:"tagFormat"`
+	// Plugins lists plugin names and [name, options] pairs
+	Plugins json.RawMessage `json:"plugins"`
+	// Preset is the conventional-changelog preset shared by the plugins
+	Preset string `json:"preset"`
+}
+
+// importedConfig is the publish.json written by an import; unlike Config it
+// leaves out every section that was not imported
+type importedConfig struct {
+	BranchTags []importedBranchTag `json:"branchTags"`
+	Bump       *BumpConfig         `json:"bump,omitempty"`
+	Release    *ReleaseConfig      `json:"release,omitempty"`
+}
+
+// importedBranchTag is a branchTags entry without the optional settings
+type importedBranchTag struct {
+	Branch string `json:"branch"`
+	Tag    string `json:"tag"`
+}
+
+// runImportCommand implements the import command: it writes publish.json from
+// the configuration of another release tool
+func runImportCommand(args []string) {
+	if len(args) == 0 || args[0] != "semantic-release" {
+		fmt.Println("Usage: git-publish import semantic-release [--dry-run] [--force]")
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print the configuration instead of writing it")
+	force := fs.Bool("force", false, "overwrite an existing publish.json")
+	fs.Parse(args[1:])
+
+	root, err := gitClient.TopLevel()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	source, file, err := readSemanticReleaseConfig(root)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Importing %s\n", file)
+
+	config, warnings, err := convertSemanticRelease(source)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, warning := range warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *dryRun {
+		fmt.Println(string(data))
+		return
+	}
+
+	path := filepath.Join(root, configFileName)
+	if _, err := os.Stat(path); err == nil && !*force {
+		fmt.Printf("Error: %s already exists; pass --force to overwrite it\n", path)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s\n", path)
+}
+
+// readSemanticReleaseConfig reads the first semantic-release configuration
+// in root and returns it with the name of its file
+func readSemanticReleaseConfig(root string) (semanticReleaseConfig, string, error) {
+	for _, name := range semanticReleaseFiles {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		config, found, err := parseSemanticReleaseConfig(name, data)
+		if err != nil {
+			return semanticReleaseConfig{}, name, fmt.Errorf("%s: %v", name, err)
+		}
+		if found {
+			return config, name, nil
+		}
+	}
+	return semanticReleaseConfig{}, "", fmt.Errorf("no semantic-release configuration found in %s", root)
+}
+
+// parseSemanticReleaseConfig parses one configuration file. It reports false
+// for a package.json without a "release" key.
+func parseSemanticReleaseConfig(name string, data []byte) (semanticReleaseConfig, bool, error) {
+	var config semanticReleaseConfig
+	switch {
+	case name == "package.json":
+		var pkg struct {
+			Release *semanticReleaseConfig `json:"release"`
+		}
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			return config, false, err
+		}
+		if pkg.Release == nil {
+			return config, false, nil
+		}
+		return *pkg.Release, true, nil
+	case strings.HasSuffix(name, ".js") || strings.HasSuffix(name, ".cjs"):
+		object, err := jsObjectToJSON(string(data))
+		if err != nil {
+			return config, false, err
+		}
+		data = []byte(object)
+	case strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml"):
+		return config, false, fmt.Errorf("YAML configurations are not supported; convert it to .releaserc.json")
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		if name == ".releaserc" {
+			// .releaserc may be YAML as well
+			return config, false, fmt.Errorf("only JSON is supported: %v", err)
+		}
+		return config, false, err
+	}
+	return config, true, nil
+}
+
+// convertSemanticRelease returns the publish.json equivalent of a
+// semantic-release configuration and warnings about what has no equivalent
+func convertSemanticRelease(source semanticReleaseConfig) (importedConfig, []string, error) {
+	var config importedConfig
+	var warnings []string
+	if len(source.Branches) == 0 {
+		source.Branches = semanticReleaseDefaults.Branches
+	}
+	if source.TagFormat == "" {
+		source.TagFormat = semanticReleaseDefaults.TagFormat
+	}
+	if len(source.Plugins) == 0 {
+		source.Plugins = semanticReleaseDefaults.Plugins
+	}
+
+	tagFormat, err := importTagFormat(source.TagFormat)
+	if err != nil {
+		return config, nil, err
+	}
+	branches, skipped, err := importBranches(source.Branches)
+	if err != nil {
+		return config, nil, err
+	}
+	warnings = append(warnings, skipped...)
+	if len(branches) == 0 {
+		return config, nil, fmt.Errorf("no release branch can be imported")
+	}
+	for _, branch := range branches {
+		config.BranchTags = append(config.BranchTags, importedBranchTag{Branch: branch, Tag: tagFormat})
+	}
+
+	plugins, err := importPlugins(source.Plugins)
+	if err != nil {
+		return config, nil, err
+	}
+	for _, plugin := range plugins {
+		preset := stringOption(plugin.Options, "preset")
+		if preset == "" {
+			preset = source.Preset
+		}
+		switch plugin.Name {
+		case "@semantic-release/commit-analyzer":
+			config.Bump = &BumpConfig{Suggest: true}
+			warnings = append(warnings, "the commit analyzer maps to bump.suggest, which suggests versions from the changed paths instead of commit messages")
+		case "@semantic-release/release-notes-generator":
+			if config.Release == nil {
+				config.Release = &ReleaseConfig{}
+			}
+			switch preset {
+			case "":
+				config.Release.Preset = presetAngular
+			case presetAngular, presetConventionalCommits:
+				config.Release.Preset = preset
+			default:
+				warnings = append(warnings, fmt.Sprintf("release notes preset %q is not supported; the notes list every commit", preset))
+			}
+		case "@semantic-release/github", "@semantic-release/gitlab", "@saithodev/semantic-release-gitea":
+			if config.Release == nil {
+				config.Release = &ReleaseConfig{}
+			}
+			config.Release.Create = true
+		default:
+			warnings = append(warnings, fmt.Sprintf("plugin %s is not imported", plugin.Name))
+		}
+	}
+	return config, warnings, nil
+}
+
+// importTagFormat turns a tagFormat such as "v${version}" into a tag format
+func importTagFormat(tagFormat string) (string, error) {
+	prefix, rest, found := strings.Cut(tagFormat, "${version}")
+	format := prefix + "0.0.0"
+	if !found || rest != "" || strings.Contains(prefix, "${") || !validateTagFormat(format, extractPrefix(format)) {
+		return "", fmt.Errorf("tagFormat %q cannot be imported: only a prefix before ${version} is supported", tagFormat)
+	}
+	return format, nil
+}
+
+// importBranches returns the release branches of a branches option and why
+// the others were skipped. Maintenance and pre-release branches need version
+// ranges and pre-release tags, which tag formats do not have.
+func importBranches(raw json.RawMessage) ([]string, []string, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		// A single branch
+		entries = []json.RawMessage{raw}
+	}
+
+	var branches, skipped []string
+	for _, entry := range entries {
+		var branch struct {
+			Name       string          `json:"name"`
+			Range      string          `json:"range"`
+			Prerelease json.RawMessage `json:"prerelease"`
+		}
+		if err := json.Unmarshal(entry, &branch.Name); err != nil {
+			if err := json.Unmarshal(entry, &branch); err != nil {
+				return nil, nil, fmt.Errorf("branches entry %s is neither a name nor a branch object", entry)
+			}
+		}
+		switch {
+		case branch.Name == "":
+			return nil, nil, fmt.Errorf("branches entry %s has no name", entry)
+		case len(branch.Prerelease) > 0 && string(branch.Prerelease) != "false":
+			skipped = append(skipped, fmt.Sprintf("pre-release branch %s is not imported", branch.Name))
+		case branch.Range != "" || strings.ContainsAny(branch.Name, "*?+()[]{}"):
+			skipped = append(skipped, fmt.Sprintf("maintenance branch %s is not imported", branch.Name))
+		default:
+			branches = append(branches, branch.Name)
+		}
+	}
+	return branches, skipped, nil
+}
+
+// semanticReleasePlugin is a plugin name with its options
+type semanticReleasePlugin struct {
+	Name    string
+	Options map[string]interface{}
+}
+
+// importPlugins parses a plugins option
+func importPlugins(raw json.RawMessage) ([]semanticReleasePlugin, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("plugins is not a list")
+	}
+	plugins := make([]semanticReleasePlugin, len(entries))
+	for i, entry := range entries {
+		if err := json.Unmarshal(entry, &plugins[i].Name); err == nil {
+			continue
+		}
+		var pair []json.RawMessage
+		if err := json.Unmarshal(entry, &pair); err != nil || len(pair) == 0 || json.Unmarshal(pair[0], &plugins[i].Name) != nil {
+			return nil, fmt.Errorf("plugins entry %s is neither a name nor [name, options]", entry)
+		}
+		if len(pair) > 1 {
+			json.Unmarshal(pair[1], &plugins[i].Options)
+		}
+	}
+	return plugins, nil
+}
+
+// stringOption returns a string option of a plugin, "" if it is missing
+func stringOption(options map[string]interface{}, key string) string {
+	value, _ := options[key].(string)
+	return value
+}
+
+// jsObjectToJSON converts the object a release.config.js exports into JSON.
+// Only literals are supported: comments, unquoted keys, single-quoted strings
+// and trailing commas are converted, anything computed is an error.
+func jsObjectToJSON(source string) (string, error) {
+	start := strings.Index(source, "{")
+	for _, export := range []string{"module.exports", "export default"} {
+		if i := strings.Index(source, export); i >= 0 {
+			if start = strings.Index(source[i:], "{"); start >= 0 {
+				start += i
+			}
+			break
+		}
+	}
+	if start < 0 {
+		return "", fmt.Errorf("no exported object found")
+	}
+
+	var b strings.Builder
+	depth := 0
+	pendingComma := false
+	for i := start; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case strings.HasPrefix(source[i:], "//"):
+			end := strings.IndexByte(source[i:], '\n')
+			if end < 0 {
+				end = len(source) - i
+			}
+			i += end
+			continue
+		case strings.HasPrefix(source[i:], "/*"):
+			end := strings.Index(source[i+2:], "*/")
+			if end < 0 {
+				return "", fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+			continue
+		}
+
+		// A comma is only written once the next token shows it is not trailing
+		if pendingComma && c != '}' && c != ']' {
+			b.WriteByte(',')
+		}
+		pendingComma = false
+
+		switch {
+		case c == '{' || c == '[':
+			depth++
+			b.WriteByte(c)
+			i++
+		case c == '}' || c == ']':
+			depth--
+			b.WriteByte(c)
+			i++
+			if depth == 0 {
+				return b.String(), nil
+			}
+		case c == ':':
+			b.WriteByte(c)
+			i++
+		case c == ',':
+			pendingComma = true
+			i++
+		case c == '"' || c == '\'' || c == '`':
+			value, end, err := readJSString(source, i)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(strconv.Quote(value))
+			i = end
+		case c == '-' || c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(source) && strings.IndexByte("0123456789.eE+-", source[end]) >= 0 {
+				end++
+			}
+			b.WriteString(source[i:end])
+			i = end
+		case c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			end := i + 1
+			for end < len(source) && (source[end] == '_' || source[end] == '$' || source[end] >= 'a' && source[end] <= 'z' ||
+				source[end] >= 'A' && source[end] <= 'Z' || source[end] >= '0' && source[end] <= '9') {
+				end++
+			}
+			word := source[i:end]
+			switch {
+			case word == "true" || word == "false" || word == "null":
+				b.WriteString(word)
+			case strings.HasPrefix(strings.TrimLeft(source[end:], " \t\r\n"), ":"):
+				b.WriteString(strconv.Quote(word))
+			default:
+				return "", fmt.Errorf("%s is not a literal; only JSON-expressible configurations can be imported", word)
+			}
+			i = end
+		default:
+			return "", fmt.Errorf("unexpected %q; only JSON-expressible configurations can be imported", c)
+		}
+	}
+	return "", fmt.Errorf("unterminated object")
+}
+
+// readJSString reads the JavaScript string literal starting at source[start]
+// and returns its value and the index after it
+func readJSString(source string, start int) (string, int, error) {
+	quote := source[start]
+	var b strings.Builder
+	for i := start + 1; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '$' && quote == '`' && strings.HasPrefix(source[i:], "${"):
+			return "", 0, fmt.Errorf("template literal with ${...} is not a literal value")
+		case c == '\\' && i+1 < len(source):
+			i++
+			switch source[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				// \', \", \\ and \$ stand for the character itself
+				b.WriteByte(source[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
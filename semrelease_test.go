@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSObjectToJSON(t *testing.T) {
+	source := `// Release configuration
+module.exports = {
+  branches: ['main', { name: 'beta', prerelease: true },],
+  /* the default with a "v" */
+  tagFormat: 'v${version}',
+  plugins: [
+    ["@semantic-release/commit-analyzer", { preset: "conventionalcommits", releaseRules: [{ type: 'docs', release: false }] }],
+    '@semantic-release/github',
+  ],
+};
+`
+	got, err := jsObjectToJSON(source)
+	if err != nil {
+		t.Fatalf("jsObjectToJSON() error = %v", err)
+	}
+	var value map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &value); err != nil {
+		t.Fatalf("jsObjectToJSON() = %s, not JSON: %v", got, err)
+	}
+	if value["tagFormat"] != "v${version}" {
+		t.Errorf("tagFormat = %v", value["tagFormat"])
+	}
+
+	for _, source := range []string{
+		"module.exports = { branches: require('./branches') }",
+		"module.exports = { tagFormat: `${prefix}${version}` }",
+		"module.exports = { branches: ['main'",
+	} {
+		if got, err := jsObjectToJSON(source); err == nil {
+			t.Errorf("jsObjectToJSON(%q) = %s, want an error", source, got)
+		}
+	}
+}
+
+func TestConvertSemanticRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		want     string
+		warnings int
+	}{
+		{"defaults", `{}`,
+			`{"branchTags":[{"branch":"master","tag":"v0.0.0"},{"branch":"main","tag":"v0.0.0"},{"branch":"next","tag":"v0.0.0"},{"branch":"next-major","tag":"v0.0.0"}],"bump":{"suggest":true},"release":{"create":true,"preset":"angular"}}`, 5},
+		{"single branch and prefix", `{"branches": "release", "tagFormat": "api-${version}", "plugins": ["@semantic-release/release-notes-generator"], "preset": "conventionalcommits"}`,
+			`{"branchTags":[{"branch":"release","tag":"api-0.0.0"}],"release":{"preset":"conventionalcommits"}}`, 0},
+		{"maintenance and unknown preset", `{"branches": [{"name": "1.x", "range": "1.x"}, "main", {"name": "rc", "prerelease": "rc"}], "plugins": [["@semantic-release/release-notes-generator", {"preset": "eslint"}], "@semantic-release/gitlab"]}`,
+			`{"branchTags":[{"branch":"main","tag":"v0.0.0"}],"release":{"create":true}}`, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var source semanticReleaseConfig
+			if err := json.Unmarshal([]byte(tt.source), &source); err != nil {
+				t.Fatal(err)
+			}
+			config, warnings, err := convertSemanticRelease(source)
+			if err != nil {
+				t.Fatalf("convertSemanticRelease() error = %v", err)
+			}
+			got, _ := json.Marshal(config)
+			if string(got) != tt.want || len(warnings) != tt.warnings {
+				t.Errorf("convertSemanticRelease() = %s, %q, want %s with %d warnings", got, warnings, tt.want, tt.warnings)
+			}
+		})
+	}
+
+	for _, source := range []semanticReleaseConfig{
+		{TagFormat: "${version}-stable"},
+		{Branches: json.RawMessage(`[{"name": "beta", "prerelease": true}]`)},
+		{Plugins: json.RawMessage(`"@semantic-release/github"`)},
+	} {
+		if _, _, err := convertSemanticRelease(source); err == nil {
+			t.Errorf("convertSemanticRelease(%+v) succeeded", source)
+		}
+	}
+}
+
+func TestReadSemanticReleaseConfig(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "package.json"), []byte(`{"name": "tool"}`), 0644)
+	if _, _, err := readSemanticReleaseConfig(root); err == nil {
+		t.Errorf("readSemanticReleaseConfig() without a configuration succeeded")
+	}
+
+	os.WriteFile(filepath.Join(root, "release.config.js"), []byte(`module.exports = { branches: ['main'] }`), 0644)
+	config, file, err := readSemanticReleaseConfig(root)
+	if err != nil || file != "release.config.js" || !reflect.DeepEqual(config.Branches, json.RawMessage(`["main"]`)) {
+		t.Errorf("readSemanticReleaseConfig() = %+v, %q, %v", config, file, err)
+	}
+
+	// The release key of package.json comes first, YAML is refused
+	os.WriteFile(filepath.Join(root, "package.json"), []byte(`{"name": "tool", "release": {"tagFormat": "tool-${version}"}}`), 0644)
+	if config, file, _ := readSemanticReleaseConfig(root); file != "package.json" || config.TagFormat != "tool-${version}" {
+		t.Errorf("readSemanticReleaseConfig() = %+v, %q, want package.json", config, file)
+	}
+	os.Remove(filepath.Join(root, "package.json"))
+	os.WriteFile(filepath.Join(root, ".releaserc"), []byte("branches:\n  - main\n"), 0644)
+	if _, _, err := readSemanticReleaseConfig(root); err == nil || !strings.Contains(err.Error(), "only JSON") {
+		t.Errorf("readSemanticReleaseConfig() of YAML = %v, want an error", err)
+	}
+}
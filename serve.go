@@ -0,0 +1,409 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServeConfig represents the release bot run by the serve command
+type ServeConfig struct {
+	// Branches are tagged when pushed to (default: every configured branch)
+	Branches []string `json:"branches,omitempty"`
+	// Remote receives the tags (default: origin)
+	Remote string `json:"remote,omitempty"`
+	// DryRun only notifies about the tags that would be created
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// webhookSecretEnv holds the secret shared with the hosting service
+const webhookSecretEnv = "GIT_PUBLISH_WEBHOOK_SECRET"
+
+// profileTokenEnv holds the token of the Go profiler that serve serves with
+// --profile-perf; without it the profiler is not served
+const profileTokenEnv = "GIT_PUBLISH_PROFILE_TOKEN"
+
+// maxWebhookBody bounds the payloads read from the network
+const maxWebhookBody = 5 << 20
+
+// errNothingToRelease reports a branch without commits since its last tag
+var errNothingToRelease = errors.New("nothing to release")
+
+// webhookEvent is a push to, or a merge into, a branch
+type webhookEvent struct {
+	Provider string
+	Branch   string
+	Commit   string
+}
+
+// webhookServer tags the branches named by incoming webhooks
+type webhookServer struct {
+	mu         sync.Mutex
+	config     Config
+	remoteURLs map[string]string
+	secret     string
+	// release tags the branch; run in the background and replaced in tests
+	release func(branch string)
+	// name labels the metrics of the repository
+	name    string
+	metrics *releaseMetrics
+	// profileToken guards the profiler, see profileTokenEnv
+	profileToken string
+}
+
+// runServeCommand implements the serve command: it receives push and merge
+// webhooks of the hosting service and publishes the next tag of the branch
+func runServeCommand(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", ":8643", "address to listen on")
+	var branches stringsFlag
+	fs.Var(&branches, "branch", "branch tagged when pushed to, repeatable (default: serve.branches, else every configured branch)")
+	remote := fs.String("remote", "", "remote the tags are pushed to (default: serve.remote, else origin)")
+	dryRun := fs.Bool("dry-run", false, "only notify about the tags that would be created")
+	repos := fs.String("repos", "", "repositories file; serves every repository listed there from clones in its work dir")
+	fs.Parse(args)
+
+	// Flags win over the serve configuration, also after a reload
+	applyFlags := func(config Config) Config {
+		if len(branches) > 0 {
+			config.Serve.Branches = branches
+		}
+		if *remote != "" {
+			config.Serve.Remote = *remote
+		}
+		if *dryRun {
+			config.Serve.DryRun = true
+		}
+		if config.Serve.Remote == "" {
+			config.Serve.Remote = "origin"
+		}
+		return config
+	}
+	if *repos != "" {
+		if len(branches) > 0 {
+			fmt.Println("Error: --branch cannot be used with --repos; set serve.branches of the repository instead")
+			os.Exit(1)
+		}
+		runServeRepositories(*addr, *repos, applyFlags)
+		return
+	}
+
+	secret := os.Getenv(webhookSecretEnv)
+	if secret == "" {
+		fmt.Printf("Error: set %s to the secret configured for the webhook\n", webhookSecretEnv)
+		os.Exit(1)
+	}
+	remoteURLs := getAllRemoteURLs()
+	if _, ok := remoteURLs["origin"]; !ok {
+		fmt.Println("Error: serve needs the origin remote to fetch the pushed branches from")
+		os.Exit(1)
+	}
+	config := applyFlags(readConfig())
+	if _, ok := remoteURLs[config.Serve.Remote]; !ok {
+		fmt.Printf("Error: remote '%s' not found\n", config.Serve.Remote)
+		os.Exit(1)
+	}
+
+	server := &webhookServer{config: config, remoteURLs: remoteURLs, secret: secret, name: metricsRepositoryName(remoteURLs), metrics: newReleaseMetrics(), profileToken: serveProfileToken(*addr)}
+	server.release = server.releaseBranch
+
+	go watchConfig(nil, func(reloaded Config) {
+		server.mu.Lock()
+		server.config = applyFlags(reloaded)
+		server.mu.Unlock()
+	}, nil)
+
+	go scheduleReminders(server.remind)
+
+	mode := ""
+	if config.Serve.DryRun {
+		mode = " (dry run)"
+	}
+	fmt.Printf("Receiving webhooks on %s/webhook%s, metrics on %s/metrics. Press Ctrl+C to stop.\n", *addr, mode, *addr)
+	if err := http.ListenAndServe(*addr, server.handler()); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// serveProfileToken returns the token of the profiler served on addr with
+// --profile-perf, telling how to reach it or how to turn it on
+func serveProfileToken(addr string) string {
+	if perf == nil {
+		return ""
+	}
+	token := os.Getenv(profileTokenEnv)
+	if token == "" {
+		fmt.Printf("Warning: set %s to serve the Go profiler under /debug/pprof/\n", profileTokenEnv)
+		return ""
+	}
+	fmt.Printf("Serving the Go profiler on %s/debug/pprof/ with the token of %s\n", addr, profileTokenEnv)
+	return token
+}
+
+// handler returns the HTTP handler of the webhook receiver
+func (s *webhookServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", s.handleWebhook)
+	if s.metrics != nil {
+		mux.HandleFunc("/metrics", s.metrics.handler)
+	}
+	registerProfiler(mux, s.profileToken)
+	return logRequests(mux)
+}
+
+func (s *webhookServer) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST"))
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := verifyWebhook(r.Header, body, s.secret); err != nil {
+		writeJSONError(w, http.StatusUnauthorized, err)
+		return
+	}
+	e, ok, err := parseWebhook(r.Header, body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !ok {
+		writeJSON(w, map[string]string{"ignored": "not a push or merge into a branch"})
+		return
+	}
+
+	s.mu.Lock()
+	served := s.serves(e.Branch)
+	s.mu.Unlock()
+	if !served {
+		writeJSON(w, map[string]string{"ignored": fmt.Sprintf("branch %s is not tagged automatically", e.Branch)})
+		return
+	}
+	logFor(logServe).Info("webhook", "provider", e.Provider, "branch", e.Branch, "commit", e.Commit)
+	go s.release(e.Branch)
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, map[string]string{"queued": e.Branch})
+}
+
+// serves reports whether pushes to branch are tagged
+func (s *webhookServer) serves(branch string) bool {
+	if len(s.config.Serve.Branches) > 0 && !contains(s.config.Serve.Branches, branch) {
+		return false
+	}
+	for _, bt := range s.config.BranchTags {
+		if bt.Branch == branch {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyWebhook checks that the request was signed with the shared secret:
+// an HMAC of the body on GitHub and Gitea, the secret itself on GitLab
+func verifyWebhook(header http.Header, body []byte, secret string) error {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	switch {
+	case header.Get("X-Gitea-Signature") != "":
+		if hmac.Equal([]byte(header.Get("X-Gitea-Signature")), []byte(expected)) {
+			return nil
+		}
+	case header.Get("X-Hub-Signature-256") != "":
+		if hmac.Equal([]byte(header.Get("X-Hub-Signature-256")), []byte("sha256="+expected)) {
+			return nil
+		}
+	case header.Get("X-Gitlab-Token") != "":
+		if subtle.ConstantTimeCompare([]byte(header.Get("X-Gitlab-Token")), []byte(secret)) == 1 {
+			return nil
+		}
+	default:
+		return fmt.Errorf("the request is not signed")
+	}
+	return fmt.Errorf("invalid webhook signature")
+}
+
+// webhookPayload holds the fields of push and merge payloads of GitHub,
+// Gitea and GitLab
+type webhookPayload struct {
+	// Push events
+	Ref     string `json:"ref"`
+	After   string `json:"after"`
+	Deleted bool   `json:"deleted"`
+	// GitHub and Gitea pull request events
+	Action      string `json:"action"`
+	PullRequest struct {
+		Merged         bool   `json:"merged"`
+		MergeCommitSHA string `json:"merge_commit_sha"`
+		Base           struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	// GitLab merge request events
+	ObjectAttributes struct {
+		Action         string `json:"action"`
+		TargetBranch   string `json:"target_branch"`
+		MergeCommitSHA string `json:"merge_commit_sha"`
+	} `json:"object_attributes"`
+}
+
+// parseWebhook returns the branch a webhook reports changes on; false for
+// other events, such as pings, tag pushes, branch deletions and closed but
+// unmerged pull requests
+func parseWebhook(header http.Header, body []byte) (webhookEvent, bool, error) {
+	var provider, kind string
+	switch {
+	case header.Get("X-Gitea-Event") != "":
+		provider, kind = providerGitea, header.Get("X-Gitea-Event")
+	case header.Get("X-GitHub-Event") != "":
+		provider, kind = providerGitHub, header.Get("X-GitHub-Event")
+	case header.Get("X-Gitlab-Event") != "":
+		provider, kind = providerGitLab, header.Get("X-Gitlab-Event")
+	default:
+		return webhookEvent{}, false, fmt.Errorf("unknown webhook, expected a GitHub, GitLab or Gitea event")
+	}
+
+	var payload webhookPayload
+	switch kind {
+	case "push", "Push Hook", "pull_request", "Merge Request Hook":
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return webhookEvent{}, false, fmt.Errorf("parsing the %s payload: %v", kind, err)
+		}
+	default:
+		return webhookEvent{}, false, nil
+	}
+
+	e := webhookEvent{Provider: provider}
+	switch kind {
+	case "push", "Push Hook":
+		if !strings.HasPrefix(payload.Ref, "refs/heads/") || payload.Deleted || strings.Trim(payload.After, "0") == "" {
+			return e, false, nil
+		}
+		e.Branch, e.Commit = strings.TrimPrefix(payload.Ref, "refs/heads/"), payload.After
+	case "pull_request":
+		if payload.Action != "closed" || !payload.PullRequest.Merged {
+			return e, false, nil
+		}
+		e.Branch, e.Commit = payload.PullRequest.Base.Ref, payload.PullRequest.MergeCommitSHA
+	default:
+		if payload.ObjectAttributes.Action != "merge" {
+			return e, false, nil
+		}
+		e.Branch, e.Commit = payload.ObjectAttributes.TargetBranch, payload.ObjectAttributes.MergeCommitSHA
+	}
+	return e, e.Branch != "", nil
+}
+
+// remind notifies about the overdue branches of the served repository
+func (s *webhookServer) remind(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.config.Remind.enabled() {
+		return
+	}
+	fetchRemote(s.config.Tags)
+	remindOverdue(s.config, now)
+}
+
+// releaseBranch publishes the next tag of every format of branch and notifies
+// about the outcome. Releases run one at a time; a push and the merge that
+// caused it tag once, since the second finds nothing to release.
+func (s *webhookServer) releaseBranch(branch string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	config := s.config
+	bus := newEventBus()
+	subscribeNotifications(bus, config.Notifications)
+	if s.metrics != nil {
+		s.metrics.subscribe(bus, s.name)
+	}
+	failed := func(tag string, err error) {
+		if s.metrics != nil {
+			s.metrics.failure(s.name)
+		}
+		sendNotification(config.Notifications, notification{
+			Event:   "release.failed",
+			Tag:     tag,
+			Branch:  branch,
+			Remote:  config.Serve.Remote,
+			Message: fmt.Sprintf("Publishing %s failed: %v", branch, err),
+		})
+	}
+
+	// Only fast-forwards move the local branch; a checked-out or diverged
+	// branch fails here instead of being released at a stale commit
+	fetchRemote(config.Tags)
+	if err := gitClient.Fetch("--no-tags", "origin", "refs/heads/"+branch+":refs/heads/"+branch); err != nil {
+		failed("", fmt.Errorf("updating the local branch: %v", err))
+		return
+	}
+
+	for _, bt := range config.BranchTags {
+		if bt.Branch != branch {
+			continue
+		}
+		plan, err := autoPlan(config, s.remoteURLs, bt)
+		switch {
+		case errors.Is(err, errNothingToRelease):
+			fmt.Printf("Nothing to release on %s since %s\n", branch, plan.LastTag)
+			continue
+		case err != nil:
+			failed(plan.Tag, err)
+			continue
+		}
+		if config.Serve.DryRun {
+			sendNotification(config.Notifications, notification{
+				Event:   "release.planned",
+				Tag:     plan.Tag,
+				Branch:  branch,
+				Remote:  plan.Remote,
+				Message: fmt.Sprintf("Would publish %s on %s (dry run)", plan.Tag, branch),
+			})
+			continue
+		}
+		publishPlan(plan, config, bus)
+	}
+}
+
+// autoPlan returns the plan of the next tag of bt, suggested as in the
+// interactive flow, or errNothingToRelease when no commits follow the last tag
+func autoPlan(config Config, remoteURLs map[string]string, bt BranchTagConfig) (Plan, error) {
+	lastTag, err := releasedTag(config, bt, "")
+	if err != nil {
+		return Plan{}, err
+	}
+	if lastTag != "" {
+		commits, err := gitClient.Commits("refs/tags/"+lastTag, branchRef(bt.Branch))
+		if err != nil {
+			return Plan{}, fmt.Errorf("listing the commits since %s: %v", lastTag, err)
+		}
+		if len(commits) == 0 {
+			return Plan{LastTag: lastTag}, errNothingToRelease
+		}
+	}
+	tag, err := suggestNextTag(config, bt, lastTag)
+	if err != nil {
+		return Plan{}, err
+	}
+	plan, err := checkedPlan(config, remoteURLs, bt, lastTag, tag, config.Serve.Remote, "", false)
+	if err != nil {
+		return Plan{Tag: tag}, err
+	}
+	return plan, nil
+}
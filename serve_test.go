@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func signWebhook(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	body := `{"ref":"refs/heads/main"}`
+	tests := []struct {
+		name    string
+		header  map[string]string
+		wantErr string
+	}{
+		{"github", map[string]string{"X-Hub-Signature-256": "sha256=" + signWebhook("s3cret", body)}, ""},
+		{"github wrong secret", map[string]string{"X-Hub-Signature-256": "sha256=" + signWebhook("other", body)}, "invalid webhook signature"},
+		{"gitea", map[string]string{"X-Gitea-Signature": signWebhook("s3cret", body)}, ""},
+		{"gitlab", map[string]string{"X-Gitlab-Token": "s3cret"}, ""},
+		{"gitlab wrong token", map[string]string{"X-Gitlab-Token": "guess"}, "invalid webhook signature"},
+		{"unsigned", map[string]string{"X-GitHub-Event": "push"}, "not signed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.header {
+				header.Set(k, v)
+			}
+			err := verifyWebhook(header, []byte(body), "s3cret")
+			if (tt.wantErr == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("verifyWebhook() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	tests := []struct {
+		name       string
+		event      string
+		kind       string
+		body       string
+		wantBranch string
+		wantErr    bool
+	}{
+		{"github push", "X-GitHub-Event", "push", `{"ref":"refs/heads/main","after":"abc123"}`, "main", false},
+		{"gitea push", "X-Gitea-Event", "push", `{"ref":"refs/heads/release/1.2","after":"abc123"}`, "release/1.2", false},
+		{"gitlab push", "X-Gitlab-Event", "Push Hook", `{"ref":"refs/heads/main","after":"abc123"}`, "main", false},
+		{"tag push", "X-GitHub-Event", "push", `{"ref":"refs/tags/v1.0.0","after":"abc123"}`, "", false},
+		{"branch deleted", "X-Gitlab-Event", "Push Hook", `{"ref":"refs/heads/main","after":"0000000000000000000000000000000000000000"}`, "", false},
+		{"github merge", "X-GitHub-Event", "pull_request", `{"action":"closed","pull_request":{"merged":true,"merge_commit_sha":"abc","base":{"ref":"main"}}}`, "main", false},
+		{"github closed unmerged", "X-GitHub-Event", "pull_request", `{"action":"closed","pull_request":{"merged":false,"base":{"ref":"main"}}}`, "", false},
+		{"gitlab merge", "X-Gitlab-Event", "Merge Request Hook", `{"object_attributes":{"action":"merge","target_branch":"gray"}}`, "gray", false},
+		{"gitlab opened", "X-Gitlab-Event", "Merge Request Hook", `{"object_attributes":{"action":"open","target_branch":"gray"}}`, "", false},
+		{"ping", "X-GitHub-Event", "ping", `{"zen":"Keep it simple."}`, "", false},
+		{"bad json", "X-GitHub-Event", "push", `{`, "", true},
+		{"unknown service", "X-Other-Event", "push", `{}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(tt.event, tt.kind)
+			e, ok, err := parseWebhook(header, []byte(tt.body))
+			if (err != nil) != tt.wantErr || ok != (tt.wantBranch != "") || e.Branch != tt.wantBranch {
+				t.Errorf("parseWebhook() = %+v, %v, %v, want branch %q", e, ok, err, tt.wantBranch)
+			}
+		})
+	}
+}
+
+func TestWebhookServerQueuesServedBranches(t *testing.T) {
+	released := make(chan string, 1)
+	s := &webhookServer{
+		config: Config{
+			BranchTags: []BranchTagConfig{{Branch: "main", Tag: "v0.0.0"}, {Branch: "gray", Tag: "g0.0.0"}},
+			Serve:      ServeConfig{Branches: []string{"main"}},
+		},
+		secret:  "s3cret",
+		release: func(branch string) { released <- branch },
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"served branch", `{"ref":"refs/heads/main","after":"abc123"}`, "s3cret", http.StatusAccepted, `"queued":"main"`},
+		{"branch not served", `{"ref":"refs/heads/gray","after":"abc123"}`, "s3cret", http.StatusOK, "not tagged automatically"},
+		{"unconfigured branch", `{"ref":"refs/heads/dev","after":"abc123"}`, "s3cret", http.StatusOK, "not tagged automatically"},
+		{"wrong token", `{"ref":"refs/heads/main","after":"abc123"}`, "guess", http.StatusUnauthorized, "invalid webhook signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/webhook", strings.NewReader(tt.body))
+			req.Header.Set("X-Gitlab-Event", "Push Hook")
+			req.Header.Set("X-Gitlab-Token", tt.token)
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("POST /webhook = %d %s, want %d with %s", rec.Code, rec.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusAccepted {
+				if branch := <-released; branch != "main" {
+					t.Errorf("released %s, want main", branch)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookServerProfiler(t *testing.T) {
+	useProfiling(t)
+	tests := []struct {
+		name  string
+		token string
+		url   string
+		want  int
+	}{
+		{"token", "s3cret", "/debug/pprof/?token=s3cret", http.StatusOK},
+		{"wrong token", "s3cret", "/debug/pprof/?token=guess", http.StatusForbidden},
+		{"without a token", "s3cret", "/debug/pprof/", http.StatusForbidden},
+		// Without a token set the profiler is not served at all
+		{"no token set", "", "/debug/pprof/", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &webhookServer{secret: "webhook", profileToken: tt.token}
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if rec.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.url, rec.Code, tt.want)
+			}
+		})
+	}
+}
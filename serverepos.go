@@ -0,0 +1,403 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServeRepositories is the repositories file of serve --repos: the
+// repositories one shared instance releases
+type ServeRepositories struct {
+	// WorkDir holds a clone of every repository, named after it
+	WorkDir      string            `json:"workDir"`
+	Repositories []ServeRepository `json:"repositories"`
+}
+
+// ServeRepository is a repository released by a shared serve instance. Its
+// own publish.json applies, with Serve on top.
+type ServeRepository struct {
+	// Name identifies the repository in the URLs, e.g. /webhook/web
+	Name string `json:"name"`
+	// URL is cloned and fetched from as origin
+	URL string `json:"url"`
+	// SecretEnv names the variable holding the webhook secret (default: GIT_PUBLISH_WEBHOOK_SECRET)
+	SecretEnv string `json:"secretEnv,omitempty"`
+	// TokenEnv names the variable holding the API token of the hosting service
+	TokenEnv string `json:"tokenEnv,omitempty"`
+	// SSHKey is the private key git uses for this repository
+	SSHKey string      `json:"sshKey,omitempty"`
+	Serve  ServeConfig `json:"serve,omitempty"`
+}
+
+// repositoryNamePattern keeps names usable as a URL path segment and a directory
+var repositoryNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// repositoryLock serializes the work on the clones: it changes into the clone
+// and sets its credentials in the environment, both shared by the process
+var repositoryLock sync.Mutex
+
+// readServeRepositories reads and checks the repositories file at path. A
+// relative work dir is relative to the file.
+func readServeRepositories(path string) (ServeRepositories, error) {
+	var repos ServeRepositories
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return repos, err
+	}
+	if err := json.Unmarshal(data, &repos); err != nil {
+		return repos, fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	var problems []string
+	if repos.WorkDir == "" {
+		problems = append(problems, "workDir is empty")
+	} else if !filepath.IsAbs(repos.WorkDir) {
+		repos.WorkDir = filepath.Join(filepath.Dir(path), repos.WorkDir)
+	}
+	if len(repos.Repositories) == 0 {
+		problems = append(problems, "repositories is empty")
+	}
+	seen := map[string]bool{}
+	for _, repo := range repos.Repositories {
+		switch {
+		case !repositoryNamePattern.MatchString(repo.Name):
+			problems = append(problems, fmt.Sprintf("repository name %q is not letters, digits, ., _ and -", repo.Name))
+		case seen[repo.Name]:
+			problems = append(problems, fmt.Sprintf("repository %s is listed twice", repo.Name))
+		case repo.URL == "":
+			problems = append(problems, fmt.Sprintf("repository %s has no url", repo.Name))
+		}
+		seen[repo.Name] = true
+	}
+	if len(problems) > 0 {
+		return repos, fmt.Errorf("invalid repositories file %s: %s", path, strings.Join(problems, "; "))
+	}
+	return repos, nil
+}
+
+// secret returns the webhook secret of repo, empty when it is not set
+func (repo ServeRepository) secret() string {
+	if repo.SecretEnv != "" {
+		return os.Getenv(repo.SecretEnv)
+	}
+	return os.Getenv(webhookSecretEnv)
+}
+
+// env returns the variables set while working on repo: its token for every
+// hosting service and its SSH key for git
+func (repo ServeRepository) env() map[string]string {
+	env := map[string]string{}
+	if token := os.Getenv(repo.TokenEnv); repo.TokenEnv != "" && token != "" {
+		for _, names := range providerTokenEnv {
+			for _, name := range names {
+				env[name] = token
+			}
+		}
+	}
+	if repo.SSHKey != "" {
+		env["GIT_SSH_COMMAND"] = fmt.Sprintf("ssh -i '%s' -o IdentitiesOnly=yes", repo.SSHKey)
+	}
+	return env
+}
+
+// repositoryServer releases the repositories of a repositories file, each
+// from its clone in the work dir
+type repositoryServer struct {
+	workDir string
+	repos   map[string]ServeRepository
+	// applyFlags puts the serve flags over the configuration of a repository
+	applyFlags func(Config) Config
+	// release tags a branch of a repository; run in the background and replaced in tests
+	release func(repo ServeRepository, branch string)
+	metrics *releaseMetrics
+	// profileToken guards the profiler, see profileTokenEnv
+	profileToken string
+}
+
+// runServeRepositories serves the repositories of the file at path on addr
+func runServeRepositories(addr, path string, applyFlags func(Config) Config) {
+	repos, err := readServeRepositories(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	server := &repositoryServer{workDir: repos.WorkDir, repos: map[string]ServeRepository{}, applyFlags: applyFlags, metrics: newReleaseMetrics(), profileToken: serveProfileToken(addr)}
+	server.release = server.releaseRepository
+	for _, repo := range repos.Repositories {
+		if repo.secret() == "" {
+			name := repo.SecretEnv
+			if name == "" {
+				name = webhookSecretEnv
+			}
+			fmt.Printf("Error: set %s to the webhook secret of %s\n", name, repo.Name)
+			os.Exit(1)
+		}
+		server.repos[repo.Name] = repo
+	}
+	if err := os.MkdirAll(repos.WorkDir, 0755); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, repo := range repos.Repositories {
+		fmt.Printf("Preparing %s in %s\n", repo.Name, server.dir(repo))
+		if err := server.refresh(repo); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var names []string
+	for _, repo := range repos.Repositories {
+		names = append(names, repo.Name)
+	}
+	go scheduleReminders(server.remind)
+
+	fmt.Printf("Receiving webhooks on %s/webhook/<name> for %s, metrics on %s/metrics. Press Ctrl+C to stop.\n", addr, strings.Join(names, ", "), addr)
+	if err := http.ListenAndServe(addr, server.handler()); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// handler returns the HTTP handler receiving the webhooks and refresh
+// requests of every repository
+func (s *repositoryServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook/", s.handleWebhook)
+	mux.HandleFunc("/refresh/", s.handleRefresh)
+	if s.metrics != nil {
+		mux.HandleFunc("/metrics", s.metrics.handler)
+	}
+	registerProfiler(mux, s.profileToken)
+	return logRequests(mux)
+}
+
+// repository returns the repository named in the request path after route
+func (s *repositoryServer) repository(w http.ResponseWriter, r *http.Request, route string) (ServeRepository, bool) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST"))
+		return ServeRepository{}, false
+	}
+	name := strings.TrimPrefix(r.URL.Path, route)
+	repo, ok := s.repos[name]
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("unknown repository %q", name))
+	}
+	return repo, ok
+}
+
+func (s *repositoryServer) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	repo, ok := s.repository(w, r, "/webhook/")
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := verifyWebhook(r.Header, body, repo.secret()); err != nil {
+		writeJSONError(w, http.StatusUnauthorized, err)
+		return
+	}
+	e, ok, err := parseWebhook(r.Header, body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !ok {
+		writeJSON(w, map[string]string{"ignored": "not a push or merge into a branch"})
+		return
+	}
+	// Whether the branch is tagged is decided by the repository's own
+	// configuration, read from the clone once it is refreshed
+	logFor(logServe).Info("webhook", "repository", repo.Name, "provider", e.Provider, "branch", e.Branch, "commit", e.Commit)
+	go s.release(repo, e.Branch)
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, map[string]string{"queued": repo.Name + " " + e.Branch})
+}
+
+// handleRefresh updates the clone of a repository, e.g. after a force push or
+// a change of its publish.json. The webhook secret is the bearer token.
+func (s *repositoryServer) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	repo, ok := s.repository(w, r, "/refresh/")
+	if !ok {
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(repo.secret())) != 1 {
+		writeJSONError(w, http.StatusUnauthorized, fmt.Errorf("invalid token"))
+		return
+	}
+	if err := s.refresh(repo); err != nil {
+		writeJSONError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, map[string]string{"refreshed": repo.Name})
+}
+
+// dir returns the clone of repo
+func (s *repositoryServer) dir(repo ServeRepository) string {
+	return filepath.Join(s.workDir, repo.Name)
+}
+
+// refresh clones repo when the work dir has no clone yet, else fetches it
+func (s *repositoryServer) refresh(repo ServeRepository) error {
+	repositoryLock.Lock()
+	defer repositoryLock.Unlock()
+	return s.refreshLocked(repo)
+}
+
+// refreshLocked is refresh with repositoryLock held. HEAD is detached at the
+// default branch of origin, so the local branches can be fast-forwarded and
+// publish.json is the current one.
+func (s *repositoryServer) refreshLocked(repo ServeRepository) error {
+	dir := s.dir(repo)
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		err := withEnv(repo.env(), func() error {
+			return gitClient.Clone(repo.URL, dir)
+		})
+		if err != nil {
+			return fmt.Errorf("cloning %s: %v", repo.Name, err)
+		}
+	}
+	return s.inRepository(repo, func() error {
+		if err := gitClient.Fetch("--quiet", "--prune", "origin"); err != nil {
+			return fmt.Errorf("fetching %s: %v", repo.Name, err)
+		}
+		gitClient.SetRemoteHead("origin")
+		if err := gitClient.CheckoutDetached("refs/remotes/origin/HEAD"); err != nil {
+			return fmt.Errorf("checking out %s: %v", repo.Name, err)
+		}
+		return nil
+	})
+}
+
+// inRepository runs fn in the clone of repo with its credentials set, and
+// changes back afterwards. repositoryLock has to be held.
+func (s *repositoryServer) inRepository(repo ServeRepository, fn func() error) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(s.dir(repo)); err != nil {
+		return err
+	}
+	defer os.Chdir(cwd)
+	return withEnv(repo.env(), fn)
+}
+
+// releaseRepository refreshes the clone of repo and releases branch with the
+// configuration found there, like serve does in a single clone
+func (s *repositoryServer) releaseRepository(repo ServeRepository, branch string) {
+	repositoryLock.Lock()
+	defer repositoryLock.Unlock()
+	if err := s.refreshLocked(repo); err != nil {
+		logFor(logServe).Error("refresh failed", "repository", repo.Name, "error", err)
+		if s.metrics != nil {
+			s.metrics.failure(repo.Name)
+		}
+		return
+	}
+	err := s.inRepository(repo, func() error {
+		config, err := s.repositoryConfig(repo)
+		if err != nil {
+			return err
+		}
+		single := &webhookServer{config: config, remoteURLs: getAllRemoteURLs(), name: repo.Name, metrics: s.metrics}
+		if !single.serves(branch) {
+			fmt.Printf("%s: branch %s is not tagged automatically\n", repo.Name, branch)
+			return nil
+		}
+		fmt.Printf("Releasing %s of %s\n", branch, repo.Name)
+		single.releaseBranch(branch)
+		return nil
+	})
+	if err != nil {
+		logFor(logServe).Error("release failed", "repository", repo.Name, "branch", branch, "error", err)
+	}
+}
+
+// repositoryConfig reads the configuration of repo in its clone, with the
+// serve settings of the repositories file and the flags applied
+func (s *repositoryServer) repositoryConfig(repo ServeRepository) (Config, error) {
+	resolved, err := loadConfig(nil)
+	if err != nil {
+		return Config{}, err
+	}
+	config := resolved.Config
+	config.Serve = mergeServeConfig(config.Serve, repo.Serve)
+	return s.applyFlags(config), nil
+}
+
+// remind notifies about the overdue branches of every repository, one
+// repository at a time
+func (s *repositoryServer) remind(now time.Time) {
+	names := make([]string, 0, len(s.repos))
+	for name := range s.repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.remindRepository(s.repos[name], now)
+	}
+}
+
+// remindRepository refreshes the clone of repo and notifies about its overdue branches
+func (s *repositoryServer) remindRepository(repo ServeRepository, now time.Time) {
+	repositoryLock.Lock()
+	defer repositoryLock.Unlock()
+	if err := s.refreshLocked(repo); err != nil {
+		logFor(logServe).Error("refresh failed", "repository", repo.Name, "error", err)
+		return
+	}
+	err := s.inRepository(repo, func() error {
+		config, err := s.repositoryConfig(repo)
+		if err != nil {
+			return err
+		}
+		remindOverdue(config, now)
+		return nil
+	})
+	if err != nil {
+		logFor(logServe).Error("reminders failed", "repository", repo.Name, "error", err)
+	}
+}
+
+// mergeServeConfig returns base with the values set in override
+func mergeServeConfig(base, override ServeConfig) ServeConfig {
+	if len(override.Branches) > 0 {
+		base.Branches = override.Branches
+	}
+	if override.Remote != "" {
+		base.Remote = override.Remote
+	}
+	if override.DryRun {
+		base.DryRun = true
+	}
+	return base
+}
+
+// withEnv runs fn with the variables of env set, restoring them afterwards
+func withEnv(env map[string]string, fn func() error) error {
+	for name, value := range env {
+		previous, set := os.LookupEnv(name)
+		os.Setenv(name, value)
+		if set {
+			defer os.Setenv(name, previous)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+	return fn()
+}
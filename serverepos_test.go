@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadServeRepositories(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{"valid", `{"workDir": "clones", "repositories": [{"name": "web", "url": "git@example.com:acme/web.git"}]}`, ""},
+		{"no work dir", `{"repositories": [{"name": "web", "url": "git@example.com:acme/web.git"}]}`, "workDir is empty"},
+		{"no repositories", `{"workDir": "clones"}`, "repositories is empty"},
+		{"bad name", `{"workDir": "clones", "repositories": [{"name": "acme/web", "url": "u"}]}`, "is not letters"},
+		{"duplicate", `{"workDir": "clones", "repositories": [{"name": "web", "url": "u"}, {"name": "web", "url": "v"}]}`, "listed twice"},
+		{"no url", `{"workDir": "clones", "repositories": [{"name": "web"}]}`, "has no url"},
+		{"bad json", `{`, "parsing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "repos.json")
+			os.WriteFile(path, []byte(tt.file), 0644)
+			repos, err := readServeRepositories(path)
+			if (tt.wantErr == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("readServeRepositories() error = %v, want %q", err, tt.wantErr)
+			}
+			if err == nil && repos.WorkDir != filepath.Join(filepath.Dir(path), "clones") {
+				t.Errorf("work dir = %q, want it next to the file", repos.WorkDir)
+			}
+		})
+	}
+}
+
+func TestServeRepositoryEnv(t *testing.T) {
+	t.Setenv("WEB_TOKEN", "t0ken")
+	env := ServeRepository{TokenEnv: "WEB_TOKEN", SSHKey: "/keys/web"}.env()
+	if env["GITHUB_TOKEN"] != "t0ken" || env["GITLAB_TOKEN"] != "t0ken" {
+		t.Errorf("env() = %v, want the token for every hosting service", env)
+	}
+	if env["GIT_SSH_COMMAND"] != "ssh -i '/keys/web' -o IdentitiesOnly=yes" {
+		t.Errorf("GIT_SSH_COMMAND = %q", env["GIT_SSH_COMMAND"])
+	}
+	if env := (ServeRepository{TokenEnv: "UNSET_TOKEN"}).env(); len(env) != 0 {
+		t.Errorf("env() without a token = %v, want nothing", env)
+	}
+}
+
+func TestWithEnv(t *testing.T) {
+	t.Setenv("GIT_PUBLISH_TEST_SET", "before")
+	os.Unsetenv("GIT_PUBLISH_TEST_UNSET")
+	withEnv(map[string]string{"GIT_PUBLISH_TEST_SET": "during", "GIT_PUBLISH_TEST_UNSET": "during"}, func() error {
+		if os.Getenv("GIT_PUBLISH_TEST_SET") != "during" || os.Getenv("GIT_PUBLISH_TEST_UNSET") != "during" {
+			t.Error("variables not set while running")
+		}
+		return nil
+	})
+	if os.Getenv("GIT_PUBLISH_TEST_SET") != "before" {
+		t.Errorf("GIT_PUBLISH_TEST_SET = %q after running, want before", os.Getenv("GIT_PUBLISH_TEST_SET"))
+	}
+	if _, set := os.LookupEnv("GIT_PUBLISH_TEST_UNSET"); set {
+		t.Error("GIT_PUBLISH_TEST_UNSET still set after running")
+	}
+}
+
+func TestRepositoryServerWebhook(t *testing.T) {
+	t.Setenv("WEB_SECRET", "s3cret")
+	released := make(chan string, 1)
+	s := &repositoryServer{repos: map[string]ServeRepository{"web": {Name: "web", SecretEnv: "WEB_SECRET"}}}
+	s.release = func(repo ServeRepository, branch string) { released <- repo.Name + " " + branch }
+	body := `{"ref":"refs/heads/main","after":"abc123"}`
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		secret     string
+		wantStatus int
+	}{
+		{"unknown repository", "POST", "/webhook/api", "s3cret", http.StatusNotFound},
+		{"wrong method", "GET", "/webhook/web", "s3cret", http.StatusMethodNotAllowed},
+		{"other repository's secret", "POST", "/webhook/web", "other", http.StatusUnauthorized},
+		{"push", "POST", "/webhook/web", "s3cret", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+			req.Header.Set("X-GitHub-Event", "push")
+			req.Header.Set("X-Hub-Signature-256", "sha256="+signWebhook(tt.secret, body))
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+	if got := <-released; got != "web main" {
+		t.Errorf("released %q, want web main", got)
+	}
+}
+
+func TestRepositoryServerRefreshWithFakeGit(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	g.remotes = map[string]string{"origin": "https://git.example.com/acme/web.git"}
+	g.remoteBranches = map[string]string{"origin/main": "b"}
+	g.defaultBranch = "main"
+	useFakeGit(t, g)
+	repo := ServeRepository{Name: "web", URL: "https://git.example.com/acme/web.git"}
+	s := &repositoryServer{workDir: t.TempDir(), repos: map[string]ServeRepository{"web": repo}}
+
+	if err := s.refresh(repo); err != nil {
+		t.Fatalf("refresh() cloning = %v", err)
+	}
+	if url := g.clones[s.dir(repo)]; url != repo.URL || g.head != "b" || g.current != "" {
+		t.Errorf("refresh() cloned %q and detached HEAD at %q on %q, want a clone detached at origin/main", url, g.head, g.current)
+	}
+
+	// Existing clones are fetched, not cloned again
+	g.clones, g.remoteBranches["origin/main"] = nil, "a"
+	if err := s.refresh(repo); err != nil {
+		t.Fatalf("refresh() fetching = %v", err)
+	}
+	if len(g.clones) != 0 || len(g.fetches) != 2 || g.head != "a" {
+		t.Errorf("refresh() cloned %q after %d fetches with HEAD at %q, want a fetch and HEAD at a", g.clones, len(g.fetches), g.head)
+	}
+}
+
+func TestRepositoryServerRefresh(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	origin := t.TempDir()
+	gitInTestRepo(t, origin, "init", "-q", "-b", "main")
+	gitInTestRepo(t, origin, "commit", "-q", "--allow-empty", "-m", "initial")
+	t.Setenv("WEB_SECRET", "s3cret")
+	repo := ServeRepository{Name: "web", URL: origin, SecretEnv: "WEB_SECRET"}
+	s := &repositoryServer{workDir: t.TempDir(), repos: map[string]ServeRepository{"web": repo}}
+	cwd, _ := os.Getwd()
+
+	if err := s.refresh(repo); err != nil {
+		t.Fatalf("refresh() cloning = %v", err)
+	}
+	if wd, _ := os.Getwd(); wd != cwd {
+		t.Errorf("refresh() left the working directory at %s", wd)
+	}
+
+	// A new commit arrives with a refresh request
+	gitInTestRepo(t, origin, "commit", "-q", "--allow-empty", "-m", "second")
+	req := httptest.NewRequest("POST", "/refresh/web", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh status = %d: %s", rec.Code, rec.Body)
+	}
+	out, err := exec.Command("git", "-C", s.dir(repo), "log", "-1", "--format=%s", "HEAD").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "second" {
+		t.Errorf("clone HEAD at %q, %v, want the second commit", out, err)
+	}
+	if err := exec.Command("git", "-C", s.dir(repo), "symbolic-ref", "-q", "HEAD").Run(); err == nil {
+		t.Error("HEAD of the clone is not detached")
+	}
+
+	req.Header.Set("Authorization", "Bearer guess")
+	rec = httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh with a wrong token: status = %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// SignaturesConfig requires the commits of a release to be signed
+type SignaturesConfig struct {
+	// Require refuses a tag while a commit since the last tag lacks a good signature
+	Require bool `json:"require,omitempty"`
+	// Branches limits the check to the production branches (default: every branch)
+	Branches []string `json:"branches,omitempty"`
+	// AllowedCommitters are committer emails, or globs such as *@bots.example.com,
+	// whose commits need no signature
+	AllowedCommitters []string `json:"allowedCommitters,omitempty"`
+}
+
+// commitSignature is the signature status of a commit
+type commitSignature struct {
+	Hash      string
+	Subject   string
+	Committer string
+	// Status is git's %G? letter: G good, U good with unknown validity, N none,
+	// B bad, E cannot be checked, X/Y expired signature/key, R revoked key
+	Status string
+}
+
+// validateSignaturesConfig returns the problems of the signature requirement
+func validateSignaturesConfig(config SignaturesConfig) []string {
+	var problems []string
+	for _, pattern := range config.AllowedCommitters {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("signatures.allowedCommitters %q is not a valid glob", pattern))
+		}
+	}
+	return problems
+}
+
+// signatureProblem describes why a commit does not count as signed, "" if it does
+func signatureProblem(status string) string {
+	switch status {
+	case "G", "U":
+		return ""
+	case "N", "":
+		return "not signed"
+	case "B":
+		return "bad signature"
+	case "E":
+		return "signature cannot be checked (missing key?)"
+	case "X":
+		return "expired signature"
+	case "Y":
+		return "signed with an expired key"
+	case "R":
+		return "signed with a revoked key"
+	}
+	return "signature status " + status
+}
+
+// isAllowedCommitter reports whether commits of email need no signature
+func isAllowedCommitter(email string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(email)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSignedCommits refuses a tag on branch while a commit since lastTag has
+// no good signature and does not come from an allowed committer, listing the
+// offending commits. The first tag of a branch is not checked, as older
+// history often predates signing.
+func checkSignedCommits(config SignaturesConfig, branch, lastTag string) error {
+	if !config.Require || lastTag == "" || (len(config.Branches) > 0 && !contains(config.Branches, branch)) {
+		return nil
+	}
+	signatures, err := gitClient.CommitSignatures(lastTag, branchRef(branch))
+	if err != nil {
+		return fmt.Errorf("checking the commit signatures since %s: %v", lastTag, err)
+	}
+
+	var offenders []string
+	for _, s := range signatures {
+		problem := signatureProblem(s.Status)
+		if problem == "" || isAllowedCommitter(s.Committer, config.AllowedCommitters) {
+			continue
+		}
+		offenders = append(offenders, fmt.Sprintf("  %s %s (%s, committed by %s)", s.Hash, s.Subject, problem, s.Committer))
+	}
+	if len(offenders) == 0 {
+		return nil
+	}
+	for _, offender := range offenders {
+		fmt.Println(offender)
+	}
+	return fmt.Errorf("%d of %d commit(s) on %s since %s are not signed; signatures.require allows no unsigned commits", len(offenders), len(signatures), branch, lastTag)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckSignedCommits(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c", "d", "e")
+	g.tags["v1.0.0"] = "a"
+	g.subjects = map[string]string{"b": "Add feature", "c": "Bump deps", "d": "Fix bug", "e": "Update docs"}
+	g.authors = map[string]string{"b": "dev@example.com", "c": "renovate@bots.example.com", "d": "dev@example.com", "e": "dev@example.com"}
+	g.signatures = map[string]string{"b": "G", "d": "U", "e": "E"}
+	useFakeGit(t, g)
+
+	tests := []struct {
+		name    string
+		config  SignaturesConfig
+		branch  string
+		lastTag string
+		wantErr string
+	}{
+		{"off", SignaturesConfig{}, "main", "v1.0.0", ""},
+		{"unsigned", SignaturesConfig{Require: true}, "main", "v1.0.0", "2 of 4 commit(s) on main since v1.0.0"},
+		{"allowed committer", SignaturesConfig{Require: true, AllowedCommitters: []string{"*@bots.example.com"}}, "main", "v1.0.0", "1 of 4"},
+		{"other branch", SignaturesConfig{Require: true, Branches: []string{"release"}}, "main", "v1.0.0", ""},
+		{"first tag", SignaturesConfig{Require: true}, "main", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			output := captureOutput(func() { err = checkSignedCommits(tt.config, tt.branch, tt.lastTag) })
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("checkSignedCommits() = %v, want %q", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(output, "e Update docs (signature cannot be checked (missing key?), committed by dev@example.com)") {
+				t.Errorf("offenders = %q", output)
+			}
+		})
+	}
+}
+
+func TestIsAllowedCommitter(t *testing.T) {
+	allowed := []string{"release-bot@example.com", "*@bots.example.com"}
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"release-bot@example.com", true},
+		{"Renovate@Bots.Example.com", true},
+		{"dev@example.com", false},
+		{"bot@bots.example.com.evil.org", false},
+	}
+	for _, tt := range tests {
+		if got := isAllowedCommitter(tt.email, allowed); got != tt.want {
+			t.Errorf("isAllowedCommitter(%q) = %v, want %v", tt.email, got, tt.want)
+		}
+	}
+}
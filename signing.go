@@ -0,0 +1,408 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Signature formats of git's gpg.format
+const (
+	signingOpenPGP = "openpgp"
+	signingSSH     = "ssh"
+	signingX509    = "x509"
+)
+
+// SigningConfig signs the tags created by the tool
+type SigningConfig struct {
+	// Tags signs every new tag, which makes it annotated
+	Tags bool `json:"tags,omitempty"`
+	// Format is openpgp, ssh (git 2.34+) or x509 (default: gpg.format of git, else openpgp)
+	Format string `json:"format,omitempty"`
+	// Key is a GPG key ID or, for ssh, a key file or key::<public key> (default: user.signingKey)
+	Key string `json:"key,omitempty"`
+	// AllowedSigners is the file verifying SSH signatures, relative to the
+	// repository root (default: gpg.ssh.allowedSignersFile)
+	AllowedSigners string `json:"allowedSigners,omitempty"`
+}
+
+// validateSigningConfig returns the problems of the signing configuration
+func validateSigningConfig(config SigningConfig) []string {
+	var problems []string
+	switch config.Format {
+	case "", signingOpenPGP, signingSSH, signingX509:
+	default:
+		problems = append(problems, fmt.Sprintf("signing.format %q is not openpgp, ssh or x509", config.Format))
+	}
+	if config.AllowedSigners != "" && config.Format != "" && config.Format != signingSSH {
+		problems = append(problems, "signing.allowedSigners only applies to the ssh format")
+	}
+	return problems
+}
+
+// signingGitArgs returns the -c options making git sign and verify as configured
+func signingGitArgs(config SigningConfig) []string {
+	var args []string
+	if config.Format != "" {
+		args = append(args, "-c", "gpg.format="+config.Format)
+	}
+	if config.AllowedSigners != "" {
+		args = append(args, "-c", "gpg.ssh.allowedSignersFile="+allowedSignersPath(config))
+	}
+	return args
+}
+
+// describeSigning shows the format and key of the signature in the plan
+func describeSigning(config SigningConfig) string {
+	format, key := config.Format, config.Key
+	if format == "" {
+		format = "git's gpg.format"
+	}
+	if key == "" {
+		key = "user.signingKey"
+	}
+	return fmt.Sprintf("yes (%s, key %s)", format, key)
+}
+
+// annotateTag creates the annotated tag of plan with message, signed when
+// the plan says so
+func annotateTag(plan Plan, message string, date time.Time) error {
+	if plan.Signing == nil {
+		return gitClient.CreateAnnotatedTag(plan.Tag, plan.TargetCommit, message, date)
+	}
+	return createSignedTag(plan, message, date)
+}
+
+// createSignedTag creates the signed tag of plan and checks that its
+// signature verifies, so that a tag nobody can verify is not pushed
+func createSignedTag(plan Plan, message string, date time.Time) error {
+	if err := gitClient.CreateSignedTag(plan.Tag, plan.TargetCommit, message, date, newTagSigner(*plan.Signing)); err != nil {
+		return err
+	}
+	if err := verifyTagSignature(plan.Tag, "refs/tags/"+plan.Tag, *plan.Signing); err != nil {
+		return fmt.Errorf("%v; the tag was created but not pushed, delete it with git tag -d %s", err, plan.Tag)
+	}
+	return nil
+}
+
+// tagObject writes the tag object annotateTag would create for plan, signed
+// and verified alike, without creating the tag, so that an existing tag can
+// be moved to it in one update
+func tagObject(plan Plan, message string, date time.Time) (string, error) {
+	if plan.Signing == nil {
+		return gitClient.CreateTagObject(plan.Tag, plan.TargetCommit, message, date)
+	}
+	object, err := gitClient.SignedTagObject(plan.Tag, plan.TargetCommit, message, date, newTagSigner(*plan.Signing))
+	if err != nil {
+		return "", err
+	}
+	if err := verifyTagSignature(plan.Tag, object, *plan.Signing); err != nil {
+		return "", err
+	}
+	return object, nil
+}
+
+// tagSigner is how git tag signs: the -c options of the format and the
+// option choosing the key
+type tagSigner struct {
+	Config []string
+	Key    []string
+}
+
+// newTagSigner returns the tagSigner of the signing configuration
+func newTagSigner(config SigningConfig) tagSigner {
+	return tagSigner{Config: signingGitArgs(config), Key: signingKeyArgs(config)}
+}
+
+// signingKeyArgs returns the git tag option signing with the configured key,
+// or else with user.signingKey
+func signingKeyArgs(config SigningConfig) []string {
+	if config.Key != "" {
+		return []string{"-u", expandHome(config.Key)}
+	}
+	return []string{"-s"}
+}
+
+// committerDateEnv dates the tag created by git at date
+func committerDateEnv(date time.Time) string {
+	return fmt.Sprintf("GIT_COMMITTER_DATE=@%d %s", date.Unix(), date.Format("-0700"))
+}
+
+// verifyTagSignature checks the signature of object, the tag object of tag,
+// with git verify-tag. SSH signatures are only checked when an allowed
+// signers file is known.
+func verifyTagSignature(tag, object string, config SigningConfig) error {
+	format := config.Format
+	if format == "" {
+		format, _ = gitClient.ConfigValue("gpg.format")
+	}
+	if format == signingSSH {
+		yellow := color.New(color.FgYellow).SprintFunc()
+		path := allowedSignersPath(config)
+		if path == "" {
+			fmt.Printf("%s the SSH signature of %s was not verified; set signing.allowedSigners to check it\n", yellow("Warning:"), tag)
+			return nil
+		}
+		if _, err := os.Stat(path); err != nil {
+			fmt.Printf("%s the SSH signature of %s was not verified: %s does not exist; add the key with git-publish signers add\n", yellow("Warning:"), tag, path)
+			return nil
+		}
+	}
+	if err := gitClient.VerifyTag(object, signingGitArgs(config)); err != nil {
+		return fmt.Errorf("the signature of tag %s does not verify: %v", tag, err)
+	}
+	return nil
+}
+
+// allowedSignersPath returns the allowed signers file: signing.allowedSigners
+// relative to the repository root, else gpg.ssh.allowedSignersFile of git
+func allowedSignersPath(config SigningConfig) string {
+	if config.AllowedSigners == "" {
+		path, _ := gitClient.ConfigValue("gpg.ssh.allowedSignersFile")
+		return expandHome(path)
+	}
+	path := expandHome(config.AllowedSigners)
+	if root, err := gitClient.TopLevel(); err == nil && !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	return path
+}
+
+// expandHome replaces a leading ~/ with the home directory
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
+
+// allowedSigner is an entry of an allowed signers file
+type allowedSigner struct {
+	Principals []string
+	KeyType    string
+	Key        string
+}
+
+// parseAllowedSigners returns the entries of an allowed signers file:
+// principals, optional options such as namespaces="git", key type and key
+func parseAllowedSigners(data string) []allowedSigner {
+	var signers []allowedSigner
+	for _, line := range strings.Split(data, "\n") {
+		fields := splitSignerLine(strings.TrimSpace(line))
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for i := 1; i < len(fields)-1; i++ {
+			if isSSHKeyType(fields[i]) {
+				signers = append(signers, allowedSigner{Principals: strings.Split(fields[0], ","), KeyType: fields[i], Key: fields[i+1]})
+				break
+			}
+		}
+	}
+	return signers
+}
+
+// splitSignerLine splits at spaces outside of double quotes
+func splitSignerLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			field.WriteRune(r)
+		case (r == ' ' || r == '\t') && !quoted:
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+func isSSHKeyType(s string) bool {
+	return strings.HasPrefix(s, "ssh-") || strings.HasPrefix(s, "ecdsa-") || strings.HasPrefix(s, "sk-")
+}
+
+// runSignersCommand implements the signers command, which manages the
+// allowed signers file verifying SSH signed tags
+func runSignersCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: git-publish signers list | add [--email <email>] [<public key file>] | remove <email>")
+		os.Exit(1)
+	}
+	config := readConfig()
+	path := allowedSignersPath(config.Signing)
+	if path == "" {
+		fmt.Println("Error: set signing.allowedSigners (or git's gpg.ssh.allowedSignersFile) to the allowed signers file")
+		os.Exit(1)
+	}
+
+	var err error
+	switch args[0] {
+	case "list":
+		err = listAllowedSigners(path)
+	case "add":
+		fs := flag.NewFlagSet("signers add", flag.ExitOnError)
+		email := fs.String("email", "", "principal of the key (default: user.email)")
+		fs.Parse(args[1:])
+		err = addAllowedSigner(path, *email, fs.Arg(0), config.Signing)
+	case "remove":
+		if len(args) != 2 {
+			fmt.Println("Usage: git-publish signers remove <email>")
+			os.Exit(1)
+		}
+		err = removeAllowedSigner(path, args[1])
+	default:
+		fmt.Printf("Error: Unknown signers command '%s'\n", args[0])
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func listAllowedSigners(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		fmt.Printf("No allowed signers yet; add your key with git-publish signers add (%s)\n", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	signers := parseAllowedSigners(string(data))
+	fmt.Printf("%s in %s:\n", plural(len(signers), "allowed signer"), path)
+	for _, s := range signers {
+		key := s.Key
+		if len(key) > 12 {
+			key = "..." + key[len(key)-12:]
+		}
+		fmt.Printf("  %s  %s %s\n", strings.Join(s.Principals, ","), s.KeyType, key)
+	}
+	return nil
+}
+
+// addAllowedSigner adds the public key in keyFile (default: the signing key)
+// for email (default: user.email) to the allowed signers file at path
+func addAllowedSigner(path, email, keyFile string, config SigningConfig) error {
+	if email == "" {
+		email, _ = gitClient.ConfigValue("user.email")
+		if email == "" {
+			return fmt.Errorf("set --email or git's user.email")
+		}
+	}
+	publicKey, err := readPublicKey(keyFile, config)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(publicKey)
+	if len(fields) < 2 || !isSSHKeyType(fields[0]) {
+		return fmt.Errorf("%q is not an SSH public key", publicKey)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, s := range parseAllowedSigners(string(data)) {
+		if s.Key == fields[1] && contains(s.Principals, email) {
+			fmt.Printf("%s is already allowed to sign with this key\n", email)
+			return nil
+		}
+	}
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += fmt.Sprintf("%s namespaces=\"git\" %s %s\n", email, fields[0], fields[1])
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Allowed %s to sign with its %s key in %s\n", email, fields[0], path)
+	return nil
+}
+
+// readPublicKey returns the public key in keyFile or, without one, of the
+// signing key: a key:: literal, a .pub file or the .pub next to a private key
+func readPublicKey(keyFile string, config SigningConfig) (string, error) {
+	if keyFile == "" {
+		keyFile = config.Key
+		if keyFile == "" {
+			keyFile, _ = gitClient.ConfigValue("user.signingKey")
+		}
+		if keyFile == "" {
+			return "", fmt.Errorf("name the public key file, or set signing.key")
+		}
+	}
+	if strings.HasPrefix(keyFile, "key::") {
+		return strings.TrimPrefix(keyFile, "key::"), nil
+	}
+	keyFile = expandHome(keyFile)
+	if !strings.HasSuffix(keyFile, ".pub") {
+		if _, err := os.Stat(keyFile + ".pub"); err == nil {
+			keyFile += ".pub"
+		}
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// removeAllowedSigner removes email from the file at path: entries of
+// email alone are deleted, shared ones keep their other principals
+func removeAllowedSigner(path, email string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var kept []string
+	removed := 0
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		fields := splitSignerLine(strings.TrimSpace(line))
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || !contains(strings.Split(fields[0], ","), email) {
+			kept = append(kept, line)
+			continue
+		}
+		removed++
+		var others []string
+		for _, principal := range strings.Split(fields[0], ",") {
+			if principal != email {
+				others = append(others, principal)
+			}
+		}
+		if len(others) > 0 {
+			kept = append(kept, strings.Join(others, ",")+strings.TrimPrefix(strings.TrimLeft(line, " \t"), fields[0]))
+		}
+	}
+	if removed == 0 {
+		return fmt.Errorf("%s is not in %s", email, path)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(kept, "")), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s of %s from %s\n", plural(removed, "key"), email, path)
+	return nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateSigningConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config SigningConfig
+		want   int
+	}{
+		{"none", SigningConfig{}, 0},
+		{"ssh", SigningConfig{Tags: true, Format: "ssh", Key: "~/.ssh/id_ed25519.pub", AllowedSigners: ".github/allowed_signers"}, 0},
+		{"git's format", SigningConfig{Tags: true, AllowedSigners: "allowed_signers"}, 0},
+		{"unknown format", SigningConfig{Format: "minisign"}, 1},
+		{"allowed signers with gpg", SigningConfig{Format: "openpgp", AllowedSigners: "allowed_signers"}, 1},
+	}
+	for _, tt := range tests {
+		if got := validateSigningConfig(tt.config); len(got) != tt.want {
+			t.Errorf("%s: validateSigningConfig() = %q, want %d problems", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseAllowedSigners(t *testing.T) {
+	data := `# release managers
+alice@example.com namespaces="git,file" ssh-ed25519 AAAAC3alice alice@laptop
+bob@example.com,bob@corp.example.com ecdsa-sha2-nistp256 AAAAE2bob
+
+broken line
+`
+	want := []allowedSigner{
+		{Principals: []string{"alice@example.com"}, KeyType: "ssh-ed25519", Key: "AAAAC3alice"},
+		{Principals: []string{"bob@example.com", "bob@corp.example.com"}, KeyType: "ecdsa-sha2-nistp256", Key: "AAAAE2bob"},
+	}
+	if got := parseAllowedSigners(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAllowedSigners() = %+v, want %+v", got, want)
+	}
+}
+
+func TestManageAllowedSigners(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "signing", "allowed_signers")
+	keyFile := filepath.Join(dir, "id_ed25519")
+	os.WriteFile(keyFile+".pub", []byte("ssh-ed25519 AAAAC3alice alice@laptop\n"), 0644)
+
+	captureOutput(func() {
+		if err := addAllowedSigner(path, "alice@example.com", "", SigningConfig{Key: keyFile}); err != nil {
+			t.Fatalf("addAllowedSigner() = %v", err)
+		}
+		// Adding the same key again changes nothing
+		addAllowedSigner(path, "alice@example.com", keyFile+".pub", SigningConfig{})
+		addAllowedSigner(path, "bob@example.com", "", SigningConfig{Key: "key::ssh-ed25519 AAAAC3bob"})
+	})
+	data, _ := os.ReadFile(path)
+	want := "alice@example.com namespaces=\"git\" ssh-ed25519 AAAAC3alice\nbob@example.com namespaces=\"git\" ssh-ed25519 AAAAC3bob\n"
+	if string(data) != want {
+		t.Errorf("allowed signers = %q, want %q", data, want)
+	}
+
+	if output := captureOutput(func() { listAllowedSigners(path) }); !strings.Contains(output, "2 allowed signers") || !strings.Contains(output, "bob@example.com  ssh-ed25519") {
+		t.Errorf("listAllowedSigners() printed %q", output)
+	}
+
+	captureOutput(func() {
+		if err := removeAllowedSigner(path, "alice@example.com"); err != nil {
+			t.Errorf("removeAllowedSigner() = %v", err)
+		}
+		if err := removeAllowedSigner(path, "carol@example.com"); err == nil {
+			t.Error("removeAllowedSigner() of an unknown signer succeeded")
+		}
+	})
+	if data, _ := os.ReadFile(path); string(data) != "bob@example.com namespaces=\"git\" ssh-ed25519 AAAAC3bob\n" {
+		t.Errorf("allowed signers after removing alice = %q", data)
+	}
+
+	if err := addAllowedSigner(path, "carol@example.com", "", SigningConfig{Key: "key::not a key"}); err == nil {
+		t.Error("addAllowedSigner() accepted something that is not an SSH key")
+	}
+
+	// A key shared by several principals stays for the others
+	os.WriteFile(path, []byte("dev@example.com,bob@example.com namespaces=\"git\" ssh-ed25519 AAAAC3team\nbob@example.com namespaces=\"git\" ssh-ed25519 AAAAC3bob\n"), 0644)
+	captureOutput(func() {
+		if err := removeAllowedSigner(path, "bob@example.com"); err != nil {
+			t.Errorf("removeAllowedSigner() = %v", err)
+		}
+	})
+	if data, _ := os.ReadFile(path); string(data) != "dev@example.com namespaces=\"git\" ssh-ed25519 AAAAC3team\n" {
+		t.Errorf("allowed signers after removing bob = %q, want the team key kept for dev", data)
+	}
+}
+
+func TestMakePlanSigning(t *testing.T) {
+	useFakeGit(t, newFakeGitClient("a"))
+	config := Config{Signing: SigningConfig{Tags: true, Format: "ssh"}}
+	plan, err := makePlan(config, nil, "main", "v0.0.0", "", "v1.0.0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Signing == nil || plan.TagMessage != "Release v1.0.0" {
+		t.Errorf("plan signing %+v with message %q, want a signed annotated tag", plan.Signing, plan.TagMessage)
+	}
+	if !strings.Contains(formatPlan(plan), "Signed:        yes (ssh, key user.signingKey)") {
+		t.Errorf("formatPlan() does not show the signature:\n%s", formatPlan(plan))
+	}
+
+	plan, _ = makePlan(Config{}, nil, "main", "v0.0.0", "", "v1.0.0", "")
+	if plan.Signing != nil || plan.TagMessage != "" {
+		t.Errorf("unsigned plan has signing %+v and message %q", plan.Signing, plan.TagMessage)
+	}
+}
+
+// useSSHSigningKeys creates a test repository and the SSH keys release,
+// which allowed_signers lists, and other, returning the key directory
+func useSSHSigningKeys(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not installed")
+	}
+	dir := newBlobTestRepo(t)
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	keys := t.TempDir()
+	for _, name := range []string{"release", "other"} {
+		if output, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", filepath.Join(keys, name)).CombinedOutput(); err != nil {
+			t.Skipf("ssh-keygen failed: %v %s", err, output)
+		}
+	}
+	captureOutput(func() {
+		addAllowedSigner(filepath.Join(dir, "allowed_signers"), "test@example.com", filepath.Join(keys, "release.pub"), SigningConfig{})
+	})
+	return keys
+}
+
+func TestAnnotateTagSigningWithFakeGit(t *testing.T) {
+	signing := &SigningConfig{Tags: true, Format: signingOpenPGP, Key: "ABC123"}
+	wantSigner := tagSigner{Config: []string{"-c", "gpg.format=openpgp"}, Key: []string{"-u", "ABC123"}}
+
+	tests := []struct {
+		name      string
+		verifyErr error
+		wantErr   string
+	}{
+		{"verified", nil, ""},
+		{"unverified", fmt.Errorf("gpg: Can't check signature: No public key"), "delete it with git tag -d v1.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGitClient("c1")
+			g.verifyErr = tt.verifyErr
+			useFakeGit(t, g)
+
+			err := annotateTag(Plan{Tag: "v1.0.0", TargetCommit: "c1", Signing: signing}, "Release v1.0.0", time.Now())
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("annotateTag() = %v, want %q", err, tt.wantErr)
+			}
+			if signer := g.tagSigners["v1.0.0"]; !reflect.DeepEqual(signer, wantSigner) {
+				t.Errorf("tag signed by %+v, want %+v", signer, wantSigner)
+			}
+
+			// Tag objects for moving a tag are verified alike
+			object, err := tagObject(Plan{Tag: "v1.0.0", TargetCommit: "c1", Signing: signing}, "Release v1.0.0", time.Now())
+			if (err != nil) != (tt.verifyErr != nil) || err == nil && !reflect.DeepEqual(g.tagSigners[object], wantSigner) {
+				t.Errorf("tagObject() = %q, %v, want a signed object verified like the tag", object, err)
+			}
+		})
+	}
+}
+
+func TestCreateSignedTagSSH(t *testing.T) {
+	keys := useSSHSigningKeys(t)
+	head, err := gitClient.RevParse("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tag     string
+		key     string
+		wantErr bool
+	}{
+		{"v1.0.0", "release", false},
+		{"v1.0.1", "other", true},
+	}
+	for _, tt := range tests {
+		plan := Plan{Tag: tt.tag, TargetCommit: head, Signing: &SigningConfig{Tags: true, Format: "ssh", Key: filepath.Join(keys, tt.key), AllowedSigners: "allowed_signers"}}
+		err := annotateTag(plan, "Release "+tt.tag, time.Now())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("annotateTag(%s) signed with %s = %v, want error %v", tt.tag, tt.key, err, tt.wantErr)
+		}
+		if err != nil && !strings.Contains(err.Error(), "does not verify") {
+			t.Errorf("annotateTag(%s) = %v, want a verification error", tt.tag, err)
+		}
+	}
+	output, _ := exec.Command("git", "cat-file", "tag", "v1.0.0").Output()
+	if !strings.Contains(string(output), "-----BEGIN SSH SIGNATURE-----") {
+		t.Errorf("tag v1.0.0 carries no SSH signature:\n%s", output)
+	}
+}
+
+func TestRetagSignedSSH(t *testing.T) {
+	keys := useSSHSigningKeys(t)
+	dir, _ := os.Getwd()
+	gitInTestRepo(t, dir, "tag", "v1.0.0")
+	old, _ := gitClient.RevParse("HEAD")
+	gitInTestRepo(t, dir, "commit", "-q", "--allow-empty", "-m", "Fix release")
+	head, _ := gitClient.RevParse("HEAD")
+
+	// A key the allowed signers do not list leaves the tag where it was
+	plan := retagPlan{Tag: "v1.0.0", Branch: "main", TagFormat: "v0.0.0", OldCommit: old, NewCommit: head, OldObject: old, Annotated: true, Leases: map[string]string{}}
+	plan.Signing = &SigningConfig{Tags: true, Format: "ssh", Key: filepath.Join(keys, "other"), AllowedSigners: "allowed_signers"}
+	var err error
+	captureOutput(func() { err = executeRetag(plan, Config{}, nil) })
+	if err == nil || !strings.Contains(err.Error(), "does not verify") {
+		t.Errorf("executeRetag() signed with an unknown key = %v, want a verification error", err)
+	}
+	if commit, _ := gitClient.RevParse("refs/tags/v1.0.0"); commit != old {
+		t.Errorf("tag v1.0.0 = %s after a failed signature, want it still at %s", commit, old)
+	}
+
+	plan.Signing.Key = filepath.Join(keys, "release")
+	captureOutput(func() { err = executeRetag(plan, Config{}, nil) })
+	if err != nil {
+		t.Fatalf("executeRetag() error = %v", err)
+	}
+	if commit, _ := gitClient.RevParse("refs/tags/v1.0.0^{commit}"); commit != head {
+		t.Errorf("tag v1.0.0 = %s, want it moved to %s", commit, head)
+	}
+	output, _ := exec.Command("git", "cat-file", "tag", "v1.0.0").Output()
+	if !strings.Contains(string(output), "-----BEGIN SSH SIGNATURE-----") {
+		t.Errorf("moved tag v1.0.0 carries no SSH signature:\n%s", output)
+	}
+	if output, err := exec.Command("git", "-c", "gpg.ssh.allowedSignersFile=allowed_signers", "tag", "-v", "v1.0.0").CombinedOutput(); err != nil {
+		t.Errorf("git tag -v v1.0.0 = %v: %s", err, output)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// skipReleaseMarkers mark a commit that does not need a release anywhere in
+// its message, as semantic-release does
+var skipReleaseMarkers = []string{"[skip release]", "[release skip]"}
+
+// skipsRelease reports whether a commit message opts out of releases with a
+// skip marker or a "Release-Note: none" trailer
+func skipsRelease(message string) bool {
+	lower := strings.ToLower(message)
+	for _, marker := range skipReleaseMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	for _, value := range trailerValues(message, "Release-Note") {
+		if strings.EqualFold(value, "none") {
+			return true
+		}
+	}
+	return false
+}
+
+// trailerValues returns the values of the trailers named key, compared case
+// insensitively, in the last paragraph of a commit message
+func trailerValues(message, key string) []string {
+	paragraphs := strings.Split(strings.TrimSpace(message), "\n\n")
+	if len(paragraphs) < 2 {
+		// A message without a body has no trailers
+		return nil
+	}
+	var values []string
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), key) {
+			values = append(values, strings.TrimSpace(value))
+		}
+	}
+	return values
+}
+
+// skippedCommits returns the short hashes of the commits between from and to
+// that opt out of releases
+func skippedCommits(from, to string) (map[string]bool, error) {
+	messages, err := gitClient.CommitMessages(from, to)
+	if err != nil {
+		return nil, err
+	}
+	skipped := map[string]bool{}
+	for hash, message := range messages {
+		if skipsRelease(message) {
+			skipped[hash] = true
+		}
+	}
+	return skipped, nil
+}
+
+// releasedCommits lists the commits between from and to like Commits, leaving
+// out those that opt out of releases. Generated changelogs use it.
+func releasedCommits(from, to string) ([]string, error) {
+	commits, err := gitClient.Commits(from, to)
+	if err != nil {
+		return nil, err
+	}
+	skipped, err := skippedCommits(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var released []string
+	for _, commit := range commits {
+		hash, _, _ := strings.Cut(commit, " ")
+		if !skipped[hash] {
+			released = append(released, commit)
+		}
+	}
+	return released, nil
+}
+
+// warnSkippedRelease warns when every commit on branch since lastTag opts out
+// of releases, so a new tag is probably unnecessary
+func warnSkippedRelease(lastTag, branch string) {
+	commits, err := gitClient.Commits(lastTag, branchRef(branch))
+	if err != nil || len(commits) == 0 {
+		return
+	}
+	skipped, err := skippedCommits(lastTag, branch)
+	if err != nil {
+		fmt.Printf("Warning: could not read the commit messages since %s: %v\n", lastTag, err)
+		return
+	}
+	if len(skipped) == len(commits) {
+		fmt.Printf("Warning: all %d commit(s) since %s are marked [skip release] or Release-Note: none; a release is probably unnecessary\n", len(commits), lastTag)
+	}
+}
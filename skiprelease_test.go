@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSkipsRelease(t *testing.T) {
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{"Fix typo in README [skip release]", true},
+		{"Update CI\n\nNothing user facing. [Release Skip]", true},
+		{"Bump linter\n\nRelease-Note: none", true},
+		{"Bump linter\n\nSigned-off-by: A <a@example.com>\nrelease-note: None", true},
+		{"Add export\n\nRelease-Note: Adds the export command", false},
+		{"Release-Note: none", false},
+		{"Release-Note: none\n\nExplain why in the body", false},
+		{"Add export", false},
+	}
+	for _, tt := range tests {
+		if got := skipsRelease(tt.message); got != tt.want {
+			t.Errorf("skipsRelease(%q) = %v, want %v", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestReleasedCommits(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c", "d")
+	g.tags["v1.0.0"] = "a"
+	g.subjects = map[string]string{"b": "Add export", "c": "Update CI [skip release]", "d": "Bump linter"}
+	g.bodies["d"] = "Release-Note: none"
+	useFakeGit(t, g)
+
+	commits, err := releasedCommits("v1.0.0", "main")
+	if want := []string{"b Add export"}; err != nil || !reflect.DeepEqual(commits, want) {
+		t.Errorf("releasedCommits() = %q, %v, want %q", commits, err, want)
+	}
+}
+
+func TestWarnSkippedRelease(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c")
+	g.tags["v1.0.0"] = "a"
+	g.subjects = map[string]string{"b": "Update CI [skip release]", "c": "Fix typo"}
+	useFakeGit(t, g)
+
+	if output := captureOutput(func() { warnSkippedRelease("v1.0.0", "main") }); output != "" {
+		t.Errorf("warnSkippedRelease() with a releasable commit printed %q", output)
+	}
+
+	g.bodies["c"] = "Docs only.\n\nRelease-Note: none"
+	output := captureOutput(func() { warnSkippedRelease("v1.0.0", "main") })
+	if !strings.Contains(output, "all 2 commit(s) since v1.0.0") {
+		t.Errorf("warnSkippedRelease() = %q, want a warning", output)
+	}
+}
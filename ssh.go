@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// sshCommandForKey builds a GIT_SSH_COMMAND value that authenticates with only the given key
+func sshCommandForKey(key string) (string, error) {
+	key = expandHome(key)
+	if _, err := os.Stat(key); err != nil {
+		return "", fmt.Errorf("SSH key %s is not accessible: %v", key, err)
+	}
+
+	return "ssh -i " + shellQuote(key) + " -o IdentitiesOnly=yes", nil
+}
+
+// shellQuote quotes s for safe use as a single word in a POSIX shell command
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// applySSHKey makes cmd authenticate with the given key; an empty key leaves cmd unchanged
+func applySSHKey(cmd *exec.Cmd, key string) error {
+	if key == "" {
+		return nil
+	}
+
+	sshCommand, err := sshCommandForKey(key)
+	if err != nil {
+		return err
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+sshCommand)
+	return nil
+}
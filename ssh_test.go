@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSSHCommandForKey tests building GIT_SSH_COMMAND for an explicit identity
+func TestSSHCommandForKey(t *testing.T) {
+	key := filepath.Join(t.TempDir(), "deploy key's")
+	if err := os.WriteFile(key, []byte("key"), 0600); err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+
+	result, err := sshCommandForKey(key)
+	if err != nil {
+		t.Fatalf("sshCommandForKey() returned error: %v", err)
+	}
+	expected := "ssh -i '" + filepath.Dir(key) + "/deploy key'\\''s' -o IdentitiesOnly=yes"
+	if result != expected {
+		t.Errorf("sshCommandForKey() = %q, expected %q", result, expected)
+	}
+
+	if _, err := sshCommandForKey(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("Expected an error for a missing key file")
+	}
+}
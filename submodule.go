@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// runSubmoduleCommand implements the submodule command: it runs the publish
+// flow inside a submodule and optionally records the tagged commit in the superproject
+func runSubmoduleCommand(args []string) {
+	fs := flag.NewFlagSet("submodule", flag.ExitOnError)
+	opts := addPublishFlags(fs)
+	bump := fs.Bool("bump-superproject", false, "commit the tagged submodule commit in the superproject without asking")
+
+	// Accept the submodule path before the flags as well
+	var path string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		path, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if path == "" {
+		path = fs.Arg(0)
+	}
+
+	submodules, err := listSubmodules()
+	if err != nil || len(submodules) == 0 {
+		fmt.Println("Error: This repository has no submodules")
+		os.Exit(1)
+	}
+
+	if path != "" {
+		path = strings.TrimSuffix(path, "/")
+		if !contains(submodules, path) {
+			fmt.Printf("Error: '%s' is not a submodule (available: %s)\n", path, strings.Join(submodules, ", "))
+			os.Exit(1)
+		}
+	} else {
+		path = selectSubmodule(submodules)
+	}
+
+	superproject, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(path); err != nil {
+		fmt.Printf("Error: Submodule %s is not checked out (run git submodule update --init %s): %v\n", path, path, err)
+		os.Exit(1)
+	}
+
+	cyan := color.New(color.FgCyan).SprintFunc()
+	fmt.Printf("Publishing submodule %s\n", cyan(path))
+	config, remoteURLs := preparePublish(opts)
+	plan, ok := buildPlan(config, remoteURLs, opts)
+	if !ok {
+		fmt.Println("Tagging cancelled.")
+		return
+	}
+	executePlan(plan, config)
+
+	if err := os.Chdir(superproject); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	recorded, err := recordedSubmoduleCommit(path)
+	if err != nil || recorded == plan.TargetCommit {
+		return
+	}
+	if !*bump && !confirmSuperprojectBump(path, plan) {
+		return
+	}
+	if err := bumpSubmodule(path, plan); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// listSubmodules returns the paths of the submodules declared in .gitmodules
+func listSubmodules() ([]string, error) {
+	return gitClient.SubmodulePaths()
+}
+
+// parseSubmodulePaths reads the values of git config --null --get-regexp:
+// entries are NUL terminated with a newline between key and value, as names
+// may contain spaces
+func parseSubmodulePaths(output []byte) []string {
+	var paths []string
+	for _, entry := range strings.Split(string(output), "\x00") {
+		if fields := strings.SplitN(entry, "\n", 2); len(fields) == 2 {
+			paths = append(paths, fields[1])
+		}
+	}
+	return paths
+}
+
+// selectSubmodule asks which submodule to publish
+func selectSubmodule(submodules []string) string {
+	choices := make([]choice, len(submodules))
+	for i, path := range submodules {
+		choices[i] = choice{Name: path}
+	}
+	return submodules[prompter.Select("Select submodule to publish:", "submodule", choices)]
+}
+
+// recordedSubmoduleCommit returns the submodule commit recorded in the superproject's HEAD
+func recordedSubmoduleCommit(path string) (string, error) {
+	return gitClient.RevParse("HEAD:" + path)
+}
+
+// confirmSuperprojectBump asks whether to record the tagged commit in the superproject
+func confirmSuperprojectBump(path string, plan Plan) bool {
+	return prompter.Confirm(fmt.Sprintf("Record %s (%s) as the %s commit in the superproject?", plan.Tag, shortCommit(plan.TargetCommit), path), false)
+}
+
+// bumpSubmodule commits the tagged submodule commit in the superproject without
+// touching the submodule's working tree
+func bumpSubmodule(path string, plan Plan) error {
+	if staged, err := gitClient.HasStagedChanges(); err != nil || staged {
+		return fmt.Errorf("the superproject has staged changes; commit or unstage them first")
+	}
+	if err := gitClient.StageGitlink(path, plan.TargetCommit); err != nil {
+		return fmt.Errorf("recording submodule commit: %v", err)
+	}
+	if err := gitClient.Commit(fmt.Sprintf("Bump %s to %s", path, plan.Tag)); err != nil {
+		return fmt.Errorf("committing submodule bump: %v", err)
+	}
+	fmt.Printf("Run 'git submodule update %s' to check out the recorded commit.\n", path)
+	return nil
+}
+
+// shortCommit abbreviates a commit hash for display
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
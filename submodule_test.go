@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseSubmodulePaths tests reading submodule paths from .gitmodules
+func TestParseSubmodulePaths(t *testing.T) {
+	submodules := parseSubmodulePaths([]byte("submodule.libs/core.path\nlibs/core\x00submodule.docs theme.path\ndocs/theme dir\x00"))
+	expected := []string{"libs/core", "docs/theme dir"}
+	if len(submodules) != len(expected) {
+		t.Fatalf("parseSubmodulePaths() = %v, expected %v", submodules, expected)
+	}
+	for i, path := range submodules {
+		if path != expected[i] {
+			t.Errorf("parseSubmodulePaths()[%d] = %q, expected %q", i, path, expected[i])
+		}
+	}
+}
+
+// TestBumpSubmodule tests recording the tagged commit in the superproject
+func TestBumpSubmodule(t *testing.T) {
+	g := newFakeGitClient("c1")
+	useFakeGit(t, g)
+	plan := Plan{Tag: "v1.0.0", TargetCommit: "abc"}
+
+	g.staged = map[string]string{"docs/theme": "def"}
+	if err := bumpSubmodule("libs/core", plan); err == nil {
+		t.Error("bumpSubmodule() with staged changes succeeded, want an error")
+	}
+	if len(g.commitMessages) != 0 {
+		t.Errorf("bumpSubmodule() with staged changes committed %q", g.commitMessages)
+	}
+
+	g.staged = nil
+	var err error
+	captureOutput(func() { err = bumpSubmodule("libs/core", plan) })
+	if err != nil {
+		t.Fatalf("bumpSubmodule() error: %v", err)
+	}
+	if want := []string{"Bump libs/core to v1.0.0"}; !reflect.DeepEqual(g.commitMessages, want) || g.branches["main"] == "c1" {
+		t.Errorf("commits = %q on %s, want %q on a new commit", g.commitMessages, g.branches["main"], want)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// tagDateLayouts are the formats accepted by --tag-date; dates without a zone are local time
+var tagDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// parseTagDate parses the value of --tag-date, refusing dates after now
+func parseTagDate(value string, now time.Time) (time.Time, error) {
+	for _, layout := range tagDateLayouts {
+		date, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			continue
+		}
+		if date.After(now) {
+			return time.Time{}, fmt.Errorf("tag date %s is in the future", value)
+		}
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid tag date %q, use YYYY-MM-DD, YYYY-MM-DD HH:MM:SS or RFC 3339", value)
+}
+
+// checkTagDate refuses to date a tag before the commit it points to was made
+func checkTagDate(date time.Time, commit string) error {
+	committed, err := gitClient.CommitDate(commit)
+	if err != nil {
+		return fmt.Errorf("reading the date of commit %s: %v", commit, err)
+	}
+	if date.Before(committed) {
+		return fmt.Errorf("tag date %s is before commit %s was made (%s)", date.Format(time.RFC3339), shortCommit(commit), committed.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// confirmTagDate warns that the planned tag is backdated and, in an
+// interactive run, asks for confirmation. It returns false when the user declines.
+func confirmTagDate(plan Plan) bool {
+	yellow := color.New(color.FgYellow).SprintFunc()
+
+	fmt.Printf("%s Tag %s will be an annotated tag dated %s instead of now.\n", yellow("Warning:"), plan.Tag, plan.TagDate)
+	fmt.Println("Backdated tags misrepresent when a release was made; only use them to re-create or import historical releases.")
+	if !isInteractive() {
+		return true
+	}
+	return prompter.Confirm("Create the backdated tag?", false)
+}
+
+// createBackdatedTag creates the annotated tag of a plan with a tag date
+func createBackdatedTag(plan Plan) error {
+	date, err := time.Parse(time.RFC3339, plan.TagDate)
+	if err != nil {
+		return fmt.Errorf("invalid tag date in plan: %v", err)
+	}
+	message := plan.TagMessage
+	if message == "" {
+		message = "Release " + plan.Tag
+	}
+	if err := annotateTag(plan, message, date); err != nil {
+		return tagCreationError(plan.Tag, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTagDate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2020-01-02T10:00:00Z", time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC), false},
+		{"2020-01-02T10:00:00+02:00", time.Date(2020, 1, 2, 8, 0, 0, 0, time.UTC), false},
+		{"2020-01-02", time.Date(2020, 1, 2, 0, 0, 0, 0, time.Local), false},
+		{"2020-01-02 10:30:00", time.Date(2020, 1, 2, 10, 30, 0, 0, time.Local), false},
+		{"2030-01-01", time.Time{}, true},
+		{"02/01/2020", time.Time{}, true},
+		{"yesterday", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTagDate(tt.value, now)
+		if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+			t.Errorf("parseTagDate(%q) = %v, %v, want %v (error: %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckTagDate(t *testing.T) {
+	g := newFakeGitClient("a")
+	g.commitDates["a"] = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	useFakeGit(t, g)
+
+	if err := checkTagDate(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), "a"); err != nil {
+		t.Errorf("checkTagDate() after the commit error = %v", err)
+	}
+	if err := checkTagDate(time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC), "a"); err == nil {
+		t.Error("checkTagDate() before the commit succeeded, want an error")
+	}
+	if err := checkTagDate(time.Now(), "missing"); err == nil {
+		t.Error("checkTagDate() of an unknown commit succeeded, want an error")
+	}
+}
+
+func TestRunPlanStepsBackdatedTag(t *testing.T) {
+	g := newFakeGitClient("a")
+	useFakeGit(t, g)
+	bus := &eventBus{handlers: make(map[string][]eventHandler)}
+
+	plan := Plan{Branch: "main", TargetCommit: "a", Tag: "v1.0.0", TagDate: "2020-01-02T10:00:00Z"}
+	if _, err := runPlanSteps(plan, Config{}, bus, &publishProgress{Plan: plan}); err != nil {
+		t.Fatalf("runPlanSteps() error = %v", err)
+	}
+	want := time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC)
+	if got, ok := g.tagDates["v1.0.0"]; !ok || !got.Equal(want) {
+		t.Errorf("tag date = %v (annotated: %v), want %v", got, ok, want)
+	}
+
+	// Lightweight tags stay the default
+	plan = Plan{Branch: "main", TargetCommit: "a", Tag: "v1.0.1"}
+	if _, err := runPlanSteps(plan, Config{}, bus, &publishProgress{Plan: plan}); err != nil {
+		t.Fatalf("runPlanSteps() error = %v", err)
+	}
+	if _, annotated := g.tagDates["v1.0.1"]; annotated || g.tags["v1.0.1"] != "a" {
+		t.Errorf("v1.0.1 annotated = %v, commit = %q, want a lightweight tag on a", annotated, g.tags["v1.0.1"])
+	}
+}
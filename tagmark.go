@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// toolTagTrailer marks the annotated tags created by git-publish
+const toolTagTrailer = "Created-By: git-publish"
+
+// TagsConfig tells tags created by the tool apart from tags made by hand and
+// selects the refs releases are published as
+type TagsConfig struct {
+	// Mark creates annotated tags carrying the Created-By: git-publish trailer
+	Mark bool `json:"mark,omitempty"`
+	// OnlyMarked ignores unmarked tags when looking up the last tag; it implies Mark
+	OnlyMarked bool `json:"onlyMarked,omitempty"`
+	// Namespace also publishes every release as <namespace>/<tag>, e.g. refs/releases/v1.2.3
+	Namespace string `json:"namespace,omitempty"`
+	// NamespaceOnly pushes the namespace ref instead of the tag, which stays local
+	NamespaceOnly bool `json:"namespaceOnly,omitempty"`
+	// Trailers are appended to the message of new tags, which makes them annotated
+	Trailers []TagTrailer `json:"trailers,omitempty"`
+}
+
+// marksTags reports whether new tags carry the trailer
+func (c TagsConfig) marksTags() bool {
+	return c.Mark || c.OnlyMarked
+}
+
+// tagMessage returns the message of the annotated tag of a plan, with the
+// ticket, the configured trailers and the marker as trailers; "" when the tag
+// can be lightweight
+func tagMessage(plan Plan, config TagsConfig) string {
+	var trailers []string
+	if plan.Ticket != "" {
+		trailers = append(trailers, "Ticket: "+plan.Ticket)
+	}
+	trailers = append(trailers, plan.Trailers...)
+	if config.marksTags() {
+		trailers = append(trailers, toolTagTrailer)
+	}
+	if len(trailers) == 0 {
+		return ""
+	}
+	return "Release " + plan.Tag + "\n\n" + strings.Join(trailers, "\n")
+}
+
+// hasToolTrailer reports whether the last paragraph of a tag message holds the trailer
+func hasToolTrailer(message string) bool {
+	paragraphs := strings.Split(strings.TrimSpace(message), "\n\n")
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "Created-By") && strings.TrimSpace(value) == "git-publish" {
+			return true
+		}
+	}
+	return false
+}
+
+// isToolTag reports whether tag is an annotated tag created by the tool
+func isToolTag(tag string) bool {
+	message, err := gitClient.TagMessage(tag)
+	return err == nil && hasToolTrailer(message)
+}
+
+// createMarkedTag creates the annotated tag of a plan with a tag message
+func createMarkedTag(plan Plan) error {
+	if err := annotateTag(plan, plan.TagMessage, time.Now()); err != nil {
+		return tagCreationError(plan.Tag, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestHasToolTrailer(t *testing.T) {
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{"", false},
+		{toolTagTrailer, true},
+		{"Release notes\n\nCreated-By: git-publish", true},
+		{"Release notes\n\ncreated-by:  git-publish\nSigned-off-by: Dev <dev@example.com>", true},
+		{"Created-By: git-publish\n\nmoved by hand", false},
+		{"Created-By: someone", false},
+	}
+
+	for _, tt := range tests {
+		if got := hasToolTrailer(tt.message); got != tt.want {
+			t.Errorf("hasToolTrailer(%q) = %v, want %v", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestCreateMarkedTag(t *testing.T) {
+	g := newFakeGitClient("a", "b")
+	useFakeGit(t, g)
+
+	config := defaultConfig
+	config.Tags = TagsConfig{OnlyMarked: true}
+	plan, err := makePlan(config, nil, "main", "v0.0.0", "", "v1.0.0", "")
+	if err != nil {
+		t.Fatalf("makePlan() error = %v", err)
+	}
+	if !plan.OnlyMarkedTags || plan.TagMessage != "Release v1.0.0\n\n"+toolTagTrailer {
+		t.Fatalf("makePlan() = %+v, want a marked tag looked up among marked tags", plan)
+	}
+
+	if err := createMarkedTag(plan); err != nil {
+		t.Fatalf("createMarkedTag() error = %v", err)
+	}
+	if g.tags["v1.0.0"] != "b" || !isToolTag("v1.0.0") {
+		t.Errorf("tag v1.0.0 = %q with message %q, want a marked tag on b", g.tags["v1.0.0"], g.tagMessages["v1.0.0"])
+	}
+	if isToolTag("v9.9.9") {
+		t.Error("isToolTag() of a missing tag = true, want false")
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// matchedTag is a tag listed by the tags command
+type matchedTag struct {
+	Tag     string `json:"tag"`
+	Version string `json:"version"`
+	Format  string `json:"format"`
+	Commit  string `json:"commit"`
+
+	semver semver
+}
+
+// runTagsCommand implements the tags command: it lists the tags of the
+// configured formats whose version satisfies a semver range, lowest first
+func runTagsCommand(args []string) {
+	fs := flag.NewFlagSet("tags", flag.ExitOnError)
+	match := fs.String("match", "", `semver range the versions have to satisfy, e.g. ">=1.4.0 <2.0.0" or "1.x" (default: all)`)
+	branch := fs.String("branch", "", "only list tags of the formats of this branch that are on it")
+	output := fs.String("output", "text", "output format: text or json")
+	fs.Parse(args)
+
+	if *output != "text" && *output != "json" {
+		fmt.Printf("Error: Unknown output format '%s'\n", *output)
+		os.Exit(1)
+	}
+	versions, err := parseVersionRange(*match)
+	if err != nil {
+		fmt.Printf("Error: invalid --match: %v\n", err)
+		os.Exit(1)
+	}
+
+	tags, err := matchTags(readConfig(), versions, *branch)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *output == "json" {
+		data, err := json.MarshalIndent(tags, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	for _, t := range tags {
+		fmt.Println(t.Tag)
+	}
+}
+
+// matchTags returns the tags of the configured formats (those of branch, if
+// given, and only tags on it) whose version is in versions, lowest first
+func matchTags(config Config, versions versionRange, branch string) ([]matchedTag, error) {
+	var formats []string
+	for _, bt := range config.BranchTags {
+		if branch == "" || bt.Branch == branch {
+			formats = append(formats, bt.Tag)
+		}
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("branch '%s' is not configured", branch)
+	}
+	if branch != "" {
+		if _, err := lookupCommit(branch); err != nil {
+			return nil, fmt.Errorf("branch '%s' does not exist", branch)
+		}
+	}
+
+	tags := []matchedTag{}
+	seen := map[string]bool{}
+	for _, format := range uniqueStrings(formats) {
+		prefix := extractPrefix(format)
+		candidates, err := gitClient.ListTags(tagGlob(format))
+		if err != nil {
+			return nil, fmt.Errorf("listing tags: %v", err)
+		}
+		for _, tag := range candidates {
+			if seen[tag] || !validateTagFormat(tag, prefix) {
+				continue
+			}
+			version, ok := parseSemver(strings.TrimPrefix(tag, prefix))
+			if !ok || !versions.matches(version) {
+				continue
+			}
+			if branch != "" && !isTagOnBranchFunc(tag, branch) {
+				continue
+			}
+			commit, err := gitClient.RevParse("refs/tags/" + tag + "^{commit}")
+			if err != nil {
+				return nil, fmt.Errorf("resolving tag %s: %v", tag, err)
+			}
+			seen[tag] = true
+			tags = append(tags, matchedTag{Tag: tag, Version: version.String(), Format: format, Commit: commit, semver: version})
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if cmp := tags[i].semver.compare(tags[j].semver); cmp != 0 {
+			return cmp < 0
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchTags(t *testing.T) {
+	g := newFakeGitClient("a", "b", "c")
+	g.parents["g1"] = "a"
+	g.branches["gray"] = "g1"
+	g.tags = map[string]string{
+		"v1.3.0":  "a",
+		"v1.4.0":  "a",
+		"v1.10.2": "b",
+		"v2.0.0":  "c",
+		"v1.5.0":  "g1",
+		"g1.4.0":  "g1",
+		"v-next":  "c",
+	}
+	useFakeGit(t, g)
+	config := Config{BranchTags: []BranchTagConfig{
+		{Branch: "main", Tag: "v0.0.0"},
+		{Branch: "gray", Tag: "g0.0.0"},
+	}}
+
+	tests := []struct {
+		name    string
+		spec    string
+		branch  string
+		want    []string
+		wantErr bool
+	}{
+		{"range on a branch", ">=1.4.0 <2.0.0", "main", []string{"v1.4.0", "v1.10.2"}, false},
+		{"range on every format", ">=1.4.0 <2.0.0", "", []string{"g1.4.0", "v1.4.0", "v1.5.0", "v1.10.2"}, false},
+		{"all tags of a branch", "", "gray", []string{"g1.4.0"}, false},
+		{"unconfigured branch", "", "develop", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions, err := parseVersionRange(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tags, err := matchTags(config, versions, tt.branch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("matchTags() error = %v, want error %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, tag := range tags {
+				got = append(got, tag.Tag)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matchTags(%q, %q) = %v, want %v", tt.spec, tt.branch, got, tt.want)
+			}
+		})
+	}
+
+	tags, _ := matchTags(config, versionRange{{}}, "main")
+	if first := tags[0]; first != (matchedTag{Tag: "v1.3.0", Version: "1.3.0", Format: "v0.0.0", Commit: "a", semver: semver{1, 3, 0}}) {
+		t.Errorf("first tag = %+v", first)
+	}
+}
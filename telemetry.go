@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// TelemetryConfig is the crash reporting opt-in of the user configuration
+type TelemetryConfig struct {
+	// Enabled turns crash reports on; they are off until the user runs telemetry on
+	Enabled bool `json:"enabled,omitempty"`
+	// Endpoint receives every report as a JSON POST; without it reports are only saved locally
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// crashReport describes a panic without any repository data: no paths, branch
+// or tag names, remotes or function arguments
+type crashReport struct {
+	Time      time.Time `json:"time"`
+	Version   string    `json:"version"`
+	GoVersion string    `json:"goVersion"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	Command   string    `json:"command"`
+	Phase     string    `json:"phase"`
+	Panic     string    `json:"panic"`
+	Stack     string    `json:"stack"`
+}
+
+// The command and phase of the flow currently running, recorded in crash reports
+var (
+	currentCommand = "publish"
+	currentPhase   = "startup"
+)
+
+// enterPhase records which step of the flow is running
+func enterPhase(phase string) {
+	perf.enterPhase(phase)
+	currentPhase = phase
+}
+
+// runTelemetryCommand implements the telemetry command
+func runTelemetryCommand(args []string) {
+	if len(args) < 1 {
+		fmt.Println("Usage: git-publish telemetry on [--endpoint <url>] | off | status")
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "on":
+		fs := flag.NewFlagSet("telemetry on", flag.ExitOnError)
+		endpoint := fs.String("endpoint", "", "URL receiving crash reports as JSON POST (default: only save them locally)")
+		fs.Parse(args[1:])
+		setTelemetry(true, *endpoint)
+	case "off":
+		setTelemetry(false, "")
+	case "status":
+		printTelemetryStatus()
+	default:
+		fmt.Printf("Error: Unknown telemetry command '%s'\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// setTelemetry stores the crash reporting choice in the user configuration
+func setTelemetry(enabled bool, endpoint string) {
+	userConfig, _ := readUserConfig()
+	userConfig.Telemetry = TelemetryConfig{Enabled: enabled, Endpoint: endpoint}
+	if _, err := writeUserConfig(userConfig); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	printTelemetryStatus()
+}
+
+// printTelemetryStatus shows whether crash reports are collected and what they contain
+func printTelemetryStatus() {
+	green := color.New(color.FgGreen).SprintFunc()
+	userConfig, _ := readUserConfig()
+	telemetry := userConfig.Telemetry
+
+	if !telemetry.Enabled {
+		fmt.Println("Crash reporting: off")
+	} else if telemetry.Endpoint == "" {
+		fmt.Printf("Crash reporting: %s (reports are saved locally)\n", green("on"))
+	} else {
+		fmt.Printf("Crash reporting: %s (reports are sent to %s)\n", green("on"), telemetry.Endpoint)
+	}
+
+	if dir, err := crashReportDir(); err == nil {
+		reports, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		fmt.Printf("Saved reports: %d in %s\n", len(reports), dir)
+	}
+	fmt.Println("A report holds the version, Go version, OS, command, phase, panic type and a stack trace without file paths or arguments. No repository data is collected.")
+}
+
+// crashReportDir returns the directory crash reports are saved in
+func crashReportDir() (string, error) {
+	path, err := userConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "crash-reports"), nil
+}
+
+// handlePanic is deferred by main: it turns a panic into a readable error and,
+// if the user opted in, a crash report, then exits
+func handlePanic() {
+	value := recover()
+	if value == nil {
+		return
+	}
+	stack := debug.Stack()
+
+	fmt.Printf("Error: git-publish crashed while running %s (%s): %v\n", currentCommand, currentPhase, value)
+	os.Stderr.Write(stack)
+	restoreAfterPanic()
+
+	if userConfig, _ := readUserConfig(); userConfig.Telemetry.Enabled {
+		reportCrash(userConfig.Telemetry, newCrashReport(value, stack))
+	} else {
+		fmt.Println("Help fix this: 'git-publish telemetry on' enables anonymous crash reports.")
+	}
+	os.Exit(2)
+}
+
+// newCrashReport builds the report of a panic with the given value and stack
+func newCrashReport(value interface{}, stack []byte) crashReport {
+	version := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+	}
+	return crashReport{
+		Time:      time.Now().UTC(),
+		Version:   version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Command:   currentCommand,
+		Phase:     currentPhase,
+		Panic:     panicMessage(value),
+		Stack:     sanitizeStack(string(stack)),
+	}
+}
+
+// panicMessage describes a panic value. Only runtime errors (nil dereferences,
+// index out of range, ...) keep their message, any other value may carry
+// repository data and is reduced to its type.
+func panicMessage(value interface{}) string {
+	if err, ok := value.(runtime.Error); ok {
+		return err.Error()
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// Patterns removing what identifies the user or repository from a stack trace
+var (
+	stackArgsPattern   = regexp.MustCompile(`\([^()]+\)$`)
+	stackOffsetPattern = regexp.MustCompile(` \+0x[0-9a-f]+$`)
+)
+
+// sanitizeStack strips function arguments, which may point at user data, and
+// reduces source paths, which may contain user names, to file names
+func sanitizeStack(stack string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(stack), "\n") {
+		if strings.HasPrefix(line, "\t") {
+			location := stackOffsetPattern.ReplaceAllString(strings.TrimSpace(line), "")
+			lines = append(lines, "\t"+filepath.Base(location))
+			continue
+		}
+		lines = append(lines, stackArgsPattern.ReplaceAllString(line, "(...)"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reportCrash saves the report and sends it to the configured endpoint
+func reportCrash(config TelemetryConfig, report crashReport) {
+	if path, err := saveCrashReport(report); err != nil {
+		fmt.Printf("Warning: could not save crash report: %v\n", err)
+	} else {
+		fmt.Printf("Crash report saved to %s\n", path)
+	}
+
+	if config.Endpoint == "" {
+		return
+	}
+	if err := newAPIClient("", nil).do("POST", config.Endpoint, report, nil); err != nil {
+		fmt.Printf("Warning: could not send crash report: %v\n", err)
+		return
+	}
+	fmt.Println("Crash report sent. Thank you!")
+}
+
+// saveCrashReport writes the report to the crash report directory and returns its path
+func saveCrashReport(report crashReport) (string, error) {
+	dir, err := crashReportDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("crash-%d.json", report.Time.UnixNano()))
+	return path, os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitizeStack(t *testing.T) {
+	stack := "goroutine 1 [running]:\n" +
+		"main.(*apiClient).send(0xc000010000, {0x6a1f20, 0x4})\n" +
+		"\t/home/alice/src/go-git-publish/api.go:118 +0x2c5\n" +
+		"main.main()\n" +
+		"\t/home/alice/src/go-git-publish/main.go:70 +0x1a\n"
+
+	want := "goroutine 1 [running]:\n" +
+		"main.(*apiClient).send(...)\n" +
+		"\tapi.go:118\n" +
+		"main.main()\n" +
+		"\tmain.go:70"
+
+	if got := sanitizeStack(stack); got != want {
+		t.Errorf("sanitizeStack() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPanicMessage(t *testing.T) {
+	var runtimeErr error
+	func() {
+		defer func() { runtimeErr = recover().(error) }()
+		var values []string
+		_ = values[3]
+	}()
+
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"runtime error keeps its message", runtimeErr, "runtime error: index out of range [3] with length 0"},
+		{"string may hold repository data", "tag v1.2.3 on secret-branch", "string"},
+		{"error may hold repository data", errors.New("remote git@example.com failed"), "*errors.errorString"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := panicMessage(tt.value); got != tt.want {
+				t.Errorf("panicMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCrashReport(t *testing.T) {
+	defer func(command, phase string) { currentCommand, currentPhase = command, phase }(currentCommand, currentPhase)
+	currentCommand = "plan"
+	enterPhase("select remote")
+
+	report := newCrashReport("branch secret", debug.Stack())
+	if report.Command != "plan" || report.Phase != "select remote" || report.Panic != "string" {
+		t.Errorf("newCrashReport() = %+v", report)
+	}
+	cwd, _ := os.Getwd()
+	if strings.Contains(report.Stack, cwd) || strings.Contains(report.Stack, "+0x") {
+		t.Errorf("stack was not sanitized:\n%s", report.Stack)
+	}
+}
+
+func TestReportCrash(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+
+	var received crashReport
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	report := crashReport{Time: time.Now(), Command: "publish", Phase: "create tag", Panic: "string"}
+	reportCrash(TelemetryConfig{Enabled: true, Endpoint: server.URL}, report)
+
+	if received.Phase != "create tag" {
+		t.Errorf("endpoint received %+v, want the report", received)
+	}
+	saved, _ := filepath.Glob(filepath.Join(dir, "git-publish", "crash-reports", "*.json"))
+	if len(saved) != 1 {
+		t.Errorf("saved reports = %v, want one", saved)
+	}
+}
+
+func TestSetTelemetry(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+
+	setTelemetry(true, "https://crash.example.com/report")
+	userConfig, _ := readUserConfig()
+	if !userConfig.Telemetry.Enabled || userConfig.Telemetry.Endpoint != "https://crash.example.com/report" {
+		t.Errorf("after telemetry on: %+v", userConfig.Telemetry)
+	}
+
+	setTelemetry(false, "")
+	userConfig, _ = readUserConfig()
+	if userConfig.Telemetry.Enabled {
+		t.Errorf("after telemetry off: %+v", userConfig.Telemetry)
+	}
+}
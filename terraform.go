@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// presetTerraform follows the tag conventions of Terraform module registries:
+// the root module is tagged X.Y.Z without a v, submodules <dir>/vX.Y.Z
+const presetTerraform = "terraform"
+
+// terraformSubmodulePrefix matches the prefix of a submodule tag format
+var terraformSubmodulePrefix = regexp.MustCompile(`^[A-Za-z0-9_.-]+(/[A-Za-z0-9_.-]+)*/v$`)
+
+// validatePreset returns the problems of the configuration under its preset
+func validatePreset(config Config) []string {
+	switch config.Preset {
+	case "":
+		return nil
+	case presetTerraform:
+	default:
+		return []string{fmt.Sprintf("preset %q is not %s", config.Preset, presetTerraform)}
+	}
+
+	var problems []string
+	for _, bt := range config.BranchTags {
+		prefix := extractPrefix(bt.Tag)
+		if prefix == "" {
+			continue
+		}
+		dir, ok := terraformModuleDir(prefix)
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("tag format %q of branch %s is neither 0.0.0 (root module) nor <dir>/v0.0.0 (submodule), as the terraform preset requires", bt.Tag, bt.Branch))
+		case !contains(bt.Paths, dir):
+			problems = append(problems, fmt.Sprintf("tag format %q of branch %s needs \"paths\": [%q]", bt.Tag, bt.Branch, dir))
+		}
+	}
+	return problems
+}
+
+// terraformModuleDir returns the submodule directory of a tag prefix such as
+// modules/vpc/v
+func terraformModuleDir(prefix string) (string, bool) {
+	if !terraformSubmodulePrefix.MatchString(prefix) {
+		return "", false
+	}
+	dir := strings.TrimSuffix(prefix, "/v")
+	if path.Clean(dir) != dir || strings.HasPrefix(dir, "..") || strings.Contains(dir, "/../") {
+		return "", false
+	}
+	return dir, true
+}
+
+// checkPresetTag checks a new tag against the conventions of the preset. For
+// Terraform the version has to be a registry-compatible semantic version and
+// the tagged module has to exist on branch.
+func checkPresetTag(config Config, branch, tagFormat, tag string) error {
+	if config.Preset != presetTerraform {
+		return nil
+	}
+	prefix := extractPrefix(tagFormat)
+	for _, part := range strings.Split(strings.TrimPrefix(tag, prefix), ".") {
+		if len(part) > 1 && part[0] == '0' {
+			return fmt.Errorf("%s is not a semantic version the Terraform registry accepts: %s has a leading zero", tag, part)
+		}
+	}
+
+	dir := "."
+	if prefix != "" {
+		var ok bool
+		if dir, ok = terraformModuleDir(prefix); !ok {
+			return fmt.Errorf("%s is not named <dir>/vX.Y.Z after a module directory", tag)
+		}
+	}
+	files, err := terraformFiles(branchRef(branch))
+	if err != nil {
+		return fmt.Errorf("listing the Terraform files of %s: %v", branch, err)
+	}
+	if !contains(files, dir) {
+		return fmt.Errorf("%s has no Terraform module in %s, which tag %s releases", branch, dir, tag)
+	}
+	return nil
+}
+
+// terraformFiles returns the directories of rev holding *.tf files, "." for
+// the root
+func terraformFiles(rev string) ([]string, error) {
+	files, err := gitClient.ListFiles(rev)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, file := range files {
+		if strings.HasSuffix(file, ".tf") {
+			dirs = append(dirs, path.Dir(file))
+		}
+	}
+	dirs = uniqueStrings(dirs)
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// terraformBranchTags returns the branchTags of the terraform preset for the
+// modules on branch: the root module and every module under modules/
+func terraformBranchTags(branch string) ([]BranchTagConfig, error) {
+	dirs, err := terraformFiles(branchRef(branch))
+	if err != nil {
+		return nil, err
+	}
+	var branchTags []BranchTagConfig
+	for _, dir := range dirs {
+		switch {
+		case dir == ".":
+			branchTags = append([]BranchTagConfig{{Branch: branch, Tag: "0.0.0"}}, branchTags...)
+		case strings.HasPrefix(dir, "modules/") && strings.Count(dir, "/") == 1:
+			branchTags = append(branchTags, BranchTagConfig{Branch: branch, Tag: dir + "/v0.0.0", Paths: []string{dir}})
+		}
+	}
+	if len(branchTags) == 0 {
+		return nil, fmt.Errorf("no Terraform module (*.tf files) found on %s", branch)
+	}
+	return branchTags, nil
+}
+
+// terraformBranchTag is a branch mapping as written by init --preset terraform
+type terraformBranchTag struct {
+	Branch string   `json:"branch"`
+	Tag    string   `json:"tag"`
+	Paths  []string `json:"paths,omitempty"`
+}
+
+// writeTerraformConfig writes a publish.json with the terraform preset and
+// the tag formats of the modules on the default branch to path
+func writeTerraformConfig(path string) error {
+	branch, err := gitClient.DefaultBranch("origin")
+	if err != nil || branch == "" {
+		branch = "main"
+	}
+	branchTags, err := terraformBranchTags(branch)
+	if err != nil {
+		return err
+	}
+	config := struct {
+		Preset     string               `json:"preset"`
+		BranchTags []terraformBranchTag `json:"branchTags"`
+	}{Preset: presetTerraform}
+	for _, bt := range branchTags {
+		config.BranchTags = append(config.BranchTags, terraformBranchTag{bt.Branch, bt.Tag, bt.Paths})
+		fmt.Printf("  %s: %s\n", bt.Branch, bt.Tag)
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
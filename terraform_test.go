@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidatePreset(t *testing.T) {
+	tests := []struct {
+		name       string
+		branchTags []BranchTagConfig
+		problems   int
+	}{
+		{"root module", []BranchTagConfig{{Branch: "main", Tag: "0.0.0"}}, 0},
+		{"submodule", []BranchTagConfig{{Branch: "main", Tag: "modules/vpc/v0.0.0", Paths: []string{"modules/vpc"}}}, 0},
+		{"v prefix on the root", []BranchTagConfig{{Branch: "main", Tag: "v0.0.0"}}, 1},
+		{"submodule without v", []BranchTagConfig{{Branch: "main", Tag: "modules/vpc/0.0.0", Paths: []string{"modules/vpc"}}}, 1},
+		{"submodule without paths", []BranchTagConfig{{Branch: "main", Tag: "modules/vpc/v0.0.0"}}, 1},
+		{"parent directory", []BranchTagConfig{{Branch: "main", Tag: "../vpc/v0.0.0", Paths: []string{"../vpc"}}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := validatePreset(Config{Preset: presetTerraform, BranchTags: tt.branchTags})
+			if len(problems) != tt.problems {
+				t.Errorf("validatePreset() = %q, want %d problem(s)", problems, tt.problems)
+			}
+		})
+	}
+}
+
+// useTerraformTestRepo makes a fake repository whose main branch holds a root
+// module, two directories under modules/ and an example
+func useTerraformTestRepo(t *testing.T) {
+	g := newFakeGitClient("c1")
+	g.files["c1"] = map[string]string{}
+	for _, file := range []string{"main.tf", "modules/vpc/main.tf", "modules/vpc/variables.tf", "modules/dns/README.md", "examples/basic/main.tf"} {
+		g.files["c1"][file] = "\n"
+	}
+	useFakeGit(t, g)
+}
+
+func TestCheckPresetTag(t *testing.T) {
+	useTerraformTestRepo(t)
+	config := Config{Preset: presetTerraform}
+
+	tests := []struct {
+		tagFormat string
+		tag       string
+		wantErr   string
+	}{
+		{"0.0.0", "1.2.3", ""},
+		{"0.0.0", "1.02.3", "leading zero"},
+		{"modules/vpc/v0.0.0", "modules/vpc/v1.0.0", ""},
+		{"modules/dns/v0.0.0", "modules/dns/v1.0.0", "no Terraform module in modules/dns"},
+		{"modules/vpc/0.0.0", "modules/vpc/1.0.0", "<dir>/vX.Y.Z"},
+	}
+	for _, tt := range tests {
+		err := checkPresetTag(config, "main", tt.tagFormat, tt.tag)
+		if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("checkPresetTag(%q, %q) = %v, want %q", tt.tagFormat, tt.tag, err, tt.wantErr)
+		}
+	}
+
+	if err := checkPresetTag(Config{}, "main", "v0.0.0", "v01.0.0"); err != nil {
+		t.Errorf("checkPresetTag() without preset = %v", err)
+	}
+}
+
+func TestTerraformBranchTags(t *testing.T) {
+	useTerraformTestRepo(t)
+
+	got, err := terraformBranchTags("main")
+	if err != nil {
+		t.Fatalf("terraformBranchTags: %v", err)
+	}
+	want := []BranchTagConfig{
+		{Branch: "main", Tag: "0.0.0"},
+		{Branch: "main", Tag: "modules/vpc/v0.0.0", Paths: []string{"modules/vpc"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("terraformBranchTags() = %+v, want %+v", got, want)
+	}
+	if problems := validatePreset(Config{Preset: presetTerraform, BranchTags: got}); len(problems) != 0 {
+		t.Errorf("generated branchTags are invalid: %q", problems)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Values of ticket.mode
+const (
+	ticketOff      = "off"
+	ticketOptional = "optional"
+	ticketRequired = "required"
+)
+
+// TicketConfig asks for a change ticket, which is recorded in the tag message
+// and, through the plan, in the audit log
+type TicketConfig struct {
+	// Mode is off (default), optional or required
+	Mode string `json:"mode,omitempty"`
+	// Pattern is a regular expression ticket IDs have to match, e.g. ^CHG-[0-9]+$
+	Pattern string `json:"pattern,omitempty"`
+	// Prompt replaces the default question
+	Prompt string `json:"prompt,omitempty"`
+	// JiraURL checks that the ticket is an issue on this Jira server
+	JiraURL string `json:"jiraUrl,omitempty"`
+	// ServiceNowURL checks that the ticket is a change request on this ServiceNow instance
+	ServiceNowURL string `json:"serviceNowUrl,omitempty"`
+}
+
+// enabled reports whether a ticket is asked for
+func (c TicketConfig) enabled() bool {
+	return c.Mode == ticketOptional || c.Mode == ticketRequired
+}
+
+// validateTicketConfig returns the problems of a ticket configuration
+func validateTicketConfig(config TicketConfig) []string {
+	var problems []string
+	switch config.Mode {
+	case "", ticketOff, ticketOptional, ticketRequired:
+	default:
+		problems = append(problems, fmt.Sprintf("ticket.mode %q is not off, optional or required", config.Mode))
+	}
+	if _, err := regexp.Compile(config.Pattern); err != nil {
+		problems = append(problems, fmt.Sprintf("ticket.pattern: %v", err))
+	}
+	return problems
+}
+
+// checkTicket validates a ticket ID against the configured pattern and ticket
+// systems and returns the ticket's title, if a ticket system was asked
+func checkTicket(config TicketConfig, ticket string) (string, error) {
+	if ticket == "" {
+		if config.Mode == ticketRequired {
+			return "", fmt.Errorf("a change ticket is required")
+		}
+		return "", nil
+	}
+	if config.Pattern != "" {
+		pattern, err := regexp.Compile(config.Pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid ticket pattern: %v", err)
+		}
+		if !pattern.MatchString(ticket) {
+			return "", fmt.Errorf("ticket %s does not match %s", ticket, config.Pattern)
+		}
+	}
+
+	var titles []string
+	if config.JiraURL != "" {
+		title, err := jiraIssueTitle(config.JiraURL, ticket)
+		if err != nil {
+			return "", err
+		}
+		titles = append(titles, title)
+	}
+	if config.ServiceNowURL != "" {
+		title, err := serviceNowChangeTitle(config.ServiceNowURL, ticket)
+		if err != nil {
+			return "", err
+		}
+		titles = append(titles, title)
+	}
+	return strings.Join(titles, " / "), nil
+}
+
+// promptForTicket asks for the change ticket until a valid one (or, if it is
+// optional, none) is entered
+func promptForTicket(config TicketConfig) string {
+	question := config.Prompt
+	if question == "" {
+		question = "Change ticket"
+		if config.Mode == ticketOptional {
+			question += " (optional)"
+		}
+	}
+
+	var title string
+	ticket, err := prompter.Input(question+":", "", func(input string) error {
+		var err error
+		title, err = checkTicket(config, strings.TrimSpace(input))
+		return err
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if title != "" {
+		fmt.Printf("Ticket %s: %s\n", strings.TrimSpace(ticket), title)
+	}
+	return strings.TrimSpace(ticket)
+}
+
+// setTicket records the change ticket in a plan and its tag message
+func setTicket(plan *Plan, config Config, ticket string) {
+	plan.Ticket = ticket
+	plan.TagMessage = tagMessage(*plan, config.Tags)
+}
+
+// basicAuthHeader returns headers authenticating as user, or with a bearer
+// token when no user is given; no headers without credentials
+func basicAuthHeader(user, secret string) http.Header {
+	header := http.Header{}
+	switch {
+	case user != "":
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+secret)))
+	case secret != "":
+		header.Set("Authorization", "Bearer "+secret)
+	}
+	return header
+}
+
+// jiraIssueTitle returns the summary of a Jira issue, authenticating with
+// JIRA_USER and JIRA_TOKEN (Jira Cloud) or the personal access token JIRA_TOKEN
+func jiraIssueTitle(baseURL, key string) (string, error) {
+	api := newAPIClient(baseURL, basicAuthHeader(os.Getenv("JIRA_USER"), os.Getenv("JIRA_TOKEN")))
+
+	var issue struct {
+		Fields struct {
+			Summary string `json:"summary"`
+		} `json:"fields"`
+	}
+	err := api.do("GET", "/rest/api/2/issue/"+url.PathEscape(key)+"?fields=summary", nil, &issue)
+	if isNotFound(err) {
+		return "", fmt.Errorf("ticket %s not found in Jira", key)
+	}
+	if err != nil {
+		return "", fmt.Errorf("checking ticket %s in Jira: %v", key, err)
+	}
+	return issue.Fields.Summary, nil
+}
+
+// serviceNowChangeTitle returns the short description of a ServiceNow change
+// request, authenticating with SERVICENOW_USER and SERVICENOW_PASSWORD
+func serviceNowChangeTitle(baseURL, number string) (string, error) {
+	api := newAPIClient(baseURL, basicAuthHeader(os.Getenv("SERVICENOW_USER"), os.Getenv("SERVICENOW_PASSWORD")))
+
+	query := url.Values{
+		"sysparm_query":  {"number=" + number},
+		"sysparm_fields": {"number,short_description"},
+		"sysparm_limit":  {"1"},
+	}
+	var response struct {
+		Result []struct {
+			ShortDescription string `json:"short_description"`
+		} `json:"result"`
+	}
+	if err := api.do("GET", "/api/now/table/change_request?"+query.Encode(), nil, &response); err != nil {
+		return "", fmt.Errorf("checking change request %s in ServiceNow: %v", number, err)
+	}
+	if len(response.Result) == 0 {
+		return "", fmt.Errorf("change request %s not found in ServiceNow", number)
+	}
+	return response.Result[0].ShortDescription, nil
+}
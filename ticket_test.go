@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckTicket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/2/issue/OPS-1":
+			if r.Header.Get("Authorization") != "Bearer jira-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"key": "OPS-1", "fields": {"summary": "Release 1.0"}}`))
+		case r.URL.Path == "/api/now/table/change_request" && r.URL.Query().Get("sysparm_query") == "number=CHG0001":
+			if user, password, _ := r.BasicAuth(); user != "bot" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"result": [{"number": "CHG0001", "short_description": "Deploy billing"}]}`))
+		case r.URL.Path == "/api/now/table/change_request":
+			w.Write([]byte(`{"result": []}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("JIRA_USER", "")
+	t.Setenv("JIRA_TOKEN", "jira-token")
+	t.Setenv("SERVICENOW_USER", "bot")
+	t.Setenv("SERVICENOW_PASSWORD", "secret")
+
+	tests := []struct {
+		name      string
+		config    TicketConfig
+		ticket    string
+		wantTitle string
+		wantErr   string
+	}{
+		{"optional and empty", TicketConfig{Mode: ticketOptional, Pattern: `^CHG-\d+$`}, "", "", ""},
+		{"required and empty", TicketConfig{Mode: ticketRequired}, "", "", "a change ticket is required"},
+		{"pattern", TicketConfig{Mode: ticketRequired, Pattern: `^CHG-\d+$`}, "CHG-42", "", ""},
+		{"pattern mismatch", TicketConfig{Mode: ticketRequired, Pattern: `^CHG-\d+$`}, "chg-42", "", "does not match"},
+		{"jira issue", TicketConfig{Mode: ticketRequired, JiraURL: server.URL}, "OPS-1", "Release 1.0", ""},
+		{"missing jira issue", TicketConfig{Mode: ticketRequired, JiraURL: server.URL}, "OPS-2", "", "not found in Jira"},
+		{"servicenow change", TicketConfig{Mode: ticketRequired, ServiceNowURL: server.URL}, "CHG0001", "Deploy billing", ""},
+		{"missing servicenow change", TicketConfig{Mode: ticketRequired, ServiceNowURL: server.URL}, "CHG0002", "", "not found in ServiceNow"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, err := checkTicket(tt.config, tt.ticket)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("checkTicket() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || title != tt.wantTitle {
+				t.Errorf("checkTicket() = %q, %v, want %q", title, err, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestPromptForTicket(t *testing.T) {
+	p := useScriptedPrompter(t, "", "CHG", " CHG-7 ")
+	config := TicketConfig{Mode: ticketRequired, Pattern: `^CHG-\d+$`}
+	if got := promptForTicket(config); got != "CHG-7" {
+		t.Errorf("promptForTicket() = %q, want CHG-7", got)
+	}
+	if len(p.asked) != 3 {
+		t.Errorf("asked %d times, want 3", len(p.asked))
+	}
+}
+
+func TestTicketTagMessage(t *testing.T) {
+	plan := Plan{Tag: "v1.2.0"}
+	setTicket(&plan, Config{}, "CHG-7")
+	if plan.TagMessage != "Release v1.2.0\n\nTicket: CHG-7" {
+		t.Errorf("TagMessage = %q", plan.TagMessage)
+	}
+	setTicket(&plan, Config{Tags: TagsConfig{Mark: true}}, "CHG-7")
+	if plan.TagMessage != "Release v1.2.0\n\nTicket: CHG-7\n"+toolTagTrailer || !hasToolTrailer(plan.TagMessage) {
+		t.Errorf("marked TagMessage = %q", plan.TagMessage)
+	}
+	setTicket(&plan, Config{}, "")
+	if plan.TagMessage != "" {
+		t.Errorf("TagMessage without ticket = %q, want a lightweight tag", plan.TagMessage)
+	}
+}
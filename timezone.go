@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// teamLocation returns the time zone release times are shown in besides UTC:
+// timeZone of the user config, e.g. Europe/Berlin, or else the local zone
+func teamLocation() *time.Location {
+	userConfig, _, _ := loadUserConfig()
+	if userConfig.TimeZone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(userConfig.TimeZone)
+	if err != nil {
+		fmt.Printf("Warning: ignoring timeZone %q of the user config: %v\n", userConfig.TimeZone, err)
+		return time.Local
+	}
+	return location
+}
+
+// formatReleaseTime shows a release time in UTC and in the team's time zone,
+// so distributed teams agree on when a release happened
+func formatReleaseTime(t time.Time) string {
+	return formatTimeIn(t, teamLocation())
+}
+
+// formatTimeIn shows t in UTC followed by its time in location, e.g.
+// "2024-07-01 22:30 UTC (2024-07-02 08:30 AEST)"; the date is only repeated
+// when it differs, and a location at UTC adds nothing
+func formatTimeIn(t time.Time, location *time.Location) string {
+	utc := t.UTC()
+	formatted := utc.Format("2006-01-02 15:04 UTC")
+	local := t.In(location)
+	if isUTC(local) {
+		return formatted
+	}
+	layout := "15:04 MST"
+	if local.Format("2006-01-02") != utc.Format("2006-01-02") {
+		layout = "2006-01-02 15:04 MST"
+	}
+	return fmt.Sprintf("%s (%s)", formatted, local.Format(layout))
+}
+
+// isUTC reports whether t is in UTC, under whatever name
+func isUTC(t time.Time) bool {
+	name, offset := t.Zone()
+	return offset == 0 && (name == "UTC" || name == "GMT")
+}
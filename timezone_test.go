@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatTimeIn(t *testing.T) {
+	release := time.Date(2024, 7, 1, 22, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		location *time.Location
+		want     string
+	}{
+		{"utc", time.UTC, "2024-07-01 22:30 UTC"},
+		{"same day", time.FixedZone("EDT", -4*3600), "2024-07-01 22:30 UTC (18:30 EDT)"},
+		{"next day", time.FixedZone("AEST", 10*3600), "2024-07-01 22:30 UTC (2024-07-02 08:30 AEST)"},
+		{"named utc", time.FixedZone("GMT", 0), "2024-07-01 22:30 UTC"},
+	}
+	for _, tt := range tests {
+		if got := formatTimeIn(release, tt.location); got != tt.want {
+			t.Errorf("%s: formatTimeIn() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTeamLocation(t *testing.T) {
+	if _, err := time.LoadLocation("Asia/Tokyo"); err != nil {
+		t.Skip("no time zone database")
+	}
+	tests := []struct {
+		timeZone    string
+		want        string
+		wantWarning bool
+	}{
+		{"", time.Local.String(), false},
+		{"Asia/Tokyo", "Asia/Tokyo", false},
+		{"Mars/Olympus", time.Local.String(), true},
+	}
+	for _, tt := range tests {
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		t.Setenv("HOME", t.TempDir())
+		writeUserConfig(UserConfig{TimeZone: tt.timeZone})
+		var got *time.Location
+		output := captureOutput(func() { got = teamLocation() })
+		if got.String() != tt.want || strings.Contains(output, "Warning") != tt.wantWarning {
+			t.Errorf("teamLocation() with %q = %s, printing %q", tt.timeZone, got, output)
+		}
+	}
+}
+
+func TestAuditLogTeamTime(t *testing.T) {
+	if _, err := time.LoadLocation("Asia/Tokyo"); err != nil {
+		t.Skip("no time zone database")
+	}
+	newBlobTestRepo(t)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	writeUserConfig(UserConfig{TimeZone: "Asia/Tokyo"})
+
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	e := event{Type: eventPublished, Time: time.Date(2024, 7, 2, 8, 30, 0, 0, tokyo), Plan: Plan{Tag: "v1.0.0"}}
+	if err := appendAuditLog(e); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := auditLogPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["time"] != "2024-07-01T23:30:00Z" || entry["teamTime"] != "2024-07-02T08:30:00+09:00" || entry["type"] != eventPublished {
+		t.Errorf("audit log entry = %s", data)
+	}
+}
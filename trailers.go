@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// TagTrailer is a trailer appended to the annotated tag message, e.g.
+// Build-Id, so automation can read structured metadata from the tag
+type TagTrailer struct {
+	// Key is the trailer name, e.g. Release-Manager
+	Key string `json:"key"`
+	// Env is the environment variable holding the value, e.g. CI_PIPELINE_URL
+	Env string `json:"env,omitempty"`
+	// Prompt asks for the value when neither --trailer nor Env give one
+	Prompt string `json:"prompt,omitempty"`
+	// Required refuses releases without a value
+	Required bool `json:"required,omitempty"`
+}
+
+// trailerKeyPattern matches the keys git interpret-trailers accepts
+var trailerKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// reservedTrailerKeys are written by the tool itself
+var reservedTrailerKeys = []string{"Created-By", "Ticket"}
+
+// validateTagTrailers returns the problems of the configured trailers
+func validateTagTrailers(trailers []TagTrailer) []string {
+	var problems []string
+	seen := map[string]bool{}
+	for _, trailer := range trailers {
+		if err := checkTrailerKey(trailer.Key); err != nil {
+			problems = append(problems, fmt.Sprintf("tags.trailers: %v", err))
+		}
+		if seen[strings.ToLower(trailer.Key)] {
+			problems = append(problems, fmt.Sprintf("tags.trailers: %s is configured twice", trailer.Key))
+		}
+		seen[strings.ToLower(trailer.Key)] = true
+		if trailer.Env == "" && trailer.Prompt == "" && trailer.Required {
+			problems = append(problems, fmt.Sprintf("tags.trailers: required trailer %s needs env or prompt", trailer.Key))
+		}
+	}
+	return problems
+}
+
+// checkTrailerKey checks that key can be a trailer of its own
+func checkTrailerKey(key string) error {
+	if !trailerKeyPattern.MatchString(key) {
+		return fmt.Errorf("%q is not a trailer key like Build-Id", key)
+	}
+	for _, reserved := range reservedTrailerKeys {
+		if strings.EqualFold(key, reserved) {
+			return fmt.Errorf("trailer %s is written by git-publish itself", key)
+		}
+	}
+	return nil
+}
+
+// parseTrailerFlags turns --trailer Key=Value flags into values by key
+func parseTrailerFlags(flags []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, flag := range flags {
+		key, value, ok := strings.Cut(flag, "=")
+		if !ok {
+			return nil, fmt.Errorf("--trailer %q is not Key=Value", flag)
+		}
+		if err := checkTrailerKey(key); err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// resolveTrailers returns the trailers of a new tag as "Key: value" lines, in
+// the configured order followed by the other flag values. A value comes from
+// the flags, else from the environment, else (with ask) from its prompt.
+// Trailers without a value are left out unless they are required.
+func resolveTrailers(trailers []TagTrailer, flags []string, ask bool) ([]string, error) {
+	values, err := parseTrailerFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	add := func(key, value string) error {
+		value = strings.TrimSpace(value)
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("the value of trailer %s spans several lines", key)
+		}
+		if value != "" {
+			lines = append(lines, key+": "+value)
+		}
+		return nil
+	}
+
+	for _, trailer := range trailers {
+		value, ok := values[trailer.Key]
+		delete(values, trailer.Key)
+		if !ok && trailer.Env != "" {
+			value = os.Getenv(trailer.Env)
+		}
+		if strings.TrimSpace(value) == "" && ask && trailer.Prompt != "" {
+			answer, err := prompter.Input(trailer.Prompt+":", "", func(input string) error {
+				if trailer.Required && strings.TrimSpace(input) == "" {
+					return fmt.Errorf("%s is required", trailer.Key)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			value = answer
+		}
+		if trailer.Required && strings.TrimSpace(value) == "" {
+			hint := "--trailer " + trailer.Key + "=<value>"
+			if trailer.Env != "" {
+				hint += " or " + trailer.Env
+			}
+			return nil, fmt.Errorf("trailer %s is required; set it with %s", trailer.Key, hint)
+		}
+		if err := add(trailer.Key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	// Keys only given as flags follow in the order they were passed
+	for _, flag := range flags {
+		key, _, _ := strings.Cut(flag, "=")
+		if value, ok := values[key]; ok {
+			delete(values, key)
+			if err := add(key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return lines, nil
+}
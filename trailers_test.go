@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateTagTrailers(t *testing.T) {
+	tests := []struct {
+		name     string
+		trailers []TagTrailer
+		want     string
+	}{
+		{"none", nil, ""},
+		{"valid", []TagTrailer{{Key: "Build-Id", Env: "BUILD_ID"}, {Key: "Release-Manager", Prompt: "Release manager", Required: true}}, ""},
+		{"bad key", []TagTrailer{{Key: "Build Id", Env: "BUILD_ID"}}, "is not a trailer key"},
+		{"reserved key", []TagTrailer{{Key: "created-by", Env: "USER"}}, "written by git-publish"},
+		{"twice", []TagTrailer{{Key: "Build-Id", Env: "A"}, {Key: "build-id", Env: "B"}}, "configured twice"},
+		{"required without a source", []TagTrailer{{Key: "Build-Id", Required: true}}, "needs env or prompt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := validateTagTrailers(tt.trailers)
+			if got := strings.Join(problems, "; "); (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("validateTagTrailers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveTrailers(t *testing.T) {
+	t.Setenv("BUILD_ID", "1234")
+	t.Setenv("PIPELINE_URL", "")
+	trailers := []TagTrailer{
+		{Key: "Build-Id", Env: "BUILD_ID"},
+		{Key: "Pipeline-Url", Env: "PIPELINE_URL"},
+		{Key: "Release-Manager", Prompt: "Release manager", Required: true},
+	}
+
+	tests := []struct {
+		name      string
+		flags     []string
+		answers   []string
+		ask       bool
+		want      []string
+		wantAsked int
+		wantErr   string
+	}{
+		{"environment and prompt", nil, []string{"Ann"}, true, []string{"Build-Id: 1234", "Release-Manager: Ann"}, 1, ""},
+		{"required prompt asked again", nil, []string{"", "Ann"}, true, []string{"Build-Id: 1234", "Release-Manager: Ann"}, 2, ""},
+		{"flags win", []string{"Release-Manager=Bob", "Build-Id=99", "Deploy-Window=night"}, nil, true, []string{"Build-Id: 99", "Release-Manager: Bob", "Deploy-Window: night"}, 0, ""},
+		{"required without prompting", nil, nil, false, nil, 0, "trailer Release-Manager is required"},
+		{"flag without a value", []string{"Build-Id"}, nil, true, nil, 0, "not Key=Value"},
+		{"reserved flag", []string{"Ticket=CHG-1"}, nil, true, nil, 0, "written by git-publish"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := useScriptedPrompter(t, tt.answers...)
+			var got []string
+			var err error
+			captureOutput(func() { got, err = resolveTrailers(trailers, tt.flags, tt.ask) })
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("resolveTrailers() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) || len(p.asked) != tt.wantAsked {
+				t.Errorf("resolveTrailers() = %q, %v after %d questions, want %q after %d", got, err, len(p.asked), tt.want, tt.wantAsked)
+			}
+		})
+	}
+}
+
+func TestTagMessageTrailers(t *testing.T) {
+	plan := Plan{Tag: "v1.0.0", Ticket: "CHG-1", Trailers: []string{"Build-Id: 1234"}}
+	want := "Release v1.0.0\n\nTicket: CHG-1\nBuild-Id: 1234\n" + toolTagTrailer
+	if got := tagMessage(plan, TagsConfig{Mark: true}); got != want {
+		t.Errorf("tagMessage() = %q, want %q", got, want)
+	}
+	plan.Ticket = ""
+	if got := tagMessage(plan, TagsConfig{}); got != "Release v1.0.0\n\nBuild-Id: 1234" {
+		t.Errorf("tagMessage() without ticket and marker = %q", got)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTrainVersion is the version of a release train unless configured otherwise
+const defaultTrainVersion = "{year}.{yearTrain}.{patch}"
+
+// TrainConfig derives the version of a branch from a release train schedule
+// instead of incrementing the last tag
+type TrainConfig struct {
+	// Cadence is weekly, biweekly, monthly, quarterly or a number of days or weeks, e.g. 21d or 3w
+	Cadence string `json:"cadence,omitempty"`
+	// Start is the day the first train left, YYYY-MM-DD
+	Start string `json:"start,omitempty"`
+	// Version builds X.Y.Z from {year}, {yy}, {month}, {train} (counted from
+	// Start), {yearTrain} (counted within the year) and {patch}, which counts
+	// the releases of a train from 0 (default: {year}.{yearTrain}.{patch})
+	Version string `json:"version,omitempty"`
+}
+
+// enabled reports whether the branch runs on a train
+func (c TrainConfig) enabled() bool {
+	return c.Cadence != ""
+}
+
+// trainPlaceholders are the placeholders of TrainConfig.Version
+var trainPlaceholders = []string{"{year}", "{yy}", "{month}", "{train}", "{yearTrain}", "{patch}"}
+
+// trainNumberField matches a literal field of TrainConfig.Version
+var trainNumberField = regexp.MustCompile(`^\d+$`)
+
+// trainInfo is the train a date falls into
+type trainInfo struct {
+	// Number counts the trains from Start, YearNumber the trains that left in the year of Leaves
+	Number     int
+	YearNumber int
+	Leaves     time.Time
+	Next       time.Time
+}
+
+// validateTrainConfig returns the problems of the train of a branch
+func validateTrainConfig(branch string, config TrainConfig) []string {
+	if !config.enabled() {
+		return nil
+	}
+	var problems []string
+	if _, _, err := parseCadence(config.Cadence); err != nil {
+		problems = append(problems, fmt.Sprintf("train of branch %s: %v", branch, err))
+	}
+	if _, err := time.Parse("2006-01-02", config.Start); err != nil {
+		problems = append(problems, fmt.Sprintf("train of branch %s: start %q is not YYYY-MM-DD", branch, config.Start))
+	}
+	if _, err := trainVersionFields(config.Version); err != nil {
+		problems = append(problems, fmt.Sprintf("train of branch %s: %v", branch, err))
+	}
+	return problems
+}
+
+// parseCadence returns the length of a train in days or months
+func parseCadence(cadence string) (days, months int, err error) {
+	switch cadence {
+	case "weekly":
+		return 7, 0, nil
+	case "biweekly":
+		return 14, 0, nil
+	case "monthly":
+		return 0, 1, nil
+	case "quarterly":
+		return 0, 3, nil
+	}
+	if n, err := strconv.Atoi(strings.TrimRight(cadence, "dw")); err == nil && n > 0 && len(cadence) > 1 {
+		switch cadence[len(cadence)-1] {
+		case 'd':
+			return n, 0, nil
+		case 'w':
+			return 7 * n, 0, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("cadence %q is not weekly, biweekly, monthly, quarterly, <n>d or <n>w", cadence)
+}
+
+// trainVersionFields splits a version template into its three fields, each a
+// placeholder or a number, one of them {patch}
+func trainVersionFields(version string) ([]string, error) {
+	if version == "" {
+		version = defaultTrainVersion
+	}
+	fields := strings.Split(version, ".")
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("version %q does not have three fields X.Y.Z", version)
+	}
+	patches := 0
+	for _, field := range fields {
+		known := trainNumberField.MatchString(field)
+		for _, placeholder := range trainPlaceholders {
+			known = known || field == placeholder
+		}
+		if !known {
+			return nil, fmt.Errorf("version field %q is not a number or one of %s", field, strings.Join(trainPlaceholders, ", "))
+		}
+		if field == "{patch}" {
+			patches++
+		}
+	}
+	if patches != 1 {
+		return nil, fmt.Errorf("version %q needs exactly one {patch} field", version)
+	}
+	return fields, nil
+}
+
+// trainAt returns the train that has left most recently at now
+func trainAt(config TrainConfig, now time.Time) (trainInfo, error) {
+	days, months, err := parseCadence(config.Cadence)
+	if err != nil {
+		return trainInfo{}, err
+	}
+	start, err := time.ParseInLocation("2006-01-02", config.Start, now.Location())
+	if err != nil {
+		return trainInfo{}, fmt.Errorf("train start %q is not YYYY-MM-DD", config.Start)
+	}
+	if now.Before(start) {
+		return trainInfo{}, fmt.Errorf("the first train leaves on %s", config.Start)
+	}
+
+	leaves := func(n int) time.Time {
+		return start.AddDate(0, (n-1)*months, (n-1)*days)
+	}
+	n := 1
+	if days > 0 {
+		n = int(now.Sub(start).Hours()/24)/days + 1
+	}
+	// Calendar months have different lengths and days may lack an hour at DST changes
+	for n > 1 && leaves(n).After(now) {
+		n--
+	}
+	for !leaves(n + 1).After(now) {
+		n++
+	}
+
+	info := trainInfo{Number: n, Leaves: leaves(n), Next: leaves(n + 1)}
+	for i := n; i >= 1 && leaves(i).Year() == info.Leaves.Year(); i-- {
+		info.YearNumber++
+	}
+	return info, nil
+}
+
+// trainTag returns the tag of the current train: the first release of a train
+// gets patch 0, further ones count up from the last tag. It fails if the train
+// version is not greater than the last tag.
+func trainTag(config TrainConfig, tagFormat, lastTag string, now time.Time) (string, trainInfo, error) {
+	info, err := trainAt(config, now)
+	if err != nil {
+		return "", info, err
+	}
+	fields, err := trainVersionFields(config.Version)
+	if err != nil {
+		return "", info, err
+	}
+
+	values := map[string]int{
+		"{year}":      info.Leaves.Year(),
+		"{yy}":        info.Leaves.Year() % 100,
+		"{month}":     int(info.Leaves.Month()),
+		"{train}":     info.Number,
+		"{yearTrain}": info.YearNumber,
+		"{patch}":     0,
+	}
+	prefix := extractPrefix(tagFormat)
+	version := func() string {
+		parts := make([]string, len(fields))
+		for i, field := range fields {
+			if value, ok := values[field]; ok {
+				parts[i] = strconv.Itoa(value)
+			} else {
+				parts[i] = field
+			}
+		}
+		return prefix + strings.Join(parts, ".")
+	}
+
+	// Later releases of the same train share every field but the patch
+	if last, ok := tagVersion(lastTag, prefix); ok {
+		current, _ := tagVersion(version(), prefix)
+		sameTrain := true
+		patchIndex := 0
+		for i, field := range fields {
+			if field == "{patch}" {
+				patchIndex = i
+			} else if current[i] != last[i] {
+				sameTrain = false
+			}
+		}
+		if sameTrain {
+			values["{patch}"] = last[patchIndex] + 1
+		}
+	}
+
+	tag := version()
+	if lastTag != "" && !isTagVersionGreater(tag, lastTag) {
+		return "", info, fmt.Errorf("release train version %s is not greater than the last tag %s; check the train's start and version", tag, lastTag)
+	}
+	return tag, info, nil
+}
+
+// describeTrain explains where a train tag comes from
+func describeTrain(info trainInfo) string {
+	return fmt.Sprintf("Release train %d (%d. of %d) left on %s, the next one leaves on %s",
+		info.Number, info.YearNumber, info.Leaves.Year(), info.Leaves.Format("2006-01-02"), info.Next.Format("2006-01-02"))
+}
+
+// defaultNextTag returns the suggested tag of a branch: the train version for
+// branches on a train, otherwise the next patch version
+func defaultNextTag(bt BranchTagConfig, lastTag string) string {
+	if bt.Train.enabled() {
+		if tag, _, err := trainTag(bt.Train, bt.Tag, lastTag, time.Now()); err == nil {
+			return tag
+		}
+	}
+	return calculateNextTag(lastTag, bt.Tag)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrainAt(t *testing.T) {
+	tests := []struct {
+		cadence    string
+		start      string
+		now        string
+		wantNumber int
+		wantYear   int
+		wantLeaves string
+		wantErr    bool
+	}{
+		{"biweekly", "2024-01-01", "2024-01-01", 1, 1, "2024-01-01", false},
+		{"biweekly", "2024-01-01", "2024-01-14", 1, 1, "2024-01-01", false},
+		{"biweekly", "2024-01-01", "2024-01-15", 2, 2, "2024-01-15", false},
+		{"biweekly", "2023-12-18", "2024-01-15", 3, 2, "2024-01-15", false},
+		{"weekly", "2024-01-01", "2024-03-31", 13, 13, "2024-03-25", false},
+		{"3w", "2024-01-01", "2024-01-22", 2, 2, "2024-01-22", false},
+		{"10d", "2024-01-01", "2024-01-10", 1, 1, "2024-01-01", false},
+		{"monthly", "2024-01-31", "2024-03-01", 1, 1, "2024-01-31", false},
+		{"monthly", "2024-01-31", "2024-03-02", 2, 2, "2024-03-02", false},
+		{"quarterly", "2023-10-01", "2024-05-01", 3, 2, "2024-04-01", false},
+		{"biweekly", "2024-01-01", "2023-12-31", 0, 0, "", true},
+		{"daily", "2024-01-01", "2024-01-02", 0, 0, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cadence+"/"+tt.now, func(t *testing.T) {
+			now, _ := time.Parse("2006-01-02", tt.now)
+			info, err := trainAt(TrainConfig{Cadence: tt.cadence, Start: tt.start}, now.Add(12*time.Hour))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("trainAt() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if info.Number != tt.wantNumber || info.YearNumber != tt.wantYear || info.Leaves.Format("2006-01-02") != tt.wantLeaves {
+				t.Errorf("trainAt() = train %d (%d. of the year) leaving %s, want %d (%d.) leaving %s",
+					info.Number, info.YearNumber, info.Leaves.Format("2006-01-02"), tt.wantNumber, tt.wantYear, tt.wantLeaves)
+			}
+		})
+	}
+}
+
+func TestTrainTag(t *testing.T) {
+	now, _ := time.Parse("2006-01-02", "2024-03-20")
+	biweekly := TrainConfig{Cadence: "biweekly", Start: "2024-01-01"}
+
+	tests := []struct {
+		name    string
+		config  TrainConfig
+		lastTag string
+		want    string
+		wantErr string
+	}{
+		{"first release", biweekly, "", "v2024.6.0", ""},
+		{"new train", biweekly, "v2024.5.2", "v2024.6.0", ""},
+		{"same train", biweekly, "v2024.6.0", "v2024.6.1", ""},
+		{"same train again", biweekly, "v2024.6.1", "v2024.6.2", ""},
+		{"train number", TrainConfig{Cadence: "biweekly", Start: "2023-01-02", Version: "{train}.{patch}.0"}, "v31.0.0", "v32.0.0", ""},
+		{"short year", TrainConfig{Cadence: "monthly", Start: "2024-01-01", Version: "{yy}.{month}.{patch}"}, "v24.3.0", "v24.3.1", ""},
+		{"literal major", TrainConfig{Cadence: "monthly", Start: "2024-01-01", Version: "1.{train}.{patch}"}, "v1.2.4", "v1.3.0", ""},
+		{"not monotonic", biweekly, "v2025.1.0", "", "is not greater than the last tag v2025.1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := trainTag(tt.config, "v0.0.0", tt.lastTag, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("trainTag() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("trainTag() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTrainConfig(t *testing.T) {
+	tests := []struct {
+		config TrainConfig
+		valid  bool
+	}{
+		{TrainConfig{}, true},
+		{TrainConfig{Cadence: "biweekly", Start: "2024-01-01"}, true},
+		{TrainConfig{Cadence: "4w", Start: "2024-01-01", Version: "{train}.0.{patch}"}, true},
+		{TrainConfig{Cadence: "0d", Start: "2024-01-01"}, false},
+		{TrainConfig{Cadence: "weekly", Start: "01/01/2024"}, false},
+		{TrainConfig{Cadence: "weekly", Start: "2024-01-01", Version: "{year}.{train}"}, false},
+		{TrainConfig{Cadence: "weekly", Start: "2024-01-01", Version: "{year}.{train}.0"}, false},
+		{TrainConfig{Cadence: "weekly", Start: "2024-01-01", Version: "{year}.{week}.{patch}"}, false},
+	}
+
+	for _, tt := range tests {
+		if problems := validateTrainConfig("main", tt.config); (len(problems) == 0) != tt.valid {
+			t.Errorf("validateTrainConfig(%+v) = %q, want valid: %v", tt.config, problems, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// confirmTypedTag asks whether a hand-typed tag that closely resembles an
+// existing one is really meant, catching typos such as v1.40.0 for v1.4.0.
+// It returns true when the tag is to be created.
+func confirmTypedTag(tag, tagFormat string) bool {
+	existing, err := gitClient.ListTags("")
+	if err != nil {
+		logFor(logGit).Debug("listing tags for the typo check failed", "error", err)
+		return true
+	}
+	similar, reason := similarTag(tag, extractPrefix(tagFormat), existing)
+	if similar == "" {
+		return true
+	}
+	yellow := color.New(color.FgYellow).SprintFunc()
+	return prompter.Confirm(fmt.Sprintf("%s %s %s %s - is it a typo? Create %s anyway?", yellow("Warning:"), tag, reason, similar, tag), false)
+}
+
+// similarTag returns an existing tag tag is probably a typo of, with how they
+// relate: one inserted, deleted or swapped character apart, or the same version
+// under another prefix. Changing a single character is not suspicious, since
+// that is how consecutive versions differ.
+func similarTag(tag, prefix string, existing []string) (string, string) {
+	if contains(existing, tag) {
+		return "", ""
+	}
+	for _, other := range existing {
+		if oneEditApart(tag, other) {
+			return other, "is one character away from the existing tag"
+		}
+	}
+	version := strings.TrimPrefix(tag, prefix)
+	for _, other := range existing {
+		if m := trailingVersion.FindStringIndex(other); m != nil && other[m[0]:] == version && other[:m[0]] != prefix {
+			return other, "has the same version as the existing tag"
+		}
+	}
+	return "", ""
+}
+
+// oneEditApart reports whether a and b differ by one inserted or deleted
+// character, or by two swapped adjacent characters
+func oneEditApart(a, b string) bool {
+	if len(a) == len(b) {
+		// The only difference has to be a swap of neighbours, e.g. 1.12 and 1.21
+		first := -1
+		for i := 0; i < len(a); i++ {
+			if a[i] != b[i] {
+				first = i
+				break
+			}
+		}
+		return first >= 0 && first+1 < len(a) && a[first] == b[first+1] && a[first+1] == b[first] && a[first+2:] == b[first+2:]
+	}
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if len(a) != len(b)+1 {
+		return false
+	}
+	// a is b with one character more
+	for i := 0; i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i+1:] == b[i:]
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestSimilarTag(t *testing.T) {
+	existing := []string{"v1.4.0", "v1.12.0", "release-2.0.0", "v11.4.0"}
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"v1.40.0", "v1.4.0"},
+		{"v1.4.01", "v1.4.0"},
+		{"v1.21.0", "v1.12.0"},
+		{"v1.4.01", "v1.4.0"},
+		{"v2.0.0", "release-2.0.0"},
+		{"v1.4.1", ""},
+		{"v1.5.0", ""},
+		{"v1.4.0", ""},
+		{"v3.0.0", ""},
+	}
+	for _, tt := range tests {
+		got, _ := similarTag(tt.tag, "v", existing)
+		if got != tt.want {
+			t.Errorf("similarTag(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestConfirmTypedTag(t *testing.T) {
+	g := newFakeGitClient("a")
+	g.tags = map[string]string{"v1.4.0": "a"}
+	useFakeGit(t, g)
+
+	tests := []struct {
+		tag     string
+		answers []string
+		want    bool
+	}{
+		{"v1.40.0", []string{"n"}, false},
+		{"v1.40.0", []string{"y"}, true},
+		{"v1.5.0", nil, true},
+	}
+	for _, tt := range tests {
+		useScriptedPrompter(t, tt.answers...)
+		var got bool
+		captureOutput(func() { got = confirmTypedTag(tt.tag, "v0.0.0") })
+		if got != tt.want {
+			t.Errorf("confirmTypedTag(%q) with %q = %v, want %v", tt.tag, tt.answers, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+//go:embed ui
+var uiAssets embed.FS
+
+// uiServer serves the local web UI and its JSON API
+type uiServer struct {
+	mu         sync.Mutex
+	config     Config
+	remoteURLs map[string]string
+	token      string
+	// allowBreaking permits breaking Go API changes in non-major releases
+	allowBreaking bool
+}
+
+// uiBranch is the status of a configured branch shown in the web UI
+type uiBranch struct {
+	Branch  string `json:"branch"`
+	Format  string `json:"format"`
+	LastTag string `json:"lastTag"`
+	NextTag string `json:"nextTag"`
+}
+
+// uiRemote is a remote the web UI may push to
+type uiRemote struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Role is upstream, fork, mirror or empty, see classifyRemotes
+	Role string `json:"role,omitempty"`
+}
+
+// uiPlanRequest is the selection made in the web UI
+type uiPlanRequest struct {
+	Branch string `json:"branch"`
+	Format string `json:"format"`
+	Tag    string `json:"tag"`
+	Remote string `json:"remote"`
+	Ticket string `json:"ticket,omitempty"`
+}
+
+// runUICommand implements the ui command: it serves a local web page for the publish flow
+func runUICommand(args []string) {
+	flags := flag.NewFlagSet("ui", flag.ExitOnError)
+	opts := addPublishFlags(flags)
+	addr := flags.String("addr", "127.0.0.1:8642", "address to listen on")
+	flags.Parse(args)
+
+	config, remoteURLs := preparePublish(opts)
+
+	token, err := newUIToken()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	server := &uiServer{config: config, remoteURLs: remoteURLs, token: token, allowBreaking: opts.AllowBreaking}
+
+	green := color.New(color.FgGreen).SprintFunc()
+	fmt.Printf("Serving git-publish UI on %s\n", green(fmt.Sprintf("http://%s/?token=%s", *addr, token)))
+	fmt.Println("Press Ctrl+C to stop.")
+
+	// Pick up valid changes of the configuration without a restart
+	go watchConfig(opts, func(reloaded Config) {
+		reloaded = filterExistingBranches(reloaded, len(remoteURLs) > 0)
+		if len(reloaded.BranchTags) == 0 {
+			fmt.Println("Warning: None of the reloaded branches exist, keeping the previous configuration")
+			return
+		}
+		server.mu.Lock()
+		server.config = reloaded
+		server.mu.Unlock()
+	}, nil)
+
+	if err := http.ListenAndServe(*addr, server.handler()); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newUIToken returns a random token that protects the API from other web pages
+func newUIToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// handler returns the HTTP handler of the web UI
+func (s *uiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	assets, _ := fs.Sub(uiAssets, "ui")
+	mux.Handle("/", http.FileServer(http.FS(assets)))
+	mux.HandleFunc("/api/status", s.authorized(s.handleStatus))
+	mux.HandleFunc("/api/plan", s.authorized(s.handlePlan))
+	mux.HandleFunc("/api/publish", s.authorized(s.handlePublish))
+	registerProfiler(mux, s.token)
+
+	return logRequests(mux)
+}
+
+// logRequests logs every request served by the web UI
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		logFor(logUI).Info("request", "method", r.Method, "path", r.URL.Path, "duration", time.Since(start))
+	})
+}
+
+// authorized rejects API requests that do not carry the session token
+func (s *uiServer) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Git-Publish-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeJSONError(w, http.StatusForbidden, fmt.Errorf("invalid or missing token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *uiServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	branches := make([]uiBranch, 0, len(s.config.BranchTags))
+	for _, bt := range s.config.BranchTags {
+		lastTag, err := releasedTag(s.config, bt, "")
+		if err != nil {
+			logFor(logUI).Warn("reading the deployed version failed", "branch", bt.Branch, "error", err)
+			lastTag = getLastTag(bt.Branch, bt.Tag, s.config.Tags.OnlyMarked)
+		}
+		branches = append(branches, uiBranch{
+			Branch:  bt.Branch,
+			Format:  bt.Tag,
+			LastTag: lastTag,
+			NextTag: defaultNextTag(bt, lastTag),
+		})
+	}
+
+	// Same order and mirror filtering as the terminal push picker
+	shown, _ := pushableRemotes(classifyRemotes(s.remoteURLs, s.config.Remotes), s.config.Remotes)
+	remotes := make([]uiRemote, 0, len(shown))
+	for _, remote := range shown {
+		remotes = append(remotes, uiRemote{Name: remote.Name, URL: remote.URL, Role: remote.Role})
+	}
+
+	ticket := ""
+	if s.config.Ticket.enabled() {
+		ticket = s.config.Ticket.Mode
+	}
+	writeJSON(w, map[string]interface{}{"branches": branches, "remotes": remotes, "ticket": ticket})
+}
+
+func (s *uiServer) handlePlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST"))
+		return
+	}
+
+	var req uiPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plan, err := s.plan(req)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{"plan": plan, "text": formatPlan(plan)})
+}
+
+func (s *uiServer) handlePublish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("use POST"))
+		return
+	}
+
+	var previewed Plan
+	if err := json.NewDecoder(r.Body).Decode(&previewed); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Never execute actions sent by the browser: rebuild the plan from the
+	// selection and make sure it still matches what was previewed
+	plan, err := s.plan(uiPlanRequest{Branch: previewed.Branch, Format: previewed.TagFormat, Tag: previewed.Tag, Remote: previewed.Remote, Ticket: previewed.Ticket})
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, err)
+		return
+	}
+	if plan.TargetCommit != previewed.TargetCommit || plan.LastTag != previewed.LastTag {
+		writeJSONError(w, http.StatusConflict, fmt.Errorf("the repository changed since the preview, please preview again"))
+		return
+	}
+
+	if err := publishPlan(plan, s.config, newEventBus()); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{"tag": plan.Tag, "branch": plan.Branch, "remote": plan.Remote, "url": plan.TagURL})
+}
+
+// plan validates the web UI selection and turns it into a plan
+func (s *uiServer) plan(req uiPlanRequest) (Plan, error) {
+	var bt BranchTagConfig
+	found := false
+	for _, candidate := range s.config.BranchTags {
+		if candidate.Branch == req.Branch && candidate.Tag == req.Format {
+			bt, found = candidate, true
+			break
+		}
+	}
+	if !found {
+		return Plan{}, fmt.Errorf("branch %s with format %s is not configured", req.Branch, req.Format)
+	}
+
+	lastTag, err := releasedTag(s.config, bt, "")
+	if err != nil {
+		return Plan{}, err
+	}
+	return checkedPlan(s.config, s.remoteURLs, bt, lastTag, req.Tag, req.Remote, req.Ticket, s.allowBreaking)
+}
+
+// checkedPlan runs the checks of the interactive flow on a tag chosen without
+// prompts and turns it into a plan
+func checkedPlan(config Config, remoteURLs map[string]string, bt BranchTagConfig, lastTag, tag, remote, ticket string, allowBreaking bool) (Plan, error) {
+	if err := validateNewTag(tag, bt.Tag, lastTag); err != nil {
+		return Plan{}, err
+	}
+	if err := checkTagRules(config.TagRules, bt.Branch, bt.Tag, lastTag, tag); err != nil {
+		return Plan{}, err
+	}
+	if err := checkPresetTag(config, bt.Branch, bt.Tag, tag); err != nil {
+		return Plan{}, err
+	}
+	if _, err := checkTicket(config.Ticket, ticket); err != nil {
+		return Plan{}, err
+	}
+	if err := checkGoAPICompat(config, bt.Branch, bt.Tag, lastTag, tag, allowBreaking); err != nil {
+		return Plan{}, err
+	}
+	if err := checkGoModulePath(config, bt.Branch, bt.Tag, tag, false); err != nil {
+		return Plan{}, err
+	}
+	if err := checkSignedCommits(config.Signatures, bt.Branch, lastTag); err != nil {
+		return Plan{}, err
+	}
+
+	plan, err := makePlan(config, remoteURLs, bt.Branch, bt.Tag, lastTag, tag, remote)
+	if err != nil {
+		return plan, err
+	}
+	if err := checkRemoteTag(plan.Remote, plan.Tag); err != nil {
+		return plan, err
+	}
+	checks, err := versionChecks(bt, plan.TargetCommit)
+	if err != nil {
+		return plan, err
+	}
+	if err := checkVersionStrings(checks, plan, bt.Tag); err != nil {
+		return plan, err
+	}
+	if plan.Trailers, err = resolveTrailers(config.Tags.Trailers, nil, false); err != nil {
+		return plan, err
+	}
+	setTicket(&plan, config, ticket)
+	if err := preflightPlan(plan, config); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes err as a JSON error response with the given status
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestUIServerRequiresToken tests that the API rejects requests without the session token
+func TestUIServerRequiresToken(t *testing.T) {
+	server := &uiServer{config: getTestConfig(), remoteURLs: map[string]string{}, token: "secret"}
+	handler := server.handler()
+
+	req := httptest.NewRequest("GET", "/api/status", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d without token, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "git-publish") {
+		t.Errorf("Expected the embedded page to be served, got %d", rec.Code)
+	}
+}
+
+// TestUIServerPlanValidation tests that the plan endpoint validates the selection
+func TestUIServerPlanValidation(t *testing.T) {
+	originalTagOnBranch := isTagOnBranchFunc
+	defer func() { isTagOnBranchFunc = originalTagOnBranch }()
+	isTagOnBranchFunc = func(tag, branch string) bool { return false }
+
+	config := getTestConfig()
+	config.Ticket = TicketConfig{Mode: ticketRequired, Pattern: `^CHG-\d+$`}
+	server := &uiServer{config: config, remoteURLs: map[string]string{}, token: "secret"}
+	handler := server.handler()
+
+	testCases := []struct {
+		body     string
+		expected string
+	}{
+		{`{"branch":"unknown","format":"v0.0.0","tag":"v1.0.0"}`, "not configured"},
+		{`{"branch":"main","format":"v0.0.0","tag":"1.0"}`, "invalid format"},
+		{`{"branch":"main","format":"v0.0.0","tag":"v1.0.0"}`, "a change ticket is required"},
+		{`{"branch":"main","format":"v0.0.0","tag":"v1.0.0","ticket":"JIRA-1"}`, "does not match"},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest("POST", "/api/plan", strings.NewReader(tc.body))
+		req.Header.Set("X-Git-Publish-Token", "secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var resp map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(resp["error"], tc.expected) {
+			t.Errorf("POST /api/plan %s = %d %q, expected 400 containing %q", tc.body, rec.Code, resp["error"], tc.expected)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+)
+
+// UserConfig represents the per-user configuration shared by all repositories
+type UserConfig struct {
+	// DefaultBranchTags are written to new publish.json files instead of the built-in defaults
+	DefaultBranchTags []BranchTagConfig `json:"defaultBranchTags,omitempty"`
+	// Tokens maps a hosting provider host name to its API token
+	Tokens map[string]string `json:"tokens,omitempty"`
+	// GitHubClientID is the OAuth app used by auth login github
+	GitHubClientID string `json:"githubClientId,omitempty"`
+	// OAuth holds the tokens of auth login, keyed by host
+	OAuth map[string]OAuthToken `json:"oauth,omitempty"`
+	// Publish holds publish.json settings applied to every repository unless its publish.json sets them
+	Publish map[string]interface{} `json:"publish,omitempty"`
+	// Telemetry is the opt-in to anonymous crash reports
+	Telemetry TelemetryConfig `json:"telemetry,omitempty"`
+	// TimeZone is the team's time zone, e.g. Europe/Berlin, in which release
+	// times are shown besides UTC (default: the local zone)
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// userConfigPath returns the location of the user configuration file
+func userConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "git-publish", "config.json"), nil
+}
+
+// readUserConfig reads the user configuration; a missing file yields an empty configuration
+func readUserConfig() (UserConfig, bool) {
+	userConfig, exists, err := loadUserConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	return userConfig, exists
+}
+
+// loadUserConfig reads the user configuration, reporting whether the file
+// exists and whether it could be parsed
+func loadUserConfig() (UserConfig, bool, error) {
+	var userConfig UserConfig
+
+	path, err := userConfigPath()
+	if err != nil {
+		return userConfig, false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return userConfig, false, nil
+	}
+
+	if err := json.Unmarshal(data, &userConfig); err != nil {
+		return userConfig, true, fmt.Errorf("parsing user config %s: %v", path, err)
+	}
+	return userConfig, true, nil
+}
+
+// writeUserConfig writes the user configuration, creating its directory if needed
+func writeUserConfig(userConfig UserConfig) (string, error) {
+	path, err := userConfigPath()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(userConfig, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	// The file may hold API tokens, keep it private
+	return path, os.WriteFile(path, data, 0600)
+}
+
+// repositoryDefaultConfig returns the configuration written to new publish.json files
+func repositoryDefaultConfig() Config {
+	if userConfig, ok := readUserConfig(); ok && len(userConfig.DefaultBranchTags) > 0 {
+		return Config{BranchTags: userConfig.DefaultBranchTags}
+	}
+	return defaultConfig
+}
+
+// runOnboarding shows a short walk-through on the first run, detected by the
+// absence of the user configuration, and offers to create it
+func runOnboarding() {
+	if _, exists := readUserConfig(); exists || !isInteractive() {
+		return
+	}
+
+	cyan := color.New(color.FgCyan).SprintFunc()
+	green := color.New(color.FgGreen).SprintFunc()
+
+	path, err := userConfigPath()
+	if err != nil {
+		return
+	}
+	configPath, err := repositoryConfigPath()
+	if err != nil {
+		cwd, _ := os.Getwd()
+		configPath = filepath.Join(cwd, configFileName)
+	}
+
+	fmt.Println(cyan("Welcome to git-publish! This looks like your first run."))
+	fmt.Println()
+	fmt.Println("What git-publish will do:")
+	fmt.Println("  - Read the branch-to-tag mapping from publish.json")
+	fmt.Println("  - Fetch branch and tag information from your remotes")
+	fmt.Println("  - Suggest the next tag for the branch you select and create it")
+	fmt.Println("  - Push the tag to a remote, but only after asking you")
+	fmt.Println()
+	fmt.Println("What git-publish will not do:")
+	fmt.Println("  - Switch branches")
+	fmt.Println("  - Commit or touch your working tree without asking: the versionFiles of")
+	fmt.Println("    publish.json are only committed (\"Bump version to X\") once you confirm a release")
+	fmt.Println("  - Push anything without confirmation")
+	fmt.Println()
+	fmt.Println("Files it writes:")
+	fmt.Printf("  - %s (only if it does not exist yet)\n", configPath)
+	fmt.Printf("  - %s (the global config, only if you agree below)\n", path)
+	fmt.Println()
+	if !prompter.Confirm("Create the global config now? It holds the defaults for new publish.json files", true) {
+		fmt.Println("Skipped. This walk-through is shown until the global config exists.")
+		fmt.Println()
+		return
+	}
+
+	written, err := writeUserConfig(UserConfig{DefaultBranchTags: defaultConfig.BranchTags})
+	if err != nil {
+		fmt.Printf("Error writing global config: %v\n", err)
+		return
+	}
+	fmt.Printf("Created global config: %s\n", green(written))
+	fmt.Println()
+}
+
+// isInteractive reports whether stdin is a terminal, i.e. a person can answer prompts
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
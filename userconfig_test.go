@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestUserConfigRoundTrip tests writing and reading the user configuration
+func TestUserConfigRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	if _, exists := readUserConfig(); exists {
+		t.Fatalf("Expected no user config on first run")
+	}
+	if config := repositoryDefaultConfig(); len(config.BranchTags) != len(defaultConfig.BranchTags) {
+		t.Errorf("Expected built-in defaults without a user config, got %+v", config)
+	}
+
+	custom := []BranchTagConfig{{Branch: "trunk", Tag: "r0.0.0"}}
+	if _, err := writeUserConfig(UserConfig{DefaultBranchTags: custom}); err != nil {
+		t.Fatalf("writeUserConfig() returned error: %v", err)
+	}
+
+	userConfig, exists := readUserConfig()
+	if !exists || len(userConfig.DefaultBranchTags) != 1 || userConfig.DefaultBranchTags[0].Tag != "r0.0.0" {
+		t.Errorf("readUserConfig() = %+v, %v, expected the written config", userConfig, exists)
+	}
+	if config := repositoryDefaultConfig(); config.BranchTags[0].Branch != "trunk" {
+		t.Errorf("Expected user defaults for new repositories, got %+v", config)
+	}
+}
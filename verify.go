@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+)
+
+// verifyRelease runs the verify command of the plan before its tag is created,
+// streaming the output, and marks the plan as verified when it passes. The
+// command runs in a work tree of the target commit, so it tests the code being
+// tagged even when another branch is checked out, with the planned version
+// files already written as they will be committed. A skipped command is only
+// reported; either way the events of the run record it.
+func verifyRelease(plan *Plan) error {
+	switch {
+	case plan.Verify == "":
+		skipGate(gateVerify, "not configured")
+		return nil
+	case plan.TagExists:
+		skipGate(gateVerify, "the tag was created by an earlier run")
+		return nil
+	case plan.SkipVerify:
+		yellow := color.New(color.FgYellow).SprintFunc()
+		fmt.Printf("%s skipping the verify command %s (--skip-verify)\n", yellow("Warning:"), plan.Verify)
+		skipGate(gateVerify, "--skip-verify")
+		return nil
+	}
+
+	enterPhase("verify")
+	fmt.Printf("Verifying %s at %s: %s\n", plan.Tag, shortHash(plan.TargetCommit), plan.Verify)
+	return runGate(gateVerify, true, func() error {
+		return inWorktree(plan.TargetCommit, func(dir string) error {
+			if err := writePlannedVersionFiles(dir, *plan); err != nil {
+				return fmt.Errorf("writing the version files to verify: %v", err)
+			}
+			cmd := execCommand("sh", "-c", plan.Verify)
+			cmd.Dir = dir
+			cmd.Env = hookEnv(*plan)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("verify command failed: %v; nothing was tagged (--skip-verify skips it)", err)
+			}
+			plan.Verified = true
+			return nil
+		})
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyRelease(t *testing.T) {
+	originalExecCommand := execCommand
+	defer func() { execCommand = originalExecCommand }()
+
+	tests := []struct {
+		name         string
+		plan         Plan
+		exit         string
+		wantRan      bool
+		wantVerified bool
+		wantErr      bool
+	}{
+		{"passes", Plan{Tag: "v1.2.0", TargetCommit: "c1", Verify: "make check"}, "exit 0", true, true, false},
+		{"fails", Plan{Tag: "v1.2.0", TargetCommit: "c1", Verify: "make check"}, "exit 2", true, false, true},
+		{"unknown commit", Plan{Tag: "v1.2.0", TargetCommit: "c2", Verify: "make check"}, "exit 0", false, false, true},
+		{"skipped", Plan{Tag: "v1.2.0", TargetCommit: "c1", Verify: "make check", SkipVerify: true}, "exit 2", false, false, false},
+		{"tag exists", Plan{Tag: "v1.2.0", TargetCommit: "c1", Verify: "make check", TagExists: true}, "exit 2", false, false, false},
+		{"no command", Plan{Tag: "v1.2.0", TargetCommit: "c1"}, "exit 2", false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGitClient("c1")
+			useFakeGit(t, g)
+			ran := false
+			execCommand = func(command string, args ...string) *exec.Cmd {
+				ran = args[len(args)-1] == tt.plan.Verify
+				return exec.Command("sh", "-c", tt.exit)
+			}
+			plan := tt.plan
+			var err error
+			captureOutput(func() { err = verifyRelease(&plan) })
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyRelease() error = %v, want error %v", err, tt.wantErr)
+			}
+			if ran != tt.wantRan || plan.Verified != tt.wantVerified {
+				t.Errorf("ran = %v, verified = %v, want %v, %v", ran, plan.Verified, tt.wantRan, tt.wantVerified)
+			}
+			if len(g.worktrees) > 0 {
+				t.Errorf("work trees %v left behind", g.worktrees)
+			}
+		})
+	}
+}
+
+// TestVerifyReleaseAtTargetCommit tests that the verify command checks the
+// tagged commit, not what is checked out
+func TestVerifyReleaseAtTargetCommit(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	gitInTestRepo(t, dir, "checkout", "-q", "-b", "release")
+	os.WriteFile(filepath.Join(dir, "released.txt"), []byte("1.2.0\n"), 0644)
+	gitInTestRepo(t, dir, "add", "released.txt")
+	gitInTestRepo(t, dir, "commit", "-q", "-m", "Prepare 1.2.0")
+	gitInTestRepo(t, dir, "checkout", "-q", "main")
+	// Uncommitted changes are not part of the release either
+	os.WriteFile(filepath.Join(dir, "dirty.txt"), []byte("wip\n"), 0644)
+	release, err := lookupCommit("release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := gitClient.RevParse("HEAD")
+	if err != nil || head == release {
+		t.Fatalf("HEAD = %s, %v, want it apart from release", head, err)
+	}
+
+	check := `test -f released.txt && test ! -f dirty.txt && test "$(git rev-parse HEAD)" = "$GIT_PUBLISH_COMMIT"`
+	tests := []struct {
+		name    string
+		commit  string
+		wantErr bool
+	}{
+		{"release", release, false},
+		{"checked out", head, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := Plan{Branch: tt.name, Tag: "v1.2.0", TargetCommit: tt.commit, Verify: check}
+			var err error
+			output := captureOutput(func() { err = verifyRelease(&plan) })
+			if (err != nil) != tt.wantErr || plan.Verified == tt.wantErr {
+				t.Errorf("verifyRelease() = %v, verified %v, want error %v\n%s", err, plan.Verified, tt.wantErr, output)
+			}
+			cmd := exec.Command("git", "worktree", "list", "--porcelain")
+			cmd.Dir = dir
+			if list, _ := cmd.Output(); strings.Count(string(list), "worktree ") != 1 {
+				t.Errorf("work trees left behind:\n%s", list)
+			}
+		})
+	}
+}
+
+// TestPublishPlanVerifyFails tests that a failed verification stops the run
+// before the tag is created and is recorded as a failed publish
+func TestPublishPlanVerifyFails(t *testing.T) {
+	originalExecCommand := execCommand
+	defer func() { execCommand = originalExecCommand }()
+	execCommand = func(command string, args ...string) *exec.Cmd {
+		return exec.Command("false")
+	}
+	g := newFakeGitClient("c1")
+	useFakeGit(t, g)
+
+	bus := &eventBus{handlers: make(map[string][]eventHandler)}
+	var events []event
+	bus.subscribe(func(e event) error {
+		events = append(events, e)
+		return nil
+	}, eventTagComputed, eventPublishFailed)
+
+	plan := Plan{Branch: "main", TargetCommit: "c1", Tag: "v1.2.0", Verify: "make check"}
+	var err error
+	captureOutput(func() { err = publishPlan(plan, Config{}, bus) })
+	if err == nil {
+		t.Fatal("publishPlan() succeeded although the verify command failed")
+	}
+	if len(events) != 1 || events[0].Type != eventPublishFailed || events[0].Plan.Verified {
+		t.Errorf("events = %+v, want one unverified PublishFailed", events)
+	}
+	if _, exists := g.tags["v1.2.0"]; exists {
+		t.Error("the tag was created")
+	}
+}
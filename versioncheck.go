@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// VersionCheckConfig is a file that has to contain the version of a new tag,
+// such as a version.go that is bumped by hand, checked before tagging
+type VersionCheckConfig struct {
+	// Path is relative to the repository root
+	Path string `json:"path"`
+	// Pattern is the text that holds the version, with {version} for it, e.g.
+	// `const Version = "{version}"` (default: the version anywhere in the file)
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// versionAttribute marks files in .gitattributes that have to contain the
+// version of a new tag, like export-subst marks files for git archive
+const versionAttribute = "publish-version"
+
+// versionPlaceholder stands for the version in a version check pattern
+const versionPlaceholder = "{version}"
+
+// validateVersionChecks returns the problems of the version checks of a branch
+func validateVersionChecks(branch string, checks []VersionCheckConfig) []string {
+	var problems []string
+	for _, check := range checks {
+		if check.Path == "" {
+			problems = append(problems, fmt.Sprintf("versionCheck of branch %s: a file has no path", branch))
+		}
+		if check.Pattern != "" && !strings.Contains(check.Pattern, versionPlaceholder) {
+			problems = append(problems, fmt.Sprintf("versionCheck of branch %s: the pattern of %s has no %s", branch, check.Path, versionPlaceholder))
+		}
+	}
+	return problems
+}
+
+// versionChecks returns the version checks of a release of bt at commit: the
+// configured ones and the files the .gitattributes of the repository root
+// marks with publish-version there
+func versionChecks(bt BranchTagConfig, commit string) ([]VersionCheckConfig, error) {
+	checks := append([]VersionCheckConfig{}, bt.VersionCheck...)
+	spec := commit + ":.gitattributes"
+	blobs, err := readBlobs([]string{spec})
+	if err != nil {
+		return nil, err
+	}
+	attributes, ok := blobs[spec]
+	if !ok || !strings.Contains(string(attributes), versionAttribute) {
+		return checks, nil
+	}
+
+	files, err := gitClient.ListFiles(commit)
+	if err != nil {
+		return nil, fmt.Errorf("listing the files of %s: %v", shortCommit(commit), err)
+	}
+	configured := map[string]bool{}
+	for _, check := range checks {
+		configured[check.Path] = true
+	}
+	for _, file := range markedFiles(string(attributes), files) {
+		if !configured[file] {
+			checks = append(checks, VersionCheckConfig{Path: file})
+		}
+	}
+	return checks, nil
+}
+
+// markedFiles returns those of the files that the content of a .gitattributes
+// file marks with publish-version. Like git, the last matching line wins, so
+// "-publish-version" or "!publish-version" unmarks files again.
+func markedFiles(attributes string, files []string) []string {
+	type rule struct {
+		pattern string
+		marked  bool
+	}
+	var rules []rule
+	for _, line := range strings.Split(attributes, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for _, attribute := range fields[1:] {
+			switch attribute {
+			case versionAttribute:
+				rules = append(rules, rule{fields[0], true})
+			case "-" + versionAttribute, "!" + versionAttribute:
+				rules = append(rules, rule{fields[0], false})
+			}
+		}
+	}
+
+	var marked []string
+	for _, file := range files {
+		isMarked := false
+		for _, r := range rules {
+			if matchAttributePattern(r.pattern, file) {
+				isMarked = r.marked
+			}
+		}
+		if isMarked {
+			marked = append(marked, file)
+		}
+	}
+	sort.Strings(marked)
+	return marked
+}
+
+// matchAttributePattern reports whether file matches a .gitattributes pattern
+// of the repository root: a pattern without a slash matches the base name at
+// any depth, one with a slash the path from the root, and a leading **/
+// matches in any directory
+func matchAttributePattern(pattern, file string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+	if rest, ok := strings.CutPrefix(pattern, "**/"); ok {
+		for dir := file; ; {
+			if matched, _ := path.Match(rest, dir); matched {
+				return true
+			}
+			_, after, found := strings.Cut(dir, "/")
+			if !found {
+				return false
+			}
+			dir = after
+		}
+	}
+	ok, _ := path.Match(strings.TrimPrefix(pattern, "/"), file)
+	return ok
+}
+
+// checkVersionStrings checks that the files of checks contain the version of
+// the tag of plan at the commit it tags. The error shows, for every file that
+// does not, the change that would fix it, found from the version of the last
+// tag or the pattern.
+func checkVersionStrings(checks []VersionCheckConfig, plan Plan, tagFormat string) error {
+	prefix := extractPrefix(tagFormat)
+	commit, version := plan.TargetCommit, strings.TrimPrefix(plan.Tag, prefix)
+	lastVersion := ""
+	if plan.LastTag != "" {
+		lastVersion = strings.TrimPrefix(plan.LastTag, prefix)
+	}
+	specs := make([]string, len(checks))
+	for i, check := range checks {
+		specs[i] = commit + ":" + check.Path
+	}
+	blobs, err := readBlobs(specs)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for i, check := range checks {
+		data, ok := blobs[specs[i]]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s does not exist", check.Path))
+			continue
+		}
+		if hasVersionString(string(data), check.Pattern, version) {
+			continue
+		}
+		problem := fmt.Sprintf("%s does not contain version %s", check.Path, version)
+		if hint := versionStringHint(check, string(data), version, lastVersion); hint != "" {
+			problem += "; it needs:\n" + hint
+		} else if check.Pattern != "" {
+			problem += fmt.Sprintf(" as %s", strings.ReplaceAll(check.Pattern, versionPlaceholder, version))
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the version strings of %s are not updated:\n  %s", shortCommit(commit), strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// hasVersionString reports whether content holds version as pattern, or
+// anywhere when there is no pattern. Without a pattern 1.2.3 does not count
+// as part of 11.2.3 or 1.2.30.
+func hasVersionString(content, pattern, version string) bool {
+	if pattern != "" {
+		return strings.Contains(content, strings.ReplaceAll(pattern, versionPlaceholder, version))
+	}
+	return versionStringPattern(version).MatchString(content)
+}
+
+// versionStringPattern matches version, not followed or preceded by more of a version number
+func versionStringPattern(version string) *regexp.Regexp {
+	return regexp.MustCompile(`(^|[^0-9.])` + regexp.QuoteMeta(version) + `($|[^0-9.]|\.($|[^0-9]))`)
+}
+
+// versionStringHint returns the lines of content that hold an older version,
+// as a diff to the lines holding version: the lines with lastVersion, else,
+// with a pattern, the lines matching it with any version
+func versionStringHint(check VersionCheckConfig, content, version, lastVersion string) string {
+	var old *regexp.Regexp
+	switch {
+	case check.Pattern != "":
+		parts := strings.Split(check.Pattern, versionPlaceholder)
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		old = regexp.MustCompile(strings.Join(parts, `[0-9A-Za-z.+-]+`))
+	case lastVersion != "":
+		old = versionStringPattern(lastVersion)
+	default:
+		return ""
+	}
+
+	var hint strings.Builder
+	for n, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !old.MatchString(line) {
+			continue
+		}
+		var fixed string
+		if check.Pattern != "" {
+			fixed = old.ReplaceAllLiteralString(line, strings.ReplaceAll(check.Pattern, versionPlaceholder, version))
+		} else {
+			fixed = old.ReplaceAllString(line, "${1}"+version+"${2}")
+		}
+		fmt.Fprintf(&hint, "    %s:%d\n    - %s\n    + %s\n", check.Path, n+1, line, fixed)
+	}
+	return strings.TrimSuffix(hint.String(), "\n")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateVersionChecks(t *testing.T) {
+	checks := []VersionCheckConfig{
+		{Path: "version.go", Pattern: `const Version = "{version}"`},
+		{Path: ""},
+		{Path: "VERSION", Pattern: "v1"},
+	}
+	want := []string{
+		"versionCheck of branch main: a file has no path",
+		"versionCheck of branch main: the pattern of VERSION has no {version}",
+	}
+	if got := validateVersionChecks("main", checks); !reflect.DeepEqual(got, want) {
+		t.Errorf("validateVersionChecks() = %q, want %q", got, want)
+	}
+}
+
+func TestMarkedFiles(t *testing.T) {
+	attributes := `# Files holding the release version
+version.go publish-version
+/docs/*.md publish-version text
+**/chart/Chart.yaml publish-version
+docs/internal.md -publish-version
+*.go diff=golang
+`
+	files := []string{
+		"version.go",
+		"cmd/tool/version.go",
+		"docs/install.md",
+		"docs/internal.md",
+		"docs/api/ref.md",
+		"deploy/chart/Chart.yaml",
+		"chart/Chart.yaml",
+		"main.go",
+	}
+	want := []string{"chart/Chart.yaml", "cmd/tool/version.go", "deploy/chart/Chart.yaml", "docs/install.md", "version.go"}
+	if got := markedFiles(attributes, files); !reflect.DeepEqual(got, want) {
+		t.Errorf("markedFiles() = %v, want %v", got, want)
+	}
+}
+
+func TestHasVersionString(t *testing.T) {
+	tests := []struct {
+		content string
+		pattern string
+		want    bool
+	}{
+		{"const Version = \"1.2.3\"\n", "", true},
+		{"Release v1.2.3.\n", "", true},
+		{"version: 1.2.30\n", "", false},
+		{"version: 11.2.3\n", "", false},
+		{"version: 1.2.3.4\n", "", false},
+		{"const Version = \"1.2.3\"\n", `Version = "{version}"`, true},
+		{"// 1.2.3\nconst Version = \"1.2.2\"\n", `Version = "{version}"`, false},
+	}
+	for _, tt := range tests {
+		if got := hasVersionString(tt.content, tt.pattern, "1.2.3"); got != tt.want {
+			t.Errorf("hasVersionString(%q, %q) = %v, want %v", tt.content, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestCheckVersionStrings(t *testing.T) {
+	g := newFakeGitClient("c1")
+	g.files["c1"] = map[string]string{
+		"a.go":           "package a\n",
+		"version.go":     "package a\n\nconst Version = \"1.2.0\"\n",
+		"VERSION":        "1.3.0\n",
+		"README.md":      "Install 1.2.0 with go install\n",
+		".gitattributes": "VERSION publish-version\nREADME.md publish-version\n",
+	}
+	useFakeGit(t, g)
+	commit := "c1"
+
+	bt := BranchTagConfig{Branch: "main", Tag: "v0.0.0", VersionCheck: []VersionCheckConfig{
+		{Path: "version.go", Pattern: `const Version = "{version}"`},
+	}}
+	checks, err := versionChecks(bt, commit)
+	if err != nil {
+		t.Fatalf("versionChecks() error = %v", err)
+	}
+	var paths []string
+	for _, check := range checks {
+		paths = append(paths, check.Path)
+	}
+	if want := []string{"version.go", "README.md", "VERSION"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("versionChecks() = %v, want %v", paths, want)
+	}
+
+	plan := Plan{Tag: "v1.3.0", LastTag: "v1.2.0", TargetCommit: commit}
+	err = checkVersionStrings(checks, plan, bt.Tag)
+	if err == nil {
+		t.Fatal("checkVersionStrings() = nil, want an error for version.go and README.md")
+	}
+	for _, want := range []string{
+		"version.go does not contain version 1.3.0; it needs:\n    version.go:3\n    - const Version = \"1.2.0\"\n    + const Version = \"1.3.0\"",
+		"README.md does not contain version 1.3.0; it needs:\n    README.md:1\n    - Install 1.2.0 with go install\n    + Install 1.3.0 with go install",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("checkVersionStrings() = %v, want it to contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "VERSION does not") {
+		t.Errorf("checkVersionStrings() = %v, VERSION holds the version", err)
+	}
+
+	plan.Tag = "v1.2.0"
+	if err := checkVersionStrings(checks[:1], plan, bt.Tag); err != nil {
+		t.Errorf("checkVersionStrings() = %v, want nil", err)
+	}
+	missing := []VersionCheckConfig{{Path: "internal/version.go"}}
+	if err := checkVersionStrings(missing, plan, bt.Tag); err == nil || !strings.Contains(err.Error(), "internal/version.go does not exist") {
+		t.Errorf("checkVersionStrings() = %v, want the missing file", err)
+	}
+}
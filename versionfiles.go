@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VersionFileConfig is a file that records the version of a release, updated
+// and committed on the branch before the tag is created
+type VersionFileConfig struct {
+	// Path is relative to the repository root; pom.xml, *.properties and
+	// Helm Chart.yaml files are supported
+	Path string `json:"path"`
+	// Key is the property holding the version in a properties file (default:
+	// version), or the only Chart.yaml field to set, version or appVersion
+	// (default: both)
+	Key string `json:"key,omitempty"`
+}
+
+// validateVersionFiles returns the problems of the version files of a branch
+func validateVersionFiles(branch string, files []VersionFileConfig) []string {
+	var problems []string
+	for _, file := range files {
+		if _, err := versionFileEditor(file); err != nil {
+			problems = append(problems, fmt.Sprintf("versionFiles of branch %s: %v", branch, err))
+		}
+	}
+	return problems
+}
+
+// versionFileEditor returns the function that sets the version in the
+// contents of a version file, chosen by its name
+func versionFileEditor(file VersionFileConfig) (func(data []byte, version string) ([]byte, error), error) {
+	name := filepath.Base(file.Path)
+	switch {
+	case file.Path == "":
+		return nil, fmt.Errorf("a version file has no path")
+	case name == "pom.xml":
+		return setPOMVersion, nil
+	case name == "Chart.yaml":
+		switch file.Key {
+		case "":
+			return func(data []byte, version string) ([]byte, error) {
+				return setChartVersion(data, version, true)
+			}, nil
+		case "version", "appVersion":
+			return func(data []byte, version string) ([]byte, error) {
+				return setYAMLScalar(data, file.Key, version)
+			}, nil
+		}
+		return nil, fmt.Errorf("key %q of %s is not version or appVersion", file.Key, file.Path)
+	case strings.HasSuffix(name, ".properties"):
+		key := file.Key
+		if key == "" {
+			key = "version"
+		}
+		return func(data []byte, version string) ([]byte, error) {
+			return setProperty(data, key, version)
+		}, nil
+	}
+	return nil, fmt.Errorf("%s is not a pom.xml, .properties or Chart.yaml file", file.Path)
+}
+
+// versionFileChanges returns the new contents of the files, under root, that
+// do not hold version yet by absolute path, and their configured paths in
+// the order of files
+func versionFileChanges(root string, files []VersionFileConfig, version string) (map[string][]byte, []string, error) {
+	updates := map[string][]byte{}
+	var changed []string
+	for _, file := range files {
+		edit, err := versionFileEditor(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		path := filepath.Join(root, file.Path)
+		data, ok := updates[path]
+		if !ok {
+			if data, err = os.ReadFile(path); err != nil {
+				return nil, nil, err
+			}
+		}
+		updated, err := edit(data, version)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", file.Path, err)
+		}
+		if !bytes.Equal(updated, data) {
+			if _, ok := updates[path]; !ok {
+				changed = append(changed, file.Path)
+			}
+			updates[path] = updated
+		}
+	}
+	return updates, changed, nil
+}
+
+// pendingVersionFiles returns those of files that do not hold the version of
+// tag yet, without changing them: the plan records them and publishPlan
+// commits them once the release is confirmed. Branch has to be checked out
+// with a clean working tree for that.
+func pendingVersionFiles(files []VersionFileConfig, branch, tagFormat, tag string) ([]VersionFileConfig, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	version := strings.TrimPrefix(tag, extractPrefix(tagFormat))
+	root, err := gitClient.TopLevel()
+	if err != nil {
+		return nil, err
+	}
+	_, changed, err := versionFileChanges(root, files, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) == 0 {
+		fmt.Printf("Version files already at %s\n", version)
+		return nil, nil
+	}
+	if !canCommitOnBranch(branch) {
+		return nil, fmt.Errorf("updating %s needs %s checked out with a clean working tree", strings.Join(changed, ", "), branch)
+	}
+	var pending []VersionFileConfig
+	for _, file := range files {
+		if contains(changed, file.Path) {
+			pending = append(pending, file)
+		}
+	}
+	return pending, nil
+}
+
+// syncVersionFiles writes the version of tag into the version files and
+// commits them on branch, which must be checked out with a clean working
+// tree. It reports whether a commit was made; files already at the version
+// are left alone, so re-runs do not commit again.
+func syncVersionFiles(files []VersionFileConfig, branch, tagFormat, tag string) (bool, error) {
+	if len(files) == 0 {
+		return false, nil
+	}
+	version := strings.TrimPrefix(tag, extractPrefix(tagFormat))
+	root, err := gitClient.TopLevel()
+	if err != nil {
+		return false, err
+	}
+	updates, changed, err := versionFileChanges(root, files, version)
+	if err != nil {
+		return false, err
+	}
+	if len(changed) == 0 {
+		fmt.Printf("Version files already at %s\n", version)
+		return false, nil
+	}
+
+	if !canCommitOnBranch(branch) {
+		return false, fmt.Errorf("updating %s needs %s checked out with a clean working tree", strings.Join(changed, ", "), branch)
+	}
+	for path, data := range updates {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return false, err
+		}
+	}
+	paths := make([]string, len(changed))
+	for i, file := range changed {
+		paths[i] = filepath.Join(root, file)
+	}
+	if err := gitClient.Commit("Bump version to "+version, paths...); err != nil {
+		return false, fmt.Errorf("committing %s: %v", strings.Join(changed, ", "), err)
+	}
+	fmt.Printf("Committed version %s in %s\n", version, strings.Join(changed, ", "))
+	return true, nil
+}
+
+// writePlannedVersionFiles writes the version of plan into its version files
+// under root, as commitPlannedVersionFiles commits them
+func writePlannedVersionFiles(root string, plan Plan) error {
+	if len(plan.VersionFiles) == 0 || plan.TagExists {
+		return nil
+	}
+	version := strings.TrimPrefix(plan.Tag, extractPrefix(plan.TagFormat))
+	updates, _, err := versionFileChanges(root, plan.VersionFiles, version)
+	if err != nil {
+		return err
+	}
+	for path, data := range updates {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitPlannedVersionFiles commits the version files of plan, now that the
+// release is confirmed and verified, and moves the plan to the new commit
+func commitPlannedVersionFiles(plan *Plan) error {
+	if len(plan.VersionFiles) == 0 || plan.TagExists {
+		return nil
+	}
+	enterPhase("commit version files")
+	committed, err := syncVersionFiles(plan.VersionFiles, plan.Branch, plan.TagFormat, plan.Tag)
+	if err != nil || !committed {
+		return err
+	}
+	commit, err := lookupCommit(plan.Branch)
+	if err != nil {
+		return fmt.Errorf("getting commit hash for branch %s: %v", plan.Branch, err)
+	}
+	plan.TargetCommit = commit
+	return nil
+}
+
+// withoutVersionFiles leaves the version files out of checks: they only get
+// the new version when the release is published
+func withoutVersionFiles(checks []VersionCheckConfig, files []VersionFileConfig) []VersionCheckConfig {
+	pending := map[string]bool{}
+	for _, file := range files {
+		pending[file.Path] = true
+	}
+	var kept []VersionCheckConfig
+	for _, check := range checks {
+		if !pending[check.Path] {
+			kept = append(kept, check)
+		}
+	}
+	return kept
+}
+
+// setPOMVersion sets the version of the project in a pom.xml. A version
+// taken from a property, such as Maven's ${revision}, is set on the property
+// instead. Only the text of the element changes; the rest of the file is kept
+// byte for byte.
+func setPOMVersion(data []byte, version string) ([]byte, error) {
+	start, end, err := xmlElementText(data, "project", "version")
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("the project has no version of its own")
+	}
+	current := strings.TrimSpace(string(data[start:end]))
+	if strings.HasPrefix(current, "${") && strings.HasSuffix(current, "}") {
+		property := current[2 : len(current)-1]
+		if start, end, err = xmlElementText(data, "project", "properties", property); err != nil {
+			return nil, err
+		}
+		if start < 0 {
+			return nil, fmt.Errorf("the project version comes from %s, which is not a property of the project", current)
+		}
+	}
+
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(version))
+	return append(append(append([]byte{}, data[:start]...), escaped.Bytes()...), data[end:]...), nil
+}
+
+// xmlElementText returns the byte range of the text of the first element at
+// path, counted from the root element, or -1 when there is none
+func xmlElementText(data []byte, path ...string) (int, int, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var stack []string
+	start := -1
+	for {
+		offset := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return -1, -1, nil
+		}
+		if err != nil {
+			return -1, -1, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if start >= 0 {
+				return -1, -1, fmt.Errorf("<%s> holds more than text", strings.Join(path, "><"))
+			}
+			stack = append(stack, t.Name.Local)
+			if equalPaths(stack, path) {
+				start = int(decoder.InputOffset())
+			}
+		case xml.EndElement:
+			if start >= 0 {
+				return start, offset, nil
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// equalPaths reports whether two element paths are the same
+func equalPaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// setProperty sets key in a Java properties file, keeping the separator,
+// comments and every other line as they are
+func setProperty(data []byte, key, value string) ([]byte, error) {
+	lines := strings.SplitAfter(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// A line ending in an odd number of backslashes continues on the next
+		first := i
+		for i < len(lines)-1 && continuesLine(lines[i]) {
+			i++
+		}
+
+		name, rest := splitPropertyKey(line)
+		if name != key {
+			continue
+		}
+		prefix := lines[first][:len(lines[first])-len(rest)]
+		// The separator is whitespace around at most one = or :
+		sep := len(rest) - len(strings.TrimLeft(rest, " \t\f"))
+		if sep < len(rest) && (rest[sep] == '=' || rest[sep] == ':') {
+			sep++
+			sep += len(rest[sep:]) - len(strings.TrimLeft(rest[sep:], " \t\f"))
+		}
+		separator := rest[:sep]
+		if separator == "" {
+			separator = "="
+		}
+		newline := "\n"
+		if !strings.HasSuffix(lines[i], "\n") {
+			newline = ""
+		} else if strings.HasSuffix(lines[i], "\r\n") {
+			newline = "\r\n"
+		}
+		replaced := prefix + separator + escapePropertyValue(value) + newline
+		lines = append(lines[:first], append([]string{replaced}, lines[i+1:]...)...)
+		return []byte(strings.Join(lines, "")), nil
+	}
+	return nil, fmt.Errorf("no %s property", key)
+}
+
+// continuesLine reports whether a properties line continues on the next line
+func continuesLine(line string) bool {
+	line = strings.TrimRight(line, "\r\n")
+	backslashes := len(line) - len(strings.TrimRight(line, "\\"))
+	return backslashes%2 == 1
+}
+
+// splitPropertyKey splits a properties line into its unescaped key and the
+// rest, which starts with the separator
+func splitPropertyKey(line string) (string, string) {
+	var key strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			key.WriteByte(line[i])
+		case c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' || c == '\r' || c == '\n':
+			return key.String(), line[i:]
+		default:
+			key.WriteByte(c)
+		}
+	}
+	return key.String(), ""
+}
+
+// escapePropertyValue escapes backslashes and a leading space of a value
+func escapePropertyValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	if strings.HasPrefix(value, " ") {
+		value = `\` + value
+	}
+	return value
+}
+
+// setChartVersion sets the chart version of a Helm Chart.yaml and, with
+// appVersion, its appVersion when the chart has one
+func setChartVersion(data []byte, version string, appVersion bool) ([]byte, error) {
+	data, err := setYAMLScalar(data, "version", version)
+	if err != nil || !appVersion {
+		return data, err
+	}
+	if updated, err := setYAMLScalar(data, "appVersion", version); err == nil {
+		return updated, nil
+	}
+	return data, nil
+}
+
+// chartField matches a top-level "key: value # comment" line of a YAML file
+var chartField = regexp.MustCompile(`^([A-Za-z]+):([ \t]*)("[^"]*"|'[^']*'|[^#\s][^#]*?)?([ \t]+#.*)?$`)
+
+// setYAMLScalar sets the top-level key of a YAML mapping, keeping the quotes
+// of the old value, comments and every other line. Indented keys, e.g. the
+// versions of chart dependencies, are not touched.
+func setYAMLScalar(data []byte, key, value string) ([]byte, error) {
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		body := strings.TrimRight(line, "\r\n")
+		m := chartField.FindStringSubmatch(body)
+		if m == nil || m[1] != key {
+			continue
+		}
+		quoted := strconv.Quote(value)
+		switch old := m[3]; {
+		case strings.HasPrefix(old, "'"):
+			quoted = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+		case !strings.HasPrefix(old, `"`):
+			quoted = value
+		}
+		separator := m[2]
+		if separator == "" {
+			separator = " "
+		}
+		lines[i] = key + ":" + separator + quoted + m[4] + line[len(body):]
+		return []byte(strings.Join(lines, "")), nil
+	}
+	return nil, fmt.Errorf("no top-level %s field", key)
+}
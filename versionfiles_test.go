@@ -0,0 +1,228 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPOM = `<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <parent>
+    <groupId>com.example</groupId>
+    <version>3.1.0</version>
+  </parent>
+  <artifactId>service</artifactId>
+  <!-- <version>0.0.1</version> -->
+  <version>1.2.0-SNAPSHOT</version>
+  <dependencies>
+    <dependency><artifactId>lib</artifactId><version>2.0.0</version></dependency>
+  </dependencies>
+</project>
+`
+
+func TestSetPOMVersion(t *testing.T) {
+	got, err := setPOMVersion([]byte(testPOM), "1.2.0")
+	if err != nil {
+		t.Fatalf("setPOMVersion() error = %v", err)
+	}
+	if want := strings.Replace(testPOM, "1.2.0-SNAPSHOT", "1.2.0", 1); string(got) != want {
+		t.Errorf("setPOMVersion() = %s, want %s", got, want)
+	}
+
+	// CI friendly versions are set on the property
+	revision := "<project>\n  <version>${revision}</version>\n  <properties>\n    <revision>1.0.0</revision>\n  </properties>\n</project>\n"
+	got, err = setPOMVersion([]byte(revision), "1.1.0")
+	if want := strings.Replace(revision, "1.0.0", "1.1.0", 1); err != nil || string(got) != want {
+		t.Errorf("setPOMVersion(${revision}) = %s, %v, want %s", got, err, want)
+	}
+
+	for _, pom := range []string{
+		"<project><parent><version>1.0.0</version></parent></project>",
+		"<project><version>${project.parent.version}</version></project>",
+		"<project><version>1.0.0</project>",
+	} {
+		if got, err := setPOMVersion([]byte(pom), "1.1.0"); err == nil {
+			t.Errorf("setPOMVersion(%q) = %s, want an error", pom, got)
+		}
+	}
+}
+
+func TestSetProperty(t *testing.T) {
+	tests := []struct {
+		name, data, key, want string
+	}{
+		{"equals", "group=com.example\nversion=1.0.0\n", "version", "group=com.example\nversion=1.1.0\n"},
+		{"spaced colon", "# version=0.1.0\n  version : 1.0.0\r\nname=a", "version", "# version=0.1.0\n  version : 1.1.0\r\nname=a"},
+		{"whitespace separator", "version 1.0.0", "version", "version 1.1.0"},
+		{"continued value", "version=1.\\\n  0.0\nname=a\n", "version", "version=1.1.0\nname=a\n"},
+		{"custom key", "versionName=1.0.0\nappVersion=1.0.0\n", "appVersion", "versionName=1.0.0\nappVersion=1.1.0\n"},
+		{"escaped key", "app\\ version=1.0.0\n", "app version", "app\\ version=1.1.0\n"},
+		{"key without value", "version\n", "version", "version=1.1.0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setProperty([]byte(tt.data), tt.key, "1.1.0")
+			if err != nil || string(got) != tt.want {
+				t.Errorf("setProperty() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := setProperty([]byte("# version=1.0.0\nversionCode=3\n"), "version", "1.1.0"); err == nil {
+		t.Errorf("setProperty() without the key succeeded")
+	}
+}
+
+func TestSyncVersionFiles(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	os.WriteFile(filepath.Join(dir, "pom.xml"), []byte(testPOM), 0644)
+	os.MkdirAll(filepath.Join(dir, "android"), 0755)
+	os.WriteFile(filepath.Join(dir, "android", "gradle.properties"), []byte("org.gradle.jvmargs=-Xmx2g\nVERSION_NAME=1.1.0\n"), 0644)
+	gitInTestRepo(t, dir, "add", ".")
+	gitInTestRepo(t, dir, "commit", "-q", "-m", "add version files")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	files := []VersionFileConfig{{Path: "pom.xml"}, {Path: "android/gradle.properties", Key: "VERSION_NAME"}}
+	committed, err := syncVersionFiles(files, "main", "v0.0.0", "v1.2.0")
+	if err != nil || !committed {
+		t.Fatalf("syncVersionFiles() = %v, %v, want a commit", committed, err)
+	}
+	show, _ := exec.Command("git", "show", "HEAD:android/gradle.properties").Output()
+	subject, _ := exec.Command("git", "log", "-1", "--format=%s").Output()
+	if !strings.Contains(string(show), "VERSION_NAME=1.2.0") || strings.TrimSpace(string(subject)) != "Bump version to 1.2.0" {
+		t.Errorf("HEAD = %q with %q, want the version commit", subject, show)
+	}
+
+	// A re-run finds the files at the version and commits nothing
+	if committed, err := syncVersionFiles(files, "main", "v0.0.0", "v1.2.0"); err != nil || committed {
+		t.Errorf("syncVersionFiles() again = %v, %v, want no commit", committed, err)
+	}
+
+	// Other branches and dirty work trees cannot be committed on
+	if _, err := syncVersionFiles(files, "release", "v0.0.0", "v1.3.0"); err == nil {
+		t.Errorf("syncVersionFiles() on a branch that is not checked out succeeded")
+	}
+}
+
+func TestPendingVersionFiles(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	os.WriteFile(filepath.Join(dir, "gradle.properties"), []byte("version=1.1.0\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "pom.xml"), []byte(testPOM), 0644)
+	gitInTestRepo(t, dir, "add", ".")
+	gitInTestRepo(t, dir, "commit", "-q", "-m", "add version files")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	head, _ := gitClient.RevParse("HEAD")
+
+	// Planning only finds the files that need the version
+	files := []VersionFileConfig{{Path: "pom.xml"}, {Path: "gradle.properties"}}
+	var pending []VersionFileConfig
+	var err error
+	captureOutput(func() { pending, err = pendingVersionFiles(files, "main", "v0.0.0", "v1.1.0") })
+	if want := files[:1]; err != nil || !reflect.DeepEqual(pending, want) {
+		t.Fatalf("pendingVersionFiles() = %v, %v, want %v", pending, err, want)
+	}
+	status, _ := exec.Command("git", "status", "--porcelain").Output()
+	if now, _ := gitClient.RevParse("HEAD"); now != head || len(status) > 0 {
+		t.Fatalf("pendingVersionFiles() changed the branch: HEAD %s, status %q", now, status)
+	}
+
+	// Publishing commits them and tags the new commit
+	plan := Plan{Branch: "main", TagFormat: "v0.0.0", Tag: "v1.1.0", TargetCommit: head, VersionFiles: pending}
+	captureOutput(func() { err = commitPlannedVersionFiles(&plan) })
+	if now, _ := gitClient.RevParse("HEAD"); err != nil || now == head || plan.TargetCommit != now {
+		t.Errorf("commitPlannedVersionFiles() = %v with target %s, want the new HEAD %s", err, plan.TargetCommit, now)
+	}
+
+	checks := []VersionCheckConfig{{Path: "pom.xml"}, {Path: "VERSION"}}
+	if got := withoutVersionFiles(checks, pending); !reflect.DeepEqual(got, checks[1:]) {
+		t.Errorf("withoutVersionFiles() = %v, want %v", got, checks[1:])
+	}
+}
+
+const testChart = `apiVersion: v2
+name: api
+# version: 0.0.1
+version: 0.3.0 # bumped on release
+appVersion: "1.1.0"
+dependencies:
+  - name: redis
+    version: 17.0.0
+`
+
+func TestSetChartVersion(t *testing.T) {
+	got, err := setChartVersion([]byte(testChart), "1.2.0", true)
+	want := strings.NewReplacer("version: 0.3.0 #", "version: 1.2.0 #", `"1.1.0"`, `"1.2.0"`).Replace(testChart)
+	if err != nil || string(got) != want {
+		t.Errorf("setChartVersion() = %s, %v, want %s", got, err, want)
+	}
+
+	// Charts without an appVersion, and only the appVersion
+	library := "apiVersion: v2\r\nname: lib\r\nversion: '0.1.0'\r\ntype: library\r\n"
+	got, err = setChartVersion([]byte(library), "1.2.0", true)
+	if want := strings.Replace(library, "'0.1.0'", "'1.2.0'", 1); err != nil || string(got) != want {
+		t.Errorf("setChartVersion(library) = %q, %v, want %q", got, err, want)
+	}
+	edit, _ := versionFileEditor(VersionFileConfig{Path: "charts/api/Chart.yaml", Key: "appVersion"})
+	got, err = edit([]byte(testChart), "1.2.0")
+	if want := strings.Replace(testChart, `"1.1.0"`, `"1.2.0"`, 1); err != nil || string(got) != want {
+		t.Errorf("appVersion only = %s, %v, want %s", got, err, want)
+	}
+
+	if _, err := setChartVersion([]byte("apiVersion: v2\nname: api\n"), "1.2.0", true); err == nil {
+		t.Errorf("setChartVersion() without a version succeeded")
+	}
+	if _, err := versionFileEditor(VersionFileConfig{Path: "Chart.yaml", Key: "kubeVersion"}); err == nil {
+		t.Errorf("versionFileEditor() accepted the kubeVersion key")
+	}
+}
+
+// TestPublishPlanVerifiesVersionFiles tests that the verify command sees the
+// planned versions before they are committed, and that a failing command
+// leaves the branch where it was
+func TestPublishPlanVerifiesVersionFiles(t *testing.T) {
+	dir := newBlobTestRepo(t)
+	os.WriteFile(filepath.Join(dir, "gradle.properties"), []byte("version=1.1.0\n"), 0644)
+	gitInTestRepo(t, dir, "add", ".")
+	gitInTestRepo(t, dir, "commit", "-q", "-m", "add version files")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	head, _ := gitClient.RevParse("HEAD")
+	files := []VersionFileConfig{{Path: "gradle.properties"}}
+	bus := &eventBus{handlers: make(map[string][]eventHandler)}
+
+	// Fails on the planned version: nothing is committed or tagged
+	plan := Plan{Branch: "main", TagFormat: "v0.0.0", Tag: "v1.2.0", TargetCommit: head, VersionFiles: files,
+		Verify: "grep -qx version=1.2.0 gradle.properties && exit 3"}
+	var err error
+	output := captureOutput(func() { err = publishPlan(plan, Config{}, bus) })
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Fatalf("publishPlan() = %v, want the verify command to fail on the new version\n%s", err, output)
+	}
+	status, _ := exec.Command("git", "status", "--porcelain").Output()
+	if now, _ := lookupCommit("main"); now != head || len(status) > 0 {
+		t.Errorf("a failing verify moved main to %s (status %q), want it at %s", now, status, head)
+	}
+	if tags, _ := gitClient.ListTags(""); len(tags) > 0 {
+		t.Errorf("tags %v created", tags)
+	}
+
+	// Passes: the version is committed and the commit tagged
+	plan.Verify = "grep -qx version=1.2.0 gradle.properties"
+	output = captureOutput(func() { err = publishPlan(plan, Config{}, bus) })
+	now, _ := lookupCommit("main")
+	if tagged, _ := gitClient.RevParse("v1.2.0^{commit}"); err != nil || now == head || tagged != now {
+		t.Errorf("publishPlan() = %v, tag at %s, main at %s, want the version commit tagged\n%s", err, tagged, now, output)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver is a MAJOR.MINOR.PATCH version of a tag
+type semver [3]int
+
+// compare returns -1, 0 or 1 as v is lower than, equal to or higher than o
+func (v semver) compare(o semver) int {
+	for i := range v {
+		switch {
+		case v[i] < o[i]:
+			return -1
+		case v[i] > o[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v semver) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// parseSemver parses the version of a tag without its prefix
+func parseSemver(version string) (semver, bool) {
+	var v semver
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+// comparator is a single condition of a version range, e.g. >=1.4.0
+type comparator struct {
+	Op      string
+	Version semver
+}
+
+func (c comparator) matches(v semver) bool {
+	cmp := v.compare(c.Version)
+	switch c.Op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+// versionRange is a semver range as npm writes it: sets of comparators that
+// all have to match, of which one set has to match
+type versionRange [][]comparator
+
+// matches reports whether v satisfies the range
+func (r versionRange) matches(v semver) bool {
+	for _, set := range r {
+		ok := true
+		for _, c := range set {
+			if !c.matches(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeOperators are the operators of a comparator, longest first
+var rangeOperators = []string{">=", "<=", ">", "<", "=", "~", "^"}
+
+// parseVersionRange parses a range like ">=1.4.0 <2.0.0", "1.x || ^2.1" or
+// "~1.4". Versions may omit parts or use x or * for them, and may start with v.
+func parseVersionRange(spec string) (versionRange, error) {
+	var r versionRange
+	for _, part := range strings.Split(spec, "||") {
+		fields := strings.Fields(part)
+		set := []comparator{}
+		for i := 0; i < len(fields); i++ {
+			token := fields[i]
+			// An operator may be separated from its version: ">= 1.4.0"
+			if contains(rangeOperators, token) && i+1 < len(fields) {
+				i++
+				token += fields[i]
+			}
+			comparators, err := parseComparator(token)
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, comparators...)
+		}
+		if len(fields) == 0 && strings.TrimSpace(spec) != "" {
+			return nil, fmt.Errorf("empty alternative in range %q", spec)
+		}
+		r = append(r, set)
+	}
+	return r, nil
+}
+
+// parseComparator turns one token of a range into comparators on full versions
+func parseComparator(token string) ([]comparator, error) {
+	op := ""
+	for _, candidate := range rangeOperators {
+		if strings.HasPrefix(token, candidate) {
+			op = candidate
+			break
+		}
+	}
+	partial := strings.TrimPrefix(strings.TrimPrefix(token, op), "v")
+	if partial == "" && op != "" {
+		return nil, fmt.Errorf("operator %s has no version", op)
+	}
+
+	// n is the number of given parts; x, X and * end the version
+	var v semver
+	n := 0
+	if partial != "" && partial != "*" && partial != "x" && partial != "X" {
+		for _, part := range strings.Split(partial, ".") {
+			if n == 3 {
+				return nil, fmt.Errorf("%q is not a version like 1.4.0", token)
+			}
+			if part == "x" || part == "X" || part == "*" {
+				break
+			}
+			number, err := strconv.Atoi(part)
+			if err != nil || number < 0 {
+				return nil, fmt.Errorf("%q is not a version like 1.4.0", token)
+			}
+			v[n] = number
+			n++
+		}
+	}
+
+	// next is the first version after the given parts, e.g. 1.5.0 for 1.4
+	next := func(parts int) semver {
+		bumped := v
+		bumped[parts-1]++
+		for i := parts; i < 3; i++ {
+			bumped[i] = 0
+		}
+		return bumped
+	}
+	between := func(upper semver) []comparator {
+		return []comparator{{">=", v}, {"<", upper}}
+	}
+
+	switch {
+	case n == 0:
+		// Any version, except that nothing is below or above everything
+		if op == "<" || op == ">" {
+			return []comparator{{"<", semver{}}}, nil
+		}
+		return nil, nil
+	case op == "" || op == "=":
+		if n == 3 {
+			return []comparator{{"=", v}}, nil
+		}
+		return between(next(n)), nil
+	case op == ">":
+		if n == 3 {
+			return []comparator{{">", v}}, nil
+		}
+		return []comparator{{">=", next(n)}}, nil
+	case op == ">=" || op == "<":
+		return []comparator{{op, v}}, nil
+	case op == "<=":
+		if n == 3 {
+			return []comparator{{"<=", v}}, nil
+		}
+		return []comparator{{"<", next(n)}}, nil
+	case op == "~":
+		if n == 1 {
+			return between(next(1)), nil
+		}
+		return between(next(2)), nil
+	default: // ^ allows changes that keep the first non-zero part
+		for i := 0; i < n; i++ {
+			if v[i] != 0 || i == n-1 {
+				return between(next(i + 1)), nil
+			}
+		}
+		return between(next(n)), nil
+	}
+}
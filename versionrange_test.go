@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseVersionRange(t *testing.T) {
+	tests := []struct {
+		spec string
+		in   []string
+		out  []string
+	}{
+		{"", []string{"0.0.0", "9.9.9"}, nil},
+		{">=1.4.0 <2.0.0", []string{"1.4.0", "1.9.12"}, []string{"1.3.9", "2.0.0"}},
+		{">= 1.4.0 < 2", []string{"1.4.0", "1.9.12"}, []string{"1.3.9", "2.0.0"}},
+		{"1.x", []string{"1.0.0", "1.99.3"}, []string{"0.9.9", "2.0.0"}},
+		{"1.4", []string{"1.4.0", "1.4.7"}, []string{"1.5.0", "1.3.0"}},
+		{"=v1.4.2", []string{"1.4.2"}, []string{"1.4.3"}},
+		{"~1.4.2", []string{"1.4.2", "1.4.9"}, []string{"1.4.1", "1.5.0"}},
+		{"~1", []string{"1.0.0", "1.7.0"}, []string{"2.0.0"}},
+		{"^1.4.2", []string{"1.4.2", "1.9.0"}, []string{"1.4.1", "2.0.0"}},
+		{"^0.4.2", []string{"0.4.2", "0.4.9"}, []string{"0.5.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{">1.4", []string{"1.5.0"}, []string{"1.4.9"}},
+		{"<=1.4", []string{"1.4.9"}, []string{"1.5.0"}},
+		{"<1.4", []string{"1.3.9"}, []string{"1.4.0"}},
+		{"1.2.x || >=3.0.0", []string{"1.2.5", "3.1.0"}, []string{"1.3.0", "2.0.0"}},
+		{"<*", nil, []string{"0.0.0", "1.0.0"}},
+	}
+	for _, tt := range tests {
+		r, err := parseVersionRange(tt.spec)
+		if err != nil {
+			t.Errorf("parseVersionRange(%q) error = %v", tt.spec, err)
+			continue
+		}
+		for _, version := range tt.in {
+			if v, _ := parseSemver(version); !r.matches(v) {
+				t.Errorf("range %q does not match %s, want a match", tt.spec, version)
+			}
+		}
+		for _, version := range tt.out {
+			if v, _ := parseSemver(version); r.matches(v) {
+				t.Errorf("range %q matches %s, want no match", tt.spec, version)
+			}
+		}
+	}
+}
+
+func TestParseVersionRangeErrors(t *testing.T) {
+	for _, spec := range []string{">=", "1.2.3.4", "1.a", ">=1.0.0 ||", "latest"} {
+		if _, err := parseVersionRange(spec); err == nil {
+			t.Errorf("parseVersionRange(%q) succeeded, want an error", spec)
+		} else if strings.TrimSpace(err.Error()) == "" {
+			t.Errorf("parseVersionRange(%q) returned an empty error", spec)
+		}
+	}
+}
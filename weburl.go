@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// webBaseURL returns the address of the web interface of the host of a remote:
+// the scheme and port of HTTP(S) remotes are kept, SSH remotes map to https
+func webBaseURL(remoteURL string, info remoteInfo) string {
+	if u, err := url.Parse(strings.TrimSpace(remoteURL)); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return u.Scheme + "://" + u.Host
+	}
+	return "https://" + info.Host
+}
+
+// tagWebURL returns the page of tag on the hosting service of a remote,
+// derived from the remote URL alone: the release page where the service shows
+// releases by tag, the tag otherwise. It returns "" for remotes without a
+// known hosting service.
+func tagWebURL(remoteURL string, override ProviderConfig, tag string) string {
+	info, ok := parseRemoteURL(remoteURL)
+	if !ok {
+		return ""
+	}
+	base := webBaseURL(remoteURL, info)
+	escaped := url.PathEscape(tag)
+
+	providerType := strings.ToLower(override.Type)
+	if providerType == "" {
+		providerType = detectProviderType(info)
+	}
+	switch providerType {
+	case providerGitHub, providerGitea:
+		return base + "/" + info.Owner + "/" + info.Repo + "/releases/tag/" + escaped
+	case providerGitLab:
+		return base + "/" + info.Owner + "/" + info.Repo + "/-/tags/" + escaped
+	case providerBitbucket:
+		project := strings.TrimPrefix(info.Owner, "scm/")
+		return base + "/projects/" + project + "/repos/" + info.Repo + "/browse?at=" + url.QueryEscape("refs/tags/"+tag)
+	case providerAzure:
+		org, project, _ := strings.Cut(info.Owner, "/")
+		return "https://dev.azure.com/" + org + "/" + url.PathEscape(project) + "/_git/" + info.Repo + "?version=GT" + url.QueryEscape(tag)
+	}
+	if strings.EqualFold(info.Host, "bitbucket.org") {
+		return base + "/" + info.Owner + "/" + info.Repo + "/src/" + escaped
+	}
+	return ""
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestTagWebURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		override ProviderConfig
+		tag      string
+		want     string
+	}{
+		{"git@github.com:acme/tool.git", ProviderConfig{}, "v1.2.0", "https://github.com/acme/tool/releases/tag/v1.2.0"},
+		{"https://github.example.com:8443/acme/tool.git", ProviderConfig{}, "v1.2.0", "https://github.example.com:8443/acme/tool/releases/tag/v1.2.0"},
+		{"ssh://git@gitlab.com:2222/group/sub/tool.git", ProviderConfig{}, "v1.2.0", "https://gitlab.com/group/sub/tool/-/tags/v1.2.0"},
+		{"git@codeberg.org:acme/tool.git", ProviderConfig{}, "release/1.0.0", "https://codeberg.org/acme/tool/releases/tag/release%2F1.0.0"},
+		{"https://git.example.com/scm/PROJ/tool.git", ProviderConfig{}, "v1.2.0", "https://git.example.com/projects/PROJ/repos/tool/browse?at=refs%2Ftags%2Fv1.2.0"},
+		{"git@bitbucket.org:acme/tool.git", ProviderConfig{}, "v1.2.0", "https://bitbucket.org/acme/tool/src/v1.2.0"},
+		{"https://dev.azure.com/org/My%20Project/_git/tool", ProviderConfig{}, "v1.2.0", "https://dev.azure.com/org/My%20Project/_git/tool?version=GTv1.2.0"},
+		{"http://git.internal/acme/tool.git", ProviderConfig{Type: "gitea"}, "v1.2.0", "http://git.internal/acme/tool/releases/tag/v1.2.0"},
+		{"https://git.internal/acme/tool.git", ProviderConfig{}, "v1.2.0", ""},
+		{"/srv/git/tool.git", ProviderConfig{Type: "github"}, "v1.2.0", ""},
+	}
+
+	for _, tt := range tests {
+		if got := tagWebURL(tt.url, tt.override, tt.tag); got != tt.want {
+			t.Errorf("tagWebURL(%q, %+v, %q) = %q, want %q", tt.url, tt.override, tt.tag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// navigation is what the answer to a question of the interactive flow asks
+// the flow to do next
+type navigation int
+
+const (
+	// navigateNone answered the question; a step returning it continues with
+	// the next step
+	navigateNone navigation = iota
+	// navigateBack returns to the previous step that asked a question
+	navigateBack
+	// navigateCancel ends the flow without tagging
+	navigateCancel
+)
+
+// parseNavigation reads b (or Esc) and q in the answer to a step question.
+// Only the questions that make up the steps, asked through the Step methods
+// of Prompter, offer navigation: other answers, such as ticket IDs, trailer
+// values or the name of a remote, may well be b or q.
+func parseNavigation(input string) navigation {
+	switch strings.ToLower(input) {
+	case "b", "back", "\x1b":
+		return navigateBack
+	case "q", "quit":
+		return navigateCancel
+	}
+	return navigateNone
+}
+
+// flowStep is a question, or a few related ones, of the interactive flow.
+// Steps keep their answers in variables of the caller, so running a step
+// again after going back simply overwrites them.
+type flowStep struct {
+	name string
+	// run asks the questions of the step and returns the navigation answered
+	// to one of them, navigateNone once the step is done
+	run func() navigation
+}
+
+// countingPrompter counts the questions asked through it, telling steps that
+// asked something from steps that went by without a question
+type countingPrompter struct {
+	Prompter
+	asked int
+}
+
+func (p *countingPrompter) Select(title, noun string, choices []choice) int {
+	p.asked++
+	return p.Prompter.Select(title, noun, choices)
+}
+
+func (p *countingPrompter) Confirm(question string, def bool) bool {
+	p.asked++
+	return p.Prompter.Confirm(question, def)
+}
+
+func (p *countingPrompter) Input(question, def string, validate func(string) error) (string, error) {
+	p.asked++
+	return p.Prompter.Input(question, def, validate)
+}
+
+func (p *countingPrompter) SelectStep(title, noun string, choices []choice) (int, navigation) {
+	p.asked++
+	return p.Prompter.SelectStep(title, noun, choices)
+}
+
+func (p *countingPrompter) ConfirmStep(question string, def bool) (bool, navigation) {
+	p.asked++
+	return p.Prompter.ConfirmStep(question, def)
+}
+
+func (p *countingPrompter) InputStep(question, def string, validate func(string) error) (string, navigation, error) {
+	p.asked++
+	return p.Prompter.InputStep(question, def, validate)
+}
+
+// runSteps runs the steps in order and returns false when the flow was
+// cancelled. Going back re-runs the last step that asked a question; steps
+// answered by flags or without choices are skipped on the way back.
+func runSteps(steps []flowStep) bool {
+	original := prompter
+	counter := &countingPrompter{Prompter: original}
+	prompter = counter
+	defer func() { prompter = original }()
+
+	if isInteractive() {
+		fmt.Println("Answer b to go back to the previous question, q to quit.")
+	}
+	// asked holds the completed steps that asked a question, in order
+	var asked []int
+	for i := 0; i < len(steps); {
+		before := counter.asked
+		switch steps[i].run() {
+		case navigateCancel:
+			return false
+		case navigateBack:
+			if len(asked) == 0 {
+				fmt.Println("This is the first question.")
+				continue
+			}
+			i, asked = asked[len(asked)-1], asked[:len(asked)-1]
+			logFor(logUI).Debug("going back", "step", steps[i].name)
+		default:
+			if counter.asked > before {
+				asked = append(asked, i)
+			}
+			i++
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers []string
+		want    []string
+		wantOK  bool
+	}{
+		{"straight through", []string{"2", "v1.0.0", "y"}, []string{"develop", "v1.0.0", "push"}, true},
+		{"back to the branch", []string{"2", "b", "1", "v1.0.0", "n"}, []string{"main", "v1.0.0", "no push"}, true},
+		// The quiet step between the tag and the push is skipped on the way back
+		{"back over a step without questions", []string{"1", "v1.0.0", "b", "v1.1.0", "y"}, []string{"main", "v1.1.0", "push"}, true},
+		{"back at the first question", []string{"b", "2", "v1.0.0", "y"}, []string{"develop", "v1.0.0", "push"}, true},
+		{"quit", []string{"1", "q"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useScriptedPrompter(t, tt.answers...)
+			var branch, tag, push string
+			quietRuns := 0
+			steps := []flowStep{
+				{"branch", func() navigation {
+					index, nav := prompter.SelectStep("Select branch:", "branch", []choice{{Name: "main"}, {Name: "develop"}})
+					branch = []string{"main", "develop"}[index]
+					return nav
+				}},
+				{"tag", func() navigation {
+					var nav navigation
+					var err error
+					if tag, nav, err = prompter.InputStep("Enter tag:", "", func(string) error { return nil }); err != nil {
+						return navigateCancel
+					}
+					return nav
+				}},
+				{"quiet", func() navigation {
+					quietRuns++
+					return navigateNone
+				}},
+				{"push", func() navigation {
+					yes, nav := prompter.ConfirmStep("Push?", true)
+					push = "no push"
+					if yes {
+						push = "push"
+					}
+					return nav
+				}},
+			}
+
+			var ok bool
+			captureOutput(func() { ok = runSteps(steps) })
+			if ok != tt.wantOK {
+				t.Fatalf("runSteps() = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if got := []string{branch, tag, push}; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("answers = %q, want %q", got, tt.want)
+			}
+			if _, counting := prompter.(*countingPrompter); counting {
+				t.Error("runSteps() left its counting prompter installed")
+			}
+		})
+	}
+}
+
+func TestNavigationOnlyAtStepQuestions(t *testing.T) {
+	// Outside the flow b is an ordinary answer, e.g. to a yes/no question
+	useScriptedPrompter(t, "b")
+	if prompter.Confirm("Continue?", true) {
+		t.Error(`Confirm() = true for "b", want false`)
+	}
+
+	// Within a step, only the questions of the step navigate: q is a ticket
+	// and b a trailer value
+	useScriptedPrompter(t, "q", "b", "q")
+	var ticket, trailer string
+	steps := []flowStep{
+		{"ticket", func() navigation {
+			var err error
+			if ticket, err = prompter.Input("Ticket:", "", func(string) error { return nil }); err != nil {
+				return navigateCancel
+			}
+			if trailer, err = prompter.Input("Reviewed-by:", "", func(string) error { return nil }); err != nil {
+				return navigateCancel
+			}
+			return navigateNone
+		}},
+		{"remote", func() navigation {
+			_, nav := prompter.ConfirmStep("Push?", true)
+			return nav
+		}},
+	}
+	var ok bool
+	captureOutput(func() { ok = runSteps(steps) })
+	if ok || ticket != "q" || trailer != "b" {
+		t.Errorf("runSteps() = %v with ticket %q and trailer %q, want cancelled at the push with q and b as answers", ok, ticket, trailer)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// inWorktree checks out commit in a temporary detached work tree, runs fn in
+// its root and removes the work tree again. Commands run there check the
+// commit being tagged, whatever is checked out in the repository and whether
+// or not its working tree is clean.
+func inWorktree(commit string, fn func(dir string) error) error {
+	parent, err := os.MkdirTemp("", "git-publish-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(parent)
+	dir := filepath.Join(parent, "worktree")
+	if err := gitClient.AddWorktree(dir, commit); err != nil {
+		return fmt.Errorf("checking out %s: %v", shortHash(commit), err)
+	}
+	defer func() {
+		if err := gitClient.RemoveWorktree(dir); err != nil {
+			fmt.Printf("Warning: could not remove the work tree %s: %v\n", dir, err)
+		}
+	}()
+	return fn(dir)
+}